@@ -12,17 +12,44 @@ const DefaultPath = ".dm.yaml"
 const EnvName = "DM_CONFIG"
 
 type Config struct {
-	Proxy            string `yaml:"proxy"`
-	TargetOS         string `yaml:"os"`
-	Arch             string `yaml:"arch"`
-	OutputDir        string `yaml:"output_dir"`
-	DockerHost       string `yaml:"docker_host"`
-	DockerTLSVerify  *bool  `yaml:"docker_tls_verify"`
-	DockerCertPath   string `yaml:"docker_cert_path"`
-	DockerAPIVersion string `yaml:"docker_api_version"`
-	Verbose          bool   `yaml:"verbose"`
-	Quiet            bool   `yaml:"quiet"`
-	JSON             bool   `yaml:"log_json"`
+	Proxy            string   `yaml:"proxy"`
+	TargetOS         string   `yaml:"os"`
+	Arch             string   `yaml:"arch"`
+	OutputDir        string   `yaml:"output_dir"`
+	CacheDir         string   `yaml:"cache_dir"`
+	DockerHost       string   `yaml:"docker_host"`
+	DockerTLSVerify  *bool    `yaml:"docker_tls_verify"`
+	DockerCertPath   string   `yaml:"docker_cert_path"`
+	DockerAPIVersion string   `yaml:"docker_api_version"`
+	Verbose          bool     `yaml:"verbose"`
+	Quiet            bool     `yaml:"quiet"`
+	JSON             bool     `yaml:"log_json"`
+	S3               S3Config `yaml:"s3"`
+	// RegistryMirrors maps a registry name (docker.io, ghcr.io, ...) to the
+	// mirrors dm pull tries in order before falling back to the registry.
+	RegistryMirrors map[string][]RegistryMirror `yaml:"registry_mirrors"`
+}
+
+// RegistryMirror is one pull endpoint for a registry, modelled on containerd
+// hosts.toml. Endpoint is host[:port] or an http(s):// URL; an http:// URL
+// implies plain HTTP.
+type RegistryMirror struct {
+	Endpoint   string `yaml:"endpoint"`
+	PlainHTTP  bool   `yaml:"plain_http"`
+	CAFile     string `yaml:"ca_file"`
+	SkipVerify bool   `yaml:"skip_verify"`
+}
+
+// S3Config holds object storage settings used by s3:// backup targets. Empty
+// fields fall back to the standard AWS_* environment variables.
+type S3Config struct {
+	Endpoint        string `yaml:"endpoint"`
+	Region          string `yaml:"region"`
+	AccessKeyID     string `yaml:"access_key_id"`
+	SecretAccessKey string `yaml:"secret_access_key"`
+	SessionToken    string `yaml:"session_token"`
+	PathStyle       *bool  `yaml:"path_style"`
+	PartSizeMB      int    `yaml:"part_size_mb"`
 }
 
 func Load(path string) (Config, error) {
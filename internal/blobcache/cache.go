@@ -0,0 +1,254 @@
+package blobcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	digest "github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+const tempDir = "tmp"
+
+// DefaultDir is where blobs are cached when .dm.yaml sets no cache_dir:
+// <user cache dir>/docker-manager/blobs, falling back to the system temp dir.
+func DefaultDir() string {
+	base, err := os.UserCacheDir()
+	if err != nil || base == "" {
+		base = os.TempDir()
+	}
+	return filepath.Join(base, "docker-manager", "blobs")
+}
+
+// Cache is a content-addressed store of registry blobs laid out like an OCI
+// layout's blobs directory (<root>/sha256/<hex>). Entries are written through
+// a temp file and renamed, so concurrent pulls in one or several processes
+// never observe a partial blob; callers verify digests before handing a blob
+// over, which also catches two processes racing on the same temp file.
+type Cache struct {
+	root string
+
+	mu      sync.Mutex
+	pending map[digest.Digest]*sync.Mutex
+}
+
+// Entry is one cached blob. LastUsed is the file mtime, refreshed on every
+// cache hit so prune can evict least recently used blobs first.
+type Entry struct {
+	Digest   digest.Digest `json:"digest"`
+	Size     int64         `json:"size"`
+	LastUsed time.Time     `json:"last_used"`
+	Path     string        `json:"path"`
+}
+
+// Open returns the cache rooted at root. Directories are created on the first
+// write, so commands that fail early leave nothing behind.
+func Open(root string) (*Cache, error) {
+	if root == "" {
+		root = DefaultDir()
+	}
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	return &Cache{root: root, pending: map[digest.Digest]*sync.Mutex{}}, nil
+}
+
+func (c *Cache) Root() string {
+	return c.root
+}
+
+// Path is where the blob with digest d lives, whether or not it is cached.
+func (c *Cache) Path(d digest.Digest) string {
+	return filepath.Join(c.root, d.Algorithm().String(), d.Encoded())
+}
+
+// TempPath is the fixed location fill writes desc to before it is moved into
+// place. Being stable across runs, anything the downloader keeps next to it
+// (such as a partially downloaded .part file) survives a failed pull and can be
+// resumed by the next one.
+func (c *Cache) TempPath(d digest.Digest) string {
+	return filepath.Join(c.root, tempDir, d.Encoded())
+}
+
+// Fetch returns the cached path of desc, calling fill to populate it on a
+// miss. fill must write the complete, digest-verified blob to the path it is
+// given. Concurrent callers for the same digest wait for the first one, so a
+// base layer shared by a whole batch is downloaded once. A cached file whose
+// size disagrees with desc is treated as corrupt and fetched again.
+func (c *Cache) Fetch(ctx context.Context, desc ocispec.Descriptor, fill func(path string) error) (string, bool, error) {
+	if err := desc.Digest.Validate(); err != nil {
+		return "", false, fmt.Errorf("无效的 blob digest %q: %w", desc.Digest, err)
+	}
+	unlock := c.lock(desc.Digest)
+	defer unlock()
+	if err := ctx.Err(); err != nil {
+		return "", false, err
+	}
+
+	path := c.Path(desc.Digest)
+	if info, err := os.Stat(path); err == nil {
+		if desc.Size <= 0 || info.Size() == desc.Size {
+			now := time.Now()
+			_ = os.Chtimes(path, now, now)
+			return path, true, nil
+		}
+		_ = os.Remove(path)
+	}
+
+	if err := os.MkdirAll(filepath.Join(c.root, tempDir), 0755); err != nil {
+		return "", false, fmt.Errorf("创建 blob 缓存目录失败: %w", err)
+	}
+	tmpPath := c.TempPath(desc.Digest)
+	if err := fill(tmpPath); err != nil {
+		_ = os.Remove(tmpPath)
+		return "", false, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		_ = os.Remove(tmpPath)
+		return "", false, err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		_ = os.Remove(tmpPath)
+		return "", false, err
+	}
+	return path, false, nil
+}
+
+// lock serializes work on one digest within this process.
+func (c *Cache) lock(d digest.Digest) func() {
+	c.mu.Lock()
+	m, ok := c.pending[d]
+	if !ok {
+		m = &sync.Mutex{}
+		c.pending[d] = m
+	}
+	c.mu.Unlock()
+	m.Lock()
+	return m.Unlock
+}
+
+// List returns every cached blob, least recently used first.
+func (c *Cache) List() ([]Entry, error) {
+	var entries []Entry
+	algorithms, err := os.ReadDir(c.root)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	for _, algorithm := range algorithms {
+		if !algorithm.IsDir() || algorithm.Name() == tempDir {
+			continue
+		}
+		files, err := os.ReadDir(filepath.Join(c.root, algorithm.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			d := digest.NewDigestFromEncoded(digest.Algorithm(algorithm.Name()), file.Name())
+			if file.IsDir() || d.Validate() != nil {
+				continue
+			}
+			info, err := file.Info()
+			if err != nil {
+				if errors.Is(err, os.ErrNotExist) {
+					continue
+				}
+				return nil, err
+			}
+			entries = append(entries, Entry{
+				Digest:   d,
+				Size:     info.Size(),
+				LastUsed: info.ModTime(),
+				Path:     filepath.Join(c.root, algorithm.Name(), file.Name()),
+			})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].LastUsed.Equal(entries[j].LastUsed) {
+			return entries[i].Digest < entries[j].Digest
+		}
+		return entries[i].LastUsed.Before(entries[j].LastUsed)
+	})
+	return entries, nil
+}
+
+// PrunePolicy selects blobs to evict. Zero values disable a rule; with no
+// rule set nothing is selected unless All is true.
+type PrunePolicy struct {
+	All       bool
+	OlderThan time.Duration
+	MaxSize   int64
+}
+
+// Plan splits entries (as returned by List) into blobs to keep and to remove.
+// Blobs unused for longer than OlderThan go first; then the least recently
+// used blobs are removed until the remainder fits in MaxSize.
+func Plan(entries []Entry, policy PrunePolicy, now time.Time) (keep, remove []Entry) {
+	if policy.All {
+		return nil, append([]Entry(nil), entries...)
+	}
+	var total int64
+	for _, entry := range entries {
+		if policy.OlderThan > 0 && now.Sub(entry.LastUsed) > policy.OlderThan {
+			remove = append(remove, entry)
+			continue
+		}
+		keep = append(keep, entry)
+		total += entry.Size
+	}
+	if policy.MaxSize <= 0 {
+		return keep, remove
+	}
+	for len(keep) > 0 && total > policy.MaxSize {
+		total -= keep[0].Size
+		remove = append(remove, keep[0])
+		keep = keep[1:]
+	}
+	return keep, remove
+}
+
+// Remove deletes the given entries and any temp files abandoned by
+// interrupted pulls.
+func (c *Cache) Remove(ctx context.Context, entries []Entry) error {
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		unlock := c.lock(entry.Digest)
+		err := os.Remove(c.Path(entry.Digest))
+		unlock()
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return c.removeStaleTemp(time.Hour)
+}
+
+func (c *Cache) removeStaleTemp(age time.Duration) error {
+	files, err := os.ReadDir(filepath.Join(c.root, tempDir))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	for _, file := range files {
+		info, err := file.Info()
+		if err != nil || time.Since(info.ModTime()) < age {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(c.root, tempDir, file.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
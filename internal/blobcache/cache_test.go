@@ -0,0 +1,99 @@
+package blobcache
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	digest "github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestFetchDownloadsEachDigestOnceUnderConcurrency(t *testing.T) {
+	cache, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []byte("shared-base-layer")
+	desc := ocispec.Descriptor{Digest: digest.FromBytes(data), Size: int64(len(data))}
+	var fills atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			path, _, err := cache.Fetch(context.Background(), desc, func(path string) error {
+				fills.Add(1)
+				return os.WriteFile(path, data, 0644)
+			})
+			if err != nil {
+				t.Errorf("Fetch() error = %v", err)
+				return
+			}
+			if got, _ := os.ReadFile(path); string(got) != string(data) {
+				t.Errorf("cached blob = %q", got)
+			}
+		}()
+	}
+	wg.Wait()
+	if fills.Load() != 1 {
+		t.Fatalf("fill calls = %d, want 1", fills.Load())
+	}
+	if _, hit, err := cache.Fetch(context.Background(), desc, nil); err != nil || !hit {
+		t.Fatalf("Fetch() hit = %v err = %v, want cache hit", hit, err)
+	}
+}
+
+func TestFetchRefetchesTruncatedBlobAndDropsFailedFill(t *testing.T) {
+	cache, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []byte("layer-data")
+	desc := ocispec.Descriptor{Digest: digest.FromBytes(data), Size: int64(len(data))}
+	if err := os.MkdirAll(filepath.Dir(cache.Path(desc.Digest)), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(cache.Path(desc.Digest), data[:3], 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := cache.Fetch(context.Background(), desc, func(string) error { return errors.New("digest mismatch") }); err == nil {
+		t.Fatal("Fetch() error = nil, want fill error")
+	}
+	if _, err := os.Stat(cache.Path(desc.Digest)); !os.IsNotExist(err) {
+		t.Fatalf("truncated blob still cached: %v", err)
+	}
+	_, hit, err := cache.Fetch(context.Background(), desc, func(path string) error { return os.WriteFile(path, data, 0644) })
+	if err != nil || hit {
+		t.Fatalf("Fetch() hit = %v err = %v, want fresh download", hit, err)
+	}
+	entries, err := cache.List()
+	if err != nil || len(entries) != 1 || entries[0].Size != desc.Size {
+		t.Fatalf("List() = %#v, %v", entries, err)
+	}
+}
+
+func TestPlanEvictsOldBlobsThenLeastRecentlyUsedUntilUnderMaxSize(t *testing.T) {
+	now := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	entries := []Entry{
+		{Digest: "sha256:a", Size: 10, LastUsed: now.Add(-30 * 24 * time.Hour)},
+		{Digest: "sha256:b", Size: 40, LastUsed: now.Add(-3 * time.Hour)},
+		{Digest: "sha256:c", Size: 30, LastUsed: now.Add(-2 * time.Hour)},
+		{Digest: "sha256:d", Size: 20, LastUsed: now.Add(-1 * time.Hour)},
+	}
+	keep, remove := Plan(entries, PrunePolicy{OlderThan: 7 * 24 * time.Hour, MaxSize: 50}, now)
+	if len(remove) != 2 || remove[0].Digest != "sha256:a" || remove[1].Digest != "sha256:b" {
+		t.Fatalf("remove = %#v", remove)
+	}
+	if len(keep) != 2 || keep[0].Digest != "sha256:c" || keep[1].Digest != "sha256:d" {
+		t.Fatalf("keep = %#v", keep)
+	}
+	if keep, remove := Plan(entries, PrunePolicy{All: true}, now); len(keep) != 0 || len(remove) != 4 {
+		t.Fatalf("all: keep=%d remove=%d", len(keep), len(remove))
+	}
+}
@@ -206,7 +206,7 @@ func TestRootCommandExposesLeafShortcuts(t *testing.T) {
 	opts := outputOptions{}
 	cmd := newRootCommand(&cfg, &opts)
 
-	for _, name := range []string{"pull", "load", "save", "tree", "health", "network", "logs", "diff", "prune", "volumes", "registry"} {
+	for _, name := range []string{"pull", "load", "save", "tree", "health", "network", "logs", "diff", "prune", "volumes"} {
 		sub, _, err := cmd.Find([]string{name})
 		if err != nil {
 			t.Fatalf("Find(%s) error = %v", name, err)
@@ -218,6 +218,24 @@ func TestRootCommandExposesLeafShortcuts(t *testing.T) {
 			t.Fatalf("%s should be a leaf shortcut, got subcommands %#v", name, sub.Commands())
 		}
 	}
+	// dm registry keeps running the registry report and also holds the
+	// remote browse commands; dm report registry stays a leaf.
+	registry, args, err := cmd.Find([]string{"registry", "registry.local:5000"})
+	if err != nil {
+		t.Fatalf("Find(registry registry.local:5000) error = %v", err)
+	}
+	if registry == nil || registry.Name() != "registry" || registry.RunE == nil || len(args) != 1 {
+		t.Fatalf("Find(registry registry.local:5000) = %#v args=%v, want registry report", registry, args)
+	}
+	for _, name := range []string{"tags", "inspect", "catalog", "prune"} {
+		sub, _, err := cmd.Find([]string{"registry", name})
+		if err != nil {
+			t.Fatalf("Find(registry %s) error = %v", name, err)
+		}
+		if sub == nil || sub.Name() != name || sub.Parent().Name() != "registry" {
+			t.Fatalf("Find(registry %s) = %#v, want registry browse command", name, sub)
+		}
+	}
 	report, _, err := cmd.Find([]string{"report", "registry"})
 	if err != nil {
 		t.Fatalf("Find(report registry) error = %v", err)
@@ -225,6 +243,9 @@ func TestRootCommandExposesLeafShortcuts(t *testing.T) {
 	if report == nil || report.Name() != "registry" {
 		t.Fatalf("Find(report registry) = %#v, want registry report command", report)
 	}
+	if len(report.Commands()) != 0 {
+		t.Fatalf("report registry should be a leaf, got subcommands %#v", report.Commands())
+	}
 	reportAll, _, err := cmd.Find([]string{"report", "all"})
 	if err != nil {
 		t.Fatalf("Find(report all) error = %v", err)
@@ -3,6 +3,7 @@ package cli
 import (
 	"context"
 	"docker-manager/internal/commands/backup"
+	"docker-manager/internal/commands/cache"
 	"docker-manager/internal/commands/diagnostics"
 	"docker-manager/internal/commands/images"
 	"docker-manager/internal/commands/pull"
@@ -104,8 +105,14 @@ func newRootCommand(cfg *appConfig, opts *outputOptions) *cobra.Command {
 	rootCmd.PersistentFlags().StringVar(&dockerAPIVersion, "docker-api-version", "", "Docker API 版本，默认读取 DOCKER_API_VERSION 或自动协商")
 
 	commandSet := newRootCommandSet(cfg)
-	rootCmd.AddCommand(backup.NewBackupCommand())
-	rootCmd.AddCommand(backup.NewRestoreCommand())
+	backupDefaults := func() backup.CommandDefaults {
+		return backup.CommandDefaults{S3: cfg.S3}
+	}
+	rootCmd.AddCommand(backup.NewBackupCommandWithDefaults(backupDefaults))
+	rootCmd.AddCommand(backup.NewRestoreCommandWithDefaults(backupDefaults))
+	rootCmd.AddCommand(cache.NewCacheCommandWithDefaults(func() cache.CommandDefaults {
+		return cache.CommandDefaults{Dir: cfg.CacheDir}
+	}))
 	rootCmd.AddCommand(commandSet.newImageGroup())
 	rootCmd.AddCommand(commandSet.newReportGroup())
 	rootCmd.AddCommand(commandSet.newImageShortcuts()...)
@@ -113,6 +120,7 @@ func newRootCommand(cfg *appConfig, opts *outputOptions) *cobra.Command {
 	rootCmd.AddCommand(diagnostics.NewDoctorCommandWithDefaults(func() diagnostics.DoctorDefaults {
 		return diagnostics.DoctorDefaults{ConfigPath: effectiveConfigPath, OutputDir: cfg.OutputDir}
 	}))
+	rootCmd.AddCommand(pull.NewSyncCommandWithDefaults(pullDefaults(cfg)))
 	rootCmd.AddCommand(completion.NewCommand())
 	rootCmd.AddCommand(version.NewCommand())
 	rootCmd.AddCommand(reverse.NewReverseCommand())
@@ -128,18 +136,13 @@ type commandFactory struct {
 type rootCommandSet struct {
 	image  []commandFactory
 	report []commandFactory
+	// registry 是只挂在 dm registry 快捷命令下的远程仓库子命令，dm report registry 保持叶子命令。
+	registry func() []*cobra.Command
 }
 
 func newRootCommandSet(cfg *appConfig) rootCommandSet {
 	pullCommand := func() *cobra.Command {
-		return pull.NewPullCommandWithDefaults(func() pull.CommandDefaults {
-			return pull.CommandDefaults{
-				Proxy:     cfg.Proxy,
-				TargetOS:  cfg.TargetOS,
-				Arch:      cfg.Arch,
-				OutputDir: cfg.OutputDir,
-			}
-		})
+		return pull.NewPullCommandWithDefaults(pullDefaults(cfg))
 	}
 	saveCommand := func() *cobra.Command {
 		return images.NewSaveCommandWithDefaults(func() string { return cfg.OutputDir })
@@ -160,6 +163,22 @@ func newRootCommandSet(cfg *appConfig) rootCommandSet {
 			{name: "volumes", new: diagnostics.NewVolumesReportCommand},
 			{name: "registry", new: diagnostics.NewRegistryReportCommand},
 		},
+		registry: func() []*cobra.Command {
+			return pull.NewRegistryCommandsWithDefaults(pullDefaults(cfg))
+		},
+	}
+}
+
+func pullDefaults(cfg *appConfig) func() pull.CommandDefaults {
+	return func() pull.CommandDefaults {
+		return pull.CommandDefaults{
+			Proxy:           cfg.Proxy,
+			TargetOS:        cfg.TargetOS,
+			Arch:            cfg.Arch,
+			OutputDir:       cfg.OutputDir,
+			CacheDir:        cfg.CacheDir,
+			RegistryMirrors: cfg.RegistryMirrors,
+		}
 	}
 }
 
@@ -168,7 +187,13 @@ func (set rootCommandSet) newImageShortcuts() []*cobra.Command {
 }
 
 func (set rootCommandSet) newReportShortcuts() []*cobra.Command {
-	return newCommandsFromFactories(set.report)
+	commands := newCommandsFromFactories(set.report)
+	for _, cmd := range commands {
+		if cmd.Name() == "registry" && set.registry != nil {
+			cmd.AddCommand(set.registry()...)
+		}
+	}
+	return commands
 }
 
 func (set rootCommandSet) newImageGroup() *cobra.Command {
@@ -4,6 +4,7 @@ import (
 	"archive/tar"
 	"compress/gzip"
 	"context"
+	"docker-manager/internal/objectstore"
 	"docker-manager/internal/resourcefilter"
 	"encoding/json"
 	"fmt"
@@ -34,13 +35,25 @@ func createBackupArchiveWithOptions(ctx context.Context, sourceDir, archivePath
 	if err != nil {
 		return err
 	}
-	defer file.Close()
-
 	gz := gzip.NewWriter(file)
-	defer gz.Close()
 	tw := tar.NewWriter(gz)
-	defer tw.Close()
+	err = writeBackupArchiveEntries(ctx, tw, sourceDir, archiveAbs)
+	if err == nil {
+		err = tw.Close()
+	}
+	if err == nil {
+		err = gz.Close()
+	}
+	// Closing completes an S3 upload, so a failed archive must be aborted
+	// instead of published.
+	if err != nil {
+		abortBackupArchiveWriter(file)
+		return err
+	}
+	return file.Close()
+}
 
+func writeBackupArchiveEntries(ctx context.Context, tw *tar.Writer, sourceDir, archiveAbs string) error {
 	return filepath.WalkDir(sourceDir, func(path string, entry os.DirEntry, walkErr error) error {
 		if err := checkBackupContext(ctx); err != nil {
 			return err
@@ -98,6 +111,12 @@ func resolveRestoreBackupDirWithOptions(ctx context.Context, path string, opts R
 	if err := checkBackupContext(ctx); err != nil {
 		return "", nil, err
 	}
+	if isRepoSource(path) {
+		return materializeRepoSnapshot(ctx, path)
+	}
+	if objectstore.IsURL(path) {
+		return resolveS3BackupDir(ctx, path, opts)
+	}
 	if !isBackupArchive(path) && !isBackupArchivePart(path) && !isEncryptedBackupArchive(path) {
 		return path, nil, nil
 	}
@@ -117,16 +136,27 @@ func resolveRestoreBackupDirWithOptions(ctx context.Context, path string, opts R
 	}
 	if isEncryptedBackupArchive(archivePath) {
 		decrypted := filepath.Join(tempDir, strings.TrimSuffix(filepath.Base(archivePath), ".enc"))
-		if err := decryptBackupArchiveWithContext(ctx, archivePath, decrypted, opts.PassphraseFile); err != nil {
+		if err := decryptBackupArchiveWithContext(ctx, archivePath, decrypted, opts); err != nil {
 			cleanup()
 			return "", nil, err
 		}
+		if archivePath != path {
+			_ = os.Remove(archivePath)
+		}
 		archivePath = decrypted
 	}
 	if err := extractBackupArchiveWithContext(ctx, archivePath, tempDir); err != nil {
 		cleanup()
 		return "", nil, err
 	}
+	// Joined and decrypted intermediates share the extraction directory; drop
+	// them so the extracted tree only holds backup files.
+	if archivePath != path {
+		if err := os.Remove(archivePath); err != nil {
+			cleanup()
+			return "", nil, err
+		}
+	}
 	root, err := findExtractedBackupRoot(tempDir)
 	if err != nil {
 		cleanup()
@@ -161,7 +191,11 @@ func extractBackupArchiveWithContext(ctx context.Context, archivePath, destDir s
 		return err
 	}
 	defer file.Close()
-	gz, err := gzip.NewReader(file)
+	return extractBackupArchiveStream(ctx, file, destDir)
+}
+
+func extractBackupArchiveStream(ctx context.Context, src io.Reader, destDir string) error {
+	gz, err := gzip.NewReader(src)
 	if err != nil {
 		return err
 	}
@@ -244,11 +278,15 @@ func isSingleBackupDir(dir string) bool {
 }
 
 func readBackupManifest(backupDir string) (BackupManifest, error) {
-	var manifest BackupManifest
 	data, err := os.ReadFile(filepath.Join(backupDir, backupManifestName))
 	if err != nil {
-		return manifest, fmt.Errorf("read manifest: %w", err)
+		return BackupManifest{}, fmt.Errorf("read manifest: %w", err)
 	}
+	return parseBackupManifest(data)
+}
+
+func parseBackupManifest(data []byte) (BackupManifest, error) {
+	var manifest BackupManifest
 	if err := json.Unmarshal(data, &manifest); err != nil {
 		return manifest, fmt.Errorf("parse manifest: %w", err)
 	}
@@ -300,6 +338,13 @@ func readNetworkInspect(backupDir string, ref BackupResourceRef) (network.Inspec
 	if err := readJSON(path, &value); err != nil {
 		return value, fmt.Errorf("read network %s: %w", ref.Name, err)
 	}
+	if ref.Name != "" && ref.Name != value.Name {
+		// A network restored under a new name must not claim the original
+		// network's subnets, so Docker allocates fresh ones.
+		value.Name = ref.Name
+		value.ID = ""
+		value.IPAM.Config = nil
+	}
 	return value, nil
 }
 
@@ -312,6 +357,9 @@ func readVolumeInspect(backupDir string, ref BackupResourceRef) (volume.Volume,
 	if err := readJSON(path, &value); err != nil {
 		return value, fmt.Errorf("read volume %s: %w", ref.Name, err)
 	}
+	if ref.Name != "" {
+		value.Name = ref.Name
+	}
 	return value, nil
 }
 
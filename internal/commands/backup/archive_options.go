@@ -4,16 +4,21 @@ import (
 	"context"
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ed25519"
 	"crypto/pbkdf2"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/binary"
 	"fmt"
 	"io"
+	"log"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+
+	"docker-manager/internal/objectstore"
 )
 
 const (
@@ -28,21 +33,49 @@ const (
 type backupArchiveOptions struct {
 	Encrypt        bool
 	PassphraseFile string
+	Recipients     []*ecdh.PublicKey
+	SignKey        ed25519.PrivateKey
 	SplitSize      int64
+	S3             objectstore.Config
 }
 
 func archiveOptionsFromBackup(opts BackupOptions) (backupArchiveOptions, error) {
 	splitSize, err := parseBackupSize(opts.SplitSize)
 	if err != nil {
-		return backupArchiveOptions{}, err
+		return backupArchiveOptions{}, fmt.Errorf("--split-size: %w", err)
+	}
+	if len(opts.Recipients) > 0 && !opts.Encrypt {
+		return backupArchiveOptions{}, fmt.Errorf("--recipient requires --encrypt")
+	}
+	if opts.Encrypt && strings.TrimSpace(opts.PassphraseFile) == "" && len(opts.Recipients) == 0 {
+		return backupArchiveOptions{}, fmt.Errorf("--encrypt requires --passphrase-file or --recipient")
+	}
+	recipients, err := parseBackupRecipients(opts.Recipients)
+	if err != nil {
+		return backupArchiveOptions{}, fmt.Errorf("--recipient: %w", err)
 	}
-	if opts.Encrypt && strings.TrimSpace(opts.PassphraseFile) == "" {
-		return backupArchiveOptions{}, fmt.Errorf("--encrypt requires --passphrase-file")
+	var signKey ed25519.PrivateKey
+	if opts.SignKey != "" {
+		signKey, err = loadBackupSignKey(opts.SignKey)
+		if err != nil {
+			return backupArchiveOptions{}, fmt.Errorf("--sign-key: %w", err)
+		}
+	}
+	if objectstore.IsURL(opts.BundleOutput) {
+		if _, _, err := objectstore.ParseURL(opts.BundleOutput); err != nil {
+			return backupArchiveOptions{}, fmt.Errorf("--bundle-output: %w", err)
+		}
+		if _, err := objectstore.NewClient(opts.S3); err != nil {
+			return backupArchiveOptions{}, fmt.Errorf("--bundle-output: %w", err)
+		}
 	}
 	return backupArchiveOptions{
 		Encrypt:        opts.Encrypt,
 		PassphraseFile: opts.PassphraseFile,
+		Recipients:     recipients,
+		SignKey:        signKey,
 		SplitSize:      splitSize,
+		S3:             opts.S3,
 	}, nil
 }
 
@@ -67,21 +100,23 @@ func parseBackupSize(value string) (int64, error) {
 	}
 	size, err := strconv.ParseInt(value, 10, 64)
 	if err != nil || size <= 0 {
-		return 0, fmt.Errorf("invalid split size %q", value)
+		return 0, fmt.Errorf("invalid size %q", value)
 	}
 	return size * multiplier, nil
 }
 
+// openBackupArchiveWriter writes to a local file or streams to an s3:// URL;
+// split parts become separate files or objects with the same suffixes.
 func openBackupArchiveWriter(ctx context.Context, archivePath string, opts backupArchiveOptions) (io.WriteCloser, error) {
-	if err := os.MkdirAll(filepath.Dir(archivePath), 0755); err != nil {
+	create, err := backupArchiveCreateFunc(ctx, archivePath, opts)
+	if err != nil {
 		return nil, err
 	}
 	var writer io.WriteCloser
-	var err error
 	if opts.SplitSize > 0 {
-		writer, err = newSplitFileWriter(ctx, archivePath, opts.SplitSize)
+		writer, err = newSplitFileWriter(ctx, archivePath, opts.SplitSize, create)
 	} else {
-		writer, err = os.Create(archivePath)
+		writer, err = create(archivePath)
 	}
 	if err != nil {
 		return nil, err
@@ -89,12 +124,22 @@ func openBackupArchiveWriter(ctx context.Context, archivePath string, opts backu
 	if !opts.Encrypt {
 		return writer, nil
 	}
-	passphrase, err := readBackupPassphrase(opts.PassphraseFile)
-	if err != nil {
-		_ = writer.Close()
-		return nil, err
+	var passphrase []byte
+	if strings.TrimSpace(opts.PassphraseFile) != "" {
+		passphrase, err = readBackupPassphrase(opts.PassphraseFile)
+		if err != nil {
+			_ = writer.Close()
+			return nil, err
+		}
+	}
+	// Passphrase-only bundles keep the original DMBKENC1 format so older
+	// releases can still restore them.
+	var encrypted *encryptWriter
+	if len(opts.Recipients) > 0 {
+		encrypted, err = newRecipientEncryptWriter(writer, opts.Recipients, passphrase)
+	} else {
+		encrypted, err = newEncryptWriter(writer, passphrase)
 	}
-	encrypted, err := newEncryptWriter(writer, passphrase)
 	if err != nil {
 		_ = writer.Close()
 		return nil, err
@@ -118,6 +163,7 @@ type encryptWriter struct {
 	dst     io.WriteCloser
 	aead    cipher.AEAD
 	prefix  []byte
+	aad     []byte
 	counter uint64
 	buf     []byte
 	closed  bool
@@ -192,12 +238,21 @@ func (w *encryptWriter) Close() error {
 	return err
 }
 
+func (w *encryptWriter) Abort() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	abortBackupArchiveWriter(w.dst)
+	return nil
+}
+
 func (w *encryptWriter) flushChunk() error {
 	nonce := make([]byte, backupEncryptionNonceSize)
 	copy(nonce, w.prefix)
 	binary.BigEndian.PutUint64(nonce[backupEncryptionPrefixSize:], w.counter)
 	w.counter++
-	sealed := w.aead.Seal(nil, nonce, w.buf, nil)
+	sealed := w.aead.Seal(nil, nonce, w.buf, w.aad)
 	if len(sealed) > int(^uint32(0)) {
 		return fmt.Errorf("encrypted chunk too large")
 	}
@@ -211,20 +266,55 @@ func (w *encryptWriter) flushChunk() error {
 	return nil
 }
 
+func backupArchiveCreateFunc(ctx context.Context, archivePath string, opts backupArchiveOptions) (func(string) (io.WriteCloser, error), error) {
+	if objectstore.IsURL(archivePath) {
+		client, err := objectstore.NewClient(opts.S3)
+		if err != nil {
+			return nil, err
+		}
+		return func(path string) (io.WriteCloser, error) {
+			bucket, key, err := objectstore.ParseURL(path)
+			if err != nil {
+				return nil, err
+			}
+			return client.NewWriter(ctx, bucket, key), nil
+		}, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(archivePath), 0755); err != nil {
+		return nil, err
+	}
+	return func(path string) (io.WriteCloser, error) {
+		return os.Create(path)
+	}, nil
+}
+
+// abortBackupArchiveWriter drops a partially written archive when the
+// destination supports it (S3 multipart uploads); local files are closed.
+func abortBackupArchiveWriter(w io.WriteCloser) {
+	if aborter, ok := w.(interface{ Abort() error }); ok {
+		if err := aborter.Abort(); err != nil {
+			log.Printf("Abort backup archive upload failed: %v", err)
+		}
+		return
+	}
+	_ = w.Close()
+}
+
 type splitFileWriter struct {
 	ctx       context.Context
 	basePath  string
 	partSize  int64
 	partIndex int
 	written   int64
-	current   *os.File
+	create    func(string) (io.WriteCloser, error)
+	current   io.WriteCloser
 }
 
-func newSplitFileWriter(ctx context.Context, basePath string, partSize int64) (*splitFileWriter, error) {
+func newSplitFileWriter(ctx context.Context, basePath string, partSize int64, create func(string) (io.WriteCloser, error)) (*splitFileWriter, error) {
 	if partSize <= 0 {
 		return nil, fmt.Errorf("split size must be positive")
 	}
-	w := &splitFileWriter{ctx: ctx, basePath: basePath, partSize: partSize}
+	w := &splitFileWriter{ctx: ctx, basePath: basePath, partSize: partSize, create: create}
 	if err := w.openNextPart(); err != nil {
 		return nil, err
 	}
@@ -275,12 +365,22 @@ func (w *splitFileWriter) openNextPart() error {
 	}
 	w.partIndex++
 	w.written = 0
-	path := splitPartPath(w.basePath, w.partIndex)
-	file, err := os.Create(path)
+	current, err := w.create(splitPartPath(w.basePath, w.partIndex))
 	if err != nil {
 		return err
 	}
-	w.current = file
+	w.current = current
+	return nil
+}
+
+// Abort discards the part being written; completed parts are kept.
+func (w *splitFileWriter) Abort() error {
+	if w.current == nil {
+		return nil
+	}
+	current := w.current
+	w.current = nil
+	abortBackupArchiveWriter(current)
 	return nil
 }
 
@@ -325,11 +425,8 @@ func joinBackupArchivePartsWithContext(ctx context.Context, firstPart, outputPat
 	}
 }
 
-func decryptBackupArchiveWithContext(ctx context.Context, encryptedPath, outputPath, passphraseFile string) error {
-	if strings.TrimSpace(passphraseFile) == "" {
-		return fmt.Errorf("encrypted backup requires --passphrase-file")
-	}
-	passphrase, err := readBackupPassphrase(passphraseFile)
+func decryptBackupArchiveWithContext(ctx context.Context, encryptedPath, outputPath string, opts RestoreOptions) error {
+	keys, err := loadBackupDecryptionKeys(opts.PassphraseFile, opts.IdentityFiles)
 	if err != nil {
 		return err
 	}
@@ -343,33 +440,57 @@ func decryptBackupArchiveWithContext(ctx context.Context, encryptedPath, outputP
 		return err
 	}
 	defer out.Close()
-	return decryptBackupArchiveStream(ctx, in, out, passphrase)
+	return decryptBackupArchiveStream(ctx, in, out, keys)
 }
 
-func decryptBackupArchiveStream(ctx context.Context, src io.Reader, dst io.Writer, passphrase []byte) error {
-	header := make([]byte, len(backupEncryptionMagic)+backupEncryptionSaltSize+backupEncryptionPrefixSize)
-	if _, err := io.ReadFull(src, header); err != nil {
+// decryptBackupArchiveStream accepts both the passphrase format (DMBKENC1)
+// and the recipient format (DMBKENC2); the magic records the scheme.
+func decryptBackupArchiveStream(ctx context.Context, src io.Reader, dst io.Writer, keys backupDecryptionKeys) error {
+	magic := make([]byte, len(backupEncryptionMagic))
+	if _, err := io.ReadFull(src, magic); err != nil {
 		return err
 	}
-	if string(header[:len(backupEncryptionMagic)]) != backupEncryptionMagic {
+	var (
+		aead   cipher.AEAD
+		prefix []byte
+		aad    []byte
+		scheme string
+	)
+	switch string(magic) {
+	case backupEncryptionMagic:
+		if len(keys.Passphrase) == 0 {
+			return fmt.Errorf("该备份包使用口令加密，需要 --passphrase-file")
+		}
+		header := make([]byte, backupEncryptionSaltSize+backupEncryptionPrefixSize)
+		if _, err := io.ReadFull(src, header); err != nil {
+			return err
+		}
+		salt := header[:backupEncryptionSaltSize]
+		prefix = header[backupEncryptionSaltSize:]
+		key, err := pbkdf2.Key(sha256.New, string(keys.Passphrase), salt, backupEncryptionKDFIter, 32)
+		if err != nil {
+			return err
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return err
+		}
+		aead, err = cipher.NewGCM(block)
+		if err != nil {
+			return err
+		}
+		scheme = backupSchemePassphrase
+	case backupRecipientMagic:
+		var err error
+		aead, prefix, aad, err = openRecipientEncryptedStream(src, keys)
+		if err != nil {
+			return err
+		}
+		scheme = backupSchemeRecipients
+	default:
 		return fmt.Errorf("invalid encrypted backup header")
 	}
-	saltStart := len(backupEncryptionMagic)
-	saltEnd := saltStart + backupEncryptionSaltSize
-	salt := header[saltStart:saltEnd]
-	prefix := header[saltEnd:]
-	key, err := pbkdf2.Key(sha256.New, string(passphrase), salt, backupEncryptionKDFIter, 32)
-	if err != nil {
-		return err
-	}
-	block, err := aes.NewCipher(key)
-	if err != nil {
-		return err
-	}
-	aead, err := cipher.NewGCM(block)
-	if err != nil {
-		return err
-	}
+	log.Printf("Decrypt backup: scheme=%s", scheme)
 	var counter uint64
 	for {
 		if err := checkBackupContext(ctx); err != nil {
@@ -390,7 +511,7 @@ func decryptBackupArchiveStream(ctx context.Context, src io.Reader, dst io.Write
 		copy(nonce, prefix)
 		binary.BigEndian.PutUint64(nonce[backupEncryptionPrefixSize:], counter)
 		counter++
-		plaintext, err := aead.Open(nil, nonce, ciphertext, nil)
+		plaintext, err := aead.Open(nil, nonce, ciphertext, aad)
 		if err != nil {
 			return fmt.Errorf("decrypt backup chunk: %w", err)
 		}
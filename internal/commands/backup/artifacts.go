@@ -65,6 +65,9 @@ func buildBackupTopLevelComposeMeta(services map[string]runconfig.ComposeService
 		if isBackupCustomNetwork(networkMode) {
 			networks[networkMode] = map[string]interface{}{"external": false}
 		}
+		for name := range svc.Networks {
+			networks[name] = map[string]interface{}{"external": false}
+		}
 	}
 	if len(volumes) == 0 {
 		volumes = nil
@@ -126,7 +129,11 @@ func writeBackupReadme(path string, manifest BackupManifest) error {
 	sb.WriteString("- Created by: `dm " + valueOrUnknown(manifest.Tool.Version) + "`\n")
 	sb.WriteString("- Source commit: `" + valueOrUnknown(manifest.Tool.Commit) + "`\n")
 	sb.WriteString("- Source build date: `" + valueOrUnknown(manifest.Tool.BuildDate) + "`\n")
-	sb.WriteString("- Source platform: `" + valueOrUnknown(manifest.SourcePlatform) + "`\n\n")
+	sb.WriteString("- Source platform: `" + valueOrUnknown(manifest.SourcePlatform) + "`\n")
+	if manifest.Parent != nil {
+		sb.WriteString("- Incremental base: `" + manifest.Parent.Path + "` (manifest sha256 `" + manifest.Parent.ManifestSHA256 + "`)\n")
+	}
+	sb.WriteString("\n")
 	sb.WriteString("## Contents\n\n")
 	sb.WriteString("- `manifest.json`: migration manifest; `containers` contains one or more container entries\n")
 	if len(manifest.Containers) == 1 && manifest.Containers[0].Path == "" {
@@ -139,20 +146,38 @@ func writeBackupReadme(path string, manifest BackupManifest) error {
 			sb.WriteString("- `networks/`: network metadata\n")
 		}
 		if len(manifest.Containers[0].Volumes) > 0 {
-			sb.WriteString("- `volumes/`: volume metadata\n")
+			if volumeDataRefNames(manifest.Containers[0].Volumes) != "" {
+				sb.WriteString("- `volumes/`: volume metadata and `<name>.tar` volume data archives\n")
+			} else {
+				sb.WriteString("- `volumes/`: volume metadata\n")
+			}
+		}
+		if backupHasBindData(manifest.Containers[0].Mounts) {
+			sb.WriteString("- `binds/`: bind mount host data archives\n")
 		}
 	} else {
 		sb.WriteString("- `containers/`: per-container backup directories\n")
 	}
+	sb.WriteString("- `blobs.json`: index of archive entries, used when this backup is the base of an incremental backup\n")
 	sb.WriteString("- `checksums.txt`: SHA256 checksums\n")
 	sb.WriteString("- `restore.sh`: helper restore script\n\n")
 	sb.WriteString("## Prerequisites\n\n")
 	sb.WriteString("- Install `dm` on the target host and make sure it is available in `PATH`.\n")
 	sb.WriteString("- The target host must be able to reach a running Docker daemon with permission to load images and create networks, volumes and containers.\n")
 	sb.WriteString("- Review container names, ports, bind mounts, named volumes and custom networks before using `--replace`.\n")
-	sb.WriteString("- If this backup contains bind mounts, the target host must already have compatible host paths and permissions.\n\n")
+	sb.WriteString("- If this backup contains bind mounts, the target host must already have compatible host paths and permissions.\n")
+	sb.WriteString("- Volume data archives are only imported into volumes created by the restore; existing volumes keep their current data.\n")
+	sb.WriteString("- Bind mount data archives are only written with `dm restore --bind-root <dir>`; use `--bind-root /` to write back to the original host paths.\n\n")
+	if manifest.Parent != nil {
+		sb.WriteString("## Incremental backup\n\n")
+		sb.WriteString("This is an incremental backup: archive entries that already exist in the base backup are stored as references. Keep the base backup (and its own bases) next to this one, at the relative path recorded in `manifest.json`, or at its original absolute path. `dm restore` locates the chain, verifies every backup in it and rebuilds the full archives before restoring.\n\n")
+	}
 	sb.WriteString("## Checksum verification\n\n")
 	sb.WriteString("`dm restore` verifies `checksums.txt` by default before it touches Docker. If verification fails, restore stops before loading images or creating resources. Use `--skip-checksum` only after manually confirming the package integrity.\n\n")
+	sb.WriteString("If the package was created with `--sign-key`, `signature.json` signs `manifest.json` and `checksums.txt`. Check it against a trusted public key without restoring:\n\n")
+	sb.WriteString("```bash\n")
+	sb.WriteString("dm backup verify <backup>.tar.gz --trusted-key signer.pub\n")
+	sb.WriteString("```\n\n")
 	sb.WriteString("## Restore\n\n")
 	sb.WriteString("```bash\n")
 	sb.WriteString("dm restore .\n")
@@ -169,6 +194,12 @@ func writeBackupReadme(path string, manifest BackupManifest) error {
 		if entry.Image != "" {
 			line += " image `" + entry.Image + "`"
 		}
+		if entry.Consistency != nil {
+			line += " consistency `" + entry.Consistency.Mode + "/" + entry.Consistency.Action + "`"
+		}
+		for _, hook := range entry.Hooks {
+			line += " " + hook.Phase + "-hook `" + hook.Status + "`"
+		}
 		sb.WriteString(line + "\n")
 	}
 	return os.WriteFile(path, []byte(sb.String()), 0644)
@@ -228,6 +259,26 @@ func resourceRefNames(refs []BackupResourceRef) string {
 	return strings.Join(names, ",")
 }
 
+func backupHasBindData(refs []BackupMountRef) bool {
+	for _, ref := range refs {
+		if ref.Data != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func volumeDataRefNames(refs []BackupResourceRef) string {
+	var names []string
+	for _, ref := range refs {
+		if ref.Data != "" {
+			names = append(names, ref.Name)
+		}
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
 func checksumPlanText(skip bool, checksumPath string) string {
 	if skip {
 		return "跳过 (--skip-checksum)"
@@ -2,13 +2,16 @@ package backup
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
+	"docker-manager/internal/objectstore"
 	"docker-manager/internal/version"
 )
 
@@ -19,14 +22,37 @@ func backupContainers(ctx context.Context, patterns []string, opts BackupOptions
 	if len(patterns) == 0 {
 		return BackupContainersResult{}, fmt.Errorf("必须提供至少一个容器名称或通配符")
 	}
-	if (opts.Encrypt || opts.SplitSize != "") && !opts.Bundle {
-		return BackupContainersResult{}, fmt.Errorf("--encrypt 和 --split-size 仅在 --bundle 时可用")
+	if (opts.Encrypt || opts.SplitSize != "" || opts.SignKey != "") && !opts.Bundle {
+		return BackupContainersResult{}, fmt.Errorf("--encrypt、--split-size 和 --sign-key 仅在 --bundle 时可用")
 	}
 	if opts.Bundle {
 		if _, err := archiveOptionsFromBackup(opts); err != nil {
 			return BackupContainersResult{}, err
 		}
 	}
+	if _, err := bindCaptureOptionsFromBackup(opts); err != nil {
+		return BackupContainersResult{}, err
+	}
+	if err := validateBackupConsistency(opts.Consistency); err != nil {
+		return BackupContainersResult{}, err
+	}
+	if opts.Base != "" {
+		// 增量包按相对路径记录基线，写入对象存储后恢复和校验时无法定位基线
+		if objectstore.IsURL(opts.BundleOutput) {
+			return BackupContainersResult{}, fmt.Errorf("--base 不能与 s3:// --bundle-output 一起使用；对象存储中的增量包在恢复和校验时无法定位基线")
+		}
+		if _, err := os.Stat(opts.Base); err != nil {
+			return BackupContainersResult{}, fmt.Errorf("--base: %w", err)
+		}
+	}
+	if opts.Repo != "" {
+		if opts.Bundle || opts.BundleOutput != "" || opts.Base != "" || opts.OutputDir != "" {
+			return BackupContainersResult{}, fmt.Errorf("--repo 不能与 --bundle、--bundle-output、--base 或 --output-dir 一起使用；仓库本身负责去重")
+		}
+		if _, err := openBackupRepo(opts.Repo); err != nil {
+			return BackupContainersResult{}, err
+		}
+	}
 	targets, err := resolveBackupContainerTargets(ctx, patterns)
 	if err != nil {
 		return BackupContainersResult{}, err
@@ -34,6 +60,13 @@ func backupContainers(ctx context.Context, patterns []string, opts BackupOptions
 	if len(targets) == 0 {
 		return BackupContainersResult{}, fmt.Errorf("未匹配任何容器")
 	}
+	if opts.Repo != "" {
+		return backupContainersToRepo(ctx, targets, opts)
+	}
+	return backupContainerTargets(ctx, targets, opts)
+}
+
+func backupContainerTargets(ctx context.Context, targets []string, opts BackupOptions) (BackupContainersResult, error) {
 	if len(targets) == 1 && !opts.Merge {
 		singleOpts := opts
 		outputDir, err := backupContainer(ctx, targets[0], singleOpts)
@@ -45,6 +78,9 @@ func backupContainers(ctx context.Context, patterns []string, opts BackupOptions
 	if opts.BundleOutput != "" && !opts.Merge {
 		return BackupContainersResult{}, fmt.Errorf("多个独立备份不能使用单个 --bundle-output；请使用 --output-dir 或添加 --merge")
 	}
+	if opts.Base != "" && !opts.Merge {
+		return BackupContainersResult{}, fmt.Errorf("多个独立备份不能共用单个 --base；请逐个备份或添加 --merge")
+	}
 	if opts.Merge {
 		return backupContainersMerged(ctx, targets, opts)
 	}
@@ -65,12 +101,28 @@ func backupContainersSeparate(ctx context.Context, targets []string, opts Backup
 		childOpts.OutputDir = filepath.Join(root, safeBackupName(target))
 		childOpts.BundleOutput = ""
 		outputDir, err := backupContainer(ctx, target, childOpts)
+		if errors.Is(err, errBackupPreHookFailed) {
+			log.Printf("Backup skipped: container=%s error=%v", target, err)
+			result.Failed = append(result.Failed, BackupContainerFailure{Container: target, Err: err})
+			continue
+		}
 		if err != nil {
 			return result, fmt.Errorf("backup %s: %w", target, err)
 		}
 		result.Paths = append(result.Paths, outputDir)
 	}
-	return result, nil
+	return result, backupFailuresError(result.Failed)
+}
+
+func backupFailuresError(failures []BackupContainerFailure) error {
+	if len(failures) == 0 {
+		return nil
+	}
+	messages := make([]string, 0, len(failures))
+	for _, failure := range failures {
+		messages = append(messages, fmt.Sprintf("%s: %v", failure.Container, failure.Err))
+	}
+	return fmt.Errorf("%d 个容器的 pre-hook 失败，已跳过: %s", len(failures), strings.Join(messages, "; "))
 }
 
 func backupContainersMerged(ctx context.Context, targets []string, opts BackupOptions) (BackupContainersResult, error) {
@@ -87,6 +139,7 @@ func backupContainersMerged(ctx context.Context, targets []string, opts BackupOp
 		Tool:           version.CurrentInfo(),
 		SourcePlatform: currentSourcePlatform(),
 	}
+	var failed []BackupContainerFailure
 	for _, target := range targets {
 		if err := checkBackupContext(ctx); err != nil {
 			return BackupContainersResult{}, err
@@ -97,6 +150,11 @@ func backupContainersMerged(ctx context.Context, targets []string, opts BackupOp
 		childOpts.Bundle = false
 		childOpts.BundleOutput = ""
 		outputDir, err := backupContainer(ctx, target, childOpts)
+		if errors.Is(err, errBackupPreHookFailed) {
+			log.Printf("Backup skipped: container=%s error=%v", target, err)
+			failed = append(failed, BackupContainerFailure{Container: target, Err: err})
+			continue
+		}
 		if err != nil {
 			return BackupContainersResult{}, fmt.Errorf("backup %s: %w", target, err)
 		}
@@ -118,10 +176,16 @@ func backupContainersMerged(ctx context.Context, targets []string, opts BackupOp
 		}
 		manifest.Containers = append(manifest.Containers, entry)
 	}
+	if len(manifest.Containers) == 0 {
+		return BackupContainersResult{Failed: failed}, backupFailuresError(failed)
+	}
 	if !opts.DryRun {
 		if err := checkBackupContext(ctx); err != nil {
 			return BackupContainersResult{}, err
 		}
+		if err := finalizeBackupBlobs(ctx, root, &manifest, opts); err != nil {
+			return BackupContainersResult{}, err
+		}
 		if err := writeJSONFile(filepath.Join(root, backupManifestName), manifest); err != nil {
 			return BackupContainersResult{}, fmt.Errorf("write manifest: %w", err)
 		}
@@ -133,6 +197,11 @@ func backupContainersMerged(ctx context.Context, targets []string, opts BackupOp
 			if err := writeBackupBundleArtifactsWithContext(ctx, root, manifest); err != nil {
 				return BackupContainersResult{}, err
 			}
+			if archiveOpts.SignKey != nil {
+				if err := writeBackupSignature(root, archiveOpts.SignKey); err != nil {
+					return BackupContainersResult{}, err
+				}
+			}
 			archivePath := opts.BundleOutput
 			if archivePath == "" {
 				archivePath = root + ".tar.gz"
@@ -144,8 +213,8 @@ func backupContainersMerged(ctx context.Context, targets []string, opts BackupOp
 			log.Printf("Backup batch bundle: %s", archivePath)
 		}
 	}
-	log.Printf("Backup batch summary: containers=%d output=%s merge=true", len(targets), root)
-	return BackupContainersResult{Paths: []string{root}}, nil
+	log.Printf("Backup batch summary: containers=%d skipped=%d output=%s merge=true", len(manifest.Containers), len(failed), root)
+	return BackupContainersResult{Paths: []string{root}, Failed: failed}, backupFailuresError(failed)
 }
 
 func backupContainer(ctx context.Context, name string, opts BackupOptions) (string, error) {
@@ -188,6 +257,17 @@ func backupContainer(ctx context.Context, name string, opts BackupOptions) (stri
 	}
 	containerManifest.Mounts = backupMountRefs(inspect)
 	containerManifest.Devices = backupDeviceRefs(inspect)
+	bindOpts, err := bindCaptureOptionsFromBackup(opts)
+	if err != nil {
+		return "", err
+	}
+	if err := validateBackupConsistency(opts.Consistency); err != nil {
+		return "", err
+	}
+	execTarget := inspect.ID
+	if execTarget == "" {
+		execTarget = containerName
+	}
 
 	if opts.DryRun {
 		if err := checkBackupContext(ctx); err != nil {
@@ -202,11 +282,20 @@ func backupContainer(ctx context.Context, name string, opts BackupOptions) (stri
 			return "", err
 		}
 		containerManifest.Networks = networks
-		volumes, err := inspectBackupVolumeRefs(ctx, svc, inspect)
+		volumes, err := inspectBackupVolumeRefs(ctx, svc, inspect, opts.IncludeVolumeData)
 		if err != nil {
 			return "", err
 		}
 		containerManifest.Volumes = volumes
+		if opts.IncludeBindMounts {
+			if err := backupBindMountData(ctx, outputDir, containerManifest.Mounts, bindOpts, true); err != nil {
+				return "", err
+			}
+		}
+		containerManifest.Hooks = plannedBackupHooks(inspect, opts)
+		if opts.Consistency != "" && opts.Consistency != backupConsistencyNone {
+			containerManifest.Consistency = &BackupConsistency{Mode: opts.Consistency, Action: "planned"}
+		}
 		manifest := BackupManifest{
 			Version:        1,
 			CreatedAt:      createdAt,
@@ -215,10 +304,21 @@ func backupContainer(ctx context.Context, name string, opts BackupOptions) (stri
 			Containers:     []BackupContainerManifest{containerManifest},
 		}
 		printBackupDryRunPlan(opts.Output, outputDir, manifest, opts)
-		log.Printf("Dry run backup: name=%s output=%s includeImage=%v networks=%d volumes=%d bundle=%v", name, outputDir, opts.IncludeImage, len(networks), len(volumes), opts.Bundle)
+		log.Printf("Dry run backup: name=%s output=%s includeImage=%v includeVolumeData=%v networks=%d volumes=%d bundle=%v", name, outputDir, opts.IncludeImage, opts.IncludeVolumeData, len(networks), len(volumes), opts.Bundle)
 		return outputDir, nil
 	}
 
+	// Pre-hooks run before anything is written so a failing hook leaves no
+	// partial backup behind.
+	hooks, err := runBackupHooks(ctx, svc, execTarget, inspect, backupHooksFor(inspect, opts, backupHookPhasePre), opts.HookTimeout)
+	containerManifest.Hooks = hooks
+	if err != nil {
+		if ctxErr := checkBackupContext(ctx); ctxErr != nil {
+			return "", ctxErr
+		}
+		return "", fmt.Errorf("%w: %v", errBackupPreHookFailed, err)
+	}
+
 	if err := os.MkdirAll(outputDir, 0755); err != nil {
 		return "", err
 	}
@@ -250,11 +350,37 @@ func backupContainer(ctx context.Context, name string, opts BackupOptions) (stri
 	}
 	containerManifest.Networks = networks
 
-	volumes, err := backupVolumes(ctx, svc, outputDir, inspect)
+	consistency, resume, err := quiesceBackupContainer(ctx, svc, execTarget, inspect, opts)
 	if err != nil {
 		return "", err
 	}
-	containerManifest.Volumes = volumes
+	containerManifest.Consistency = consistency
+	captureErr := func() error {
+		volumes, err := backupVolumes(ctx, svc, outputDir, inspect, opts)
+		if err != nil {
+			return err
+		}
+		containerManifest.Volumes = volumes
+		if opts.IncludeBindMounts {
+			return backupBindMountData(ctx, outputDir, containerManifest.Mounts, bindOpts, false)
+		}
+		return nil
+	}()
+	if err := errors.Join(captureErr, resume()); err != nil {
+		return "", err
+	}
+
+	// A failing post-hook does not invalidate data that was already
+	// captured; it is recorded in the manifest instead.
+	postHooks, err := runBackupHooks(ctx, svc, execTarget, inspect, backupHooksFor(inspect, opts, backupHookPhasePost), opts.HookTimeout)
+	containerManifest.Hooks = append(containerManifest.Hooks, postHooks...)
+	if err != nil {
+		if ctxErr := checkBackupContext(ctx); ctxErr != nil {
+			return "", ctxErr
+		}
+		log.Printf("Backup post-hook failed: container=%s error=%v", containerName, err)
+		fmt.Fprintf(opts.Output, "警告: %s post-hook 失败: %v\n", containerName, err)
+	}
 
 	manifest := BackupManifest{
 		Version:        1,
@@ -263,6 +389,12 @@ func backupContainer(ctx context.Context, name string, opts BackupOptions) (stri
 		SourcePlatform: currentSourcePlatform(),
 		Containers:     []BackupContainerManifest{containerManifest},
 	}
+	// Merged children are indexed and deduplicated once by the batch root.
+	if !opts.Merge {
+		if err := finalizeBackupBlobs(ctx, outputDir, &manifest, opts); err != nil {
+			return "", err
+		}
+	}
 	if err := writeJSONFile(filepath.Join(outputDir, backupManifestName), manifest); err != nil {
 		return "", fmt.Errorf("write manifest: %w", err)
 	}
@@ -277,6 +409,11 @@ func backupContainer(ctx context.Context, name string, opts BackupOptions) (stri
 		if err := writeBackupBundleArtifactsWithContext(ctx, outputDir, manifest); err != nil {
 			return "", err
 		}
+		if archiveOpts.SignKey != nil {
+			if err := writeBackupSignature(outputDir, archiveOpts.SignKey); err != nil {
+				return "", err
+			}
+		}
 		archivePath := opts.BundleOutput
 		if archivePath == "" {
 			archivePath = outputDir + ".tar.gz"
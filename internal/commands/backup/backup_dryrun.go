@@ -1,6 +1,7 @@
 package backup
 
 import (
+	"crypto/ed25519"
 	"fmt"
 	"io"
 	"path/filepath"
@@ -8,6 +9,7 @@ import (
 	"strings"
 
 	"docker-manager/internal/docker"
+	"docker-manager/internal/textfmt"
 )
 
 func printBackupDryRunPlan(w io.Writer, outputDir string, manifest BackupManifest, opts BackupOptions) {
@@ -21,17 +23,39 @@ func printBackupDryRunPlan(w io.Writer, outputDir string, manifest BackupManifes
 	} else {
 		fmt.Fprintln(w, "  镜像归档: 跳过 (--no-image)")
 	}
+	if opts.IncludeVolumeData {
+		fmt.Fprintln(w, "  volume 数据: 启用")
+	} else {
+		fmt.Fprintln(w, "  volume 数据: 跳过 (--no-volume-data)")
+	}
+	if opts.IncludeBindMounts {
+		fmt.Fprintln(w, "  bind 数据: 启用 (--include-bind-mounts)")
+	}
+	if opts.Repo != "" {
+		fmt.Fprintf(w, "  快照仓库: %s (按内容分块去重，备份完成后写入快照)\n", opts.Repo)
+	}
+	if opts.Base != "" {
+		fmt.Fprintf(w, "  增量基线: %s (与基线相同的归档内容只记录引用)\n", opts.Base)
+	}
 	if opts.Bundle {
 		archivePath := opts.BundleOutput
 		if archivePath == "" {
 			archivePath = outputDir + ".tar.gz"
 		}
-		if archiveOpts, err := archiveOptionsFromBackup(opts); err == nil {
+		archiveOpts, err := archiveOptionsFromBackup(opts)
+		if err == nil {
 			archivePath = backupArchiveOutputPath(archivePath, archiveOpts)
 		}
 		fmt.Fprintf(w, "  离线包: %s\n", archivePath)
 		if opts.Encrypt {
-			fmt.Fprintln(w, "  加密: 启用")
+			if len(archiveOpts.Recipients) > 0 {
+				fmt.Fprintf(w, "  加密: 启用 (recipients=%d passphrase=%v)\n", len(archiveOpts.Recipients), opts.PassphraseFile != "")
+			} else {
+				fmt.Fprintln(w, "  加密: 启用 (passphrase)")
+			}
+		}
+		if archiveOpts.SignKey != nil {
+			fmt.Fprintf(w, "  签名: ed25519 key=%s (写入 %s)\n", backupKeyID(archiveOpts.SignKey.Public().(ed25519.PublicKey)), backupSignatureName)
 		}
 		if opts.SplitSize != "" {
 			fmt.Fprintf(w, "  分卷大小: %s\n", opts.SplitSize)
@@ -56,14 +80,30 @@ func printBackupDryRunPlan(w io.Writer, outputDir string, manifest BackupManifes
 		if len(entry.Volumes) > 0 {
 			fmt.Fprintf(w, "    volume 元数据: %s\n", resourceRefNames(entry.Volumes))
 		}
+		if names := volumeDataRefNames(entry.Volumes); names != "" {
+			fmt.Fprintf(w, "    volume 数据归档: %s\n", names)
+		}
 		if len(entry.Mounts) > 0 {
 			fmt.Fprintf(w, "    挂载依赖: %s\n", backupMountSummary(entry.Mounts))
 		}
+		for _, mount := range entry.Mounts {
+			if mount.Data != "" {
+				fmt.Fprintf(w, "    bind 数据: %s -> %s (%s)\n", mount.Source, mount.Data, textfmt.SignedBytes(mount.DataSize))
+			} else if mount.DataSkipped != "" {
+				fmt.Fprintf(w, "    bind 数据跳过: %s (%s)\n", mount.Source, mount.DataSkipped)
+			}
+		}
 		if len(entry.Devices) > 0 {
 			fmt.Fprintf(w, "    设备依赖: %s\n", backupDeviceSummary(entry.Devices))
 		}
+		if entry.Consistency != nil {
+			fmt.Fprintf(w, "    一致性: %s (捕获 volume/bind 数据期间%s容器)\n", entry.Consistency.Mode, backupConsistencyVerb(entry.Consistency.Mode))
+		}
+		for _, hook := range entry.Hooks {
+			fmt.Fprintf(w, "    %s-hook (%s): %s\n", hook.Phase, hook.Source, hook.Command)
+		}
 	}
-	fmt.Fprintln(w, "  校验: dry-run 已确认 inspect 可读、compose 可生成、network/volume 元数据可读取；不会写入文件或导出镜像。")
+	fmt.Fprintln(w, "  校验: dry-run 已确认 inspect 可读、compose 可生成、network/volume 元数据可读取；不会写入文件、导出镜像或 volume 数据，也不会执行 hook 或暂停/停止容器。")
 }
 
 func backupMountSummary(refs []BackupMountRef) string {
@@ -97,3 +137,10 @@ func backupDeviceSummary(refs []BackupDeviceRef) string {
 	sort.Strings(values)
 	return strings.Join(values, ",")
 }
+
+func backupConsistencyVerb(mode string) string {
+	if mode == backupConsistencyStop {
+		return "停止并在完成后重新启动"
+	}
+	return "暂停"
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"path/filepath"
 	"sort"
+	"strings"
 
 	"docker-manager/internal/parallel"
 
@@ -43,7 +44,7 @@ func backupNetworks(ctx context.Context, svc backupDockerService, outputDir stri
 	return refs, nil
 }
 
-func backupVolumes(ctx context.Context, svc backupDockerService, outputDir string, inspect container.InspectResponse) ([]BackupResourceRef, error) {
+func backupVolumes(ctx context.Context, svc backupDockerService, outputDir string, inspect container.InspectResponse, opts BackupOptions) ([]BackupResourceRef, error) {
 	names := namedVolumes(inspect)
 	if len(names) == 0 {
 		return nil, nil
@@ -60,6 +61,14 @@ func backupVolumes(ctx context.Context, svc backupDockerService, outputDir strin
 			return fmt.Errorf("write volume %s: %w", name, err)
 		}
 		refs[i] = BackupResourceRef{Name: name, File: filepath.ToSlash(rel)}
+		if !opts.IncludeVolumeData {
+			return nil
+		}
+		dataRel := backupVolumeDataFile(name)
+		if err := svc.ExportVolume(ctx, name, backupVolumeHelperImage(inspect, opts.VolumeHelperImage), filepath.Join(outputDir, filepath.FromSlash(dataRel))); err != nil {
+			return fmt.Errorf("export volume %s data: %w", name, err)
+		}
+		refs[i].Data = dataRel
 		return nil
 	}); err != nil {
 		return nil, err
@@ -67,6 +76,35 @@ func backupVolumes(ctx context.Context, svc backupDockerService, outputDir strin
 	return refs, nil
 }
 
+func backupVolumeDataFile(name string) string {
+	return filepath.ToSlash(filepath.Join("volumes", safeBackupName(name)+".tar"))
+}
+
+// backupVolumeHelperImage prefers the source container's image ID because it
+// is guaranteed to exist on the source daemon, unlike an arbitrary probe image.
+func backupVolumeHelperImage(inspect container.InspectResponse, override string) string {
+	if strings.TrimSpace(override) != "" {
+		return override
+	}
+	if inspect.Image != "" {
+		return inspect.Image
+	}
+	if inspect.Config != nil {
+		return inspect.Config.Image
+	}
+	return ""
+}
+
+func restoreVolumeHelperImage(inspect container.InspectResponse, override string) string {
+	if strings.TrimSpace(override) != "" {
+		return override
+	}
+	if inspect.Config != nil && inspect.Config.Image != "" {
+		return inspect.Config.Image
+	}
+	return inspect.Image
+}
+
 func inspectBackupNetworkRefs(ctx context.Context, svc backupDockerService, inspect container.InspectResponse) ([]BackupResourceRef, error) {
 	if inspect.NetworkSettings == nil || len(inspect.NetworkSettings.Networks) == 0 {
 		return nil, nil
@@ -95,7 +133,7 @@ func inspectBackupNetworkRefs(ctx context.Context, svc backupDockerService, insp
 	return refs, nil
 }
 
-func inspectBackupVolumeRefs(ctx context.Context, svc backupDockerService, inspect container.InspectResponse) ([]BackupResourceRef, error) {
+func inspectBackupVolumeRefs(ctx context.Context, svc backupDockerService, inspect container.InspectResponse, includeData bool) ([]BackupResourceRef, error) {
 	names := namedVolumes(inspect)
 	refs := make([]BackupResourceRef, len(names))
 	if err := parallel.ForEachIndexErr(ctx, len(names), backupInspectConcurrency, func(ctx context.Context, i int) error {
@@ -105,6 +143,9 @@ func inspectBackupVolumeRefs(ctx context.Context, svc backupDockerService, inspe
 		}
 		rel := filepath.Join("volumes", safeBackupName(name)+".json")
 		refs[i] = BackupResourceRef{Name: name, File: filepath.ToSlash(rel)}
+		if includeData {
+			refs[i].Data = backupVolumeDataFile(name)
+		}
 		return nil
 	}); err != nil {
 		return nil, err
@@ -1,18 +1,26 @@
 package backup
 
 import (
+	"archive/tar"
 	"bytes"
 	"context"
 	"docker-manager/internal/docker"
+	"docker-manager/internal/objectstore"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
 	"net/netip"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/moby/moby/api/types/container"
 	"github.com/moby/moby/api/types/mount"
@@ -29,8 +37,13 @@ type fakeBackupDockerService struct {
 	volume          volume.Volume
 	containerExists bool
 	imageExists     bool
+	volumeExists    bool
 	calls           []string
 	loadOutput      io.Writer
+	imageArchive    []byte
+	loadedImage     []byte
+	execExitCodes   map[string]int
+	created         container.InspectResponse
 }
 
 func (f *fakeBackupDockerService) ListContainers(ctx context.Context, all bool) ([]container.Summary, error) {
@@ -57,6 +70,9 @@ func (f *fakeBackupDockerService) SaveImage(ctx context.Context, refs []string,
 	if err := os.MkdirAll(filepath.Dir(outputFile), 0755); err != nil {
 		return err
 	}
+	if f.imageArchive != nil {
+		return os.WriteFile(outputFile, f.imageArchive, 0644)
+	}
 	return os.WriteFile(outputFile, []byte("image tar"), 0644)
 }
 
@@ -64,6 +80,7 @@ func (f *fakeBackupDockerService) LoadImage(ctx context.Context, inputFile strin
 	f.mu.Lock()
 	f.calls = append(f.calls, "load-image:"+filepath.Base(inputFile))
 	f.loadOutput = output
+	f.loadedImage, _ = os.ReadFile(inputFile)
 	f.mu.Unlock()
 	return nil
 }
@@ -96,10 +113,27 @@ func (f *fakeBackupDockerService) InspectVolume(ctx context.Context, name string
 	return f.volume, nil
 }
 
-func (f *fakeBackupDockerService) CreateVolume(ctx context.Context, vol volume.Volume) error {
+func (f *fakeBackupDockerService) CreateVolume(ctx context.Context, vol volume.Volume) (bool, error) {
 	f.mu.Lock()
 	f.calls = append(f.calls, "create-volume:"+vol.Name)
 	f.mu.Unlock()
+	return !f.volumeExists, nil
+}
+
+func (f *fakeBackupDockerService) ExportVolume(ctx context.Context, name, helperImage, outputFile string) error {
+	f.mu.Lock()
+	f.calls = append(f.calls, "export-volume:"+name+"@"+helperImage)
+	f.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(outputFile), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(outputFile, []byte("volume tar"), 0644)
+}
+
+func (f *fakeBackupDockerService) ImportVolume(ctx context.Context, name, helperImage, inputFile string) error {
+	f.mu.Lock()
+	f.calls = append(f.calls, "import-volume:"+name+"@"+helperImage+":"+filepath.Base(inputFile))
+	f.mu.Unlock()
 	return nil
 }
 
@@ -120,6 +154,7 @@ func (f *fakeBackupDockerService) RemoveContainer(ctx context.Context, name stri
 func (f *fakeBackupDockerService) CreateContainer(ctx context.Context, inspect container.InspectResponse, name string) (string, error) {
 	f.mu.Lock()
 	f.calls = append(f.calls, "create-container:"+name)
+	f.created = inspect
 	f.mu.Unlock()
 	return "restored-id", nil
 }
@@ -131,6 +166,40 @@ func (f *fakeBackupDockerService) StartContainer(ctx context.Context, id string)
 	return nil
 }
 
+func (f *fakeBackupDockerService) ExecContainer(ctx context.Context, name string, cmd []string) (int, string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	command := strings.Join(cmd, " ")
+	f.calls = append(f.calls, "exec:"+name+":"+command)
+	for pattern, code := range f.execExitCodes {
+		if strings.Contains(command, pattern) {
+			return code, "hook output", nil
+		}
+	}
+	return 0, "hook output", nil
+}
+
+func (f *fakeBackupDockerService) PauseContainer(ctx context.Context, name string) error {
+	f.mu.Lock()
+	f.calls = append(f.calls, "pause-container:"+name)
+	f.mu.Unlock()
+	return nil
+}
+
+func (f *fakeBackupDockerService) UnpauseContainer(ctx context.Context, name string) error {
+	f.mu.Lock()
+	f.calls = append(f.calls, "unpause-container:"+name)
+	f.mu.Unlock()
+	return nil
+}
+
+func (f *fakeBackupDockerService) StopContainer(ctx context.Context, name string) error {
+	f.mu.Lock()
+	f.calls = append(f.calls, "stop-container:"+name)
+	f.mu.Unlock()
+	return nil
+}
+
 func TestBackupContainerWritesBundle(t *testing.T) {
 	bindDir := t.TempDir()
 	deviceDir := t.TempDir()
@@ -726,6 +795,479 @@ func TestRestoreBackupSupportsEncryptedArchive(t *testing.T) {
 	}
 }
 
+func TestRestoreBackupSupportsRecipientEncryptedArchive(t *testing.T) {
+	root := t.TempDir()
+	writeIdentity := func(name string) (string, string) {
+		var buf bytes.Buffer
+		recipient, err := generateBackupIdentity(&buf)
+		if err != nil {
+			t.Fatalf("generateBackupIdentity() error = %v", err)
+		}
+		path := filepath.Join(root, name)
+		if err := os.WriteFile(path, buf.Bytes(), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path, recipient
+	}
+	opsIdentity, opsRecipient := writeIdentity("ops.key")
+	drIdentity, drRecipient := writeIdentity("dr.key")
+	otherIdentity, _ := writeIdentity("other.key")
+	recipientFile := filepath.Join(root, "recipients.txt")
+	if err := os.WriteFile(recipientFile, []byte("# disaster recovery\n"+drRecipient+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := filepath.Join(root, "bundle")
+	writeTestJSON(t, filepath.Join(dir, backupManifestName), BackupManifest{
+		Version:    1,
+		Containers: []BackupContainerManifest{{ContainerName: "demo", InspectFile: backupInspectName}},
+	})
+	writeTestJSON(t, filepath.Join(dir, backupInspectName), container.InspectResponse{
+		Name:       "/demo",
+		Config:     &container.Config{Image: "busybox:latest"},
+		HostConfig: &container.HostConfig{},
+	})
+	archiveOpts, err := archiveOptionsFromBackup(BackupOptions{Encrypt: true, Recipients: []string{opsRecipient, recipientFile}})
+	if err != nil {
+		t.Fatalf("archiveOptionsFromBackup() error = %v", err)
+	}
+	if len(archiveOpts.Recipients) != 2 {
+		t.Fatalf("Recipients = %d, want 2", len(archiveOpts.Recipients))
+	}
+	archive := filepath.Join(root, "bundle.tar.gz.enc")
+	if err := createBackupArchiveWithOptions(context.Background(), dir, archive, archiveOpts); err != nil {
+		t.Fatalf("createBackupArchiveWithOptions() error = %v", err)
+	}
+	data, err := os.ReadFile(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(data, []byte(backupRecipientMagic)) || !bytes.Contains(data[:512], []byte(`"scheme":"recipients"`)) {
+		t.Fatalf("header = %q, want recipient scheme header", data[:64])
+	}
+
+	fake := &fakeBackupDockerService{}
+	restoreFactory := replaceBackupServiceFactory(fake)
+	defer restoreFactory()
+	for _, identity := range []string{opsIdentity, drIdentity} {
+		if err := restoreBackup(context.Background(), archive, RestoreOptions{NoStart: true, IdentityFiles: []string{identity}}); err != nil {
+			t.Fatalf("restoreBackup() with %s error = %v", filepath.Base(identity), err)
+		}
+	}
+	err = restoreBackup(context.Background(), archive, RestoreOptions{NoStart: true, IdentityFiles: []string{otherIdentity}})
+	if err == nil || !strings.Contains(err.Error(), "recipients=2") {
+		t.Fatalf("restoreBackup() with unrelated identity error = %v, want no matching identity", err)
+	}
+	passFile := filepath.Join(root, "pass.txt")
+	if err := os.WriteFile(passFile, []byte("secret-pass\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := restoreBackup(context.Background(), archive, RestoreOptions{NoStart: true, PassphraseFile: passFile}); err == nil {
+		t.Fatal("restoreBackup() with passphrase error = nil, want recipient-only bundle to reject passphrase")
+	}
+
+	data[len(data)-10] ^= 0xff
+	if err := os.WriteFile(archive, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := restoreBackup(context.Background(), archive, RestoreOptions{NoStart: true, IdentityFiles: []string{opsIdentity}}); err == nil {
+		t.Fatal("restoreBackup() tampered archive error = nil, want decrypt error")
+	}
+}
+
+func TestBackupVerifyChecksSignatureSplitPartsAndEncryption(t *testing.T) {
+	fake := &fakeBackupDockerService{
+		inspect: container.InspectResponse{
+			Name:       "/demo",
+			HostConfig: &container.HostConfig{},
+			Config:     &container.Config{Image: "busybox:latest"},
+		},
+	}
+	fake.imageArchive = make([]byte, 32*1024)
+	rand.New(rand.NewSource(8)).Read(fake.imageArchive)
+	restoreFactory := replaceBackupServiceFactory(fake)
+	defer restoreFactory()
+
+	root := t.TempDir()
+	signKey := filepath.Join(root, "sign.key")
+	otherKey := filepath.Join(root, "other.key")
+	for _, path := range []string{signKey, otherKey} {
+		if err := writeBackupSignKeyPair(io.Discard, path); err != nil {
+			t.Fatalf("writeBackupSignKeyPair() error = %v", err)
+		}
+	}
+	passFile := filepath.Join(root, "pass.txt")
+	if err := os.WriteFile(passFile, []byte("secret-pass\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(root, "bundle")
+	archive := filepath.Join(root, "demo.tar.gz.enc")
+	if _, err := backupContainer(context.Background(), "demo", BackupOptions{
+		OutputDir:      dir,
+		IncludeImage:   true,
+		Bundle:         true,
+		BundleOutput:   archive,
+		Encrypt:        true,
+		PassphraseFile: passFile,
+		SignKey:        signKey,
+		SplitSize:      "8k",
+	}); err != nil {
+		t.Fatalf("backupContainer() error = %v", err)
+	}
+
+	cmd := NewBackupCommand()
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetErr(&bytes.Buffer{})
+	cmd.SetArgs([]string{"verify", splitPartPath(archive, 1), "--trusted-key", signKey + ".pub", "--passphrase-file", passFile, "--format", "json"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("verify Execute() error = %v output=%s", err, out.String())
+	}
+	var report BackupVerifyReport
+	if err := json.Unmarshal(out.Bytes(), &report); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v output=%q", err, out.String())
+	}
+	if !report.OK || report.Kind != "split+encrypted" || len(report.Parts) < 2 || report.Encryption != backupSchemePassphrase || !report.Decrypted {
+		t.Fatalf("report = %#v, want verified split encrypted bundle", report)
+	}
+	if report.Signature.Status != "valid" || report.Checksums.Status != "ok" || len(report.Containers) != 1 {
+		t.Fatalf("signature=%#v checksums=%#v, want valid signature and checksums", report.Signature, report.Checksums)
+	}
+
+	untrusted, err := verifyBackupBundle(context.Background(), splitPartPath(archive, 1), BackupVerifyOptions{TrustedKeys: []string{otherKey + ".pub"}, PassphraseFile: passFile})
+	if err != nil || untrusted.OK || untrusted.Signature.Status != "untrusted" {
+		t.Fatalf("verify with other key = %#v err=%v, want untrusted failure", untrusted, err)
+	}
+	noKeys, err := verifyBackupBundle(context.Background(), splitPartPath(archive, 1), BackupVerifyOptions{})
+	if err != nil || noKeys.OK || noKeys.Checksums.Status != "" {
+		t.Fatalf("verify without passphrase = %#v err=%v, want failure before content checks", noKeys, err)
+	}
+
+	if err := os.Rename(splitPartPath(archive, 2), filepath.Join(root, "moved")); err != nil {
+		t.Fatal(err)
+	}
+	missing, err := verifyBackupBundle(context.Background(), splitPartPath(archive, 1), BackupVerifyOptions{PassphraseFile: passFile})
+	if err != nil || missing.OK || !strings.Contains(strings.Join(missing.Errors, "\n"), "分卷缺失") {
+		t.Fatalf("verify with missing part = %#v err=%v, want missing part error", missing, err)
+	}
+
+	// Regenerating checksums after tampering is exactly what the signature
+	// has to catch.
+	if err := os.WriteFile(filepath.Join(dir, backupInspectName), []byte(`{"Name":"/evil"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeChecksums(dir); err != nil {
+		t.Fatal(err)
+	}
+	tampered, err := verifyBackupBundle(context.Background(), dir, BackupVerifyOptions{TrustedKeys: []string{signKey + ".pub"}})
+	if err != nil || tampered.OK || tampered.Checksums.Status != "ok" || tampered.Signature.Status != "invalid" {
+		t.Fatalf("verify tampered dir = %#v err=%v, want invalid signature", tampered, err)
+	}
+	// Whoever can tamper can also re-sign with their own key; without a
+	// pinned signer that must not count as a pass.
+	attacker, err := loadBackupSignKey(otherKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, backupSignatureName)); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeChecksums(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeBackupSignature(dir, attacker); err != nil {
+		t.Fatal(err)
+	}
+	resigned, err := verifyBackupBundle(context.Background(), dir, BackupVerifyOptions{})
+	if err != nil || resigned.OK || resigned.Result != "unverified" || resigned.Signature.Status != "untrusted" || len(resigned.Errors) != 0 {
+		t.Fatalf("verify re-signed dir = %#v err=%v, want unverified result", resigned, err)
+	}
+	pinned, err := verifyBackupBundle(context.Background(), dir, BackupVerifyOptions{TrustedKeys: []string{signKey + ".pub"}})
+	if err != nil || pinned.OK || pinned.Result != "failed" || pinned.Signature.Status != "untrusted" {
+		t.Fatalf("verify re-signed dir with pinned key = %#v err=%v, want failure", pinned, err)
+	}
+	out.Reset()
+	cmd = NewBackupCommand()
+	cmd.SetOut(&out)
+	cmd.SetErr(&out)
+	cmd.SetArgs([]string{"verify", dir})
+	if err := cmd.Execute(); err == nil || !strings.Contains(out.String(), "结果: 未验证") {
+		t.Fatalf("verify command on re-signed dir err=%v output=%q, want unverified error", err, out.String())
+	}
+	// Stripping the signature is even cheaper than re-signing and must not
+	// fare any better.
+	if err := os.Remove(filepath.Join(dir, backupSignatureName)); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeChecksums(dir); err != nil {
+		t.Fatal(err)
+	}
+	stripped, err := verifyBackupBundle(context.Background(), dir, BackupVerifyOptions{})
+	if err != nil || stripped.OK || stripped.Result != "unverified" || stripped.Signature.Status != "missing" || stripped.Checksums.Status != "ok" {
+		t.Fatalf("verify stripped dir = %#v err=%v, want unverified result", stripped, err)
+	}
+	strippedPinned, err := verifyBackupBundle(context.Background(), dir, BackupVerifyOptions{TrustedKeys: []string{signKey + ".pub"}})
+	if err != nil || strippedPinned.Result != "failed" {
+		t.Fatalf("verify stripped dir with pinned key = %#v err=%v, want failure", strippedPinned, err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "extra.sh"), []byte("echo"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	unlisted, err := verifyBackupBundle(context.Background(), dir, BackupVerifyOptions{})
+	if err != nil || unlisted.OK || unlisted.Checksums.Status != "unlisted" || unlisted.Checksums.Unlisted[0] != "extra.sh" {
+		t.Fatalf("verify dir with extra file = %#v err=%v, want unlisted file failure", unlisted, err)
+	}
+}
+
+func TestBackupListAndExtractReadEncryptedSplitBundleOffline(t *testing.T) {
+	fake := &fakeBackupDockerService{
+		inspect: container.InspectResponse{
+			Name:       "/web",
+			HostConfig: &container.HostConfig{},
+			Config:     &container.Config{Image: "nginx:1.27"},
+		},
+	}
+	fake.imageArchive = make([]byte, 32*1024)
+	rand.New(rand.NewSource(9)).Read(fake.imageArchive)
+	restoreFactory := replaceBackupServiceFactory(fake)
+	defer restoreFactory()
+
+	root := t.TempDir()
+	passFile := filepath.Join(root, "pass.txt")
+	if err := os.WriteFile(passFile, []byte("secret-pass\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	archive := filepath.Join(root, "web.tar.gz.enc")
+	if _, err := backupContainer(context.Background(), "web", BackupOptions{
+		OutputDir:      filepath.Join(root, "bundle"),
+		IncludeImage:   true,
+		Bundle:         true,
+		BundleOutput:   archive,
+		Encrypt:        true,
+		PassphraseFile: passFile,
+		SplitSize:      "8k",
+	}); err != nil {
+		t.Fatalf("backupContainer() error = %v", err)
+	}
+	// Listing and extracting must not need Docker.
+	restoreFactory()
+	defer replaceBackupServiceFactory(nil)()
+
+	cmd := NewBackupCommand()
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetErr(&bytes.Buffer{})
+	cmd.SetArgs([]string{"ls", splitPartPath(archive, 1), "--passphrase-file", passFile, "--format", "json"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("ls Execute() error = %v output=%s", err, out.String())
+	}
+	var listing BackupListing
+	if err := json.Unmarshal(out.Bytes(), &listing); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v output=%q", err, out.String())
+	}
+	if listing.Kind != "split+encrypted" || listing.Encryption != backupSchemePassphrase || len(listing.Containers) != 1 {
+		t.Fatalf("listing = %#v, want one container in split encrypted bundle", listing)
+	}
+	web := listing.Containers[0]
+	var imageSize int64
+	for _, artifact := range web.Artifacts {
+		if artifact.Missing {
+			t.Fatalf("artifact %#v missing from archive", artifact)
+		}
+		if artifact.Kind == "image" {
+			imageSize = artifact.Size
+		}
+	}
+	if web.Name != "web" || web.Image != "nginx:1.27" || imageSize != int64(len(fake.imageArchive)) {
+		t.Fatalf("container = %#v, want web with %d byte image archive", web, len(fake.imageArchive))
+	}
+
+	outputDir := filepath.Join(root, "extracted")
+	result, err := extractBackupBundle(context.Background(), splitPartPath(archive, 1), BackupExtractOptions{
+		Containers:     []string{"web"},
+		Only:           []string{"inspect,image"},
+		OutputDir:      outputDir,
+		PassphraseFile: passFile,
+	})
+	if err != nil {
+		t.Fatalf("extractBackupBundle() error = %v", err)
+	}
+	if len(result.Files) != 2 {
+		t.Fatalf("extracted = %#v, want inspect and image", result.Files)
+	}
+	inspect, err := os.ReadFile(filepath.Join(outputDir, "web", backupInspectName))
+	if err != nil || !strings.Contains(string(inspect), "nginx:1.27") {
+		t.Fatalf("extracted inspect = %q err=%v", inspect, err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "web", backupComposeName)); !os.IsNotExist(err) {
+		t.Fatalf("compose extracted without --only compose: %v", err)
+	}
+	if _, err := extractBackupBundle(context.Background(), splitPartPath(archive, 1), BackupExtractOptions{Only: []string{"inspect"}, OutputDir: outputDir, PassphraseFile: passFile}); err == nil || !strings.Contains(err.Error(), "--force") {
+		t.Fatalf("extract over existing file err = %v, want --force hint", err)
+	}
+	if _, err := extractBackupBundle(context.Background(), splitPartPath(archive, 1), BackupExtractOptions{Containers: []string{"db"}, PassphraseFile: passFile}); err == nil || !strings.Contains(err.Error(), "available: web") {
+		t.Fatalf("extract unknown container err = %v, want available containers", err)
+	}
+	if _, err := extractBackupBundle(context.Background(), splitPartPath(archive, 1), BackupExtractOptions{Only: []string{"logs"}}); err == nil || !strings.Contains(err.Error(), "--only") {
+		t.Fatalf("extract unknown kind err = %v, want --only error", err)
+	}
+}
+
+func TestBackupStreamsBundleToS3AndRestoresFromIt(t *testing.T) {
+	store := newFakeBackupS3(t)
+	s3 := objectstore.Config{Endpoint: store.URL, AccessKeyID: "minio", SecretAccessKey: "minio-secret", PartSize: 4096}
+	fake := &fakeBackupDockerService{
+		inspect: container.InspectResponse{
+			Name:       "/web",
+			HostConfig: &container.HostConfig{},
+			Config:     &container.Config{Image: "nginx:1.27"},
+		},
+	}
+	fake.imageArchive = make([]byte, 48*1024)
+	rand.New(rand.NewSource(10)).Read(fake.imageArchive)
+	restoreFactory := replaceBackupServiceFactory(fake)
+	defer restoreFactory()
+
+	root := t.TempDir()
+	passFile := filepath.Join(root, "pass.txt")
+	if err := os.WriteFile(passFile, []byte("secret-pass\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := backupContainer(context.Background(), "web", BackupOptions{
+		OutputDir:      filepath.Join(root, "web"),
+		IncludeImage:   true,
+		Bundle:         true,
+		BundleOutput:   "s3://backups/prod/web.tar.gz",
+		Encrypt:        true,
+		PassphraseFile: passFile,
+		SplitSize:      "20k",
+		S3:             s3,
+	}); err != nil {
+		t.Fatalf("backupContainer() error = %v", err)
+	}
+	if len(store.objects) < 3 || store.objects["backups/prod/web.tar.gz.enc.part-001"] == nil || store.multipart == 0 {
+		t.Fatalf("objects=%v multipart=%d, want split encrypted parts uploaded with multipart", store.keys(), store.multipart)
+	}
+	if _, err := os.Stat(filepath.Join(root, "web.tar.gz.enc.part-001")); !os.IsNotExist(err) {
+		t.Fatalf("local archive written for s3 output: %v", err)
+	}
+
+	if err := restoreBackup(context.Background(), "s3://backups/prod/web.tar.gz.enc.part-001", RestoreOptions{NoStart: true, PassphraseFile: passFile, S3: s3}); err != nil {
+		t.Fatalf("restoreBackup() from s3 error = %v", err)
+	}
+	if fake.created.Config == nil || fake.created.Config.Image != "nginx:1.27" {
+		t.Fatalf("created = %#v, want container restored from s3 bundle", fake.created.Config)
+	}
+
+	err := restoreBackup(context.Background(), "s3://backups/prod/missing.tar.gz", RestoreOptions{S3: s3})
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("restore missing object err = %v, want not exist", err)
+	}
+	if _, err := archiveOptionsFromBackup(BackupOptions{Bundle: true, BundleOutput: "s3://backups/web.tar.gz"}); err == nil || !strings.Contains(err.Error(), "AWS_ACCESS_KEY_ID") {
+		t.Fatalf("archiveOptionsFromBackup() without credentials err = %v", err)
+	}
+}
+
+func TestRestoreFromS3RejectsTruncatedEncryptedArchive(t *testing.T) {
+	store := newFakeBackupS3(t)
+	s3 := objectstore.Config{Endpoint: store.URL, AccessKeyID: "minio", SecretAccessKey: "minio-secret", PartSize: 4096}
+	fake := &fakeBackupDockerService{
+		inspect: container.InspectResponse{
+			Name:       "/web",
+			HostConfig: &container.HostConfig{},
+			Config:     &container.Config{Image: "nginx:1.27"},
+		},
+	}
+	restoreFactory := replaceBackupServiceFactory(fake)
+	defer restoreFactory()
+
+	root := t.TempDir()
+	passFile := filepath.Join(root, "pass.txt")
+	if err := os.WriteFile(passFile, []byte("secret-pass\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := backupContainer(context.Background(), "web", BackupOptions{
+		OutputDir:      filepath.Join(root, "web"),
+		Bundle:         true,
+		BundleOutput:   "s3://backups/web.tar.gz",
+		Encrypt:        true,
+		PassphraseFile: passFile,
+		S3:             s3,
+	}); err != nil {
+		t.Fatalf("backupContainer() error = %v", err)
+	}
+	key := "backups/web.tar.gz.enc"
+	store.objects[key] = store.objects[key][:len(store.objects[key])-16]
+
+	err := restoreBackup(context.Background(), "s3://"+key, RestoreOptions{NoStart: true, PassphraseFile: passFile, S3: s3})
+	if err == nil {
+		t.Fatal("restoreBackup() error = nil, want truncated archive rejected")
+	}
+	if hasCall(fake.calls, "create-container:web") {
+		t.Fatalf("calls = %#v, want no container created from truncated archive", fake.calls)
+	}
+}
+
+type fakeBackupS3 struct {
+	*httptest.Server
+	mu        sync.Mutex
+	objects   map[string][]byte
+	uploads   map[string][][]byte
+	multipart int
+}
+
+func newFakeBackupS3(t *testing.T) *fakeBackupS3 {
+	store := &fakeBackupS3{objects: map[string][]byte{}, uploads: map[string][][]byte{}}
+	store.Server = httptest.NewServer(http.HandlerFunc(store.handle))
+	t.Cleanup(store.Close)
+	return store
+}
+
+func (s *fakeBackupS3) keys() []string {
+	var keys []string
+	for key := range s.objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (s *fakeBackupS3) handle(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !strings.Contains(r.Header.Get("Authorization"), "Credential=minio/") {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	name := strings.TrimPrefix(r.URL.Path, "/")
+	query := r.URL.Query()
+	body, _ := io.ReadAll(r.Body)
+	switch {
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		id := fmt.Sprintf("u%d", len(s.uploads)+1)
+		s.uploads[id] = nil
+		fmt.Fprintf(w, "<InitiateMultipartUploadResult><UploadId>%s</UploadId></InitiateMultipartUploadResult>", id)
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		s.uploads[query.Get("uploadId")] = append(s.uploads[query.Get("uploadId")], body)
+		w.Header().Set("ETag", `"etag"`)
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		s.objects[name] = bytes.Join(s.uploads[query.Get("uploadId")], nil)
+		delete(s.uploads, query.Get("uploadId"))
+		s.multipart++
+	case r.Method == http.MethodPut:
+		s.objects[name] = body
+	case r.Method == http.MethodGet:
+		data, ok := s.objects[name]
+		if !ok {
+			http.Error(w, "<Error><Code>NoSuchKey</Code></Error>", http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(data)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
 func TestRestoreBackupSupportsSplitArchive(t *testing.T) {
 	root := t.TempDir()
 	dir := filepath.Join(root, "bundle")
@@ -1179,6 +1721,681 @@ func TestRestoreBackupReplaceCreatesAndStartsContainer(t *testing.T) {
 	}
 }
 
+func TestRestoreCommandRemapsNamesNetworksVolumesPortsAndEnv(t *testing.T) {
+	dir := t.TempDir()
+	imageArchive := filepath.ToSlash(filepath.Join("images", "web.tar"))
+	networkFile := filepath.ToSlash(filepath.Join("networks", "web_net.json"))
+	volumeFile := filepath.ToSlash(filepath.Join("volumes", "web_data.json"))
+	dataFile := filepath.ToSlash(filepath.Join("volumes", "web_data.tar"))
+	writeTestJSON(t, filepath.Join(dir, backupManifestName), BackupManifest{
+		Version: 1,
+		Containers: []BackupContainerManifest{{
+			ContainerName: "web",
+			Image:         "nginx:1.25",
+			ImageArchive:  imageArchive,
+			InspectFile:   backupInspectName,
+			Networks:      []BackupResourceRef{{Name: "web_net", File: networkFile}},
+			Volumes:       []BackupResourceRef{{Name: "web_data", File: volumeFile, Data: dataFile}},
+		}},
+	})
+	writeTestJSON(t, filepath.Join(dir, backupInspectName), container.InspectResponse{
+		Name:   "/web",
+		Config: &container.Config{Image: "nginx:1.25", Env: []string{"MODE=prod", "DB_PASSWORD=secret"}},
+		HostConfig: &container.HostConfig{
+			NetworkMode: "web_net",
+			Binds:       []string{"web_data:/data:rw", "/srv/conf:/etc/nginx/conf.d:ro"},
+			PortBindings: network.PortMap{
+				network.MustParsePort("80/tcp"):  {{HostPort: "8080"}},
+				network.MustParsePort("443/tcp"): {{HostPort: "8443"}},
+			},
+		},
+		Mounts: []container.MountPoint{{Type: mount.TypeVolume, Name: "web_data", Destination: "/data"}},
+		NetworkSettings: &container.NetworkSettings{Networks: map[string]*network.EndpointSettings{
+			"web_net": {Aliases: []string{"web"}, IPAMConfig: &network.EndpointIPAMConfig{IPv4Address: netip.MustParseAddr("172.30.0.10")}},
+		}},
+	})
+	writeTestJSON(t, filepath.Join(dir, filepath.FromSlash(networkFile)), network.Inspect{Network: network.Network{
+		Name: "web_net",
+		IPAM: network.IPAM{Config: []network.IPAMConfig{{Subnet: netip.MustParsePrefix("172.30.0.0/24")}}},
+	}})
+	writeTestJSON(t, filepath.Join(dir, filepath.FromSlash(volumeFile)), volume.Volume{Name: "web_data"})
+	for _, name := range []string{imageArchive, dataFile} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("tar"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	remapFile := filepath.Join(t.TempDir(), "remap.yaml")
+	if err := os.WriteFile(remapFile, []byte("containers:\n  web: web-old\nports:\n  offset: 1000\nenv:\n  MODE: staging\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	args := []string{dir, "--skip-checksum", "--remap-file", remapFile,
+		"--rename", "web=web-staging", "--network", "web_net=staging_net", "--volume", "web_data=staging_data",
+		"--port", "8443=9443", "--image", "nginx:1.27"}
+
+	fake := &fakeBackupDockerService{}
+	restoreFactory := replaceBackupServiceFactory(fake)
+	defer restoreFactory()
+
+	cmd := NewRestoreCommand()
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetErr(&bytes.Buffer{})
+	cmd.SetArgs(append([]string{"--dry-run", "--format", "json"}, args...))
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("dry-run Execute() error = %v", err)
+	}
+	var report RestorePlanReport
+	if err := json.Unmarshal(out.Bytes(), &report); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v output=%q", err, out.String())
+	}
+	plan := report.Containers[0]
+	if plan.ContainerName != "web-staging" || plan.Image.Ref != "nginx:1.27" || plan.Image.Archive != "" {
+		t.Fatalf("plan = %#v, want renamed container with remapped image and no archive", plan)
+	}
+	if plan.Networks[0].Name != "staging_net" || plan.Volumes[0].Name != "staging_data" {
+		t.Fatalf("networks=%#v volumes=%#v, want remapped resources", plan.Networks, plan.Volumes)
+	}
+	if strings.Join(plan.Ports, ",") != "0.0.0.0:9080->80/tcp,0.0.0.0:9443->443/tcp" {
+		t.Fatalf("ports = %#v, want offset and explicit port remap", plan.Ports)
+	}
+	if strings.Contains(out.String(), "MODE=staging") || strings.Contains(out.String(), "secret") {
+		t.Fatalf("plan output = %s, must not leak environment values", out.String())
+	}
+	if !hasCall(plan.Remapped, "env MODE") {
+		t.Fatalf("remapped = %#v, want env key summary", plan.Remapped)
+	}
+
+	cmd = NewRestoreCommand()
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+	cmd.SetArgs(append([]string{"--no-start"}, args...))
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("restore Execute() error = %v", err)
+	}
+	for _, want := range []string{"create-network:staging_net", "create-volume:staging_data", "import-volume:staging_data@nginx:1.27:web_data.tar", "create-container:web-staging"} {
+		if !hasCall(fake.calls, want) {
+			t.Fatalf("calls = %#v, want %s", fake.calls, want)
+		}
+	}
+	if hasCallPrefix(fake.calls, "load-image:") {
+		t.Fatalf("calls = %#v, remapped image should not load the archived image", fake.calls)
+	}
+	created := fake.created
+	if created.Config.Image != "nginx:1.27" || strings.Join(created.Config.Env, ",") != "MODE=staging,DB_PASSWORD=secret" {
+		t.Fatalf("config = %#v, want remapped image and env", created.Config)
+	}
+	if created.HostConfig.NetworkMode != "staging_net" || created.HostConfig.Binds[0] != "staging_data:/data:rw" || created.HostConfig.Binds[1] != "/srv/conf:/etc/nginx/conf.d:ro" {
+		t.Fatalf("host config = %#v, want remapped network mode and named volume bind", created.HostConfig)
+	}
+	endpoint := created.NetworkSettings.Networks["staging_net"]
+	if endpoint == nil || endpoint.IPAMConfig != nil || endpoint.Aliases[0] != "web" {
+		t.Fatalf("networks = %#v, want renamed endpoint without the original static address", created.NetworkSettings.Networks)
+	}
+}
+
+func TestBackupContainerExportsVolumeDataIntoBundle(t *testing.T) {
+	fake := &fakeBackupDockerService{
+		inspect: container.InspectResponse{
+			Name:       "/db",
+			Image:      "sha256:abc",
+			Config:     &container.Config{Image: "postgres:16"},
+			HostConfig: &container.HostConfig{},
+			Mounts: []container.MountPoint{
+				{Type: mount.TypeVolume, Name: "db_data", Destination: "/var/lib/postgresql/data", RW: true},
+			},
+		},
+		volume: volume.Volume{Name: "db_data", Driver: "local"},
+	}
+	restoreFactory := replaceBackupServiceFactory(fake)
+	defer restoreFactory()
+
+	dir := filepath.Join(t.TempDir(), "db")
+	if _, err := backupContainer(context.Background(), "db", BackupOptions{
+		OutputDir:         dir,
+		IncludeVolumeData: true,
+		Bundle:            true,
+	}); err != nil {
+		t.Fatalf("backupContainer() error = %v", err)
+	}
+	if !hasCall(fake.calls, "export-volume:db_data@sha256:abc") {
+		t.Fatalf("calls = %#v, want volume export with source image helper", fake.calls)
+	}
+	var manifest BackupManifest
+	readTestJSON(t, filepath.Join(dir, backupManifestName), &manifest)
+	volumes := manifest.Containers[0].Volumes
+	if len(volumes) != 1 || volumes[0].Data != "volumes/db_data.tar" {
+		t.Fatalf("Volumes = %#v, want db_data data archive", volumes)
+	}
+	checksums, err := os.ReadFile(filepath.Join(dir, backupChecksumName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(checksums), "  volumes/db_data.tar\n") {
+		t.Fatalf("checksums = %q, want volume data entry", checksums)
+	}
+}
+
+func TestRestoreBackupImportsVolumeDataOnlyIntoCreatedVolumes(t *testing.T) {
+	dir := t.TempDir()
+	volumeFile := filepath.ToSlash(filepath.Join("volumes", "db_data.json"))
+	dataFile := filepath.ToSlash(filepath.Join("volumes", "db_data.tar"))
+	writeTestJSON(t, filepath.Join(dir, backupManifestName), BackupManifest{
+		Version: 1,
+		Containers: []BackupContainerManifest{{
+			ContainerName: "db",
+			InspectFile:   backupInspectName,
+			Volumes:       []BackupResourceRef{{Name: "db_data", File: volumeFile, Data: dataFile}},
+		}},
+	})
+	writeTestJSON(t, filepath.Join(dir, backupInspectName), container.InspectResponse{
+		Name:   "/db",
+		Config: &container.Config{Image: "postgres:16"},
+	})
+	writeTestJSON(t, filepath.Join(dir, filepath.FromSlash(volumeFile)), volume.Volume{Name: "db_data"})
+	if err := os.WriteFile(filepath.Join(dir, filepath.FromSlash(dataFile)), []byte("volume tar"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fake := &fakeBackupDockerService{}
+	restoreFactory := replaceBackupServiceFactory(fake)
+	defer restoreFactory()
+	if err := restoreBackup(context.Background(), dir, RestoreOptions{NoStart: true}); err != nil {
+		t.Fatalf("restoreBackup() error = %v", err)
+	}
+	if !hasCall(fake.calls, "import-volume:db_data@postgres:16:db_data.tar") {
+		t.Fatalf("calls = %#v, want volume data import", fake.calls)
+	}
+
+	existing := &fakeBackupDockerService{volumeExists: true}
+	restoreFactory = replaceBackupServiceFactory(existing)
+	defer restoreFactory()
+	if err := restoreBackup(context.Background(), dir, RestoreOptions{NoStart: true}); err != nil {
+		t.Fatalf("restoreBackup() existing volume error = %v", err)
+	}
+	if hasCallPrefix(existing.calls, "import-volume:") {
+		t.Fatalf("calls = %#v, existing volume should keep its data", existing.calls)
+	}
+
+	report, err := buildRestorePlanReportFromDir(context.Background(), existing, dir, dir, "", RestoreOptions{})
+	if err != nil {
+		t.Fatalf("buildRestorePlanReportFromDir() error = %v", err)
+	}
+	plan := report.Containers[0]
+	if plan.Volumes[0].DataAction != "skip-existing" || len(plan.Warnings) == 0 {
+		t.Fatalf("volume plan = %#v warnings=%#v, want skip-existing warning", plan.Volumes, plan.Warnings)
+	}
+}
+
+func TestBackupAndRestoreBindMountData(t *testing.T) {
+	bindDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(bindDir, "conf"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(bindDir, "conf", "app.ini"), []byte("port=80"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(bindDir, "debug.log"), []byte("noise"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fake := &fakeBackupDockerService{
+		inspect: container.InspectResponse{
+			Name:   "/legacy",
+			Config: &container.Config{Image: "legacy:1"},
+			HostConfig: &container.HostConfig{
+				Binds: []string{bindDir + ":/etc/legacy:ro"},
+			},
+			Mounts: []container.MountPoint{
+				{Type: mount.TypeBind, Source: bindDir, Destination: "/etc/legacy"},
+			},
+		},
+	}
+	restoreFactory := replaceBackupServiceFactory(fake)
+	defer restoreFactory()
+
+	dir := filepath.Join(t.TempDir(), "legacy")
+	if _, err := backupContainer(context.Background(), "legacy", BackupOptions{OutputDir: dir, IncludeBindMounts: true, BindExclude: []string{"*.log"}, BindMaxSize: "1M"}); err != nil {
+		t.Fatalf("backupContainer() error = %v", err)
+	}
+	var manifest BackupManifest
+	readTestJSON(t, filepath.Join(dir, backupManifestName), &manifest)
+	bindMount := findBackupMount(manifest.Containers[0].Mounts, "bind", "/etc/legacy")
+	if bindMount == nil || bindMount.Data != "binds/etc_legacy.tar" || bindMount.DataKind != "dir" || bindMount.DataSize != int64(len("port=80")) {
+		t.Fatalf("bind mount = %#v, want dir data archive without excluded log", bindMount)
+	}
+
+	bindRoot := t.TempDir()
+	target := restoreBindTarget(bindRoot, bindDir)
+	if err := os.MkdirAll(target, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(target, "old.txt"), []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	report, err := buildRestorePlanReportFromDir(context.Background(), fake, dir, dir, "", RestoreOptions{BindRoot: bindRoot})
+	if err != nil {
+		t.Fatalf("buildRestorePlanReportFromDir() error = %v", err)
+	}
+	binds := report.Containers[0].BindMounts
+	if len(binds) != 1 || binds[0].Target != target || binds[0].Action != "keep" || report.Summary.BindPathsOverwrite != 0 {
+		t.Fatalf("bind plans = %#v summary=%#v, want existing %s kept without --overwrite-bind-data", binds, report.Summary, target)
+	}
+	var kept bytes.Buffer
+	if err := restoreBackup(context.Background(), dir, RestoreOptions{BindRoot: bindRoot, NoStart: true, Output: &kept}); err != nil {
+		t.Fatalf("restoreBackup() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(target, "conf", "app.ini")); !os.IsNotExist(err) || !strings.Contains(kept.String(), "--overwrite-bind-data") {
+		t.Fatalf("app.ini stat err = %v output = %q, want non-empty target skipped", err, kept.String())
+	}
+
+	report, err = buildRestorePlanReportFromDir(context.Background(), fake, dir, dir, "", RestoreOptions{BindRoot: bindRoot, OverwriteBindData: true})
+	if err != nil {
+		t.Fatalf("buildRestorePlanReportFromDir() error = %v", err)
+	}
+	binds = report.Containers[0].BindMounts
+	if len(binds) != 1 || binds[0].Target != target || binds[0].Action != "overwrite" || report.Summary.BindPathsOverwrite != 1 {
+		t.Fatalf("bind plans = %#v summary=%#v, want overwrite of %s", binds, report.Summary, target)
+	}
+
+	var out bytes.Buffer
+	if err := restoreBackup(context.Background(), dir, RestoreOptions{BindRoot: bindRoot, OverwriteBindData: true, NoStart: true, Output: &out}); err != nil {
+		t.Fatalf("restoreBackup() error = %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(target, "conf", "app.ini"))
+	if err != nil || string(data) != "port=80" {
+		t.Fatalf("restored app.ini = %q, %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(target, "debug.log")); !os.IsNotExist(err) {
+		t.Fatalf("debug.log stat err = %v, want excluded file missing", err)
+	}
+	if !strings.Contains(out.String(), "将覆盖已存在的 bind 路径") {
+		t.Fatalf("output = %q, want overwrite warning", out.String())
+	}
+
+	remapped := remapRestoreBindSources(fake.inspect, map[string]string{bindDir: target})
+	if remapped.HostConfig.Binds[0] != target+":/etc/legacy:ro" || fake.inspect.HostConfig.Binds[0] != bindDir+":/etc/legacy:ro" {
+		t.Fatalf("binds = %#v original=%#v, want remapped copy", remapped.HostConfig.Binds, fake.inspect.HostConfig.Binds)
+	}
+}
+
+func TestRestoreBindArchiveRejectsSymlinkEscapingTarget(t *testing.T) {
+	source := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(source, "conf"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(source, "conf", "app.ini"), []byte("port=80"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	archive := filepath.Join(t.TempDir(), "bind.tar")
+	if err := writeBindArchive(context.Background(), source, backupBindKindDir, nil, archive); err != nil {
+		t.Fatalf("writeBindArchive() error = %v", err)
+	}
+
+	outside := t.TempDir()
+	target := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(target, "conf")); err != nil {
+		t.Fatal(err)
+	}
+	err := restoreBindArchive(context.Background(), archive, target, backupBindKindDir)
+	if err == nil || !strings.Contains(err.Error(), "恢复目录之外") {
+		t.Fatalf("restoreBindArchive() error = %v, want symlink escape rejected", err)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "app.ini")); !os.IsNotExist(err) {
+		t.Fatalf("app.ini written outside target: %v", err)
+	}
+}
+
+func TestRestoreBindArchiveAppliesReadOnlyDirModeAfterChildren(t *testing.T) {
+	source := t.TempDir()
+	conf := filepath.Join(source, "conf")
+	if err := os.MkdirAll(conf, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(conf, "app.ini"), []byte("port=80"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(conf, 0555); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chmod(conf, 0755) })
+	archive := filepath.Join(t.TempDir(), "bind.tar")
+	if err := writeBindArchive(context.Background(), source, backupBindKindDir, nil, archive); err != nil {
+		t.Fatalf("writeBindArchive() error = %v", err)
+	}
+
+	target := t.TempDir()
+	restored := filepath.Join(target, "conf")
+	t.Cleanup(func() { _ = os.Chmod(restored, 0755) })
+	// 第二次恢复覆盖已经是 0555 的目录
+	for i := 0; i < 2; i++ {
+		if err := restoreBindArchive(context.Background(), archive, target, backupBindKindDir); err != nil {
+			t.Fatalf("restoreBindArchive() #%d error = %v", i+1, err)
+		}
+	}
+	data, err := os.ReadFile(filepath.Join(restored, "app.ini"))
+	if err != nil || string(data) != "port=80" {
+		t.Fatalf("restored app.ini = %q, %v", data, err)
+	}
+	info, err := os.Stat(restored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0555 {
+		t.Fatalf("restored conf mode = %v, want 0555", info.Mode().Perm())
+	}
+}
+
+func TestBackupBindMountDataEnforcesSizeCap(t *testing.T) {
+	bindDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bindDir, "big.bin"), bytes.Repeat([]byte("x"), 2048), 0644); err != nil {
+		t.Fatal(err)
+	}
+	refs := []BackupMountRef{{Type: "bind", Source: bindDir, Destination: "/data", Verification: "verified-local"}}
+	err := backupBindMountData(context.Background(), t.TempDir(), refs, bindCaptureOptions{MaxSize: 1024}, true)
+	if err == nil || !strings.Contains(err.Error(), "--bind-max-size") {
+		t.Fatalf("backupBindMountData() error = %v, want size cap error", err)
+	}
+	refs[0].Data = ""
+	if err := backupBindMountData(context.Background(), t.TempDir(), refs, bindCaptureOptions{Include: []string{"/nowhere/*"}}, true); err != nil {
+		t.Fatalf("backupBindMountData() include error = %v", err)
+	}
+	if refs[0].Data != "" || refs[0].DataSkipped != bindSkipFiltered {
+		t.Fatalf("ref = %#v, want filtered bind mount", refs[0])
+	}
+}
+
+func TestIncrementalBackupRejectsS3BundleOutput(t *testing.T) {
+	fake := &fakeBackupDockerService{}
+	restoreFactory := replaceBackupServiceFactory(fake)
+	defer restoreFactory()
+	base := t.TempDir()
+	_, err := backupContainers(context.Background(), []string{"web"}, BackupOptions{
+		Bundle:       true,
+		BundleOutput: "s3://backups/web.tar.gz",
+		Base:         base,
+		S3:           objectstore.Config{Endpoint: "http://127.0.0.1:9000", Region: "us-east-1", AccessKeyID: "key", SecretAccessKey: "secret"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "--base 不能与 s3://") {
+		t.Fatalf("backupContainers() error = %v, want --base with s3 output rejected", err)
+	}
+	if len(fake.calls) != 0 {
+		t.Fatalf("calls = %#v, want validation to fail before touching docker", fake.calls)
+	}
+}
+
+func TestIncrementalBackupReusesBaseBlobsAndRestoresChain(t *testing.T) {
+	layer := bytes.Repeat([]byte("layer"), backupBlobMinSize/4)
+	baseImage := testTarArchive(t, map[string][]byte{"layer.tar": layer, "manifest.json": []byte(`["v1"]`)})
+	nextImage := testTarArchive(t, map[string][]byte{"layer.tar": layer, "manifest.json": []byte(`["v2"]`)})
+	fake := &fakeBackupDockerService{
+		inspect: container.InspectResponse{
+			Name:       "/web",
+			Config:     &container.Config{Image: "nginx:latest"},
+			HostConfig: &container.HostConfig{},
+		},
+		imageArchive: baseImage,
+	}
+	restoreFactory := replaceBackupServiceFactory(fake)
+	defer restoreFactory()
+
+	root := t.TempDir()
+	baseDir := filepath.Join(root, "web-1")
+	if _, err := backupContainer(context.Background(), "web", BackupOptions{OutputDir: baseDir, IncludeImage: true, Bundle: true}); err != nil {
+		t.Fatalf("base backupContainer() error = %v", err)
+	}
+	fake.imageArchive = nextImage
+	nextDir := filepath.Join(root, "web-2")
+	if _, err := backupContainer(context.Background(), "web", BackupOptions{OutputDir: nextDir, IncludeImage: true, Bundle: true, Base: baseDir + ".tar.gz"}); err != nil {
+		t.Fatalf("incremental backupContainer() error = %v", err)
+	}
+
+	var manifest BackupManifest
+	readTestJSON(t, filepath.Join(nextDir, backupManifestName), &manifest)
+	if manifest.Parent == nil || manifest.Parent.Path != "web-1.tar.gz" || manifest.Parent.ReusedBlobs != 1 {
+		t.Fatalf("Parent = %#v, want relative base reference reusing one blob", manifest.Parent)
+	}
+	info, err := os.Stat(filepath.Join(nextDir, filepath.FromSlash(manifest.Containers[0].ImageArchive)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() >= int64(len(nextImage)) {
+		t.Fatalf("delta image size = %d, want smaller than %d", info.Size(), len(nextImage))
+	}
+
+	// Move the whole chain to check that the parent is found relative to the child.
+	moved := filepath.Join(t.TempDir(), "moved")
+	if err := os.Rename(root, moved); err != nil {
+		t.Fatal(err)
+	}
+	report, err := buildRestorePlanReport(context.Background(), filepath.Join(moved, "web-2.tar.gz"), RestoreOptions{})
+	if err != nil {
+		t.Fatalf("buildRestorePlanReport() error = %v", err)
+	}
+	if len(report.Chain) != 1 || report.Chain[0] != filepath.Join(moved, "web-1.tar.gz") {
+		t.Fatalf("Chain = %#v, want moved base bundle", report.Chain)
+	}
+	if err := restoreBackup(context.Background(), filepath.Join(moved, "web-2.tar.gz"), RestoreOptions{NoStart: true}); err != nil {
+		t.Fatalf("restoreBackup() error = %v", err)
+	}
+	if !bytes.Equal(fake.loadedImage, nextImage) {
+		t.Fatalf("loaded image differs from the original archive (%d bytes, want %d)", len(fake.loadedImage), len(nextImage))
+	}
+
+	if err := os.Remove(filepath.Join(moved, "web-1.tar.gz")); err != nil {
+		t.Fatal(err)
+	}
+	if err := restoreBackup(context.Background(), filepath.Join(moved, "web-2.tar.gz"), RestoreOptions{NoStart: true}); err == nil || !strings.Contains(err.Error(), "parent backup not found") {
+		t.Fatalf("restoreBackup() without base error = %v, want missing parent", err)
+	}
+}
+
+func TestBackupRepoStoresDedupedSnapshotsAndRestores(t *testing.T) {
+	image := make([]byte, repoChunkMaxSize)
+	rand.New(rand.NewSource(1)).Read(image)
+	fake := &fakeBackupDockerService{
+		inspect: container.InspectResponse{
+			Name:       "/web",
+			Config:     &container.Config{Image: "nginx:latest"},
+			HostConfig: &container.HostConfig{},
+		},
+		containers:   []container.Summary{{Names: []string{"/web"}}},
+		imageArchive: image,
+	}
+	restoreFactory := replaceBackupServiceFactory(fake)
+	defer restoreFactory()
+
+	repoPath := filepath.Join(t.TempDir(), "repo")
+	if _, err := backupContainers(context.Background(), []string{"web"}, BackupOptions{IncludeImage: true, Repo: repoPath}); err == nil || !strings.Contains(err.Error(), "dm backup repo init") {
+		t.Fatalf("backupContainers() uninitialized repo error = %v", err)
+	}
+	if err := initBackupRepo(repoPath); err != nil {
+		t.Fatal(err)
+	}
+	next := append(append([]byte(nil), image...), []byte("changed tail")...)
+	var sources []string
+	for i, archive := range [][]byte{image, next} {
+		fake.imageArchive = archive
+		result, err := backupContainers(context.Background(), []string{"web"}, BackupOptions{IncludeImage: true, Repo: repoPath})
+		if err != nil {
+			t.Fatalf("backupContainers() error = %v", err)
+		}
+		if len(result.Paths) != 1 || !strings.HasPrefix(result.Paths[0], "repo://") {
+			t.Fatalf("backup %d Paths = %#v, want repo snapshot source", i, result.Paths)
+		}
+		sources = append(sources, result.Paths[0])
+	}
+	list, err := listRepoSnapshots(repoPath, RepoListOptions{Container: "web"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Snapshots) != 2 {
+		t.Fatalf("Snapshots = %#v, want 2", list.Snapshots)
+	}
+	latest := list.Snapshots[0]
+	if latest.AddedBytes == 0 || latest.AddedBytes >= int64(len(image)) {
+		t.Fatalf("second snapshot added %d bytes, want only the changed chunks", latest.AddedBytes)
+	}
+
+	if err := restoreBackup(context.Background(), repoSourceFor(repoPath, "latest"), RestoreOptions{NoStart: true}); err != nil {
+		t.Fatalf("restoreBackup() error = %v", err)
+	}
+	if !bytes.Equal(fake.loadedImage, next) {
+		t.Fatalf("restored image differs (%d bytes, want %d)", len(fake.loadedImage), len(next))
+	}
+
+	report, err := pruneBackupRepo(context.Background(), repoPath, RepoPruneOptions{KeepLast: 1, Apply: true})
+	if err == nil || !strings.Contains(err.Error(), "--confirm") {
+		t.Fatalf("pruneBackupRepo() without confirm error = %v", err)
+	}
+	report, err = pruneBackupRepo(context.Background(), repoPath, RepoPruneOptions{KeepLast: 1, Apply: true, Confirm: true})
+	if err != nil {
+		t.Fatalf("pruneBackupRepo() error = %v", err)
+	}
+	if len(report.Remove) != 1 || report.Remove[0].Restore != sources[0] || report.GC.UnreferencedFiles == 0 {
+		t.Fatalf("report = %#v, want oldest snapshot and its unique chunks removed", report)
+	}
+	if err := restoreBackup(context.Background(), sources[1], RestoreOptions{NoStart: true}); err != nil {
+		t.Fatalf("restoreBackup() after prune error = %v", err)
+	}
+	gc, err := pruneBackupRepo(context.Background(), repoPath, RepoPruneOptions{GCOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gc.GC.UnreferencedFiles != 0 {
+		t.Fatalf("GC = %#v, want no garbage after prune", gc.GC)
+	}
+}
+
+func TestPlanRepoRetentionKeepsDailyAndWeekly(t *testing.T) {
+	base := time.Date(2026, 3, 16, 12, 0, 0, 0, time.Local) // Monday
+	var snapshots []RepoSnapshot
+	for i, offset := range []time.Duration{0, -time.Hour, -24 * time.Hour, -48 * time.Hour, -7 * 24 * time.Hour, -14 * 24 * time.Hour} {
+		snapshots = append(snapshots, RepoSnapshot{
+			ID:         fmt.Sprintf("s%d", i),
+			CreatedAt:  base.Add(offset).Format(time.RFC3339),
+			Containers: []string{"web"},
+		})
+	}
+	snapshots = append(snapshots, RepoSnapshot{ID: "db", CreatedAt: base.Format(time.RFC3339), Containers: []string{"db"}})
+
+	// Sunday s2 falls in the previous ISO week, so it is the weekly pick
+	// there instead of the older Monday s4.
+	reasons := planRepoRetention(snapshots, RepoRetentionPolicy{Container: "web", KeepDaily: 2, KeepWeekly: 3})
+	want := map[string]string{"s0": "daily,weekly", "s2": "daily,weekly", "s5": "weekly", "db": "not-selected"}
+	for id, reason := range want {
+		if got := strings.Join(reasons[id], ","); got != reason {
+			t.Fatalf("reasons[%s] = %q, want %q (all=%#v)", id, got, reason, reasons)
+		}
+	}
+	for _, id := range []string{"s1", "s3", "s4"} {
+		if len(reasons[id]) != 0 {
+			t.Fatalf("reasons[%s] = %#v, want removal", id, reasons[id])
+		}
+	}
+}
+
+func TestBackupContainersSkipsContainerWhosePreHookFails(t *testing.T) {
+	running := &container.State{Running: true}
+	fake := &fakeBackupDockerService{
+		containers: []container.Summary{{Names: []string{"/db"}}, {Names: []string{"/web"}}},
+		inspects: map[string]container.InspectResponse{
+			"db": {
+				Name:  "/db",
+				State: running,
+				Config: &container.Config{
+					Image:  "postgres:16",
+					Labels: map[string]string{backupHookLabelPre: "pg_dump_lock"},
+				},
+				HostConfig: &container.HostConfig{},
+				Mounts:     []container.MountPoint{{Type: mount.TypeVolume, Name: "db_data", Destination: "/data"}},
+			},
+			"web": {
+				Name:       "/web",
+				State:      running,
+				Config:     &container.Config{Image: "nginx:latest"},
+				HostConfig: &container.HostConfig{},
+				Mounts:     []container.MountPoint{{Type: mount.TypeVolume, Name: "web_data", Destination: "/data"}},
+			},
+		},
+		volume:        volume.Volume{Name: "data", Driver: "local"},
+		execExitCodes: map[string]int{"pg_dump_lock": 3},
+	}
+	restoreFactory := replaceBackupServiceFactory(fake)
+	defer restoreFactory()
+
+	root := t.TempDir()
+	result, err := backupContainers(context.Background(), []string{"db", "web"}, BackupOptions{
+		OutputDir:         root,
+		IncludeVolumeData: true,
+		Consistency:       backupConsistencyPause,
+		PostHooks:         []string{"echo done"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "db") {
+		t.Fatalf("backupContainers() error = %v, want db pre-hook failure", err)
+	}
+	if len(result.Paths) != 1 || len(result.Failed) != 1 || result.Failed[0].Container != "db" {
+		t.Fatalf("result = %#v, want web backed up and db skipped", result)
+	}
+	if hasCall(fake.calls, "pause-container:db") || hasCall(fake.calls, "export-volume:db_data@postgres:16") {
+		t.Fatalf("calls = %#v, db must not be paused or captured after its pre-hook failed", fake.calls)
+	}
+	if _, err := os.Stat(filepath.Join(root, "db")); !os.IsNotExist(err) {
+		t.Fatalf("db backup dir stat error = %v, want no partial backup", err)
+	}
+	pause := callIndex(fake.calls, "pause-container:web")
+	export := callIndex(fake.calls, "export-volume:web_data@nginx:latest")
+	unpause := callIndex(fake.calls, "unpause-container:web")
+	post := callIndex(fake.calls, "exec:web:sh -c echo done")
+	if pause < 0 || !(pause < export && export < unpause && unpause < post) {
+		t.Fatalf("calls = %#v, want pause, export, unpause, post-hook in order", fake.calls)
+	}
+
+	var manifest BackupManifest
+	readTestJSON(t, filepath.Join(root, "web", backupManifestName), &manifest)
+	entry := manifest.Containers[0]
+	if entry.Consistency == nil || entry.Consistency.Action != "paused" {
+		t.Fatalf("Consistency = %#v, want paused", entry.Consistency)
+	}
+	if len(entry.Hooks) != 1 || entry.Hooks[0].Phase != backupHookPhasePost || entry.Hooks[0].Status != "ok" || entry.Hooks[0].Output != "hook output" {
+		t.Fatalf("Hooks = %#v, want recorded post-hook", entry.Hooks)
+	}
+}
+
+func callIndex(calls []string, want string) int {
+	for i, call := range calls {
+		if call == want {
+			return i
+		}
+	}
+	return -1
+}
+
+func testTarArchive(t *testing.T, files map[string][]byte) []byte {
+	t.Helper()
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, name := range names {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(files[name])), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(files[name]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
 func TestSafeExtractPathRejectsTraversal(t *testing.T) {
 	if _, err := safeExtractPath(t.TempDir(), "../evil"); err == nil {
 		t.Fatal("safeExtractPath() error = nil, want traversal error")
@@ -0,0 +1,558 @@
+package backup
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"docker-manager/internal/textfmt"
+
+	"github.com/moby/moby/api/types/container"
+	"github.com/moby/moby/api/types/mount"
+)
+
+const (
+	backupBindDataDir   = "binds"
+	backupBindKindDir   = "dir"
+	backupBindKindFile  = "file"
+	bindSkipFiltered    = "filtered"
+	bindSkipUnreadable  = "unreadable"
+	bindSkipUnsupported = "unsupported-type"
+)
+
+type bindCaptureOptions struct {
+	Include []string
+	Exclude []string
+	MaxSize int64
+}
+
+func bindCaptureOptionsFromBackup(opts BackupOptions) (bindCaptureOptions, error) {
+	if !opts.IncludeBindMounts {
+		if len(opts.BindInclude) > 0 || len(opts.BindExclude) > 0 || opts.BindMaxSize != "" {
+			return bindCaptureOptions{}, fmt.Errorf("--bind-include、--bind-exclude 和 --bind-max-size 仅在 --include-bind-mounts 时可用")
+		}
+		return bindCaptureOptions{}, nil
+	}
+	maxSize, err := parseBackupSize(opts.BindMaxSize)
+	if err != nil {
+		return bindCaptureOptions{}, fmt.Errorf("--bind-max-size: %w", err)
+	}
+	for _, pattern := range append(append([]string(nil), opts.BindInclude...), opts.BindExclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return bindCaptureOptions{}, fmt.Errorf("invalid bind glob %q: %w", pattern, err)
+		}
+	}
+	return bindCaptureOptions{Include: opts.BindInclude, Exclude: opts.BindExclude, MaxSize: maxSize}, nil
+}
+
+// backupBindMountData archives selected bind sources into binds/. All sizes
+// are measured before anything is written so the size cap fails fast.
+func backupBindMountData(ctx context.Context, outputDir string, refs []BackupMountRef, opts bindCaptureOptions, dryRun bool) error {
+	var total int64
+	for i := range refs {
+		if err := checkBackupContext(ctx); err != nil {
+			return err
+		}
+		ref := &refs[i]
+		if ref.Type != "bind" {
+			continue
+		}
+		if !bindSourceSelected(ref.Source, opts) {
+			ref.DataSkipped = bindSkipFiltered
+			continue
+		}
+		if ref.Verification != "verified-local" {
+			ref.DataSkipped = ref.Verification
+			continue
+		}
+		if ref.HostPathReadable != nil && !*ref.HostPathReadable {
+			ref.DataSkipped = bindSkipUnreadable
+			continue
+		}
+		source, err := filepath.EvalSymlinks(ref.Source)
+		if err != nil {
+			return fmt.Errorf("resolve bind source %s: %w", ref.Source, err)
+		}
+		info, err := os.Stat(source)
+		if err != nil {
+			return fmt.Errorf("stat bind source %s: %w", ref.Source, err)
+		}
+		kind := backupBindKindFile
+		if info.IsDir() {
+			kind = backupBindKindDir
+		} else if !info.Mode().IsRegular() {
+			ref.DataSkipped = bindSkipUnsupported
+			continue
+		}
+		size, err := bindSourceSize(ctx, source, opts.Exclude)
+		if err != nil {
+			return fmt.Errorf("measure bind source %s: %w", ref.Source, err)
+		}
+		total += size
+		if opts.MaxSize > 0 && total > opts.MaxSize {
+			return fmt.Errorf("bind mount data exceeds --bind-max-size %s at %s (total %s); narrow it with --bind-include/--bind-exclude", textfmt.SignedBytes(opts.MaxSize), ref.Source, textfmt.SignedBytes(total))
+		}
+		ref.Data = backupBindDataFile(ref.Destination)
+		ref.DataKind = kind
+		ref.DataSize = size
+	}
+	if dryRun {
+		return nil
+	}
+	for _, ref := range refs {
+		if ref.Data == "" {
+			continue
+		}
+		source, err := filepath.EvalSymlinks(ref.Source)
+		if err != nil {
+			return fmt.Errorf("resolve bind source %s: %w", ref.Source, err)
+		}
+		if err := writeBindArchive(ctx, source, ref.DataKind, opts.Exclude, filepath.Join(outputDir, filepath.FromSlash(ref.Data))); err != nil {
+			return fmt.Errorf("archive bind source %s: %w", ref.Source, err)
+		}
+	}
+	return nil
+}
+
+func backupBindDataFile(destination string) string {
+	name := strings.Trim(filepath.ToSlash(destination), "/")
+	return path.Join(backupBindDataDir, safeBackupName(name)+".tar")
+}
+
+// bindSourceSelected applies --bind-include/--bind-exclude to the host source.
+// A pattern without a slash matches the base name, otherwise the full path.
+func bindSourceSelected(source string, opts bindCaptureOptions) bool {
+	source = filepath.ToSlash(source)
+	if len(opts.Include) > 0 && !bindGlobMatchAny(opts.Include, source) {
+		return false
+	}
+	return !bindGlobMatchAny(opts.Exclude, source)
+}
+
+func bindGlobMatchAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if bindGlobMatch(pattern, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func bindGlobMatch(pattern, value string) bool {
+	pattern = filepath.ToSlash(strings.TrimSpace(pattern))
+	if pattern == "" {
+		return false
+	}
+	target := value
+	if !strings.Contains(pattern, "/") {
+		target = path.Base(value)
+	}
+	ok, err := path.Match(pattern, target)
+	return err == nil && ok
+}
+
+func walkBindSource(ctx context.Context, root string, exclude []string, fn func(p, rel string, entry fs.DirEntry) error) error {
+	return filepath.WalkDir(root, func(p string, entry fs.DirEntry, walkErr error) error {
+		if err := checkBackupContext(ctx); err != nil {
+			return err
+		}
+		if walkErr != nil {
+			return walkErr
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel != "." && bindGlobMatchAny(exclude, rel) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		return fn(p, rel, entry)
+	})
+}
+
+func bindSourceSize(ctx context.Context, root string, exclude []string) (int64, error) {
+	var total int64
+	err := walkBindSource(ctx, root, exclude, func(p, rel string, entry fs.DirEntry) error {
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		total += info.Size()
+		return nil
+	})
+	return total, err
+}
+
+func writeBindArchive(ctx context.Context, source, kind string, exclude []string, archivePath string) error {
+	if err := os.MkdirAll(filepath.Dir(archivePath), 0755); err != nil {
+		return err
+	}
+	file, err := os.Create(archivePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	tw := tar.NewWriter(file)
+	err = walkBindSource(ctx, source, exclude, func(p, rel string, entry fs.DirEntry) error {
+		name := rel
+		if kind == backupBindKindFile {
+			name = filepath.Base(p)
+		} else if rel == "." {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		link := ""
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err = os.Readlink(p)
+			if err != nil {
+				return err
+			}
+		case !info.Mode().IsRegular() && !info.IsDir():
+			// Sockets, pipes and device nodes cannot be recreated portably.
+			return nil
+		}
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		header.Name = name
+		if info.IsDir() {
+			header.Name += "/"
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		in, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		return backupCopyWithContext(ctx, tw, in)
+	})
+	if err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// restoreBindArchive writes symlinks last so no archive entry can be written
+// through a link created by the same archive.
+func restoreBindArchive(ctx context.Context, archivePath, target, kind string) error {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if kind == backupBindKindFile {
+		err = os.MkdirAll(filepath.Dir(target), 0755)
+	} else {
+		err = os.MkdirAll(target, 0755)
+	}
+	if err != nil {
+		return err
+	}
+	root := target
+	if kind == backupBindKindFile {
+		root = filepath.Dir(target)
+	}
+	root, err = filepath.EvalSymlinks(root)
+	if err != nil {
+		return err
+	}
+	var links, dirs []*tar.Header
+	tr := tar.NewReader(file)
+	for {
+		if err := checkBackupContext(ctx); err != nil {
+			return err
+		}
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		dest := target
+		if kind != backupBindKindFile {
+			dest, err = safeExtractPath(target, header.Name)
+			if err != nil {
+				return err
+			}
+		}
+		mode := os.FileMode(header.Mode).Perm()
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := checkBindRestorePath(root, dest); err != nil {
+				return err
+			}
+			if err := os.MkdirAll(dest, 0755); err != nil {
+				return err
+			}
+			// 目录权限放到最后设置，只读目录 (如 0555) 提前生效会导致子项无法写入
+			if err := os.Chmod(dest, mode|0700); err != nil {
+				return err
+			}
+			copied := *header
+			copied.Name = dest
+			dirs = append(dirs, &copied)
+		case tar.TypeReg, tar.TypeRegA:
+			if err := checkBindRestorePath(root, filepath.Dir(dest)); err != nil {
+				return err
+			}
+			if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+				return err
+			}
+			// 目标位置已有的符号链接先删除，避免写穿到链接指向的文件
+			if info, err := os.Lstat(dest); err == nil && info.Mode()&os.ModeSymlink != 0 {
+				if err := os.Remove(dest); err != nil {
+					return err
+				}
+			}
+			out, err := os.OpenFile(dest, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
+			if err != nil {
+				return err
+			}
+			if err := backupCopyWithContext(ctx, out, tr); err != nil {
+				_ = out.Close()
+				return err
+			}
+			if err := out.Close(); err != nil {
+				return err
+			}
+			if err := os.Chmod(dest, mode); err != nil {
+				return err
+			}
+			_ = os.Chtimes(dest, header.ModTime, header.ModTime)
+		case tar.TypeSymlink:
+			copied := *header
+			copied.Name = dest
+			links = append(links, &copied)
+			continue
+		default:
+			continue
+		}
+		// Ownership is best effort: it only succeeds when restore runs as root.
+		_ = os.Lchown(dest, header.Uid, header.Gid)
+	}
+	for _, link := range links {
+		if err := checkBindRestorePath(root, filepath.Dir(link.Name)); err != nil {
+			return err
+		}
+		if info, err := os.Lstat(link.Name); err == nil {
+			if info.IsDir() {
+				return fmt.Errorf("cannot replace directory %s with symlink", link.Name)
+			}
+			if err := os.Remove(link.Name); err != nil {
+				return err
+			}
+		}
+		if err := os.MkdirAll(filepath.Dir(link.Name), 0755); err != nil {
+			return err
+		}
+		if err := os.Symlink(link.Linkname, link.Name); err != nil {
+			return err
+		}
+		_ = os.Lchown(link.Name, link.Uid, link.Gid)
+	}
+	// 逆序处理，子目录先于父目录收紧权限
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := os.Chmod(dirs[i].Name, os.FileMode(dirs[i].Mode).Perm()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkBindRestorePath resolves the deepest existing ancestor of path and
+// rejects it when a symlink already present in the target points outside
+// root, so MkdirAll and file writes cannot escape the restore root.
+func checkBindRestorePath(root, path string) error {
+	existing := path
+	for {
+		if _, err := os.Lstat(existing); err == nil {
+			break
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			break
+		}
+		existing = parent
+	}
+	resolved, err := filepath.EvalSymlinks(existing)
+	if err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(root, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("bind 路径 %s 经符号链接指向恢复目录之外: %s", path, resolved)
+	}
+	return nil
+}
+
+// restoreBindTarget maps a backed-up host path under bindRoot. A filesystem
+// root such as "/" keeps the original path.
+func restoreBindTarget(bindRoot, source string) string {
+	root := filepath.Clean(bindRoot)
+	if isFilesystemRoot(root) {
+		return filepath.Clean(filepath.FromSlash(source))
+	}
+	rel := source[len(filepath.VolumeName(source)):]
+	rel = strings.TrimLeft(rel, `/\`)
+	return filepath.Join(root, filepath.FromSlash(rel))
+}
+
+func isFilesystemRoot(path string) bool {
+	return filepath.Dir(path) == path
+}
+
+func bindTargetHasContent(target string) (bool, error) {
+	info, err := os.Lstat(target)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	if !info.IsDir() {
+		return true, nil
+	}
+	entries, err := os.ReadDir(target)
+	if err != nil {
+		return false, err
+	}
+	return len(entries) > 0, nil
+}
+
+// restoreBindPlans marks non-empty targets "keep" unless overwrite is set;
+// existing host data is only replaced with --overwrite-bind-data.
+func restoreBindPlans(entryDir string, refs []BackupMountRef, bindRoot string, overwrite bool) []RestoreBindPlan {
+	var plans []RestoreBindPlan
+	for _, ref := range refs {
+		if ref.Type != "bind" || ref.Data == "" {
+			continue
+		}
+		plan := RestoreBindPlan{
+			Source:      ref.Source,
+			Destination: ref.Destination,
+			Data:        ref.Data,
+			Kind:        ref.DataKind,
+			Size:        ref.DataSize,
+			Action:      "skip",
+		}
+		if err := checkRestoreDataFile(entryDir, ref.Data); err != nil {
+			plan.Action = "error"
+			plan.Error = err.Error()
+			plans = append(plans, plan)
+			continue
+		}
+		if bindRoot == "" {
+			plans = append(plans, plan)
+			continue
+		}
+		plan.Target = restoreBindTarget(bindRoot, ref.Source)
+		exists, err := bindTargetHasContent(plan.Target)
+		if err != nil {
+			plan.Action = "error"
+			plan.Error = err.Error()
+			plans = append(plans, plan)
+			continue
+		}
+		plan.Exists = exists
+		plan.Action = "write"
+		if exists && overwrite {
+			plan.Action = "overwrite"
+		} else if exists {
+			plan.Action = "keep"
+		}
+		plans = append(plans, plan)
+	}
+	return plans
+}
+
+func restoreBindMountData(ctx context.Context, w io.Writer, entryDir string, refs []BackupMountRef, inspect container.InspectResponse, bindRoot string, overwrite bool) (container.InspectResponse, error) {
+	mapping := map[string]string{}
+	for _, plan := range restoreBindPlans(entryDir, refs, bindRoot, overwrite) {
+		if err := checkBackupContext(ctx); err != nil {
+			return inspect, err
+		}
+		if plan.Error != "" {
+			return inspect, fmt.Errorf("restore bind data %s: %s", plan.Source, plan.Error)
+		}
+		if plan.Target != plan.Source {
+			mapping[plan.Source] = plan.Target
+		}
+		if plan.Action == "keep" {
+			fmt.Fprintf(w, "跳过已存在且非空的 bind 路径 %s；使用 --overwrite-bind-data 覆盖\n", plan.Target)
+			continue
+		}
+		if plan.Action == "overwrite" {
+			fmt.Fprintf(w, "警告: 将覆盖已存在的 bind 路径 %s\n", plan.Target)
+		}
+		dataPath, err := backupFilePath(entryDir, plan.Data)
+		if err != nil {
+			return inspect, err
+		}
+		if err := restoreBindArchive(ctx, dataPath, plan.Target, plan.Kind); err != nil {
+			return inspect, fmt.Errorf("restore bind data %s -> %s: %w", plan.Source, plan.Target, err)
+		}
+		fmt.Fprintf(w, "已恢复 bind 数据: %s -> %s\n", plan.Source, plan.Target)
+	}
+	return remapRestoreBindSources(inspect, mapping), nil
+}
+
+// remapRestoreBindSources points the recreated container at remapped bind
+// paths; the HostConfig is copied so the caller's inspect stays untouched.
+func remapRestoreBindSources(inspect container.InspectResponse, mapping map[string]string) container.InspectResponse {
+	if inspect.HostConfig == nil || len(mapping) == 0 {
+		return inspect
+	}
+	hostConfig := *inspect.HostConfig
+	if len(hostConfig.Binds) > 0 {
+		binds := make([]string, len(hostConfig.Binds))
+		for i, bind := range hostConfig.Binds {
+			source, rest, ok := strings.Cut(bind, ":")
+			if target, found := mapping[source]; ok && found {
+				bind = target + ":" + rest
+			}
+			binds[i] = bind
+		}
+		hostConfig.Binds = binds
+	}
+	if len(hostConfig.Mounts) > 0 {
+		mounts := make([]mount.Mount, len(hostConfig.Mounts))
+		for i, m := range hostConfig.Mounts {
+			if target, found := mapping[m.Source]; m.Type == mount.TypeBind && found {
+				m.Source = target
+			}
+			mounts[i] = m
+		}
+		hostConfig.Mounts = mounts
+	}
+	inspect.HostConfig = &hostConfig
+	return inspect
+}
@@ -0,0 +1,511 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"docker-manager/internal/textfmt"
+)
+
+// Artifact kinds accepted by dm backup extract --only, in display order.
+var backupArtifactKinds = []string{"inspect", "compose", "image", "networks", "volumes", "binds"}
+
+// backupBundleContents is what dm backup ls needs from a bundle: the size of
+// every file relative to the backup root plus the manifest and blob index.
+type backupBundleContents struct {
+	Files    map[string]int64
+	Manifest []byte
+	Blobs    []byte
+}
+
+// listBackupBundle reads a bundle without Docker. Archives are streamed
+// (joined and decrypted on the fly) and only the manifest and blob index
+// are kept in memory, so listing a large bundle does not extract it.
+func listBackupBundle(ctx context.Context, source string, opts BackupListOptions) (BackupListing, error) {
+	ctx = backupContext(ctx)
+	if err := checkBackupContext(ctx); err != nil {
+		return BackupListing{}, err
+	}
+	listing := BackupListing{Source: source, Kind: backupVerifyKind(source)}
+	var (
+		contents backupBundleContents
+		err      error
+	)
+	switch {
+	case isRepoSource(source):
+		listing.Kind = "repo"
+		dir, cleanup, resolveErr := resolveRestoreBackupDirWithOptions(ctx, source, RestoreOptions{})
+		if resolveErr != nil {
+			return listing, resolveErr
+		}
+		if cleanup != nil {
+			defer cleanup()
+		}
+		contents, err = scanBackupBundleDir(ctx, dir)
+	case listing.Kind == "directory":
+		contents, err = scanBackupBundleDir(ctx, source)
+	default:
+		if strings.HasSuffix(listing.Kind, "encrypted") {
+			if listing.Encryption, err = describeBackupEncryptionFile(source); err != nil {
+				return listing, fmt.Errorf("encryption header: %w", err)
+			}
+		}
+		contents, err = scanBackupBundleArchive(ctx, source, opts.PassphraseFile, opts.IdentityFiles)
+	}
+	if err != nil {
+		return listing, err
+	}
+	if contents.Manifest == nil {
+		return listing, fmt.Errorf("bundle does not contain %s", backupManifestName)
+	}
+	manifest, err := parseBackupManifest(contents.Manifest)
+	if err != nil {
+		return listing, err
+	}
+	var blobs backupBlobIndex
+	if contents.Blobs != nil {
+		if err := json.Unmarshal(contents.Blobs, &blobs); err != nil {
+			return listing, fmt.Errorf("parse %s: %w", backupBlobIndexName, err)
+		}
+	}
+	listing.CreatedAt = manifest.CreatedAt
+	listing.ToolVersion = manifest.Tool.Version
+	listing.SourcePlatform = manifest.SourcePlatform
+	if manifest.Parent != nil {
+		listing.Parent = manifest.Parent.Path
+	}
+	_, listing.Signed = contents.Files[backupSignatureName]
+	listing.Files = len(contents.Files)
+	for _, size := range contents.Files {
+		listing.Size += size
+	}
+	for _, entry := range manifest.Containers {
+		item := BackupListContainer{Name: entry.ContainerName, Path: entry.Path, Image: entry.Image}
+		for _, artifact := range backupEntryArtifacts(entry) {
+			size, ok := contents.Files[artifact.File]
+			artifact.Size = size
+			artifact.Missing = !ok
+			artifact.Delta = blobs.Artifacts[artifact.File].Delta
+			item.Size += size
+			item.Artifacts = append(item.Artifacts, artifact)
+		}
+		for _, ref := range entry.Networks {
+			item.Networks = append(item.Networks, ref.Name)
+		}
+		for _, ref := range entry.Volumes {
+			item.Volumes = append(item.Volumes, ref.Name)
+		}
+		listing.Containers = append(listing.Containers, item)
+	}
+	return listing, nil
+}
+
+// backupEntryArtifacts lists the files of a container entry with paths
+// relative to the backup root.
+func backupEntryArtifacts(entry BackupContainerManifest) []BackupListArtifact {
+	var artifacts []BackupListArtifact
+	add := func(kind, name, rel string) {
+		if rel == "" {
+			return
+		}
+		if entry.Path != "" {
+			rel = path.Join(entry.Path, rel)
+		}
+		artifacts = append(artifacts, BackupListArtifact{Kind: kind, Name: name, File: rel})
+	}
+	inspectFile := entry.InspectFile
+	if inspectFile == "" {
+		inspectFile = backupInspectName
+	}
+	add("inspect", "", inspectFile)
+	add("compose", "", entry.ComposeFile)
+	add("image", entry.Image, entry.ImageArchive)
+	for _, ref := range entry.Networks {
+		add("networks", ref.Name, ref.File)
+	}
+	for _, ref := range entry.Volumes {
+		add("volumes", ref.Name, ref.File)
+		add("volumes", ref.Name, ref.Data)
+	}
+	for _, ref := range entry.Mounts {
+		add("binds", ref.Source, ref.Data)
+	}
+	return artifacts
+}
+
+func scanBackupBundleDir(ctx context.Context, dir string) (backupBundleContents, error) {
+	contents := backupBundleContents{Files: map[string]int64{}}
+	err := filepath.WalkDir(dir, func(file string, entry os.DirEntry, walkErr error) error {
+		if walkErr != nil || entry.IsDir() {
+			return walkErr
+		}
+		if err := checkBackupContext(ctx); err != nil {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		contents.Files[filepath.ToSlash(rel)] = info.Size()
+		return nil
+	})
+	if err != nil {
+		return contents, err
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, backupManifestName)); err == nil {
+		contents.Manifest = data
+	} else if !os.IsNotExist(err) {
+		return contents, err
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, backupBlobIndexName)); err == nil {
+		contents.Blobs = data
+	} else if !os.IsNotExist(err) {
+		return contents, err
+	}
+	return contents, nil
+}
+
+// scanBackupBundleArchive walks the tar headers of an archive. Like
+// findExtractedBackupRoot it accepts the backup root at the top of the
+// archive or one directory below it.
+func scanBackupBundleArchive(ctx context.Context, source, passphraseFile string, identityFiles []string) (backupBundleContents, error) {
+	stream, err := openBackupArchiveStream(ctx, source, passphraseFile, identityFiles)
+	if err != nil {
+		return backupBundleContents{}, err
+	}
+	defer stream.Close()
+	files := map[string]int64{}
+	small := map[string][]byte{}
+	tr := tar.NewReader(stream)
+	for {
+		if err := checkBackupContext(ctx); err != nil {
+			return backupBundleContents{}, err
+		}
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return backupBundleContents{}, err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := path.Clean(strings.TrimPrefix(header.Name, "./"))
+		files[name] = header.Size
+		if base := path.Base(name); base == backupManifestName || base == backupBlobIndexName {
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				return backupBundleContents{}, err
+			}
+			small[name] = data
+		}
+	}
+	root := ""
+	if _, ok := small[backupManifestName]; !ok {
+		var candidates []string
+		for name := range small {
+			if path.Base(name) == backupManifestName && strings.Count(name, "/") == 1 {
+				candidates = append(candidates, path.Dir(name))
+			}
+		}
+		if len(candidates) == 0 {
+			return backupBundleContents{}, fmt.Errorf("archive does not contain %s", backupManifestName)
+		}
+		sort.Strings(candidates)
+		root = candidates[0] + "/"
+	}
+	contents := backupBundleContents{Files: map[string]int64{}, Manifest: small[root+backupManifestName], Blobs: small[root+backupBlobIndexName]}
+	for name, size := range files {
+		if strings.HasPrefix(name, root) {
+			contents.Files[strings.TrimPrefix(name, root)] = size
+		}
+	}
+	return contents, nil
+}
+
+// backupArchiveStream is the plain tar stream of a (split, encrypted)
+// archive. Closing it stops the decrypt goroutine and closes the parts.
+type backupArchiveStream struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (s *backupArchiveStream) Close() error {
+	var first error
+	for i := len(s.closers) - 1; i >= 0; i-- {
+		if err := s.closers[i].Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+func openBackupArchiveStream(ctx context.Context, source, passphraseFile string, identityFiles []string) (*backupArchiveStream, error) {
+	name := source
+	paths := []string{source}
+	if isBackupArchivePart(source) {
+		name = strings.TrimSuffix(source, backupArchiveSuffixPart)
+		paths = nil
+		for i := 1; ; i++ {
+			part := splitPartPath(name, i)
+			if _, err := os.Stat(part); err != nil {
+				if i == 1 || !os.IsNotExist(err) {
+					return nil, err
+				}
+				break
+			}
+			paths = append(paths, part)
+		}
+	}
+	stream := &backupArchiveStream{}
+	readers := make([]io.Reader, 0, len(paths))
+	for _, part := range paths {
+		file, err := os.Open(part)
+		if err != nil {
+			_ = stream.Close()
+			return nil, err
+		}
+		stream.closers = append(stream.closers, file)
+		readers = append(readers, file)
+	}
+	var src io.Reader = io.MultiReader(readers...)
+	if isEncryptedBackupArchive(name) {
+		keys, err := loadBackupDecryptionKeys(passphraseFile, identityFiles)
+		if err != nil {
+			_ = stream.Close()
+			return nil, err
+		}
+		pr, pw := io.Pipe()
+		go func(encrypted io.Reader) {
+			pw.CloseWithError(decryptBackupArchiveStream(ctx, encrypted, pw, keys))
+		}(src)
+		stream.closers = append(stream.closers, pr)
+		src = pr
+	}
+	gz, err := gzip.NewReader(src)
+	if err != nil {
+		_ = stream.Close()
+		return nil, err
+	}
+	stream.closers = append(stream.closers, gz)
+	stream.Reader = gz
+	return stream, nil
+}
+
+// extractBackupBundle copies selected artifacts of selected containers to
+// <output-dir>/<container>/, keeping their paths inside the container entry.
+// Incremental backups are rebuilt from their base chain first, so extracted
+// archives are always complete.
+func extractBackupBundle(ctx context.Context, source string, opts BackupExtractOptions) (BackupExtractResult, error) {
+	ctx = backupContext(ctx)
+	kinds, err := parseBackupArtifactKinds(opts.Only)
+	if err != nil {
+		return BackupExtractResult{}, err
+	}
+	outputDir := opts.OutputDir
+	if strings.TrimSpace(outputDir) == "" {
+		outputDir = "."
+	}
+	result := BackupExtractResult{Source: source, OutputDir: outputDir}
+	restoreOpts := RestoreOptions{PassphraseFile: opts.PassphraseFile, IdentityFiles: opts.IdentityFiles}
+	dir, cleanup, err := resolveRestoreBackupDirWithOptions(ctx, source, restoreOpts)
+	if err != nil {
+		return result, err
+	}
+	if cleanup != nil {
+		defer cleanup()
+	}
+	manifest, err := readBackupManifest(dir)
+	if err != nil {
+		return result, err
+	}
+	entries, err := selectBackupExtractEntries(manifest, opts.Containers)
+	if err != nil {
+		return result, err
+	}
+	if manifest.Parent != nil {
+		materialized, _, chainCleanup, err := resolveRestoreBackupChain(ctx, dir, source, restoreOpts)
+		if err != nil {
+			return result, err
+		}
+		if chainCleanup != nil {
+			defer chainCleanup()
+		}
+		dir = materialized
+	}
+	for _, entry := range entries {
+		for _, artifact := range backupEntryArtifacts(entry) {
+			if !kinds[artifact.Kind] {
+				continue
+			}
+			if err := checkBackupContext(ctx); err != nil {
+				return result, err
+			}
+			src, err := backupFilePath(dir, artifact.File)
+			if err != nil {
+				return result, err
+			}
+			rel := artifact.File
+			if entry.Path != "" {
+				rel = strings.TrimPrefix(rel, entry.Path+"/")
+			}
+			dst, err := safeExtractPath(filepath.Join(outputDir, normalizeContainerName(entry.ContainerName)), rel)
+			if err != nil {
+				return result, err
+			}
+			size, err := copyBackupExtractFile(ctx, src, dst, opts.Force)
+			if err != nil {
+				return result, fmt.Errorf("extract %s: %w", artifact.File, err)
+			}
+			result.Files = append(result.Files, BackupExtractFile{Container: entry.ContainerName, Kind: artifact.Kind, Path: dst, Size: size})
+		}
+	}
+	log.Printf("Backup extract: source=%s containers=%d files=%d output=%s", source, len(entries), len(result.Files), outputDir)
+	return result, nil
+}
+
+func parseBackupArtifactKinds(values []string) (map[string]bool, error) {
+	kinds := map[string]bool{}
+	for _, value := range values {
+		for _, kind := range strings.Split(value, ",") {
+			kind = strings.ToLower(strings.TrimSpace(kind))
+			if kind == "" {
+				continue
+			}
+			known := false
+			for _, candidate := range backupArtifactKinds {
+				known = known || candidate == kind
+			}
+			if !known {
+				return nil, fmt.Errorf("unknown --only value %q (supported: %s)", kind, strings.Join(backupArtifactKinds, ","))
+			}
+			kinds[kind] = true
+		}
+	}
+	if len(kinds) == 0 {
+		for _, kind := range backupArtifactKinds {
+			kinds[kind] = true
+		}
+	}
+	return kinds, nil
+}
+
+func selectBackupExtractEntries(manifest BackupManifest, names []string) ([]BackupContainerManifest, error) {
+	if len(names) == 0 {
+		return manifest.Containers, nil
+	}
+	var selected []BackupContainerManifest
+	var available []string
+	for _, entry := range manifest.Containers {
+		available = append(available, entry.ContainerName)
+	}
+	for _, name := range names {
+		name = normalizeContainerName(name)
+		found := false
+		for _, entry := range manifest.Containers {
+			if normalizeContainerName(entry.ContainerName) == name || normalizeContainerName(entry.SourceName) == name {
+				selected = append(selected, entry)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("container %q not found in backup (available: %s)", name, strings.Join(available, ", "))
+		}
+	}
+	return selected, nil
+}
+
+// copyBackupExtractFile copies instead of linking: the source may be a
+// backup directory that must not change when the extracted copy is edited.
+func copyBackupExtractFile(ctx context.Context, src, dst string, force bool) (int64, error) {
+	if _, err := os.Lstat(dst); err == nil && !force {
+		return 0, fmt.Errorf("%s 已存在；使用 --force 覆盖", dst)
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return 0, err
+	}
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, info.Mode().Perm())
+	if err != nil {
+		return 0, err
+	}
+	if err := backupCopyWithContext(ctx, out, in); err != nil {
+		_ = out.Close()
+		return 0, err
+	}
+	return info.Size(), out.Close()
+}
+
+func printBackupListing(w io.Writer, listing BackupListing) {
+	fmt.Fprintf(w, "备份包: %s\n", listing.Source)
+	fmt.Fprintf(w, "类型: %s", listing.Kind)
+	if listing.Encryption != "" {
+		fmt.Fprintf(w, " 加密=%s", listing.Encryption)
+	}
+	fmt.Fprintf(w, " 签名=%v\n", listing.Signed)
+	fmt.Fprintf(w, "创建时间: %s dm=%s 源平台=%s\n", valueOrUnknown(listing.CreatedAt), valueOrUnknown(listing.ToolVersion), valueOrUnknown(listing.SourcePlatform))
+	if listing.Parent != "" {
+		fmt.Fprintf(w, "增量基线: %s（delta 文件为引用大小）\n", listing.Parent)
+	}
+	fmt.Fprintf(w, "文件: %d size=%s\n", listing.Files, textfmt.SignedBytes(listing.Size))
+	for _, item := range listing.Containers {
+		fmt.Fprintf(w, "容器: %s image=%s size=%s", item.Name, valueOrUnknown(item.Image), textfmt.SignedBytes(item.Size))
+		if item.Path != "" {
+			fmt.Fprintf(w, " path=%s", item.Path)
+		}
+		fmt.Fprintln(w)
+		if len(item.Networks) > 0 {
+			fmt.Fprintf(w, "  networks: %s\n", strings.Join(item.Networks, ", "))
+		}
+		if len(item.Volumes) > 0 {
+			fmt.Fprintf(w, "  volumes: %s\n", strings.Join(item.Volumes, ", "))
+		}
+		for _, artifact := range item.Artifacts {
+			fmt.Fprintf(w, "  - %-8s %s size=%s", artifact.Kind, artifact.File, textfmt.SignedBytes(artifact.Size))
+			if artifact.Name != "" && artifact.Kind != "networks" && artifact.Kind != "volumes" {
+				fmt.Fprintf(w, " (%s)", artifact.Name)
+			}
+			if artifact.Delta {
+				fmt.Fprint(w, " delta")
+			}
+			if artifact.Missing {
+				fmt.Fprint(w, " 缺失")
+			}
+			fmt.Fprintln(w)
+		}
+	}
+}
+
+func printBackupExtractResult(w io.Writer, result BackupExtractResult) {
+	if len(result.Files) == 0 {
+		fmt.Fprintln(w, "没有匹配的文件")
+		return
+	}
+	for _, file := range result.Files {
+		fmt.Fprintf(w, "已提取: %s [%s/%s] size=%s\n", file.Path, file.Container, file.Kind, textfmt.SignedBytes(file.Size))
+	}
+}
@@ -113,6 +113,28 @@ func verifyBackupChecksumsWithContext(ctx context.Context, root string) (bool, e
 	return true, nil
 }
 
+// readBackupChecksumList returns the sha256 of every file listed in
+// checksums.txt keyed by its slash-separated relative path.
+func readBackupChecksumList(root string) (map[string]string, error) {
+	data, err := os.ReadFile(filepath.Join(root, backupChecksumName))
+	if err != nil {
+		return nil, err
+	}
+	sums := map[string]string{}
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		sum, rel, err := parseChecksumLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", backupChecksumName, i+1, err)
+		}
+		sums[rel] = sum
+	}
+	return sums, nil
+}
+
 func parseChecksumLine(line string) (string, string, error) {
 	sum, rel, ok := strings.Cut(line, "  ")
 	if !ok {
@@ -3,34 +3,44 @@ package backup
 import (
 	"fmt"
 	"io"
+	"os"
 
 	"docker-manager/internal/commandflags"
 	"docker-manager/internal/completion"
 	"docker-manager/internal/docker"
+	"docker-manager/internal/objectstore"
 	rpt "docker-manager/internal/report"
 
 	"github.com/spf13/cobra"
 )
 
 func NewBackupCommand() *cobra.Command {
-	opts := BackupOptions{IncludeImage: true}
+	return NewBackupCommandWithDefaults(nil)
+}
+
+func NewBackupCommandWithDefaults(defaults func() CommandDefaults) *cobra.Command {
+	opts := BackupOptions{IncludeImage: true, IncludeVolumeData: true}
 	var noImage bool
+	var noVolumeData bool
 	cmd := &cobra.Command{
 		Use:   "backup <container-filter...>",
-		Short: "批量备份容器 inspect、镜像、compose、volume 和 network 元数据",
-		Long:  "批量备份容器 inspect、镜像、compose、volume 和 network 元数据。\n\n使用 --output-dir 指定备份输出目录。",
+		Short: "批量备份容器 inspect、镜像、compose、volume 数据和 network 元数据",
+		Long:  "批量备份容器 inspect、镜像、compose、volume 数据和 network 元数据。\n\n使用 --output-dir 指定备份输出目录。named volume 的文件内容通过临时 helper 容器导出为 volumes/<name>.tar，helper 容器只创建不启动。\n\n使用 --base 指定上一次备份可生成增量备份：镜像层、volume 和 bind 归档中与基线链相同的内容只写入引用，manifest 记录父备份；restore 会按相对路径（或原绝对路径）找到基线链并校验后重建完整归档。\n\n--bundle-output s3://<bucket>/<key> 把离线包（含加密和分卷输出）以分段上传方式直接写入 S3 兼容存储，不在本地保留归档；凭据读取 .dm.yaml 的 s3 配置或 AWS_* 环境变量。",
 		Args:  cobra.MinimumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			runOpts := opts
 			if noImage {
 				runOpts.IncludeImage = false
 			}
+			if noVolumeData {
+				runOpts.IncludeVolumeData = false
+			}
 			runOpts.OutputDir = opts.OutputDir
+			runOpts.S3 = s3ConfigFromDefaults(defaults)
 			runOpts.Output = cmd.OutOrStdout()
 			result, err := backupContainers(cmd.Context(), args, runOpts)
-			if err != nil {
-				return fmt.Errorf("备份容器失败: %w", err)
-			}
+			// Containers skipped by a failing pre-hook do not discard the
+			// backups already created for the rest of the batch.
 			for _, path := range result.Paths {
 				if runOpts.DryRun {
 					fmt.Fprintf(cmd.OutOrStdout(), "备份 dry-run 完成: %s\n", path)
@@ -38,33 +48,285 @@ func NewBackupCommand() *cobra.Command {
 					fmt.Fprintf(cmd.OutOrStdout(), "备份已创建: %s\n", path)
 				}
 			}
+			if err != nil {
+				return fmt.Errorf("备份容器失败: %w", err)
+			}
 			return nil
 		},
 		ValidArgsFunction: completion.LocalContainers,
 	}
 	cmd.Flags().BoolVar(&noImage, "no-image", false, "不导出容器镜像 tar")
+	cmd.Flags().BoolVar(&noVolumeData, "no-volume-data", false, "只备份 named volume 元数据，不导出 volume 文件内容")
+	cmd.Flags().StringVar(&opts.VolumeHelperImage, "volume-helper-image", "", "导出 volume 数据使用的 helper 镜像，默认使用源容器镜像")
+	cmd.Flags().BoolVar(&opts.IncludeBindMounts, "include-bind-mounts", false, "将 bind mount 的宿主机数据归档到 binds/；仅适用于本机 Docker")
+	cmd.Flags().StringArrayVar(&opts.BindInclude, "bind-include", nil, "只归档匹配的 bind 源路径；不含 / 的模式匹配文件名，可重复指定")
+	cmd.Flags().StringArrayVar(&opts.BindExclude, "bind-exclude", nil, "排除匹配的 bind 源路径，以及 bind 目录内匹配的相对路径或文件名，可重复指定")
+	cmd.Flags().StringVar(&opts.BindMaxSize, "bind-max-size", "", "每个容器 bind 数据总大小上限，超出时备份失败，例如 512M、2G")
+	cmd.Flags().StringVar(&opts.Base, "base", "", "基于已有备份目录或离线包做增量备份；与基线相同的镜像层和数据文件只记录引用，恢复时需要基线可访问")
+	cmd.Flags().StringVar(&opts.Consistency, "consistency", backupConsistencyNone, "捕获 volume/bind 数据时的一致性模式: none | pause | stop；stop 会在数据捕获后重新启动容器")
+	cmd.Flags().StringArrayVar(&opts.PreHooks, "pre-hook", nil, "备份前在容器内通过 sh -c 执行的命令，可重复指定；失败时跳过该容器。容器标签 dm.backup.pre 会先执行")
+	cmd.Flags().StringArrayVar(&opts.PostHooks, "post-hook", nil, "数据捕获后在容器内通过 sh -c 执行的命令，可重复指定；容器标签 dm.backup.post 会先执行")
+	cmd.Flags().DurationVar(&opts.HookTimeout, "hook-timeout", defaultBackupHookTimeout, "单个 hook 的超时时间")
 	cmd.Flags().BoolVar(&opts.DryRun, "dry-run", false, "只预览备份动作，不写入文件")
 	cmd.Flags().BoolVar(&opts.Bundle, "bundle", false, "生成离线迁移包 tar.gz，并附带 README、restore 脚本和 checksums")
-	cmd.Flags().StringVar(&opts.BundleOutput, "bundle-output", "", "离线迁移包输出路径，默认 <backup-dir>.tar.gz")
-	cmd.Flags().BoolVar(&opts.Encrypt, "encrypt", false, "加密离线迁移包；需要 --passphrase-file")
+	cmd.Flags().StringVar(&opts.BundleOutput, "bundle-output", "", "离线迁移包输出路径，默认 <backup-dir>.tar.gz；s3://<bucket>/<key> 会以分段上传方式流式写入对象存储")
+	cmd.Flags().BoolVar(&opts.Encrypt, "encrypt", false, "加密离线迁移包；需要 --passphrase-file 或 --recipient")
 	cmd.Flags().StringVar(&opts.PassphraseFile, "passphrase-file", "", "加密或解密备份包使用的口令文件")
+	cmd.Flags().StringArrayVar(&opts.Recipients, "recipient", nil, "加密离线迁移包的 X25519 公钥（age1...）或公钥文件，可重复；持有任一对应私钥即可恢复")
+	cmd.Flags().StringArrayVar(&opts.IdentityFiles, "identity", nil, "--base 指向加密基线时使用的私钥文件，可重复")
+	cmd.Flags().StringVar(&opts.SignKey, "sign-key", "", "使用 ed25519 私钥（PKCS#8 PEM）签名 manifest 和 checksums，生成 signature.json；可用 dm backup verify 校验")
 	cmd.Flags().StringVar(&opts.SplitSize, "split-size", "", "按指定大小分卷输出离线迁移包，例如 512M、2G")
 	cmd.Flags().StringVar(&opts.OutputDir, "output-dir", "", "备份输出目录；批量目标会在该目录下拆分子目录")
 	cmd.Flags().BoolVar(&opts.Merge, "merge", false, "将多个容器合并为一个批量备份包，可整体 restore")
+	cmd.Flags().StringVar(&opts.Repo, "repo", "", "将备份按内容分块存入 dm backup repo init 创建的仓库，每个备份生成一个快照")
+	cmd.AddCommand(newBackupRepoCommand(), newBackupKeygenCommand(), newBackupVerifyCommand(), newBackupListCommand(), newBackupExtractCommand())
+	return cmd
+}
+
+func newBackupKeygenCommand() *cobra.Command {
+	var output string
+	var sign bool
+	cmd := &cobra.Command{
+		Use:   "keygen",
+		Short: "生成用于 --recipient/--identity 的 X25519 密钥对，或用于 --sign-key 的 ed25519 签名密钥",
+		Long:  "生成用于 --recipient/--identity 的 X25519 密钥对。\n\n私钥文件与 age-keygen 的格式相同；公钥输出到标准输出，可直接作为 dm backup --recipient 的参数。\n\n使用 --sign 生成 ed25519 签名密钥：私钥为 PKCS#8 PEM，公钥写入 <output>.pub，供 dm backup verify --trusted-key 使用。",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if sign {
+				return writeBackupSignKeyPair(cmd.OutOrStdout(), output)
+			}
+			if output == "" {
+				_, err := generateBackupIdentity(cmd.OutOrStdout())
+				return err
+			}
+			file, err := os.OpenFile(output, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+			if err != nil {
+				return fmt.Errorf("创建私钥文件失败: %w", err)
+			}
+			recipient, err := generateBackupIdentity(file)
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "私钥已写入: %s\n公钥: %s\n", output, recipient)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&output, "output", "o", "", "私钥输出文件（不会覆盖已有文件），默认输出到标准输出")
+	cmd.Flags().BoolVar(&sign, "sign", false, "生成 ed25519 签名密钥而不是加密密钥；需要 --output")
+	return cmd
+}
+
+func writeBackupSignKeyPair(w io.Writer, output string) error {
+	if output == "" {
+		return fmt.Errorf("--sign 需要 --output 指定私钥文件")
+	}
+	file, err := os.OpenFile(output, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("创建私钥文件失败: %w", err)
+	}
+	public, err := generateBackupSignKey(file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(output+".pub", public, 0644); err != nil {
+		return fmt.Errorf("写入公钥文件失败: %w", err)
+	}
+	fmt.Fprintf(w, "签名私钥已写入: %s\n公钥已写入: %s.pub\n", output, output)
+	return nil
+}
+
+func newBackupVerifyCommand() *cobra.Command {
+	opts := BackupVerifyOptions{}
+	cmd := &cobra.Command{
+		Use:   "verify <backup-dir-or-archive>",
+		Short: "离线校验备份包的分卷、加密、checksums 和签名，不执行恢复",
+		Long:  "离线校验备份包的分卷、加密、checksums 和签名，不执行恢复，也不需要 Docker。\n\n提供 --trusted-key 时，备份必须由其中一个公钥签名才算通过；未提供时，未签名或签名即使自洽的备份也只报告为“未验证”并以非零状态退出，因为能改动备份的人同样可以删除签名或重新签名。",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			report, err := verifyBackupBundle(cmd.Context(), args[0], opts)
+			if err != nil {
+				return fmt.Errorf("校验备份失败: %w", err)
+			}
+			if err := rpt.Print(cmd.OutOrStdout(), opts.Format, report, func(w io.Writer) {
+				printBackupVerifyReport(w, report)
+			}); err != nil {
+				return err
+			}
+			if report.Result == "unverified" {
+				return fmt.Errorf("备份签名者未验证: %s (备份需签名，并使用 --trusted-key 指定签名公钥)", args[0])
+			}
+			if !report.OK {
+				return fmt.Errorf("备份校验未通过: %s", args[0])
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringArrayVar(&opts.TrustedKeys, "trusted-key", nil, "受信任的 ed25519 公钥文件（PEM 或 ssh-ed25519），可重复")
+	cmd.Flags().StringVar(&opts.PassphraseFile, "passphrase-file", "", "解密口令加密备份包使用的口令文件")
+	cmd.Flags().StringArrayVar(&opts.IdentityFiles, "identity", nil, "解密 --recipient 加密备份包使用的私钥文件，可重复")
+	commandflags.AddReportFormatFlag(cmd, &opts.Format)
+	return cmd
+}
+
+func newBackupListCommand() *cobra.Command {
+	opts := BackupListOptions{}
+	cmd := &cobra.Command{
+		Use:   "ls <backup-dir-or-archive>",
+		Short: "离线列出备份包中的容器、镜像、volume 和文件大小",
+		Long:  "离线列出备份包中的容器、镜像、network、volume 以及各文件大小，不需要 Docker。\n\n归档、分卷和加密归档以流方式读取，不会解压到磁盘；增量备份中的 delta 文件显示为引用大小。",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			listing, err := listBackupBundle(cmd.Context(), args[0], opts)
+			if err != nil {
+				return fmt.Errorf("读取备份包失败: %w", err)
+			}
+			return rpt.Print(cmd.OutOrStdout(), opts.Format, listing, func(w io.Writer) {
+				printBackupListing(w, listing)
+			})
+		},
+	}
+	cmd.Flags().StringVar(&opts.PassphraseFile, "passphrase-file", "", "读取口令加密备份包使用的口令文件")
+	cmd.Flags().StringArrayVar(&opts.IdentityFiles, "identity", nil, "读取 --recipient 加密备份包使用的私钥文件，可重复")
+	commandflags.AddReportFormatFlag(cmd, &opts.Format)
+	return cmd
+}
+
+func newBackupExtractCommand() *cobra.Command {
+	opts := BackupExtractOptions{}
+	cmd := &cobra.Command{
+		Use:   "extract <backup-dir-or-archive>",
+		Short: "离线从备份包中提取单个容器的 inspect、compose、镜像或数据归档",
+		Long:  "离线从备份包中提取指定容器的文件到 <output-dir>/<container>/，不需要 Docker，也不执行恢复。\n\n--only 可选 inspect,compose,image,networks,volumes,binds，默认全部；增量备份会先按基线链重建完整归档。",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			result, err := extractBackupBundle(cmd.Context(), args[0], opts)
+			if err != nil {
+				return fmt.Errorf("提取备份失败: %w", err)
+			}
+			return rpt.Print(cmd.OutOrStdout(), opts.Format, result, func(w io.Writer) {
+				printBackupExtractResult(w, result)
+			})
+		},
+	}
+	cmd.Flags().StringArrayVar(&opts.Containers, "container", nil, "只提取指定容器，可重复；默认全部容器")
+	cmd.Flags().StringArrayVar(&opts.Only, "only", nil, "只提取指定类型，逗号分隔: inspect,compose,image,networks,volumes,binds")
+	cmd.Flags().StringVar(&opts.OutputDir, "output-dir", ".", "提取输出目录，每个容器一个子目录")
+	cmd.Flags().BoolVar(&opts.Force, "force", false, "覆盖输出目录中已存在的文件")
+	cmd.Flags().StringVar(&opts.PassphraseFile, "passphrase-file", "", "解密口令加密备份包使用的口令文件")
+	cmd.Flags().StringArrayVar(&opts.IdentityFiles, "identity", nil, "解密 --recipient 加密备份包使用的私钥文件，可重复")
+	commandflags.AddReportFormatFlag(cmd, &opts.Format)
+	return cmd
+}
+
+func newBackupRepoCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "repo",
+		Short: "管理按内容寻址去重的本地备份仓库",
+		Long:  "管理按内容寻址去重的本地备份仓库。\n\n使用 dm backup <container> --repo <path> 写入快照，dm restore repo://<path>#<snapshot> 恢复；<snapshot> 可以是快照 ID 前缀、latest 或容器名（该容器最新快照）。",
+	}
+	cmd.AddCommand(newBackupRepoInitCommand(), newBackupRepoListCommand(), newBackupRepoPruneCommand(false), newBackupRepoPruneCommand(true))
+	return cmd
+}
+
+func newBackupRepoInitCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "init <path>",
+		Short: "初始化备份仓库",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := initBackupRepo(args[0]); err != nil {
+				return fmt.Errorf("初始化备份仓库失败: %w", err)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "备份仓库已创建: %s\n", args[0])
+			return nil
+		},
+	}
+}
+
+func newBackupRepoListCommand() *cobra.Command {
+	opts := RepoListOptions{}
+	cmd := &cobra.Command{
+		Use:   "ls <path>",
+		Short: "列出备份仓库中的快照",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			list, err := listRepoSnapshots(args[0], opts)
+			if err != nil {
+				return fmt.Errorf("列出快照失败: %w", err)
+			}
+			return rpt.Print(cmd.OutOrStdout(), opts.Format, list, func(w io.Writer) {
+				printRepoSnapshotList(w, list)
+			})
+		},
+	}
+	cmd.Flags().StringVar(&opts.Container, "container", "", "只列出包含该容器的快照")
+	commandflags.AddReportFormatFlag(cmd, &opts.Format)
+	return cmd
+}
+
+func newBackupRepoPruneCommand(gcOnly bool) *cobra.Command {
+	opts := RepoPruneOptions{GCOnly: gcOnly}
+	cmd := &cobra.Command{
+		Use:   "prune <path>",
+		Short: "按保留策略删除快照并回收未引用的数据块",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			report, err := pruneBackupRepo(cmd.Context(), args[0], opts)
+			if err != nil {
+				return fmt.Errorf("清理备份仓库失败: %w", err)
+			}
+			return rpt.Print(cmd.OutOrStdout(), opts.Format, report, func(w io.Writer) {
+				printRepoPruneReport(w, report)
+			})
+		},
+	}
+	if gcOnly {
+		cmd.Use = "gc <path>"
+		cmd.Short = "回收没有被任何快照引用的数据块"
+	} else {
+		cmd.Flags().StringVar(&opts.Container, "container", "", "只对包含该容器的快照应用保留策略")
+		cmd.Flags().IntVar(&opts.KeepLast, "keep-last", 0, "每组快照保留最新的 N 个")
+		cmd.Flags().IntVar(&opts.KeepDaily, "keep-daily", 0, "每组快照保留最近 N 天中每天最新的一个")
+		cmd.Flags().IntVar(&opts.KeepWeekly, "keep-weekly", 0, "每组快照保留最近 N 周中每周最新的一个")
+	}
+	cmd.Flags().BoolVar(&opts.Apply, "apply", false, "根据报告执行删除")
+	cmd.Flags().BoolVar(&opts.Confirm, "confirm", false, "确认执行 --apply 删除操作")
+	commandflags.AddReportFormatFlag(cmd, &opts.Format)
 	return cmd
 }
 
 func NewRestoreCommand() *cobra.Command {
+	return NewRestoreCommandWithDefaults(nil)
+}
+
+func NewRestoreCommandWithDefaults(defaults func() CommandDefaults) *cobra.Command {
 	opts := RestoreOptions{}
+	remapFlags := RestoreRemapFlags{}
 	cmd := &cobra.Command{
 		Use:   "restore <backup-dir-or-archive...>",
-		Short: "从 backup 生成的目录、批量目录或 tar.gz 离线包恢复镜像、网络、volume 和容器",
+		Short: "从 backup 生成的目录、批量目录、tar.gz 离线包、s3:// 对象或 repo:// 仓库快照恢复镜像、网络、volume 和容器",
+		Long:  "从 backup 生成的目录、批量目录、tar.gz 离线包、s3:// 对象或 repo:// 仓库快照恢复镜像、网络、volume 和容器。\n\ns3://<bucket>/<key> 边下载边解密、解包，不保留归档副本（分卷包会依次读取 .part-NNN），临时目录需能容纳解包后的备份。S3 凭据和地址读取 .dm.yaml 的 s3 配置，未设置的项使用 AWS_ACCESS_KEY_ID、AWS_SECRET_ACCESS_KEY、AWS_SESSION_TOKEN、AWS_REGION 和 AWS_ENDPOINT_URL_S3 / AWS_ENDPOINT_URL。",
 		Args:  cobra.MinimumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			opts.Output = cmd.OutOrStdout()
+			opts.S3 = s3ConfigFromDefaults(defaults)
 			if opts.Name != "" && len(args) > 1 {
 				return fmt.Errorf("--name 只支持恢复单个备份")
 			}
+			if opts.OverwriteBindData && opts.BindRoot == "" {
+				return fmt.Errorf("--overwrite-bind-data 需要配合 --bind-root 使用")
+			}
+			remap, err := buildRestoreRemap(remapFlags)
+			if err != nil {
+				return err
+			}
+			opts.Remap = remap
 			if opts.DryRun && opts.Format != rpt.FormatText {
 				for _, arg := range args {
 					report, err := buildRestorePlanReport(cmd.Context(), arg, opts)
@@ -100,11 +362,33 @@ func NewRestoreCommand() *cobra.Command {
 	cmd.Flags().BoolVar(&opts.NoStart, "no-start", false, "只创建容器，不启动")
 	cmd.Flags().BoolVar(&opts.DryRun, "dry-run", false, "只预览恢复动作，不修改 Docker；配合 --format json/markdown/html 可输出结构化恢复计划")
 	cmd.Flags().StringVar(&opts.PassphraseFile, "passphrase-file", "", "解密加密备份包使用的口令文件")
+	cmd.Flags().StringArrayVar(&opts.IdentityFiles, "identity", nil, "解密 --recipient 加密备份包使用的私钥文件（AGE-SECRET-KEY-1...），可重复")
 	cmd.Flags().BoolVar(&opts.SkipChecksum, "skip-checksum", false, "跳过 checksums.txt 完整性校验")
+	cmd.Flags().StringVar(&opts.VolumeHelperImage, "volume-helper-image", "", "导入 volume 数据使用的 helper 镜像，默认使用恢复后的容器镜像")
+	cmd.Flags().StringVar(&opts.BindRoot, "bind-root", "", "将备份中的 bind 数据写入该目录下的原路径并重定向容器挂载；指定 / 时写回原路径")
+	cmd.Flags().BoolVar(&opts.OverwriteBindData, "overwrite-bind-data", false, "允许 --bind-root 覆盖已存在且非空的 bind 路径；默认跳过这些路径")
+	cmd.Flags().StringVar(&remapFlags.File, "remap-file", "", "YAML 重映射文件，包含 containers/networks/volumes/ports/env/images；命令行参数优先")
+	cmd.Flags().StringArrayVar(&remapFlags.Containers, "rename", nil, "按 old=new 重命名恢复的容器，可重复；批量恢复时使用")
+	cmd.Flags().StringArrayVar(&remapFlags.Networks, "network", nil, "按 old=new 将容器连接的 network 改名恢复，可重复")
+	cmd.Flags().StringArrayVar(&remapFlags.Volumes, "volume", nil, "按 old=new 将命名 volume 改名恢复，可重复")
+	cmd.Flags().IntVar(&remapFlags.PortOffset, "port-offset", 0, "所有发布的宿主机端口加上该偏移量")
+	cmd.Flags().StringArrayVar(&remapFlags.Ports, "port", nil, "按 old=new 改写单个宿主机端口，优先于 --port-offset，可重复")
+	cmd.Flags().StringArrayVar(&remapFlags.Env, "env", nil, "按 KEY=VALUE 覆盖或新增环境变量，可重复")
+	cmd.Flags().StringArrayVar(&remapFlags.Images, "image", nil, "按 [old=]new 替换容器镜像；省略 old 时替换所有容器的镜像，且不再导入备份中的镜像归档")
 	commandflags.AddReportFormatFlag(cmd, &opts.Format)
 	return cmd
 }
 
+// s3ConfigFromDefaults resolves object storage settings when the command
+// runs, after the root command has loaded .dm.yaml.
+func s3ConfigFromDefaults(defaults func() CommandDefaults) objectstore.Config {
+	var cfg CommandDefaults
+	if defaults != nil {
+		cfg = defaults()
+	}
+	return objectstore.ConfigFromApp(cfg.S3)
+}
+
 func printRestoreDockerTarget(w io.Writer) {
 	if docker.IsRemoteEndpoint() {
 		fmt.Fprintf(w, "Target Docker: %s\n", docker.Endpoint())
@@ -1,13 +1,21 @@
 package backup
 
 import (
+	"bytes"
 	"context"
+	"fmt"
 	"io"
 	"log"
 	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
 
 	cerrdefs "github.com/containerd/errdefs"
+	"github.com/moby/moby/api/pkg/stdcopy"
 	"github.com/moby/moby/api/types/container"
+	"github.com/moby/moby/api/types/mount"
 	"github.com/moby/moby/api/types/network"
 	"github.com/moby/moby/api/types/volume"
 	mobyclient "github.com/moby/moby/client"
@@ -162,12 +170,12 @@ func (s *dockerBackupService) InspectVolume(ctx context.Context, name string) (v
 	return docker.ConvertDockerType[volume.Volume](result.Volume)
 }
 
-func (s *dockerBackupService) CreateVolume(ctx context.Context, vol volume.Volume) error {
+func (s *dockerBackupService) CreateVolume(ctx context.Context, vol volume.Volume) (bool, error) {
 	if _, err := s.cli.VolumeInspect(ctx, vol.Name, mobyclient.VolumeInspectOptions{}); err == nil {
 		log.Printf("Skip existing volume: %s", vol.Name)
-		return nil
+		return false, nil
 	} else if !cerrdefs.IsNotFound(err) {
-		return err
+		return false, err
 	}
 
 	_, err := s.cli.VolumeCreate(ctx, mobyclient.VolumeCreateOptions{
@@ -176,7 +184,91 @@ func (s *dockerBackupService) CreateVolume(ctx context.Context, vol volume.Volum
 		DriverOpts: vol.Options,
 		Labels:     vol.Labels,
 	})
-	return err
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ExportVolume copies the volume contents through a created but never started
+// helper container, so the helper image only has to exist locally.
+func (s *dockerBackupService) ExportVolume(ctx context.Context, name, helperImage, outputFile string) error {
+	id, err := s.createVolumeHelper(ctx, name, helperImage, true)
+	if err != nil {
+		return err
+	}
+	defer removeVolumeHelperContainer(s.cli, id)
+
+	result, err := s.cli.CopyFromContainer(ctx, id, mobyclient.CopyFromContainerOptions{SourcePath: backupVolumeHelperMount})
+	if err != nil {
+		return fmt.Errorf("copy volume data: %w", err)
+	}
+	defer result.Content.Close()
+
+	file, err := os.Create(outputFile)
+	if err != nil {
+		return err
+	}
+	if err := backupCopyWithContext(ctx, file, result.Content); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func (s *dockerBackupService) ImportVolume(ctx context.Context, name, helperImage, inputFile string) error {
+	file, err := os.Open(inputFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	id, err := s.createVolumeHelper(ctx, name, helperImage, false)
+	if err != nil {
+		return err
+	}
+	defer removeVolumeHelperContainer(s.cli, id)
+
+	_, err = s.cli.CopyToContainer(ctx, id, mobyclient.CopyToContainerOptions{
+		DestinationPath: path.Dir(backupVolumeHelperMount),
+		Content:         file,
+		CopyUIDGID:      true,
+	})
+	if err != nil {
+		return fmt.Errorf("copy volume data: %w", err)
+	}
+	return nil
+}
+
+func (s *dockerBackupService) createVolumeHelper(ctx context.Context, name, helperImage string, readOnly bool) (string, error) {
+	if strings.TrimSpace(helperImage) == "" {
+		return "", fmt.Errorf("volume helper image is empty")
+	}
+	resp, err := s.cli.ContainerCreate(ctx, mobyclient.ContainerCreateOptions{
+		Config: &container.Config{
+			Image:      helperImage,
+			Entrypoint: []string{"true"},
+		},
+		HostConfig: &container.HostConfig{
+			Mounts: []mount.Mount{{
+				Type:     mount.TypeVolume,
+				Source:   name,
+				Target:   backupVolumeHelperMount,
+				ReadOnly: readOnly,
+			}},
+		},
+		Name: "dm_volume_data_" + strconv.FormatInt(time.Now().UnixNano(), 10) + "_" + safeBackupName(name),
+	})
+	if err != nil {
+		return "", fmt.Errorf("create volume helper container with image %q: %w", helperImage, err)
+	}
+	return resp.ID, nil
+}
+
+func removeVolumeHelperContainer(cli *mobyclient.Client, containerID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, _ = cli.ContainerRemove(ctx, containerID, mobyclient.ContainerRemoveOptions{Force: true, RemoveVolumes: true})
 }
 
 func (s *dockerBackupService) ContainerExists(ctx context.Context, name string) (bool, error) {
@@ -225,3 +317,52 @@ func (s *dockerBackupService) StartContainer(ctx context.Context, id string) err
 	_, err := s.cli.ContainerStart(ctx, id, mobyclient.ContainerStartOptions{})
 	return err
 }
+
+func (s *dockerBackupService) ExecContainer(ctx context.Context, name string, cmd []string) (int, string, error) {
+	created, err := s.cli.ExecCreate(ctx, name, mobyclient.ExecCreateOptions{
+		AttachStdout: true,
+		AttachStderr: true,
+		Cmd:          cmd,
+	})
+	if err != nil {
+		return 0, "", err
+	}
+	attached, err := s.cli.ExecAttach(ctx, created.ID, mobyclient.ExecAttachOptions{})
+	if err != nil {
+		return 0, "", err
+	}
+	defer attached.Close()
+	var output bytes.Buffer
+	if _, err := stdcopy.StdCopy(&output, &output, attached.Reader); err != nil {
+		return 0, output.String(), err
+	}
+	for {
+		inspect, err := s.cli.ExecInspect(ctx, created.ID, mobyclient.ExecInspectOptions{})
+		if err != nil {
+			return 0, output.String(), err
+		}
+		if !inspect.Running {
+			return inspect.ExitCode, output.String(), nil
+		}
+		select {
+		case <-ctx.Done():
+			return 0, output.String(), ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+func (s *dockerBackupService) PauseContainer(ctx context.Context, name string) error {
+	_, err := s.cli.ContainerPause(ctx, name, mobyclient.ContainerPauseOptions{})
+	return err
+}
+
+func (s *dockerBackupService) UnpauseContainer(ctx context.Context, name string) error {
+	_, err := s.cli.ContainerUnpause(ctx, name, mobyclient.ContainerUnpauseOptions{})
+	return err
+}
+
+func (s *dockerBackupService) StopContainer(ctx context.Context, name string) error {
+	_, err := s.cli.ContainerStop(ctx, name, mobyclient.ContainerStopOptions{})
+	return err
+}
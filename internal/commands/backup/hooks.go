@@ -0,0 +1,194 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/moby/moby/api/types/container"
+	"github.com/moby/moby/api/types/mount"
+)
+
+const (
+	backupConsistencyNone  = "none"
+	backupConsistencyPause = "pause"
+	backupConsistencyStop  = "stop"
+
+	backupHookLabelPre  = "dm.backup.pre"
+	backupHookLabelPost = "dm.backup.post"
+
+	backupHookPhasePre  = "pre"
+	backupHookPhasePost = "post"
+
+	backupHookOutputLimit    = 4096
+	defaultBackupHookTimeout = 5 * time.Minute
+)
+
+// errBackupPreHookFailed marks a backup aborted by its own pre-hook; batch
+// backups skip that container and continue with the rest.
+var errBackupPreHookFailed = errors.New("pre-hook failed")
+
+type backupHook struct {
+	Phase   string
+	Source  string
+	Command string
+}
+
+func validateBackupConsistency(mode string) error {
+	switch mode {
+	case "", backupConsistencyNone, backupConsistencyPause, backupConsistencyStop:
+		return nil
+	default:
+		return fmt.Errorf("--consistency 只支持 none、pause 或 stop，当前为 %q", mode)
+	}
+}
+
+// backupHooksFor returns the hooks of one phase: the container's own label
+// hook first, then the hooks given on the command line.
+func backupHooksFor(inspect container.InspectResponse, opts BackupOptions, phase string) []backupHook {
+	label, commands := backupHookLabelPre, opts.PreHooks
+	if phase == backupHookPhasePost {
+		label, commands = backupHookLabelPost, opts.PostHooks
+	}
+	var hooks []backupHook
+	if inspect.Config != nil {
+		if command := strings.TrimSpace(inspect.Config.Labels[label]); command != "" {
+			hooks = append(hooks, backupHook{Phase: phase, Source: "label:" + label, Command: command})
+		}
+	}
+	for _, command := range commands {
+		if command = strings.TrimSpace(command); command != "" {
+			hooks = append(hooks, backupHook{Phase: phase, Source: "flag", Command: command})
+		}
+	}
+	return hooks
+}
+
+func plannedBackupHooks(inspect container.InspectResponse, opts BackupOptions) []BackupHookResult {
+	var results []BackupHookResult
+	for _, phase := range []string{backupHookPhasePre, backupHookPhasePost} {
+		for _, hook := range backupHooksFor(inspect, opts, phase) {
+			results = append(results, BackupHookResult{Phase: hook.Phase, Source: hook.Source, Command: hook.Command, Status: "planned"})
+		}
+	}
+	return results
+}
+
+// runBackupHooks runs hooks with `sh -c` inside the container. It stops at
+// the first failing hook; hooks of a container that is not running are
+// recorded as skipped.
+func runBackupHooks(ctx context.Context, svc backupDockerService, name string, inspect container.InspectResponse, hooks []backupHook, timeout time.Duration) ([]BackupHookResult, error) {
+	if timeout <= 0 {
+		timeout = defaultBackupHookTimeout
+	}
+	running := inspect.State != nil && inspect.State.Running
+	var results []BackupHookResult
+	for _, hook := range hooks {
+		if err := checkBackupContext(ctx); err != nil {
+			return results, err
+		}
+		result := BackupHookResult{Phase: hook.Phase, Source: hook.Source, Command: hook.Command}
+		if !running {
+			result.Status = "skipped"
+			result.Error = "container is not running"
+			results = append(results, result)
+			continue
+		}
+		hookCtx, cancel := context.WithTimeout(ctx, timeout)
+		started := time.Now()
+		exitCode, output, err := svc.ExecContainer(hookCtx, name, []string{"sh", "-c", hook.Command})
+		cancel()
+		result.Duration = time.Since(started).Round(time.Millisecond).String()
+		result.ExitCode = exitCode
+		result.Output = truncateBackupHookOutput(output)
+		switch {
+		case err != nil:
+			result.Status = "failed"
+			result.Error = err.Error()
+		case exitCode != 0:
+			result.Status = "failed"
+			result.Error = fmt.Sprintf("exit code %d", exitCode)
+		default:
+			result.Status = "ok"
+		}
+		results = append(results, result)
+		log.Printf("Backup hook: container=%s phase=%s source=%s status=%s duration=%s", name, hook.Phase, hook.Source, result.Status, result.Duration)
+		if result.Status == "failed" {
+			return results, fmt.Errorf("%s hook %q: %s", hook.Phase, hook.Command, result.Error)
+		}
+	}
+	return results, nil
+}
+
+func truncateBackupHookOutput(output string) string {
+	output = strings.TrimSpace(output)
+	if len(output) <= backupHookOutputLimit {
+		return output
+	}
+	return output[len(output)-backupHookOutputLimit:]
+}
+
+// backupNeedsQuiesce reports whether the backup captures data that the
+// running container may be writing to.
+func backupNeedsQuiesce(inspect container.InspectResponse, opts BackupOptions) bool {
+	for _, point := range inspect.Mounts {
+		if point.Type == mount.TypeVolume && opts.IncludeVolumeData {
+			return true
+		}
+		if point.Type == mount.TypeBind && opts.IncludeBindMounts {
+			return true
+		}
+	}
+	return false
+}
+
+// quiesceBackupContainer pauses or stops the container according to mode.
+// The returned resume function restores the previous state and must be
+// called even when the capture fails.
+func quiesceBackupContainer(ctx context.Context, svc backupDockerService, name string, inspect container.InspectResponse, opts BackupOptions) (*BackupConsistency, func() error, error) {
+	mode := opts.Consistency
+	if mode == "" || mode == backupConsistencyNone {
+		return nil, func() error { return nil }, nil
+	}
+	consistency := &BackupConsistency{Mode: mode}
+	noop := func() error { return nil }
+	switch {
+	case !backupNeedsQuiesce(inspect, opts):
+		consistency.Action = "skipped-no-data"
+		return consistency, noop, nil
+	case inspect.State == nil || !inspect.State.Running:
+		consistency.Action = "skipped-not-running"
+		return consistency, noop, nil
+	case inspect.State.Paused:
+		consistency.Action = "already-paused"
+		return consistency, noop, nil
+	}
+	// Resuming must not be skipped because the backup context was canceled.
+	resumeCtx := context.WithoutCancel(ctx)
+	started := time.Now()
+	finish := func(resume func(context.Context, string) error, verb string) func() error {
+		return func() error {
+			consistency.Quiesced = time.Since(started).Round(time.Millisecond).String()
+			if err := resume(resumeCtx, name); err != nil {
+				return fmt.Errorf("%s container %s after backup: %w", verb, name, err)
+			}
+			log.Printf("Backup consistency: container=%s mode=%s quiesced=%s", name, mode, consistency.Quiesced)
+			return nil
+		}
+	}
+	if mode == backupConsistencyPause {
+		if err := svc.PauseContainer(ctx, name); err != nil {
+			return nil, nil, fmt.Errorf("pause container %s: %w", name, err)
+		}
+		consistency.Action = "paused"
+		return consistency, finish(svc.UnpauseContainer, "unpause"), nil
+	}
+	if err := svc.StopContainer(ctx, name); err != nil {
+		return nil, nil, fmt.Errorf("stop container %s: %w", name, err)
+	}
+	consistency.Action = "stopped"
+	return consistency, finish(svc.StartContainer, "restart"), nil
+}
@@ -0,0 +1,787 @@
+package backup
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"docker-manager/internal/objectstore"
+)
+
+const (
+	backupBlobIndexName     = "blobs.json"
+	backupBlobMinSize       = 64 * 1024
+	backupChainMaxDepth     = 64
+	backupBlobPAXDigest     = "DM.blob"
+	backupBlobPAXSize       = "DM.size"
+	backupBlobDigestPrefix  = "sha256:"
+	backupBlobIndexVersion  = 1
+	backupArchiveSuffixPart = ".part-001"
+)
+
+// backupBlobIndex lists the tar entry bodies stored in each artifact of one
+// backup. Delta artifacts replace bodies that already exist in the parent
+// chain with PAX references, so only locally stored bodies are indexed.
+type backupBlobIndex struct {
+	Version   int                           `json:"version"`
+	Artifacts map[string]backupBlobArtifact `json:"artifacts"`
+}
+
+type backupBlobArtifact struct {
+	Delta bool              `json:"delta,omitempty"`
+	Blobs []backupBlobEntry `json:"blobs,omitempty"`
+}
+
+type backupBlobEntry struct {
+	Digest string `json:"digest"`
+	Offset int64  `json:"offset"`
+	Size   int64  `json:"size"`
+}
+
+type backupBlobLocation struct {
+	File   string
+	Offset int64
+	Size   int64
+}
+
+// backupChainMember is one resolved backup in an incremental chain, child first.
+type backupChainMember struct {
+	Source   string
+	Dir      string
+	Manifest BackupManifest
+	Index    backupBlobIndex
+}
+
+type backupChain struct {
+	Members []backupChainMember
+	blobs   map[string]backupBlobLocation
+}
+
+func (c *backupChain) lookup(digest string) (backupBlobLocation, bool) {
+	loc, ok := c.blobs[digest]
+	return loc, ok
+}
+
+func (c *backupChain) sources() []string {
+	sources := make([]string, 0, len(c.Members))
+	for _, member := range c.Members {
+		sources = append(sources, member.Source)
+	}
+	return sources
+}
+
+// resolveBackupChain follows manifest parent references starting from an
+// already resolved backup directory. Parents are located relative to the
+// reference directory of their child, falling back to the recorded source.
+func resolveBackupChain(ctx context.Context, dir, source string, opts RestoreOptions) (*backupChain, func(), error) {
+	var cleanups []func()
+	cleanup := func() {
+		for i := len(cleanups) - 1; i >= 0; i-- {
+			cleanups[i]()
+		}
+	}
+	chain := &backupChain{blobs: map[string]backupBlobLocation{}}
+	seen := map[string]bool{}
+	for depth := 0; ; depth++ {
+		if err := checkBackupContext(ctx); err != nil {
+			cleanup()
+			return nil, nil, err
+		}
+		if depth >= backupChainMaxDepth {
+			cleanup()
+			return nil, nil, fmt.Errorf("backup chain is deeper than %d backups", backupChainMaxDepth)
+		}
+		manifest, err := readBackupManifest(dir)
+		if err != nil {
+			cleanup()
+			return nil, nil, fmt.Errorf("%s: %w", source, err)
+		}
+		digest, err := fileSHA256WithContext(ctx, filepath.Join(dir, backupManifestName))
+		if err != nil {
+			cleanup()
+			return nil, nil, err
+		}
+		if seen[digest] {
+			cleanup()
+			return nil, nil, fmt.Errorf("backup chain contains a cycle at %s", source)
+		}
+		seen[digest] = true
+		index, err := readOrBuildBackupBlobIndex(ctx, dir, manifest)
+		if err != nil {
+			cleanup()
+			return nil, nil, fmt.Errorf("%s: %w", source, err)
+		}
+		member := backupChainMember{Source: source, Dir: dir, Manifest: manifest, Index: index}
+		chain.Members = append(chain.Members, member)
+		chain.addMember(member)
+		if manifest.Parent == nil {
+			return chain, cleanup, nil
+		}
+		parentSource, err := locateBackupParent(source, *manifest.Parent)
+		if err != nil {
+			cleanup()
+			return nil, nil, err
+		}
+		parentDir, parentCleanup, err := resolveRestoreBackupDirWithOptions(ctx, parentSource, opts)
+		if err != nil {
+			cleanup()
+			return nil, nil, fmt.Errorf("resolve parent backup %s: %w", parentSource, err)
+		}
+		if parentCleanup != nil {
+			cleanups = append(cleanups, parentCleanup)
+		}
+		parentDigest, err := fileSHA256WithContext(ctx, filepath.Join(parentDir, backupManifestName))
+		if err != nil {
+			cleanup()
+			return nil, nil, fmt.Errorf("read parent backup %s: %w", parentSource, err)
+		}
+		if !strings.EqualFold(parentDigest, manifest.Parent.ManifestSHA256) {
+			cleanup()
+			return nil, nil, fmt.Errorf("parent backup %s does not match: manifest sha256 expected %s actual %s", parentSource, manifest.Parent.ManifestSHA256, parentDigest)
+		}
+		dir, source = parentDir, parentSource
+	}
+}
+
+// addMember keeps the location closest to the child when a digest is stored
+// more than once in the chain.
+func (c *backupChain) addMember(member backupChainMember) {
+	for rel, artifact := range member.Index.Artifacts {
+		file := filepath.Join(member.Dir, filepath.FromSlash(rel))
+		for _, blob := range artifact.Blobs {
+			if _, ok := c.blobs[blob.Digest]; ok {
+				continue
+			}
+			c.blobs[blob.Digest] = backupBlobLocation{File: file, Offset: blob.Offset, Size: blob.Size}
+		}
+	}
+}
+
+func locateBackupParent(childSource string, parent BackupParentRef) (string, error) {
+	var candidates []string
+	if parent.Path != "" {
+		rel := filepath.FromSlash(parent.Path)
+		if filepath.IsAbs(rel) {
+			candidates = append(candidates, rel)
+		} else {
+			candidates = append(candidates, filepath.Join(backupReferenceDir(childSource), rel))
+		}
+	}
+	if parent.Source != "" {
+		candidates = append(candidates, parent.Source)
+	}
+	for _, candidate := range candidates {
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("parent backup not found; tried %s", strings.Join(candidates, ", "))
+}
+
+// backupReferenceDir is the directory a backup was created in, derived from
+// the path the user passed: bundles are named <dir>.tar.gz[.enc][.part-NNN].
+func backupReferenceDir(source string) string {
+	ref := source
+	lower := strings.ToLower(ref)
+	if strings.HasSuffix(lower, backupArchiveSuffixPart) {
+		ref = ref[:len(ref)-len(backupArchiveSuffixPart)]
+		lower = strings.ToLower(ref)
+	}
+	if strings.HasSuffix(lower, ".enc") {
+		ref = ref[:len(ref)-len(".enc")]
+		lower = strings.ToLower(ref)
+	}
+	switch {
+	case strings.HasSuffix(lower, ".tar.gz"):
+		ref = ref[:len(ref)-len(".tar.gz")]
+	case strings.HasSuffix(lower, ".tgz"):
+		ref = ref[:len(ref)-len(".tgz")]
+	}
+	return filepath.Dir(filepath.Clean(ref))
+}
+
+// backupParentRef records base relative to refDir, the directory that will
+// contain the new backup (its bundle when one is written).
+func backupParentRef(ctx context.Context, refDir, base string, baseChain *backupChain) (*BackupParentRef, error) {
+	if len(baseChain.Members) == 0 {
+		return nil, fmt.Errorf("base backup chain is empty")
+	}
+	digest, err := fileSHA256WithContext(ctx, filepath.Join(baseChain.Members[0].Dir, backupManifestName))
+	if err != nil {
+		return nil, err
+	}
+	absBase, err := filepath.Abs(base)
+	if err != nil {
+		return nil, err
+	}
+	ref := &BackupParentRef{
+		Path:           filepath.ToSlash(absBase),
+		Source:         absBase,
+		ManifestSHA256: digest,
+		CreatedAt:      baseChain.Members[0].Manifest.CreatedAt,
+	}
+	if absRef, err := filepath.Abs(refDir); err == nil {
+		if rel, err := filepath.Rel(absRef, absBase); err == nil {
+			ref.Path = filepath.ToSlash(rel)
+		}
+	}
+	return ref, nil
+}
+
+// finalizeBackupBlobs indexes the artifacts of a finished backup and, when
+// opts.Base is set, rewrites them as deltas against the base chain and
+// records the parent reference in manifest.
+func finalizeBackupBlobs(ctx context.Context, root string, manifest *BackupManifest, opts BackupOptions) error {
+	if opts.Base == "" {
+		_, _, err := writeBackupBlobIndex(ctx, root, *manifest, nil)
+		return err
+	}
+	baseOpts := RestoreOptions{PassphraseFile: opts.PassphraseFile, IdentityFiles: opts.IdentityFiles}
+	baseDir, baseCleanup, err := resolveRestoreBackupDirWithOptions(ctx, opts.Base, baseOpts)
+	if err != nil {
+		return fmt.Errorf("resolve base backup %s: %w", opts.Base, err)
+	}
+	if baseCleanup != nil {
+		defer baseCleanup()
+	}
+	chain, chainCleanup, err := resolveBackupChain(ctx, baseDir, opts.Base, baseOpts)
+	if err != nil {
+		return fmt.Errorf("base backup: %w", err)
+	}
+	defer chainCleanup()
+	refDir := filepath.Dir(filepath.Clean(root))
+	if opts.Bundle && opts.BundleOutput != "" && !objectstore.IsURL(opts.BundleOutput) {
+		refDir = filepath.Dir(filepath.Clean(opts.BundleOutput))
+	}
+	parent, err := backupParentRef(ctx, refDir, opts.Base, chain)
+	if err != nil {
+		return err
+	}
+	reused, reusedBytes, err := writeBackupBlobIndex(ctx, root, *manifest, chain)
+	if err != nil {
+		return err
+	}
+	parent.ReusedBlobs = reused
+	parent.ReusedBytes = reusedBytes
+	manifest.Parent = parent
+	log.Printf("Incremental backup: base=%s chain=%d reused_blobs=%d reused_bytes=%d", opts.Base, len(chain.Members), reused, reusedBytes)
+	return nil
+}
+
+// resolveRestoreBackupChain resolves the parents of an incremental backup,
+// verifies their checksums and rebuilds the delta artifacts of dir. It
+// returns dir unchanged for full backups.
+func resolveRestoreBackupChain(ctx context.Context, dir, source string, opts RestoreOptions) (string, []string, func(), error) {
+	manifest, err := readBackupManifest(dir)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	if manifest.Parent == nil {
+		return dir, nil, nil, nil
+	}
+	chain, chainCleanup, err := resolveBackupChain(ctx, dir, source, opts)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	for _, member := range chain.Members[1:] {
+		if opts.SkipChecksum {
+			break
+		}
+		verified, err := verifyBackupChecksumsWithContext(ctx, member.Dir)
+		if err != nil {
+			chainCleanup()
+			return "", nil, nil, fmt.Errorf("verify parent backup %s checksums: %w", member.Source, err)
+		}
+		if verified {
+			log.Printf("Checksum verification passed: %s", member.Source)
+		}
+	}
+	materialized, materializeCleanup, err := materializeBackupChain(ctx, chain)
+	if err != nil {
+		chainCleanup()
+		return "", nil, nil, fmt.Errorf("materialize backup chain: %w", err)
+	}
+	cleanup := func() {
+		if materializeCleanup != nil {
+			materializeCleanup()
+		}
+		chainCleanup()
+	}
+	return materialized, chain.sources()[1:], cleanup, nil
+}
+
+// backupArtifactPaths lists the tar artifacts of a backup relative to its
+// root; these are the files that take part in deduplication.
+func backupArtifactPaths(manifest BackupManifest) []string {
+	var paths []string
+	for _, entry := range manifest.Containers {
+		add := func(rel string) {
+			if rel == "" {
+				return
+			}
+			if entry.Path != "" {
+				rel = path.Join(entry.Path, rel)
+			}
+			paths = append(paths, rel)
+		}
+		add(entry.ImageArchive)
+		for _, ref := range entry.Volumes {
+			add(ref.Data)
+		}
+		for _, ref := range entry.Mounts {
+			add(ref.Data)
+		}
+	}
+	return paths
+}
+
+func readOrBuildBackupBlobIndex(ctx context.Context, dir string, manifest BackupManifest) (backupBlobIndex, error) {
+	var index backupBlobIndex
+	err := readJSON(filepath.Join(dir, backupBlobIndexName), &index)
+	if err == nil {
+		if index.Version != backupBlobIndexVersion {
+			return index, fmt.Errorf("unsupported %s version %d", backupBlobIndexName, index.Version)
+		}
+		return index, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return index, fmt.Errorf("read %s: %w", backupBlobIndexName, err)
+	}
+	// Backups created before blob indexes existed are full backups, so
+	// their artifacts can be indexed by scanning them.
+	index = backupBlobIndex{Version: backupBlobIndexVersion, Artifacts: map[string]backupBlobArtifact{}}
+	for _, rel := range backupArtifactPaths(manifest) {
+		file, err := backupFilePath(dir, rel)
+		if err != nil {
+			return index, err
+		}
+		blobs, err := scanBackupArtifactBlobs(ctx, file)
+		if err != nil {
+			return index, fmt.Errorf("index %s: %w", rel, err)
+		}
+		index.Artifacts[rel] = backupBlobArtifact{Blobs: blobs}
+	}
+	return index, nil
+}
+
+// writeBackupBlobIndex records blobs.json for root. With a base chain every
+// artifact is also deduplicated against the chain and earlier artifacts of
+// the same backup; without one the artifacts are only indexed so the backup
+// can serve as a later base.
+func writeBackupBlobIndex(ctx context.Context, root string, manifest BackupManifest, base *backupChain) (int, int64, error) {
+	index := backupBlobIndex{Version: backupBlobIndexVersion, Artifacts: map[string]backupBlobArtifact{}}
+	var reused int
+	var reusedBytes int64
+	for _, rel := range backupArtifactPaths(manifest) {
+		if err := checkBackupContext(ctx); err != nil {
+			return 0, 0, err
+		}
+		if _, ok := index.Artifacts[rel]; ok {
+			continue
+		}
+		file, err := backupFilePath(root, rel)
+		if err != nil {
+			return 0, 0, err
+		}
+		if base == nil {
+			blobs, err := scanBackupArtifactBlobs(ctx, file)
+			if err != nil {
+				return 0, 0, fmt.Errorf("index %s: %w", rel, err)
+			}
+			index.Artifacts[rel] = backupBlobArtifact{Blobs: blobs}
+			continue
+		}
+		artifact, count, size, err := dedupeBackupArtifact(ctx, file, base)
+		if err != nil {
+			return 0, 0, fmt.Errorf("dedupe %s: %w", rel, err)
+		}
+		reused += count
+		reusedBytes += size
+		index.Artifacts[rel] = artifact
+		for _, blob := range artifact.Blobs {
+			if _, ok := base.blobs[blob.Digest]; !ok {
+				base.blobs[blob.Digest] = backupBlobLocation{File: file, Offset: blob.Offset, Size: blob.Size}
+			}
+		}
+	}
+	if err := writeJSONFile(filepath.Join(root, backupBlobIndexName), index); err != nil {
+		return 0, 0, fmt.Errorf("write %s: %w", backupBlobIndexName, err)
+	}
+	return reused, reusedBytes, nil
+}
+
+type backupBlobDigest struct {
+	digest string
+	offset int64
+	size   int64
+}
+
+// dedupeBackupArtifact hashes every large tar entry and, when any of them is
+// already available, rewrites the artifact with PAX references in their place.
+func dedupeBackupArtifact(ctx context.Context, file string, available *backupChain) (backupBlobArtifact, int, int64, error) {
+	digests, err := hashBackupArtifactEntries(ctx, file)
+	if err != nil {
+		return backupBlobArtifact{}, 0, 0, err
+	}
+	var reused int
+	var reusedBytes int64
+	for _, item := range digests {
+		if item.digest == "" {
+			continue
+		}
+		if _, ok := available.lookup(item.digest); ok {
+			reused++
+			reusedBytes += item.size
+		}
+	}
+	if reused == 0 {
+		return backupBlobArtifact{Blobs: backupBlobEntries(digests)}, 0, 0, nil
+	}
+	blobs, err := rewriteBackupArtifactDelta(ctx, file, digests, available)
+	if err != nil {
+		return backupBlobArtifact{}, 0, 0, err
+	}
+	return backupBlobArtifact{Delta: true, Blobs: blobs}, reused, reusedBytes, nil
+}
+
+func backupBlobEntries(digests []backupBlobDigest) []backupBlobEntry {
+	var blobs []backupBlobEntry
+	for _, item := range digests {
+		if item.digest == "" {
+			continue
+		}
+		blobs = append(blobs, backupBlobEntry{Digest: item.digest, Offset: item.offset, Size: item.size})
+	}
+	return blobs
+}
+
+func scanBackupArtifactBlobs(ctx context.Context, file string) ([]backupBlobEntry, error) {
+	digests, err := hashBackupArtifactEntries(ctx, file)
+	if err != nil {
+		return nil, err
+	}
+	return backupBlobEntries(digests), nil
+}
+
+// hashBackupArtifactEntries returns one item per tar entry in archive order;
+// entries too small to be worth deduplicating have an empty digest.
+func hashBackupArtifactEntries(ctx context.Context, file string) ([]backupBlobDigest, error) {
+	in, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	counter := &backupCountingReader{r: in}
+	tr := tar.NewReader(counter)
+	var items []backupBlobDigest
+	for {
+		if err := checkBackupContext(ctx); err != nil {
+			return nil, err
+		}
+		header, err := tr.Next()
+		if err == io.EOF {
+			return items, nil
+		}
+		if len(items) == 0 && (errors.Is(err, tar.ErrHeader) || errors.Is(err, io.ErrUnexpectedEOF)) {
+			// Not a tar archive; it is stored as-is and never deduplicated.
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		item := backupBlobDigest{offset: counter.n, size: header.Size}
+		if isBackupBlobCandidate(header) {
+			hash := sha256.New()
+			if err := backupCopyWithContext(ctx, hash, tr); err != nil {
+				return nil, err
+			}
+			item.digest = backupBlobDigestPrefix + hex.EncodeToString(hash.Sum(nil))
+		}
+		items = append(items, item)
+	}
+}
+
+func isBackupBlobCandidate(header *tar.Header) bool {
+	if header.Typeflag != tar.TypeReg && header.Typeflag != tar.TypeRegA {
+		return false
+	}
+	if _, ok := header.PAXRecords[backupBlobPAXDigest]; ok {
+		return false
+	}
+	return header.Size >= backupBlobMinSize
+}
+
+func rewriteBackupArtifactDelta(ctx context.Context, file string, digests []backupBlobDigest, available *backupChain) ([]backupBlobEntry, error) {
+	in, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	tmp := file + ".delta"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp)
+	counter := &backupCountingWriter{w: out}
+	tw := tar.NewWriter(counter)
+	tr := tar.NewReader(in)
+	var blobs []backupBlobEntry
+	for i := 0; ; i++ {
+		if err := checkBackupContext(ctx); err != nil {
+			_ = out.Close()
+			return nil, err
+		}
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			_ = out.Close()
+			return nil, err
+		}
+		if i >= len(digests) {
+			_ = out.Close()
+			return nil, fmt.Errorf("artifact changed while deduplicating")
+		}
+		item := digests[i]
+		if item.digest != "" {
+			if _, ok := available.lookup(item.digest); ok {
+				ref := *header
+				ref.Size = 0
+				ref.Format = tar.FormatPAX
+				ref.PAXRecords = clonePAXRecords(header.PAXRecords)
+				ref.PAXRecords[backupBlobPAXDigest] = item.digest
+				ref.PAXRecords[backupBlobPAXSize] = strconv.FormatInt(item.size, 10)
+				if err := tw.WriteHeader(&ref); err != nil {
+					_ = out.Close()
+					return nil, err
+				}
+				continue
+			}
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			_ = out.Close()
+			return nil, err
+		}
+		offset := counter.n
+		if err := backupCopyWithContext(ctx, tw, tr); err != nil {
+			_ = out.Close()
+			return nil, err
+		}
+		if item.digest != "" {
+			blobs = append(blobs, backupBlobEntry{Digest: item.digest, Offset: offset, Size: item.size})
+		}
+	}
+	if err := tw.Close(); err != nil {
+		_ = out.Close()
+		return nil, err
+	}
+	if err := out.Close(); err != nil {
+		return nil, err
+	}
+	if err := in.Close(); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		return nil, err
+	}
+	return blobs, nil
+}
+
+func clonePAXRecords(records map[string]string) map[string]string {
+	cloned := make(map[string]string, len(records)+2)
+	for key, value := range records {
+		cloned[key] = value
+	}
+	return cloned
+}
+
+// materializeBackupChain returns a directory in which every delta artifact of
+// the child backup has been rebuilt into a complete tar. Blob contents are
+// verified against their digests while they are copied out of the chain.
+func materializeBackupChain(ctx context.Context, chain *backupChain) (string, func(), error) {
+	child := chain.Members[0]
+	var deltas []string
+	for rel, artifact := range child.Index.Artifacts {
+		if artifact.Delta {
+			deltas = append(deltas, rel)
+		}
+	}
+	if len(deltas) == 0 {
+		return child.Dir, nil, nil
+	}
+	sort.Strings(deltas)
+	isDelta := map[string]bool{}
+	for _, rel := range deltas {
+		isDelta[rel] = true
+	}
+	tempDir, err := os.MkdirTemp("", "dm-restore-chain-*")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() { _ = os.RemoveAll(tempDir) }
+	err = filepath.WalkDir(child.Dir, func(p string, entry os.DirEntry, walkErr error) error {
+		if err := checkBackupContext(ctx); err != nil {
+			return err
+		}
+		if walkErr != nil {
+			return walkErr
+		}
+		rel, err := filepath.Rel(child.Dir, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(tempDir, rel)
+		if entry.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		if isDelta[filepath.ToSlash(rel)] {
+			return materializeBackupArtifact(ctx, p, target, chain)
+		}
+		return linkOrCopyBackupFile(ctx, p, target)
+	})
+	if err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	log.Printf("Backup chain materialized: backups=%d delta_artifacts=%d", len(chain.Members), len(deltas))
+	return tempDir, cleanup, nil
+}
+
+func materializeBackupArtifact(ctx context.Context, deltaFile, outputFile string, chain *backupChain) error {
+	in, err := os.Open(deltaFile)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(outputFile)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	tr := tar.NewReader(in)
+	tw := tar.NewWriter(out)
+	for {
+		if err := checkBackupContext(ctx); err != nil {
+			return err
+		}
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		digest, ok := header.PAXRecords[backupBlobPAXDigest]
+		if !ok {
+			if err := tw.WriteHeader(header); err != nil {
+				return err
+			}
+			if err := backupCopyWithContext(ctx, tw, tr); err != nil {
+				return err
+			}
+			continue
+		}
+		size, err := strconv.ParseInt(header.PAXRecords[backupBlobPAXSize], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid blob size for %s: %w", header.Name, err)
+		}
+		loc, ok := chain.lookup(digest)
+		if !ok {
+			return fmt.Errorf("blob %s for %s is missing from the backup chain", digest, header.Name)
+		}
+		if loc.Size != size {
+			return fmt.Errorf("blob %s for %s has size %d in the chain, want %d", digest, header.Name, loc.Size, size)
+		}
+		full := *header
+		full.Size = size
+		full.PAXRecords = clonePAXRecords(header.PAXRecords)
+		delete(full.PAXRecords, backupBlobPAXDigest)
+		delete(full.PAXRecords, backupBlobPAXSize)
+		if err := tw.WriteHeader(&full); err != nil {
+			return err
+		}
+		if err := copyBackupBlob(ctx, tw, loc, digest); err != nil {
+			return fmt.Errorf("%s: %w", header.Name, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+func copyBackupBlob(ctx context.Context, dst io.Writer, loc backupBlobLocation, digest string) error {
+	file, err := os.Open(loc.File)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if err := backupCopyWithContext(ctx, io.MultiWriter(dst, hash), io.NewSectionReader(file, loc.Offset, loc.Size)); err != nil {
+		return err
+	}
+	actual := backupBlobDigestPrefix + hex.EncodeToString(hash.Sum(nil))
+	if actual != digest {
+		return fmt.Errorf("blob digest mismatch in %s: expected %s actual %s", loc.File, digest, actual)
+	}
+	return nil
+}
+
+func linkOrCopyBackupFile(ctx context.Context, source, target string) error {
+	if err := os.Link(source, target); err == nil {
+		return nil
+	}
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if err := backupCopyWithContext(ctx, out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+type backupCountingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *backupCountingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+type backupCountingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *backupCountingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
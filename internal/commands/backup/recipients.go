@@ -0,0 +1,530 @@
+package backup
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Recipient-encrypted bundles use the same chunk stream as passphrase
+// bundles, but the chunks are sealed with a random file key that is wrapped
+// once per recipient. Keys use the age X25519 text encoding, so keys made by
+// age-keygen work as well.
+const (
+	backupRecipientMagic      = "DMBKENC2\n"
+	backupRecipientHeaderMax  = 1 << 20
+	backupRecipientKeyPrefix  = "age"
+	backupIdentityKeyPrefix   = "AGE-SECRET-KEY-"
+	backupRecipientWrapInfo   = "dm-backup/x25519"
+	backupEncryptionFileKey   = 32
+	backupStanzaX25519        = "x25519"
+	backupStanzaPassphrase    = "pbkdf2"
+	backupSchemePassphrase    = "passphrase"
+	backupSchemeRecipients    = "recipients"
+	backupEncryptionCipherGCM = "aes-256-gcm"
+)
+
+// backupEncryptionHeader is the JSON header of a DMBKENC2 bundle. It is also
+// the additional authenticated data of every chunk, so it cannot be altered
+// without breaking decryption.
+type backupEncryptionHeader struct {
+	Scheme      string                   `json:"scheme"`
+	Cipher      string                   `json:"cipher"`
+	ChunkSize   int                      `json:"chunk_size"`
+	NoncePrefix []byte                   `json:"nonce_prefix"`
+	Stanzas     []backupEncryptionStanza `json:"stanzas"`
+}
+
+type backupEncryptionStanza struct {
+	Type       string `json:"type"`
+	Ephemeral  []byte `json:"ephemeral,omitempty"`
+	Salt       []byte `json:"salt,omitempty"`
+	Iterations int    `json:"iterations,omitempty"`
+	WrappedKey []byte `json:"wrapped_key"`
+}
+
+// backupDecryptionKeys holds everything a restore may use to open an
+// encrypted bundle.
+type backupDecryptionKeys struct {
+	Passphrase []byte
+	Identities []*ecdh.PrivateKey
+}
+
+func loadBackupDecryptionKeys(passphraseFile string, identityFiles []string) (backupDecryptionKeys, error) {
+	var keys backupDecryptionKeys
+	if strings.TrimSpace(passphraseFile) == "" && len(identityFiles) == 0 {
+		return keys, fmt.Errorf("encrypted backup requires --passphrase-file or --identity")
+	}
+	if strings.TrimSpace(passphraseFile) != "" {
+		passphrase, err := readBackupPassphrase(passphraseFile)
+		if err != nil {
+			return keys, err
+		}
+		keys.Passphrase = passphrase
+	}
+	for _, path := range identityFiles {
+		identities, err := readBackupIdentities(path)
+		if err != nil {
+			return keys, err
+		}
+		keys.Identities = append(keys.Identities, identities...)
+	}
+	return keys, nil
+}
+
+// parseBackupRecipients accepts inline age1... keys or files with one key per
+// line; blank lines and # comments are ignored.
+func parseBackupRecipients(values []string) ([]*ecdh.PublicKey, error) {
+	var recipients []*ecdh.PublicKey
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		lines := []string{value}
+		if !strings.HasPrefix(value, backupRecipientKeyPrefix+"1") {
+			data, err := os.ReadFile(value)
+			if err != nil {
+				return nil, fmt.Errorf("read recipient file: %w", err)
+			}
+			lines = strings.Split(string(data), "\n")
+		}
+		found := false
+		for _, line := range lines {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			key, err := parseBackupRecipient(line)
+			if err != nil {
+				return nil, fmt.Errorf("recipient %s: %w", value, err)
+			}
+			recipients = append(recipients, key)
+			found = true
+		}
+		if !found {
+			return nil, fmt.Errorf("recipient %s 不包含公钥", value)
+		}
+	}
+	return recipients, nil
+}
+
+func parseBackupRecipient(text string) (*ecdh.PublicKey, error) {
+	hrp, data, err := bech32Decode(text)
+	if err != nil {
+		return nil, err
+	}
+	if hrp != backupRecipientKeyPrefix {
+		return nil, fmt.Errorf("不是 X25519 公钥（应以 age1 开头）")
+	}
+	return ecdh.X25519().NewPublicKey(data)
+}
+
+func readBackupIdentities(path string) ([]*ecdh.PrivateKey, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("read identity file: %w", err)
+	}
+	defer file.Close()
+	var identities []*ecdh.PrivateKey
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		hrp, data, err := bech32Decode(line)
+		if err != nil {
+			return nil, fmt.Errorf("identity file %s: %w", path, err)
+		}
+		if hrp != strings.ToLower(backupIdentityKeyPrefix) {
+			return nil, fmt.Errorf("identity file %s: 不是 X25519 私钥（应以 AGE-SECRET-KEY-1 开头）", path)
+		}
+		key, err := ecdh.X25519().NewPrivateKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("identity file %s: %w", path, err)
+		}
+		identities = append(identities, key)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(identities) == 0 {
+		return nil, fmt.Errorf("identity file %s 不包含私钥", path)
+	}
+	return identities, nil
+}
+
+func formatBackupRecipient(key *ecdh.PublicKey) string {
+	return bech32Encode(backupRecipientKeyPrefix, key.Bytes())
+}
+
+func formatBackupIdentity(key *ecdh.PrivateKey) string {
+	return strings.ToUpper(bech32Encode(strings.ToLower(backupIdentityKeyPrefix), key.Bytes()))
+}
+
+// generateBackupIdentity writes a new identity file in age-keygen layout and
+// returns the matching recipient.
+func generateBackupIdentity(w io.Writer) (string, error) {
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", err
+	}
+	recipient := formatBackupRecipient(key.PublicKey())
+	_, err = fmt.Fprintf(w, "# created: %s\n# public key: %s\n%s\n", time.Now().Format(time.RFC3339), recipient, formatBackupIdentity(key))
+	return recipient, err
+}
+
+func newRecipientEncryptWriter(dst io.WriteCloser, recipients []*ecdh.PublicKey, passphrase []byte) (*encryptWriter, error) {
+	fileKey := make([]byte, backupEncryptionFileKey)
+	prefix := make([]byte, backupEncryptionPrefixSize)
+	if _, err := rand.Read(fileKey); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(prefix); err != nil {
+		return nil, err
+	}
+	header := backupEncryptionHeader{
+		Scheme:      backupSchemeRecipients,
+		Cipher:      backupEncryptionCipherGCM,
+		ChunkSize:   backupEncryptionChunkSize,
+		NoncePrefix: prefix,
+	}
+	for _, recipient := range recipients {
+		stanza, err := wrapBackupFileKeyX25519(fileKey, recipient)
+		if err != nil {
+			return nil, err
+		}
+		header.Stanzas = append(header.Stanzas, stanza)
+	}
+	if len(passphrase) > 0 {
+		stanza, err := wrapBackupFileKeyPassphrase(fileKey, passphrase)
+		if err != nil {
+			return nil, err
+		}
+		header.Stanzas = append(header.Stanzas, stanza)
+	}
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+	preamble := make([]byte, 0, len(backupRecipientMagic)+4+len(headerJSON))
+	preamble = append(preamble, backupRecipientMagic...)
+	preamble = binary.BigEndian.AppendUint32(preamble, uint32(len(headerJSON)))
+	preamble = append(preamble, headerJSON...)
+	aead, err := newBackupGCM(fileKey)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dst.Write(preamble); err != nil {
+		return nil, err
+	}
+	return &encryptWriter{dst: dst, aead: aead, prefix: prefix, aad: preamble, buf: make([]byte, 0, backupEncryptionChunkSize)}, nil
+}
+
+// readBackupRecipientHeader reads a DMBKENC2 header after its magic and
+// returns it together with the bytes every chunk is authenticated against.
+func readBackupRecipientHeader(src io.Reader) (backupEncryptionHeader, []byte, error) {
+	var header backupEncryptionHeader
+	var size uint32
+	if err := binary.Read(src, binary.BigEndian, &size); err != nil {
+		return header, nil, err
+	}
+	if size == 0 || size > backupRecipientHeaderMax {
+		return header, nil, fmt.Errorf("invalid encrypted backup header")
+	}
+	headerJSON := make([]byte, size)
+	if _, err := io.ReadFull(src, headerJSON); err != nil {
+		return header, nil, err
+	}
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return header, nil, fmt.Errorf("invalid encrypted backup header: %w", err)
+	}
+	aad := make([]byte, 0, len(backupRecipientMagic)+4+len(headerJSON))
+	aad = append(aad, backupRecipientMagic...)
+	aad = binary.BigEndian.AppendUint32(aad, size)
+	aad = append(aad, headerJSON...)
+	return header, aad, nil
+}
+
+// openRecipientEncryptedStream returns the chunk cipher, nonce prefix and
+// chunk additional data of a DMBKENC2 stream positioned after its magic.
+func openRecipientEncryptedStream(src io.Reader, keys backupDecryptionKeys) (cipher.AEAD, []byte, []byte, error) {
+	header, aad, err := readBackupRecipientHeader(src)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if header.Cipher != backupEncryptionCipherGCM || len(header.NoncePrefix) != backupEncryptionPrefixSize {
+		return nil, nil, nil, fmt.Errorf("unsupported encrypted backup: scheme=%s cipher=%s", header.Scheme, header.Cipher)
+	}
+	fileKey, err := unwrapBackupFileKey(header, keys)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	aead, err := newBackupGCM(fileKey)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return aead, header.NoncePrefix, aad, nil
+}
+
+// describeBackupEncryption reads the header of an encrypted bundle and
+// describes its scheme without decrypting anything.
+func describeBackupEncryption(src io.Reader) (string, error) {
+	magic := make([]byte, len(backupEncryptionMagic))
+	if _, err := io.ReadFull(src, magic); err != nil {
+		return "", err
+	}
+	switch string(magic) {
+	case backupEncryptionMagic:
+		return backupSchemePassphrase, nil
+	case backupRecipientMagic:
+	default:
+		return "", fmt.Errorf("invalid encrypted backup header")
+	}
+	header, _, err := readBackupRecipientHeader(src)
+	if err != nil {
+		return "", err
+	}
+	counts := map[string]int{}
+	for _, stanza := range header.Stanzas {
+		counts[stanza.Type]++
+	}
+	return fmt.Sprintf("%s %s=%d %s=%d", header.Scheme, backupStanzaX25519, counts[backupStanzaX25519], backupStanzaPassphrase, counts[backupStanzaPassphrase]), nil
+}
+
+func unwrapBackupFileKey(header backupEncryptionHeader, keys backupDecryptionKeys) ([]byte, error) {
+	var recipients, passphrases int
+	for _, stanza := range header.Stanzas {
+		switch stanza.Type {
+		case backupStanzaX25519:
+			recipients++
+			for _, identity := range keys.Identities {
+				if fileKey, err := unwrapBackupFileKeyX25519(stanza, identity); err == nil {
+					return fileKey, nil
+				}
+			}
+		case backupStanzaPassphrase:
+			passphrases++
+			if len(keys.Passphrase) == 0 {
+				continue
+			}
+			if fileKey, err := unwrapBackupFileKeyPassphrase(stanza, keys.Passphrase); err == nil {
+				return fileKey, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("没有可以解密该备份包的 --identity 或 --passphrase-file（scheme=%s recipients=%d passphrase=%d）", header.Scheme, recipients, passphrases)
+}
+
+func wrapBackupFileKeyX25519(fileKey []byte, recipient *ecdh.PublicKey) (backupEncryptionStanza, error) {
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return backupEncryptionStanza{}, err
+	}
+	shared, err := ephemeral.ECDH(recipient)
+	if err != nil {
+		return backupEncryptionStanza{}, err
+	}
+	wrapped, err := sealBackupFileKey(shared, ephemeral.PublicKey().Bytes(), recipient.Bytes(), fileKey)
+	if err != nil {
+		return backupEncryptionStanza{}, err
+	}
+	return backupEncryptionStanza{Type: backupStanzaX25519, Ephemeral: ephemeral.PublicKey().Bytes(), WrappedKey: wrapped}, nil
+}
+
+func unwrapBackupFileKeyX25519(stanza backupEncryptionStanza, identity *ecdh.PrivateKey) ([]byte, error) {
+	ephemeral, err := ecdh.X25519().NewPublicKey(stanza.Ephemeral)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := identity.ECDH(ephemeral)
+	if err != nil {
+		return nil, err
+	}
+	wrapKey, err := backupX25519WrapKey(shared, stanza.Ephemeral, identity.PublicKey().Bytes())
+	if err != nil {
+		return nil, err
+	}
+	return openBackupFileKey(wrapKey, stanza.WrappedKey)
+}
+
+// backupX25519WrapKey binds the wrap key to both public keys of the exchange.
+func backupX25519WrapKey(shared, ephemeral, recipient []byte) ([]byte, error) {
+	salt := append(append([]byte(nil), ephemeral...), recipient...)
+	return hkdf.Key(sha256.New, shared, salt, backupRecipientWrapInfo, 32)
+}
+
+func sealBackupFileKey(shared, ephemeral, recipient, fileKey []byte) ([]byte, error) {
+	wrapKey, err := backupX25519WrapKey(shared, ephemeral, recipient)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newBackupGCM(wrapKey)
+	if err != nil {
+		return nil, err
+	}
+	// Every wrap key is derived from a fresh ephemeral key, so a fixed nonce
+	// is never reused with the same key.
+	return aead.Seal(nil, make([]byte, aead.NonceSize()), fileKey, nil), nil
+}
+
+func wrapBackupFileKeyPassphrase(fileKey, passphrase []byte) (backupEncryptionStanza, error) {
+	salt := make([]byte, backupEncryptionSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return backupEncryptionStanza{}, err
+	}
+	wrapKey, err := pbkdf2.Key(sha256.New, string(passphrase), salt, backupEncryptionKDFIter, 32)
+	if err != nil {
+		return backupEncryptionStanza{}, err
+	}
+	aead, err := newBackupGCM(wrapKey)
+	if err != nil {
+		return backupEncryptionStanza{}, err
+	}
+	wrapped := aead.Seal(nil, make([]byte, aead.NonceSize()), fileKey, nil)
+	return backupEncryptionStanza{Type: backupStanzaPassphrase, Salt: salt, Iterations: backupEncryptionKDFIter, WrappedKey: wrapped}, nil
+}
+
+func unwrapBackupFileKeyPassphrase(stanza backupEncryptionStanza, passphrase []byte) ([]byte, error) {
+	if stanza.Iterations <= 0 || stanza.Iterations > 10*backupEncryptionKDFIter {
+		return nil, fmt.Errorf("invalid pbkdf2 iterations %d", stanza.Iterations)
+	}
+	wrapKey, err := pbkdf2.Key(sha256.New, string(passphrase), stanza.Salt, stanza.Iterations, 32)
+	if err != nil {
+		return nil, err
+	}
+	return openBackupFileKey(wrapKey, stanza.WrappedKey)
+}
+
+func openBackupFileKey(wrapKey, wrapped []byte) ([]byte, error) {
+	aead, err := newBackupGCM(wrapKey)
+	if err != nil {
+		return nil, err
+	}
+	fileKey, err := aead.Open(nil, make([]byte, aead.NonceSize()), wrapped, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(fileKey) != backupEncryptionFileKey {
+		return nil, errors.New("invalid file key")
+	}
+	return fileKey, nil
+}
+
+func newBackupGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+const bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+func bech32Polymod(values []byte) uint32 {
+	generator := [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+	chk := uint32(1)
+	for _, value := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(value)
+		for i, g := range generator {
+			if (top>>uint(i))&1 == 1 {
+				chk ^= g
+			}
+		}
+	}
+	return chk
+}
+
+func bech32HRPExpand(hrp string) []byte {
+	result := make([]byte, 0, len(hrp)*2+1)
+	for i := 0; i < len(hrp); i++ {
+		result = append(result, hrp[i]>>5)
+	}
+	result = append(result, 0)
+	for i := 0; i < len(hrp); i++ {
+		result = append(result, hrp[i]&31)
+	}
+	return result
+}
+
+func bech32ConvertBits(data []byte, from, to uint, pad bool) ([]byte, error) {
+	var acc, bits uint
+	maxv := uint(1)<<to - 1
+	var result []byte
+	for _, value := range data {
+		if uint(value)>>from != 0 {
+			return nil, errors.New("invalid bech32 data")
+		}
+		acc = acc<<from | uint(value)
+		bits += from
+		for bits >= to {
+			bits -= to
+			result = append(result, byte(acc>>bits&maxv))
+		}
+	}
+	if pad {
+		if bits > 0 {
+			result = append(result, byte(acc<<(to-bits)&maxv))
+		}
+	} else if bits >= from || acc<<(to-bits)&maxv != 0 {
+		return nil, errors.New("invalid bech32 padding")
+	}
+	return result, nil
+}
+
+func bech32Encode(hrp string, data []byte) string {
+	values, _ := bech32ConvertBits(data, 8, 5, true)
+	combined := append(bech32HRPExpand(hrp), values...)
+	combined = append(combined, 0, 0, 0, 0, 0, 0)
+	mod := bech32Polymod(combined) ^ 1
+	var b strings.Builder
+	b.WriteString(hrp)
+	b.WriteByte('1')
+	for _, value := range values {
+		b.WriteByte(bech32Charset[value])
+	}
+	for i := 0; i < 6; i++ {
+		b.WriteByte(bech32Charset[(mod>>uint(5*(5-i)))&31])
+	}
+	return b.String()
+}
+
+func bech32Decode(text string) (string, []byte, error) {
+	if strings.ToLower(text) != text && strings.ToUpper(text) != text {
+		return "", nil, errors.New("invalid bech32 key: mixed case")
+	}
+	text = strings.ToLower(text)
+	sep := strings.LastIndexByte(text, '1')
+	if sep < 1 || sep+7 > len(text) {
+		return "", nil, errors.New("invalid bech32 key")
+	}
+	hrp := text[:sep]
+	values := make([]byte, 0, len(text)-sep-1)
+	for i := sep + 1; i < len(text); i++ {
+		index := strings.IndexByte(bech32Charset, text[i])
+		if index < 0 {
+			return "", nil, fmt.Errorf("invalid bech32 character %q", text[i])
+		}
+		values = append(values, byte(index))
+	}
+	if bech32Polymod(append(bech32HRPExpand(hrp), values...)) != 1 {
+		return "", nil, errors.New("invalid bech32 checksum")
+	}
+	data, err := bech32ConvertBits(values[:len(values)-6], 5, 8, false)
+	if err != nil {
+		return "", nil, err
+	}
+	return hrp, data, nil
+}
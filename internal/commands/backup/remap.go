@@ -0,0 +1,388 @@
+package backup
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/moby/moby/api/types/container"
+	"github.com/moby/moby/api/types/mount"
+	"github.com/moby/moby/api/types/network"
+	"gopkg.in/yaml.v3"
+)
+
+// restoreRemapAll is the image remap key that applies to every container.
+const restoreRemapAll = "*"
+
+// RestoreRemap rewrites a backup while it is restored, so the same backup can
+// be restored next to the original or into a different environment. It can be
+// loaded from a YAML file and overridden by command line flags.
+type RestoreRemap struct {
+	Containers map[string]string `yaml:"containers,omitempty" json:"containers,omitempty"`
+	Networks   map[string]string `yaml:"networks,omitempty" json:"networks,omitempty"`
+	Volumes    map[string]string `yaml:"volumes,omitempty" json:"volumes,omitempty"`
+	Ports      RestorePortRemap  `yaml:"ports,omitempty" json:"ports,omitempty"`
+	Env        map[string]string `yaml:"env,omitempty" json:"env,omitempty"`
+	Images     map[string]string `yaml:"images,omitempty" json:"images,omitempty"`
+}
+
+// RestorePortRemap changes published host ports. Map entries take precedence
+// over Offset; container ports are never changed.
+type RestorePortRemap struct {
+	Offset int               `yaml:"offset,omitempty" json:"offset,omitempty"`
+	Map    map[string]string `yaml:"map,omitempty" json:"map,omitempty"`
+}
+
+// RestoreRemapFlags holds the raw remap flags of dm restore.
+type RestoreRemapFlags struct {
+	File       string
+	Containers []string
+	Networks   []string
+	Volumes    []string
+	PortOffset int
+	Ports      []string
+	Env        []string
+	Images     []string
+}
+
+func (r RestoreRemap) empty() bool {
+	return len(r.Containers) == 0 && len(r.Networks) == 0 && len(r.Volumes) == 0 &&
+		r.Ports.Offset == 0 && len(r.Ports.Map) == 0 && len(r.Env) == 0 && len(r.Images) == 0
+}
+
+// buildRestoreRemap loads the remap file and lets flags override its entries.
+func buildRestoreRemap(flags RestoreRemapFlags) (RestoreRemap, error) {
+	var remap RestoreRemap
+	if flags.File != "" {
+		data, err := os.ReadFile(flags.File)
+		if err != nil {
+			return remap, fmt.Errorf("read remap file: %w", err)
+		}
+		if err := yaml.Unmarshal(data, &remap); err != nil {
+			return remap, fmt.Errorf("parse remap file %s: %w", flags.File, err)
+		}
+	}
+	pairs := []struct {
+		flag   string
+		values []string
+		target *map[string]string
+	}{
+		{"--rename", flags.Containers, &remap.Containers},
+		{"--network", flags.Networks, &remap.Networks},
+		{"--volume", flags.Volumes, &remap.Volumes},
+		{"--port", flags.Ports, &remap.Ports.Map},
+		{"--env", flags.Env, &remap.Env},
+	}
+	for _, pair := range pairs {
+		for _, value := range pair.values {
+			key, val, ok := strings.Cut(value, "=")
+			if !ok || strings.TrimSpace(key) == "" {
+				return remap, fmt.Errorf("%s 需要 old=new 格式，当前为 %q", pair.flag, value)
+			}
+			if *pair.target == nil {
+				*pair.target = map[string]string{}
+			}
+			(*pair.target)[strings.TrimSpace(key)] = strings.TrimSpace(val)
+		}
+	}
+	for _, value := range flags.Images {
+		key, val, ok := strings.Cut(value, "=")
+		if !ok {
+			key, val = restoreRemapAll, value
+		}
+		if strings.TrimSpace(val) == "" {
+			return remap, fmt.Errorf("--image 需要 [old=]new 格式，当前为 %q", value)
+		}
+		if remap.Images == nil {
+			remap.Images = map[string]string{}
+		}
+		remap.Images[strings.TrimSpace(key)] = strings.TrimSpace(val)
+	}
+	if flags.PortOffset != 0 {
+		remap.Ports.Offset = flags.PortOffset
+	}
+	return remap, remap.validate()
+}
+
+func (r RestoreRemap) validate() error {
+	for kind, names := range map[string]map[string]string{"containers": r.Containers, "networks": r.Networks, "volumes": r.Volumes} {
+		for old, name := range names {
+			if name == "" {
+				return fmt.Errorf("remap %s: %s 的新名称不能为空", kind, old)
+			}
+		}
+	}
+	for old, name := range r.Containers {
+		if normalizeContainerName(name) != name || strings.ContainsAny(name, " \t") {
+			return fmt.Errorf("remap containers: %s 的新名称 %q 无效", old, name)
+		}
+	}
+	for old, port := range r.Ports.Map {
+		if _, err := strconv.ParseUint(old, 10, 16); err != nil {
+			return fmt.Errorf("remap ports: 无效的宿主机端口 %q", old)
+		}
+		if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+			return fmt.Errorf("remap ports: 无效的宿主机端口 %q", port)
+		}
+	}
+	for key := range r.Env {
+		if key == "" || strings.Contains(key, "=") {
+			return fmt.Errorf("remap env: 无效的变量名 %q", key)
+		}
+	}
+	return nil
+}
+
+// containerName returns the restore name of a backed up container.
+func (r RestoreRemap) containerName(name string) string {
+	if renamed, ok := r.Containers[name]; ok {
+		return renamed
+	}
+	return name
+}
+
+func (r RestoreRemap) networkName(name string) string {
+	if renamed, ok := r.Networks[name]; ok {
+		return renamed
+	}
+	return name
+}
+
+func (r RestoreRemap) volumeName(name string) string {
+	if renamed, ok := r.Volumes[name]; ok {
+		return renamed
+	}
+	return name
+}
+
+func (r RestoreRemap) image(ref string) (string, bool) {
+	if image, ok := r.Images[ref]; ok {
+		return image, true
+	}
+	if image, ok := r.Images[restoreRemapAll]; ok {
+		return image, true
+	}
+	return ref, false
+}
+
+func (r RestoreRemap) hostPort(port string) (string, error) {
+	if port == "" {
+		return port, nil
+	}
+	if mapped, ok := r.Ports.Map[port]; ok {
+		return mapped, nil
+	}
+	if r.Ports.Offset == 0 {
+		return port, nil
+	}
+	start, end, isRange := strings.Cut(port, "-")
+	shifted := make([]string, 0, 2)
+	for _, part := range []string{start, end} {
+		if part == "" {
+			continue
+		}
+		value, err := strconv.Atoi(part)
+		if err != nil {
+			return "", fmt.Errorf("无效的宿主机端口 %q", port)
+		}
+		value += r.Ports.Offset
+		if value < 1 || value > 65535 {
+			return "", fmt.Errorf("宿主机端口 %s 偏移 %d 后超出范围", port, r.Ports.Offset)
+		}
+		shifted = append(shifted, strconv.Itoa(value))
+	}
+	if isRange {
+		return strings.Join(shifted, "-"), nil
+	}
+	return shifted[0], nil
+}
+
+// applyRestoreRemap rewrites the manifest entry and container inspect of one
+// backed up container. It returns the remapped entry and inspect plus a
+// human-readable list of changes; environment values are never included.
+func applyRestoreRemap(entry BackupContainerManifest, inspect container.InspectResponse, remap RestoreRemap) (BackupContainerManifest, container.InspectResponse, []string, error) {
+	if remap.empty() {
+		return entry, inspect, nil, nil
+	}
+	var changes []string
+	note := func(kind, old, name string) {
+		if old != name {
+			changes = append(changes, fmt.Sprintf("%s %s -> %s", kind, old, name))
+		}
+	}
+
+	entry.Networks = append([]BackupResourceRef(nil), entry.Networks...)
+	for i, ref := range entry.Networks {
+		entry.Networks[i].Name = remap.networkName(ref.Name)
+		note("network", ref.Name, entry.Networks[i].Name)
+	}
+	entry.Volumes = append([]BackupResourceRef(nil), entry.Volumes...)
+	for i, ref := range entry.Volumes {
+		entry.Volumes[i].Name = remap.volumeName(ref.Name)
+		note("volume", ref.Name, entry.Volumes[i].Name)
+	}
+
+	imageRef := entry.Image
+	if imageRef == "" && inspect.Config != nil {
+		imageRef = inspect.Config.Image
+	}
+	image, imageRemapped := remap.image(imageRef)
+	if imageRemapped {
+		note("image", imageRef, image)
+		entry.Image = image
+		// The archived image is the old one; the new reference must
+		// already exist or be pullable on the target.
+		entry.ImageArchive = ""
+	}
+	if inspect.Config != nil && (imageRemapped || len(remap.Env) > 0) {
+		config := *inspect.Config
+		if imageRemapped {
+			config.Image = image
+		}
+		if len(remap.Env) > 0 {
+			config.Env = remapRestoreEnv(config.Env, remap.Env)
+			keys := make([]string, 0, len(remap.Env))
+			for key := range remap.Env {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			changes = append(changes, "env "+strings.Join(keys, ","))
+		}
+		inspect.Config = &config
+	}
+
+	if inspect.HostConfig != nil {
+		hostConfig := *inspect.HostConfig
+		if mode := string(hostConfig.NetworkMode); mode != "" {
+			if target, ok := strings.CutPrefix(mode, "container:"); ok {
+				hostConfig.NetworkMode = container.NetworkMode("container:" + remap.containerName(target))
+			} else {
+				hostConfig.NetworkMode = container.NetworkMode(remap.networkName(mode))
+			}
+		}
+		hostConfig.Binds = remapRestoreBinds(hostConfig.Binds, remap)
+		if len(hostConfig.Mounts) > 0 {
+			mounts := append([]mount.Mount(nil), hostConfig.Mounts...)
+			for i := range mounts {
+				if mounts[i].Type == mount.TypeVolume {
+					mounts[i].Source = remap.volumeName(mounts[i].Source)
+				}
+			}
+			hostConfig.Mounts = mounts
+		}
+		if len(hostConfig.VolumesFrom) > 0 {
+			volumesFrom := make([]string, len(hostConfig.VolumesFrom))
+			for i, from := range hostConfig.VolumesFrom {
+				name, mode, hasMode := strings.Cut(from, ":")
+				volumesFrom[i] = remap.containerName(name)
+				if hasMode {
+					volumesFrom[i] += ":" + mode
+				}
+			}
+			hostConfig.VolumesFrom = volumesFrom
+		}
+		if len(hostConfig.PortBindings) > 0 {
+			bindings, portChanges, err := remapRestorePortBindings(hostConfig.PortBindings, remap)
+			if err != nil {
+				return entry, inspect, nil, err
+			}
+			hostConfig.PortBindings = bindings
+			changes = append(changes, portChanges...)
+		}
+		inspect.HostConfig = &hostConfig
+	}
+
+	if len(inspect.Mounts) > 0 {
+		points := append([]container.MountPoint(nil), inspect.Mounts...)
+		for i := range points {
+			if points[i].Type == mount.TypeVolume {
+				points[i].Name = remap.volumeName(points[i].Name)
+			}
+		}
+		inspect.Mounts = points
+	}
+
+	if inspect.NetworkSettings != nil && len(inspect.NetworkSettings.Networks) > 0 {
+		settings := *inspect.NetworkSettings
+		endpoints := make(map[string]*network.EndpointSettings, len(settings.Networks))
+		for name, endpoint := range settings.Networks {
+			renamed := remap.networkName(name)
+			if endpoint != nil && renamed != name {
+				copied := *endpoint
+				// Fixed addresses belong to the original network's subnet.
+				copied.IPAMConfig = nil
+				endpoint = &copied
+			}
+			endpoints[renamed] = endpoint
+		}
+		settings.Networks = endpoints
+		inspect.NetworkSettings = &settings
+	}
+	return entry, inspect, changes, nil
+}
+
+func remapRestoreEnv(env []string, overrides map[string]string) []string {
+	result := make([]string, 0, len(env)+len(overrides))
+	seen := map[string]bool{}
+	for _, item := range env {
+		key, _, _ := strings.Cut(item, "=")
+		if value, ok := overrides[key]; ok {
+			result = append(result, key+"="+value)
+			seen[key] = true
+			continue
+		}
+		result = append(result, item)
+	}
+	keys := make([]string, 0, len(overrides))
+	for key := range overrides {
+		if !seen[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		result = append(result, key+"="+overrides[key])
+	}
+	return result
+}
+
+// remapRestoreBinds renames named volumes in -v style binds; host paths are
+// left to --bind-root.
+func remapRestoreBinds(binds []string, remap RestoreRemap) []string {
+	if len(binds) == 0 || len(remap.Volumes) == 0 {
+		return binds
+	}
+	result := make([]string, len(binds))
+	for i, bind := range binds {
+		source, rest, ok := strings.Cut(bind, ":")
+		if ok && !strings.HasPrefix(source, "/") && !strings.HasPrefix(source, ".") {
+			bind = remap.volumeName(source) + ":" + rest
+		}
+		result[i] = bind
+	}
+	return result
+}
+
+func remapRestorePortBindings(bindings network.PortMap, remap RestoreRemap) (network.PortMap, []string, error) {
+	result := make(network.PortMap, len(bindings))
+	var changes []string
+	for port, list := range bindings {
+		mapped := make([]network.PortBinding, len(list))
+		for i, binding := range list {
+			hostPort, err := remap.hostPort(binding.HostPort)
+			if err != nil {
+				return nil, nil, fmt.Errorf("remap port %s: %w", port, err)
+			}
+			if hostPort != binding.HostPort {
+				changes = append(changes, fmt.Sprintf("port %s/%s -> %s/%s", binding.HostPort, port.Proto(), hostPort, port.Proto()))
+			}
+			binding.HostPort = hostPort
+			mapped[i] = binding
+		}
+		result[port] = mapped
+	}
+	sort.Strings(changes)
+	return result, changes, nil
+}
@@ -0,0 +1,490 @@
+package backup
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"docker-manager/internal/version"
+)
+
+const (
+	repoScheme        = "repo://"
+	repoConfigName    = "config.json"
+	repoLockName      = "lock"
+	repoChunksDir     = "chunks"
+	repoSnapshotsDir  = "snapshots"
+	repoConfigVersion = 1
+
+	// Content-defined chunking keeps chunk boundaries stable when bytes are
+	// inserted or removed, so unchanged regions of image and volume tars
+	// dedupe across snapshots.
+	repoChunkMinSize = 512 * 1024
+	repoChunkMaxSize = 8 * 1024 * 1024
+	repoChunkAvgBits = 20
+)
+
+var repoGearTable = func() [256]uint64 {
+	var table [256]uint64
+	for i := range table {
+		sum := sha256.Sum256([]byte{'d', 'm', byte(i)})
+		table[i] = binary.LittleEndian.Uint64(sum[:8])
+	}
+	return table
+}()
+
+type repoConfig struct {
+	Version   int    `json:"version"`
+	CreatedAt string `json:"created_at"`
+	Chunker   string `json:"chunker"`
+}
+
+type backupRepo struct {
+	path string
+}
+
+func isRepoSource(path string) bool {
+	return strings.HasPrefix(path, repoScheme)
+}
+
+// parseRepoSource splits repo://<path>#<snapshot> into its parts.
+func parseRepoSource(source string) (string, string, error) {
+	rest := strings.TrimPrefix(source, repoScheme)
+	index := strings.LastIndex(rest, "#")
+	if index < 0 || index == len(rest)-1 {
+		return "", "", fmt.Errorf("%s 缺少快照，格式为 repo://<path>#<snapshot>", source)
+	}
+	if index == 0 {
+		return "", "", fmt.Errorf("%s 缺少仓库路径，格式为 repo://<path>#<snapshot>", source)
+	}
+	return rest[:index], rest[index+1:], nil
+}
+
+func repoSourceFor(repoPath, snapshotID string) string {
+	return repoScheme + repoPath + "#" + snapshotID
+}
+
+func initBackupRepo(path string) error {
+	if _, err := os.Stat(filepath.Join(path, repoConfigName)); err == nil {
+		return fmt.Errorf("仓库已存在: %s", path)
+	}
+	for _, dir := range []string{path, filepath.Join(path, repoChunksDir), filepath.Join(path, repoSnapshotsDir)} {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return err
+		}
+	}
+	return writeJSONFile(filepath.Join(path, repoConfigName), repoConfig{
+		Version:   repoConfigVersion,
+		CreatedAt: time.Now().Format(time.RFC3339),
+		Chunker:   fmt.Sprintf("gear-sha256 min=%d avg=%d max=%d", repoChunkMinSize, 1<<repoChunkAvgBits, repoChunkMaxSize),
+	})
+}
+
+func openBackupRepo(path string) (*backupRepo, error) {
+	var config repoConfig
+	if err := readJSON(filepath.Join(path, repoConfigName), &config); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%s 不是备份仓库；请先运行 dm backup repo init %s", path, path)
+		}
+		return nil, fmt.Errorf("read repo config: %w", err)
+	}
+	if config.Version != repoConfigVersion {
+		return nil, fmt.Errorf("unsupported backup repo version %d", config.Version)
+	}
+	return &backupRepo{path: path}, nil
+}
+
+// lock takes the repository write lock. Writers (backup, prune --apply)
+// must not run concurrently, otherwise GC could delete chunks of a
+// snapshot that is still being written.
+func (r *backupRepo) lock() (func(), error) {
+	lockPath := filepath.Join(r.path, repoLockName)
+	file, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		if errors.Is(err, os.ErrExist) {
+			owner, _ := os.ReadFile(lockPath)
+			return nil, fmt.Errorf("仓库 %s 已被锁定 (%s)；如确认没有其他 dm 进程在使用，请删除 %s", r.path, strings.TrimSpace(string(owner)), lockPath)
+		}
+		return nil, err
+	}
+	fmt.Fprintf(file, "pid=%d created_at=%s\n", os.Getpid(), time.Now().Format(time.RFC3339))
+	_ = file.Close()
+	return func() { _ = os.Remove(lockPath) }, nil
+}
+
+func (r *backupRepo) chunkPath(id string) string {
+	return filepath.Join(r.path, repoChunksDir, id[:2], id)
+}
+
+func (r *backupRepo) snapshots() ([]RepoSnapshot, error) {
+	entries, err := os.ReadDir(filepath.Join(r.path, repoSnapshotsDir))
+	if err != nil {
+		return nil, err
+	}
+	var snapshots []RepoSnapshot
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		var snapshot RepoSnapshot
+		if err := readJSON(filepath.Join(r.path, repoSnapshotsDir, entry.Name()), &snapshot); err != nil {
+			return nil, fmt.Errorf("read snapshot %s: %w", entry.Name(), err)
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		left, _ := time.Parse(time.RFC3339Nano, snapshots[i].CreatedAt)
+		right, _ := time.Parse(time.RFC3339Nano, snapshots[j].CreatedAt)
+		if !left.Equal(right) {
+			return left.After(right)
+		}
+		return snapshots[i].ID > snapshots[j].ID
+	})
+	return snapshots, nil
+}
+
+// findSnapshot resolves a snapshot ID prefix, "latest", or a container name
+// (the latest snapshot containing that container).
+func (r *backupRepo) findSnapshot(selector string) (RepoSnapshot, error) {
+	snapshots, err := r.snapshots()
+	if err != nil {
+		return RepoSnapshot{}, err
+	}
+	if len(snapshots) == 0 {
+		return RepoSnapshot{}, fmt.Errorf("仓库 %s 中没有快照", r.path)
+	}
+	if selector == "latest" {
+		return snapshots[0], nil
+	}
+	var matches []RepoSnapshot
+	for _, snapshot := range snapshots {
+		if strings.HasPrefix(snapshot.ID, selector) {
+			matches = append(matches, snapshot)
+		}
+	}
+	if len(matches) == 1 {
+		return matches[0], nil
+	}
+	if len(matches) > 1 {
+		return RepoSnapshot{}, fmt.Errorf("快照前缀 %q 匹配多个快照，请提供更长的 ID", selector)
+	}
+	for _, snapshot := range snapshots {
+		for _, name := range snapshot.Containers {
+			if name == normalizeContainerName(selector) {
+				return snapshot, nil
+			}
+		}
+	}
+	return RepoSnapshot{}, fmt.Errorf("仓库 %s 中没有匹配 %q 的快照", r.path, selector)
+}
+
+// storeSnapshot chunks every file under dir into the repository and writes
+// the snapshot last, so an interrupted store only leaves unreferenced chunks.
+func (r *backupRepo) storeSnapshot(ctx context.Context, dir string) (RepoSnapshot, error) {
+	manifest, err := readBackupManifest(dir)
+	if err != nil {
+		return RepoSnapshot{}, err
+	}
+	snapshot := RepoSnapshot{
+		CreatedAt: time.Now().Format(time.RFC3339Nano),
+		Tool:      version.CurrentInfo(),
+	}
+	for _, entry := range manifest.Containers {
+		snapshot.Containers = append(snapshot.Containers, entry.ContainerName)
+	}
+	err = filepath.WalkDir(dir, func(p string, entry os.DirEntry, walkErr error) error {
+		if err := checkBackupContext(ctx); err != nil {
+			return err
+		}
+		if walkErr != nil {
+			return walkErr
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		if !entry.Type().IsRegular() {
+			return fmt.Errorf("unsupported file type in backup: %s", p)
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		file := RepoFile{Path: filepath.ToSlash(rel), Mode: uint32(info.Mode().Perm())}
+		added, err := r.storeFile(ctx, p, &file)
+		if err != nil {
+			return fmt.Errorf("store %s: %w", rel, err)
+		}
+		snapshot.Files = append(snapshot.Files, file)
+		snapshot.Size += file.Size
+		snapshot.AddedBytes += added
+		return nil
+	})
+	if err != nil {
+		return RepoSnapshot{}, err
+	}
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return RepoSnapshot{}, err
+	}
+	sum := sha256.Sum256(data)
+	snapshot.ID = hex.EncodeToString(sum[:])[:16]
+	if err := writeJSONFile(filepath.Join(r.path, repoSnapshotsDir, snapshot.ID+".json"), snapshot); err != nil {
+		return RepoSnapshot{}, fmt.Errorf("write snapshot: %w", err)
+	}
+	log.Printf("Backup repo snapshot: repo=%s id=%s files=%d size=%d added=%d", r.path, snapshot.ID, len(snapshot.Files), snapshot.Size, snapshot.AddedBytes)
+	return snapshot, nil
+}
+
+func (r *backupRepo) storeFile(ctx context.Context, path string, file *RepoFile) (int64, error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+	var added int64
+	err = chunkRepoStream(ctx, in, func(chunk []byte) error {
+		sum := sha256.Sum256(chunk)
+		id := hex.EncodeToString(sum[:])
+		file.Chunks = append(file.Chunks, id)
+		file.Size += int64(len(chunk))
+		written, err := r.writeChunk(id, chunk)
+		if written {
+			added += int64(len(chunk))
+		}
+		return err
+	})
+	return added, err
+}
+
+func (r *backupRepo) writeChunk(id string, data []byte) (bool, error) {
+	target := r.chunkPath(id)
+	if _, err := os.Stat(target); err == nil {
+		return false, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+		return false, err
+	}
+	tmp := target + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return false, err
+	}
+	if err := os.Rename(tmp, target); err != nil {
+		_ = os.Remove(tmp)
+		return false, err
+	}
+	return true, nil
+}
+
+// chunkRepoStream splits r with a gear rolling hash; emit receives a buffer
+// that is only valid until it returns.
+func chunkRepoStream(ctx context.Context, r io.Reader, emit func([]byte) error) error {
+	const mask = uint64(1<<repoChunkAvgBits-1) << (64 - repoChunkAvgBits)
+	reader := bufio.NewReaderSize(r, 1024*1024)
+	buf := make([]byte, 0, repoChunkMaxSize)
+	var hash uint64
+	for {
+		b, err := reader.ReadByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		buf = append(buf, b)
+		hash = hash<<1 + repoGearTable[b]
+		if len(buf) < repoChunkMinSize {
+			continue
+		}
+		if hash&mask == 0 || len(buf) >= repoChunkMaxSize {
+			if err := checkBackupContext(ctx); err != nil {
+				return err
+			}
+			if err := emit(buf); err != nil {
+				return err
+			}
+			buf = buf[:0]
+			hash = 0
+		}
+	}
+	if len(buf) > 0 {
+		return emit(buf)
+	}
+	return nil
+}
+
+// materializeRepoSnapshot rebuilds a snapshot into a temporary backup
+// directory, verifying every chunk against its digest.
+func materializeRepoSnapshot(ctx context.Context, source string) (string, func(), error) {
+	repoPath, selector, err := parseRepoSource(source)
+	if err != nil {
+		return "", nil, err
+	}
+	repo, err := openBackupRepo(repoPath)
+	if err != nil {
+		return "", nil, err
+	}
+	snapshot, err := repo.findSnapshot(selector)
+	if err != nil {
+		return "", nil, err
+	}
+	tempDir, err := os.MkdirTemp("", "dm-restore-repo-*")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() { _ = os.RemoveAll(tempDir) }
+	for _, file := range snapshot.Files {
+		if err := repo.restoreFile(ctx, tempDir, file); err != nil {
+			cleanup()
+			return "", nil, fmt.Errorf("snapshot %s: %s: %w", snapshot.ID, file.Path, err)
+		}
+	}
+	log.Printf("Backup repo snapshot materialized: repo=%s id=%s files=%d", repoPath, snapshot.ID, len(snapshot.Files))
+	return tempDir, cleanup, nil
+}
+
+func (r *backupRepo) restoreFile(ctx context.Context, dir string, file RepoFile) error {
+	target, err := safeExtractPath(dir, file.Path)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, os.FileMode(file.Mode).Perm()|0200)
+	if err != nil {
+		return err
+	}
+	var written int64
+	for _, id := range file.Chunks {
+		if err := checkBackupContext(ctx); err != nil {
+			_ = out.Close()
+			return err
+		}
+		data, err := r.readChunk(id)
+		if err != nil {
+			_ = out.Close()
+			return err
+		}
+		if _, err := out.Write(data); err != nil {
+			_ = out.Close()
+			return err
+		}
+		written += int64(len(data))
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	if written != file.Size {
+		return fmt.Errorf("size mismatch: expected %d actual %d", file.Size, written)
+	}
+	return nil
+}
+
+func (r *backupRepo) readChunk(id string) ([]byte, error) {
+	if len(id) != sha256.Size*2 {
+		return nil, fmt.Errorf("invalid chunk id %q", id)
+	}
+	data, err := os.ReadFile(r.chunkPath(id))
+	if err != nil {
+		return nil, fmt.Errorf("read chunk %s: %w", id, err)
+	}
+	sum := sha256.Sum256(data)
+	if actual := hex.EncodeToString(sum[:]); actual != id {
+		return nil, fmt.Errorf("chunk %s is corrupted: actual sha256 %s", id, actual)
+	}
+	return data, nil
+}
+
+// backupContainersToRepo stages the backup in a temporary directory, then
+// stores each resulting backup directory as one repository snapshot.
+func backupContainersToRepo(ctx context.Context, targets []string, opts BackupOptions) (BackupContainersResult, error) {
+	repo, err := openBackupRepo(opts.Repo)
+	if err != nil {
+		return BackupContainersResult{}, err
+	}
+	stagingDir, err := os.MkdirTemp("", "dm-backup-repo-*")
+	if err != nil {
+		return BackupContainersResult{}, err
+	}
+	defer os.RemoveAll(stagingDir)
+	stageOpts := opts
+	stageOpts.OutputDir = filepath.Join(stagingDir, "backup")
+	staged, err := backupContainerTargets(ctx, targets, stageOpts)
+	if err != nil {
+		return BackupContainersResult{}, err
+	}
+	if opts.DryRun {
+		return staged, nil
+	}
+	unlock, err := repo.lock()
+	if err != nil {
+		return BackupContainersResult{}, err
+	}
+	defer unlock()
+	var result BackupContainersResult
+	for _, dir := range staged.Paths {
+		snapshot, err := repo.storeSnapshot(ctx, dir)
+		if err != nil {
+			return result, err
+		}
+		result.Paths = append(result.Paths, repoSourceFor(opts.Repo, snapshot.ID))
+	}
+	return result, nil
+}
+
+func repoSnapshotSummary(repoPath string, snapshot RepoSnapshot) RepoSnapshotSummary {
+	return RepoSnapshotSummary{
+		ID:         snapshot.ID,
+		CreatedAt:  snapshot.CreatedAt,
+		Containers: snapshot.Containers,
+		Files:      len(snapshot.Files),
+		Size:       snapshot.Size,
+		AddedBytes: snapshot.AddedBytes,
+		Restore:    repoSourceFor(repoPath, snapshot.ID),
+	}
+}
+
+func listRepoSnapshots(repoPath string, opts RepoListOptions) (RepoSnapshotList, error) {
+	repo, err := openBackupRepo(repoPath)
+	if err != nil {
+		return RepoSnapshotList{}, err
+	}
+	snapshots, err := repo.snapshots()
+	if err != nil {
+		return RepoSnapshotList{}, err
+	}
+	list := RepoSnapshotList{Repository: repoPath, Snapshots: []RepoSnapshotSummary{}}
+	for _, snapshot := range snapshots {
+		if opts.Container != "" && !repoSnapshotHasContainer(snapshot, opts.Container) {
+			continue
+		}
+		list.Snapshots = append(list.Snapshots, repoSnapshotSummary(repoPath, snapshot))
+	}
+	return list, nil
+}
+
+func repoSnapshotHasContainer(snapshot RepoSnapshot, name string) bool {
+	name = normalizeContainerName(name)
+	for _, candidate := range snapshot.Containers {
+		if candidate == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,255 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"docker-manager/internal/textfmt"
+)
+
+// planRepoRetention decides which snapshots to keep. Snapshots are grouped by
+// their container set and each group is evaluated newest first: keep-last
+// keeps the N newest, keep-daily/keep-weekly keep the newest snapshot of each
+// of the N most recent days/ISO weeks that have snapshots.
+func planRepoRetention(snapshots []RepoSnapshot, policy RepoRetentionPolicy) map[string][]string {
+	reasons := map[string][]string{}
+	groups := map[string][]RepoSnapshot{}
+	var keys []string
+	for _, snapshot := range snapshots {
+		if policy.Container != "" && !repoSnapshotHasContainer(snapshot, policy.Container) {
+			reasons[snapshot.ID] = append(reasons[snapshot.ID], "not-selected")
+			continue
+		}
+		names := append([]string(nil), snapshot.Containers...)
+		sort.Strings(names)
+		key := strings.Join(names, ",")
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], snapshot)
+	}
+	for _, key := range keys {
+		group := groups[key]
+		times := make([]time.Time, len(group))
+		for i, snapshot := range group {
+			created, err := time.Parse(time.RFC3339Nano, snapshot.CreatedAt)
+			if err != nil {
+				// Never delete what cannot be ordered.
+				reasons[snapshot.ID] = append(reasons[snapshot.ID], "invalid-time")
+				continue
+			}
+			times[i] = created.Local()
+		}
+		order := make([]int, len(group))
+		for i := range order {
+			order[i] = i
+		}
+		sort.SliceStable(order, func(a, b int) bool { return times[order[a]].After(times[order[b]]) })
+		for n, i := range order {
+			if n < policy.KeepLast {
+				reasons[group[i].ID] = append(reasons[group[i].ID], "last")
+			}
+		}
+		keepRepoBuckets(group, order, times, policy.KeepDaily, "daily", func(t time.Time) string { return t.Format("2006-01-02") }, reasons)
+		keepRepoBuckets(group, order, times, policy.KeepWeekly, "weekly", func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%d-W%02d", year, week)
+		}, reasons)
+	}
+	return reasons
+}
+
+func keepRepoBuckets(group []RepoSnapshot, order []int, times []time.Time, limit int, reason string, bucket func(time.Time) string, reasons map[string][]string) {
+	if limit <= 0 {
+		return
+	}
+	last := ""
+	kept := 0
+	for _, i := range order {
+		if times[i].IsZero() {
+			continue
+		}
+		key := bucket(times[i])
+		if key == last {
+			continue
+		}
+		last = key
+		if kept >= limit {
+			return
+		}
+		reasons[group[i].ID] = append(reasons[group[i].ID], reason)
+		kept++
+	}
+}
+
+// collectRepoGarbage lists chunk files not referenced by any of the given
+// snapshots, including temporary files left by interrupted writes.
+func (r *backupRepo) collectRepoGarbage(ctx context.Context, snapshots []RepoSnapshot) (RepoGCSummary, []string, error) {
+	referenced := map[string]bool{}
+	for _, snapshot := range snapshots {
+		for _, file := range snapshot.Files {
+			for _, id := range file.Chunks {
+				referenced[id] = true
+			}
+		}
+	}
+	summary := RepoGCSummary{ReferencedChunks: len(referenced)}
+	var garbage []string
+	err := filepath.WalkDir(filepath.Join(r.path, repoChunksDir), func(p string, entry os.DirEntry, walkErr error) error {
+		if err := checkBackupContext(ctx); err != nil {
+			return err
+		}
+		if walkErr != nil {
+			return walkErr
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		if referenced[entry.Name()] {
+			summary.Chunks++
+			return nil
+		}
+		if !strings.HasSuffix(entry.Name(), ".tmp") {
+			summary.Chunks++
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		summary.UnreferencedFiles++
+		summary.ReclaimableBytes += info.Size()
+		garbage = append(garbage, p)
+		return nil
+	})
+	return summary, garbage, err
+}
+
+func pruneBackupRepo(ctx context.Context, repoPath string, opts RepoPruneOptions) (RepoPruneReport, error) {
+	ctx = backupContext(ctx)
+	if err := checkBackupContext(ctx); err != nil {
+		return RepoPruneReport{}, err
+	}
+	policy := RepoRetentionPolicy{
+		Container:  normalizeContainerName(opts.Container),
+		KeepLast:   opts.KeepLast,
+		KeepDaily:  opts.KeepDaily,
+		KeepWeekly: opts.KeepWeekly,
+	}
+	if policy.KeepLast < 0 || policy.KeepDaily < 0 || policy.KeepWeekly < 0 {
+		return RepoPruneReport{}, fmt.Errorf("--keep-* 不能为负数")
+	}
+	hasPolicy := policy.KeepLast > 0 || policy.KeepDaily > 0 || policy.KeepWeekly > 0
+	if !opts.GCOnly && !hasPolicy {
+		return RepoPruneReport{}, fmt.Errorf("至少需要一个 --keep-last、--keep-daily 或 --keep-weekly 策略")
+	}
+	if opts.Apply && !opts.Confirm {
+		return RepoPruneReport{}, fmt.Errorf("--apply 会删除备份仓库中的快照和数据块；如确认执行，请添加 --confirm")
+	}
+	repo, err := openBackupRepo(repoPath)
+	if err != nil {
+		return RepoPruneReport{}, err
+	}
+	if opts.Apply {
+		unlock, err := repo.lock()
+		if err != nil {
+			return RepoPruneReport{}, err
+		}
+		defer unlock()
+	}
+	snapshots, err := repo.snapshots()
+	if err != nil {
+		return RepoPruneReport{}, err
+	}
+	report := RepoPruneReport{Repository: repoPath, Policy: policy}
+	remaining := snapshots
+	var removed []RepoSnapshot
+	if !opts.GCOnly {
+		reasons := planRepoRetention(snapshots, policy)
+		remaining = nil
+		for _, snapshot := range snapshots {
+			summary := repoSnapshotSummary(repoPath, snapshot)
+			summary.Reasons = reasons[snapshot.ID]
+			if len(summary.Reasons) > 0 {
+				remaining = append(remaining, snapshot)
+				report.Keep = append(report.Keep, summary)
+				continue
+			}
+			removed = append(removed, snapshot)
+			report.Remove = append(report.Remove, summary)
+		}
+	}
+	gc, garbage, err := repo.collectRepoGarbage(ctx, remaining)
+	if err != nil {
+		return report, err
+	}
+	report.GC = gc
+	if !opts.Apply {
+		return report, nil
+	}
+	for _, snapshot := range removed {
+		if err := os.Remove(filepath.Join(repo.path, repoSnapshotsDir, snapshot.ID+".json")); err != nil {
+			return report, fmt.Errorf("remove snapshot %s: %w", snapshot.ID, err)
+		}
+	}
+	for _, path := range garbage {
+		if err := checkBackupContext(ctx); err != nil {
+			return report, err
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return report, err
+		}
+	}
+	report.Applied = true
+	log.Printf("Backup repo prune: repo=%s removed_snapshots=%d removed_files=%d reclaimed=%d", repoPath, len(removed), len(garbage), gc.ReclaimableBytes)
+	return report, nil
+}
+
+func printRepoSnapshotList(w io.Writer, list RepoSnapshotList) {
+	fmt.Fprintf(w, "备份仓库: %s\n", list.Repository)
+	if len(list.Snapshots) == 0 {
+		fmt.Fprintln(w, "没有快照")
+		return
+	}
+	for _, snapshot := range list.Snapshots {
+		printRepoSnapshotSummary(w, snapshot)
+	}
+}
+
+func printRepoSnapshotSummary(w io.Writer, snapshot RepoSnapshotSummary) {
+	fmt.Fprintf(w, "  - %s %s containers=%s files=%d size=%s added=%s", snapshot.ID, snapshot.CreatedAt, strings.Join(snapshot.Containers, ","), snapshot.Files, textfmt.SignedBytes(snapshot.Size), textfmt.SignedBytes(snapshot.AddedBytes))
+	if len(snapshot.Reasons) > 0 {
+		fmt.Fprintf(w, " keep=%s", strings.Join(snapshot.Reasons, ","))
+	}
+	fmt.Fprintln(w)
+}
+
+func printRepoPruneReport(w io.Writer, report RepoPruneReport) {
+	fmt.Fprintf(w, "备份仓库清理: %s\n", report.Repository)
+	policy := report.Policy
+	fmt.Fprintf(w, "保留策略: keep-last=%d keep-daily=%d keep-weekly=%d", policy.KeepLast, policy.KeepDaily, policy.KeepWeekly)
+	if policy.Container != "" {
+		fmt.Fprintf(w, " container=%s", policy.Container)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "保留快照: %d\n", len(report.Keep))
+	for _, snapshot := range report.Keep {
+		printRepoSnapshotSummary(w, snapshot)
+	}
+	fmt.Fprintf(w, "删除快照: %d\n", len(report.Remove))
+	for _, snapshot := range report.Remove {
+		printRepoSnapshotSummary(w, snapshot)
+	}
+	fmt.Fprintf(w, "数据块: 总数=%d 引用=%d 未引用文件=%d 可回收=%s\n", report.GC.Chunks, report.GC.ReferencedChunks, report.GC.UnreferencedFiles, textfmt.SignedBytes(report.GC.ReclaimableBytes))
+	if report.Applied {
+		fmt.Fprintln(w, "已执行清理")
+	} else {
+		fmt.Fprintln(w, "预览模式；添加 --apply --confirm 执行清理")
+	}
+}
@@ -10,6 +10,8 @@ import (
 	"sort"
 	"strings"
 
+	"docker-manager/internal/docker"
+
 	"github.com/moby/moby/api/types/container"
 	"github.com/moby/moby/api/types/network"
 )
@@ -29,6 +31,7 @@ func restoreBackup(ctx context.Context, backupDir string, opts RestoreOptions) e
 	if cleanup != nil {
 		defer cleanup()
 	}
+	source := backupDir
 	backupDir = resolvedDir
 
 	if !opts.SkipChecksum {
@@ -43,6 +46,21 @@ func restoreBackup(ctx context.Context, backupDir string, opts RestoreOptions) e
 		log.Printf("Skip checksum verification: %s", backupDir)
 	}
 
+	chainDir, parents, chainCleanup, err := resolveRestoreBackupChain(ctx, backupDir, source, opts)
+	if err != nil {
+		return err
+	}
+	if chainCleanup != nil {
+		defer chainCleanup()
+	}
+	if len(parents) > 0 {
+		log.Printf("Restore incremental backup: source=%s parents=%s", source, strings.Join(parents, ","))
+		if opts.DryRun {
+			fmt.Fprintf(opts.Output, "增量备份链: %s <- %s\n", source, strings.Join(parents, " <- "))
+		}
+	}
+	backupDir = chainDir
+
 	if err := checkBackupContext(ctx); err != nil {
 		return err
 	}
@@ -103,6 +121,10 @@ func restoreBackupContainerEntry(ctx context.Context, svc backupDockerService, b
 	if err != nil {
 		return err
 	}
+	entry, inspect, remapped, err := applyRestoreRemap(entry, inspect, opts.Remap)
+	if err != nil {
+		return err
+	}
 	if err := checkBackupContext(ctx); err != nil {
 		return err
 	}
@@ -113,6 +135,9 @@ func restoreBackupContainerEntry(ctx context.Context, svc backupDockerService, b
 	if targetName == "" {
 		targetName = normalizeContainerName(inspect.Name)
 	}
+	if opts.Name == "" {
+		targetName = opts.Remap.containerName(targetName)
+	}
 	if targetName == "" {
 		return fmt.Errorf("backup does not contain a container name; use --name")
 	}
@@ -135,6 +160,7 @@ func restoreBackupContainerEntry(ctx context.Context, svc backupDockerService, b
 		if err != nil {
 			return err
 		}
+		plan.Remapped = remapped
 		printRestoreDryRunContainerPlan(opts.Output, plan)
 		log.Printf("Dry run restore: backup=%s container=%s replace=%v noStart=%v image=%v networks=%d volumes=%d", entryDir, targetName, opts.Replace, opts.NoStart, plan.ImageArchive != "", len(plan.Networks), len(plan.Volumes))
 		return nil
@@ -174,9 +200,39 @@ func restoreBackupContainerEntry(ctx context.Context, svc backupDockerService, b
 		if err != nil {
 			return err
 		}
-		if err := svc.CreateVolume(ctx, volMeta); err != nil {
+		created, err := svc.CreateVolume(ctx, volMeta)
+		if err != nil {
 			return fmt.Errorf("restore volume %s: %w", ref.Name, err)
 		}
+		if ref.Data == "" {
+			continue
+		}
+		// Existing volumes may hold newer data than the backup, so only
+		// volumes created by this restore are populated.
+		if !created {
+			log.Printf("Skip volume data for existing volume: %s", ref.Name)
+			continue
+		}
+		dataPath, err := backupFilePath(entryDir, ref.Data)
+		if err != nil {
+			return err
+		}
+		if err := svc.ImportVolume(ctx, ref.Name, restoreVolumeHelperImage(inspect, opts.VolumeHelperImage), dataPath); err != nil {
+			return fmt.Errorf("restore volume %s data: %w", ref.Name, err)
+		}
+	}
+
+	if opts.BindRoot != "" {
+		if err := checkBackupContext(ctx); err != nil {
+			return err
+		}
+		if docker.IsRemoteEndpoint() {
+			return fmt.Errorf("--bind-root 写入本机文件系统，不能用于远程 Docker endpoint %s", docker.Endpoint())
+		}
+		inspect, err = restoreBindMountData(ctx, opts.Output, entryDir, entry.Mounts, inspect, opts.BindRoot, opts.OverwriteBindData)
+		if err != nil {
+			return err
+		}
 	}
 
 	// Destructive replacement is intentionally delayed until all restorable
@@ -215,6 +271,7 @@ func buildRestoreDryRunPlan(entryDir string, entry BackupContainerManifest, insp
 		Networks:      append([]BackupResourceRef(nil), entry.Networks...),
 		Volumes:       append([]BackupResourceRef(nil), entry.Volumes...),
 		Ports:         restorePortBindings(inspect),
+		BindMounts:    restoreBindPlans(entryDir, entry.Mounts, opts.BindRoot, opts.OverwriteBindData),
 		Exists:        exists,
 		Replace:       opts.Replace,
 		NoStart:       opts.NoStart,
@@ -241,10 +298,22 @@ func buildRestoreDryRunPlan(entryDir string, entry BackupContainerManifest, insp
 		if _, err := readVolumeInspect(entryDir, ref); err != nil {
 			return plan, err
 		}
+		if ref.Data == "" {
+			continue
+		}
+		if err := checkRestoreDataFile(entryDir, ref.Data); err != nil {
+			return plan, err
+		}
+	}
+	for _, bind := range plan.BindMounts {
+		if bind.Error != "" {
+			return plan, fmt.Errorf("bind data %s: %s", bind.Data, bind.Error)
+		}
 	}
 	if exists && !opts.Replace {
 		plan.Conflicts = append(plan.Conflicts, fmt.Sprintf("目标容器 %s 已存在；实际恢复需要 --replace 或更换 --name", targetName))
 	}
+	plan.Conflicts = append(plan.Conflicts, restoreBindWarnings(plan.BindMounts)...)
 	if len(plan.Ports) > 0 {
 		plan.Conflicts = append(plan.Conflicts, "请确认目标宿主机端口未被占用: "+strings.Join(plan.Ports, ", "))
 	}
@@ -269,9 +338,22 @@ func printRestoreDryRunContainerPlan(w io.Writer, plan restoreDryRunPlan) {
 	if len(plan.Volumes) > 0 {
 		fmt.Fprintf(w, "    将创建/复用 volume: %s\n", resourceRefNames(plan.Volumes))
 	}
+	if names := volumeDataRefNames(plan.Volumes); names != "" {
+		fmt.Fprintf(w, "    将导入 volume 数据 (仅新建 volume): %s\n", names)
+	}
+	for _, bind := range plan.BindMounts {
+		if bind.Target == "" {
+			fmt.Fprintf(w, "    bind 数据: %s (未指定 --bind-root，跳过)\n", bind.Source)
+			continue
+		}
+		fmt.Fprintf(w, "    将写入 bind 数据: %s -> %s action=%s\n", bind.Source, bind.Target, bind.Action)
+	}
 	if len(plan.Ports) > 0 {
 		fmt.Fprintf(w, "    端口绑定: %s\n", strings.Join(plan.Ports, ", "))
 	}
+	if len(plan.Remapped) > 0 {
+		fmt.Fprintf(w, "    重映射: %s\n", strings.Join(plan.Remapped, "; "))
+	}
 	switch {
 	case plan.Exists && plan.Replace:
 		fmt.Fprintln(w, "    目标状态: 已存在，实际恢复会先删除后重建")
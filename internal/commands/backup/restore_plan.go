@@ -34,11 +34,22 @@ func buildRestorePlanReport(ctx context.Context, backupPath string, opts Restore
 			return RestorePlanReport{}, fmt.Errorf("verify checksums: %w", err)
 		}
 	}
+	chainDir, parents, chainCleanup, err := resolveRestoreBackupChain(ctx, resolvedDir, backupPath, opts)
+	if err != nil {
+		return RestorePlanReport{}, err
+	}
+	if chainCleanup != nil {
+		defer chainCleanup()
+	}
 	svc, err := newBackupDockerService()
 	if err != nil {
 		return RestorePlanReport{}, err
 	}
-	return buildRestorePlanReportFromDir(ctx, svc, resolvedDir, backupPath, checksumText, opts)
+	report, err := buildRestorePlanReportFromDir(ctx, svc, chainDir, backupPath, checksumText, opts)
+	if len(parents) > 0 {
+		report.Chain = parents
+	}
+	return report, err
 }
 
 func buildRestorePlanReportFromDir(ctx context.Context, svc backupDockerService, backupDir, source string, checksumText string, opts RestoreOptions) (RestorePlanReport, error) {
@@ -58,11 +69,15 @@ func buildRestorePlanReportFromDir(ctx context.Context, svc backupDockerService,
 		Checksum:       checksumText,
 		ContainerCount: len(manifest.Containers),
 		Options: RestorePlanOptions{
-			Replace: opts.Replace,
-			NoStart: opts.NoStart,
-			Name:    opts.Name,
+			Replace:  opts.Replace,
+			NoStart:  opts.NoStart,
+			Name:     opts.Name,
+			BindRoot: opts.BindRoot,
 		},
 	}
+	if opts.BindRoot != "" && docker.IsRemoteEndpoint() {
+		report.Warnings = append(report.Warnings, "--bind-root 写入的是本机文件系统，而目标 Docker 是远程 endpoint；实际恢复会被拒绝")
+	}
 	ports, portWarnings := currentRestorePortBindings(ctx, svc)
 	report.Warnings = append(report.Warnings, portWarnings...)
 	plans := make([]RestoreContainerPlan, len(manifest.Containers))
@@ -97,6 +112,10 @@ func buildRestoreContainerPlan(ctx context.Context, svc backupDockerService, bac
 	if err != nil {
 		return RestoreContainerPlan{}, err
 	}
+	entry, inspect, remapped, err := applyRestoreRemap(entry, inspect, opts.Remap)
+	if err != nil {
+		return RestoreContainerPlan{}, err
+	}
 	targetName := opts.Name
 	if targetName == "" {
 		targetName = entry.ContainerName
@@ -104,6 +123,9 @@ func buildRestoreContainerPlan(ctx context.Context, svc backupDockerService, bac
 	if targetName == "" {
 		targetName = normalizeContainerName(inspect.Name)
 	}
+	if opts.Name == "" {
+		targetName = opts.Remap.containerName(targetName)
+	}
 	if targetName == "" {
 		return RestoreContainerPlan{}, fmt.Errorf("backup does not contain a container name; use --name")
 	}
@@ -116,16 +138,24 @@ func buildRestoreContainerPlan(ctx context.Context, svc backupDockerService, bac
 		SourceName:    entry.ContainerName,
 		EntryDir:      entryDir,
 		Ports:         restorePortBindings(inspect),
+		Remapped:      remapped,
 		Container:     restoreTargetPlan(exists, opts),
 	}
 	plan.Image = restoreImagePlan(ctx, svc, entryDir, entry, inspect)
 	plan.Networks = restoreNetworkPlans(ctx, svc, entryDir, entry.Networks)
 	plan.Volumes = restoreVolumePlans(ctx, svc, entryDir, entry.Volumes)
+	plan.BindMounts = restoreBindPlans(entryDir, entry.Mounts, opts.BindRoot, opts.OverwriteBindData)
 	plan.PortConflicts = restorePortConflicts(inspect, existingPorts, targetName)
 	plan.Actions = restoreContainerActions(plan, opts)
 	if exists && !opts.Replace {
 		plan.Warnings = append(plan.Warnings, fmt.Sprintf("目标容器 %s 已存在；实际恢复需要 --replace 或改用 --name", targetName))
 	}
+	for _, vol := range plan.Volumes {
+		if vol.DataAction == "skip-existing" {
+			plan.Warnings = append(plan.Warnings, fmt.Sprintf("volume %s 已存在；备份中的 volume 数据不会导入，现有数据保持不变", vol.Name))
+		}
+	}
+	plan.Warnings = append(plan.Warnings, restoreBindWarnings(plan.BindMounts)...)
 	if len(plan.PortConflicts) > 0 {
 		plan.Warnings = append(plan.Warnings, "存在端口冲突，实际恢复前需要释放端口或调整容器配置")
 	}
@@ -213,7 +243,7 @@ func restoreVolumePlans(ctx context.Context, svc backupDockerService, entryDir s
 	plans := make([]RestoreResourcePlan, len(refs))
 	parallel.ForEachIndex(ctx, len(refs), backupInspectConcurrency, func(ctx context.Context, i int) {
 		ref := refs[i]
-		plan := RestoreResourcePlan{Name: ref.Name, File: ref.File, Action: "create"}
+		plan := RestoreResourcePlan{Name: ref.Name, File: ref.File, Data: ref.Data, Action: "create"}
 		expected, err := readVolumeInspect(entryDir, ref)
 		if err != nil {
 			plan.Action = "error"
@@ -221,6 +251,16 @@ func restoreVolumePlans(ctx context.Context, svc backupDockerService, entryDir s
 			plans[i] = plan
 			return
 		}
+		if ref.Data != "" {
+			plan.DataAction = "populate"
+			if err := checkRestoreDataFile(entryDir, ref.Data); err != nil {
+				plan.Action = "error"
+				plan.DataAction = "error"
+				plan.Error = err.Error()
+				plans[i] = plan
+				return
+			}
+		}
 		actual, err := svc.InspectVolume(ctx, ref.Name)
 		if err != nil {
 			if cerrdefs.IsNotFound(err) {
@@ -234,6 +274,9 @@ func restoreVolumePlans(ctx context.Context, svc backupDockerService, entryDir s
 		}
 		plan.Exists = true
 		plan.Action = "reuse"
+		if ref.Data != "" {
+			plan.DataAction = "skip-existing"
+		}
 		plan.Differences = compareRestoreVolume(expected, actual)
 		if len(plan.Differences) > 0 {
 			plan.Different = true
@@ -244,6 +287,36 @@ func restoreVolumePlans(ctx context.Context, svc backupDockerService, entryDir s
 	return plans
 }
 
+func restoreBindWarnings(plans []RestoreBindPlan) []string {
+	var warnings []string
+	var skipped []string
+	for _, plan := range plans {
+		switch plan.Action {
+		case "overwrite":
+			warnings = append(warnings, fmt.Sprintf("将覆盖已存在的 bind 路径 %s", plan.Target))
+		case "keep":
+			warnings = append(warnings, fmt.Sprintf("bind 路径 %s 已存在且非空，未指定 --overwrite-bind-data 时保留原内容", plan.Target))
+		case "skip":
+			skipped = append(skipped, plan.Source)
+		}
+	}
+	if len(skipped) > 0 {
+		warnings = append(warnings, "备份包含 bind 数据，未指定 --bind-root 时不会写入: "+strings.Join(skipped, ", "))
+	}
+	return warnings
+}
+
+func checkRestoreDataFile(entryDir, rel string) error {
+	dataPath, err := backupFilePath(entryDir, rel)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(dataPath); err != nil {
+		return fmt.Errorf("backup data %s: %w", rel, err)
+	}
+	return nil
+}
+
 func restoreTargetPlan(exists bool, opts RestoreOptions) RestoreTargetPlan {
 	switch {
 	case exists && opts.Replace:
@@ -269,6 +342,14 @@ func restoreContainerActions(plan RestoreContainerPlan, opts RestoreOptions) []s
 		if vol.Action == "create" {
 			actions = append(actions, "create-volume:"+vol.Name)
 		}
+		if vol.DataAction == "populate" {
+			actions = append(actions, "populate-volume:"+vol.Name)
+		}
+	}
+	for _, bind := range plan.BindMounts {
+		if bind.Action == "write" || bind.Action == "overwrite" {
+			actions = append(actions, "write-bind:"+bind.Target)
+		}
 	}
 	if plan.Container.Action == "replace" {
 		actions = append(actions, "remove-container:"+plan.ContainerName)
@@ -377,7 +458,10 @@ func printRestorePlanReport(w io.Writer, report RestorePlanReport) {
 		fmt.Fprintf(w, "目标 Docker: %s\n", report.DockerEndpoint)
 	}
 	fmt.Fprintf(w, "容器数量: %d checksum=%s\n", report.ContainerCount, report.Checksum)
-	fmt.Fprintf(w, "摘要: 镜像导入=%d 已存在镜像=%d network创建=%d 已存在network=%d 差异network=%d volume创建=%d 已存在volume=%d 差异volume=%d 容器创建=%d 替换=%d 冲突=%d 端口冲突=%d\n\n",
+	if len(report.Chain) > 0 {
+		fmt.Fprintf(w, "增量基线: %s\n", strings.Join(report.Chain, " <- "))
+	}
+	fmt.Fprintf(w, "摘要: 镜像导入=%d 已存在镜像=%d network创建=%d 已存在network=%d 差异network=%d volume创建=%d 已存在volume=%d 差异volume=%d volume数据导入=%d bind写入=%d bind覆盖=%d 容器创建=%d 替换=%d 冲突=%d 端口冲突=%d\n\n",
 		report.Summary.ImagesToLoad,
 		report.Summary.ImagesPresent,
 		report.Summary.NetworksToCreate,
@@ -386,6 +470,9 @@ func printRestorePlanReport(w io.Writer, report RestorePlanReport) {
 		report.Summary.VolumesToCreate,
 		report.Summary.VolumesPresent,
 		report.Summary.VolumesDifferent,
+		report.Summary.VolumesToPopulate,
+		report.Summary.BindPathsToWrite,
+		report.Summary.BindPathsOverwrite,
 		report.Summary.ContainersToCreate,
 		report.Summary.ContainersToReplace,
 		report.Summary.ContainerConflicts,
@@ -416,9 +503,13 @@ func printRestoreContainerPlan(w io.Writer, plan RestoreContainerPlan) {
 	}
 	printRestoreResourcePlans(w, "network", plan.Networks)
 	printRestoreResourcePlans(w, "volume", plan.Volumes)
+	printRestoreBindPlans(w, plan.BindMounts)
 	if len(plan.Ports) > 0 {
 		fmt.Fprintf(w, "  端口: %s\n", strings.Join(plan.Ports, ", "))
 	}
+	if len(plan.Remapped) > 0 {
+		fmt.Fprintf(w, "  重映射: %s\n", strings.Join(plan.Remapped, "; "))
+	}
 	for _, conflict := range plan.PortConflicts {
 		fmt.Fprintf(w, "  端口冲突: %s 已被 %s 使用\n", conflict.Port, conflict.Container)
 	}
@@ -433,6 +524,9 @@ func printRestoreContainerPlan(w io.Writer, plan RestoreContainerPlan) {
 func printRestoreResourcePlans(w io.Writer, kind string, plans []RestoreResourcePlan) {
 	for _, plan := range plans {
 		fmt.Fprintf(w, "  %s: %s exists=%v action=%s", kind, plan.Name, plan.Exists, plan.Action)
+		if plan.DataAction != "" {
+			fmt.Fprintf(w, " data=%s data_action=%s", plan.Data, plan.DataAction)
+		}
 		if plan.Error != "" {
 			fmt.Fprintf(w, " error=%s", plan.Error)
 		}
@@ -442,3 +536,17 @@ func printRestoreResourcePlans(w io.Writer, kind string, plans []RestoreResource
 		}
 	}
 }
+
+func printRestoreBindPlans(w io.Writer, plans []RestoreBindPlan) {
+	for _, plan := range plans {
+		fmt.Fprintf(w, "  bind: %s", plan.Source)
+		if plan.Target != "" {
+			fmt.Fprintf(w, " -> %s", plan.Target)
+		}
+		fmt.Fprintf(w, " exists=%v action=%s", plan.Exists, plan.Action)
+		if plan.Error != "" {
+			fmt.Fprintf(w, " error=%s", plan.Error)
+		}
+		fmt.Fprintln(w)
+	}
+}
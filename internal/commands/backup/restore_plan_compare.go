@@ -90,6 +90,18 @@ func addRestorePlanSummary(summary *RestorePlanSummary, plan RestoreContainerPla
 		if vol.Different {
 			summary.VolumesDifferent++
 		}
+		if vol.DataAction == "populate" {
+			summary.VolumesToPopulate++
+		}
+	}
+	for _, bind := range plan.BindMounts {
+		switch bind.Action {
+		case "write":
+			summary.BindPathsToWrite++
+		case "overwrite":
+			summary.BindPathsToWrite++
+			summary.BindPathsOverwrite++
+		}
 	}
 	switch plan.Container.Action {
 	case "replace":
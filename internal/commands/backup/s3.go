@@ -0,0 +1,143 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"docker-manager/internal/objectstore"
+)
+
+// resolveS3BackupDir streams an archive (all parts of a split archive) from
+// object storage through decryption and extraction. Nothing but the
+// extracted backup is written to disk, so a restore needs free space for the
+// unpacked backup only, not for another copy of the archive.
+func resolveS3BackupDir(ctx context.Context, source string, opts RestoreOptions) (string, func(), error) {
+	if !isBackupArchive(source) && !isBackupArchivePart(source) && !isEncryptedBackupArchive(source) {
+		return "", nil, fmt.Errorf("s3 备份必须是 .tar.gz、.tgz、.enc 或 .part-001 离线包: %s", source)
+	}
+	client, err := objectstore.NewClient(opts.S3)
+	if err != nil {
+		return "", nil, err
+	}
+	bucket, key, err := objectstore.ParseURL(source)
+	if err != nil {
+		return "", nil, err
+	}
+	tempDir, err := os.MkdirTemp("", "dm-restore-*")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() { _ = os.RemoveAll(tempDir) }
+
+	body := &s3BackupArchiveReader{ctx: ctx, client: client, bucket: bucket, key: key, split: isBackupArchivePart(key)}
+	defer body.Close()
+	var archive io.Reader = body
+	if isEncryptedBackupArchive(strings.TrimSuffix(key, backupArchiveSuffixPart)) {
+		keys, err := loadBackupDecryptionKeys(opts.PassphraseFile, opts.IdentityFiles)
+		if err != nil {
+			cleanup()
+			return "", nil, err
+		}
+		pr, pw := io.Pipe()
+		decrypted := make(chan struct{})
+		go func() {
+			pw.CloseWithError(decryptBackupArchiveStream(ctx, body, pw, keys))
+			close(decrypted)
+		}()
+		// 先关闭管道让解密协程退出，再由外层关闭对象流
+		defer func() {
+			_ = pr.Close()
+			<-decrypted
+		}()
+		archive = pr
+	}
+	if err := extractBackupArchiveStream(ctx, archive, tempDir); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	// tar 结束标记之后可能还有未读完的数据；读到末尾才能让解密校验最后一个数据块
+	if _, err := io.Copy(io.Discard, archive); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	log.Printf("Backup streamed: source=%s parts=%d size=%d", source, body.parts, body.size)
+	root, err := findExtractedBackupRoot(tempDir)
+	if err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return root, cleanup, nil
+}
+
+// s3BackupArchiveReader reads an archive object, or the parts of a split
+// archive in order, as one stream. Parts are requested only when the
+// previous one has been consumed.
+type s3BackupArchiveReader struct {
+	ctx     context.Context
+	client  *objectstore.Client
+	bucket  string
+	key     string
+	split   bool
+	current io.ReadCloser
+	parts   int
+	size    int64
+	done    bool
+}
+
+func (r *s3BackupArchiveReader) Read(p []byte) (int, error) {
+	for {
+		if r.done {
+			return 0, io.EOF
+		}
+		if r.current == nil {
+			if err := r.openNext(); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		n, err := r.current.Read(p)
+		r.size += int64(n)
+		if err == io.EOF {
+			_ = r.current.Close()
+			r.current = nil
+			r.done = !r.split
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+func (r *s3BackupArchiveReader) openNext() error {
+	key := r.key
+	if r.split {
+		key = splitPartPath(strings.TrimSuffix(r.key, backupArchiveSuffixPart), r.parts+1)
+	}
+	body, _, err := r.client.Get(r.ctx, r.bucket, key)
+	if errors.Is(err, os.ErrNotExist) && r.split && r.parts > 0 {
+		r.done = true
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	r.current = body
+	r.parts++
+	return nil
+}
+
+func (r *s3BackupArchiveReader) Close() error {
+	if r.current == nil {
+		return nil
+	}
+	err := r.current.Close()
+	r.current = nil
+	return err
+}
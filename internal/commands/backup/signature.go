@@ -0,0 +1,254 @@
+package backup
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	backupSignatureName      = "signature.json"
+	backupSignatureAlgorithm = "ed25519"
+	backupSignatureContext   = "dm-backup-signature-v1"
+	backupSignatureSSHType   = "ssh-ed25519"
+)
+
+// BackupSignature is stored next to checksums.txt. It signs the manifest and
+// the checksum list; the checksum list in turn covers every other file.
+type BackupSignature struct {
+	Version         int    `json:"version"`
+	Algorithm       string `json:"algorithm"`
+	KeyID           string `json:"key_id"`
+	PublicKey       string `json:"public_key"`
+	SignedAt        string `json:"signed_at"`
+	ManifestSHA256  string `json:"manifest_sha256"`
+	ChecksumsSHA256 string `json:"checksums_sha256"`
+	Signature       string `json:"signature"`
+}
+
+// BackupSignatureStatus is the signature part of a verify report. Status is
+// valid, untrusted (signed, but not by a trusted key), invalid or missing.
+type BackupSignatureStatus struct {
+	Status    string `json:"status"`
+	Algorithm string `json:"algorithm,omitempty"`
+	KeyID     string `json:"key_id,omitempty"`
+	SignedAt  string `json:"signed_at,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// loadBackupSignKey reads a PKCS#8 PEM ed25519 private key, as written by
+// `openssl genpkey -algorithm ed25519` or `dm backup keygen --sign`.
+func loadBackupSignKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read sign key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("sign key %s 不是 PEM 格式", path)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse sign key %s: %w", path, err)
+	}
+	key, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("sign key %s 不是 ed25519 私钥", path)
+	}
+	return key, nil
+}
+
+// readBackupTrustedKeys accepts PEM public keys and ssh-ed25519 lines in the
+// authorized_keys layout; one file may hold several keys.
+func readBackupTrustedKeys(paths []string) ([]ed25519.PublicKey, error) {
+	var keys []ed25519.PublicKey
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read trusted key: %w", err)
+		}
+		found := 0
+		for rest := data; ; {
+			var block *pem.Block
+			block, rest = pem.Decode(rest)
+			if block == nil {
+				break
+			}
+			parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("trusted key %s: %w", path, err)
+			}
+			key, ok := parsed.(ed25519.PublicKey)
+			if !ok {
+				return nil, fmt.Errorf("trusted key %s 不是 ed25519 公钥", path)
+			}
+			keys = append(keys, key)
+			found++
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) < 2 || fields[0] != backupSignatureSSHType {
+				continue
+			}
+			key, err := parseBackupSSHPublicKey(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("trusted key %s: %w", path, err)
+			}
+			keys = append(keys, key)
+			found++
+		}
+		if found == 0 {
+			return nil, fmt.Errorf("trusted key %s 不包含 ed25519 公钥", path)
+		}
+	}
+	return keys, nil
+}
+
+func parseBackupSSHPublicKey(encoded string) (ed25519.PublicKey, error) {
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	var fields [][]byte
+	for len(data) > 0 {
+		if len(data) < 4 {
+			return nil, errors.New("invalid ssh public key")
+		}
+		size := binary.BigEndian.Uint32(data)
+		if uint64(size) > uint64(len(data)-4) {
+			return nil, errors.New("invalid ssh public key")
+		}
+		fields = append(fields, data[4:4+size])
+		data = data[4+size:]
+	}
+	if len(fields) != 2 || string(fields[0]) != backupSignatureSSHType || len(fields[1]) != ed25519.PublicKeySize {
+		return nil, errors.New("invalid ssh-ed25519 public key")
+	}
+	return ed25519.PublicKey(fields[1]), nil
+}
+
+func backupKeyID(key ed25519.PublicKey) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+func backupSignaturePayload(manifestSHA, checksumsSHA, keyID, signedAt string) []byte {
+	return []byte(strings.Join([]string{backupSignatureContext, keyID, signedAt, manifestSHA, checksumsSHA}, "\n") + "\n")
+}
+
+// writeBackupSignature must run after checksums.txt is written.
+func writeBackupSignature(root string, key ed25519.PrivateKey) error {
+	manifestSHA, err := fileSHA256(filepath.Join(root, backupManifestName))
+	if err != nil {
+		return fmt.Errorf("sign backup: %w", err)
+	}
+	checksumsSHA, err := fileSHA256(filepath.Join(root, backupChecksumName))
+	if err != nil {
+		return fmt.Errorf("sign backup: %w", err)
+	}
+	public := key.Public().(ed25519.PublicKey)
+	signature := BackupSignature{
+		Version:         1,
+		Algorithm:       backupSignatureAlgorithm,
+		KeyID:           backupKeyID(public),
+		PublicKey:       base64.StdEncoding.EncodeToString(public),
+		SignedAt:        time.Now().UTC().Format(time.RFC3339),
+		ManifestSHA256:  manifestSHA,
+		ChecksumsSHA256: checksumsSHA,
+	}
+	payload := backupSignaturePayload(manifestSHA, checksumsSHA, signature.KeyID, signature.SignedAt)
+	signature.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, payload))
+	return writeJSONFile(filepath.Join(root, backupSignatureName), signature)
+}
+
+// verifyBackupSignature checks the signature file of an extracted backup.
+// Without trusted keys a correct signature is reported as untrusted, because
+// anyone can sign with a key of their own.
+func verifyBackupSignature(root string, trusted []ed25519.PublicKey) BackupSignatureStatus {
+	var signature BackupSignature
+	if err := readJSON(filepath.Join(root, backupSignatureName), &signature); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return BackupSignatureStatus{Status: "missing"}
+		}
+		return BackupSignatureStatus{Status: "invalid", Error: err.Error()}
+	}
+	status := BackupSignatureStatus{Status: "invalid", Algorithm: signature.Algorithm, KeyID: signature.KeyID, SignedAt: signature.SignedAt}
+	if signature.Algorithm != backupSignatureAlgorithm {
+		status.Error = fmt.Sprintf("unsupported signature algorithm %q", signature.Algorithm)
+		return status
+	}
+	sig, err := base64.StdEncoding.DecodeString(signature.Signature)
+	if err != nil {
+		status.Error = "invalid signature encoding"
+		return status
+	}
+	for _, check := range []struct{ name, want string }{
+		{backupManifestName, signature.ManifestSHA256},
+		{backupChecksumName, signature.ChecksumsSHA256},
+	} {
+		actual, err := fileSHA256(filepath.Join(root, check.name))
+		if err != nil {
+			status.Error = err.Error()
+			return status
+		}
+		if !strings.EqualFold(actual, check.want) {
+			status.Error = fmt.Sprintf("%s 与签名不一致", check.name)
+			return status
+		}
+	}
+	payload := backupSignaturePayload(signature.ManifestSHA256, signature.ChecksumsSHA256, signature.KeyID, signature.SignedAt)
+	for _, key := range trusted {
+		if ed25519.Verify(key, payload, sig) {
+			status.Status = "valid"
+			status.KeyID = backupKeyID(key)
+			return status
+		}
+	}
+	embedded, err := base64.StdEncoding.DecodeString(signature.PublicKey)
+	if err != nil || len(embedded) != ed25519.PublicKeySize || !ed25519.Verify(ed25519.PublicKey(embedded), payload, sig) {
+		status.Error = "signature does not match"
+		return status
+	}
+	if backupKeyID(ed25519.PublicKey(embedded)) != signature.KeyID {
+		status.Error = "key id does not match public key"
+		return status
+	}
+	status.Status = "untrusted"
+	if len(trusted) > 0 {
+		status.Error = "签名密钥不在 --trusted-key 列表中"
+	}
+	return status
+}
+
+// generateBackupSignKey writes a PKCS#8 PEM private key to w and returns the
+// PEM public key.
+func generateBackupSignKey(w io.Writer) ([]byte, error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		return nil, err
+	}
+	if err := pem.Encode(w, &pem.Block{Type: "PRIVATE KEY", Bytes: privateDER}); err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}), nil
+}
@@ -2,13 +2,16 @@ package backup
 
 import (
 	"context"
+	"docker-manager/internal/appconfig"
 	"docker-manager/internal/docker"
+	"docker-manager/internal/objectstore"
 	"docker-manager/internal/version"
 	"github.com/moby/moby/api/types/container"
 	"github.com/moby/moby/api/types/network"
 	"github.com/moby/moby/api/types/volume"
 	mobyclient "github.com/moby/moby/client"
 	"io"
+	"time"
 )
 
 const (
@@ -19,6 +22,10 @@ const (
 	backupRestoreName  = "restore.sh"
 	backupChecksumName = "checksums.txt"
 	backupRoot         = "docker-backups"
+
+	// backupVolumeHelperMount is where helper containers mount a named volume
+	// while its data is copied; the copied tar entries are rooted at "volume/".
+	backupVolumeHelperMount = "/mnt/volume"
 )
 
 type backupDockerService interface {
@@ -30,11 +37,17 @@ type backupDockerService interface {
 	InspectNetwork(ctx context.Context, name string) (network.Inspect, error)
 	CreateNetwork(ctx context.Context, inspect network.Inspect) error
 	InspectVolume(ctx context.Context, name string) (volume.Volume, error)
-	CreateVolume(ctx context.Context, vol volume.Volume) error
+	CreateVolume(ctx context.Context, vol volume.Volume) (bool, error)
+	ExportVolume(ctx context.Context, name, helperImage, outputFile string) error
+	ImportVolume(ctx context.Context, name, helperImage, inputFile string) error
 	ContainerExists(ctx context.Context, name string) (bool, error)
 	RemoveContainer(ctx context.Context, name string) error
 	CreateContainer(ctx context.Context, inspect container.InspectResponse, name string) (string, error)
 	StartContainer(ctx context.Context, id string) error
+	ExecContainer(ctx context.Context, name string, cmd []string) (int, string, error)
+	PauseContainer(ctx context.Context, name string) error
+	UnpauseContainer(ctx context.Context, name string) error
+	StopContainer(ctx context.Context, name string) error
 }
 
 var newBackupDockerService = func() (backupDockerService, error) {
@@ -49,28 +62,56 @@ type dockerBackupService struct {
 	cli *mobyclient.Client
 }
 
+// CommandDefaults carries .dm.yaml settings into the backup and restore
+// commands.
+type CommandDefaults struct {
+	S3 appconfig.S3Config
+}
+
 type BackupOptions struct {
-	OutputDir      string
-	IncludeImage   bool
-	DryRun         bool
-	Bundle         bool
-	BundleOutput   string
-	Encrypt        bool
-	PassphraseFile string
-	SplitSize      string
-	Merge          bool
-	Output         io.Writer
+	OutputDir         string
+	IncludeImage      bool
+	IncludeVolumeData bool
+	VolumeHelperImage string
+	IncludeBindMounts bool
+	BindInclude       []string
+	BindExclude       []string
+	BindMaxSize       string
+	Base              string
+	Repo              string
+	Consistency       string
+	PreHooks          []string
+	PostHooks         []string
+	HookTimeout       time.Duration
+	DryRun            bool
+	Bundle            bool
+	BundleOutput      string
+	Encrypt           bool
+	PassphraseFile    string
+	Recipients        []string
+	IdentityFiles     []string
+	SignKey           string
+	SplitSize         string
+	Merge             bool
+	S3                objectstore.Config
+	Output            io.Writer
 }
 
 type RestoreOptions struct {
-	Name           string
-	Replace        bool
-	NoStart        bool
-	DryRun         bool
-	Format         string
-	PassphraseFile string
-	SkipChecksum   bool
-	Output         io.Writer
+	Name              string
+	Replace           bool
+	NoStart           bool
+	DryRun            bool
+	Format            string
+	PassphraseFile    string
+	IdentityFiles     []string
+	SkipChecksum      bool
+	VolumeHelperImage string
+	BindRoot          string
+	OverwriteBindData bool
+	Remap             RestoreRemap
+	S3                objectstore.Config
+	Output            io.Writer
 }
 
 // BackupManifest keeps the current batch-friendly containers list while still
@@ -81,6 +122,7 @@ type BackupManifest struct {
 	Tool           version.VersionInfo       `json:"tool,omitempty"`
 	SourcePlatform string                    `json:"source_platform,omitempty"`
 	Containers     []BackupContainerManifest `json:"containers,omitempty"`
+	Parent         *BackupParentRef          `json:"parent,omitempty"`
 
 	ContainerName string              `json:"container_name,omitempty"`
 	SourceName    string              `json:"source_name,omitempty"`
@@ -92,6 +134,18 @@ type BackupManifest struct {
 	Volumes       []BackupResourceRef `json:"volumes,omitempty"`
 }
 
+// BackupParentRef links an incremental backup to the backup it was
+// deduplicated against. Path is relative to the directory containing the
+// backup root; Source is the absolute path used at backup time.
+type BackupParentRef struct {
+	Path           string `json:"path"`
+	Source         string `json:"source,omitempty"`
+	ManifestSHA256 string `json:"manifest_sha256"`
+	CreatedAt      string `json:"created_at,omitempty"`
+	ReusedBlobs    int    `json:"reused_blobs"`
+	ReusedBytes    int64  `json:"reused_bytes"`
+}
+
 type BackupContainerManifest struct {
 	ContainerName string              `json:"container_name"`
 	SourceName    string              `json:"source_name"`
@@ -104,11 +158,33 @@ type BackupContainerManifest struct {
 	Volumes       []BackupResourceRef `json:"volumes,omitempty"`
 	Mounts        []BackupMountRef    `json:"mounts,omitempty"`
 	Devices       []BackupDeviceRef   `json:"devices,omitempty"`
+	Consistency   *BackupConsistency  `json:"consistency,omitempty"`
+	Hooks         []BackupHookResult  `json:"hooks,omitempty"`
+}
+
+// BackupConsistency records how the container was quiesced while its volume
+// and bind data were captured.
+type BackupConsistency struct {
+	Mode     string `json:"mode"`
+	Action   string `json:"action"`
+	Quiesced string `json:"quiesced,omitempty"`
+}
+
+type BackupHookResult struct {
+	Phase    string `json:"phase"`
+	Source   string `json:"source"`
+	Command  string `json:"command"`
+	Status   string `json:"status"`
+	ExitCode int    `json:"exit_code,omitempty"`
+	Output   string `json:"output,omitempty"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration,omitempty"`
 }
 
 type BackupResourceRef struct {
 	Name string `json:"name"`
 	File string `json:"file"`
+	Data string `json:"data,omitempty"`
 }
 
 type BackupMountRef struct {
@@ -125,6 +201,10 @@ type BackupMountRef struct {
 	HostPathReadable *bool  `json:"host_path_readable,omitempty"`
 	HostPathWritable *bool  `json:"host_path_writable,omitempty"`
 	Warning          string `json:"warning,omitempty"`
+	Data             string `json:"data,omitempty"`
+	DataKind         string `json:"data_kind,omitempty"`
+	DataSize         int64  `json:"data_size,omitempty"`
+	DataSkipped      string `json:"data_skipped,omitempty"`
 }
 
 type BackupDeviceRef struct {
@@ -146,6 +226,8 @@ type restoreDryRunPlan struct {
 	Networks      []BackupResourceRef
 	Volumes       []BackupResourceRef
 	Ports         []string
+	BindMounts    []RestoreBindPlan
+	Remapped      []string
 	Exists        bool
 	Replace       bool
 	NoStart       bool
@@ -156,6 +238,7 @@ type RestorePlanReport struct {
 	Source         string                 `json:"source"`
 	DockerEndpoint string                 `json:"docker_endpoint"`
 	Checksum       string                 `json:"checksum"`
+	Chain          []string               `json:"chain,omitempty"`
 	ContainerCount int                    `json:"container_count"`
 	Options        RestorePlanOptions     `json:"options"`
 	Containers     []RestoreContainerPlan `json:"containers"`
@@ -164,9 +247,10 @@ type RestorePlanReport struct {
 }
 
 type RestorePlanOptions struct {
-	Replace bool   `json:"replace"`
-	NoStart bool   `json:"no_start"`
-	Name    string `json:"name,omitempty"`
+	Replace  bool   `json:"replace"`
+	NoStart  bool   `json:"no_start"`
+	Name     string `json:"name,omitempty"`
+	BindRoot string `json:"bind_root,omitempty"`
 }
 
 type RestorePlanSummary struct {
@@ -178,6 +262,9 @@ type RestorePlanSummary struct {
 	VolumesToCreate     int `json:"volumes_to_create"`
 	VolumesPresent      int `json:"volumes_present"`
 	VolumesDifferent    int `json:"volumes_different"`
+	VolumesToPopulate   int `json:"volumes_to_populate"`
+	BindPathsToWrite    int `json:"bind_paths_to_write"`
+	BindPathsOverwrite  int `json:"bind_paths_overwrite"`
 	ContainersToCreate  int `json:"containers_to_create"`
 	ContainersToReplace int `json:"containers_to_replace"`
 	ContainerConflicts  int `json:"container_conflicts"`
@@ -192,8 +279,10 @@ type RestoreContainerPlan struct {
 	Image         RestoreImagePlan      `json:"image"`
 	Networks      []RestoreResourcePlan `json:"networks,omitempty"`
 	Volumes       []RestoreResourcePlan `json:"volumes,omitempty"`
+	BindMounts    []RestoreBindPlan     `json:"bind_mounts,omitempty"`
 	Ports         []string              `json:"ports,omitempty"`
 	PortConflicts []RestorePortConflict `json:"port_conflicts,omitempty"`
+	Remapped      []string              `json:"remapped,omitempty"`
 	Container     RestoreTargetPlan     `json:"container"`
 	Actions       []string              `json:"actions,omitempty"`
 	Warnings      []string              `json:"warnings,omitempty"`
@@ -211,6 +300,8 @@ type RestoreImagePlan struct {
 type RestoreResourcePlan struct {
 	Name        string   `json:"name"`
 	File        string   `json:"file,omitempty"`
+	Data        string   `json:"data,omitempty"`
+	DataAction  string   `json:"data_action,omitempty"`
 	Exists      bool     `json:"exists"`
 	Different   bool     `json:"different,omitempty"`
 	Action      string   `json:"action"`
@@ -218,6 +309,18 @@ type RestoreResourcePlan struct {
 	Error       string   `json:"error,omitempty"`
 }
 
+type RestoreBindPlan struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination,omitempty"`
+	Data        string `json:"data"`
+	Kind        string `json:"kind,omitempty"`
+	Size        int64  `json:"size,omitempty"`
+	Target      string `json:"target,omitempty"`
+	Exists      bool   `json:"exists"`
+	Action      string `json:"action"`
+	Error       string `json:"error,omitempty"`
+}
+
 type RestoreTargetPlan struct {
 	Exists bool   `json:"exists"`
 	Action string `json:"action"`
@@ -229,5 +332,190 @@ type RestorePortConflict struct {
 }
 
 type BackupContainersResult struct {
-	Paths []string
+	Paths  []string
+	Failed []BackupContainerFailure
+}
+
+type BackupContainerFailure struct {
+	Container string
+	Err       error
+}
+
+// RepoSnapshot is one backup stored in a chunk repository. Files are the
+// backup directory contents, each split into content-addressed chunks.
+type RepoSnapshot struct {
+	ID         string              `json:"id"`
+	CreatedAt  string              `json:"created_at"`
+	Tool       version.VersionInfo `json:"tool,omitempty"`
+	Containers []string            `json:"containers"`
+	Size       int64               `json:"size"`
+	AddedBytes int64               `json:"added_bytes"`
+	Files      []RepoFile          `json:"files"`
+}
+
+type RepoFile struct {
+	Path   string   `json:"path"`
+	Mode   uint32   `json:"mode"`
+	Size   int64    `json:"size"`
+	Chunks []string `json:"chunks,omitempty"`
+}
+
+type RepoListOptions struct {
+	Container string
+	Format    string
+}
+
+type RepoSnapshotList struct {
+	Repository string                `json:"repository"`
+	Snapshots  []RepoSnapshotSummary `json:"snapshots"`
+}
+
+type RepoSnapshotSummary struct {
+	ID         string   `json:"id"`
+	CreatedAt  string   `json:"created_at"`
+	Containers []string `json:"containers"`
+	Files      int      `json:"files"`
+	Size       int64    `json:"size"`
+	AddedBytes int64    `json:"added_bytes"`
+	Restore    string   `json:"restore"`
+	Reasons    []string `json:"reasons,omitempty"`
+}
+
+type RepoPruneOptions struct {
+	Container  string
+	KeepLast   int
+	KeepDaily  int
+	KeepWeekly int
+	GCOnly     bool
+	Apply      bool
+	Confirm    bool
+	Format     string
+}
+
+type RepoPruneReport struct {
+	Repository string                `json:"repository"`
+	Policy     RepoRetentionPolicy   `json:"policy"`
+	Keep       []RepoSnapshotSummary `json:"keep,omitempty"`
+	Remove     []RepoSnapshotSummary `json:"remove,omitempty"`
+	GC         RepoGCSummary         `json:"gc"`
+	Applied    bool                  `json:"applied"`
+}
+
+type RepoRetentionPolicy struct {
+	Container  string `json:"container,omitempty"`
+	KeepLast   int    `json:"keep_last,omitempty"`
+	KeepDaily  int    `json:"keep_daily,omitempty"`
+	KeepWeekly int    `json:"keep_weekly,omitempty"`
+}
+
+type RepoGCSummary struct {
+	Chunks            int   `json:"chunks"`
+	ReferencedChunks  int   `json:"referenced_chunks"`
+	UnreferencedFiles int   `json:"unreferenced_files"`
+	ReclaimableBytes  int64 `json:"reclaimable_bytes"`
+}
+
+type BackupVerifyOptions struct {
+	TrustedKeys    []string
+	PassphraseFile string
+	IdentityFiles  []string
+	Format         string
+}
+
+// BackupVerifyReport is the result of dm backup verify. OK is false when any
+// check failed; Warnings do not affect it.
+type BackupVerifyReport struct {
+	Source     string                `json:"source"`
+	Kind       string                `json:"kind"`
+	Parts      []BackupVerifyPart    `json:"parts,omitempty"`
+	Encryption string                `json:"encryption,omitempty"`
+	Decrypted  bool                  `json:"decrypted"`
+	Containers []string              `json:"containers,omitempty"`
+	Parent     string                `json:"parent,omitempty"`
+	Checksums  BackupVerifyChecksums `json:"checksums"`
+	Signature  BackupSignatureStatus `json:"signature"`
+	Result     string                `json:"result"` // passed | unverified | failed
+	OK         bool                  `json:"ok"`
+	Errors     []string              `json:"errors,omitempty"`
+	Warnings   []string              `json:"warnings,omitempty"`
+}
+
+type BackupVerifyPart struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+type BackupVerifyChecksums struct {
+	Status   string   `json:"status"`
+	Files    int      `json:"files"`
+	Unlisted []string `json:"unlisted,omitempty"`
+	Error    string   `json:"error,omitempty"`
+}
+
+type BackupListOptions struct {
+	PassphraseFile string
+	IdentityFiles  []string
+	Format         string
+}
+
+// BackupListing describes a bundle from its manifest and archive headers
+// only; nothing is extracted to disk. For incremental backups, sizes of
+// delta artifacts are the stored (referencing) sizes.
+type BackupListing struct {
+	Source         string                `json:"source"`
+	Kind           string                `json:"kind"`
+	Encryption     string                `json:"encryption,omitempty"`
+	CreatedAt      string                `json:"created_at,omitempty"`
+	ToolVersion    string                `json:"tool_version,omitempty"`
+	SourcePlatform string                `json:"source_platform,omitempty"`
+	Parent         string                `json:"parent,omitempty"`
+	Signed         bool                  `json:"signed"`
+	Files          int                   `json:"files"`
+	Size           int64                 `json:"size"`
+	Containers     []BackupListContainer `json:"containers"`
+}
+
+type BackupListContainer struct {
+	Name      string               `json:"name"`
+	Path      string               `json:"path,omitempty"`
+	Image     string               `json:"image,omitempty"`
+	Networks  []string             `json:"networks,omitempty"`
+	Volumes   []string             `json:"volumes,omitempty"`
+	Size      int64                `json:"size"`
+	Artifacts []BackupListArtifact `json:"artifacts"`
+}
+
+// BackupListArtifact is one file of a container entry. Kind is one of the
+// dm backup extract --only values.
+type BackupListArtifact struct {
+	Kind    string `json:"kind"`
+	Name    string `json:"name,omitempty"`
+	File    string `json:"file"`
+	Size    int64  `json:"size"`
+	Delta   bool   `json:"delta,omitempty"`
+	Missing bool   `json:"missing,omitempty"`
+}
+
+type BackupExtractOptions struct {
+	Containers     []string
+	Only           []string
+	OutputDir      string
+	Force          bool
+	PassphraseFile string
+	IdentityFiles  []string
+	Format         string
+}
+
+type BackupExtractResult struct {
+	Source    string              `json:"source"`
+	OutputDir string              `json:"output_dir"`
+	Files     []BackupExtractFile `json:"files"`
+}
+
+type BackupExtractFile struct {
+	Container string `json:"container"`
+	Kind      string `json:"kind"`
+	Path      string `json:"path"`
+	Size      int64  `json:"size"`
 }
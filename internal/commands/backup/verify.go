@@ -0,0 +1,301 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"docker-manager/internal/textfmt"
+)
+
+// verifyBackupBundle checks a backup directory, archive, encrypted archive or
+// split archive without touching Docker. Problems with the bundle itself are
+// reported in the result; only unusable options are returned as errors.
+func verifyBackupBundle(ctx context.Context, source string, opts BackupVerifyOptions) (BackupVerifyReport, error) {
+	ctx = backupContext(ctx)
+	if err := checkBackupContext(ctx); err != nil {
+		return BackupVerifyReport{}, err
+	}
+	trusted, err := readBackupTrustedKeys(opts.TrustedKeys)
+	if err != nil {
+		return BackupVerifyReport{}, err
+	}
+	if isRepoSource(source) {
+		return BackupVerifyReport{}, fmt.Errorf("dm backup verify 不支持 repo:// 快照；仓库数据块在恢复时按 sha256 校验")
+	}
+	report := BackupVerifyReport{Source: source, Kind: backupVerifyKind(source)}
+	fail := func(format string, args ...interface{}) {
+		report.Errors = append(report.Errors, fmt.Sprintf(format, args...))
+	}
+
+	if report.Kind != "directory" {
+		parts, problems, err := backupVerifyParts(ctx, source)
+		if err != nil {
+			return report, err
+		}
+		report.Parts = parts
+		for _, problem := range problems {
+			fail("%s", problem)
+		}
+	}
+	if strings.HasSuffix(report.Kind, "encrypted") && len(report.Parts) > 0 {
+		scheme, err := describeBackupEncryptionFile(report.Parts[0].Path)
+		if err != nil {
+			fail("encryption header: %v", err)
+		}
+		report.Encryption = scheme
+		if strings.TrimSpace(opts.PassphraseFile) == "" && len(opts.IdentityFiles) == 0 {
+			fail("加密备份需要 --passphrase-file 或 --identity 才能校验内容")
+		}
+	}
+	if len(report.Errors) > 0 {
+		report.setResult(false)
+		return report, nil
+	}
+
+	dir, cleanup, err := resolveRestoreBackupDirWithOptions(ctx, source, RestoreOptions{PassphraseFile: opts.PassphraseFile, IdentityFiles: opts.IdentityFiles})
+	if err != nil {
+		if ctxErr := checkBackupContext(ctx); ctxErr != nil {
+			return report, ctxErr
+		}
+		fail("read bundle: %v", err)
+		report.setResult(false)
+		return report, nil
+	}
+	if cleanup != nil {
+		defer cleanup()
+	}
+	report.Decrypted = report.Encryption != ""
+
+	manifest, err := readBackupManifest(dir)
+	if err != nil {
+		fail("%v", err)
+	}
+	for _, entry := range manifest.Containers {
+		report.Containers = append(report.Containers, entry.ContainerName)
+	}
+	if manifest.Parent != nil {
+		report.Parent = manifest.Parent.Path
+		report.Warnings = append(report.Warnings, fmt.Sprintf("增量备份：基线 %s 的内容需要单独校验", manifest.Parent.Path))
+	}
+
+	report.Checksums = verifyBackupChecksumCoverage(ctx, dir)
+	if report.Checksums.Status != "ok" {
+		fail("checksums: %s", backupVerifyChecksumProblem(report.Checksums))
+	}
+
+	report.Signature = verifyBackupSignature(dir, trusted)
+	unverified := false
+	switch report.Signature.Status {
+	case "valid":
+	case "untrusted":
+		if len(trusted) > 0 {
+			fail("signature: %s", report.Signature.Error)
+		} else {
+			// 能改动备份的人也能用自己的密钥重新签名，只有 --trusted-key 固定的签名者才能算通过
+			unverified = true
+			report.Warnings = append(report.Warnings, "签名与内容一致，但未提供 --trusted-key，签名者身份未验证；请用 --trusted-key 指定签名公钥")
+		}
+	case "missing":
+		if len(trusted) > 0 {
+			fail("signature: 备份未签名")
+		} else {
+			// 删掉签名并重算 checksums.txt 与重新签名一样容易，未签名的备份同样不能算通过
+			unverified = true
+			report.Warnings = append(report.Warnings, "备份未签名；checksums.txt 只能发现意外损坏，无法证明内容未被改动")
+		}
+	default:
+		fail("signature: %s", report.Signature.Error)
+	}
+	report.setResult(unverified)
+	log.Printf("Backup verify: source=%s kind=%s result=%s signature=%s", source, report.Kind, report.Result, report.Signature.Status)
+	return report, nil
+}
+
+func (r *BackupVerifyReport) setResult(unverified bool) {
+	switch {
+	case len(r.Errors) > 0:
+		r.Result = "failed"
+	case unverified:
+		r.Result = "unverified"
+	default:
+		r.Result = "passed"
+	}
+	r.OK = r.Result == "passed"
+}
+
+func backupVerifyKind(source string) string {
+	name := strings.TrimSuffix(source, backupArchiveSuffixPart)
+	kind := "archive"
+	switch {
+	case isBackupArchivePart(source):
+		kind = "split"
+	case !isBackupArchive(source) && !isEncryptedBackupArchive(source):
+		return "directory"
+	}
+	if isEncryptedBackupArchive(name) {
+		kind += "+encrypted"
+	}
+	return kind
+}
+
+// backupVerifyParts hashes every file of an archive. For split archives it
+// also checks that no part is missing in the middle and that only the last
+// part is shorter than the others.
+func backupVerifyParts(ctx context.Context, source string) ([]BackupVerifyPart, []string, error) {
+	paths := []string{source}
+	var problems []string
+	if isBackupArchivePart(source) {
+		base := strings.TrimSuffix(source, backupArchiveSuffixPart)
+		paths = nil
+		for i := 1; ; i++ {
+			path := splitPartPath(base, i)
+			if _, err := os.Stat(path); err != nil {
+				if !os.IsNotExist(err) {
+					return nil, nil, err
+				}
+				if _, err := os.Stat(splitPartPath(base, i+1)); err == nil {
+					problems = append(problems, fmt.Sprintf("分卷缺失: %s", filepath.Base(path)))
+				}
+				break
+			}
+			paths = append(paths, path)
+		}
+	}
+	var parts []BackupVerifyPart
+	for _, path := range paths {
+		if err := checkBackupContext(ctx); err != nil {
+			return nil, nil, err
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		sum, err := fileSHA256WithContext(ctx, path)
+		if err != nil {
+			return nil, nil, err
+		}
+		parts = append(parts, BackupVerifyPart{Path: path, Size: info.Size(), SHA256: sum})
+	}
+	for i := 1; i+1 < len(parts); i++ {
+		if parts[i].Size != parts[0].Size {
+			problems = append(problems, fmt.Sprintf("分卷大小不一致: %s=%d，首个分卷=%d", filepath.Base(parts[i].Path), parts[i].Size, parts[0].Size))
+		}
+	}
+	if len(parts) > 1 && parts[len(parts)-1].Size > parts[0].Size {
+		problems = append(problems, fmt.Sprintf("最后一个分卷 %s 大于首个分卷", filepath.Base(parts[len(parts)-1].Path)))
+	}
+	return parts, problems, nil
+}
+
+func describeBackupEncryptionFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	return describeBackupEncryption(file)
+}
+
+// verifyBackupChecksumCoverage verifies checksums.txt and also reports files
+// that it does not list, which a plain checksum check would silently accept.
+func verifyBackupChecksumCoverage(ctx context.Context, dir string) BackupVerifyChecksums {
+	sums, err := readBackupChecksumList(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return BackupVerifyChecksums{Status: "missing"}
+		}
+		return BackupVerifyChecksums{Status: "error", Error: err.Error()}
+	}
+	result := BackupVerifyChecksums{Status: "ok", Files: len(sums)}
+	if _, err := verifyBackupChecksumsWithContext(ctx, dir); err != nil {
+		result.Status = "mismatch"
+		result.Error = err.Error()
+		return result
+	}
+	err = filepath.WalkDir(dir, func(path string, entry os.DirEntry, walkErr error) error {
+		if walkErr != nil || entry.IsDir() {
+			return walkErr
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == backupChecksumName || rel == backupSignatureName {
+			return nil
+		}
+		if _, ok := sums[rel]; !ok {
+			result.Unlisted = append(result.Unlisted, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		result.Status = "error"
+		result.Error = err.Error()
+		return result
+	}
+	if len(result.Unlisted) > 0 {
+		sort.Strings(result.Unlisted)
+		result.Status = "unlisted"
+	}
+	return result
+}
+
+func backupVerifyChecksumProblem(checksums BackupVerifyChecksums) string {
+	switch checksums.Status {
+	case "missing":
+		return "备份不包含 checksums.txt"
+	case "unlisted":
+		return "存在未列入 checksums.txt 的文件: " + strings.Join(checksums.Unlisted, ", ")
+	default:
+		return checksums.Error
+	}
+}
+
+func printBackupVerifyReport(w io.Writer, report BackupVerifyReport) {
+	fmt.Fprintf(w, "备份校验: %s\n", report.Source)
+	fmt.Fprintf(w, "类型: %s\n", report.Kind)
+	for _, part := range report.Parts {
+		fmt.Fprintf(w, "  - %s size=%s sha256=%s\n", filepath.Base(part.Path), textfmt.SignedBytes(part.Size), part.SHA256)
+	}
+	if report.Encryption != "" {
+		fmt.Fprintf(w, "加密: %s decrypted=%v\n", report.Encryption, report.Decrypted)
+	}
+	if len(report.Containers) > 0 {
+		fmt.Fprintf(w, "容器: %s\n", strings.Join(report.Containers, ", "))
+	}
+	if report.Checksums.Status != "" {
+		fmt.Fprintf(w, "checksums: %s files=%d\n", report.Checksums.Status, report.Checksums.Files)
+	}
+	if report.Signature.Status != "" {
+		fmt.Fprintf(w, "签名: %s", report.Signature.Status)
+		if report.Signature.KeyID != "" {
+			fmt.Fprintf(w, " key=%s", report.Signature.KeyID)
+		}
+		if report.Signature.SignedAt != "" {
+			fmt.Fprintf(w, " signed_at=%s", report.Signature.SignedAt)
+		}
+		fmt.Fprintln(w)
+	}
+	for _, warning := range report.Warnings {
+		fmt.Fprintf(w, "警告: %s\n", warning)
+	}
+	for _, problem := range report.Errors {
+		fmt.Fprintf(w, "错误: %s\n", problem)
+	}
+	switch report.Result {
+	case "passed":
+		fmt.Fprintln(w, "结果: 通过")
+	case "unverified":
+		fmt.Fprintln(w, "结果: 未验证 (未签名或签名者未由 --trusted-key 固定)")
+	default:
+		fmt.Fprintln(w, "结果: 失败")
+	}
+}
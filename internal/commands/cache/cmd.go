@@ -0,0 +1,124 @@
+package cache
+
+import (
+	"fmt"
+	"io"
+
+	"docker-manager/internal/blobcache"
+	"docker-manager/internal/commandflags"
+	rpt "docker-manager/internal/report"
+
+	"github.com/spf13/cobra"
+)
+
+type CommandDefaults struct {
+	Dir string
+}
+
+func NewCacheCommand() *cobra.Command {
+	return NewCacheCommandWithDefaults(nil)
+}
+
+func NewCacheCommandWithDefaults(defaults func() CommandDefaults) *cobra.Command {
+	var dir string
+	cmd := &cobra.Command{
+		Use:   "cache",
+		Short: "查看和清理 dm pull 使用的共享 blob 缓存",
+		Long:  "查看和清理 dm pull 使用的共享 blob 缓存。\n\n缓存按 digest 保存 registry 返回的原始层和配置 blob，位置由 --cache-dir、.dm.yaml 的 cache_dir 决定，默认位于用户缓存目录下的 docker-manager/blobs。",
+	}
+	cmd.PersistentFlags().StringVar(&dir, "cache-dir", "", "blob 缓存目录，默认读取配置 cache_dir 或用户缓存目录下的 docker-manager/blobs")
+	openCache := func(cmd *cobra.Command) (*blobcache.Cache, error) {
+		if !cmd.Flags().Changed("cache-dir") && defaults != nil {
+			if configured := defaults().Dir; configured != "" {
+				dir = configured
+			}
+		}
+		cache, err := blobcache.Open(dir)
+		if err != nil {
+			return nil, fmt.Errorf("打开 blob 缓存失败: %w", err)
+		}
+		return cache, nil
+	}
+	cmd.AddCommand(newCacheListCommand(openCache), newCacheUsageCommand(openCache), newCachePruneCommand(openCache))
+	return cmd
+}
+
+type cacheOpener func(cmd *cobra.Command) (*blobcache.Cache, error)
+
+func newCacheListCommand(open cacheOpener) *cobra.Command {
+	var format string
+	cmd := &cobra.Command{
+		Use:   "ls",
+		Short: "列出缓存中的 blob，按最近使用时间从旧到新排序",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cache, err := open(cmd)
+			if err != nil {
+				return err
+			}
+			listing, err := listCache(cache)
+			if err != nil {
+				return fmt.Errorf("读取 blob 缓存失败: %w", err)
+			}
+			return rpt.Print(cmd.OutOrStdout(), format, listing, func(w io.Writer) {
+				printCacheListing(w, listing)
+			})
+		},
+	}
+	commandflags.AddReportFormatFlag(cmd, &format)
+	return cmd
+}
+
+func newCacheUsageCommand(open cacheOpener) *cobra.Command {
+	var format string
+	cmd := &cobra.Command{
+		Use:   "du",
+		Short: "统计缓存中的 blob 数量和占用空间",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cache, err := open(cmd)
+			if err != nil {
+				return err
+			}
+			usage, err := cacheUsage(cache)
+			if err != nil {
+				return fmt.Errorf("读取 blob 缓存失败: %w", err)
+			}
+			return rpt.Print(cmd.OutOrStdout(), format, usage, func(w io.Writer) {
+				printCacheUsage(w, usage)
+			})
+		},
+	}
+	commandflags.AddReportFormatFlag(cmd, &format)
+	return cmd
+}
+
+func newCachePruneCommand(open cacheOpener) *cobra.Command {
+	opts := CachePruneOptions{}
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "按最近使用时间和总大小清理缓存中的 blob",
+		Long:  "按最近使用时间和总大小清理缓存中的 blob。\n\n--older-than 删除超过指定时间未被 pull 使用的 blob；--max-size 从最久未使用的 blob 开始删除，直到缓存不超过指定大小；--all 清空缓存。默认只输出预览，添加 --apply --confirm 执行删除。",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cache, err := open(cmd)
+			if err != nil {
+				return err
+			}
+			report, err := pruneCache(cmd.Context(), cache, opts)
+			if err != nil {
+				return fmt.Errorf("清理 blob 缓存失败: %w", err)
+			}
+			return rpt.Print(cmd.OutOrStdout(), opts.Format, report, func(w io.Writer) {
+				printCachePruneReport(w, report)
+			})
+		},
+	}
+	cmd.Flags().DurationVar(&opts.OlderThan, "older-than", 0, "删除超过该时间未使用的 blob，例如 72h、720h")
+	cmd.Flags().StringVar(&opts.MaxSize, "max-size", "", "缓存保留的最大总大小，例如 20g、512m")
+	cmd.Flags().BoolVar(&opts.All, "all", false, "删除全部缓存 blob")
+	cmd.Flags().BoolVar(&opts.Apply, "apply", false, "根据报告执行删除")
+	cmd.Flags().BoolVar(&opts.Confirm, "confirm", false, "确认执行 --apply 删除操作")
+	commandflags.AddReportFormatFlag(cmd, &opts.Format)
+	return cmd
+}
@@ -0,0 +1,78 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"docker-manager/internal/blobcache"
+
+	digest "github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestCachePruneRequiresConfirmAndRemovesOldBlobs(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := blobcache.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := addCachedBlob(t, cache, "old-layer", time.Now().Add(-48*time.Hour))
+	fresh := addCachedBlob(t, cache, "fresh-layer", time.Now())
+
+	cmd := NewCacheCommandWithDefaults(func() CommandDefaults { return CommandDefaults{Dir: dir} })
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"prune", "--older-than", "24h", "--apply"})
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "--confirm") {
+		t.Fatalf("Execute() error = %v, want --confirm error", err)
+	}
+	out.Reset()
+
+	cmd.SetArgs([]string{"prune", "--older-than", "24h", "--apply", "--confirm", "--format", "json"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	var report CachePruneReport
+	if err := json.Unmarshal(out.Bytes(), &report); err != nil {
+		t.Fatalf("report: %v\n%s", err, out.String())
+	}
+	if !report.Applied || len(report.Remove) != 1 || report.Remove[0].Digest != old || report.KeepBlobs != 1 {
+		t.Fatalf("report = %#v", report)
+	}
+	if _, err := os.Stat(cache.Path(old)); !os.IsNotExist(err) {
+		t.Fatalf("old blob still cached: %v", err)
+	}
+	if _, err := os.Stat(cache.Path(fresh)); err != nil {
+		t.Fatalf("fresh blob removed: %v", err)
+	}
+
+	out.Reset()
+	cmd.SetArgs([]string{"du"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if !strings.Contains(out.String(), "blobs=1 size=11 B") {
+		t.Fatalf("du output = %q", out.String())
+	}
+}
+
+func addCachedBlob(t *testing.T, cache *blobcache.Cache, content string, lastUsed time.Time) digest.Digest {
+	t.Helper()
+	data := []byte(content)
+	desc := ocispec.Descriptor{Digest: digest.FromBytes(data), Size: int64(len(data))}
+	path, _, err := cache.Fetch(context.Background(), desc, func(path string) error {
+		return os.WriteFile(path, data, 0644)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, lastUsed, lastUsed); err != nil {
+		t.Fatal(err)
+	}
+	return desc.Digest
+}
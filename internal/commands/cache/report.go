@@ -0,0 +1,210 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"docker-manager/internal/blobcache"
+	"docker-manager/internal/textfmt"
+)
+
+type CacheListing struct {
+	Dir       string            `json:"dir"`
+	Blobs     []blobcache.Entry `json:"blobs"`
+	TotalSize int64             `json:"total_size"`
+}
+
+type CacheUsage struct {
+	Dir        string    `json:"dir"`
+	Blobs      int       `json:"blobs"`
+	TotalSize  int64     `json:"total_size"`
+	OldestUsed time.Time `json:"oldest_used,omitempty"`
+	NewestUsed time.Time `json:"newest_used,omitempty"`
+}
+
+type CachePruneOptions struct {
+	OlderThan time.Duration
+	MaxSize   string
+	All       bool
+	Apply     bool
+	Confirm   bool
+	Format    string
+}
+
+type CachePrunePolicy struct {
+	All       bool   `json:"all,omitempty"`
+	OlderThan string `json:"older_than,omitempty"`
+	MaxSize   int64  `json:"max_size,omitempty"`
+}
+
+type CachePruneReport struct {
+	Dir              string            `json:"dir"`
+	Policy           CachePrunePolicy  `json:"policy"`
+	KeepBlobs        int               `json:"keep_blobs"`
+	KeepSize         int64             `json:"keep_size"`
+	Remove           []blobcache.Entry `json:"remove,omitempty"`
+	ReclaimableBytes int64             `json:"reclaimable_bytes"`
+	Applied          bool              `json:"applied"`
+}
+
+func listCache(cache *blobcache.Cache) (CacheListing, error) {
+	entries, err := cache.List()
+	if err != nil {
+		return CacheListing{}, err
+	}
+	listing := CacheListing{Dir: cache.Root(), Blobs: entries}
+	for _, entry := range entries {
+		listing.TotalSize += entry.Size
+	}
+	return listing, nil
+}
+
+func cacheUsage(cache *blobcache.Cache) (CacheUsage, error) {
+	entries, err := cache.List()
+	if err != nil {
+		return CacheUsage{}, err
+	}
+	usage := CacheUsage{Dir: cache.Root(), Blobs: len(entries)}
+	for _, entry := range entries {
+		usage.TotalSize += entry.Size
+	}
+	if len(entries) > 0 {
+		usage.OldestUsed = entries[0].LastUsed
+		usage.NewestUsed = entries[len(entries)-1].LastUsed
+	}
+	return usage, nil
+}
+
+func pruneCache(ctx context.Context, cache *blobcache.Cache, opts CachePruneOptions) (CachePruneReport, error) {
+	maxSize, err := parseCacheSize(opts.MaxSize)
+	if err != nil {
+		return CachePruneReport{}, fmt.Errorf("--max-size: %w", err)
+	}
+	if opts.OlderThan < 0 {
+		return CachePruneReport{}, fmt.Errorf("--older-than 不能为负数")
+	}
+	if !opts.All && opts.OlderThan == 0 && maxSize == 0 {
+		return CachePruneReport{}, fmt.Errorf("至少需要一个 --older-than、--max-size 或 --all 策略")
+	}
+	if opts.Apply && !opts.Confirm {
+		return CachePruneReport{}, fmt.Errorf("--apply 会删除缓存中的 blob；如确认执行，请添加 --confirm")
+	}
+	entries, err := cache.List()
+	if err != nil {
+		return CachePruneReport{}, err
+	}
+	policy := blobcache.PrunePolicy{All: opts.All, OlderThan: opts.OlderThan, MaxSize: maxSize}
+	keep, remove := blobcache.Plan(entries, policy, time.Now())
+	report := CachePruneReport{
+		Dir:       cache.Root(),
+		Policy:    CachePrunePolicy{All: opts.All, MaxSize: maxSize},
+		KeepBlobs: len(keep),
+		Remove:    remove,
+	}
+	if opts.OlderThan > 0 {
+		report.Policy.OlderThan = opts.OlderThan.String()
+	}
+	for _, entry := range keep {
+		report.KeepSize += entry.Size
+	}
+	for _, entry := range remove {
+		report.ReclaimableBytes += entry.Size
+	}
+	if !opts.Apply {
+		return report, nil
+	}
+	if err := cache.Remove(ctx, remove); err != nil {
+		return report, err
+	}
+	report.Applied = true
+	log.Printf("Blob cache prune: dir=%s removed=%d reclaimed=%d", cache.Root(), len(remove), report.ReclaimableBytes)
+	return report, nil
+}
+
+// parseCacheSize accepts a byte count with an optional k/m/g/t suffix.
+func parseCacheSize(value string) (int64, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+	units := map[byte]int64{
+		'k': 1 << 10,
+		'm': 1 << 20,
+		'g': 1 << 30,
+		't': 1 << 40,
+	}
+	multiplier := int64(1)
+	last := value[len(value)-1]
+	if last >= 'A' && last <= 'Z' {
+		last = last - 'A' + 'a'
+	}
+	if unit, ok := units[last]; ok {
+		multiplier = unit
+		value = strings.TrimSpace(value[:len(value)-1])
+	}
+	size, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || size <= 0 {
+		return 0, fmt.Errorf("invalid size %q", value)
+	}
+	return size * multiplier, nil
+}
+
+func printCacheListing(w io.Writer, listing CacheListing) {
+	fmt.Fprintf(w, "blob 缓存: %s\n", listing.Dir)
+	if len(listing.Blobs) == 0 {
+		fmt.Fprintln(w, "缓存为空")
+		return
+	}
+	for _, entry := range listing.Blobs {
+		printCacheEntry(w, entry)
+	}
+	fmt.Fprintf(w, "合计: blobs=%d size=%s\n", len(listing.Blobs), textfmt.SignedBytes(listing.TotalSize))
+}
+
+func printCacheEntry(w io.Writer, entry blobcache.Entry) {
+	fmt.Fprintf(w, "  - %s size=%s last_used=%s\n", entry.Digest, textfmt.SignedBytes(entry.Size), entry.LastUsed.Format(time.RFC3339))
+}
+
+func printCacheUsage(w io.Writer, usage CacheUsage) {
+	fmt.Fprintf(w, "blob 缓存: %s\n", usage.Dir)
+	fmt.Fprintf(w, "blobs=%d size=%s\n", usage.Blobs, textfmt.SignedBytes(usage.TotalSize))
+	if usage.Blobs > 0 {
+		fmt.Fprintf(w, "最久未使用: %s\n", usage.OldestUsed.Format(time.RFC3339))
+		fmt.Fprintf(w, "最近使用: %s\n", usage.NewestUsed.Format(time.RFC3339))
+	}
+}
+
+func printCachePruneReport(w io.Writer, report CachePruneReport) {
+	fmt.Fprintf(w, "blob 缓存清理: %s\n", report.Dir)
+	policy := report.Policy
+	fmt.Fprintf(w, "清理策略: all=%v older-than=%s max-size=%s\n", policy.All, valueOrNone(policy.OlderThan), maxSizeText(policy.MaxSize))
+	fmt.Fprintf(w, "保留 blob: %d (%s)\n", report.KeepBlobs, textfmt.SignedBytes(report.KeepSize))
+	fmt.Fprintf(w, "删除 blob: %d 可回收=%s\n", len(report.Remove), textfmt.SignedBytes(report.ReclaimableBytes))
+	for _, entry := range report.Remove {
+		printCacheEntry(w, entry)
+	}
+	if report.Applied {
+		fmt.Fprintln(w, "已执行清理")
+	} else {
+		fmt.Fprintln(w, "预览模式；添加 --apply --confirm 执行清理")
+	}
+}
+
+func valueOrNone(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+func maxSizeText(size int64) string {
+	if size <= 0 {
+		return "-"
+	}
+	return textfmt.SignedBytes(size)
+}
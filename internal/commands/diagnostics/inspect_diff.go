@@ -1,23 +1,19 @@
 package diagnostics
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
 	"io"
-	"net/netip"
 	"sort"
-	"strings"
 
 	"docker-manager/internal/commandflags"
 	"docker-manager/internal/completion"
 	"docker-manager/internal/docker"
+	"docker-manager/internal/inspectfields"
 	"docker-manager/internal/parallel"
 	rpt "docker-manager/internal/report"
 
 	"github.com/moby/moby/api/types/container"
-	"github.com/moby/moby/api/types/network"
 	mobyclient "github.com/moby/moby/client"
 	"github.com/spf13/cobra"
 )
@@ -137,198 +133,8 @@ func buildInspectDiffReport(leftName, rightName string, left, right container.In
 }
 
 func inspectComparableFields(info container.InspectResponse, opts InspectDiffOptions) map[string]string {
-	fields := map[string]string{}
 	redactProfile, _ := normalizeRedactProfile(opts.RedactProfile, opts.RedactSecrets)
-	add := func(path string, value interface{}) {
-		if redactProfile != "none" {
-			value = redactInspectDiffValue(value, redactProfile)
-		}
-		fields[path] = inspectDiffValue(value)
-	}
-
-	if info.Config != nil {
-		cfg := info.Config
-		add("config.image", cfg.Image)
-		add("config.user", cfg.User)
-		add("config.working_dir", cfg.WorkingDir)
-		add("config.hostname", cfg.Hostname)
-		add("config.domainname", cfg.Domainname)
-		add("config.cmd", []string(cfg.Cmd))
-		add("config.entrypoint", []string(cfg.Entrypoint))
-		add("config.healthcheck", comparableHealthcheck(cfg.Healthcheck))
-		add("config.env", envMap(cfg.Env, redactProfile))
-		add("config.labels", cfg.Labels)
-		add("config.exposed_ports", cfg.ExposedPorts)
-		add("config.tty", cfg.Tty)
-		add("config.open_stdin", cfg.OpenStdin)
-		add("config.stop_signal", cfg.StopSignal)
-	}
-
-	if info.HostConfig != nil {
-		host := info.HostConfig
-		add("host.restart_policy", host.RestartPolicy)
-		add("host.network_mode", host.NetworkMode)
-		add("host.privileged", host.Privileged)
-		add("host.auto_remove", host.AutoRemove)
-		add("host.publish_all_ports", host.PublishAllPorts)
-		add("host.port_bindings", host.PortBindings)
-		add("host.binds", sortedStrings(host.Binds))
-		add("host.dns", sortedNetIPAddrs(host.DNS))
-		add("host.dns_search", sortedStrings(host.DNSSearch))
-		add("host.extra_hosts", sortedStrings(host.ExtraHosts))
-		add("host.cap_add", sortedStrings([]string(host.CapAdd)))
-		add("host.cap_drop", sortedStrings([]string(host.CapDrop)))
-		add("host.security_opt", sortedStrings(host.SecurityOpt))
-		add("host.devices", host.Devices)
-		add("host.ulimits", host.Ulimits)
-		add("host.log_config", comparableLogConfig(host.LogConfig))
-		add("host.memory", host.Memory)
-		add("host.memory_reservation", host.MemoryReservation)
-		add("host.memory_swap", host.MemorySwap)
-		add("host.nano_cpus", host.NanoCPUs)
-		add("host.cpu_shares", host.CPUShares)
-		add("host.cpu_quota", host.CPUQuota)
-		add("host.cpu_period", host.CPUPeriod)
-		add("host.cpuset_cpus", host.CpusetCpus)
-		add("host.shm_size", host.ShmSize)
-		add("host.readonly_rootfs", host.ReadonlyRootfs)
-		add("host.tmpfs", host.Tmpfs)
-		add("host.sysctls", host.Sysctls)
-		add("host.runtime", host.Runtime)
-		add("host.ipc_mode", host.IpcMode)
-		add("host.pid_mode", host.PidMode)
-		add("host.userns_mode", host.UsernsMode)
-	}
-
-	add("mounts", comparableMounts(info.Mounts))
-	if info.NetworkSettings != nil {
-		add("networks", comparableNetworks(info.NetworkSettings.Networks))
-	}
-	return fields
-}
-
-func redactInspectDiffValue(value interface{}, profile sensitiveProfile) interface{} {
-	switch v := value.(type) {
-	case nil:
-		return nil
-	case string:
-		return redactSensitiveTextWithProfile(v, profile)
-	case []string:
-		return redactStringSliceWithProfile(v, profile)
-	case map[string]string:
-		return redactStringMapWithProfile(v, profile)
-	case map[string]interface{}:
-		result := make(map[string]interface{}, len(v))
-		for key, item := range v {
-			if isSensitiveKeyWithProfile(key, profile) {
-				result[key] = redactedValue
-			} else {
-				result[key] = redactInspectDiffValue(item, profile)
-			}
-		}
-		return result
-	case []interface{}:
-		result := make([]interface{}, len(v))
-		for i, item := range v {
-			result[i] = redactInspectDiffValue(item, profile)
-		}
-		return result
-	case []map[string]interface{}:
-		result := make([]map[string]interface{}, len(v))
-		for i, item := range v {
-			result[i] = redactInspectDiffValue(item, profile).(map[string]interface{})
-		}
-		return result
-	default:
-		return value
-	}
-}
-
-func comparableHealthcheck(health *container.HealthConfig) map[string]interface{} {
-	if health == nil {
-		return nil
-	}
-	return map[string]interface{}{
-		"test":         append([]string(nil), health.Test...),
-		"interval":     health.Interval,
-		"timeout":      health.Timeout,
-		"start_period": health.StartPeriod,
-		"retries":      health.Retries,
-	}
-}
-
-func comparableLogConfig(config container.LogConfig) map[string]interface{} {
-	return map[string]interface{}{
-		"type":   config.Type,
-		"config": cloneStringMap(config.Config),
-	}
-}
-
-func comparableMounts(mounts []container.MountPoint) []map[string]interface{} {
-	result := make([]map[string]interface{}, 0, len(mounts))
-	for _, mount := range mounts {
-		result = append(result, map[string]interface{}{
-			"type":        mount.Type,
-			"name":        mount.Name,
-			"source":      mount.Source,
-			"destination": mount.Destination,
-			"driver":      mount.Driver,
-			"mode":        mount.Mode,
-			"rw":          mount.RW,
-			"propagation": mount.Propagation,
-		})
-	}
-	sort.Slice(result, func(i, j int) bool {
-		return fmt.Sprint(result[i]["destination"]) < fmt.Sprint(result[j]["destination"])
-	})
-	return result
-}
-
-func comparableNetworks(networks map[string]*network.EndpointSettings) map[string]interface{} {
-	result := map[string]interface{}{}
-	for name, endpoint := range networks {
-		if endpoint == nil {
-			result[name] = nil
-			continue
-		}
-		result[name] = map[string]interface{}{
-			"ip_address":  endpoint.IPAddress,
-			"ipam_config": endpoint.IPAMConfig,
-			"links":       sortedStrings(endpoint.Links),
-			"aliases":     sortedStrings(endpoint.Aliases),
-			"mac_address": endpoint.MacAddress,
-			"driver_opts": endpoint.DriverOpts,
-		}
-	}
-	return result
-}
-
-func envMap(envs []string, redactProfile sensitiveProfile) map[string]string {
-	result := map[string]string{}
-	for _, env := range envs {
-		key, value, found := strings.Cut(env, "=")
-		if !found {
-			result[env] = ""
-			continue
-		}
-		if redactProfile != "none" && isSensitiveKeyWithProfile(key, redactProfile) {
-			value = redactedValue
-		} else if redactProfile != "none" {
-			value = redactSensitiveTextWithProfile(value, redactProfile)
-		}
-		result[key] = value
-	}
-	return result
-}
-
-func inspectDiffValue(value interface{}) string {
-	var buf bytes.Buffer
-	encoder := json.NewEncoder(&buf)
-	encoder.SetEscapeHTML(false)
-	if err := encoder.Encode(value); err != nil {
-		return fmt.Sprint(value)
-	}
-	return strings.TrimSpace(buf.String())
+	return inspectfields.Comparable(info, redactProfile)
 }
 
 func sortedStrings(items []string) []string {
@@ -340,20 +146,6 @@ func sortedStrings(items []string) []string {
 	return result
 }
 
-func sortedNetIPAddrs(items []netip.Addr) []string {
-	if len(items) == 0 {
-		return nil
-	}
-	result := make([]string, 0, len(items))
-	for _, item := range items {
-		if item.IsValid() {
-			result = append(result, item.String())
-		}
-	}
-	sort.Strings(result)
-	return result
-}
-
 func sortInspectDiffEntries(entries []InspectDiffEntry) {
 	sort.Slice(entries, func(i, j int) bool {
 		return entries[i].Path < entries[j].Path
@@ -13,6 +13,7 @@ import (
 	"strings"
 
 	"docker-manager/internal/completion"
+	"docker-manager/internal/ocilayout"
 
 	"github.com/Yui100901/MyGo/file_utils"
 	"github.com/moby/moby/api/types/image"
@@ -39,10 +40,11 @@ var newImageManager = func() (imageService, error) {
 }
 
 type SaveOptions struct {
-	Merge   bool
-	All     bool
-	DryRun  bool
-	Filters []string
+	Merge         bool
+	All           bool
+	DryRun        bool
+	Filters       []string
+	ArchiveFormat string
 }
 
 type imageExportTarget struct {
@@ -54,6 +56,8 @@ func NewLoadCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "load [path]",
 		Short: "导入 Docker 镜像，默认递归扫描 images 目录",
+		Long: `导入 Docker 镜像，默认递归扫描 images 目录。
+支持 docker-archive tar、OCI archive tar 以及 OCI layout 目录（包含 oci-layout 文件的目录会被整体打包后导入）。`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			path := "images"
 			if len(args) > 0 {
@@ -77,10 +81,15 @@ func NewSaveCommandWithDefaults(defaultOutputDir func() string) *cobra.Command {
 	var all bool
 	var dryRun bool
 	var filters []string
+	var archiveFormat string
 	cmd := &cobra.Command{
 		Use:   "save [path] [options]",
 		Short: "导出 Docker 镜像，默认输出到 images 目录",
 		RunE: func(cmd *cobra.Command, args []string) error {
+			format, err := ocilayout.ParseFormat(archiveFormat)
+			if err != nil {
+				return err
+			}
 			path := defaultSavePath(defaultOutputDir)
 			if len(args) > 0 {
 				path = args[0]
@@ -91,10 +100,11 @@ func NewSaveCommandWithDefaults(defaultOutputDir func() string) *cobra.Command {
 				}
 			}
 			opts := SaveOptions{
-				Merge:   merge,
-				All:     all,
-				DryRun:  dryRun,
-				Filters: filters,
+				Merge:         merge,
+				All:           all,
+				DryRun:        dryRun,
+				Filters:       filters,
+				ArchiveFormat: format,
 			}
 			if err := saveImagesWithOptions(cmd.Context(), path, opts); err != nil {
 				return fmt.Errorf("导出镜像失败: %w", err)
@@ -106,7 +116,11 @@ func NewSaveCommandWithDefaults(defaultOutputDir func() string) *cobra.Command {
 	cmd.Flags().BoolVarP(&all, "all", "a", false, "导出所有镜像，包括无 tag 镜像")
 	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "仅预览将导出的镜像，不写入文件")
 	cmd.Flags().StringArrayVarP(&filters, "filter", "f", nil, "筛选要导出的镜像，支持 id:/image:/repo:/tag:/digest:/label: 和 * ? 通配符，可重复指定")
+	cmd.Flags().StringVar(&archiveFormat, "archive-format", ocilayout.FormatDockerArchive, "归档格式: docker-archive、oci（OCI layout 目录）或 oci-archive（OCI layout tar）")
 	_ = cmd.RegisterFlagCompletionFunc("filter", completion.LocalImages)
+	_ = cmd.RegisterFlagCompletionFunc("archive-format", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{ocilayout.FormatDockerArchive, ocilayout.FormatOCI, ocilayout.FormatOCIArchive}, cobra.ShellCompDirectiveNoFileComp
+	})
 	return cmd
 }
 
@@ -146,7 +160,7 @@ func loadImages(ctx context.Context, path string, output io.Writer) error {
 			return errors.Join(append(loadErrs, err)...)
 		}
 		log.Printf("Load image archive [%d/%d]: %s", i+1, total, archive)
-		if err := imageManager.Load(ctx, archive, output); err != nil {
+		if err := loadImageArchive(ctx, archive, output); err != nil {
 			wrappedErr := fmt.Errorf("load image archive %s: %w", archive, err)
 			log.Println(wrappedErr)
 			loadErrs = append(loadErrs, wrappedErr)
@@ -159,6 +173,20 @@ func loadImages(ctx context.Context, path string, output io.Writer) error {
 	return errors.Join(loadErrs...)
 }
 
+// loadImageArchive hands one archive to Docker. OCI layout directories are
+// tarred to a temporary file first because the load API only takes a stream.
+func loadImageArchive(ctx context.Context, archive string, output io.Writer) error {
+	if !ocilayout.IsLayoutDir(archive) {
+		return imageManager.Load(ctx, archive, output)
+	}
+	tarPath, err := ocilayout.TarDirTemp(ctx, archive)
+	if err != nil {
+		return fmt.Errorf("打包 OCI layout 失败: %w", err)
+	}
+	defer os.Remove(tarPath)
+	return imageManager.Load(ctx, tarPath, output)
+}
+
 type imageArchiveDiscovery struct {
 	Archives []string
 	Skipped  int
@@ -169,6 +197,9 @@ func findDockerImageArchives(path string) (imageArchiveDiscovery, error) {
 	if err != nil {
 		return imageArchiveDiscovery{}, err
 	}
+	if info.IsDir() && ocilayout.IsLayoutDir(path) {
+		return imageArchiveDiscovery{Archives: []string{path}}, nil
+	}
 	if !info.IsDir() {
 		if isDockerImageArchive(path) {
 			return imageArchiveDiscovery{Archives: []string{path}}, nil
@@ -184,6 +215,10 @@ func findDockerImageArchives(path string) (imageArchiveDiscovery, error) {
 			return walkErr
 		}
 		if entry.IsDir() {
+			if ocilayout.IsLayoutDir(filePath) {
+				archives = append(archives, filePath)
+				return filepath.SkipDir
+			}
 			return nil
 		}
 		if !isDockerImageArchive(filePath) {
@@ -228,13 +263,13 @@ func saveImagesWithOptions(ctx context.Context, path string, opts SaveOptions) e
 		log.Println("Export image", target.ID, target.Name)
 	}
 	total := len(targets)
-	log.Printf("Save images: total=%d skipped=%d merge=%v dryRun=%v output=%s filters=%s", total, skipped, opts.Merge, opts.DryRun, path, strings.Join(opts.Filters, ","))
+	log.Printf("Save images: total=%d skipped=%d merge=%v dryRun=%v output=%s format=%s filters=%s", total, skipped, opts.Merge, opts.DryRun, path, opts.ArchiveFormat, strings.Join(opts.Filters, ","))
 
 	if opts.DryRun {
 		for i, target := range targets {
-			outputFile := filepath.Join(path, target.Name+".tar")
+			outputFile := imageArchivePath(path, target.Name, opts.ArchiveFormat)
 			if opts.Merge {
-				outputFile = filepath.Join(path, "images.tar")
+				outputFile = imageArchivePath(path, "images", opts.ArchiveFormat)
 			}
 			log.Printf("Dry run save image [%d/%d]: %s -> %s", i+1, total, target.ID, outputFile)
 		}
@@ -247,9 +282,9 @@ func saveImagesWithOptions(ctx context.Context, path string, opts SaveOptions) e
 		for _, target := range targets {
 			imageIDList = append(imageIDList, target.ID)
 		}
-		outputFile := filepath.Join(path, "images.tar")
+		outputFile := imageArchivePath(path, "images", opts.ArchiveFormat)
 		log.Printf("Save merged images [1/1]: images=%d output=%s", total, outputFile)
-		if err := imageManager.Save(ctx, imageIDList, outputFile); err != nil {
+		if err := saveImageArchive(ctx, imageIDList, outputFile, opts.ArchiveFormat); err != nil {
 			log.Printf("Save summary: total=%d success=0 failed=1 skipped=%d", total, skipped)
 			return err
 		}
@@ -262,9 +297,9 @@ func saveImagesWithOptions(ctx context.Context, path string, opts SaveOptions) e
 			if err := ctx.Err(); err != nil {
 				return errors.Join(append(saveErrs, err)...)
 			}
-			outputFile := filepath.Join(path, target.Name+".tar")
+			outputFile := imageArchivePath(path, target.Name, opts.ArchiveFormat)
 			log.Printf("Save image [%d/%d]: %s -> %s", i+1, total, target.ID, outputFile)
-			if err := imageManager.Save(ctx, []string{target.ID}, outputFile); err != nil {
+			if err := saveImageArchive(ctx, []string{target.ID}, outputFile, opts.ArchiveFormat); err != nil {
 				wrappedErr := fmt.Errorf("export image %s to %s: %w", target.ID, outputFile, err)
 				log.Println(wrappedErr)
 				saveErrs = append(saveErrs, wrappedErr)
@@ -277,6 +312,33 @@ func saveImagesWithOptions(ctx context.Context, path string, opts SaveOptions) e
 	}
 }
 
+// imageArchivePath is <name>.tar for tar formats and <name> for an OCI layout
+// directory.
+func imageArchivePath(dir, name, format string) string {
+	if format == ocilayout.FormatOCI {
+		return filepath.Join(dir, name)
+	}
+	return filepath.Join(dir, name+".tar")
+}
+
+// saveImageArchive exports through docker save and, for OCI formats, converts
+// the result next to the final path so a failed conversion leaves nothing
+// half written behind.
+func saveImageArchive(ctx context.Context, images []string, outputFile, format string) error {
+	if !ocilayout.IsOCI(format) {
+		return imageManager.Save(ctx, images, outputFile)
+	}
+	dockerArchive := outputFile + ".docker.tar"
+	defer os.Remove(dockerArchive)
+	if err := imageManager.Save(ctx, images, dockerArchive); err != nil {
+		return err
+	}
+	if err := ocilayout.ConvertDockerArchive(ctx, dockerArchive, outputFile, format == ocilayout.FormatOCIArchive); err != nil {
+		return fmt.Errorf("转换为 %s 失败: %w", format, err)
+	}
+	return nil
+}
+
 func buildImageExportTargets(images []image.Summary, opts SaveOptions) ([]imageExportTarget, int) {
 	var targets []imageExportTarget
 	skipped := 0
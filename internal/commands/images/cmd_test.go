@@ -1,6 +1,8 @@
 package images
 
 import (
+	"archive/tar"
+	"bytes"
 	"context"
 	"errors"
 	"io"
@@ -8,6 +10,8 @@ import (
 	"path/filepath"
 	"testing"
 
+	"docker-manager/internal/ocilayout"
+
 	"github.com/moby/moby/api/types/image"
 )
 
@@ -18,6 +22,9 @@ type fakeImageManager struct {
 	saveCalls []saveCall
 	loadCalls []string
 	cancel    context.CancelFunc
+	// archive, when set, is written to every Save output like docker save.
+	archive []byte
+	onLoad  func(inputFile string)
 }
 
 type saveCall struct {
@@ -37,6 +44,11 @@ func (m *fakeImageManager) Save(ctx context.Context, images []string, outputFile
 	if m.cancel != nil {
 		m.cancel()
 	}
+	if m.archive != nil {
+		if err := os.WriteFile(outputFile, m.archive, 0644); err != nil {
+			return err
+		}
+	}
 	if len(images) == 1 {
 		return m.saveErrs[images[0]]
 	}
@@ -45,6 +57,9 @@ func (m *fakeImageManager) Save(ctx context.Context, images []string, outputFile
 
 func (m *fakeImageManager) Load(ctx context.Context, inputFile string, output io.Writer) error {
 	m.loadCalls = append(m.loadCalls, inputFile)
+	if m.onLoad != nil {
+		m.onLoad(inputFile)
+	}
 	if m.cancel != nil {
 		m.cancel()
 	}
@@ -368,3 +383,67 @@ func TestLoadImagesStopsWhenContextCanceled(t *testing.T) {
 		t.Fatalf("Load called %d times, want 1 after cancellation", len(manager.loadCalls))
 	}
 }
+
+func TestSaveImagesWritesOCILayoutAndLoadImportsIt(t *testing.T) {
+	manager := &fakeImageManager{
+		images:  []image.Summary{{ID: "sha256:one", RepoTags: []string{"repo/app:v1"}}},
+		archive: testDockerArchive(t),
+	}
+	withFakeImageManager(t, manager)
+	dir := t.TempDir()
+
+	if err := saveImagesWithOptions(context.Background(), dir, SaveOptions{ArchiveFormat: ocilayout.FormatOCI}); err != nil {
+		t.Fatalf("saveImagesWithOptions(oci) error = %v", err)
+	}
+	layout := filepath.Join(dir, "repo_app-v1")
+	if !ocilayout.IsLayoutDir(layout) {
+		t.Fatalf("%s is not an OCI layout", layout)
+	}
+	if _, err := os.Stat(layout + ".docker.tar"); !os.IsNotExist(err) {
+		t.Fatalf("intermediate docker archive kept: %v", err)
+	}
+	if err := saveImagesWithOptions(context.Background(), dir, SaveOptions{Merge: true, ArchiveFormat: ocilayout.FormatOCIArchive}); err != nil {
+		t.Fatalf("saveImagesWithOptions(oci-archive) error = %v", err)
+	}
+
+	var loadedLayout bool
+	manager.onLoad = func(inputFile string) {
+		extracted := t.TempDir()
+		if err := ocilayout.ExtractTar(context.Background(), inputFile, extracted); err != nil {
+			t.Fatalf("loaded archive is not a tar: %v", err)
+		}
+		if ocilayout.IsLayoutDir(extracted) {
+			loadedLayout = true
+		}
+	}
+	if err := loadImages(context.Background(), dir, io.Discard); err != nil {
+		t.Fatalf("loadImages() error = %v", err)
+	}
+	if len(manager.loadCalls) != 2 || manager.loadCalls[0] != filepath.Join(dir, "images.tar") {
+		t.Fatalf("loadCalls = %v, want images.tar and the layout directory", manager.loadCalls)
+	}
+	if !loadedLayout {
+		t.Fatal("OCI layout was not loaded")
+	}
+}
+
+func testDockerArchive(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	files := []struct{ name, content string }{
+		{"manifest.json", `[{"Config":"c.json","Layers":["l/layer.tar"],"RepoTags":["repo/app:v1"]}]`},
+		{"c.json", `{"architecture":"amd64"}`},
+		{"l/layer.tar", "layer"},
+	}
+	for _, file := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: file.name, Mode: 0644, Size: int64(len(file.content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		_, _ = tw.Write([]byte(file.content))
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
@@ -11,6 +11,8 @@ import (
 	"path/filepath"
 	"strings"
 
+	"docker-manager/internal/ocilayout"
+
 	"github.com/Yui100901/MyGo/struct_utils"
 	digest "github.com/opencontainers/go-digest"
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
@@ -201,7 +203,12 @@ func resolveOutputFile(info *ImageInfo, opts PullOptions) (string, error) {
 	if outputDir == "" {
 		outputDir = "."
 	}
-	return filepath.Join(outputDir, defaultOutputFileName(info)), nil
+	name := defaultOutputFileName(info)
+	if opts.ArchiveFormat == ocilayout.FormatOCI {
+		// An OCI layout is a directory, not a tar file.
+		name = strings.TrimSuffix(name, ".tar")
+	}
+	return filepath.Join(outputDir, name), nil
 }
 
 func defaultOutputFileName(info *ImageInfo) string {
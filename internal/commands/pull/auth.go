@@ -37,8 +37,18 @@ func authHeaders(headers map[string]string, auth *pullRegistryAuth) map[string]s
 // WWW-Authenticate, load Docker credentials if present, then exchange them for
 // the Authorization header required by the next registry request.
 func (r *PullRunner) resolveRegistryAuth(ctx context.Context, header string, info *ImageInfo, opts PullOptions) (*pullRegistryAuth, error) {
+	return r.resolveRegistryAuthWithScope(ctx, header, "", info, opts)
+}
+
+// resolveRegistryAuthWithScope is resolveRegistryAuth with an explicit token
+// scope. Pushes need "pull,push" even when the challenge came from /v2/ and
+// therefore carries no scope of its own.
+func (r *PullRunner) resolveRegistryAuthWithScope(ctx context.Context, header, scope string, info *ImageInfo, opts PullOptions) (*pullRegistryAuth, error) {
 	challenge := parseAuthChallenge(header)
-	cred, credErr := r.loadPullRegistryCredential(ctx, info.Registry, opts.DockerConfig)
+	if scope != "" {
+		challenge.Params["scope"] = scope
+	}
+	cred, credErr := r.loadPullRegistryCredential(ctx, endpointHost(info), opts.DockerConfig)
 	switch strings.ToLower(challenge.Scheme) {
 	case "bearer":
 		token, err := r.fetchBearerToken(ctx, challenge, info, cred)
@@ -54,7 +64,7 @@ func (r *PullRunner) resolveRegistryAuth(ctx context.Context, header string, inf
 			return nil, credErr
 		}
 		if cred.Username == "" && cred.Password == "" {
-			return nil, fmt.Errorf("registry %s 需要 Basic 认证，但未找到 Docker 凭据", info.Registry)
+			return nil, fmt.Errorf("registry %s 需要 Basic 认证，但未找到 Docker 凭据", endpointHost(info))
 		}
 		return &pullRegistryAuth{Authorization: registryauth.BasicAuthHeader(cred.Username, cred.Password)}, nil
 	default:
@@ -67,7 +77,7 @@ func (r *PullRunner) resolveRegistryAuth(ctx context.Context, header string, inf
 			}
 		}
 		if strings.TrimSpace(header) == "" {
-			return nil, fmt.Errorf("registry %s 返回 401 但没有 WWW-Authenticate challenge", info.Registry)
+			return nil, fmt.Errorf("registry %s 返回 401 但没有 WWW-Authenticate challenge", endpointHost(info))
 		}
 		return nil, fmt.Errorf("不支持的 registry 认证方式 %q", challenge.Scheme)
 	}
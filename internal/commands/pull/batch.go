@@ -7,16 +7,21 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	"docker-manager/internal/commandflags"
 	"docker-manager/internal/parallel"
+	rpt "docker-manager/internal/report"
+	"docker-manager/internal/textfmt"
 
+	digest "github.com/opencontainers/go-digest"
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 )
 
@@ -32,8 +37,13 @@ type PullBatchOptions struct {
 	To             string
 	OutputDir      string
 	Load           bool
+	Direct         bool
+	Platforms      []ocispec.Platform
+	AllPlatforms   bool
+	ArchiveFormat  string
 	DockerConfig   string
 	PlainHTTP      bool
+	CopySignatures bool
 	Concurrency    int
 	Retries        int
 	SkipExisting   bool
@@ -42,6 +52,17 @@ type PullBatchOptions struct {
 	ReportFile     string
 	ProgressOutput io.Writer
 	commandflags.FormatOptions
+	// items overrides --to and the platforms per image, keyed by image
+	// reference; dm sync fills it from the sync spec.
+	items map[string]pullBatchItem
+}
+
+// pullBatchItem is the per-image part of a batch that differs between sync
+// spec entries.
+type pullBatchItem struct {
+	To           string
+	Platforms    []ocispec.Platform
+	AllPlatforms bool
 }
 
 type PullBatchReport struct {
@@ -57,13 +78,26 @@ type PullBatchReport struct {
 }
 
 type PullBatchResult struct {
-	Image      string `json:"image"`
-	Target     string `json:"target,omitempty"`
-	Status     string `json:"status"`
-	Attempts   int    `json:"attempts,omitempty"`
-	Message    string `json:"message,omitempty"`
-	StartedAt  string `json:"started_at,omitempty"`
-	FinishedAt string `json:"finished_at,omitempty"`
+	Image      string            `json:"image"`
+	Target     string            `json:"target,omitempty"`
+	Status     string            `json:"status"`
+	Attempts   int               `json:"attempts,omitempty"`
+	Message    string            `json:"message,omitempty"`
+	StartedAt  string            `json:"started_at,omitempty"`
+	FinishedAt string            `json:"finished_at,omitempty"`
+	Endpoint   string            `json:"endpoint,omitempty"`
+	Signature  *PullSignature    `json:"signature,omitempty"`
+	Partial    []PullPartialBlob `json:"partial,omitempty"`
+}
+
+// PullPartialBlob is a blob a failed pull left half-downloaded. The bytes
+// stay in the blob cache's temp dir and the next attempt continues them with
+// an HTTP Range request.
+type PullPartialBlob struct {
+	Digest     string `json:"digest"`
+	Path       string `json:"path"`
+	Downloaded int64  `json:"downloaded"`
+	Total      int64  `json:"total,omitempty"`
 }
 
 type pullBatchState struct {
@@ -72,13 +106,54 @@ type pullBatchState struct {
 }
 
 type pullBatchStateItem struct {
-	Image      string `json:"image"`
-	Target     string `json:"target,omitempty"`
-	Status     string `json:"status"`
-	Attempts   int    `json:"attempts,omitempty"`
-	Message    string `json:"message,omitempty"`
-	StartedAt  string `json:"started_at,omitempty"`
-	FinishedAt string `json:"finished_at,omitempty"`
+	Image      string            `json:"image"`
+	Target     string            `json:"target,omitempty"`
+	Status     string            `json:"status"`
+	Attempts   int               `json:"attempts,omitempty"`
+	Message    string            `json:"message,omitempty"`
+	StartedAt  string            `json:"started_at,omitempty"`
+	FinishedAt string            `json:"finished_at,omitempty"`
+	Partial    []PullPartialBlob `json:"partial,omitempty"`
+}
+
+// pullPartials tracks the partial blobs of one batch item across attempts.
+type pullPartials struct {
+	mu    sync.Mutex
+	blobs map[digest.Digest]PullPartialBlob
+}
+
+func newPullPartials() *pullPartials {
+	return &pullPartials{blobs: map[digest.Digest]PullPartialBlob{}}
+}
+
+func (p *pullPartials) record(desc ocispec.Descriptor, partPath string) {
+	size := partialDownloadSize(partPath)
+	if p == nil || size == 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.blobs[desc.Digest] = PullPartialBlob{Digest: string(desc.Digest), Path: partPath, Downloaded: size, Total: desc.Size}
+}
+
+func (p *pullPartials) done(d digest.Digest) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.blobs, d)
+}
+
+func (p *pullPartials) list() []PullPartialBlob {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	blobs := make([]PullPartialBlob, 0, len(p.blobs))
+	for _, blob := range p.blobs {
+		blobs = append(blobs, blob)
+	}
+	sort.Slice(blobs, func(i, j int) bool { return blobs[i].Digest < blobs[j].Digest })
+	return blobs
 }
 
 type pullBatchFunc func(image string, opts PullOptions) error
@@ -102,7 +177,7 @@ func runPullBatchWithDeps(ctx context.Context, opts PullBatchOptions, pull pullB
 	if opts.To != "" && len(images) > 1 && isTaggedImageRef(opts.To) {
 		return PullBatchReport{}, fmt.Errorf("--to 使用完整镜像名时只能同步单个镜像；批量同步请使用 registry 或 namespace 前缀")
 	}
-	if opts.SkipExisting && opts.To == "" {
+	if opts.SkipExisting && opts.To == "" && opts.items == nil {
 		return PullBatchReport{}, fmt.Errorf("--skip-existing 需要配合 --to 使用")
 	}
 	if opts.Concurrency <= 0 {
@@ -182,15 +257,17 @@ func updatePullBatchStateItem(state pullBatchState, result PullBatchResult) {
 		Message:    result.Message,
 		StartedAt:  result.StartedAt,
 		FinishedAt: result.FinishedAt,
+		Partial:    result.Partial,
 	}
 }
 
 func runPullBatchItem(ctx context.Context, imageName string, opts PullBatchOptions, state pullBatchState, pull pullBatchFunc, exists pullBatchExistsFunc, progressOutput io.Writer) PullBatchResult {
 	startedAt := time.Now().Format(time.RFC3339)
 	result := PullBatchResult{Image: imageName, Status: pullBatchStatusFailed, StartedAt: startedAt}
+	entry := opts.item(imageName)
 	target := ""
-	if opts.To != "" {
-		resolved, err := resolvePullBatchTarget(imageName, opts.To)
+	if entry.To != "" {
+		resolved, err := resolvePullBatchTarget(imageName, entry.To)
 		if err != nil {
 			result.Message = err.Error()
 			result.FinishedAt = time.Now().Format(time.RFC3339)
@@ -206,17 +283,35 @@ func runPullBatchItem(ctx context.Context, imageName string, opts PullBatchOptio
 			result.FinishedAt = time.Now().Format(time.RFC3339)
 			return result
 		}
+		if item, ok := state.Items[imageName]; ok {
+			for _, blob := range item.Partial {
+				if partialDownloadSize(blob.Path) > 0 {
+					log.Printf("Resume partial blob: image=%s digest=%s downloaded=%d total=%d", imageName, blob.Digest, blob.Downloaded, blob.Total)
+				}
+			}
+		}
 	}
+	partials := newPullPartials()
+	endpoint := &pullEndpointRecord{}
+	signature := &PullSignature{}
 	pullOpts := PullOptions{
 		Context:        ctx,
 		OutputDir:      opts.OutputDir,
 		Load:           opts.Load,
-		To:             opts.To,
+		To:             entry.To,
+		Direct:         opts.Direct,
+		Platforms:      entry.Platforms,
+		AllPlatforms:   entry.AllPlatforms,
+		ArchiveFormat:  opts.ArchiveFormat,
 		DockerConfig:   opts.DockerConfig,
 		PlainHTTP:      opts.PlainHTTP,
 		ProgressOutput: progressOutput,
+		CopySignatures: opts.CopySignatures,
+		partials:       partials,
+		endpoint:       endpoint,
+		signature:      signature,
 	}
-	if opts.SkipExisting {
+	if opts.SkipExisting && target != "" {
 		found, err := exists(ctx, imageName, target, pullOpts)
 		if err != nil {
 			result.Message = "检查目标 manifest 失败: " + err.Error()
@@ -240,10 +335,13 @@ func runPullBatchItem(ctx context.Context, imageName string, opts PullBatchOptio
 			result.FinishedAt = time.Now().Format(time.RFC3339)
 			return result
 		}
-		if err := pull(imageName, pullOpts); err != nil {
+		err := pull(imageName, pullOpts)
+		result.Signature = signature.recorded()
+		if err != nil {
 			if errors.Is(err, context.Canceled) {
 				result.Message = err.Error()
 				result.FinishedAt = time.Now().Format(time.RFC3339)
+				result.Partial = partials.list()
 				return result
 			}
 			lastErr = err
@@ -251,6 +349,7 @@ func runPullBatchItem(ctx context.Context, imageName string, opts PullBatchOptio
 		}
 		result.Status = pullBatchStatusSuccess
 		result.Message = "同步成功"
+		result.Endpoint = endpoint.String()
 		result.FinishedAt = time.Now().Format(time.RFC3339)
 		return result
 	}
@@ -258,9 +357,17 @@ func runPullBatchItem(ctx context.Context, imageName string, opts PullBatchOptio
 		result.Message = lastErr.Error()
 	}
 	result.FinishedAt = time.Now().Format(time.RFC3339)
+	result.Partial = partials.list()
 	return result
 }
 
+func (opts PullBatchOptions) item(imageName string) pullBatchItem {
+	if item, ok := opts.items[imageName]; ok {
+		return item
+	}
+	return pullBatchItem{To: opts.To, Platforms: opts.Platforms, AllPlatforms: opts.AllPlatforms}
+}
+
 func (r *PullRunner) targetManifestExists(ctx context.Context, imageName, target string, opts PullOptions) (bool, error) {
 	info, err := parseImageInfo(target)
 	if err != nil {
@@ -382,6 +489,18 @@ func writePullBatchState(path string, state pullBatchState) error {
 	return writeAtomicJSON(path, data)
 }
 
+// writePullBatchOutput prints the batch report in the requested format and
+// writes the --report file when one was asked for.
+func writePullBatchOutput(w io.Writer, opts PullBatchOptions, report PullBatchReport) error {
+	err := rpt.Print(w, opts.Format, report, func(w io.Writer) {
+		printPullBatchReport(w, report)
+	})
+	if err != nil || opts.ReportFile == "" {
+		return err
+	}
+	return writePullBatchReport(opts.ReportFile, report)
+}
+
 func writePullBatchReport(path string, report PullBatchReport) error {
 	data, err := json.MarshalIndent(report, "", "  ")
 	if err != nil {
@@ -434,9 +553,23 @@ func printPullBatchReport(w io.Writer, report PullBatchReport) {
 		if item.Attempts > 0 {
 			_, _ = fmt.Fprintf(w, " attempts=%d", item.Attempts)
 		}
+		if item.Endpoint != "" {
+			_, _ = fmt.Fprintf(w, " endpoint=%s", item.Endpoint)
+		}
+		if sig := item.Signature; sig != nil && sig.Verified {
+			_, _ = fmt.Fprintf(w, " signature=verified(%d)", sig.Signatures)
+		} else if sig != nil && sig.Key != "" {
+			_, _ = fmt.Fprint(w, " signature=unverified")
+		}
 		if item.Message != "" {
 			_, _ = fmt.Fprintf(w, " (%s)", item.Message)
 		}
 		_, _ = fmt.Fprintln(w)
+		if item.Signature != nil && len(item.Signature.Copied) > 0 {
+			_, _ = fmt.Fprintf(w, "    signatures copied: %s\n", strings.Join(item.Signature.Copied, ", "))
+		}
+		for _, blob := range item.Partial {
+			_, _ = fmt.Fprintf(w, "    partial %s %s/%s，--resume 时续传\n", blob.Digest, textfmt.SignedBytes(blob.Downloaded), textfmt.SignedBytes(blob.Total))
+		}
 	}
 }
@@ -0,0 +1,450 @@
+package pull
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"docker-manager/internal/commandflags"
+	rpt "docker-manager/internal/report"
+	"docker-manager/internal/sensitive"
+	"docker-manager/internal/textfmt"
+
+	"github.com/Yui100901/MyGo/struct_utils"
+	"github.com/distribution/reference"
+	digest "github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/spf13/cobra"
+)
+
+// RegistryTagsReport is the output of dm registry tags. Next is set when
+// --limit stopped before the end of the list and is the --last to continue.
+type RegistryTagsReport struct {
+	Repository string   `json:"repository"`
+	Listed     int      `json:"listed"`
+	Tags       []string `json:"tags"`
+	Next       string   `json:"next,omitempty"`
+}
+
+// RegistryCatalogReport is the output of dm registry catalog.
+type RegistryCatalogReport struct {
+	Registry     string   `json:"registry"`
+	Repositories []string `json:"repositories"`
+	Next         string   `json:"next,omitempty"`
+}
+
+// RegistryInspectReport describes a remote image without pulling it: the
+// manifest (or index) digest and, per platform, layers and config.
+type RegistryInspectReport struct {
+	Reference string                 `json:"reference"`
+	Digest    string                 `json:"digest"`
+	MediaType string                 `json:"media_type"`
+	Platforms []RegistryInspectImage `json:"platforms"`
+}
+
+type RegistryInspectImage struct {
+	Platform     string                 `json:"platform"`
+	Digest       string                 `json:"digest"`
+	Created      string                 `json:"created,omitempty"`
+	Size         int64                  `json:"size"`
+	Entrypoint   []string               `json:"entrypoint,omitempty"`
+	Cmd          []string               `json:"cmd,omitempty"`
+	WorkingDir   string                 `json:"working_dir,omitempty"`
+	User         string                 `json:"user,omitempty"`
+	ExposedPorts []string               `json:"exposed_ports,omitempty"`
+	Env          []string               `json:"env,omitempty"`
+	Labels       map[string]string      `json:"labels,omitempty"`
+	Layers       []RegistryInspectLayer `json:"layers"`
+}
+
+type RegistryInspectLayer struct {
+	Digest    string `json:"digest"`
+	MediaType string `json:"media_type"`
+	Size      int64  `json:"size"`
+}
+
+// registryBrowseOptions are the client flags shared by the browse commands.
+type registryBrowseOptions struct {
+	commandflags.FormatOptions
+	Proxy        string
+	Timeout      time.Duration
+	DockerConfig string
+	PlainHTTP    bool
+}
+
+// NewRegistryCommandsWithDefaults returns the remote registry subcommands
+// (tags, inspect, catalog, prune) that hang off the dm registry shortcut.
+func NewRegistryCommandsWithDefaults(defaults func() CommandDefaults) []*cobra.Command {
+	return []*cobra.Command{
+		newRegistryTagsCommand(defaults),
+		newRegistryInspectCommand(defaults),
+		newRegistryCatalogCommand(defaults),
+		newRegistryPruneCommand(defaults),
+	}
+}
+
+func newRegistryTagsCommand(defaults func() CommandDefaults) *cobra.Command {
+	opts := registryBrowseOptions{Timeout: defaultPullTimeout}
+	var regex, semver, last string
+	var latest, limit int
+	pageSize := tagsPageSize
+	cmd := &cobra.Command{
+		Use:   "tags <repo>",
+		Short: "列出远程仓库的 tag",
+		Long: `通过 /v2/<name>/tags/list 列出远程仓库的 tag，自动跟随 Link 分页。
+--limit 只读取前 N 个 tag，输出中的 next 可作为 --last 继续读取下一页；--regex、--semver、--latest 的含义与 sync.yaml 相同，在读取到的 tag 上筛选。`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if pageSize <= 0 {
+				return fmt.Errorf("--page-size 必须大于 0")
+			}
+			if limit < 0 {
+				return fmt.Errorf("--limit 不能为负数")
+			}
+			selector, err := newTagSelector(regex, semver, latest)
+			if err != nil {
+				return err
+			}
+			info, err := parseRepositoryArg(args[0])
+			if err != nil {
+				return err
+			}
+			runner, err := opts.runner(cmd, defaults)
+			if err != nil {
+				return err
+			}
+			tags, next, err := runner.listTagsPage(cmd.Context(), info, opts.pullOptions(cmd.Context()), pageSize, last, limit)
+			if err != nil {
+				return err
+			}
+			report := RegistryTagsReport{Repository: info.Registry + "/" + imagePath(info), Listed: len(tags), Tags: selector.selectTags(tags), Next: next}
+			if report.Tags == nil {
+				report.Tags = []string{}
+			}
+			return rpt.Print(cmd.OutOrStdout(), opts.Format, report, func(w io.Writer) {
+				printRegistryTags(w, report)
+			})
+		},
+	}
+	cmd.Flags().StringVar(&regex, "regex", "", "只保留匹配该正则的 tag")
+	cmd.Flags().StringVar(&semver, "semver", "", "只保留满足 semver 范围的 tag，例如 '>=1.26 <2'")
+	cmd.Flags().IntVar(&latest, "latest", 0, "只保留版本最高的 N 个 tag")
+	cmd.Flags().IntVar(&limit, "limit", 0, "最多读取 N 个 tag，0 表示读取全部")
+	cmd.Flags().StringVar(&last, "last", "", "从该 tag 之后开始读取，用于继续上一页")
+	cmd.Flags().IntVar(&pageSize, "page-size", pageSize, "每次请求的分页大小（n 参数）")
+	opts.addFlags(cmd)
+	return cmd
+}
+
+func newRegistryInspectCommand(defaults func() CommandDefaults) *cobra.Command {
+	opts := registryBrowseOptions{Timeout: defaultPullTimeout}
+	var platformValues []string
+	var redactSecrets bool
+	var redactProfile string
+	cmd := &cobra.Command{
+		Use:   "inspect <ref>",
+		Short: "查看远程镜像的清单和配置，不拉取镜像",
+		Long: `读取远程镜像的 manifest 或多架构索引，以及每个平台的镜像配置，输出 digest、平台、层大小、创建时间、entrypoint/cmd、env 和 label。
+默认展示索引中的全部平台，--platform 只展示指定平台；--redact-secrets 会脱敏 env 和 label 中的敏感值。`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			profile, err := sensitive.NormalizeProfile(redactProfile, redactSecrets)
+			if err != nil {
+				return err
+			}
+			platforms, err := parsePlatforms(platformValues)
+			if err != nil {
+				return err
+			}
+			info, err := parseImageInfo(args[0])
+			if err != nil {
+				return fmt.Errorf("解析镜像名称失败: %w", err)
+			}
+			runner, err := opts.runner(cmd, defaults)
+			if err != nil {
+				return err
+			}
+			pullOpts := opts.pullOptions(cmd.Context())
+			pullOpts.Platforms = platforms
+			pullOpts.AllPlatforms = len(platforms) == 0
+			report, err := runner.inspectRemoteImage(cmd.Context(), info, pullOpts)
+			if err != nil {
+				return err
+			}
+			for i := range report.Platforms {
+				image := &report.Platforms[i]
+				for j, env := range image.Env {
+					image.Env[j] = sensitive.RedactEnvValue(env, profile)
+				}
+				image.Labels = sensitive.RedactStringMap(image.Labels, profile)
+			}
+			return rpt.Print(cmd.OutOrStdout(), opts.Format, report, func(w io.Writer) {
+				printRegistryInspect(w, report)
+			})
+		},
+	}
+	cmd.Flags().StringArrayVar(&platformValues, "platform", nil, "只展示指定平台，格式 os/arch[/variant]，可重复或逗号分隔")
+	commandflags.AddRedactFlags(cmd, &redactSecrets, &redactProfile, "脱敏 env 和 label 中的敏感值")
+	opts.addFlags(cmd)
+	return cmd
+}
+
+func newRegistryCatalogCommand(defaults func() CommandDefaults) *cobra.Command {
+	opts := registryBrowseOptions{Timeout: defaultPullTimeout}
+	var last string
+	var limit int
+	pageSize := tagsPageSize
+	cmd := &cobra.Command{
+		Use:   "catalog <registry>",
+		Short: "列出 registry 中的仓库",
+		Long: `通过 /v2/_catalog 列出 registry 中的仓库，自动跟随 Link 分页；需要 registry 开启 catalog 接口（Docker Hub 等公共 registry 通常不开放）。
+--limit 只读取前 N 个仓库，输出中的 next 可作为 --last 继续读取下一页。`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if pageSize <= 0 {
+				return fmt.Errorf("--page-size 必须大于 0")
+			}
+			if limit < 0 {
+				return fmt.Errorf("--limit 不能为负数")
+			}
+			registry, plainHTTP, err := parseRegistryArg(args[0])
+			if err != nil {
+				return err
+			}
+			runner, err := opts.runner(cmd, defaults)
+			if err != nil {
+				return err
+			}
+			pullOpts := opts.pullOptions(cmd.Context())
+			pullOpts.PlainHTTP = pullOpts.PlainHTTP || plainHTTP
+			info := &ImageInfo{Registry: registry}
+			first := registryBaseURL(pullOpts, info) + "/_catalog?" + pageQuery(pageSize, last)
+			repositories, next, err := runner.listRegistryPages(cmd.Context(), first, "registry:catalog:*", info, pullOpts, limit, registry+" 的仓库")
+			if err != nil {
+				return err
+			}
+			if repositories == nil {
+				repositories = []string{}
+			}
+			report := RegistryCatalogReport{Registry: registry, Repositories: repositories, Next: next}
+			return rpt.Print(cmd.OutOrStdout(), opts.Format, report, func(w io.Writer) {
+				printRegistryCatalog(w, report)
+			})
+		},
+	}
+	cmd.Flags().IntVar(&limit, "limit", 0, "最多读取 N 个仓库，0 表示读取全部")
+	cmd.Flags().StringVar(&last, "last", "", "从该仓库之后开始读取，用于继续上一页")
+	cmd.Flags().IntVar(&pageSize, "page-size", pageSize, "每次请求的分页大小（n 参数）")
+	opts.addFlags(cmd)
+	return cmd
+}
+
+func (o *registryBrowseOptions) addFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&o.Proxy, "proxy", "", "强制指定 HTTP 代理，例如 http://127.0.0.1:7890；为空时使用环境变量代理")
+	cmd.Flags().DurationVar(&o.Timeout, "timeout", o.Timeout, "连接、TLS 握手和响应头超时时间，例如 30s、2m")
+	commandflags.AddDockerConfigFlag(cmd, &o.DockerConfig)
+	commandflags.AddPlainHTTPFlag(cmd, &o.PlainHTTP)
+	commandflags.AddReportFormatFlag(cmd, &o.Format)
+}
+
+func (o *registryBrowseOptions) runner(cmd *cobra.Command, defaults func() CommandDefaults) (*PullRunner, error) {
+	if defaults != nil && !cmd.Flags().Changed("proxy") {
+		if proxy := defaults().Proxy; proxy != "" {
+			o.Proxy = proxy
+		}
+	}
+	if o.Timeout <= 0 {
+		return nil, fmt.Errorf("--timeout 必须大于 0")
+	}
+	configureHTTPLogging(false)
+	runner, err := NewPullRunnerWithTimeout(o.Proxy, "linux", "amd64", o.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("配置代理失败: %w", err)
+	}
+	return runner, nil
+}
+
+func (o *registryBrowseOptions) pullOptions(ctx context.Context) PullOptions {
+	return PullOptions{Context: ctx, DockerConfig: o.DockerConfig, PlainHTTP: o.PlainHTTP}
+}
+
+// parseRepositoryArg accepts a repository without tag or digest, the form
+// dm registry tags and sync.yaml sources use.
+func parseRepositoryArg(value string) (*ImageInfo, error) {
+	named, err := reference.ParseNormalizedNamed(value)
+	if err != nil {
+		return nil, fmt.Errorf("解析仓库名称失败: %w", err)
+	}
+	if !reference.IsNameOnly(named) {
+		return nil, fmt.Errorf("%s 应为不带 tag 或 digest 的仓库名", value)
+	}
+	return parseImageInfo(value)
+}
+
+// parseRegistryArg normalizes a registry host for dm registry catalog; an
+// http:// prefix selects plain HTTP.
+func parseRegistryArg(value string) (string, bool, error) {
+	value = strings.TrimSpace(value)
+	plainHTTP := strings.HasPrefix(strings.ToLower(value), "http://")
+	if strings.Contains(value, "://") {
+		parsed, err := url.Parse(value)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+			return "", false, fmt.Errorf("无效的 registry 地址 %q", value)
+		}
+		value = parsed.Host + parsed.Path
+	}
+	value = strings.TrimSuffix(strings.TrimSuffix(value, "/"), "/v2")
+	if value == "" {
+		return "", false, fmt.Errorf("registry 不能为空")
+	}
+	if strings.Contains(value, "/") {
+		return "", false, fmt.Errorf("registry 只接受主机名和可选端口，例如 registry.local:5000")
+	}
+	if value == dockerHubDomain || value == "index.docker.io" {
+		value = defaultRegistry
+	}
+	return value, plainHTTP, nil
+}
+
+// inspectRemoteImage reads the manifest or index of info and the config of
+// every selected platform. Nothing is downloaded besides manifests and configs.
+func (r *PullRunner) inspectRemoteImage(ctx context.Context, info *ImageInfo, opts PullOptions) (RegistryInspectReport, error) {
+	index, images, auth, err := r.fetchPlatformManifests(ctx, info, opts)
+	if err != nil {
+		return RegistryInspectReport{}, err
+	}
+	report := RegistryInspectReport{Reference: getImageRef(info)}
+	if index != nil {
+		report.Digest = digest.FromBytes(index.Raw).String()
+		report.MediaType = index.MediaType
+	} else {
+		report.Digest = images[0].Descriptor.Digest.String()
+		report.MediaType = images[0].Manifest.MediaType
+	}
+	for _, image := range images {
+		manifest := image.Manifest.Manifest
+		configURL := registryAPIURL(opts, info, "blobs", string(manifest.Config.Digest))
+		data, nextAuth, err := r.fetchRegistryBytesWithRetry(ctx, configURL, nil, nil, info, opts, auth)
+		auth = nextAuth
+		if err != nil {
+			return report, fmt.Errorf("获取 %s 的镜像配置失败: %w", platformLabel(image.Descriptor), err)
+		}
+		if got := digest.FromBytes(data); got != manifest.Config.Digest {
+			return report, fmt.Errorf("镜像配置 digest 不匹配: 期望 %s，实际 %s", manifest.Config.Digest, got)
+		}
+		config, err := struct_utils.UnmarshalData[ocispec.Image](data, struct_utils.JSON)
+		if err != nil {
+			return report, fmt.Errorf("解析 %s 的镜像配置失败: %w", platformLabel(image.Descriptor), err)
+		}
+		report.Platforms = append(report.Platforms, inspectImage(image, config))
+	}
+	return report, nil
+}
+
+func inspectImage(image platformImage, config *ocispec.Image) RegistryInspectImage {
+	platform := config.Platform
+	if image.Descriptor.Platform != nil {
+		platform = *image.Descriptor.Platform
+	}
+	result := RegistryInspectImage{
+		Platform:   formatPlatform(platform),
+		Digest:     image.Descriptor.Digest.String(),
+		Entrypoint: config.Config.Entrypoint,
+		Cmd:        config.Config.Cmd,
+		WorkingDir: config.Config.WorkingDir,
+		User:       config.Config.User,
+		Env:        config.Config.Env,
+		Labels:     config.Config.Labels,
+		Layers:     []RegistryInspectLayer{},
+	}
+	if config.Created != nil {
+		result.Created = config.Created.UTC().Format(time.RFC3339)
+	}
+	for port := range config.Config.ExposedPorts {
+		result.ExposedPorts = append(result.ExposedPorts, port)
+	}
+	sort.Strings(result.ExposedPorts)
+	for _, layer := range image.Manifest.Manifest.Layers {
+		result.Size += layer.Size
+		result.Layers = append(result.Layers, RegistryInspectLayer{Digest: layer.Digest.String(), MediaType: layer.MediaType, Size: layer.Size})
+	}
+	return result
+}
+
+func printRegistryTags(w io.Writer, report RegistryTagsReport) {
+	_, _ = fmt.Fprintf(w, "Registry tags: repository=%s listed=%d selected=%d\n", report.Repository, report.Listed, len(report.Tags))
+	for _, tag := range report.Tags {
+		_, _ = fmt.Fprintf(w, "- %s\n", tag)
+	}
+	if report.Next != "" {
+		_, _ = fmt.Fprintf(w, "还有更多 tag；使用 --last %s 继续读取\n", report.Next)
+	}
+}
+
+func printRegistryCatalog(w io.Writer, report RegistryCatalogReport) {
+	_, _ = fmt.Fprintf(w, "Registry catalog: registry=%s repositories=%d\n", report.Registry, len(report.Repositories))
+	for _, repository := range report.Repositories {
+		_, _ = fmt.Fprintf(w, "- %s\n", repository)
+	}
+	if report.Next != "" {
+		_, _ = fmt.Fprintf(w, "还有更多仓库；使用 --last %s 继续读取\n", report.Next)
+	}
+}
+
+func printRegistryInspect(w io.Writer, report RegistryInspectReport) {
+	_, _ = fmt.Fprintf(w, "Registry inspect: reference=%s digest=%s media_type=%s platforms=%d\n", report.Reference, report.Digest, report.MediaType, len(report.Platforms))
+	for _, image := range report.Platforms {
+		_, _ = fmt.Fprintf(w, "\n[%s] digest=%s size=%s layers=%d", image.Platform, image.Digest, textfmt.Bytes(uint64(image.Size)), len(image.Layers))
+		if image.Created != "" {
+			_, _ = fmt.Fprintf(w, " created=%s", image.Created)
+		}
+		_, _ = fmt.Fprintln(w)
+		printInspectList(w, "entrypoint", image.Entrypoint)
+		printInspectList(w, "cmd", image.Cmd)
+		if image.WorkingDir != "" {
+			_, _ = fmt.Fprintf(w, "  workdir: %s\n", image.WorkingDir)
+		}
+		if image.User != "" {
+			_, _ = fmt.Fprintf(w, "  user: %s\n", image.User)
+		}
+		if len(image.ExposedPorts) > 0 {
+			_, _ = fmt.Fprintf(w, "  ports: %s\n", strings.Join(image.ExposedPorts, ", "))
+		}
+		if len(image.Env) > 0 {
+			_, _ = fmt.Fprintln(w, "  env:")
+			for _, env := range image.Env {
+				_, _ = fmt.Fprintf(w, "    %s\n", env)
+			}
+		}
+		if len(image.Labels) > 0 {
+			_, _ = fmt.Fprintln(w, "  labels:")
+			keys := make([]string, 0, len(image.Labels))
+			for key := range image.Labels {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				_, _ = fmt.Fprintf(w, "    %s=%s\n", key, image.Labels[key])
+			}
+		}
+		_, _ = fmt.Fprintln(w, "  layers:")
+		for _, layer := range image.Layers {
+			_, _ = fmt.Fprintf(w, "    %s %s\n", layer.Digest, textfmt.Bytes(uint64(layer.Size)))
+		}
+	}
+}
+
+// printInspectList prints entrypoint/cmd in exec form so arguments with
+// spaces stay readable.
+func printInspectList(w io.Writer, name string, values []string) {
+	if len(values) == 0 {
+		return
+	}
+	data, _ := json.Marshal(values)
+	_, _ = fmt.Fprintf(w, "  %s: %s\n", name, data)
+}
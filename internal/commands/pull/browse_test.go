@@ -0,0 +1,193 @@
+package pull
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	digest "github.com/opencontainers/go-digest"
+	"github.com/spf13/cobra"
+)
+
+func runRegistryCommand(t *testing.T, name string, args ...string) []byte {
+	t.Helper()
+	var cmd *cobra.Command
+	for _, sub := range NewRegistryCommandsWithDefaults(nil) {
+		if sub.Name() == name {
+			cmd = sub
+		}
+	}
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetArgs(append(args, "--plain-http", "--format", "json"))
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("%s Execute() error = %v\n%s", name, err, out.String())
+	}
+	return out.Bytes()
+}
+
+func TestRegistryTagsPagesLimitsAndFilters(t *testing.T) {
+	registry := newFakeRegistry(t, "")
+	registry.tagsPage = 2
+	for _, tag := range []string{"1.25.0", "1.26.0", "1.26.1", "1.27.0", "latest"} {
+		registry.addImage("library/nginx", tag, []byte(`{"architecture":"amd64"}`), []byte("nginx-"+tag))
+	}
+	repo := registry.host() + "/library/nginx"
+
+	var all RegistryTagsReport
+	if err := json.Unmarshal(runRegistryCommand(t, "tags", repo, "--semver", ">=1.26"), &all); err != nil {
+		t.Fatal(err)
+	}
+	if all.Listed != 5 || strings.Join(all.Tags, ",") != "1.26.0,1.26.1,1.27.0" || all.Next != "" {
+		t.Fatalf("report = %#v", all)
+	}
+
+	var page RegistryTagsReport
+	if err := json.Unmarshal(runRegistryCommand(t, "tags", repo, "--limit", "3"), &page); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(page.Tags, ",") != "1.25.0,1.26.0,1.26.1" || page.Next != "1.26.1" {
+		t.Fatalf("first page = %#v", page)
+	}
+	var rest RegistryTagsReport
+	if err := json.Unmarshal(runRegistryCommand(t, "tags", repo, "--last", page.Next), &rest); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(rest.Tags, ",") != "1.27.0,latest" || rest.Next != "" {
+		t.Fatalf("next page = %#v", rest)
+	}
+
+	cmd := newRegistryTagsCommand(nil)
+	cmd.SetArgs([]string{repo + ":1.27.0"})
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+	if err := cmd.Execute(); err == nil {
+		t.Fatal("tags with a tagged reference succeeded")
+	}
+}
+
+func TestRegistryInspectShowsEveryPlatformConfig(t *testing.T) {
+	registry := newFakeRegistry(t, "")
+	amd64 := registry.addImage("team/app", "amd64", []byte(`{"architecture":"amd64","os":"linux","created":"2026-03-01T10:00:00Z",`+
+		`"config":{"Entrypoint":["/entrypoint.sh"],"Cmd":["serve"],"Env":["PATH=/usr/bin","DB_PASSWORD=hunter2"],"Labels":{"org.opencontainers.image.version":"1.2.0"},"ExposedPorts":{"8080/tcp":{}}}}`),
+		[]byte("base-layer"), []byte("app-layer"))
+	arm64 := registry.addImage("team/app", "arm64", []byte(`{"architecture":"arm64","os":"linux","config":{"Cmd":["serve"]}}`), []byte("arm-layer"))
+	index := registry.addIndex("team/app", "v1",
+		fakeIndexEntry{manifest: amd64, os: "linux", arch: "amd64"},
+		fakeIndexEntry{manifest: arm64, os: "linux", arch: "arm64", variant: "v8"},
+		fakeIndexEntry{manifest: []byte(`{"schemaVersion":2}`), os: "unknown", arch: "unknown"},
+	)
+
+	var report RegistryInspectReport
+	if err := json.Unmarshal(runRegistryCommand(t, "inspect", registry.host()+"/team/app:v1", "--redact-secrets"), &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Digest != digest.FromBytes(index).String() || len(report.Platforms) != 2 {
+		t.Fatalf("report = %#v", report)
+	}
+	image := report.Platforms[0]
+	if image.Platform != "linux/amd64" || image.Created != "2026-03-01T10:00:00Z" || image.Size != int64(len("base-layer")+len("app-layer")) ||
+		len(image.Layers) != 2 || strings.Join(image.Entrypoint, " ") != "/entrypoint.sh" ||
+		image.Labels["org.opencontainers.image.version"] != "1.2.0" || strings.Join(image.ExposedPorts, ",") != "8080/tcp" {
+		t.Fatalf("amd64 = %#v", image)
+	}
+	if strings.Contains(strings.Join(image.Env, ","), "hunter2") {
+		t.Fatalf("env not redacted: %v", image.Env)
+	}
+	if report.Platforms[1].Platform != "linux/arm64/v8" || report.Platforms[1].Digest != digest.FromBytes(arm64).String() {
+		t.Fatalf("arm64 = %#v", report.Platforms[1])
+	}
+
+	if err := json.Unmarshal(runRegistryCommand(t, "inspect", registry.host()+"/team/app:amd64"), &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Digest != digest.FromBytes(amd64).String() || len(report.Platforms) != 1 || report.Platforms[0].Platform != "linux/amd64" {
+		t.Fatalf("single manifest report = %#v", report)
+	}
+}
+
+func TestRegistryCatalogUsesCatalogScope(t *testing.T) {
+	registry := newFakeRegistry(t, "secret")
+	registry.tagsPage = 1
+	for _, repo := range []string{"team/api", "team/web", "library/nginx"} {
+		registry.addImage(repo, "latest", []byte(`{"architecture":"amd64"}`), []byte(repo))
+	}
+
+	var report RegistryCatalogReport
+	if err := json.Unmarshal(runRegistryCommand(t, "catalog", "http://"+registry.host()), &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Registry != registry.host() || strings.Join(report.Repositories, ",") != "library/nginx,team/api,team/web" {
+		t.Fatalf("report = %#v", report)
+	}
+	if registry.scope != "registry:catalog:*" {
+		t.Fatalf("token scope = %q", registry.scope)
+	}
+}
+
+func TestRegistryPruneKeepsByPolicyAndDeletesWholeDigests(t *testing.T) {
+	registry := newFakeRegistry(t, "")
+	image := func(tag string, age time.Duration, name string) {
+		created := time.Now().Add(-age).UTC().Format(time.RFC3339)
+		registry.addImage("team/app", tag, []byte(`{"architecture":"amd64","os":"linux","created":"`+created+`"}`), []byte(name))
+	}
+	day := 24 * time.Hour
+	image("v0.9", 100*day, "build-1")
+	image("ci-1", 100*day, "build-1")
+	image("ci-2", 90*day, "build-2")
+	image("old", 90*day, "build-2")
+	image("ci-3", 20*day, "build-3")
+	image("ci-5", 10*day, "build-5")
+	image("ci-4", time.Hour, "build-4")
+	repo := registry.host() + "/team/app"
+	policy := []string{repo, "--keep-last", "2", "--keep-regex", `^v\d`, "--older-than", "30d"}
+
+	var plan RegistryPruneReport
+	if err := json.Unmarshal(runRegistryCommand(t, "prune", policy...), &plan); err != nil {
+		t.Fatal(err)
+	}
+	reasons := map[string]string{}
+	for _, tag := range plan.Keep {
+		reasons[tag.Tag] = strings.Join(tag.Reasons, ",")
+	}
+	want := map[string]string{"ci-1": "shared", "ci-3": "recent", "ci-4": "recent,last", "ci-5": "recent,last", "v0.9": "regex"}
+	for tag, reason := range want {
+		if reasons[tag] != reason {
+			t.Fatalf("keep reasons = %v, want %v", reasons, want)
+		}
+	}
+	if len(plan.Remove) != 2 || plan.Remove[0].Tag != "ci-2" || plan.Remove[1].Tag != "old" || plan.Applied {
+		t.Fatalf("remove = %#v", plan.Remove)
+	}
+	if n := registry.requestsMatching("DELETE "); n != 0 {
+		t.Fatalf("preview sent %d deletes", n)
+	}
+
+	cmd := newRegistryPruneCommand(nil)
+	cmd.SetArgs(append(policy, "--plain-http", "--apply"))
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "--confirm") {
+		t.Fatalf("--apply without --confirm error = %v", err)
+	}
+
+	var applied RegistryPruneReport
+	if err := json.Unmarshal(runRegistryCommand(t, "prune", append(policy, "--apply", "--confirm")...), &applied); err != nil {
+		t.Fatal(err)
+	}
+	if !applied.Applied || len(applied.Deleted) != 1 || registry.requestsMatching("DELETE /v2/team/app/manifests/sha256:") != 1 {
+		t.Fatalf("applied = %#v", applied)
+	}
+	for _, tag := range []string{"ci-2", "old"} {
+		if _, ok := registry.manifest("team/app", tag); ok {
+			t.Fatalf("%s still present", tag)
+		}
+	}
+	for tag := range want {
+		if _, ok := registry.manifest("team/app", tag); !ok {
+			t.Fatalf("kept tag %s was deleted", tag)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package pull
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"docker-manager/internal/blobcache"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// useBlobCache turns on the shared blob cache rooted at dir (empty means
+// blobcache.DefaultDir) unless disabled.
+func (r *PullRunner) useBlobCache(dir string, disabled bool) error {
+	if disabled {
+		return nil
+	}
+	cache, err := blobcache.Open(dir)
+	if err != nil {
+		return err
+	}
+	r.blobCache = cache
+	return nil
+}
+
+// fetchBlob places the digest-verified blob desc at path. With a blob cache
+// configured the registry is only asked for blobs the cache does not hold yet,
+// and the cached copy is hard linked (or copied) into the workspace. Partial
+// downloads then live at a stable path in the cache, so a failed blob is
+// recorded in opts.partials and continued by the next attempt or run.
+func (r *PullRunner) fetchBlob(ctx context.Context, info *ImageInfo, desc ocispec.Descriptor, auth *pullRegistryAuth, opts PullOptions, path string) error {
+	save := func(dst string) error {
+		blobURL := registryAPIURL(opts, info, "blobs", string(desc.Digest))
+		_, err := r.saveRegistryFileWithRetry(ctx, blobURL, nil, nil, info, opts, auth, dst)
+		if isMirrorFallbackError(info, err) {
+			// Mirrors may lack blobs the upstream has; the .part file, if
+			// any, is continued from the upstream since blobs are immutable.
+			log.Printf("Mirror blob 下载失败，回源 registry: mirror=%s digest=%s err=%v", endpointHost(info), desc.Digest, err)
+			opts.endpoint.fellBack()
+			upstream := upstreamInfo(info)
+			_, err = r.saveRegistryFileWithRetry(ctx, registryAPIURL(opts, upstream, "blobs", string(desc.Digest)), nil, nil, upstream, opts, nil, dst)
+		}
+		if err != nil {
+			return err
+		}
+		return ctx.Err()
+	}
+	download := func(dst string) error {
+		resumed := partialDownloadSize(partialDownloadPath(dst)) > 0
+		if err := save(dst); err != nil {
+			return err
+		}
+		err := verifyFileDigest(dst, desc.Digest)
+		if err != nil && resumed {
+			// The bytes kept from an earlier attempt were bad; fetch it whole.
+			log.Printf("Resumed blob failed verification, downloading again: digest=%s", desc.Digest)
+			_ = os.Remove(dst)
+			if err := save(dst); err != nil {
+				return err
+			}
+			err = verifyFileDigest(dst, desc.Digest)
+		}
+		if err != nil {
+			_ = os.Remove(dst)
+			return fmt.Errorf("校验 blob digest 失败: %w", err)
+		}
+		return nil
+	}
+	if r.blobCache == nil {
+		return download(path)
+	}
+	cached, _, err := r.blobCache.Fetch(ctx, desc, download)
+	if err != nil {
+		opts.partials.record(desc, partialDownloadPath(r.blobCache.TempPath(desc.Digest)))
+		return err
+	}
+	opts.partials.done(desc.Digest)
+	return linkOrCopyFile(cached, path)
+}
+
+// linkOrCopyFile makes dst a hard link to src, copying when the workspace and
+// the cache sit on different filesystems. Callers only ever rename or remove
+// dst, so sharing the inode with the cache is safe.
+func linkOrCopyFile(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	_ = os.Remove(dst)
+	if err := os.Link(src, dst); err == nil {
+		return nil
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	part := partialDownloadPath(dst)
+	out, err := os.Create(part)
+	if err != nil {
+		return err
+	}
+	_, copyErr := io.Copy(out, in)
+	if err := errors.Join(copyErr, out.Close()); err != nil {
+		_ = os.Remove(part)
+		return err
+	}
+	return os.Rename(part, dst)
+}
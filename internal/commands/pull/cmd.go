@@ -2,12 +2,12 @@ package pull
 
 import (
 	"context"
+	"docker-manager/internal/appconfig"
 	"docker-manager/internal/commandflags"
-	rpt "docker-manager/internal/report"
+	"docker-manager/internal/ocilayout"
 	"errors"
 	"fmt"
 	"github.com/spf13/cobra"
-	"io"
 	"log"
 	"os"
 	"os/signal"
@@ -27,6 +27,15 @@ func NewPullCommandWithDefaults(defaults func() CommandDefaults) *cobra.Command
 	var outputDir string
 	var load bool
 	var to string
+	var direct bool
+	var platformValues []string
+	var allPlatforms bool
+	var archiveFormat string
+	var cacheDir string
+	var noCache bool
+	var registryMirrors map[string][]appconfig.RegistryMirror
+	var verifyKey string
+	var copySignatures bool
 	var dockerConfig string
 	var plainHTTP bool
 	var verboseHTTP bool
@@ -41,19 +50,55 @@ func NewPullCommandWithDefaults(defaults func() CommandDefaults) *cobra.Command
 		Short: "无需 Docker 客户端下载 Docker 镜像",
 		Long: `无需 Docker 客户端下载 Docker 镜像，从官方镜像源拉取。
 默认使用 HTTP_PROXY/HTTPS_PROXY 环境变量代理；未设置则直连。可通过 --proxy 强制指定代理。
-默认拉取 linux/amd64 镜像。
-支持直接传多个镜像或通过 --file 读取镜像列表；批量模式可使用 --concurrency、--retries、--resume、--skip-existing 和 --report。`,
+层和配置 blob 按 digest 缓存在共享 blob 缓存中（cache_dir 配置或 --cache-dir），批量拉取共享基础层时只下载一次，可用 dm cache 查看和清理。
+默认拉取 linux/amd64 镜像；--platform linux/amd64,linux/arm64 或 --all-platforms 可拉取多个平台并保留镜像索引。
+支持直接传多个镜像或通过 --file 读取镜像列表；批量模式可使用 --concurrency、--retries、--resume、--skip-existing 和 --report。
+.dm.yaml 的 registry_mirrors 为每个 registry 配置按顺序尝试的 mirror，mirror 不可用时自动回源。
+registry 支持 Range 时，中断的层下载会保留在 blob 缓存中，重试或 --resume 时从断点续传，完成后校验 digest。
+--verify-key 使用 cosign 公钥离线校验镜像签名，未签名或签名无效的镜像不会写入、导入或推送；--copy-signatures 在 registry 间复制时一并复制签名、证明和 SBOM。`,
 		Args: cobra.ArbitraryArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
 			defer stop()
 
-			applyCommandDefaults(cmd, defaults, &proxy, &targetOS, &arch, &outputDir)
+			applyCommandDefaults(cmd, defaults, &proxy, &targetOS, &arch, &outputDir, &cacheDir, &registryMirrors)
 			if !cmd.Flags().Changed("output-dir") {
 				batchOpts.OutputDir = outputDir
 			}
 			configureHTTPLogging(verboseHTTP)
 			imageNameList = args
+			if direct && to == "" {
+				return fmt.Errorf("--direct 需要配合 --to 使用")
+			}
+			if direct && (load || output != "") {
+				return fmt.Errorf("--direct 不会生成本地 tar，不能与 --load 或 --output 同时使用")
+			}
+			platforms, err := parsePlatforms(platformValues)
+			if err != nil {
+				return err
+			}
+			if allPlatforms && len(platforms) > 0 {
+				return fmt.Errorf("--all-platforms 不能与 --platform 同时使用")
+			}
+			archiveFormat, err = ocilayout.ParseFormat(archiveFormat)
+			if err != nil {
+				return err
+			}
+			if direct && cmd.Flags().Changed("archive-format") {
+				return fmt.Errorf("--direct 不会生成本地归档，不能与 --archive-format 同时使用")
+			}
+			variant := ""
+			if len(platforms) == 1 && !allPlatforms {
+				// A single --platform is just a more precise --os/--arch.
+				targetOS, arch, variant = platforms[0].OS, platforms[0].Architecture, platforms[0].Variant
+				platforms = nil
+			}
+			if copySignatures && to == "" {
+				return fmt.Errorf("--copy-signatures 需要配合 --to 使用")
+			}
+			if copySignatures && !direct && !allPlatforms && len(platforms) == 0 {
+				return fmt.Errorf("--copy-signatures 需要配合 --direct：经 Docker 推送后 manifest digest 会改变，签名无法对应")
+			}
 			if shouldRunPullBatch(cmd, imageNameList, batchOpts) {
 				if timeout <= 0 {
 					return fmt.Errorf("--timeout 必须大于 0")
@@ -62,31 +107,38 @@ func NewPullCommandWithDefaults(defaults func() CommandDefaults) *cobra.Command
 				if err != nil {
 					return fmt.Errorf("配置代理失败: %w", err)
 				}
+				runner.platform.targetVariant = variant
+				if err := runner.useBlobCache(cacheDir, noCache || direct); err != nil {
+					return err
+				}
+				if err := runner.useRegistryMirrors(registryMirrors); err != nil {
+					return err
+				}
+				if err := runner.useVerifyKey(verifyKey); err != nil {
+					return err
+				}
 				if output != "" {
 					return fmt.Errorf("--output 只能在拉取单个镜像时使用，请改用 --output-dir")
 				}
 				batchOpts.Images = append([]string(nil), imageNameList...)
 				batchOpts.To = to
+				batchOpts.Direct = direct
+				batchOpts.Platforms = platforms
+				batchOpts.AllPlatforms = allPlatforms
+				batchOpts.ArchiveFormat = archiveFormat
 				batchOpts.OutputDir = outputDir
 				batchOpts.Load = load
 				batchOpts.DockerConfig = dockerConfig
 				batchOpts.PlainHTTP = plainHTTP
+				batchOpts.CopySignatures = copySignatures
 				batchOpts.ProgressOutput = cmd.OutOrStdout()
 				report, err := runPullBatch(ctx, runner, batchOpts)
 				if errors.Is(err, context.Canceled) {
 					return err
 				}
 				if report.GeneratedAt != "" {
-					printErr := rpt.Print(cmd.OutOrStdout(), batchOpts.Format, report, func(w io.Writer) {
-						printPullBatchReport(w, report)
-					})
-					if printErr != nil {
-						return printErr
-					}
-					if batchOpts.ReportFile != "" {
-						if writeErr := writePullBatchReport(batchOpts.ReportFile, report); writeErr != nil {
-							return writeErr
-						}
+					if writeErr := writePullBatchOutput(cmd.OutOrStdout(), batchOpts, report); writeErr != nil {
+						return writeErr
 					}
 				}
 				return err
@@ -101,20 +153,35 @@ func NewPullCommandWithDefaults(defaults func() CommandDefaults) *cobra.Command
 			if err != nil {
 				return fmt.Errorf("配置代理失败: %w", err)
 			}
+			runner.platform.targetVariant = variant
+			if err := runner.useBlobCache(cacheDir, noCache || direct); err != nil {
+				return err
+			}
+			if err := runner.useRegistryMirrors(registryMirrors); err != nil {
+				return err
+			}
+			if err := runner.useVerifyKey(verifyKey); err != nil {
+				return err
+			}
 			opts := PullOptions{
 				Context:        ctx,
 				Output:         output,
 				OutputDir:      outputDir,
 				Load:           load,
 				To:             to,
+				Direct:         direct,
+				Platforms:      platforms,
+				AllPlatforms:   allPlatforms,
+				ArchiveFormat:  archiveFormat,
 				DockerConfig:   dockerConfig,
 				PlainHTTP:      plainHTTP,
 				ProgressOutput: cmd.OutOrStdout(),
+				CopySignatures: copySignatures,
 			}
 			var pullErrs []error
 			success := 0
 			total := len(imageNameList)
-			log.Printf("Pull images: total=%d os=%s arch=%s output=%s outputDir=%s to=%s plainHTTP=%v", total, targetOS, arch, output, outputDir, to, plainHTTP)
+			log.Printf("Pull images: total=%d os=%s arch=%s output=%s outputDir=%s to=%s direct=%v plainHTTP=%v", total, targetOS, arch, output, outputDir, to, direct, plainHTTP)
 			for i, imageName := range imageNameList {
 				log.Printf("Pull image [%d/%d]: %s", i+1, total, imageName)
 				if err := runner.getImage(imageName, opts); err != nil {
@@ -133,13 +200,21 @@ func NewPullCommandWithDefaults(defaults func() CommandDefaults) *cobra.Command
 	}
 	cmd.Flags().StringVarP(&targetOS, "os", "", "linux", "目标操作系统")
 	cmd.Flags().StringVarP(&arch, "arch", "a", "amd64", "目标架构")
+	cmd.Flags().StringSliceVar(&platformValues, "platform", nil, "目标平台 os/arch[/variant]，多个用逗号分隔时写入多平台归档并在 --to 时重建镜像索引")
+	cmd.Flags().BoolVar(&allPlatforms, "all-platforms", false, "拉取镜像索引中的全部平台，--to 时在目标 registry 重建镜像索引")
 	cmd.Flags().StringVar(&proxy, "proxy", "", "强制指定 HTTP 代理，例如 http://127.0.0.1:7890；为空时使用环境变量代理")
 	cmd.Flags().DurationVar(&timeout, "timeout", defaultPullTimeout, "连接、TLS 握手和响应头超时时间，例如 30s、2m、5m")
 	cmd.Flags().StringVarP(&output, "output", "o", "", "输出 tar 文件路径，仅支持单个镜像")
 	cmd.Flags().StringVar(&outputDir, "output-dir", ".", "输出 tar 文件目录")
+	cmd.Flags().StringVar(&archiveFormat, "archive-format", ocilayout.FormatDockerArchive, "镜像归档格式: docker-archive、oci（OCI layout 目录）或 oci-archive（OCI layout tar）")
+	cmd.Flags().StringVar(&cacheDir, "cache-dir", "", "共享 blob 缓存目录，默认读取配置 cache_dir 或用户缓存目录下的 docker-manager/blobs")
+	cmd.Flags().BoolVar(&noCache, "no-cache", false, "不使用共享 blob 缓存，每次都从 registry 下载全部层")
 	cmd.Flags().BoolVar(&load, "load", false, "拉取并打包完成后自动导入 Docker")
 	cmd.Flags().BoolVar(&verboseHTTP, "verbose-http", false, "输出底层 HTTP 请求调试日志")
 	cmd.Flags().StringVar(&to, "to", "", "pull 后导入 Docker、tag 并 push 到目标 registry/repository；可用 http:// 或 https:// 指定目标协议")
+	cmd.Flags().BoolVar(&direct, "direct", false, "配合 --to 直接在 registry 之间复制 blob 和 manifest，不落地 tar、不依赖 Docker daemon")
+	cmd.Flags().StringVar(&verifyKey, "verify-key", "", "cosign 公钥（PEM）路径，拉取前离线校验镜像签名，校验失败的镜像不会写入、导入或推送")
+	cmd.Flags().BoolVar(&copySignatures, "copy-signatures", false, "registry 间复制时一并复制 cosign 签名、证明和 SBOM，需要配合 --to --direct 或多平台复制")
 	commandflags.AddDockerConfigFlag(cmd, &dockerConfig)
 	cmd.Flags().BoolVar(&plainHTTP, "plain-http", false, "使用 http:// 拉取 registry，适用于未启用 TLS 的内网 registry")
 	cmd.Flags().StringVarP(&batchOpts.File, "file", "f", "", "镜像列表文件，空行和 # 注释会被忽略")
@@ -152,6 +227,8 @@ func NewPullCommandWithDefaults(defaults func() CommandDefaults) *cobra.Command
 	commandflags.AddReportFormatFlag(cmd, &batchOpts.Format)
 	_ = cmd.RegisterFlagCompletionFunc("os", completePullValues("linux", "windows"))
 	_ = cmd.RegisterFlagCompletionFunc("arch", completePullValues("amd64", "arm64", "arm", "386", "ppc64le", "s390x"))
+	_ = cmd.RegisterFlagCompletionFunc("archive-format", completePullValues(ocilayout.FormatDockerArchive, ocilayout.FormatOCI, ocilayout.FormatOCIArchive))
+	_ = cmd.RegisterFlagCompletionFunc("platform", completePullValues("linux/amd64", "linux/arm64", "linux/arm/v7", "linux/386", "linux/ppc64le", "linux/s390x"))
 	return cmd
 }
 
@@ -180,7 +257,7 @@ func completePullValues(values ...string) cobra.CompletionFunc {
 	}
 }
 
-func applyCommandDefaults(cmd *cobra.Command, defaults func() CommandDefaults, proxy, targetOS, arch, outputDir *string) {
+func applyCommandDefaults(cmd *cobra.Command, defaults func() CommandDefaults, proxy, targetOS, arch, outputDir, cacheDir *string, mirrors *map[string][]appconfig.RegistryMirror) {
 	if defaults == nil {
 		return
 	}
@@ -198,4 +275,8 @@ func applyCommandDefaults(cmd *cobra.Command, defaults func() CommandDefaults, p
 	if cfg.OutputDir != "" && !flags.Changed("output-dir") {
 		*outputDir = cfg.OutputDir
 	}
+	if cfg.CacheDir != "" && !flags.Changed("cache-dir") {
+		*cacheDir = cfg.CacheDir
+	}
+	*mirrors = cfg.RegistryMirrors
 }
@@ -0,0 +1,360 @@
+package pull
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	digest "github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"golang.org/x/sync/errgroup"
+)
+
+// registryCopy streams one image from the source registry to the --to target
+// through the OCI distribution API. Nothing touches the local disk or the
+// Docker daemon: blobs the target already has are skipped, blobs living in
+// another repository of the same registry are mounted, and everything else is
+// piped from the source GET straight into a monolithic upload PUT.
+type registryCopy struct {
+	runner     *PullRunner
+	source     *ImageInfo
+	sourceOpts PullOptions
+	sourceAuth *pullRegistryAuth
+	target     *ImageInfo
+	targetRef  string
+	targetOpts PullOptions
+	targetAuth *pullRegistryAuth
+
+	mu    sync.Mutex
+	stats registryCopyStats
+}
+
+type registryCopyStats struct {
+	Blobs    int
+	Skipped  int
+	Mounted  int
+	Uploaded int
+	Bytes    int64
+}
+
+func (r *PullRunner) copyImageToRegistry(ctx context.Context, info *ImageInfo, opts PullOptions) error {
+	c, err := r.newRegistryCopy(ctx, info, opts)
+	if err != nil {
+		return err
+	}
+	fetched, sourceAuth, err := r.fetchRegistryManifest(ctx, info, opts)
+	if err != nil {
+		return fmt.Errorf("获取清单失败: %w", err)
+	}
+	c.sourceAuth = sourceAuth
+	if err := c.copyImage(ctx, c.target.Tag, fetched); err != nil {
+		return err
+	}
+	c.logDone()
+	return c.copySignatureArtifacts(ctx, digest.FromBytes(fetched.Raw))
+}
+
+// newRegistryCopy validates the --to target and settles its push credential;
+// the caller fills in sourceAuth once the source manifest has been fetched.
+func (r *PullRunner) newRegistryCopy(ctx context.Context, info *ImageInfo, opts PullOptions) (*registryCopy, error) {
+	target, err := resolvePushTarget(info, opts.To)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.checkPushTargetRegistry(ctx, target, opts); err != nil {
+		return nil, err
+	}
+	targetInfo, err := parseImageInfo(target)
+	if err != nil {
+		return nil, fmt.Errorf("解析目标镜像失败: %w", err)
+	}
+	targetOpts := opts
+	targetOpts.PlainHTTP = pushTargetUsesPlainHTTP(opts)
+	targetAuth, err := r.resolvePushAuth(ctx, targetInfo, targetOpts)
+	if err != nil {
+		return nil, fmt.Errorf("获取目标 registry 推送认证失败: %w", err)
+	}
+	log.Printf("Direct copy: %s -> %s", getImageRef(info), target)
+	return &registryCopy{
+		runner:     r,
+		source:     info,
+		sourceOpts: opts,
+		target:     targetInfo,
+		targetRef:  target,
+		targetOpts: targetOpts,
+		targetAuth: targetAuth,
+	}, nil
+}
+
+func (c *registryCopy) copyImage(ctx context.Context, ref string, manifest *registryManifest) error {
+	if err := c.copyBlobs(ctx, append([]ocispec.Descriptor{manifest.Manifest.Config}, manifest.Manifest.Layers...)); err != nil {
+		return err
+	}
+	return c.putManifest(ctx, ref, manifest)
+}
+
+func (c *registryCopy) logDone() {
+	log.Printf("镜像直接推送成功: %s blobs=%d skipped=%d mounted=%d uploaded=%d bytes=%d",
+		c.targetRef, c.stats.Blobs, c.stats.Skipped, c.stats.Mounted, c.stats.Uploaded, c.stats.Bytes)
+}
+
+func getImageRef(info *ImageInfo) string {
+	if info.Digest != "" {
+		return fmt.Sprintf("%s/%s@%s", info.Registry, imagePath(info), info.Digest)
+	}
+	return fmt.Sprintf("%s/%s:%s", info.Registry, imagePath(info), info.Tag)
+}
+
+// resolvePushAuth asks the target registry for a pull,push token up front so
+// the concurrent blob uploads share one credential exchange.
+func (r *PullRunner) resolvePushAuth(ctx context.Context, info *ImageInfo, opts PullOptions) (*pullRegistryAuth, error) {
+	scheme := "https"
+	if opts.PlainHTTP {
+		scheme = "http"
+	}
+	result := r.pingRegistryV2Once(ctx, fmt.Sprintf("%s://%s/v2/", scheme, info.Registry), nil)
+	switch result.status {
+	case registryPingOK:
+		return nil, nil
+	case registryPingAuthRequired:
+		return r.resolveRegistryAuthWithScope(ctx, result.message, fmt.Sprintf("repository:%s:pull,push", imagePath(info)), info, opts)
+	default:
+		return nil, errors.New(result.message)
+	}
+}
+
+func (c *registryCopy) copyBlobs(ctx context.Context, blobs []ocispec.Descriptor) error {
+	g, ctx := errgroup.WithContext(ctx)
+	sem := make(chan struct{}, maxLayerConcurrency)
+	for _, blob := range blobs {
+		desc := blob
+		if isForeignLayer(desc) {
+			log.Printf("跳过外部层 %s", desc.Digest)
+			continue
+		}
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		g.Go(func() error {
+			defer func() { <-sem }()
+			return c.copyBlobWithRetry(ctx, desc)
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return fmt.Errorf("复制 blob 失败: %w", err)
+	}
+	return nil
+}
+
+func isForeignLayer(desc ocispec.Descriptor) bool {
+	return len(desc.URLs) > 0 && strings.Contains(desc.MediaType, "foreign")
+}
+
+func (c *registryCopy) copyBlobWithRetry(ctx context.Context, desc ocispec.Descriptor) error {
+	var lastErr error
+	backoff := initialBackoff
+	for i := 0; i < maxHTTPRetries; i++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		err := c.copyBlob(ctx, desc)
+		if err == nil {
+			return nil
+		}
+		if errors.Is(err, context.Canceled) {
+			return err
+		}
+		lastErr = err
+		log.Printf("复制 blob %s 失败（尝试 %d/%d）: %v，稍后重试...", desc.Digest, i+1, maxHTTPRetries, err)
+		if err := sleepWithContext(ctx, backoff); err != nil {
+			return err
+		}
+		backoff *= 2
+	}
+	return lastErr
+}
+
+func (c *registryCopy) copyBlob(ctx context.Context, desc ocispec.Descriptor) error {
+	digest := string(desc.Digest)
+	blobURL := registryAPIURL(c.targetOpts, c.target, "blobs", digest)
+	resp, auth, err := c.runner.doRegistryRequest(ctx, http.MethodHead, blobURL, nil, nil, c.target, c.targetOpts, c.targetAuth)
+	if err != nil {
+		return err
+	}
+	drainResponse(resp)
+	if resp.StatusCode == http.StatusOK {
+		c.record(func(s *registryCopyStats) { s.Skipped++ })
+		return nil
+	}
+	if resp.StatusCode != http.StatusNotFound {
+		return registryResponseError(resp)
+	}
+
+	uploadURL := registryAPIURL(c.targetOpts, c.target, "blobs", "uploads/")
+	mount := c.source.Registry == c.target.Registry && imagePath(c.source) != imagePath(c.target)
+	if mount {
+		query := url.Values{"mount": {digest}, "from": {imagePath(c.source)}}
+		uploadURL += "?" + query.Encode()
+	}
+	resp, auth, err = c.runner.doRegistryRequest(ctx, http.MethodPost, uploadURL, nil, []byte{}, c.target, c.targetOpts, auth)
+	if err != nil {
+		return err
+	}
+	drainResponse(resp)
+	switch resp.StatusCode {
+	case http.StatusCreated:
+		c.record(func(s *registryCopyStats) { s.Mounted++ })
+		return nil
+	case http.StatusAccepted:
+	default:
+		return registryResponseError(resp)
+	}
+	location, err := uploadLocation(resp, digest)
+	if err != nil {
+		return err
+	}
+	return c.uploadBlob(ctx, desc, location, auth)
+}
+
+// uploadBlob pipes the source blob into a single PUT. The body cannot be
+// replayed, so the target credential must already be settled by the POST.
+func (c *registryCopy) uploadBlob(ctx context.Context, desc ocispec.Descriptor, location string, targetAuth *pullRegistryAuth) error {
+	sourceURL := registryAPIURL(c.sourceOpts, c.source, "blobs", string(desc.Digest))
+	source, _, err := c.runner.doRegistryRequest(ctx, http.MethodGet, sourceURL, nil, nil, c.source, c.sourceOpts, c.sourceAuth)
+	if err != nil {
+		return err
+	}
+	defer source.Body.Close()
+	if source.StatusCode != http.StatusOK {
+		return registryResponseError(source)
+	}
+	size := desc.Size
+	if size <= 0 {
+		size = source.ContentLength
+	}
+	body := newDownloadProgressReader(source.Body, c.sourceOpts.ProgressOutput, downloadProgressLabel(sourceURL, string(desc.Digest)), size)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, location, body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/octet-stream")
+	if targetAuth != nil && targetAuth.Authorization != "" {
+		req.Header.Set("Authorization", targetAuth.Authorization)
+	}
+	resp, err := c.runner.httpClient.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	drainResponse(resp)
+	if resp.StatusCode != http.StatusCreated {
+		return registryResponseError(resp)
+	}
+	c.record(func(s *registryCopyStats) {
+		s.Uploaded++
+		s.Bytes += size
+	})
+	return nil
+}
+
+func (c *registryCopy) putManifest(ctx context.Context, ref string, manifest *registryManifest) error {
+	manifestURL := registryAPIURL(c.targetOpts, c.target, "manifests", ref)
+	headers := map[string]string{"Content-Type": manifest.MediaType}
+	resp, _, err := c.runner.doRegistryRequest(ctx, http.MethodPut, manifestURL, headers, manifest.Raw, c.target, c.targetOpts, c.targetAuth)
+	if err != nil {
+		return fmt.Errorf("推送清单失败: %w", err)
+	}
+	drainResponse(resp)
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("推送清单失败: %w", registryResponseError(resp))
+	}
+	return nil
+}
+
+func (c *registryCopy) record(update func(*registryCopyStats)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stats.Blobs++
+	update(&c.stats)
+}
+
+// doRegistryRequest sends one registry API request and, on 401, resolves the
+// challenge and retries once. body must be replayable, which is why blob data
+// goes through uploadBlob instead. The caller owns the returned response body.
+func (r *PullRunner) doRegistryRequest(ctx context.Context, method, rawURL string, headers map[string]string, body []byte, info *ImageInfo, opts PullOptions, auth *pullRegistryAuth) (*http.Response, *pullRegistryAuth, error) {
+	return r.doRegistryRequestWithScope(ctx, method, rawURL, headers, body, "", info, opts, auth)
+}
+
+// doRegistryRequestWithScope is doRegistryRequest with an explicit token scope,
+// e.g. registry:catalog:* for /v2/_catalog.
+func (r *PullRunner) doRegistryRequestWithScope(ctx context.Context, method, rawURL string, headers map[string]string, body []byte, scope string, info *ImageInfo, opts PullOptions, auth *pullRegistryAuth) (*http.Response, *pullRegistryAuth, error) {
+	resp, err := r.sendRegistryRequest(ctx, method, rawURL, authHeaders(headers, auth), body)
+	if err != nil {
+		return nil, auth, err
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		return resp, auth, nil
+	}
+	drainResponse(resp)
+	nextAuth, err := r.resolveRegistryAuthWithScope(ctx, resp.Header.Get("WWW-Authenticate"), scope, info, opts)
+	if err != nil {
+		return nil, auth, err
+	}
+	resp, err = r.sendRegistryRequest(ctx, method, rawURL, authHeaders(headers, nextAuth), body)
+	if err != nil {
+		return nil, nextAuth, err
+	}
+	return resp, nextAuth, nil
+}
+
+func (r *PullRunner) sendRegistryRequest(ctx context.Context, method, rawURL string, headers map[string]string, body []byte) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, reader)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	return r.httpClient.Client.Do(req)
+}
+
+func drainResponse(resp *http.Response) {
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if err := resp.Body.Close(); err != nil {
+		log.Printf("警告: 关闭 HTTP response body 失败: %v", err)
+	}
+}
+
+func registryResponseError(resp *http.Response) error {
+	return &httpStatusError{StatusCode: resp.StatusCode, Status: resp.Status, Header: resp.Header.Clone()}
+}
+
+// uploadLocation resolves the (possibly relative) Location of an upload
+// session and appends the digest query that completes a monolithic upload.
+func uploadLocation(resp *http.Response, digest string) (string, error) {
+	location := resp.Header.Get("Location")
+	if location == "" {
+		return "", fmt.Errorf("registry 未返回上传地址")
+	}
+	parsed, err := resp.Request.URL.Parse(location)
+	if err != nil {
+		return "", fmt.Errorf("解析上传地址失败: %w", err)
+	}
+	query := parsed.Query()
+	query.Set("digest", digest)
+	parsed.RawQuery = query.Encode()
+	return parsed.String(), nil
+}
@@ -11,6 +11,7 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -46,19 +47,15 @@ func (r *PullRunner) downloadLayers(ctx context.Context, info *ImageInfo, manife
 }
 
 func (r *PullRunner) downloadConfig(ctx context.Context, info *ImageInfo, manifest *ocispec.Manifest, auth *pullRegistryAuth, opts PullOptions, tempDir string) error {
-	configURL := registryAPIURL(opts, info, "blobs", string(manifest.Config.Digest))
 	digest := strings.TrimPrefix(string(manifest.Config.Digest), "sha256:")
 	if digest == string(manifest.Config.Digest) {
 		digest = strings.TrimPrefix(digest, "sha:")
 	}
 	configPath := filepath.Join(tempDir, digest+".json")
-
-	_, err := r.saveRegistryFileWithRetry(ctx, configURL, nil, nil, info, opts, auth, configPath)
-	return err
+	return r.fetchBlob(ctx, info, manifest.Config, auth, opts, configPath)
 }
 
 func (r *PullRunner) downloadLayer(ctx context.Context, info *ImageInfo, layer ocispec.Descriptor, auth *pullRegistryAuth, opts PullOptions, tempDir string) error {
-	layerURL := registryAPIURL(opts, info, "blobs", string(layer.Digest))
 	layerID := sha256Hash(string(layer.Digest))
 	layerDir := filepath.Join(tempDir, layerID)
 	if err := os.MkdirAll(layerDir, 0755); err != nil {
@@ -67,16 +64,14 @@ func (r *PullRunner) downloadLayer(ctx context.Context, info *ImageInfo, layer o
 
 	blobPath := filepath.Join(layerDir, "layer.blob")
 	tarPath := filepath.Join(layerDir, "layer.tar")
+	if _, err := os.Stat(tarPath); err == nil {
+		// Platforms of one multi-arch pull often share base layers.
+		return nil
+	}
 
-	if _, err := r.saveRegistryFileWithRetry(ctx, layerURL, nil, nil, info, opts, auth, blobPath); err != nil {
+	if err := r.fetchBlob(ctx, info, layer, auth, opts, blobPath); err != nil {
 		return fmt.Errorf("下载层失败: %w", err)
 	}
-	if err := ctx.Err(); err != nil {
-		return err
-	}
-	if err := verifyFileDigest(blobPath, layer.Digest); err != nil {
-		return fmt.Errorf("校验层 digest 失败: %w", err)
-	}
 
 	if err := ctx.Err(); err != nil {
 		return err
@@ -248,7 +243,6 @@ func (r *PullRunner) saveRegistryFileWithRetry(ctx context.Context, rawURL strin
 	backoff := initialBackoff
 	for i := 0; i < maxHTTPRetries; i++ {
 		if err := ctx.Err(); err != nil {
-			_ = removePartialDownload(outputPath)
 			return currentAuth, err
 		}
 		nextAuth, err := r.saveRegistryFileOnce(ctx, rawURL, headers, query, info, opts, currentAuth, outputPath)
@@ -256,14 +250,12 @@ func (r *PullRunner) saveRegistryFileWithRetry(ctx context.Context, rawURL strin
 			return nextAuth, nil
 		}
 		currentAuth = nextAuth
-		_ = removePartialDownload(outputPath)
 		if errors.Is(err, context.Canceled) {
 			return currentAuth, err
 		}
 		lastErr = err
 		log.Printf("保存 %s 到 %s 失败（尝试 %d/%d）: %v，稍后重试...", rawURL, outputPath, i+1, maxHTTPRetries, err)
 		if err := sleepWithContext(ctx, backoff); err != nil {
-			_ = removePartialDownload(outputPath)
 			return currentAuth, err
 		}
 		backoff *= 2
@@ -292,8 +284,18 @@ func (r *PullRunner) httpSaveToFile(ctx context.Context, rawURL string, headers
 	return r.httpSaveToFileWithStatus(ctx, rawURL, headers, query, outputPath, io.Discard)
 }
 
+// httpSaveToFileWithStatus downloads rawURL to outputPath through a .part
+// file. An existing .part is continued with a Range request; it is kept after
+// a failure only when the server honours ranges (206 or Accept-Ranges: bytes),
+// so the next attempt or a later --resume run picks up where this one stopped.
 func (r *PullRunner) httpSaveToFileWithStatus(ctx context.Context, rawURL string, headers map[string]string, query map[string]string, outputPath string, progressOutput io.Writer) error {
-	req, err := buildGETRequest(ctx, rawURL, headers, query)
+	partPath := partialDownloadPath(outputPath)
+	offset := partialDownloadSize(partPath)
+	requestHeaders := headers
+	if offset > 0 {
+		requestHeaders = withRangeHeader(headers, offset)
+	}
+	req, err := buildGETRequest(ctx, rawURL, requestHeaders, query)
 	if err != nil {
 		return err
 	}
@@ -306,6 +308,11 @@ func (r *PullRunner) httpSaveToFileWithStatus(ctx context.Context, rawURL string
 			log.Printf("警告: 关闭 HTTP response body 失败: %v", cerr)
 		}
 	}()
+	if offset > 0 && resp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		// The partial file no longer matches the blob; start over.
+		_ = os.Remove(partPath)
+		return r.httpSaveToFileWithStatus(ctx, rawURL, headers, query, outputPath, progressOutput)
+	}
 	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
 		return &httpStatusError{StatusCode: resp.StatusCode, Status: resp.Status, Header: resp.Header.Clone()}
 	}
@@ -313,30 +320,74 @@ func (r *PullRunner) httpSaveToFileWithStatus(ctx context.Context, rawURL string
 		return err
 	}
 
-	partPath := partialDownloadPath(outputPath)
-	file, err := os.Create(partPath)
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	total := resp.ContentLength
+	if offset > 0 && resp.StatusCode == http.StatusPartialContent && contentRangeStart(resp.Header.Get("Content-Range")) == offset {
+		flags = os.O_WRONLY | os.O_APPEND
+		if total > 0 {
+			total += offset
+		}
+		log.Printf("Resume download: url=%s offset=%d", rawURL, offset)
+	} else {
+		offset = 0
+	}
+	file, err := os.OpenFile(partPath, flags, 0644)
 	if err != nil {
 		return err
 	}
-	reader := newDownloadProgressReader(resp.Body, progressOutput, downloadProgressLabel(rawURL, outputPath), resp.ContentLength)
+	reader := newDownloadProgressReader(resp.Body, progressOutput, downloadProgressLabel(rawURL, outputPath), total)
+	reader.resumeFrom(offset)
 	_, copyErr := io.Copy(file, reader)
 	closeErr := file.Close()
-	if copyErr != nil {
-		_ = os.Remove(partPath)
-		return copyErr
-	}
-	if closeErr != nil {
-		_ = os.Remove(partPath)
-		return closeErr
-	}
-	if err := ctx.Err(); err != nil {
-		_ = os.Remove(partPath)
+	if err := errors.Join(copyErr, closeErr, ctx.Err()); err != nil {
+		if !acceptsRangeRequests(resp) {
+			_ = os.Remove(partPath)
+		}
 		return err
 	}
 	_ = os.Remove(outputPath)
 	return os.Rename(partPath, outputPath)
 }
 
+func partialDownloadSize(partPath string) int64 {
+	info, err := os.Stat(partPath)
+	if err != nil || !info.Mode().IsRegular() {
+		return 0
+	}
+	return info.Size()
+}
+
+func withRangeHeader(headers map[string]string, offset int64) map[string]string {
+	withRange := make(map[string]string, len(headers)+1)
+	for key, value := range headers {
+		withRange[key] = value
+	}
+	withRange["Range"] = fmt.Sprintf("bytes=%d-", offset)
+	return withRange
+}
+
+// contentRangeStart parses the first byte position of "bytes start-end/size",
+// returning -1 when the header is missing or malformed.
+func contentRangeStart(value string) int64 {
+	value, ok := strings.CutPrefix(strings.TrimSpace(value), "bytes ")
+	if !ok {
+		return -1
+	}
+	start, _, ok := strings.Cut(value, "-")
+	if !ok {
+		return -1
+	}
+	offset, err := strconv.ParseInt(strings.TrimSpace(start), 10, 64)
+	if err != nil {
+		return -1
+	}
+	return offset
+}
+
+func acceptsRangeRequests(resp *http.Response) bool {
+	return resp.StatusCode == http.StatusPartialContent || strings.EqualFold(strings.TrimSpace(resp.Header.Get("Accept-Ranges")), "bytes")
+}
+
 type downloadProgressReader struct {
 	reader     io.Reader
 	output     io.Writer
@@ -346,6 +397,7 @@ type downloadProgressReader struct {
 	started    time.Time
 	lastReport time.Time
 	enabled    bool
+	resumed    int64
 }
 
 func newDownloadProgressReader(reader io.Reader, output io.Writer, label string, total int64) *downloadProgressReader {
@@ -361,6 +413,13 @@ func newDownloadProgressReader(reader io.Reader, output io.Writer, label string,
 	}
 }
 
+// resumeFrom accounts for bytes already on disk from an earlier attempt. They
+// count towards the percentage but not towards the transfer rate.
+func (r *downloadProgressReader) resumeFrom(offset int64) {
+	r.downloaded += offset
+	r.resumed += offset
+}
+
 func (r *downloadProgressReader) Read(p []byte) (int, error) {
 	n, err := r.reader.Read(p)
 	if n > 0 {
@@ -386,7 +445,7 @@ func (r *downloadProgressReader) report(final bool) {
 	if elapsed <= 0 {
 		elapsed = 0.001
 	}
-	speed := float64(r.downloaded) / elapsed
+	speed := float64(r.downloaded-r.resumed) / elapsed
 	if final {
 		progressPrintf(r.output, "下载完成 %s %s %s\n", r.label, textfmt.SignedBytes(r.downloaded), textfmt.Rate(speed))
 		return
@@ -0,0 +1,220 @@
+package pull
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"docker-manager/internal/appconfig"
+)
+
+// registryEndpoint is where registry API requests for an image go when it is
+// not the image's own registry, i.e. a configured mirror.
+type registryEndpoint struct {
+	Host      string
+	PlainHTTP bool
+}
+
+// useRegistryMirrors installs the registry_mirrors section of .dm.yaml. Mirrors
+// with their own CA or skip_verify get a dedicated TLS transport, selected by
+// host so every request path (manifests, blobs, tokens) picks it up.
+func (r *PullRunner) useRegistryMirrors(config map[string][]appconfig.RegistryMirror) error {
+	if len(config) == 0 {
+		return nil
+	}
+	base, ok := r.httpClient.Client.Transport.(*http.Transport)
+	if !ok {
+		return fmt.Errorf("registry_mirrors 需要默认 HTTP transport")
+	}
+	router := &endpointTransport{base: base, hosts: map[string]http.RoundTripper{}}
+	mirrors := map[string][]registryEndpoint{}
+	for registry, entries := range config {
+		key := normalizeMirrorRegistry(registry)
+		for _, entry := range entries {
+			endpoint, err := parseMirrorEndpoint(entry)
+			if err != nil {
+				return fmt.Errorf("registry_mirrors.%s: %w", registry, err)
+			}
+			if entry.CAFile != "" || entry.SkipVerify {
+				tlsConfig, err := mirrorTLSConfig(entry)
+				if err != nil {
+					return fmt.Errorf("registry_mirrors.%s: %w", registry, err)
+				}
+				transport := base.Clone()
+				transport.TLSClientConfig = tlsConfig
+				router.hosts[endpoint.Host] = transport
+			}
+			mirrors[key] = append(mirrors[key], endpoint)
+		}
+	}
+	r.mirrors = mirrors
+	if len(router.hosts) > 0 {
+		r.httpClient.Client.Transport = router
+	}
+	return nil
+}
+
+func parseMirrorEndpoint(entry appconfig.RegistryMirror) (registryEndpoint, error) {
+	value := strings.TrimSpace(entry.Endpoint)
+	if value == "" {
+		return registryEndpoint{}, fmt.Errorf("mirror endpoint 不能为空")
+	}
+	endpoint := registryEndpoint{PlainHTTP: entry.PlainHTTP}
+	if !strings.Contains(value, "://") {
+		value = "https://" + value
+	} else if strings.HasPrefix(strings.ToLower(value), "http://") {
+		endpoint.PlainHTTP = true
+	}
+	parsed, err := url.Parse(value)
+	if err != nil || parsed.Host == "" {
+		return registryEndpoint{}, fmt.Errorf("无效的 mirror endpoint %q", entry.Endpoint)
+	}
+	if path := strings.Trim(parsed.Path, "/"); path != "" && path != "v2" {
+		return registryEndpoint{}, fmt.Errorf("mirror endpoint %q 不支持路径前缀", entry.Endpoint)
+	}
+	endpoint.Host = parsed.Host
+	return endpoint, nil
+}
+
+func mirrorTLSConfig(entry appconfig.RegistryMirror) (*tls.Config, error) {
+	config := &tls.Config{InsecureSkipVerify: entry.SkipVerify}
+	if entry.CAFile == "" {
+		return config, nil
+	}
+	pem, err := os.ReadFile(entry.CAFile)
+	if err != nil {
+		return nil, fmt.Errorf("读取 CA 文件失败: %w", err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("CA 文件 %s 中没有有效的 PEM 证书", entry.CAFile)
+	}
+	config.RootCAs = pool
+	return config, nil
+}
+
+// normalizeMirrorRegistry folds the Docker Hub aliases onto the name
+// parseImageInfo produces.
+func normalizeMirrorRegistry(registry string) string {
+	registry = strings.ToLower(strings.TrimSpace(registry))
+	switch registry {
+	case dockerHubDomain, "index.docker.io", defaultRegistry:
+		return defaultRegistry
+	}
+	return registry
+}
+
+// endpointTransport routes requests to mirrors with custom TLS settings to
+// their own transport and everything else to the shared one.
+type endpointTransport struct {
+	base  http.RoundTripper
+	hosts map[string]http.RoundTripper
+}
+
+func (t *endpointTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if transport, ok := t.hosts[req.URL.Host]; ok {
+		return transport.RoundTrip(req)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// resolveEndpoint picks the first mirror that serves the image manifest. A
+// nil endpoint means the image's own registry, which is always the last
+// resort.
+func (r *PullRunner) resolveEndpoint(ctx context.Context, info *ImageInfo, opts PullOptions) *registryEndpoint {
+	for _, mirror := range r.mirrors[normalizeMirrorRegistry(info.Registry)] {
+		endpoint := mirror
+		probe := *info
+		probe.endpoint = &endpoint
+		err := r.probeManifest(ctx, &probe, opts)
+		if err == nil {
+			log.Printf("Registry endpoint: image=%s endpoint=%s", getImageRef(info), endpoint.Host)
+			return &endpoint
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		log.Printf("Registry mirror 不可用，尝试下一个: image=%s mirror=%s err=%v", getImageRef(info), endpoint.Host, err)
+	}
+	return nil
+}
+
+func (r *PullRunner) probeManifest(ctx context.Context, info *ImageInfo, opts PullOptions) error {
+	manifestURL := registryAPIURL(opts, info, "manifests", getReference(info))
+	resp, _, err := r.doRegistryRequest(ctx, http.MethodHead, manifestURL, manifestAcceptHeaders(true), nil, info, opts, nil)
+	if err != nil {
+		return err
+	}
+	drainResponse(resp)
+	if resp.StatusCode != http.StatusOK {
+		return registryResponseError(resp)
+	}
+	return nil
+}
+
+// endpointHost is the host registry API requests for info are sent to.
+func endpointHost(info *ImageInfo) string {
+	if info.endpoint != nil {
+		return info.endpoint.Host
+	}
+	return info.Registry
+}
+
+// upstreamInfo returns info with any mirror endpoint removed.
+func upstreamInfo(info *ImageInfo) *ImageInfo {
+	upstream := *info
+	upstream.endpoint = nil
+	return &upstream
+}
+
+// pullEndpointRecord tells the batch report which endpoint served an image.
+type pullEndpointRecord struct {
+	mu        sync.Mutex
+	endpoint  string
+	fallbacks int
+}
+
+func (p *pullEndpointRecord) served(info *ImageInfo) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.endpoint = endpointHost(info)
+	p.fallbacks = 0
+}
+
+func (p *pullEndpointRecord) fellBack() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.fallbacks++
+}
+
+func (p *pullEndpointRecord) String() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.fallbacks > 0 {
+		return fmt.Sprintf("%s (%d 个 blob 回源)", p.endpoint, p.fallbacks)
+	}
+	return p.endpoint
+}
+
+// isMirrorFallbackError reports whether err from a mirror is worth retrying
+// against the upstream registry.
+func isMirrorFallbackError(info *ImageInfo, err error) bool {
+	return err != nil && info.endpoint != nil && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+}
@@ -8,9 +8,11 @@ import (
 	"io"
 	"log"
 	"net/url"
+	"os"
 	"strings"
 
 	"docker-manager/internal/docker"
+	"docker-manager/internal/ocilayout"
 
 	"github.com/distribution/reference"
 )
@@ -240,6 +242,14 @@ func loadImageTar(ctx context.Context, path string, output io.Writer) error {
 	if err != nil {
 		return err
 	}
+	if ocilayout.IsLayoutDir(path) {
+		archive, err := ocilayout.TarDirTemp(ctx, path)
+		if err != nil {
+			return fmt.Errorf("打包 OCI layout 失败: %w", err)
+		}
+		defer os.Remove(archive)
+		path = archive
+	}
 	return im.LoadWithContext(ctx, path, output)
 }
 
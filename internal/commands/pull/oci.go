@@ -0,0 +1,153 @@
+package pull
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"docker-manager/internal/ocilayout"
+
+	digest "github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"golang.org/x/sync/errgroup"
+)
+
+// getOCIImage is the single-platform pull for oci and oci-archive output.
+func (r *PullRunner) getOCIImage(ctx context.Context, info *ImageInfo, opts PullOptions) error {
+	fetched, auth, err := r.fetchRegistryManifest(ctx, info, opts)
+	if err != nil {
+		return fmt.Errorf("获取清单失败: %w", err)
+	}
+	desc := ocispec.Descriptor{MediaType: fetched.MediaType, Digest: digest.FromBytes(fetched.Raw), Size: int64(len(fetched.Raw))}
+	outputFile, err := r.writeOCIImage(ctx, info, nil, []platformImage{{Descriptor: desc, Manifest: fetched}}, auth, opts)
+	if err != nil {
+		return err
+	}
+	return r.completePulledImage(outputFile, info, opts)
+}
+
+// writeOCIImage writes the selected images as an OCI image layout. Blobs are
+// stored exactly as the registry served them, so layers stay compressed and
+// manifest digests match the source. With an index the layout points at a
+// (possibly rebuilt) index blob; otherwise at the single manifest.
+func (r *PullRunner) writeOCIImage(ctx context.Context, info *ImageInfo, index *registryIndex, images []platformImage, auth *pullRegistryAuth, opts PullOptions) (string, error) {
+	outputFile, err := resolveOutputFile(info, opts)
+	if err != nil {
+		return "", fmt.Errorf("解析输出路径失败: %w", err)
+	}
+	var root string
+	if opts.ArchiveFormat == ocilayout.FormatOCI {
+		root = partialDownloadPath(outputFile)
+		if err := os.RemoveAll(root); err != nil {
+			return "", err
+		}
+		if err := os.MkdirAll(root, 0755); err != nil {
+			return "", fmt.Errorf("创建输出目录失败: %w", err)
+		}
+	} else if root, err = prepareWorkspace(info); err != nil {
+		return "", fmt.Errorf("准备临时目录失败: %w", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(root); err != nil {
+			log.Printf("警告: 清理临时目录 %s 失败: %v", root, err)
+		}
+	}()
+
+	if err := r.downloadLayoutBlobs(ctx, info, images, auth, opts, root); err != nil {
+		return "", err
+	}
+	for _, image := range images {
+		if _, err := ocilayout.WriteBlob(root, image.Manifest.MediaType, image.Manifest.Raw); err != nil {
+			return "", fmt.Errorf("写入镜像清单失败: %w", err)
+		}
+	}
+	top := images[0].Descriptor
+	if index != nil {
+		indexManifest, err := platformIndexManifest(index, images, opts.AllPlatforms)
+		if err != nil {
+			return "", err
+		}
+		if top, err = ocilayout.WriteBlob(root, indexManifest.MediaType, indexManifest.Raw); err != nil {
+			return "", fmt.Errorf("写入镜像索引失败: %w", err)
+		}
+	}
+	top.Platform = nil
+	top.Annotations = ocilayout.RefAnnotations(fullImageName(info), info.Tag)
+
+	repoTags := platformRepoTags(info, images, r.defaultPlatformImage(images))
+	docker := make([]ocilayout.DockerManifest, 0, len(images))
+	for i, image := range images {
+		manifest := image.Manifest.Manifest
+		entry := ocilayout.DockerManifest{Config: ocilayout.BlobRelPath(manifest.Config.Digest), RepoTags: repoTags[i]}
+		for _, layer := range manifest.Layers {
+			entry.Layers = append(entry.Layers, ocilayout.BlobRelPath(layer.Digest))
+		}
+		docker = append(docker, entry)
+	}
+	if err := ocilayout.WriteLayout(root, []ocispec.Descriptor{top}, docker); err != nil {
+		return "", fmt.Errorf("写入 OCI layout 失败: %w", err)
+	}
+
+	if opts.ArchiveFormat == ocilayout.FormatOCI {
+		if err := os.RemoveAll(outputFile); err != nil {
+			return "", err
+		}
+		if err := os.Rename(root, outputFile); err != nil {
+			return "", fmt.Errorf("保存 OCI layout 失败: %w", err)
+		}
+		return outputFile, nil
+	}
+	if err := packageImage(ctx, root, outputFile); err != nil {
+		return "", fmt.Errorf("打包镜像失败: %w", err)
+	}
+	return outputFile, nil
+}
+
+// downloadLayoutBlobs fetches every config and layer blob once, straight into
+// blobs/sha256 without decompressing.
+func (r *PullRunner) downloadLayoutBlobs(ctx context.Context, info *ImageInfo, images []platformImage, auth *pullRegistryAuth, opts PullOptions, root string) error {
+	var blobs []ocispec.Descriptor
+	seen := map[digest.Digest]bool{}
+	for _, image := range images {
+		for _, desc := range append([]ocispec.Descriptor{image.Manifest.Manifest.Config}, image.Manifest.Manifest.Layers...) {
+			if seen[desc.Digest] || isForeignLayer(desc) {
+				continue
+			}
+			seen[desc.Digest] = true
+			blobs = append(blobs, desc)
+		}
+	}
+
+	g, ctx := errgroup.WithContext(ctx)
+	sem := make(chan struct{}, maxLayerConcurrency)
+	for _, blob := range blobs {
+		desc := blob
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		g.Go(func() error {
+			defer func() { <-sem }()
+			if err := r.fetchBlob(ctx, info, desc, auth, opts, ocilayout.BlobPath(root, desc.Digest)); err != nil {
+				return fmt.Errorf("下载 blob %s 失败: %w", desc.Digest, err)
+			}
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return fmt.Errorf("下载镜像层失败: %w", err)
+	}
+	return nil
+}
+
+// fullImageName is the normalized reference containerd and Docker expect in
+// io.containerd.image.name, e.g. docker.io/library/busybox:latest.
+func fullImageName(info *ImageInfo) string {
+	registry := info.Registry
+	if registry == defaultRegistry {
+		registry = dockerHubDomain
+	}
+	return fmt.Sprintf("%s/%s:%s", registry, imagePath(info), info.Tag)
+}
@@ -0,0 +1,396 @@
+package pull
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"docker-manager/internal/ocilayout"
+
+	"github.com/Yui100901/MyGo/struct_utils"
+	digest "github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// registryIndex is the manifest list / OCI index a multi-platform pull started
+// from, kept verbatim so an unfiltered --all-platforms mirror keeps its digest.
+type registryIndex struct {
+	Raw       []byte
+	MediaType string
+	Index     *ocispec.Index
+}
+
+// platformImage is one platform manifest selected from an index. Descriptor is
+// the index entry (platform and annotations included) used to rebuild the
+// index on the target registry.
+type platformImage struct {
+	Descriptor ocispec.Descriptor
+	Manifest   *registryManifest
+}
+
+func isMultiPlatformPull(opts PullOptions) bool {
+	return opts.AllPlatforms || len(opts.Platforms) > 0
+}
+
+// parsePlatforms accepts os/arch[/variant] values, comma separated or repeated.
+func parsePlatforms(values []string) ([]ocispec.Platform, error) {
+	var platforms []ocispec.Platform
+	seen := map[string]bool{}
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			item = strings.TrimSpace(item)
+			if item == "" {
+				continue
+			}
+			parts := strings.Split(item, "/")
+			if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+				return nil, fmt.Errorf("无效平台 %q，格式应为 os/arch[/variant]", item)
+			}
+			platform := ocispec.Platform{OS: parts[0], Architecture: parts[1]}
+			if len(parts) == 3 {
+				platform.Variant = parts[2]
+			}
+			key := formatPlatform(platform)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			platforms = append(platforms, platform)
+		}
+	}
+	return platforms, nil
+}
+
+func formatPlatform(platform ocispec.Platform) string {
+	parts := []string{platform.OS, platform.Architecture}
+	if platform.Variant != "" {
+		parts = append(parts, platform.Variant)
+	}
+	return strings.Join(parts, "/")
+}
+
+func platformMatches(actual *ocispec.Platform, want ocispec.Platform) bool {
+	if actual == nil || actual.OS != want.OS || actual.Architecture != want.Architecture {
+		return false
+	}
+	return want.Variant == "" || actual.Variant == want.Variant
+}
+
+// selectIndexManifests picks the index entries for --platform, or every real
+// image platform for --all-platforms. Attestation manifests carry the
+// unknown/unknown platform and are not images, so they are left out.
+func selectIndexManifests(index *ocispec.Index, platforms []ocispec.Platform, all bool) ([]ocispec.Descriptor, error) {
+	if all {
+		var selected []ocispec.Descriptor
+		for _, m := range index.Manifests {
+			if m.Platform == nil || m.Platform.OS == "unknown" || isIndexMediaType(m.MediaType) {
+				continue
+			}
+			selected = append(selected, m)
+		}
+		if len(selected) == 0 {
+			return nil, fmt.Errorf("镜像索引中没有可用的平台清单")
+		}
+		return selected, nil
+	}
+	var selected []ocispec.Descriptor
+	seen := map[digest.Digest]bool{}
+	for _, want := range platforms {
+		found := false
+		for _, m := range index.Manifests {
+			if !platformMatches(m.Platform, want) {
+				continue
+			}
+			found = true
+			if !seen[m.Digest] {
+				seen[m.Digest] = true
+				selected = append(selected, m)
+			}
+			break
+		}
+		if !found {
+			return nil, fmt.Errorf("未找到匹配的平台: %s（可用: %s）", formatPlatform(want), strings.Join(indexPlatforms(index), ", "))
+		}
+	}
+	return selected, nil
+}
+
+func indexPlatforms(index *ocispec.Index) []string {
+	var values []string
+	for _, m := range index.Manifests {
+		if m.Platform != nil && m.Platform.OS != "unknown" {
+			values = append(values, formatPlatform(*m.Platform))
+		}
+	}
+	return values
+}
+
+func isIndexMediaType(mediaType string) bool {
+	return mediaType == ocispec.MediaTypeImageIndex || mediaType == dockerManifestListV2
+}
+
+// fetchPlatformManifests resolves the selected platform manifests. A source
+// that is a plain manifest yields a nil index and that single image.
+func (r *PullRunner) fetchPlatformManifests(ctx context.Context, info *ImageInfo, opts PullOptions) (*registryIndex, []platformImage, *pullRegistryAuth, error) {
+	manifestURL := registryAPIURL(opts, info, "manifests", getReference(info))
+	respBytes, auth, err := r.fetchRegistryBytesWithRetry(ctx, manifestURL, manifestAcceptHeaders(true), nil, info, opts, nil)
+	if err != nil {
+		return nil, nil, auth, fmt.Errorf("获取清单失败: %w", err)
+	}
+	if err := checkManifestDigest(respBytes, info.Digest); err != nil {
+		return nil, nil, auth, err
+	}
+	isIndex, err := isManifestIndex(respBytes)
+	if err != nil {
+		return nil, nil, auth, fmt.Errorf("解析清单类型失败: %w", err)
+	}
+	if !isIndex {
+		log.Printf("源镜像不是多架构索引，按单平台镜像处理: %s", getImageRef(info))
+		manifest, auth, err := parseRegistryManifest(respBytes, auth)
+		if err != nil {
+			return nil, nil, auth, err
+		}
+		desc := ocispec.Descriptor{MediaType: manifest.MediaType, Digest: digest.FromBytes(respBytes), Size: int64(len(respBytes))}
+		return nil, []platformImage{{Descriptor: desc, Manifest: manifest}}, auth, nil
+	}
+	index, err := struct_utils.UnmarshalData[ocispec.Index](respBytes, struct_utils.JSON)
+	if err != nil {
+		return nil, nil, auth, fmt.Errorf("解析多架构清单失败: %w", err)
+	}
+	mediaType := index.MediaType
+	if mediaType == "" {
+		mediaType = ocispec.MediaTypeImageIndex
+	}
+	selected, err := selectIndexManifests(index, opts.Platforms, opts.AllPlatforms)
+	if err != nil {
+		return nil, nil, auth, err
+	}
+	images := make([]platformImage, 0, len(selected))
+	for _, desc := range selected {
+		data, nextAuth, err := r.fetchRegistryBytesWithRetry(ctx, registryAPIURL(opts, info, "manifests", string(desc.Digest)), manifestAcceptHeaders(false), nil, info, opts, auth)
+		auth = nextAuth
+		if err != nil {
+			return nil, nil, auth, fmt.Errorf("获取架构清单 %s 失败: %w", platformLabel(desc), err)
+		}
+		if got := digest.FromBytes(data); got != desc.Digest {
+			return nil, nil, auth, fmt.Errorf("架构清单 %s digest 不匹配: 期望 %s，实际 %s", platformLabel(desc), desc.Digest, got)
+		}
+		manifest, _, err := parseRegistryManifest(data, auth)
+		if err != nil {
+			return nil, nil, auth, fmt.Errorf("解析架构清单 %s 失败: %w", platformLabel(desc), err)
+		}
+		images = append(images, platformImage{Descriptor: desc, Manifest: manifest})
+	}
+	return &registryIndex{Raw: respBytes, MediaType: mediaType, Index: index}, images, auth, nil
+}
+
+func manifestAcceptHeaders(includeIndex bool) map[string]string {
+	types := []string{dockerManifestV2, ocispec.MediaTypeImageManifest}
+	if includeIndex {
+		types = append(types, dockerManifestListV2, ocispec.MediaTypeImageIndex)
+	}
+	return map[string]string{"Accept": strings.Join(types, ", ")}
+}
+
+func platformLabel(desc ocispec.Descriptor) string {
+	if desc.Platform == nil {
+		return string(desc.Digest)
+	}
+	return formatPlatform(*desc.Platform)
+}
+
+// getMultiPlatformImage downloads every selected platform into one archive
+// and, with --to, mirrors them through the registry API so the target tag
+// points at a rebuilt index. docker push cannot recreate an index, so this
+// path never goes through the Docker daemon for pushing.
+func (r *PullRunner) getMultiPlatformImage(ctx context.Context, info *ImageInfo, opts PullOptions) error {
+	index, images, auth, err := r.fetchPlatformManifests(ctx, info, opts)
+	if err != nil {
+		return fmt.Errorf("获取清单失败: %w", err)
+	}
+	labels := make([]string, 0, len(images))
+	for _, image := range images {
+		labels = append(labels, platformLabel(image.Descriptor))
+	}
+	log.Printf("选中平台: %s", strings.Join(labels, ", "))
+
+	if !opts.Direct {
+		if err := r.downloadPlatformImages(ctx, info, index, images, auth, opts); err != nil {
+			return err
+		}
+	}
+	if opts.To == "" {
+		return nil
+	}
+	c, err := r.newRegistryCopy(ctx, info, opts)
+	if err != nil {
+		return err
+	}
+	c.sourceAuth = auth
+	published, err := c.copyPlatformImages(ctx, index, images, opts.AllPlatforms)
+	if err != nil {
+		return err
+	}
+	c.logDone()
+	return c.copySignatureArtifacts(ctx, published)
+}
+
+func (r *PullRunner) downloadPlatformImages(ctx context.Context, info *ImageInfo, index *registryIndex, images []platformImage, auth *pullRegistryAuth, opts PullOptions) error {
+	var outputFile string
+	var err error
+	if ocilayout.IsOCI(opts.ArchiveFormat) {
+		outputFile, err = r.writeOCIImage(ctx, info, index, images, auth, opts)
+	} else {
+		outputFile, err = r.writeDockerPlatformArchive(ctx, info, images, auth, opts)
+	}
+	if err != nil {
+		return err
+	}
+	log.Printf("镜像拉取成功: %s platforms=%d", outputFile, len(images))
+	if !opts.Load {
+		return nil
+	}
+	progressOutput := opts.ProgressOutput
+	if progressOutput == nil {
+		progressOutput = io.Discard
+	}
+	if err := r.loadPulledImage(ctx, outputFile, progressOutput); err != nil {
+		return fmt.Errorf("导入镜像失败: %w", err)
+	}
+	log.Printf("镜像导入成功: %s", outputFile)
+	return nil
+}
+
+func (r *PullRunner) writeDockerPlatformArchive(ctx context.Context, info *ImageInfo, images []platformImage, auth *pullRegistryAuth, opts PullOptions) (string, error) {
+	tempDir, err := prepareWorkspace(info)
+	if err != nil {
+		return "", fmt.Errorf("准备临时目录失败: %w", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			log.Printf("警告: 清理临时目录 %s 失败: %v", tempDir, err)
+		}
+	}()
+
+	for _, image := range images {
+		log.Printf("下载平台 %s", platformLabel(image.Descriptor))
+		if err := r.downloadConfig(ctx, info, image.Manifest.Manifest, auth, opts, tempDir); err != nil {
+			return "", fmt.Errorf("下载配置文件失败: %w", err)
+		}
+		if err := r.downloadLayers(ctx, info, image.Manifest.Manifest, auth, opts, tempDir); err != nil {
+			return "", fmt.Errorf("下载镜像层失败: %w", err)
+		}
+	}
+	if err := createPlatformManifestFile(info, images, r.defaultPlatformImage(images), tempDir); err != nil {
+		return "", fmt.Errorf("创建清单文件失败: %w", err)
+	}
+	outputFile, err := resolveOutputFile(info, opts)
+	if err != nil {
+		return "", fmt.Errorf("解析输出路径失败: %w", err)
+	}
+	if err := packageImage(ctx, tempDir, outputFile); err != nil {
+		return "", fmt.Errorf("打包镜像失败: %w", err)
+	}
+	return outputFile, nil
+}
+
+// defaultPlatformImage is the entry that receives the plain repo:tag in the
+// archive: the runner's --os/--arch when selected, otherwise the first one.
+func (r *PullRunner) defaultPlatformImage(images []platformImage) int {
+	want := ocispec.Platform{OS: r.platform.targetOS, Architecture: r.platform.targetArch, Variant: r.platform.targetVariant}
+	for i, image := range images {
+		if platformMatches(image.Descriptor.Platform, want) {
+			return i
+		}
+	}
+	return 0
+}
+
+// createPlatformManifestFile writes a docker-archive manifest.json with one
+// entry per platform. Every entry is tagged repo:tag-os-arch[-variant] so a
+// docker load keeps all of them addressable; the default entry also gets the
+// plain repo:tag.
+func createPlatformManifestFile(info *ImageInfo, images []platformImage, defaultImage int, tempDir string) error {
+	repoTags := platformRepoTags(info, images, defaultImage)
+	entries := make([]*ImageManifest, 0, len(images))
+	for i, image := range images {
+		manifest := image.Manifest.Manifest
+		entries = append(entries, &ImageManifest{
+			Config:   strings.TrimPrefix(string(manifest.Config.Digest), "sha256:") + ".json",
+			Layers:   getLayerPaths(manifest.Layers),
+			RepoTags: repoTags[i],
+		})
+	}
+	data, err := struct_utils.MarshalData(entries, struct_utils.JSON)
+	if err != nil {
+		return fmt.Errorf("序列化清单失败: %w", err)
+	}
+	return os.WriteFile(filepath.Join(tempDir, "manifest.json"), data, 0644)
+}
+
+func platformRepoTags(info *ImageInfo, images []platformImage, defaultImage int) [][]string {
+	base := localImageRef(info)
+	tags := make([][]string, len(images))
+	for i, image := range images {
+		if i == defaultImage {
+			tags[i] = append(tags[i], base)
+		}
+		if len(images) > 1 && image.Descriptor.Platform != nil {
+			tags[i] = append(tags[i], base+"-"+platformTagSuffix(*image.Descriptor.Platform))
+		}
+	}
+	return tags
+}
+
+func platformTagSuffix(platform ocispec.Platform) string {
+	return sanitizeOutputName(strings.ReplaceAll(formatPlatform(platform), "/", "-"))
+}
+
+// copyPlatformImages pushes each platform manifest by digest and then the
+// index under the target tag, returning the digest the tag now points at. An
+// unfiltered --all-platforms copy reuses the source index bytes; any other
+// selection gets a rebuilt index.
+func (c *registryCopy) copyPlatformImages(ctx context.Context, index *registryIndex, images []platformImage, all bool) (digest.Digest, error) {
+	if index == nil {
+		return images[0].Descriptor.Digest, c.copyImage(ctx, c.target.Tag, images[0].Manifest)
+	}
+	for _, image := range images {
+		log.Printf("推送平台 %s: %s", platformLabel(image.Descriptor), image.Descriptor.Digest)
+		if err := c.copyImage(ctx, string(image.Descriptor.Digest), image.Manifest); err != nil {
+			return "", fmt.Errorf("推送平台 %s 失败: %w", platformLabel(image.Descriptor), err)
+		}
+	}
+	indexManifest, err := platformIndexManifest(index, images, all)
+	if err != nil {
+		return "", err
+	}
+	return digest.FromBytes(indexManifest.Raw), c.putManifest(ctx, c.target.Tag, indexManifest)
+}
+
+// platformIndexManifest returns the index to publish for the selected images:
+// the source bytes when nothing was filtered out, otherwise a rebuilt index
+// keeping the source entries' platforms and annotations.
+func platformIndexManifest(index *registryIndex, images []platformImage, all bool) (*registryManifest, error) {
+	indexManifest := &registryManifest{Raw: index.Raw, MediaType: index.MediaType}
+	if all && len(images) == len(index.Index.Manifests) {
+		return indexManifest, nil
+	}
+	rebuilt := ocispec.Index{
+		Versioned:   index.Index.Versioned,
+		MediaType:   index.Index.MediaType,
+		Annotations: index.Index.Annotations,
+	}
+	for _, image := range images {
+		rebuilt.Manifests = append(rebuilt.Manifests, image.Descriptor)
+	}
+	data, err := struct_utils.MarshalData(rebuilt, struct_utils.JSON)
+	if err != nil {
+		return nil, fmt.Errorf("序列化镜像索引失败: %w", err)
+	}
+	indexManifest.Raw = data
+	return indexManifest, nil
+}
@@ -0,0 +1,430 @@
+package pull
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	rpt "docker-manager/internal/report"
+
+	"github.com/Yui100901/MyGo/struct_utils"
+	digest "github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/spf13/cobra"
+)
+
+// RegistryPruneOptions selects the tags dm registry prune keeps. A tag is
+// kept when any rule holds; everything else is deleted on --apply --confirm.
+type RegistryPruneOptions struct {
+	KeepLast  int
+	KeepRegex []string
+	OlderThan string
+	DryRun    bool
+	Apply     bool
+	Confirm   bool
+}
+
+type RegistryPruneReport struct {
+	GeneratedAt string                  `json:"generated_at"`
+	Repository  string                  `json:"repository"`
+	Policy      RegistryRetentionPolicy `json:"policy"`
+	Keep        []RegistryPruneTag      `json:"keep,omitempty"`
+	Remove      []RegistryPruneTag      `json:"remove,omitempty"`
+	Warnings    []string                `json:"warnings,omitempty"`
+	Applied     bool                    `json:"applied"`
+	// Deleted lists the manifest digests removed through the registry API.
+	Deleted []string `json:"deleted,omitempty"`
+}
+
+type RegistryRetentionPolicy struct {
+	KeepLast  int      `json:"keep_last,omitempty"`
+	KeepRegex []string `json:"keep_regex,omitempty"`
+	OlderThan string   `json:"older_than,omitempty"`
+}
+
+type RegistryPruneTag struct {
+	Tag     string   `json:"tag"`
+	Digest  string   `json:"digest"`
+	Created string   `json:"created,omitempty"`
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+// registryRetention is RegistryRetentionPolicy with parsed values.
+type registryRetention struct {
+	keepLast  int
+	keepRegex []*regexp.Regexp
+	olderThan time.Duration
+}
+
+func newRegistryPruneCommand(defaults func() CommandDefaults) *cobra.Command {
+	browse := registryBrowseOptions{Timeout: defaultPullTimeout}
+	opts := RegistryPruneOptions{}
+	cmd := &cobra.Command{
+		Use:   "prune <repo>",
+		Short: "按保留策略删除远程仓库中的旧 tag",
+		Long: `按保留策略清理 registry 仓库中的 tag：先列出全部 tag 并解析到 manifest digest 和镜像创建时间，满足任一保留规则的 tag 保留，其余通过 distribution API 删除 manifest。
+--keep-last 保留创建时间最新的 N 个 tag，--keep-regex 保留匹配的 tag，--older-than 只删除创建时间早于该时长的 tag（支持 30d 这样的天数）。
+删除 manifest 会移除指向同一 digest 的所有 tag，因此只要有一个 tag 需要保留，该 digest 的其它 tag 也会保留；没有创建时间的镜像不会删除。
+默认只输出预览，添加 --apply --confirm 执行删除；registry 需要开启删除（registry:2 的 REGISTRY_STORAGE_DELETE_ENABLED），删除后在 registry 侧运行垃圾回收才会释放存储。`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			info, err := parseRepositoryArg(args[0])
+			if err != nil {
+				return err
+			}
+			retention, err := opts.retention()
+			if err != nil {
+				return err
+			}
+			runner, err := browse.runner(cmd, defaults)
+			if err != nil {
+				return err
+			}
+			report, err := runner.pruneRegistryTags(cmd.Context(), info, browse.pullOptions(cmd.Context()), opts, retention)
+			if report.GeneratedAt == "" {
+				return err
+			}
+			if printErr := rpt.Print(cmd.OutOrStdout(), browse.Format, report, func(w io.Writer) {
+				printRegistryPruneReport(w, report)
+			}); printErr != nil {
+				return printErr
+			}
+			return err
+		},
+	}
+	cmd.Flags().IntVar(&opts.KeepLast, "keep-last", 0, "保留创建时间最新的 N 个 tag")
+	cmd.Flags().StringArrayVar(&opts.KeepRegex, "keep-regex", nil, "保留匹配该正则的 tag，可重复指定")
+	cmd.Flags().StringVar(&opts.OlderThan, "older-than", "", "只删除创建时间早于该时长的 tag，例如 720h、30d")
+	cmd.Flags().BoolVar(&opts.DryRun, "dry-run", false, "只输出保留和删除计划，不删除")
+	cmd.Flags().BoolVar(&opts.Apply, "apply", false, "根据计划删除 manifest")
+	cmd.Flags().BoolVar(&opts.Confirm, "confirm", false, "确认执行 --apply 删除操作")
+	browse.addFlags(cmd)
+	return cmd
+}
+
+// retention validates the options the way dm report prune and backup repo
+// prune do: at least one rule, and --apply only together with --confirm.
+func (o RegistryPruneOptions) retention() (registryRetention, error) {
+	var retention registryRetention
+	if o.KeepLast < 0 {
+		return retention, fmt.Errorf("--keep-last 不能为负数")
+	}
+	retention.keepLast = o.KeepLast
+	for _, pattern := range o.KeepRegex {
+		regex, err := regexp.Compile(pattern)
+		if err != nil {
+			return retention, fmt.Errorf("--keep-regex %q 无效: %w", pattern, err)
+		}
+		retention.keepRegex = append(retention.keepRegex, regex)
+	}
+	if o.OlderThan != "" {
+		age, err := parseRetentionAge(o.OlderThan)
+		if err != nil {
+			return retention, err
+		}
+		retention.olderThan = age
+	}
+	if retention.keepLast == 0 && len(retention.keepRegex) == 0 && retention.olderThan == 0 {
+		return retention, fmt.Errorf("至少需要一个 --keep-last、--keep-regex 或 --older-than 策略")
+	}
+	if o.DryRun && o.Apply {
+		return retention, fmt.Errorf("--dry-run 不能与 --apply 同时使用")
+	}
+	if o.Apply && !o.Confirm {
+		return retention, fmt.Errorf("--apply 会删除远程 registry 中的 manifest 和 tag；如确认执行，请添加 --confirm")
+	}
+	return retention, nil
+}
+
+// parseRetentionAge accepts Go durations plus whole days (30d).
+func parseRetentionAge(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err == nil && n > 0 {
+			return time.Duration(n) * 24 * time.Hour, nil
+		}
+	} else if age, err := time.ParseDuration(value); err == nil && age > 0 {
+		return age, nil
+	}
+	return 0, fmt.Errorf("无法解析 --older-than=%q，请使用 720h 或 30d 这样的正时长", value)
+}
+
+// pruneRegistryTags plans the retention of every tag of info's repository and
+// deletes the manifests nobody keeps when opts.Apply is set.
+func (r *PullRunner) pruneRegistryTags(ctx context.Context, info *ImageInfo, pullOpts PullOptions, opts RegistryPruneOptions, retention registryRetention) (RegistryPruneReport, error) {
+	tags, err := r.listTags(ctx, info, pullOpts)
+	if err != nil {
+		return RegistryPruneReport{}, err
+	}
+	report := RegistryPruneReport{
+		Repository: info.Registry + "/" + imagePath(info),
+		Policy:     RegistryRetentionPolicy{KeepLast: opts.KeepLast, KeepRegex: opts.KeepRegex, OlderThan: opts.OlderThan},
+	}
+	var auth *pullRegistryAuth
+	resolved := make([]RegistryPruneTag, 0, len(tags))
+	created := map[digest.Digest]time.Time{}
+	for _, tag := range tags {
+		d, nextAuth, err := r.resolveTagDigest(ctx, info, pullOpts, auth, tag)
+		auth = nextAuth
+		if err != nil {
+			return report, fmt.Errorf("解析 tag %s 失败: %w", tag, err)
+		}
+		if _, ok := created[d]; !ok {
+			t, nextAuth, err := r.manifestCreated(ctx, info, pullOpts, auth, d)
+			auth = nextAuth
+			if err != nil {
+				report.Warnings = append(report.Warnings, fmt.Sprintf("读取 %s (%s) 的创建时间失败: %v", tag, d, err))
+			}
+			created[d] = t
+		}
+		entry := RegistryPruneTag{Tag: tag, Digest: d.String()}
+		if t := created[d]; !t.IsZero() {
+			entry.Created = t.UTC().Format(time.RFC3339)
+		}
+		resolved = append(resolved, entry)
+	}
+
+	reasons := planRegistryRetention(resolved, created, retention, time.Now())
+	var removeDigests []digest.Digest
+	removing := map[string][]string{}
+	for _, entry := range resolved {
+		entry.Reasons = reasons[entry.Tag]
+		if len(entry.Reasons) > 0 {
+			report.Keep = append(report.Keep, entry)
+			continue
+		}
+		if _, ok := removing[entry.Digest]; !ok {
+			removeDigests = append(removeDigests, digest.Digest(entry.Digest))
+		}
+		removing[entry.Digest] = append(removing[entry.Digest], entry.Tag)
+		report.Remove = append(report.Remove, entry)
+	}
+	report.GeneratedAt = time.Now().Format(time.RFC3339)
+	if !opts.Apply {
+		return report, nil
+	}
+
+	deleteAuth, err := r.resolveDeleteAuth(ctx, info, pullOpts)
+	if err != nil {
+		return report, err
+	}
+	for _, d := range removeDigests {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		// CI may have moved a tag since the plan was made; deleting by digest
+		// would then take the new push with it.
+		moved := ""
+		for _, tag := range removing[d.String()] {
+			current, nextAuth, err := r.resolveTagDigest(ctx, info, pullOpts, deleteAuth, tag)
+			deleteAuth = nextAuth
+			if err != nil || current != d {
+				moved = tag
+				break
+			}
+		}
+		if moved != "" {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("tag %s 已不再指向 %s，跳过删除", moved, d))
+			continue
+		}
+		resp, nextAuth, err := r.doRegistryRequestWithScope(ctx, http.MethodDelete, registryAPIURL(pullOpts, info, "manifests", d.String()), nil, nil, registryDeleteScope(info), info, pullOpts, deleteAuth)
+		if err != nil {
+			return report, fmt.Errorf("删除 %s 失败: %w", d, err)
+		}
+		deleteAuth = nextAuth
+		drainResponse(resp)
+		switch resp.StatusCode {
+		case http.StatusAccepted, http.StatusOK, http.StatusNoContent, http.StatusNotFound:
+		case http.StatusMethodNotAllowed:
+			return report, fmt.Errorf("registry 未开启删除（HTTP 405），registry:2 需要设置 REGISTRY_STORAGE_DELETE_ENABLED=true")
+		default:
+			return report, fmt.Errorf("删除 %s 失败: %w", d, registryResponseError(resp))
+		}
+		report.Deleted = append(report.Deleted, d.String())
+	}
+	report.Applied = true
+	log.Printf("Registry prune: repository=%s deleted=%d kept=%d warnings=%d", report.Repository, len(report.Deleted), len(report.Keep), len(report.Warnings))
+	return report, nil
+}
+
+// planRegistryRetention returns the keep reasons of each tag; tags without a
+// reason are deleted. Deleting a manifest removes every tag pointing at it,
+// so a digest kept through one tag keeps its other tags as "shared".
+func planRegistryRetention(tags []RegistryPruneTag, created map[digest.Digest]time.Time, retention registryRetention, now time.Time) map[string][]string {
+	reasons := map[string][]string{}
+	order := make([]int, 0, len(tags))
+	for i, entry := range tags {
+		t := created[digest.Digest(entry.Digest)]
+		if t.IsZero() {
+			// The image config has no created time (or it could not be read),
+			// so the tag has no place in the --keep-last order and no age for
+			// --older-than; keep it instead of guessing.
+			reasons[entry.Tag] = append(reasons[entry.Tag], "no-created")
+			continue
+		}
+		order = append(order, i)
+		for _, regex := range retention.keepRegex {
+			if regex.MatchString(entry.Tag) {
+				reasons[entry.Tag] = append(reasons[entry.Tag], "regex")
+				break
+			}
+		}
+		if retention.olderThan > 0 && now.Sub(t) < retention.olderThan {
+			reasons[entry.Tag] = append(reasons[entry.Tag], "recent")
+		}
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		ta, tb := created[digest.Digest(tags[order[a]].Digest)], created[digest.Digest(tags[order[b]].Digest)]
+		if !ta.Equal(tb) {
+			return ta.After(tb)
+		}
+		return tags[order[a]].Tag > tags[order[b]].Tag
+	})
+	for n, i := range order {
+		if n >= retention.keepLast {
+			break
+		}
+		reasons[tags[i].Tag] = append(reasons[tags[i].Tag], "last")
+	}
+	kept := map[string]bool{}
+	for _, entry := range tags {
+		if len(reasons[entry.Tag]) > 0 {
+			kept[entry.Digest] = true
+		}
+	}
+	for _, entry := range tags {
+		if kept[entry.Digest] && len(reasons[entry.Tag]) == 0 {
+			reasons[entry.Tag] = []string{"shared"}
+		}
+	}
+	return reasons
+}
+
+// resolveTagDigest returns the manifest digest tag points at, from
+// Docker-Content-Digest when the registry sends it.
+func (r *PullRunner) resolveTagDigest(ctx context.Context, info *ImageInfo, opts PullOptions, auth *pullRegistryAuth, tag string) (digest.Digest, *pullRegistryAuth, error) {
+	manifestURL := registryAPIURL(opts, info, "manifests", tag)
+	resp, auth, err := r.doRegistryRequest(ctx, http.MethodHead, manifestURL, manifestAcceptHeaders(true), nil, info, opts, auth)
+	if err != nil {
+		return "", auth, err
+	}
+	drainResponse(resp)
+	if resp.StatusCode != http.StatusOK {
+		return "", auth, registryResponseError(resp)
+	}
+	if d, err := digest.Parse(resp.Header.Get("Docker-Content-Digest")); err == nil {
+		return d, auth, nil
+	}
+	data, auth, err := r.fetchRegistryBytesOnce(ctx, manifestURL, manifestAcceptHeaders(true), nil, info, opts, auth)
+	if err != nil {
+		return "", auth, err
+	}
+	return digest.FromBytes(data), auth, nil
+}
+
+// manifestCreated reads the creation time from the image config of d; for an
+// index the first image platform stands for the whole index.
+func (r *PullRunner) manifestCreated(ctx context.Context, info *ImageInfo, opts PullOptions, auth *pullRegistryAuth, d digest.Digest) (time.Time, *pullRegistryAuth, error) {
+	data, auth, err := r.fetchRegistryBytesWithRetry(ctx, registryAPIURL(opts, info, "manifests", d.String()), manifestAcceptHeaders(true), nil, info, opts, auth)
+	if err != nil {
+		return time.Time{}, auth, err
+	}
+	if err := checkManifestDigest(data, d.String()); err != nil {
+		return time.Time{}, auth, err
+	}
+	isIndex, err := isManifestIndex(data)
+	if err != nil {
+		return time.Time{}, auth, err
+	}
+	if isIndex {
+		index, err := struct_utils.UnmarshalData[ocispec.Index](data, struct_utils.JSON)
+		if err != nil {
+			return time.Time{}, auth, err
+		}
+		selected, err := selectIndexManifests(index, nil, true)
+		if err != nil {
+			return time.Time{}, auth, err
+		}
+		data, auth, err = r.fetchRegistryBytesWithRetry(ctx, registryAPIURL(opts, info, "manifests", string(selected[0].Digest)), manifestAcceptHeaders(false), nil, info, opts, auth)
+		if err != nil {
+			return time.Time{}, auth, err
+		}
+	}
+	manifest, auth, err := parseRegistryManifest(data, auth)
+	if err != nil {
+		return time.Time{}, auth, err
+	}
+	configDigest := manifest.Manifest.Config.Digest
+	data, auth, err = r.fetchRegistryBytesWithRetry(ctx, registryAPIURL(opts, info, "blobs", string(configDigest)), nil, nil, info, opts, auth)
+	if err != nil {
+		return time.Time{}, auth, err
+	}
+	config, err := struct_utils.UnmarshalData[ocispec.Image](data, struct_utils.JSON)
+	if err != nil {
+		return time.Time{}, auth, err
+	}
+	if config.Created == nil {
+		return time.Time{}, auth, nil
+	}
+	return *config.Created, auth, nil
+}
+
+// resolveDeleteAuth asks for a token that allows deletes up front, the same
+// way pushes ask for pull,push before the first upload.
+func (r *PullRunner) resolveDeleteAuth(ctx context.Context, info *ImageInfo, opts PullOptions) (*pullRegistryAuth, error) {
+	resp, err := r.sendRegistryRequest(ctx, http.MethodGet, registryBaseURL(opts, info)+"/", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	drainResponse(resp)
+	if resp.StatusCode != http.StatusUnauthorized {
+		return nil, nil
+	}
+	return r.resolveRegistryAuthWithScope(ctx, resp.Header.Get("WWW-Authenticate"), registryDeleteScope(info), info, opts)
+}
+
+func registryDeleteScope(info *ImageInfo) string {
+	return fmt.Sprintf("repository:%s:pull,delete", imagePath(info))
+}
+
+func printRegistryPruneReport(w io.Writer, report RegistryPruneReport) {
+	_, _ = fmt.Fprintf(w, "Registry 仓库清理: %s\n", report.Repository)
+	policy := report.Policy
+	_, _ = fmt.Fprintf(w, "保留策略: keep-last=%d keep-regex=%s older-than=%s\n", policy.KeepLast, strings.Join(policy.KeepRegex, ","), policy.OlderThan)
+	_, _ = fmt.Fprintf(w, "保留 tag: %d\n", len(report.Keep))
+	for _, tag := range report.Keep {
+		printRegistryPruneTag(w, tag)
+	}
+	_, _ = fmt.Fprintf(w, "删除 tag: %d\n", len(report.Remove))
+	for _, tag := range report.Remove {
+		printRegistryPruneTag(w, tag)
+	}
+	for _, warning := range report.Warnings {
+		_, _ = fmt.Fprintf(w, "警告: %s\n", warning)
+	}
+	if report.Applied {
+		_, _ = fmt.Fprintf(w, "已删除 manifest: %d\n", len(report.Deleted))
+	} else {
+		_, _ = fmt.Fprintln(w, "预览模式；添加 --apply --confirm 执行清理")
+	}
+}
+
+func printRegistryPruneTag(w io.Writer, tag RegistryPruneTag) {
+	created := tag.Created
+	if created == "" {
+		created = "-"
+	}
+	_, _ = fmt.Fprintf(w, "  - %s %s created=%s", tag.Tag, tag.Digest, created)
+	if len(tag.Reasons) > 0 {
+		_, _ = fmt.Fprintf(w, " keep=%s", strings.Join(tag.Reasons, ","))
+	}
+	_, _ = fmt.Fprintln(w)
+}
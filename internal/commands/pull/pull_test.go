@@ -1,26 +1,42 @@
 package pull
 
 import (
+	"archive/tar"
 	"bytes"
 	"compress/gzip"
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
 	"encoding/base64"
 	"encoding/json"
+	"encoding/pem"
 	"errors"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"path/filepath"
 	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"docker-manager/internal/appconfig"
+	"docker-manager/internal/ocilayout"
+
 	"github.com/Yui100901/MyGo/network/http_utils"
 	"github.com/klauspost/compress/zstd"
 	digest "github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 )
 
 // 测试parseImageInfo函数，验证不同格式的镜像字符串是否被正确解析为Registry、Repository、Image、Tag和Digest等字段。
@@ -1279,3 +1295,881 @@ func newTestPullRunner() *PullRunner {
 		runCredentialHelper: defaultRunPullCredentialHelper,
 	}
 }
+
+func TestDirectCopyUploadsMissingBlobsWithoutDocker(t *testing.T) {
+	config := []byte(`{"architecture":"amd64","os":"linux"}`)
+	layer := []byte("layer-data")
+	source := newFakeRegistry(t, "")
+	manifest := source.addImage("library/busybox", "1.36", config, layer)
+	target := newFakeRegistry(t, "push-token")
+	target.putBlob("mirror/busybox", config)
+
+	runner := newTestPullRunner()
+	runner.loadPulledImage = func(ctx context.Context, path string, output io.Writer) error {
+		t.Fatal("direct copy must not load into Docker")
+		return nil
+	}
+	runner.tagPulledImage = func(ctx context.Context, source, target string) error {
+		t.Fatal("direct copy must not tag with Docker")
+		return nil
+	}
+	runner.pushPulledImage = func(ctx context.Context, target, registryAuth string, output io.Writer) error {
+		t.Fatal("direct copy must not push with Docker")
+		return nil
+	}
+	configPath := writePullDockerConfig(t, target.host(), "dev-user", "dev-pass")
+	err := runner.getImage(source.host()+"/library/busybox:1.36", PullOptions{
+		To:           "http://" + target.host() + "/mirror",
+		Direct:       true,
+		PlainHTTP:    true,
+		DockerConfig: configPath,
+		OutputDir:    t.TempDir(),
+	})
+	if err != nil {
+		t.Fatalf("getImage() error = %v", err)
+	}
+	stored, ok := target.manifest("mirror/busybox", "1.36")
+	if !ok || !bytes.Equal(stored, manifest) {
+		t.Fatalf("target manifest = %s, want source bytes %s", stored, manifest)
+	}
+	if got := target.blob("mirror/busybox", layer); !bytes.Equal(got, layer) {
+		t.Fatalf("target layer = %q, want %q", got, layer)
+	}
+	if target.scope != "repository:mirror/busybox:pull,push" {
+		t.Fatalf("token scope = %q, want pull,push on target repository", target.scope)
+	}
+	uploads := target.requestsMatching("PUT /v2/mirror/busybox/blobs/uploads/")
+	if uploads != 1 {
+		t.Fatalf("blob uploads = %d, want only the missing layer", uploads)
+	}
+}
+
+func TestDirectCopyMountsBlobsWithinSameRegistry(t *testing.T) {
+	registry := newFakeRegistry(t, "")
+	registry.addImage("team/app", "v1", []byte(`{"os":"linux"}`), []byte("layer"))
+
+	runner := newTestPullRunner()
+	err := runner.getImage(registry.host()+"/team/app:v1", PullOptions{
+		To:        registry.host() + "/mirror",
+		Direct:    true,
+		PlainHTTP: true,
+	})
+	if err != nil {
+		t.Fatalf("getImage() error = %v", err)
+	}
+	if registry.mounts != 2 {
+		t.Fatalf("mounts = %d, want config and layer mounted", registry.mounts)
+	}
+	if n := registry.requestsMatching("GET /v2/team/app/blobs/"); n != 0 {
+		t.Fatalf("source blob downloads = %d, want 0", n)
+	}
+	if _, ok := registry.manifest("mirror/app", "v1"); !ok {
+		t.Fatal("target manifest missing")
+	}
+}
+
+func TestDirectCopyRequiresTo(t *testing.T) {
+	err := newTestPullRunner().getImage("busybox", PullOptions{Direct: true})
+	if err == nil || !strings.Contains(err.Error(), "--to") {
+		t.Fatalf("getImage() error = %v, want --to requirement", err)
+	}
+}
+
+// fakeRegistry is a minimal in-memory OCI distribution registry. When token
+// is set every /v2/ request needs "Bearer <token>" obtained from /token.
+type fakeRegistry struct {
+	*httptest.Server
+	t         *testing.T
+	token     string
+	mu        sync.Mutex
+	blobs     map[string][]byte
+	manifests map[string][]byte
+	requests  []string
+	scope     string
+	mounts    int
+	// ranges makes blob GETs honour Range like registries backed by object
+	// storage; blobHook, when set, may take over a blob GET entirely.
+	ranges   bool
+	blobHook func(w http.ResponseWriter, r *http.Request, data []byte) bool
+	// tagsPage caps tags/list pages below the client's n, as Docker Hub does.
+	tagsPage int
+}
+
+func newFakeRegistry(t *testing.T, token string) *fakeRegistry {
+	t.Helper()
+	registry := &fakeRegistry{t: t, token: token, blobs: map[string][]byte{}, manifests: map[string][]byte{}}
+	registry.Server = httptest.NewServer(http.HandlerFunc(registry.handle))
+	t.Cleanup(registry.Close)
+	return registry
+}
+
+func (f *fakeRegistry) host() string {
+	return strings.TrimPrefix(f.URL, "http://")
+}
+
+func (f *fakeRegistry) putBlob(repo string, data []byte) digest.Digest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	d := digest.FromBytes(data)
+	f.blobs[repo+"@"+d.String()] = data
+	return d
+}
+
+func (f *fakeRegistry) blob(repo string, data []byte) []byte {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.blobs[repo+"@"+digest.FromBytes(data).String()]
+}
+
+func (f *fakeRegistry) manifest(repo, ref string) ([]byte, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	data, ok := f.manifests[repo+":"+ref]
+	return data, ok
+}
+
+func (f *fakeRegistry) addImage(repo, tag string, config []byte, layers ...[]byte) []byte {
+	manifest := map[string]any{
+		"schemaVersion": 2,
+		"mediaType":     "application/vnd.oci.image.manifest.v1+json",
+		"config":        map[string]any{"mediaType": "application/vnd.oci.image.config.v1+json", "digest": f.putBlob(repo, config), "size": len(config)},
+	}
+	var descs []map[string]any
+	for _, layer := range layers {
+		descs = append(descs, map[string]any{"mediaType": "application/vnd.oci.image.layer.v1.tar", "digest": f.putBlob(repo, layer), "size": len(layer)})
+	}
+	manifest["layers"] = descs
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	f.mu.Lock()
+	f.manifests[repo+":"+tag] = data
+	f.manifests[repo+":"+digest.FromBytes(data).String()] = data
+	f.mu.Unlock()
+	return data
+}
+
+func (f *fakeRegistry) requestsMatching(prefix string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	count := 0
+	for _, request := range f.requests {
+		if strings.HasPrefix(request, prefix) {
+			count++
+		}
+	}
+	return count
+}
+
+func (f *fakeRegistry) handle(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	request := r.Method + " " + r.URL.Path
+	if value := r.Header.Get("Range"); value != "" {
+		request += " Range:" + value
+	}
+	f.requests = append(f.requests, request)
+	if r.URL.Path == "/token" {
+		f.scope = r.URL.Query().Get("scope")
+		_, _ = w.Write([]byte(`{"token":"` + f.token + `"}`))
+		return
+	}
+	if f.token != "" && r.Header.Get("Authorization") != "Bearer "+f.token {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="`+serverURLFromRequest(r)+`/token",service="fake"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/v2/")
+	body, _ := io.ReadAll(r.Body)
+	switch {
+	case path == "":
+		w.WriteHeader(http.StatusOK)
+	case strings.Contains(path, "/blobs/uploads/"):
+		repo, session, _ := strings.Cut(path, "/blobs/uploads/")
+		if r.Method == http.MethodPost {
+			if mount := r.URL.Query().Get("mount"); mount != "" {
+				if data, ok := f.blobs[r.URL.Query().Get("from")+"@"+mount]; ok {
+					f.blobs[repo+"@"+mount] = data
+					f.mounts++
+					w.WriteHeader(http.StatusCreated)
+					return
+				}
+			}
+			w.Header().Set("Location", "/v2/"+repo+"/blobs/uploads/session-1?state=x")
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		d := r.URL.Query().Get("digest")
+		if session == "" || r.URL.Query().Get("state") != "x" || digest.FromBytes(body).String() != d {
+			http.Error(w, "digest mismatch", http.StatusBadRequest)
+			return
+		}
+		f.blobs[repo+"@"+d] = body
+		w.WriteHeader(http.StatusCreated)
+	case strings.Contains(path, "/blobs/"):
+		repo, d, _ := strings.Cut(path, "/blobs/")
+		data, ok := f.blobs[repo+"@"+d]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Method == http.MethodGet && f.blobHook != nil && f.blobHook(w, r, data) {
+			return
+		}
+		if f.ranges {
+			http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+			return
+		}
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(data)
+		}
+	case path == "_catalog":
+		f.serveCatalog(w, r)
+	case strings.HasSuffix(path, "/tags/list"):
+		f.serveTags(w, r, strings.TrimSuffix(path, "/tags/list"))
+	case strings.Contains(path, "/manifests/"):
+		repo, ref, _ := strings.Cut(path, "/manifests/")
+		if r.Method == http.MethodPut {
+			f.manifests[repo+":"+ref] = body
+			f.manifests[repo+":"+digest.FromBytes(body).String()] = body
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		data, ok := f.manifests[repo+":"+ref]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Method == http.MethodDelete {
+			// Like distribution, deleting a manifest drops every tag on it.
+			for key, stored := range f.manifests {
+				if strings.HasPrefix(key, repo+":") && bytes.Equal(stored, data) {
+					delete(f.manifests, key)
+				}
+			}
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		w.Header().Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+		w.Header().Set("Docker-Content-Digest", digest.FromBytes(data).String())
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(data)
+		}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// serveTags pages through the repository's tags n at a time, pointing at the
+// next page with a Link header like distribution does.
+func (f *fakeRegistry) serveTags(w http.ResponseWriter, r *http.Request, repo string) {
+	var tags []string
+	for key := range f.manifests {
+		name, ref, _ := strings.Cut(key, ":")
+		if name == repo && !strings.HasPrefix(ref, "sha256:") {
+			tags = append(tags, ref)
+		}
+	}
+	f.servePage(w, r, "/v2/"+repo+"/tags/list", "tags", tags)
+}
+
+func (f *fakeRegistry) serveCatalog(w http.ResponseWriter, r *http.Request) {
+	seen := map[string]bool{}
+	var repos []string
+	for key := range f.manifests {
+		name, _, _ := strings.Cut(key, ":")
+		if !seen[name] {
+			seen[name] = true
+			repos = append(repos, name)
+		}
+	}
+	f.servePage(w, r, "/v2/_catalog", "repositories", repos)
+}
+
+func (f *fakeRegistry) servePage(w http.ResponseWriter, r *http.Request, path, key string, items []string) {
+	sort.Strings(items)
+	if last := r.URL.Query().Get("last"); last != "" {
+		items = items[sort.SearchStrings(items, last+"\x00"):]
+	}
+	n, _ := strconv.Atoi(r.URL.Query().Get("n"))
+	if f.tagsPage > 0 && (n <= 0 || n > f.tagsPage) {
+		n = f.tagsPage
+	}
+	if n > 0 && n < len(items) {
+		items = items[:n]
+		w.Header().Set("Link", fmt.Sprintf(`<%s?n=%d&last=%s>; rel="next"`, path, n, url.QueryEscape(items[n-1])))
+	}
+	_ = json.NewEncoder(w).Encode(map[string]any{key: items})
+}
+
+func TestParsePlatforms(t *testing.T) {
+	platforms, err := parsePlatforms([]string{"linux/amd64,linux/arm64/v8", "linux/amd64"})
+	if err != nil {
+		t.Fatalf("parsePlatforms() error = %v", err)
+	}
+	if len(platforms) != 2 || formatPlatform(platforms[1]) != "linux/arm64/v8" {
+		t.Fatalf("platforms = %#v", platforms)
+	}
+	if _, err := parsePlatforms([]string{"linux"}); err == nil {
+		t.Fatal("parsePlatforms(linux) error = nil")
+	}
+}
+
+func TestMultiPlatformPullWritesArchiveForEveryPlatform(t *testing.T) {
+	registry := newFakeRegistry(t, "")
+	shared := []byte("shared-base-layer")
+	amd64 := registry.addImage("team/app", "amd64-only", []byte(`{"architecture":"amd64"}`), shared, []byte("amd64-layer"))
+	arm64 := registry.addImage("team/app", "arm64-only", []byte(`{"architecture":"arm64"}`), shared)
+	registry.addIndex("team/app", "v1",
+		fakeIndexEntry{manifest: amd64, os: "linux", arch: "amd64"},
+		fakeIndexEntry{manifest: arm64, os: "linux", arch: "arm64", variant: "v8"},
+		fakeIndexEntry{manifest: []byte(`{"schemaVersion":2}`), os: "unknown", arch: "unknown"},
+	)
+
+	output := filepath.Join(t.TempDir(), "app.tar")
+	runner := newTestPullRunner()
+	err := runner.getImage(registry.host()+"/team/app:v1", PullOptions{Output: output, AllPlatforms: true, PlainHTTP: true})
+	if err != nil {
+		t.Fatalf("getImage() error = %v", err)
+	}
+	var entries []ImageManifest
+	if err := json.Unmarshal(readTarEntry(t, output, "manifest.json"), &entries); err != nil {
+		t.Fatalf("manifest.json: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("entries = %#v, want 2 platforms without the attestation", entries)
+	}
+	if strings.Join(entries[0].RepoTags, ",") != "team/app:v1,team/app:v1-linux-amd64" ||
+		strings.Join(entries[1].RepoTags, ",") != "team/app:v1-linux-arm64-v8" {
+		t.Fatalf("RepoTags = %v / %v", entries[0].RepoTags, entries[1].RepoTags)
+	}
+	if n := registry.requestsMatching("GET /v2/team/app/blobs/" + digest.FromBytes(shared).String()); n != 1 {
+		t.Fatalf("shared layer downloads = %d, want 1", n)
+	}
+}
+
+func TestMultiPlatformDirectCopyRebuildsIndexOnTarget(t *testing.T) {
+	source := newFakeRegistry(t, "")
+	amd64 := source.addImage("library/nginx", "a", []byte(`{"architecture":"amd64"}`), []byte("amd64"))
+	arm64 := source.addImage("library/nginx", "b", []byte(`{"architecture":"arm64"}`), []byte("arm64"))
+	s390x := source.addImage("library/nginx", "c", []byte(`{"architecture":"s390x"}`), []byte("s390x"))
+	source.addIndex("library/nginx", "latest",
+		fakeIndexEntry{manifest: amd64, os: "linux", arch: "amd64"},
+		fakeIndexEntry{manifest: arm64, os: "linux", arch: "arm64"},
+		fakeIndexEntry{manifest: s390x, os: "linux", arch: "s390x"},
+	)
+	target := newFakeRegistry(t, "")
+
+	runner := newTestPullRunner()
+	err := runner.getImage(source.host()+"/library/nginx:latest", PullOptions{
+		To:        target.host() + "/mirror",
+		Direct:    true,
+		PlainHTTP: true,
+		Platforms: []ocispec.Platform{{OS: "linux", Architecture: "amd64"}, {OS: "linux", Architecture: "arm64"}},
+	})
+	if err != nil {
+		t.Fatalf("getImage() error = %v", err)
+	}
+	data, ok := target.manifest("mirror/nginx", "latest")
+	if !ok {
+		t.Fatal("target index missing")
+	}
+	var index ocispec.Index
+	if err := json.Unmarshal(data, &index); err != nil {
+		t.Fatal(err)
+	}
+	if index.MediaType != ocispec.MediaTypeImageIndex || len(index.Manifests) != 2 ||
+		index.Manifests[1].Platform == nil || index.Manifests[1].Platform.Architecture != "arm64" {
+		t.Fatalf("index = %s", data)
+	}
+	for _, manifest := range [][]byte{amd64, arm64} {
+		if stored, ok := target.manifest("mirror/nginx", digest.FromBytes(manifest).String()); !ok || !bytes.Equal(stored, manifest) {
+			t.Fatalf("platform manifest %s not copied by digest", digest.FromBytes(manifest))
+		}
+	}
+	if target.blob("mirror/nginx", []byte("s390x")) != nil {
+		t.Fatal("unselected platform was copied")
+	}
+}
+
+type fakeIndexEntry struct {
+	manifest []byte
+	os       string
+	arch     string
+	variant  string
+}
+
+func (f *fakeRegistry) addIndex(repo, tag string, entries ...fakeIndexEntry) []byte {
+	index := ocispec.Index{MediaType: ocispec.MediaTypeImageIndex}
+	index.SchemaVersion = 2
+	for _, entry := range entries {
+		d := digest.FromBytes(entry.manifest)
+		f.mu.Lock()
+		f.manifests[repo+":"+d.String()] = entry.manifest
+		f.mu.Unlock()
+		index.Manifests = append(index.Manifests, ocispec.Descriptor{
+			MediaType: ocispec.MediaTypeImageManifest,
+			Digest:    d,
+			Size:      int64(len(entry.manifest)),
+			Platform:  &ocispec.Platform{OS: entry.os, Architecture: entry.arch, Variant: entry.variant},
+		})
+	}
+	data, err := json.Marshal(index)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	f.mu.Lock()
+	f.manifests[repo+":"+tag] = data
+	f.mu.Unlock()
+	return data
+}
+
+func readTarEntry(t *testing.T, path, name string) []byte {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	tr := tar.NewReader(file)
+	for {
+		header, err := tr.Next()
+		if err != nil {
+			t.Fatalf("tar entry %s not found: %v", name, err)
+		}
+		if header.Name == name {
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return data
+		}
+	}
+}
+
+func TestPullWritesOCIArchiveKeepingCompressedLayers(t *testing.T) {
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	_, _ = zw.Write([]byte("layer-content"))
+	_ = zw.Close()
+	registry := newFakeRegistry(t, "")
+	manifest := registry.addImage("team/app", "v1", []byte(`{"architecture":"amd64"}`), gz.Bytes())
+
+	output := filepath.Join(t.TempDir(), "app.tar")
+	err := newTestPullRunner().getImage(registry.host()+"/team/app:v1", PullOptions{Output: output, ArchiveFormat: ocilayout.FormatOCIArchive, PlainHTTP: true})
+	if err != nil {
+		t.Fatalf("getImage() error = %v", err)
+	}
+	if string(readTarEntry(t, output, "oci-layout")) != `{"imageLayoutVersion":"1.0.0"}` {
+		t.Fatal("oci-layout missing")
+	}
+	var index ocispec.Index
+	if err := json.Unmarshal(readTarEntry(t, output, "index.json"), &index); err != nil {
+		t.Fatal(err)
+	}
+	if len(index.Manifests) != 1 || index.Manifests[0].Digest != digest.FromBytes(manifest) ||
+		index.Manifests[0].Annotations[ocispec.AnnotationRefName] != "v1" ||
+		index.Manifests[0].Annotations["io.containerd.image.name"] != registry.host()+"/team/app:v1" {
+		t.Fatalf("index.json = %#v", index)
+	}
+	layerPath := "blobs/sha256/" + digest.FromBytes(gz.Bytes()).Encoded()
+	if !bytes.Equal(readTarEntry(t, output, layerPath), gz.Bytes()) {
+		t.Fatal("layer blob was not kept compressed")
+	}
+	var docker []ImageManifest
+	if err := json.Unmarshal(readTarEntry(t, output, "manifest.json"), &docker); err != nil {
+		t.Fatal(err)
+	}
+	if len(docker) != 1 || docker[0].Layers[0] != layerPath || docker[0].RepoTags[0] != "team/app:v1" {
+		t.Fatalf("manifest.json = %#v", docker)
+	}
+}
+
+func TestMultiPlatformPullWritesOCILayoutDirectory(t *testing.T) {
+	registry := newFakeRegistry(t, "")
+	amd64 := registry.addImage("team/app", "a", []byte(`{"architecture":"amd64"}`), []byte("amd64"))
+	arm64 := registry.addImage("team/app", "b", []byte(`{"architecture":"arm64"}`), []byte("arm64"))
+	source := registry.addIndex("team/app", "v1",
+		fakeIndexEntry{manifest: amd64, os: "linux", arch: "amd64"},
+		fakeIndexEntry{manifest: arm64, os: "linux", arch: "arm64"},
+	)
+
+	outputDir := t.TempDir()
+	err := newTestPullRunner().getImage(registry.host()+"/team/app:v1", PullOptions{OutputDir: outputDir, AllPlatforms: true, ArchiveFormat: ocilayout.FormatOCI, PlainHTTP: true})
+	if err != nil {
+		t.Fatalf("getImage() error = %v", err)
+	}
+	layout := filepath.Join(outputDir, "team_app_v1")
+	data, err := os.ReadFile(filepath.Join(layout, "index.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var index ocispec.Index
+	if err := json.Unmarshal(data, &index); err != nil {
+		t.Fatal(err)
+	}
+	if len(index.Manifests) != 1 || index.Manifests[0].Digest != digest.FromBytes(source) {
+		t.Fatalf("index.json = %s, want the unmodified source index", data)
+	}
+	for _, manifest := range [][]byte{amd64, arm64, source} {
+		if _, err := os.Stat(filepath.Join(layout, "blobs", "sha256", digest.FromBytes(manifest).Encoded())); err != nil {
+			t.Fatalf("blob %s missing: %v", digest.FromBytes(manifest), err)
+		}
+	}
+}
+
+func TestBatchPullDownloadsSharedLayerOnceThroughBlobCache(t *testing.T) {
+	registry := newFakeRegistry(t, "")
+	shared := []byte("shared-base-layer")
+	images := []string{}
+	for _, name := range []string{"api", "web", "worker"} {
+		registry.addImage("team/"+name, "v1", []byte(`{"architecture":"amd64","os":"`+name+`"}`), shared, []byte(name+"-layer"))
+		images = append(images, registry.host()+"/team/"+name+":v1")
+	}
+	sharedBlob := "/blobs/" + digest.FromBytes(shared).String()
+	sharedDownloads := func() int {
+		n := 0
+		for _, name := range []string{"api", "web", "worker"} {
+			n += registry.requestsMatching("GET /v2/team/" + name + sharedBlob)
+		}
+		return n
+	}
+
+	cacheDir := t.TempDir()
+	outputDir := t.TempDir()
+	for round := 1; round <= 2; round++ {
+		runner := newTestPullRunner()
+		if err := runner.useBlobCache(cacheDir, false); err != nil {
+			t.Fatal(err)
+		}
+		report, err := runPullBatch(context.Background(), runner, PullBatchOptions{
+			Images:      images,
+			OutputDir:   outputDir,
+			Concurrency: 3,
+			PlainHTTP:   true,
+		})
+		if err != nil || report.Succeeded != 3 {
+			t.Fatalf("round %d: runPullBatch() = %#v, %v", round, report, err)
+		}
+		if n := sharedDownloads(); n != 1 {
+			t.Fatalf("round %d: shared layer downloads = %d, want 1", round, n)
+		}
+	}
+	layerPath := sha256Hash(digest.FromBytes(shared).String()) + "/layer.tar"
+	for _, result := range []string{"team_api_v1.tar", "team_web_v1.tar", "team_worker_v1.tar"} {
+		matches, _ := filepath.Glob(filepath.Join(outputDir, "*"+result))
+		if len(matches) != 1 {
+			entries, _ := os.ReadDir(outputDir)
+			t.Fatalf("archive %s not found in %v", result, entries)
+		}
+		if !bytes.Equal(readTarEntry(t, matches[0], layerPath), shared) {
+			t.Fatalf("%s: shared layer content mismatch", result)
+		}
+	}
+}
+
+func TestBatchResumeContinuesPartialLayerWithRange(t *testing.T) {
+	registry := newFakeRegistry(t, "")
+	registry.ranges = true
+	layer := bytes.Repeat([]byte("0123456789"), 1000)
+	registry.addImage("team/app", "v1", []byte(`{"architecture":"amd64"}`), layer)
+	layerDigest := digest.FromBytes(layer).String()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	gets := 0
+	registry.blobHook = func(w http.ResponseWriter, r *http.Request, data []byte) bool {
+		if !strings.HasSuffix(r.URL.Path, layerDigest) {
+			return false
+		}
+		gets++
+		switch gets {
+		case 1:
+			// Drop the connection after 4000 bytes of a 10000 byte layer.
+			w.Header().Set("Accept-Ranges", "bytes")
+			w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+			_, _ = w.Write(data[:4000])
+			return true
+		case 2:
+			// The operator gives up on the retry with Ctrl-C.
+			cancel()
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return true
+		}
+		return false
+	}
+
+	opts := PullBatchOptions{
+		Images:      []string{registry.host() + "/team/app:v1"},
+		OutputDir:   t.TempDir(),
+		Concurrency: 1,
+		PlainHTTP:   true,
+	}
+	cacheDir := t.TempDir()
+	runner := newTestPullRunner()
+	if err := runner.useBlobCache(cacheDir, false); err != nil {
+		t.Fatal(err)
+	}
+	report, err := runPullBatch(ctx, runner, opts)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("runPullBatch() error = %v, want context.Canceled", err)
+	}
+	partial := report.Items[0].Partial
+	if len(partial) != 1 || partial[0].Digest != layerDigest || partial[0].Downloaded != 4000 || partial[0].Total != int64(len(layer)) {
+		t.Fatalf("partial = %#v", partial)
+	}
+	state, err := readPullBatchState(filepath.Join(opts.OutputDir, "pull-state.json"))
+	if err != nil || len(state.Items[opts.Images[0]].Partial) != 1 {
+		t.Fatalf("state = %#v, %v", state, err)
+	}
+
+	runner = newTestPullRunner()
+	if err := runner.useBlobCache(cacheDir, false); err != nil {
+		t.Fatal(err)
+	}
+	opts.Resume = true
+	report, err = runPullBatch(context.Background(), runner, opts)
+	if err != nil || report.Succeeded != 1 || len(report.Items[0].Partial) != 0 {
+		t.Fatalf("resumed runPullBatch() = %#v, %v", report, err)
+	}
+	// Both the interrupted retry and the resumed run continue at byte 4000.
+	if n := registry.requestsMatching("GET /v2/team/app/blobs/" + layerDigest + " Range:bytes=4000-"); n != 2 {
+		t.Fatalf("range requests = %d, want 2", n)
+	}
+	matches, _ := filepath.Glob(filepath.Join(opts.OutputDir, "*.tar"))
+	if len(matches) != 1 || !bytes.Equal(readTarEntry(t, matches[0], sha256Hash(layerDigest)+"/layer.tar"), layer) {
+		t.Fatalf("archive %v does not hold the complete layer", matches)
+	}
+}
+
+func TestHTTPSaveToFileRestartsWhenServerIgnoresRange(t *testing.T) {
+	content := []byte("complete-blob-content")
+	var rangeHeader string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rangeHeader = r.Header.Get("Range")
+		_, _ = w.Write(content)
+	}))
+	defer server.Close()
+
+	output := filepath.Join(t.TempDir(), "blob")
+	if err := os.WriteFile(partialDownloadPath(output), []byte("stale"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := newTestPullRunner().httpSaveToFile(context.Background(), server.URL, nil, nil, output); err != nil {
+		t.Fatalf("httpSaveToFile() error = %v", err)
+	}
+	if rangeHeader != "bytes=5-" {
+		t.Fatalf("Range = %q, want bytes=5-", rangeHeader)
+	}
+	if got, _ := os.ReadFile(output); !bytes.Equal(got, content) {
+		t.Fatalf("output = %q, want the full body instead of an append", got)
+	}
+}
+
+func TestBatchPullUsesRegistryMirrorsInOrderAndFallsBackToUpstream(t *testing.T) {
+	upstream := newFakeRegistry(t, "")
+	upstream.addImage("team/api", "v1", []byte(`{"architecture":"amd64"}`), []byte("api-layer"))
+	upstream.addImage("team/web", "v1", []byte(`{"architecture":"amd64"}`), []byte("web-layer"))
+	mirror := newFakeRegistry(t, "")
+	mirror.addImage("team/api", "v1", []byte(`{"architecture":"amd64"}`), []byte("api-layer"))
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	runner := newTestPullRunner()
+	err := runner.useRegistryMirrors(map[string][]appconfig.RegistryMirror{
+		upstream.host(): {
+			{Endpoint: down.URL},
+			{Endpoint: mirror.host(), PlainHTTP: true},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := runPullBatch(context.Background(), runner, PullBatchOptions{
+		Images:      []string{upstream.host() + "/team/api:v1", upstream.host() + "/team/web:v1"},
+		OutputDir:   t.TempDir(),
+		Concurrency: 1,
+		PlainHTTP:   true,
+	})
+	if err != nil {
+		t.Fatalf("runPullBatch() error = %v", err)
+	}
+	if report.Items[0].Endpoint != mirror.host() || report.Items[1].Endpoint != upstream.host() {
+		t.Fatalf("endpoints = %q, %q", report.Items[0].Endpoint, report.Items[1].Endpoint)
+	}
+	if n := upstream.requestsMatching("GET /v2/team/api/"); n != 0 {
+		t.Fatalf("upstream served %d api requests, want 0", n)
+	}
+	if n := mirror.requestsMatching("GET /v2/team/api/blobs/"); n != 2 {
+		t.Fatalf("mirror requests = %v", mirror.requests)
+	}
+	if n := upstream.requestsMatching("GET /v2/team/web/blobs/"); n != 2 {
+		t.Fatalf("upstream web blob requests = %d, want 2", n)
+	}
+}
+
+func TestParseMirrorEndpoint(t *testing.T) {
+	tests := []struct {
+		entry appconfig.RegistryMirror
+		want  registryEndpoint
+	}{
+		{entry: appconfig.RegistryMirror{Endpoint: "mirror.gcr.io"}, want: registryEndpoint{Host: "mirror.gcr.io"}},
+		{entry: appconfig.RegistryMirror{Endpoint: "https://mirror.local:5000/v2/"}, want: registryEndpoint{Host: "mirror.local:5000"}},
+		{entry: appconfig.RegistryMirror{Endpoint: "http://10.0.0.5:5000"}, want: registryEndpoint{Host: "10.0.0.5:5000", PlainHTTP: true}},
+	}
+	for _, tt := range tests {
+		got, err := parseMirrorEndpoint(tt.entry)
+		if err != nil || got != tt.want {
+			t.Fatalf("parseMirrorEndpoint(%q) = %#v, %v; want %#v", tt.entry.Endpoint, got, err, tt.want)
+		}
+	}
+	if _, err := parseMirrorEndpoint(appconfig.RegistryMirror{Endpoint: "https://proxy.local/docker"}); err == nil {
+		t.Fatal("parseMirrorEndpoint() error = nil for a path prefix")
+	}
+	if normalizeMirrorRegistry("docker.io") != defaultRegistry {
+		t.Fatal("docker.io mirrors must apply to Docker Hub images")
+	}
+}
+
+func TestVerifyKeyRefusesUnsignedImagesAndRecordsVerifiedDigest(t *testing.T) {
+	registry := newFakeRegistry(t, "")
+	signedManifest := registry.addImage("team/api", "v1", []byte(`{"architecture":"amd64"}`), []byte("api-layer"))
+	registry.addImage("team/web", "v1", []byte(`{"architecture":"amd64"}`), []byte("web-layer"))
+	otherManifest := registry.addImage("team/job", "v1", []byte(`{"architecture":"amd64"}`), []byte("job-layer"))
+	key := newTestSigningKey(t)
+	registry.addCosignArtifact("team/api", signedManifest, ".sig", key)
+	registry.addCosignArtifact("team/job", otherManifest, ".sig", newTestSigningKey(t))
+
+	runner := newTestPullRunner()
+	if err := runner.useVerifyKey(writeTestPublicKey(t, key)); err != nil {
+		t.Fatal(err)
+	}
+	outputDir := t.TempDir()
+	report, err := runPullBatch(context.Background(), runner, PullBatchOptions{
+		Images:      []string{registry.host() + "/team/api:v1", registry.host() + "/team/web:v1", registry.host() + "/team/job:v1"},
+		OutputDir:   outputDir,
+		Concurrency: 1,
+		PlainHTTP:   true,
+	})
+	if err == nil {
+		t.Fatal("runPullBatch() error = nil, want unsigned images to fail")
+	}
+	api, web, job := report.Items[0], report.Items[1], report.Items[2]
+	if api.Status != pullBatchStatusSuccess || api.Signature == nil || !api.Signature.Verified ||
+		api.Signature.Signatures != 1 || api.Signature.Digest != digest.FromBytes(signedManifest).String() {
+		t.Fatalf("api = %#v signature=%#v", api, api.Signature)
+	}
+	if web.Status != pullBatchStatusFailed || web.Signature == nil || web.Signature.Verified || !strings.Contains(web.Signature.Error, "未找到") {
+		t.Fatalf("web = %#v signature=%#v", web, web.Signature)
+	}
+	if job.Status != pullBatchStatusFailed || job.Signature == nil || !strings.Contains(job.Signature.Error, "签名与公钥不匹配") {
+		t.Fatalf("job = %#v signature=%#v", job, job.Signature)
+	}
+	entries, err := os.ReadDir(outputDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if strings.Contains(entry.Name(), "web") || strings.Contains(entry.Name(), "job") {
+			t.Fatalf("unsigned image written: %s", entry.Name())
+		}
+	}
+	if n := registry.requestsMatching("GET /v2/team/web/blobs/"); n != 0 {
+		t.Fatalf("unsigned image blobs downloaded %d times", n)
+	}
+}
+
+func TestDirectCopyWithCopySignaturesCopiesSignaturesAndAttestations(t *testing.T) {
+	source := newFakeRegistry(t, "")
+	manifest := source.addImage("team/api", "v1", []byte(`{"architecture":"amd64"}`), []byte("api-layer"))
+	key := newTestSigningKey(t)
+	source.addCosignArtifact("team/api", manifest, ".sig", key)
+	source.addCosignArtifact("team/api", manifest, ".att", key)
+	target := newFakeRegistry(t, "")
+
+	runner := newTestPullRunner()
+	if err := runner.useVerifyKey(writeTestPublicKey(t, key)); err != nil {
+		t.Fatal(err)
+	}
+	signature := &PullSignature{}
+	err := runner.getImage(source.host()+"/team/api:v1", PullOptions{
+		To:             target.host() + "/mirror",
+		Direct:         true,
+		PlainHTTP:      true,
+		CopySignatures: true,
+		signature:      signature,
+	})
+	if err != nil {
+		t.Fatalf("getImage() error = %v", err)
+	}
+	d := digest.FromBytes(manifest)
+	for _, suffix := range []string{".sig", ".att"} {
+		stored, ok := target.manifest("mirror/api", cosignTag(d, suffix))
+		if !ok {
+			t.Fatalf("%s not copied", suffix)
+		}
+		var copied ocispec.Manifest
+		if err := json.Unmarshal(stored, &copied); err != nil {
+			t.Fatal(err)
+		}
+		if target.blob("mirror/api", source.blobs["team/api@"+copied.Layers[0].Digest.String()]) == nil {
+			t.Fatalf("%s payload not copied", suffix)
+		}
+	}
+	if !signature.Verified || len(signature.Copied) != 2 || signature.Copied[0] != cosignTag(d, ".sig") {
+		t.Fatalf("signature = %#v", signature)
+	}
+}
+
+func newTestSigningKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func writeTestPublicKey(t *testing.T, key *ecdsa.PrivateKey) string {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "cosign.pub")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// addCosignArtifact stores a cosign-style artifact for manifest under its
+// sha256-<hex><suffix> tag, with one simple-signing layer signed by key.
+func (f *fakeRegistry) addCosignArtifact(repo string, manifest []byte, suffix string, key *ecdsa.PrivateKey) {
+	d := digest.FromBytes(manifest)
+	payload := []byte(`{"critical":{"identity":{"docker-reference":"` + repo + `"},"image":{"docker-manifest-digest":"` + d.String() + `"},"type":"cosign container image signature"},"optional":{"suffix":"` + suffix + `"}}`)
+	hash := sha256.Sum256(payload)
+	signature, err := ecdsa.SignASN1(rand.Reader, key, hash[:])
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	config := []byte(`{"architecture":"","os":""}`)
+	data, err := json.Marshal(map[string]any{
+		"schemaVersion": 2,
+		"mediaType":     ocispec.MediaTypeImageManifest,
+		"config":        map[string]any{"mediaType": "application/vnd.oci.image.config.v1+json", "digest": f.putBlob(repo, config), "size": len(config)},
+		"layers": []map[string]any{{
+			"mediaType":   "application/vnd.dev.cosign.simplesigning.v1+json",
+			"digest":      f.putBlob(repo, payload),
+			"size":        len(payload),
+			"annotations": map[string]string{cosignSignatureAnnotation: base64.StdEncoding.EncodeToString(signature)},
+		}},
+	})
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	f.mu.Lock()
+	f.manifests[repo+":"+cosignTag(d, suffix)] = data
+	f.mu.Unlock()
+}
@@ -106,21 +106,31 @@ func parseImageInfo(imageName string) (*ImageInfo, error) {
 	return spec, nil
 }
 
+// registryManifest keeps the exact manifest bytes next to the parsed form so
+// direct registry copies can re-upload the manifest without changing its digest.
+type registryManifest struct {
+	Raw       []byte
+	MediaType string
+	Manifest  *ocispec.Manifest
+}
+
 func (r *PullRunner) fetchManifest(ctx context.Context, info *ImageInfo, opts PullOptions) (*ocispec.Manifest, *pullRegistryAuth, error) {
-	manifestURL := registryAPIURL(opts, info, "manifests", getReference(info))
-	headers := map[string]string{
-		"Accept": strings.Join([]string{
-			dockerManifestV2,
-			dockerManifestListV2,
-			ocispec.MediaTypeImageManifest,
-			ocispec.MediaTypeImageIndex,
-		}, ", "),
+	fetched, auth, err := r.fetchRegistryManifest(ctx, info, opts)
+	if err != nil {
+		return nil, auth, err
 	}
+	return fetched.Manifest, auth, nil
+}
 
-	respBytes, auth, err := r.fetchRegistryBytesWithRetry(ctx, manifestURL, headers, nil, info, opts, nil)
+func (r *PullRunner) fetchRegistryManifest(ctx context.Context, info *ImageInfo, opts PullOptions) (*registryManifest, *pullRegistryAuth, error) {
+	manifestURL := registryAPIURL(opts, info, "manifests", getReference(info))
+	respBytes, auth, err := r.fetchRegistryBytesWithRetry(ctx, manifestURL, manifestAcceptHeaders(true), nil, info, opts, nil)
 	if err != nil {
 		return nil, auth, fmt.Errorf("获取清单失败: %w", err)
 	}
+	if err := checkManifestDigest(respBytes, info.Digest); err != nil {
+		return nil, auth, err
+	}
 
 	isIndex, err := isManifestIndex(respBytes)
 	if err != nil {
@@ -134,8 +144,19 @@ func (r *PullRunner) fetchManifest(ctx context.Context, info *ImageInfo, opts Pu
 		return r.handleOCIIndex(ctx, info, index, auth, opts)
 	}
 
-	manifest, err := struct_utils.UnmarshalData[ocispec.Manifest](respBytes, struct_utils.JSON)
-	return manifest, auth, err
+	return parseRegistryManifest(respBytes, auth)
+}
+
+func parseRegistryManifest(data []byte, auth *pullRegistryAuth) (*registryManifest, *pullRegistryAuth, error) {
+	manifest, err := struct_utils.UnmarshalData[ocispec.Manifest](data, struct_utils.JSON)
+	if err != nil {
+		return nil, auth, err
+	}
+	mediaType := manifest.MediaType
+	if mediaType == "" {
+		mediaType = ocispec.MediaTypeImageManifest
+	}
+	return &registryManifest{Raw: data, MediaType: mediaType, Manifest: manifest}, auth, nil
 }
 
 func getReference(info *ImageInfo) string {
@@ -145,46 +166,49 @@ func getReference(info *ImageInfo) string {
 	return info.Tag
 }
 
-func (r *PullRunner) handleOCIIndex(ctx context.Context, info *ImageInfo, index *ocispec.Index, auth *pullRegistryAuth, opts PullOptions) (*ocispec.Manifest, *pullRegistryAuth, error) {
+func (r *PullRunner) handleOCIIndex(ctx context.Context, info *ImageInfo, index *ocispec.Index, auth *pullRegistryAuth, opts PullOptions) (*registryManifest, *pullRegistryAuth, error) {
 	log.Println("[+] 检测到多架构镜像索引")
 	var selectedDigest string
 
+	want := ocispec.Platform{OS: r.platform.targetOS, Architecture: r.platform.targetArch, Variant: r.platform.targetVariant}
 	for _, m := range index.Manifests {
-		if m.Platform != nil &&
-			m.Platform.OS == r.platform.targetOS &&
-			m.Platform.Architecture == r.platform.targetArch {
+		if platformMatches(m.Platform, want) {
 			selectedDigest = string(m.Digest)
 			break
 		}
 	}
 
 	if selectedDigest == "" {
-		return nil, auth, fmt.Errorf("未找到匹配的平台: %s/%s", r.platform.targetOS, r.platform.targetArch)
+		return nil, auth, fmt.Errorf("未找到匹配的平台: %s", formatPlatform(want))
 	}
 
 	manifestURL := registryAPIURL(opts, info, "manifests", selectedDigest)
-	headers := map[string]string{
-		"Accept": strings.Join([]string{
-			dockerManifestV2,
-			ocispec.MediaTypeImageManifest,
-		}, ", "),
-	}
-
-	resp, auth, err := r.fetchRegistryBytesWithRetry(ctx, manifestURL, headers, nil, info, opts, auth)
+	resp, auth, err := r.fetchRegistryBytesWithRetry(ctx, manifestURL, manifestAcceptHeaders(false), nil, info, opts, auth)
 	if err != nil {
 		return nil, auth, fmt.Errorf("获取架构清单失败: %w", err)
 	}
+	if err := checkManifestDigest(resp, selectedDigest); err != nil {
+		return nil, auth, err
+	}
 
-	manifest, err := struct_utils.UnmarshalData[ocispec.Manifest](resp, struct_utils.JSON)
-	return manifest, auth, err
+	return parseRegistryManifest(resp, auth)
 }
 
 func registryAPIURL(opts PullOptions, info *ImageInfo, kind, ref string) string {
+	return fmt.Sprintf("%s/%s/%s/%s", registryBaseURL(opts, info), imagePath(info), kind, ref)
+}
+
+// registryBaseURL is the /v2 root of the endpoint serving info.
+func registryBaseURL(opts PullOptions, info *ImageInfo) string {
 	scheme := "https"
-	if opts.PlainHTTP {
+	plainHTTP := opts.PlainHTTP
+	if info.endpoint != nil {
+		plainHTTP = info.endpoint.PlainHTTP
+	}
+	if plainHTTP {
 		scheme = "http"
 	}
-	return fmt.Sprintf("%s://%s/v2/%s/%s/%s", scheme, info.Registry, imagePath(info), kind, ref)
+	return fmt.Sprintf("%s://%s/v2", scheme, endpointHost(info))
 }
 
 func (r *PullRunner) fetchRegistryBytesWithRetry(ctx context.Context, rawURL string, headers map[string]string, query map[string]string, info *ImageInfo, opts PullOptions, auth *pullRegistryAuth) ([]byte, *pullRegistryAuth, error) {
@@ -2,6 +2,7 @@ package pull
 
 import (
 	"context"
+	"docker-manager/internal/ocilayout"
 	"fmt"
 	"github.com/Yui100901/MyGo/network/http_utils"
 	"io"
@@ -48,6 +49,26 @@ func (r *PullRunner) getImage(imageName string, opts PullOptions) error {
 		return err
 	}
 
+	if opts.Direct && opts.To == "" {
+		return fmt.Errorf("--direct 需要配合 --to 使用")
+	}
+	imageInfo.endpoint = r.resolveEndpoint(ctx, imageInfo, opts)
+	opts.endpoint.served(imageInfo)
+	if r.verifier != nil {
+		if err := r.verifyImageSignature(ctx, imageInfo, opts); err != nil {
+			return fmt.Errorf("签名校验失败，拒绝拉取: %w", err)
+		}
+	}
+	if isMultiPlatformPull(opts) {
+		return r.getMultiPlatformImage(ctx, imageInfo, opts)
+	}
+	if opts.Direct {
+		return r.copyImageToRegistry(ctx, imageInfo, opts)
+	}
+	if ocilayout.IsOCI(opts.ArchiveFormat) {
+		return r.getOCIImage(ctx, imageInfo, opts)
+	}
+
 	tempDir, err := prepareWorkspace(imageInfo)
 	if err != nil {
 		return fmt.Errorf("准备临时目录失败: %w", err)
@@ -0,0 +1,310 @@
+package pull
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	digest "github.com/opencontainers/go-digest"
+)
+
+const (
+	cosignSignatureAnnotation = "dev.cosignproject.cosign/signature"
+	cosignSignatureType       = "cosign container image signature"
+	// maxSignaturePayloadSize bounds the simple-signing payloads read into memory.
+	maxSignaturePayloadSize = 1 << 20
+)
+
+// cosignArtifactSuffixes are the tags cosign attaches to a signed digest:
+// signatures, attestations and SBOMs.
+var cosignArtifactSuffixes = []string{".sig", ".att", ".sbom"}
+
+// PullSignature is the cosign outcome of one image: the verified digest with
+// --verify-key, and the artifact tags copied with --copy-signatures.
+type PullSignature struct {
+	Digest     string   `json:"digest,omitempty"`
+	Key        string   `json:"key,omitempty"`
+	Verified   bool     `json:"verified"`
+	Signatures int      `json:"signatures,omitempty"`
+	Copied     []string `json:"copied,omitempty"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// signatureVerifier checks cosign signatures offline against one public key.
+// There is no transparency log lookup: the key alone decides.
+type signatureVerifier struct {
+	path string
+	key  crypto.PublicKey
+}
+
+// cosignPayload is the part of the simple-signing payload that binds a
+// signature to a manifest digest.
+type cosignPayload struct {
+	Critical struct {
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+		Type string `json:"type"`
+	} `json:"critical"`
+}
+
+// useVerifyKey loads the cosign public key passed to --verify-key. Every
+// image is then verified before anything is downloaded, loaded or pushed.
+func (r *PullRunner) useVerifyKey(path string) error {
+	if path == "" {
+		return nil
+	}
+	key, err := loadCosignPublicKey(path)
+	if err != nil {
+		return fmt.Errorf("--verify-key: %w", err)
+	}
+	r.verifier = &signatureVerifier{path: path, key: key}
+	return nil
+}
+
+func loadCosignPublicKey(path string) (crypto.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取公钥失败: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, fmt.Errorf("%s 不是 PEM 格式的公钥", path)
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("解析公钥失败: %w", err)
+	}
+	switch key.(type) {
+	case *ecdsa.PublicKey, *rsa.PublicKey, ed25519.PublicKey:
+		return key, nil
+	default:
+		return nil, fmt.Errorf("不支持的公钥类型 %T", key)
+	}
+}
+
+// verifyImageSignature resolves the digest info refers to and requires at
+// least one cosign signature for it made by the --verify-key key. On success
+// info is pinned to that digest, so a tag moved in the meantime cannot slip
+// unsigned content into the rest of the pull.
+func (r *PullRunner) verifyImageSignature(ctx context.Context, info *ImageInfo, opts PullOptions) error {
+	result := opts.signature
+	if result == nil {
+		result = &PullSignature{}
+	}
+	*result = PullSignature{Key: r.verifier.path}
+	signed, count, err := r.verifySignatureOf(ctx, info, opts)
+	result.Digest = string(signed)
+	if err != nil {
+		result.Error = err.Error()
+		return err
+	}
+	result.Verified = true
+	result.Signatures = count
+	info.Digest = string(signed)
+	log.Printf("Signature verified: image=%s digest=%s signatures=%d key=%s", getImageRef(info), signed, count, r.verifier.path)
+	return nil
+}
+
+func (r *PullRunner) verifySignatureOf(ctx context.Context, info *ImageInfo, opts PullOptions) (digest.Digest, int, error) {
+	manifestURL := registryAPIURL(opts, info, "manifests", getReference(info))
+	data, auth, err := r.fetchRegistryBytesWithRetry(ctx, manifestURL, manifestAcceptHeaders(true), nil, info, opts, nil)
+	if err != nil {
+		return "", 0, fmt.Errorf("获取清单失败: %w", err)
+	}
+	signed := digest.FromBytes(data)
+	if err := checkManifestDigest(data, info.Digest); err != nil {
+		return signed, 0, err
+	}
+
+	source := info
+	tag := cosignTag(signed, ".sig")
+	sigManifest, auth, err := r.fetchOptionalManifest(ctx, source, opts, auth, tag)
+	if err == nil && sigManifest == nil && info.endpoint != nil {
+		// Pull-through mirrors only know tags somebody pulled before.
+		source = upstreamInfo(info)
+		sigManifest, auth, err = r.fetchOptionalManifest(ctx, source, opts, nil, tag)
+	}
+	if err != nil {
+		return signed, 0, fmt.Errorf("获取签名 %s 失败: %w", tag, err)
+	}
+	if sigManifest == nil {
+		return signed, 0, fmt.Errorf("未找到 %s 的 cosign 签名（%s）", signed, tag)
+	}
+
+	count := 0
+	var lastErr error
+	for _, layer := range sigManifest.Manifest.Layers {
+		encoded := layer.Annotations[cosignSignatureAnnotation]
+		if encoded == "" {
+			continue
+		}
+		if layer.Size > maxSignaturePayloadSize {
+			lastErr = fmt.Errorf("签名 payload %s 过大", layer.Digest)
+			continue
+		}
+		payload, nextAuth, err := r.fetchRegistryBytesWithRetry(ctx, registryAPIURL(opts, source, "blobs", string(layer.Digest)), nil, nil, source, opts, auth)
+		auth = nextAuth
+		if err != nil {
+			return signed, 0, fmt.Errorf("获取签名 payload 失败: %w", err)
+		}
+		if got := digest.FromBytes(payload); got != layer.Digest {
+			lastErr = fmt.Errorf("签名 payload digest 不匹配: 期望 %s，实际 %s", layer.Digest, got)
+			continue
+		}
+		if err := r.verifier.verify(payload, encoded, signed); err != nil {
+			lastErr = err
+			continue
+		}
+		count++
+	}
+	if count == 0 {
+		if lastErr == nil {
+			lastErr = fmt.Errorf("%s 中没有 cosign 签名", tag)
+		}
+		return signed, 0, fmt.Errorf("没有可用 %s 验证通过的签名: %w", r.verifier.path, lastErr)
+	}
+	return signed, count, nil
+}
+
+// verify checks one base64 signature over payload and that the payload is a
+// cosign claim about signed.
+func (v *signatureVerifier) verify(payload []byte, encoded string, signed digest.Digest) error {
+	signature, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return fmt.Errorf("签名不是有效的 base64: %w", err)
+	}
+	hash := sha256.Sum256(payload)
+	switch key := v.key.(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(key, hash[:], signature) {
+			return errors.New("签名与公钥不匹配")
+		}
+	case *rsa.PublicKey:
+		if rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], signature) != nil &&
+			rsa.VerifyPSS(key, crypto.SHA256, hash[:], signature, nil) != nil {
+			return errors.New("签名与公钥不匹配")
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(key, payload, signature) {
+			return errors.New("签名与公钥不匹配")
+		}
+	}
+	var claim cosignPayload
+	if err := json.Unmarshal(payload, &claim); err != nil {
+		return fmt.Errorf("解析签名 payload 失败: %w", err)
+	}
+	if claim.Critical.Type != cosignSignatureType {
+		return fmt.Errorf("签名 payload 类型 %q 不是 cosign 镜像签名", claim.Critical.Type)
+	}
+	if claim.Critical.Image.DockerManifestDigest != string(signed) {
+		return fmt.Errorf("签名针对 %s，而不是 %s", claim.Critical.Image.DockerManifestDigest, signed)
+	}
+	return nil
+}
+
+// cosignTag is the tag cosign stores the artifact with suffix under for d,
+// e.g. sha256-<hex>.sig.
+func cosignTag(d digest.Digest, suffix string) string {
+	return fmt.Sprintf("%s-%s%s", d.Algorithm(), d.Encoded(), suffix)
+}
+
+// fetchOptionalManifest GETs one manifest without retries; a missing
+// manifest is (nil, nil), which is normal for optional cosign artifacts.
+func (r *PullRunner) fetchOptionalManifest(ctx context.Context, info *ImageInfo, opts PullOptions, auth *pullRegistryAuth, ref string) (*registryManifest, *pullRegistryAuth, error) {
+	manifestURL := registryAPIURL(opts, info, "manifests", ref)
+	resp, auth, err := r.doRegistryRequest(ctx, http.MethodGet, manifestURL, manifestAcceptHeaders(false), nil, info, opts, auth)
+	if err != nil {
+		return nil, auth, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		drainResponse(resp)
+		return nil, auth, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		drainResponse(resp)
+		return nil, auth, registryResponseError(resp)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSignaturePayloadSize))
+	drainResponse(resp)
+	if err != nil {
+		return nil, auth, err
+	}
+	return parseRegistryManifest(data, auth)
+}
+
+// copySignatureArtifacts copies the cosign signatures, attestations and SBOMs
+// of the published digest to the --to repository. Their tags are derived from
+// the digest, which is why only registry copies that keep manifest bytes
+// unchanged can carry signatures along.
+func (c *registryCopy) copySignatureArtifacts(ctx context.Context, published digest.Digest) error {
+	if !c.sourceOpts.CopySignatures {
+		return nil
+	}
+	result := c.sourceOpts.signature
+	if result != nil && result.Digest == "" {
+		result.Digest = string(published)
+	}
+	var copied []string
+	for _, suffix := range cosignArtifactSuffixes {
+		tag := cosignTag(published, suffix)
+		manifest, auth, err := c.runner.fetchOptionalManifest(ctx, c.source, c.sourceOpts, c.sourceAuth, tag)
+		c.sourceAuth = auth
+		if err != nil {
+			return fmt.Errorf("获取 %s 失败: %w", tag, err)
+		}
+		if manifest == nil {
+			continue
+		}
+		if err := c.copyImage(ctx, tag, manifest); err != nil {
+			return fmt.Errorf("复制 %s 失败: %w", tag, err)
+		}
+		copied = append(copied, tag)
+	}
+	if result != nil {
+		result.Copied = copied
+	}
+	if len(copied) == 0 {
+		log.Printf("警告: 源 registry 没有 %s 的签名或证明，未复制", published)
+		return nil
+	}
+	log.Printf("Signatures copied: target=%s digest=%s tags=%s", c.targetRef, published, strings.Join(copied, ","))
+	return nil
+}
+
+// checkManifestDigest rejects manifest bytes that do not match the digest
+// they were requested by; expected may be empty for tag references.
+func checkManifestDigest(data []byte, expected string) error {
+	if expected == "" {
+		return nil
+	}
+	if got := digest.FromBytes(data); got.String() != expected {
+		return fmt.Errorf("清单 digest 不匹配: 期望 %s，实际 %s", expected, got)
+	}
+	return nil
+}
+
+// recorded returns a copy of p for the batch report, or nil when neither
+// verification nor signature copying touched it.
+func (p *PullSignature) recorded() *PullSignature {
+	if p == nil || (p.Key == "" && p.Digest == "") {
+		return nil
+	}
+	result := *p
+	result.Copied = append([]string(nil), p.Copied...)
+	return &result
+}
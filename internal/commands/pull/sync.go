@@ -0,0 +1,376 @@
+package pull
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+
+	"docker-manager/internal/appconfig"
+	"docker-manager/internal/commandflags"
+	"docker-manager/internal/ocilayout"
+	rpt "docker-manager/internal/report"
+
+	"github.com/distribution/reference"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// SyncSpec is the sync.yaml read by dm sync: a versioned description of which
+// tags of which repositories are mirrored where.
+type SyncSpec struct {
+	Defaults SyncDefaults `yaml:"defaults" json:"defaults"`
+	Images   []SyncEntry  `yaml:"images" json:"images"`
+}
+
+// SyncDefaults apply to every entry that does not set its own value. Target
+// is a registry or registry/namespace prefix, like --to of dm pull.
+type SyncDefaults struct {
+	Target       string   `yaml:"target" json:"target,omitempty"`
+	Platforms    []string `yaml:"platforms" json:"platforms,omitempty"`
+	AllPlatforms bool     `yaml:"all_platforms" json:"all_platforms,omitempty"`
+}
+
+// SyncEntry selects tags of one source repository. Tags is an explicit list;
+// otherwise the registry tag list is narrowed by Regex, Semver and Latest.
+// Target, when set, is the full target repository, which renames the image.
+type SyncEntry struct {
+	Source       string   `yaml:"source" json:"source"`
+	Tags         []string `yaml:"tags" json:"tags,omitempty"`
+	Regex        string   `yaml:"regex" json:"regex,omitempty"`
+	Semver       string   `yaml:"semver" json:"semver,omitempty"`
+	Latest       int      `yaml:"latest" json:"latest,omitempty"`
+	Target       string   `yaml:"target" json:"target,omitempty"`
+	Platforms    []string `yaml:"platforms" json:"platforms,omitempty"`
+	AllPlatforms *bool    `yaml:"all_platforms" json:"all_platforms,omitempty"`
+}
+
+// SyncPlan is what a sync spec resolves to against the registries right now.
+type SyncPlan struct {
+	File  string         `json:"file"`
+	Items []SyncPlanItem `json:"items"`
+}
+
+type SyncPlanItem struct {
+	Image     string   `json:"image"`
+	Target    string   `json:"target,omitempty"`
+	Platforms []string `json:"platforms,omitempty"`
+}
+
+func readSyncSpec(path string) (SyncSpec, error) {
+	var spec SyncSpec
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return spec, fmt.Errorf("读取 sync 文件失败: %w", err)
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&spec); err != nil && !errors.Is(err, io.EOF) {
+		return spec, fmt.Errorf("解析 sync 文件 %s 失败: %w", path, err)
+	}
+	if len(spec.Images) == 0 {
+		return spec, fmt.Errorf("sync 文件 %s 没有 images 条目", path)
+	}
+	for i, entry := range spec.Images {
+		if err := entry.validate(); err != nil {
+			return spec, fmt.Errorf("images[%d] %s: %w", i, entry.Source, err)
+		}
+	}
+	return spec, nil
+}
+
+func (e SyncEntry) validate() error {
+	if strings.TrimSpace(e.Source) == "" {
+		return fmt.Errorf("source 不能为空")
+	}
+	named, err := reference.ParseNormalizedNamed(e.Source)
+	if err != nil {
+		return fmt.Errorf("source 无效: %w", err)
+	}
+	if _, ok := named.(reference.Tagged); ok {
+		return fmt.Errorf("source 只写仓库名，tag 通过 tags、regex、semver 或 latest 选择")
+	}
+	if _, ok := named.(reference.Digested); ok {
+		return fmt.Errorf("source 不支持 digest")
+	}
+	if len(e.Tags) > 0 && (e.Regex != "" || e.Semver != "" || e.Latest != 0) {
+		return fmt.Errorf("tags 不能与 regex、semver 或 latest 同时使用")
+	}
+	if len(e.Tags) == 0 && e.Regex == "" && e.Semver == "" && e.Latest == 0 {
+		return fmt.Errorf("需要 tags、regex、semver 或 latest 之一")
+	}
+	if _, err := newTagSelector(e.Regex, e.Semver, e.Latest); err != nil {
+		return err
+	}
+	if e.Target != "" {
+		target, err := stripPushTargetScheme(strings.Trim(strings.TrimSpace(e.Target), "/"))
+		if err != nil {
+			return err
+		}
+		if isTaggedImageRef(target) || strings.Contains(target, "@") {
+			return fmt.Errorf("target 是目标仓库，不能带 tag 或 digest")
+		}
+	}
+	return nil
+}
+
+// planSync resolves every entry to concrete image references and the batch
+// overrides that send each one to its target with its platforms.
+func (r *PullRunner) planSync(ctx context.Context, file string, spec SyncSpec, opts PullOptions) (SyncPlan, map[string]pullBatchItem, error) {
+	plan := SyncPlan{File: file}
+	items := map[string]pullBatchItem{}
+	for i, entry := range spec.Images {
+		platforms, all, err := entry.platforms(spec.Defaults)
+		if err != nil {
+			return plan, nil, fmt.Errorf("images[%d] %s: %w", i, entry.Source, err)
+		}
+		tags, err := r.syncTags(ctx, entry, opts)
+		if err != nil {
+			return plan, nil, fmt.Errorf("images[%d] %s: %w", i, entry.Source, err)
+		}
+		if len(tags) == 0 {
+			log.Printf("警告: %s 没有匹配的 tag", entry.Source)
+			continue
+		}
+		source := strings.TrimSpace(entry.Source)
+		for _, tag := range tags {
+			image := source + ":" + tag
+			item := pullBatchItem{To: spec.Defaults.Target, Platforms: platforms, AllPlatforms: all}
+			if entry.Target != "" {
+				item.To = strings.TrimRight(strings.TrimSpace(entry.Target), "/") + ":" + tag
+			}
+			target := ""
+			if item.To != "" {
+				if target, err = resolvePullBatchTarget(image, item.To); err != nil {
+					return plan, nil, fmt.Errorf("images[%d] %s: %w", i, image, err)
+				}
+			}
+			if existing, ok := items[image]; ok {
+				if existing.To != item.To {
+					return plan, nil, fmt.Errorf("%s 在多个条目中指向不同的 target", image)
+				}
+				continue
+			}
+			items[image] = item
+			plan.Items = append(plan.Items, SyncPlanItem{Image: image, Target: target, Platforms: syncPlatformLabels(platforms, all)})
+		}
+	}
+	if len(plan.Items) == 0 {
+		return plan, nil, fmt.Errorf("sync 文件 %s 没有选中任何镜像", file)
+	}
+	return plan, items, nil
+}
+
+func (r *PullRunner) syncTags(ctx context.Context, entry SyncEntry, opts PullOptions) ([]string, error) {
+	if len(entry.Tags) > 0 {
+		return uniquePullBatchImages(entry.Tags), nil
+	}
+	selector, err := newTagSelector(entry.Regex, entry.Semver, entry.Latest)
+	if err != nil {
+		return nil, err
+	}
+	info, err := parseImageInfo(entry.Source)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := r.listTags(ctx, info, opts)
+	if err != nil {
+		return nil, err
+	}
+	selected := selector.selectTags(tags)
+	log.Printf("Sync tags: source=%s listed=%d selected=%d", entry.Source, len(tags), len(selected))
+	return selected, nil
+}
+
+func (e SyncEntry) platforms(defaults SyncDefaults) ([]ocispec.Platform, bool, error) {
+	values, all := defaults.Platforms, defaults.AllPlatforms
+	if len(e.Platforms) > 0 {
+		values, all = e.Platforms, false
+	}
+	if e.AllPlatforms != nil {
+		all = *e.AllPlatforms
+	}
+	if all {
+		if len(e.Platforms) > 0 {
+			return nil, false, fmt.Errorf("all_platforms 不能与 platforms 同时使用")
+		}
+		return nil, true, nil
+	}
+	platforms, err := parsePlatforms(values)
+	return platforms, false, err
+}
+
+func syncPlatformLabels(platforms []ocispec.Platform, all bool) []string {
+	if all {
+		return []string{"all"}
+	}
+	labels := make([]string, 0, len(platforms))
+	for _, platform := range platforms {
+		labels = append(labels, formatPlatform(platform))
+	}
+	return labels
+}
+
+func printSyncPlan(w io.Writer, plan SyncPlan) {
+	_, _ = fmt.Fprintf(w, "Sync plan: file=%s images=%d\n", plan.File, len(plan.Items))
+	for _, item := range plan.Items {
+		_, _ = fmt.Fprintf(w, "- %s", item.Image)
+		if item.Target != "" {
+			_, _ = fmt.Fprintf(w, " -> %s", item.Target)
+		}
+		if len(item.Platforms) > 0 {
+			_, _ = fmt.Fprintf(w, " platforms=%s", strings.Join(item.Platforms, ","))
+		}
+		_, _ = fmt.Fprintln(w)
+	}
+	_, _ = fmt.Fprintln(w, "预览模式；去掉 --dry-run 执行同步")
+}
+
+func NewSyncCommand() *cobra.Command {
+	return NewSyncCommandWithDefaults(nil)
+}
+
+func NewSyncCommandWithDefaults(defaults func() CommandDefaults) *cobra.Command {
+	var targetOS string
+	var arch string
+	var proxy string
+	var outputDir string
+	var direct bool
+	var archiveFormat string
+	var cacheDir string
+	var noCache bool
+	var registryMirrors map[string][]appconfig.RegistryMirror
+	var verifyKey string
+	var dockerConfig string
+	var plainHTTP bool
+	var dryRun bool
+	timeout := defaultPullTimeout
+	batchOpts := PullBatchOptions{
+		OutputDir:   ".",
+		Concurrency: 1,
+		Retries:     1,
+	}
+	cmd := &cobra.Command{
+		Use:   "sync -f sync.yaml",
+		Short: "按 sync.yaml 声明批量同步镜像",
+		Long: `按 sync.yaml 声明批量同步镜像。每个条目指定源仓库、tag 选择方式（tags 列表、regex、semver 范围、latest 最新 N 个）、平台和目标仓库；
+regex/semver/latest 通过 registry 的 /v2/<name>/tags/list 获取 tag 列表后筛选。
+同步复用 dm pull 的批量状态文件和报告，可使用 --resume、--skip-existing、--retries 和 --report；--dry-run 只输出解析出的镜像列表。`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+			defer stop()
+
+			applyCommandDefaults(cmd, defaults, &proxy, &targetOS, &arch, &outputDir, &cacheDir, &registryMirrors)
+			configureHTTPLogging(false)
+			if batchOpts.File == "" {
+				return fmt.Errorf("sync 需要 -f 指定 sync.yaml")
+			}
+			if timeout <= 0 {
+				return fmt.Errorf("--timeout 必须大于 0")
+			}
+			var err error
+			archiveFormat, err = ocilayout.ParseFormat(archiveFormat)
+			if err != nil {
+				return err
+			}
+			if direct && cmd.Flags().Changed("archive-format") {
+				return fmt.Errorf("--direct 不会生成本地归档，不能与 --archive-format 同时使用")
+			}
+			spec, err := readSyncSpec(batchOpts.File)
+			if err != nil {
+				return err
+			}
+			runner, err := NewPullRunnerWithTimeout(proxy, targetOS, arch, timeout)
+			if err != nil {
+				return fmt.Errorf("配置代理失败: %w", err)
+			}
+			if err := runner.useBlobCache(cacheDir, noCache || direct); err != nil {
+				return err
+			}
+			if err := runner.useRegistryMirrors(registryMirrors); err != nil {
+				return err
+			}
+			if err := runner.useVerifyKey(verifyKey); err != nil {
+				return err
+			}
+			plan, items, err := runner.planSync(ctx, batchOpts.File, spec, PullOptions{Context: ctx, DockerConfig: dockerConfig, PlainHTTP: plainHTTP})
+			if err != nil {
+				return err
+			}
+			if batchOpts.CopySignatures && !direct {
+				return fmt.Errorf("--copy-signatures 需要配合 --direct 使用")
+			}
+			if direct {
+				for _, item := range plan.Items {
+					if item.Target == "" {
+						return fmt.Errorf("--direct 需要每个条目都有 target，%s 没有", item.Image)
+					}
+				}
+			}
+			if dryRun {
+				return rpt.Print(cmd.OutOrStdout(), batchOpts.Format, plan, func(w io.Writer) {
+					printSyncPlan(w, plan)
+				})
+			}
+
+			batchOpts.Images = make([]string, 0, len(plan.Items))
+			for _, item := range plan.Items {
+				batchOpts.Images = append(batchOpts.Images, item.Image)
+			}
+			// The images come from the spec, not from a plain list file.
+			batchOpts.File = ""
+			batchOpts.items = items
+			batchOpts.Direct = direct
+			batchOpts.ArchiveFormat = archiveFormat
+			batchOpts.OutputDir = outputDir
+			batchOpts.DockerConfig = dockerConfig
+			batchOpts.PlainHTTP = plainHTTP
+			batchOpts.ProgressOutput = cmd.OutOrStdout()
+			if batchOpts.StateFile == "" {
+				batchOpts.StateFile = filepath.Join(outputDir, "sync-state.json")
+			}
+			report, err := runPullBatch(ctx, runner, batchOpts)
+			if errors.Is(err, context.Canceled) {
+				return err
+			}
+			if report.GeneratedAt != "" {
+				if writeErr := writePullBatchOutput(cmd.OutOrStdout(), batchOpts, report); writeErr != nil {
+					return writeErr
+				}
+			}
+			return err
+		},
+	}
+	cmd.Flags().StringVarP(&batchOpts.File, "file", "f", "", "sync.yaml 路径")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "只解析 sync.yaml 和 tag 列表并输出将同步的镜像，不拉取")
+	cmd.Flags().StringVar(&targetOS, "os", "linux", "条目未指定 platforms 时的目标操作系统")
+	cmd.Flags().StringVarP(&arch, "arch", "a", "amd64", "条目未指定 platforms 时的目标架构")
+	cmd.Flags().StringVar(&proxy, "proxy", "", "强制指定 HTTP 代理，例如 http://127.0.0.1:7890；为空时使用环境变量代理")
+	cmd.Flags().DurationVar(&timeout, "timeout", defaultPullTimeout, "连接、TLS 握手和响应头超时时间，例如 30s、2m、5m")
+	cmd.Flags().StringVar(&outputDir, "output-dir", ".", "没有 target 的条目输出 tar 文件的目录，也是默认状态文件目录")
+	cmd.Flags().StringVar(&archiveFormat, "archive-format", ocilayout.FormatDockerArchive, "镜像归档格式: docker-archive、oci（OCI layout 目录）或 oci-archive（OCI layout tar）")
+	cmd.Flags().StringVar(&cacheDir, "cache-dir", "", "共享 blob 缓存目录，默认读取配置 cache_dir 或用户缓存目录下的 docker-manager/blobs")
+	cmd.Flags().BoolVar(&noCache, "no-cache", false, "不使用共享 blob 缓存")
+	cmd.Flags().BoolVar(&direct, "direct", false, "直接在 registry 之间复制 blob 和 manifest，不落地 tar、不依赖 Docker daemon")
+	cmd.Flags().StringVar(&verifyKey, "verify-key", "", "cosign 公钥（PEM）路径，同步前离线校验镜像签名")
+	cmd.Flags().BoolVar(&batchOpts.CopySignatures, "copy-signatures", false, "registry 间复制时一并复制 cosign 签名、证明和 SBOM")
+	commandflags.AddDockerConfigFlag(cmd, &dockerConfig)
+	cmd.Flags().BoolVar(&plainHTTP, "plain-http", false, "使用 http:// 访问源 registry，适用于未启用 TLS 的内网 registry")
+	cmd.Flags().IntVar(&batchOpts.Concurrency, "concurrency", batchOpts.Concurrency, "并发同步的镜像数量")
+	cmd.Flags().IntVar(&batchOpts.Retries, "retries", batchOpts.Retries, "单个镜像失败后的重试次数")
+	cmd.Flags().BoolVar(&batchOpts.SkipExisting, "skip-existing", false, "目标 registry 已存在同名 manifest 则跳过")
+	cmd.Flags().BoolVar(&batchOpts.Resume, "resume", false, "读取状态文件并跳过已经成功的镜像")
+	cmd.Flags().StringVar(&batchOpts.StateFile, "state-file", "", "状态文件路径，默认写入 <output-dir>/sync-state.json")
+	cmd.Flags().StringVar(&batchOpts.ReportFile, "report", "", "额外写入 JSON 汇总报告文件")
+	commandflags.AddReportFormatFlag(cmd, &batchOpts.Format)
+	_ = cmd.RegisterFlagCompletionFunc("archive-format", completePullValues(ocilayout.FormatDockerArchive, ocilayout.FormatOCI, ocilayout.FormatOCIArchive))
+	return cmd
+}
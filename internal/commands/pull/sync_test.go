@@ -0,0 +1,117 @@
+package pull
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTagSelectorAppliesRegexSemverAndLatest(t *testing.T) {
+	tags := []string{"latest", "1.25.4", "1.26.0", "1.26.2", "1.27.1", "1.27.1-alpine", "1.27.3-alpine", "1.28.0-alpine", "v2.0.0", "mainline", "1.27rc1"}
+	tests := []struct {
+		name       string
+		regex      string
+		constraint string
+		latest     int
+		want       string
+	}{
+		{name: "semver range", constraint: ">=1.26 <2", want: "1.26.0,1.26.2,1.27.1"},
+		{name: "caret", constraint: "^1.26.1", want: "1.26.2,1.27.1"},
+		{name: "tilde and alternative", constraint: "~1.25.0 || =2.0.0", want: "1.25.4,v2.0.0"},
+		{name: "partial version", constraint: "1.26", want: "1.26.0,1.26.2"},
+		{name: "latest versions only", latest: 2, want: "v2.0.0,1.27.1"},
+		{name: "regex family latest", regex: `^\d+\.\d+\.\d+-alpine$`, latest: 2, want: "1.28.0-alpine,1.27.3-alpine"},
+		{name: "regex with semver", regex: `-alpine$`, constraint: "<1.28", want: "1.27.1-alpine,1.27.3-alpine"},
+		{name: "regex only", regex: `^main`, want: "mainline"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selector, err := newTagSelector(tt.regex, tt.constraint, tt.latest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Join(selector.selectTags(tags), ","); got != tt.want {
+				t.Fatalf("selectTags() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+	for _, bad := range []string{">=", "1.x", "!1.2", ">=1.2-beta"} {
+		if _, err := parseSemverRange(bad); err == nil {
+			t.Fatalf("parseSemverRange(%q) error = nil", bad)
+		}
+	}
+}
+
+func TestReadSyncSpecRejectsAmbiguousEntries(t *testing.T) {
+	dir := t.TempDir()
+	cases := map[string]string{
+		"tagged source":  "images:\n  - source: nginx:1.27\n    latest: 1\n",
+		"no selection":   "images:\n  - source: nginx\n",
+		"tags and regex": "images:\n  - source: nginx\n    tags: [\"1.27\"]\n    regex: '^1'\n",
+		"tagged target":  "images:\n  - source: nginx\n    tags: [\"1.27\"]\n    target: registry.local/nginx:stable\n",
+		"unknown field":  "images:\n  - source: nginx\n    tag: [\"1.27\"]\n",
+	}
+	for name, content := range cases {
+		path := filepath.Join(dir, strings.ReplaceAll(name, " ", "-")+".yaml")
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := readSyncSpec(path); err == nil {
+			t.Fatalf("%s: readSyncSpec() error = nil", name)
+		}
+	}
+}
+
+func TestSyncCopiesSelectedTagsToPerEntryTargets(t *testing.T) {
+	source := newFakeRegistry(t, "")
+	source.tagsPage = 2
+	for _, tag := range []string{"1.25.0", "1.26.0", "1.26.1", "1.27.0", "latest"} {
+		source.addImage("library/nginx", tag, []byte(`{"architecture":"amd64","tag":"`+tag+`"}`), []byte("nginx-"+tag))
+	}
+	source.addImage("library/redis", "7.2", []byte(`{"architecture":"amd64"}`), []byte("redis-7.2"))
+	target := newFakeRegistry(t, "")
+
+	dir := t.TempDir()
+	spec := filepath.Join(dir, "sync.yaml")
+	content := "defaults:\n  target: " + target.host() + "/mirror\n" +
+		"images:\n" +
+		"  - source: " + source.host() + "/library/nginx\n    semver: '>=1.26'\n    latest: 2\n" +
+		"  - source: " + source.host() + "/library/redis\n    tags: ['7.2']\n    target: " + target.host() + "/cache/redis-server\n"
+	if err := os.WriteFile(spec, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := NewSyncCommandWithDefaults(nil)
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"-f", spec, "--plain-http", "--direct", "--output-dir", dir, "--format", "json"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Execute() error = %v\n%s", err, out.String())
+	}
+	var report PullBatchReport
+	if err := json.Unmarshal(out.Bytes(), &report); err != nil {
+		t.Fatalf("report: %v\n%s", err, out.String())
+	}
+	if report.Total != 3 || report.Succeeded != 3 {
+		t.Fatalf("report = %#v", report)
+	}
+	for _, ref := range []string{"mirror/nginx:1.27.0", "mirror/nginx:1.26.1", "cache/redis-server:7.2"} {
+		repo, tag, _ := strings.Cut(ref, ":")
+		if _, ok := target.manifest(repo, tag); !ok {
+			t.Fatalf("%s not synced; requests = %v", ref, target.requests)
+		}
+	}
+	if _, ok := target.manifest("mirror/nginx", "1.26.0"); ok {
+		t.Fatal("tag outside latest 2 was synced")
+	}
+	if n := source.requestsMatching("GET /v2/library/nginx/tags/list"); n != 3 {
+		t.Fatalf("tags/list pages = %d, want 3", n)
+	}
+	state, err := readPullBatchState(filepath.Join(dir, "sync-state.json"))
+	if err != nil || len(state.Items) != 3 {
+		t.Fatalf("state = %#v, %v", state, err)
+	}
+}
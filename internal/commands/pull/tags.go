@@ -0,0 +1,336 @@
+package pull
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// tagsPageSize is the n= page size asked of /v2/<name>/tags/list; registries
+// may return fewer and point at the next page with a Link header.
+const tagsPageSize = 1000
+
+// listTags returns every tag of info's repository, following Link pagination.
+func (r *PullRunner) listTags(ctx context.Context, info *ImageInfo, opts PullOptions) ([]string, error) {
+	tags, _, err := r.listTagsPage(ctx, info, opts, tagsPageSize, "", 0)
+	return tags, err
+}
+
+// listTagsPage lists the tags after last, asking for pageSize per request and
+// stopping once limit tags were read (0 reads them all). next is the last tag
+// returned when the registry has more, i.e. the last= of the following page.
+func (r *PullRunner) listTagsPage(ctx context.Context, info *ImageInfo, opts PullOptions, pageSize int, last string, limit int) ([]string, string, error) {
+	first := registryAPIURL(opts, info, "tags", "list") + "?" + pageQuery(pageSize, last)
+	return r.listRegistryPages(ctx, first, "", info, opts, limit, imagePath(info)+" 的 tag")
+}
+
+// listRegistryPages reads a paginated list endpoint (tags/list or _catalog)
+// from first on, following Link rel="next". scope overrides the token scope
+// for endpoints that are not about info's repository.
+func (r *PullRunner) listRegistryPages(ctx context.Context, first, scope string, info *ImageInfo, opts PullOptions, limit int, subject string) ([]string, string, error) {
+	next := first
+	var auth *pullRegistryAuth
+	var items []string
+	for next != "" {
+		resp, nextAuth, err := r.doRegistryRequestWithScope(ctx, http.MethodGet, next, map[string]string{"Accept": "application/json"}, nil, scope, info, opts, auth)
+		if err != nil {
+			return nil, "", err
+		}
+		auth = nextAuth
+		if resp.StatusCode != http.StatusOK {
+			drainResponse(resp)
+			return nil, "", fmt.Errorf("列出 %s 失败: %w", subject, registryResponseError(resp))
+		}
+		var page struct {
+			Tags         []string `json:"tags"`
+			Repositories []string `json:"repositories"`
+		}
+		err = json.NewDecoder(io.LimitReader(resp.Body, 64<<20)).Decode(&page)
+		link := resp.Header.Get("Link")
+		base := resp.Request.URL
+		drainResponse(resp)
+		if err != nil {
+			return nil, "", fmt.Errorf("解析 %s 列表失败: %w", subject, err)
+		}
+		items = append(items, page.Tags...)
+		items = append(items, page.Repositories...)
+		next, err = nextPage(base, link)
+		if err != nil {
+			return nil, "", err
+		}
+		if limit > 0 && len(items) >= limit {
+			if len(items) > limit || next != "" {
+				items = items[:limit]
+				return items, items[limit-1], nil
+			}
+			break
+		}
+	}
+	return items, "", nil
+}
+
+func pageQuery(pageSize int, last string) string {
+	query := url.Values{}
+	if pageSize > 0 {
+		query.Set("n", strconv.Itoa(pageSize))
+	}
+	if last != "" {
+		query.Set("last", last)
+	}
+	return query.Encode()
+}
+
+// nextPage resolves the rel="next" target of a Link header, which registries
+// usually send relative to the registry root.
+func nextPage(base *url.URL, link string) (string, error) {
+	for _, part := range strings.Split(link, ",") {
+		target, params, ok := strings.Cut(strings.TrimSpace(part), ";")
+		if !ok || !strings.Contains(strings.ReplaceAll(params, " ", ""), `rel="next"`) {
+			continue
+		}
+		target = strings.Trim(strings.TrimSpace(target), "<>")
+		resolved, err := base.Parse(target)
+		if err != nil {
+			return "", fmt.Errorf("解析分页地址 %q 失败: %w", target, err)
+		}
+		return resolved.String(), nil
+	}
+	return "", nil
+}
+
+// tagSelector picks tags from a repository's tag list. Regex, semver and
+// latest narrow the list in that order; latest keeps the N highest versions.
+// Without a regex only plain version tags (1.27, v2.5.1) take part in semver
+// and latest; with one, the version prefix of the matching tags is compared,
+// so `^\d+\.\d+-alpine$` plus latest: 2 works as expected.
+type tagSelector struct {
+	regex  *regexp.Regexp
+	semver semverRange
+	latest int
+}
+
+func newTagSelector(pattern, constraint string, latest int) (tagSelector, error) {
+	var selector tagSelector
+	if pattern != "" {
+		regex, err := regexp.Compile(pattern)
+		if err != nil {
+			return selector, fmt.Errorf("regex %q 无效: %w", pattern, err)
+		}
+		selector.regex = regex
+	}
+	if constraint != "" {
+		semver, err := parseSemverRange(constraint)
+		if err != nil {
+			return selector, err
+		}
+		selector.semver = semver
+	}
+	if latest < 0 {
+		return selector, fmt.Errorf("latest 不能为负数")
+	}
+	selector.latest = latest
+	return selector, nil
+}
+
+func (s tagSelector) selectTags(tags []string) []string {
+	type candidate struct {
+		tag     string
+		version tagVersion
+	}
+	versioned := s.semver != nil || s.latest > 0
+	var selected []candidate
+	for _, tag := range tags {
+		if s.regex != nil && !s.regex.MatchString(tag) {
+			continue
+		}
+		version, ok := parseTagVersion(tag)
+		if versioned && (!ok || (s.regex == nil && version.suffix != "")) {
+			continue
+		}
+		if s.semver != nil && !s.semver.matches(version) {
+			continue
+		}
+		selected = append(selected, candidate{tag: tag, version: version})
+	}
+	if s.latest > 0 {
+		sort.SliceStable(selected, func(i, j int) bool {
+			if c := compareTagVersions(selected[i].version, selected[j].version); c != 0 {
+				return c > 0
+			}
+			return selected[i].tag > selected[j].tag
+		})
+		if len(selected) > s.latest {
+			selected = selected[:s.latest]
+		}
+	}
+	result := make([]string, 0, len(selected))
+	for _, c := range selected {
+		result = append(result, c.tag)
+	}
+	return result
+}
+
+// tagVersion is the numeric version at the start of a tag. Missing minor or
+// patch parts count as zero; suffix is whatever follows (e.g. "-alpine").
+type tagVersion struct {
+	parts  [3]int
+	count  int
+	suffix string
+}
+
+var tagVersionPattern = regexp.MustCompile(`^v?(\d+)(?:\.(\d+))?(?:\.(\d+))?(.*)$`)
+
+func parseTagVersion(tag string) (tagVersion, bool) {
+	match := tagVersionPattern.FindStringSubmatch(tag)
+	if match == nil {
+		return tagVersion{}, false
+	}
+	var version tagVersion
+	for i := 0; i < 3; i++ {
+		if match[i+1] == "" {
+			break
+		}
+		n, err := strconv.Atoi(match[i+1])
+		if err != nil {
+			return tagVersion{}, false
+		}
+		version.parts[i] = n
+		version.count++
+	}
+	version.suffix = match[4]
+	if version.suffix != "" && !strings.ContainsAny(version.suffix[:1], "-+_") {
+		// 1.2rc1 or 20240101T... are not versions we can order reliably.
+		return tagVersion{}, false
+	}
+	return version, true
+}
+
+func compareTagVersions(a, b tagVersion) int {
+	for i := 0; i < 3; i++ {
+		if a.parts[i] != b.parts[i] {
+			if a.parts[i] < b.parts[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// semverRange is a set of alternatives (||), each a list of comparators that
+// must all hold. Supported forms: =, >, >=, <, <=, ^, ~, a bare (possibly
+// partial) version such as 1.27, and *.
+type semverRange [][]semverComparator
+
+type semverComparator struct {
+	op      string
+	version tagVersion
+}
+
+func parseSemverRange(expr string) (semverRange, error) {
+	var result semverRange
+	for _, alternative := range strings.Split(expr, "||") {
+		fields := strings.Fields(strings.ReplaceAll(alternative, ",", " "))
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("semver 范围 %q 无效", expr)
+		}
+		var comparators []semverComparator
+		for i := 0; i < len(fields); i++ {
+			field := fields[i]
+			// Accept ">= 1.2" as well as ">=1.2".
+			if strings.Trim(field, "<>=^~") == "" && i+1 < len(fields) {
+				field += fields[i+1]
+				i++
+			}
+			parsed, err := parseSemverComparator(field)
+			if err != nil {
+				return nil, fmt.Errorf("semver 范围 %q 无效: %w", expr, err)
+			}
+			comparators = append(comparators, parsed...)
+		}
+		result = append(result, comparators)
+	}
+	return result, nil
+}
+
+func parseSemverComparator(field string) ([]semverComparator, error) {
+	if field == "*" || field == "x" {
+		return nil, nil
+	}
+	value := strings.TrimLeft(field, "<>=^~")
+	op := field[:len(field)-len(value)]
+	version, ok := parseTagVersion(value)
+	if !ok || version.suffix != "" {
+		return nil, fmt.Errorf("%q 不是版本号", value)
+	}
+	switch op {
+	case ">", ">=", "<", "<=":
+		return []semverComparator{{op: op, version: version}}, nil
+	case "", "=", "==":
+		if version.count == 3 {
+			return []semverComparator{{op: "=", version: version}}, nil
+		}
+		// A partial version matches everything below the next bump of its
+		// last given part: 1.27 is >=1.27.0 <1.28.0.
+		return []semverComparator{{op: ">=", version: version}, {op: "<", version: bumpVersion(version, version.count-1)}}, nil
+	case "^":
+		part := 0
+		for part < version.count-1 && version.parts[part] == 0 {
+			part++
+		}
+		return []semverComparator{{op: ">=", version: version}, {op: "<", version: bumpVersion(version, part)}}, nil
+	case "~":
+		part := 1
+		if version.count == 1 {
+			part = 0
+		}
+		return []semverComparator{{op: ">=", version: version}, {op: "<", version: bumpVersion(version, part)}}, nil
+	}
+	return nil, fmt.Errorf("不支持的运算符 %q", op)
+}
+
+func bumpVersion(version tagVersion, part int) tagVersion {
+	bumped := tagVersion{count: 3}
+	copy(bumped.parts[:part], version.parts[:part])
+	bumped.parts[part] = version.parts[part] + 1
+	return bumped
+}
+
+func (r semverRange) matches(version tagVersion) bool {
+	for _, comparators := range r {
+		ok := true
+		for _, comparator := range comparators {
+			if !comparator.matches(version) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (c semverComparator) matches(version tagVersion) bool {
+	cmp := compareTagVersions(version, c.version)
+	switch c.op {
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	default:
+		return cmp == 0
+	}
+}
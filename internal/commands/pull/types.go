@@ -6,7 +6,11 @@ import (
 	"sync"
 	"time"
 
+	"docker-manager/internal/appconfig"
+	"docker-manager/internal/blobcache"
+
 	"github.com/Yui100901/MyGo/network/http_utils"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 )
 
 const (
@@ -27,8 +31,9 @@ const (
 var pullProgressMu sync.Mutex
 
 type targetPlatform struct {
-	targetOS   string
-	targetArch string
+	targetOS      string
+	targetArch    string
+	targetVariant string
 }
 
 type ImageInfo struct {
@@ -37,6 +42,8 @@ type ImageInfo struct {
 	Image      string
 	Tag        string
 	Digest     string
+	// endpoint is the registry mirror serving this pull; nil means Registry.
+	endpoint *registryEndpoint
 }
 
 type PullOptions struct {
@@ -45,16 +52,31 @@ type PullOptions struct {
 	OutputDir      string
 	Load           bool
 	To             string
+	Direct         bool
+	Platforms      []ocispec.Platform
+	AllPlatforms   bool
+	ArchiveFormat  string
 	DockerConfig   string
 	PlainHTTP      bool
 	ProgressOutput io.Writer
+	// CopySignatures copies cosign signatures, attestations and SBOMs along
+	// with registry-to-registry copies.
+	CopySignatures bool
+	// partials collects blobs left half-downloaded, for the batch state file.
+	partials *pullPartials
+	// endpoint records which registry endpoint served the image.
+	endpoint *pullEndpointRecord
+	// signature receives the cosign verification and copy result.
+	signature *PullSignature
 }
 
 type CommandDefaults struct {
-	Proxy     string
-	TargetOS  string
-	Arch      string
-	OutputDir string
+	Proxy           string
+	TargetOS        string
+	Arch            string
+	OutputDir       string
+	CacheDir        string
+	RegistryMirrors map[string][]appconfig.RegistryMirror
 }
 
 // PullRunner owns all side-effectful dependencies for pull. Tests replace
@@ -66,4 +88,11 @@ type PullRunner struct {
 	tagPulledImage      func(ctx context.Context, source, target string) error
 	pushPulledImage     func(ctx context.Context, target, registryAuth string, output io.Writer) error
 	runCredentialHelper func(ctx context.Context, helper, server string) (pullRegistryCredential, error)
+	// blobCache is shared by every image of a batch; nil downloads each blob
+	// into the workspace directly.
+	blobCache *blobcache.Cache
+	// mirrors lists registry_mirrors endpoints by normalized registry name.
+	mirrors map[string][]registryEndpoint
+	// verifier, when set, requires a valid cosign signature for every image.
+	verifier *signatureVerifier
 }
@@ -0,0 +1,567 @@
+package reverse
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"docker-manager/internal/runconfig"
+
+	"github.com/moby/moby/api/types/container"
+)
+
+// compose 写入容器、网络和卷的标签
+const (
+	composeLabelPrefix    = "com.docker.compose."
+	composeProjectLabel   = "com.docker.compose.project"
+	composeServiceLabel   = "com.docker.compose.service"
+	composeNumberLabel    = "com.docker.compose.container-number"
+	composeDependsOnLabel = "com.docker.compose.depends_on"
+	composeNetworkLabel   = "com.docker.compose.network"
+	composeVolumeLabel    = "com.docker.compose.volume"
+)
+
+// ComposeProject 是按 compose 标签还原的单个项目。
+type ComposeProject struct {
+	Name     string
+	File     ComposeFile
+	Warnings map[string][]string // 以服务名为键
+}
+
+func (p ComposeProject) String() string {
+	return composeFileWithWarnings(p.File, p.Warnings)
+}
+
+type composeReplica struct {
+	name   string
+	number int
+	info   container.InspectResponse
+}
+
+// ComposeProjects 按 com.docker.compose.project/service 标签分组还原项目，并返回不属于任何项目的容器。
+func (rr *ReverseResult) ComposeProjects() ([]ComposeProject, []string) {
+	grouped := map[string]map[string][]composeReplica{}
+	var standalone []string
+	for _, name := range sortedComposeServiceNames(rr.ComposeMap) {
+		info := rr.sources[name]
+		var labels map[string]string
+		if info.Config != nil {
+			labels = info.Config.Labels
+		}
+		project, service := labels[composeProjectLabel], labels[composeServiceLabel]
+		if project == "" || service == "" {
+			standalone = append(standalone, name)
+			continue
+		}
+		if grouped[project] == nil {
+			grouped[project] = map[string][]composeReplica{}
+		}
+		number, _ := strconv.Atoi(labels[composeNumberLabel])
+		grouped[project][service] = append(grouped[project][service], composeReplica{name: name, number: number, info: info})
+	}
+
+	projectNames := make([]string, 0, len(grouped))
+	for project := range grouped {
+		projectNames = append(projectNames, project)
+	}
+	sort.Strings(projectNames)
+	projects := make([]ComposeProject, 0, len(projectNames))
+	for _, project := range projectNames {
+		projects = append(projects, newComposeProjectBuilder(rr, project, grouped[project]).build())
+	}
+	return projects, standalone
+}
+
+func sortedComposeServiceNames(services map[string]ComposeService) []string {
+	names := make([]string, 0, len(services))
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+type composeProjectBuilder struct {
+	rr         *ReverseResult
+	project    string
+	replicas   map[string][]composeReplica
+	containers map[string]string // 容器名 -> 服务名
+	volumes    map[string]string // compose 键 -> 实际卷名
+	networks   map[string]string // compose 键 -> 实际网络名
+}
+
+func newComposeProjectBuilder(rr *ReverseResult, project string, replicas map[string][]composeReplica) *composeProjectBuilder {
+	b := &composeProjectBuilder{
+		rr:         rr,
+		project:    project,
+		replicas:   replicas,
+		containers: map[string]string{},
+		volumes:    map[string]string{},
+		networks:   map[string]string{},
+	}
+	for service, items := range replicas {
+		sort.Slice(items, func(i, j int) bool {
+			if items[i].number != items[j].number {
+				return items[i].number < items[j].number
+			}
+			return items[i].name < items[j].name
+		})
+		for _, item := range items {
+			b.containers[item.name] = service
+		}
+	}
+	return b
+}
+
+func (b *composeProjectBuilder) build() ComposeProject {
+	project := ComposeProject{
+		Name:     b.project,
+		File:     ComposeFile{Name: b.project, Services: map[string]ComposeService{}},
+		Warnings: map[string][]string{},
+	}
+	for _, service := range sortedReplicaServiceNames(b.replicas) {
+		svc, warnings := b.service(service)
+		project.File.Services[service] = svc
+		if len(warnings) > 0 {
+			project.Warnings[service] = warnings
+		}
+	}
+	b.inferDependsOn(project.File.Services)
+	project.File.Volumes, project.File.Networks = b.topLevelMeta(project.File.Services)
+	return project
+}
+
+func sortedReplicaServiceNames(replicas map[string][]composeReplica) []string {
+	names := make([]string, 0, len(replicas))
+	for name := range replicas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// service 以编号最小的副本为模板还原服务，并把容器级名称改写为项目内的服务、卷和网络键。
+func (b *composeProjectBuilder) service(service string) (ComposeService, []string) {
+	replicas := b.replicas[service]
+	first := replicas[0].name
+	svc := b.rr.ComposeMap[first]
+	warnings := copyWarnings(b.rr.ComposeWarnings[first])
+
+	svc.Labels = withoutComposeLabels(svc.Labels)
+	if len(replicas) > 1 || svc.ContainerName == b.project+"-"+service+"-"+strconv.Itoa(replicas[0].number) ||
+		svc.ContainerName == b.project+"_"+service+"_"+strconv.Itoa(replicas[0].number) {
+		svc.ContainerName = ""
+	}
+	svc.Volumes = b.serviceVolumes(svc.Volumes)
+	svc.VolumesFrom = b.serviceVolumesFrom(svc.VolumesFrom)
+	svc.Links, svc.ServiceLinks = b.serviceLinks(svc.Links)
+	svc.NetworkMode, svc.Networks = b.serviceNetworks(service, svc.NetworkMode, svc.Networks)
+
+	if count := len(replicas); count > 1 {
+		deploy := ComposeDeploy{}
+		if svc.Deploy != nil {
+			deploy = *svc.Deploy
+		}
+		deploy.Replicas = &count
+		svc.Deploy = &deploy
+		for _, port := range svc.Ports {
+			if composeFixedHostPort(port) != "" {
+				warnings = append(warnings, fmt.Sprintf("副本数 %d 与固定宿主机端口 %s 冲突，需改为端口范围或去掉宿主机端口", count, port))
+			}
+		}
+		for _, replica := range replicas[1:] {
+			if fields := replicaDivergence(b.rr.ComposeMap[first], b.rr.ComposeMap[replica.name]); len(fields) > 0 {
+				warnings = append(warnings, fmt.Sprintf("副本 %s 的 %s 与 %s 不一致，合并为 deploy.replicas 后按 %s 的配置输出", replica.name, strings.Join(fields, "、"), first, first))
+			}
+		}
+	}
+	return svc, warnings
+}
+
+// composeFixedHostPort 返回端口映射中固定的宿主机端口；"IP::80" 或宿主机端口为 0 时由 Docker 随机分配，返回空。
+func composeFixedHostPort(port string) string {
+	mapping, _, _ := strings.Cut(port, "/")
+	parts := strings.Split(mapping, ":")
+	if len(parts) < 2 {
+		return ""
+	}
+	// IPv6 宿主机地址本身含冒号，宿主机端口总是倒数第二段
+	if host := parts[len(parts)-2]; host != "0" {
+		return host
+	}
+	return ""
+}
+
+// replicaDivergence 返回副本间不一致的镜像、环境变量和挂载；匿名卷每个副本各不相同，只比较挂载点。
+func replicaDivergence(first, replica ComposeService) []string {
+	var fields []string
+	if first.Image != replica.Image {
+		fields = append(fields, "image")
+	}
+	if !sameStringSet(first.Environment, replica.Environment) {
+		fields = append(fields, "environment")
+	}
+	if !sameStringSet(replicaVolumes(first.Volumes), replicaVolumes(replica.Volumes)) {
+		fields = append(fields, "volumes")
+	}
+	return fields
+}
+
+func replicaVolumes(volumes []string) []string {
+	result := make([]string, 0, len(volumes))
+	for _, v := range volumes {
+		if source, rest, ok := strings.Cut(v, ":"); ok && runconfig.IsAnonymousVolumeName(source) {
+			v = rest
+		}
+		result = append(result, v)
+	}
+	return result
+}
+
+func sameStringSet(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a = append([]string(nil), a...)
+	b = append([]string(nil), b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func copyWarnings(warnings []string) []string {
+	if len(warnings) == 0 {
+		return nil
+	}
+	return append([]string(nil), warnings...)
+}
+
+func withoutComposeLabels(labels map[string]string) map[string]string {
+	result := map[string]string{}
+	for key, value := range labels {
+		if !strings.HasPrefix(key, composeLabelPrefix) {
+			result[key] = value
+		}
+	}
+	if len(result) == 0 {
+		return nil
+	}
+	return result
+}
+
+func (b *composeProjectBuilder) serviceVolumes(volumes []string) []string {
+	var result []string
+	for _, v := range volumes {
+		source, rest, ok := strings.Cut(v, ":")
+		if !ok || strings.ContainsAny(source, "/\\") || strings.HasPrefix(source, ".") || strings.HasPrefix(source, "~") {
+			result = append(result, v)
+			continue
+		}
+		if runconfig.IsAnonymousVolumeName(source) {
+			// 匿名卷由 compose 重新创建
+			result = append(result, rest)
+			continue
+		}
+		result = append(result, b.volumeKey(source)+":"+rest)
+	}
+	return result
+}
+
+func (b *composeProjectBuilder) serviceVolumesFrom(volumesFrom []string) []string {
+	var result []string
+	for _, source := range volumesFrom {
+		if service, ok := b.containers[strings.TrimPrefix(source, "container:")]; ok {
+			source = service
+		}
+		result = append(result, source)
+	}
+	return result
+}
+
+// serviceLinks 将指向同项目容器的 external_links 改写为 links。
+func (b *composeProjectBuilder) serviceLinks(links []string) ([]string, []string) {
+	var external, internal []string
+	seen := map[string]bool{}
+	for _, link := range links {
+		name, alias, _ := strings.Cut(link, ":")
+		service, ok := b.containers[name]
+		if !ok {
+			external = append(external, link)
+			continue
+		}
+		// compose 会额外以容器名建立链接，这些别名不需要写回
+		entry := service
+		if _, implicit := b.containers[alias]; alias != "" && alias != service && !implicit {
+			entry = service + ":" + alias
+		}
+		if !seen[entry] {
+			seen[entry] = true
+			internal = append(internal, entry)
+		}
+	}
+	return external, internal
+}
+
+func (b *composeProjectBuilder) serviceNetworks(service, mode string, networks map[string]ComposeServiceNetwork) (string, map[string]ComposeServiceNetwork) {
+	switch {
+	case strings.HasPrefix(mode, "container:"):
+		if target, ok := b.containers[strings.TrimPrefix(mode, "container:")]; ok {
+			return "service:" + target, nil
+		}
+		return mode, nil
+	case mode != "" && mode != "default" && mode != "bridge" && mode != "host" && mode != "none":
+		networks = map[string]ComposeServiceNetwork{mode: {}}
+		mode = ""
+	}
+	if len(networks) == 0 {
+		return mode, nil
+	}
+
+	// compose 自动为服务名和容器名添加别名
+	implicit := map[string]bool{service: true}
+	for _, replica := range b.replicas[service] {
+		implicit[replica.name] = true
+	}
+	result := make(map[string]ComposeServiceNetwork, len(networks))
+	for name, cfg := range networks {
+		var aliases []string
+		for _, alias := range cfg.Aliases {
+			if !implicit[alias] {
+				aliases = append(aliases, alias)
+			}
+		}
+		cfg.Aliases = aliases
+		result[b.networkKey(name)] = cfg
+	}
+	if cfg, ok := result["default"]; ok && len(result) == 1 && isEmptyComposeServiceNetwork(cfg) {
+		return "", nil
+	}
+	return mode, result
+}
+
+func isEmptyComposeServiceNetwork(cfg ComposeServiceNetwork) bool {
+	return len(cfg.Aliases) == 0 && cfg.IPv4Address == "" && cfg.IPv6Address == "" &&
+		len(cfg.LinkLocalIPs) == 0 && len(cfg.DriverOpts) == 0 && cfg.GwPriority == 0
+}
+
+func (b *composeProjectBuilder) volumeKey(name string) string {
+	key := b.resourceKey(name, b.rr.VolumeMeta[name].Labels, composeVolumeLabel)
+	b.volumes[key] = name
+	return key
+}
+
+func (b *composeProjectBuilder) networkKey(name string) string {
+	key := b.resourceKey(name, b.rr.NetworkMeta[name].Labels, composeNetworkLabel)
+	b.networks[key] = name
+	return key
+}
+
+// resourceKey 优先使用 compose 标签记录的键，缺少元数据时按 "<project>_" 前缀推断。
+func (b *composeProjectBuilder) resourceKey(name string, labels map[string]string, keyLabel string) string {
+	if labels[composeProjectLabel] == b.project && labels[keyLabel] != "" {
+		return labels[keyLabel]
+	}
+	if key, ok := strings.CutPrefix(name, b.project+"_"); ok && labels[composeProjectLabel] == "" {
+		return key
+	}
+	return name
+}
+
+func (b *composeProjectBuilder) ownsResource(key, name string, labels map[string]string) bool {
+	if project := labels[composeProjectLabel]; project != "" {
+		return project == b.project
+	}
+	return name == b.project+"_"+key
+}
+
+func (b *composeProjectBuilder) topLevelMeta(services map[string]ComposeService) (map[string]interface{}, map[string]interface{}) {
+	volumes, networks := map[string]interface{}{}, map[string]interface{}{}
+	for _, svc := range services {
+		for _, v := range svc.Volumes {
+			if key, _, ok := strings.Cut(v, ":"); ok {
+				if name, known := b.volumes[key]; known {
+					volumes[key] = b.resourceDefinition(key, name, b.rr.VolumeMeta[name].Labels, b.rr.composeVolumeDefinition(name))
+				}
+			}
+		}
+		for key := range svc.Networks {
+			if name, known := b.networks[key]; known {
+				networks[key] = b.resourceDefinition(key, name, b.rr.NetworkMeta[name].Labels, b.rr.composeNetworkDefinition(name))
+			}
+		}
+	}
+	if len(volumes) == 0 {
+		volumes = nil
+	}
+	if len(networks) == 0 {
+		networks = nil
+	}
+	return volumes, networks
+}
+
+// resourceDefinition 去掉 compose 管理标签；不属于本项目的卷和网络声明为 external 以复用现有资源。
+func (b *composeProjectBuilder) resourceDefinition(key, name string, labels map[string]string, def map[string]interface{}) map[string]interface{} {
+	if !b.ownsResource(key, name, labels) {
+		return map[string]interface{}{"external": true, "name": name}
+	}
+	if userLabels := withoutComposeLabels(labels); len(userLabels) > 0 {
+		def["labels"] = userLabels
+	} else {
+		delete(def, "labels")
+	}
+	if name != b.project+"_"+key {
+		def["name"] = name
+	}
+	return def
+}
+
+// -------------------- depends_on 推断 --------------------
+
+// inferDependsOn 依次采用 compose 记录的依赖、links 和共享网络上的环境变量引用推断 depends_on，跳过会形成环的边。
+func (b *composeProjectBuilder) inferDependsOn(services map[string]ComposeService) {
+	deps := map[string]map[string]ComposeDependsOn{}
+	add := func(from, to string, dep ComposeDependsOn) {
+		if _, ok := services[to]; !ok || from == to {
+			return
+		}
+		if _, ok := deps[from][to]; ok || dependsOnReaches(deps, to, from) {
+			return
+		}
+		if deps[from] == nil {
+			deps[from] = map[string]ComposeDependsOn{}
+		}
+		deps[from][to] = dep
+	}
+	started := ComposeDependsOn{Condition: "service_started"}
+
+	names := sortedComposeServiceNames(services)
+	for _, service := range names {
+		info := b.replicas[service][0].info
+		if info.Config == nil {
+			continue
+		}
+		for _, item := range strings.Split(info.Config.Labels[composeDependsOnLabel], ",") {
+			parts := strings.Split(item, ":")
+			dep := started
+			if len(parts) > 1 && parts[1] != "" {
+				dep.Condition = parts[1]
+			}
+			if len(parts) > 2 {
+				dep.Restart = parts[2] == "true"
+			}
+			add(service, parts[0], dep)
+		}
+	}
+	for _, service := range names {
+		for _, link := range services[service].ServiceLinks {
+			target, _, _ := strings.Cut(link, ":")
+			add(service, target, started)
+		}
+	}
+
+	hosts := b.serviceHostnames(services)
+	for _, service := range names {
+		svc := services[service]
+		for _, env := range svc.Environment {
+			_, value, _ := strings.Cut(env, "=")
+			for _, token := range hostnameTokens(value) {
+				if target, ok := hosts[token]; ok && sharesComposeNetwork(svc, services[target]) {
+					add(service, target, started)
+				}
+			}
+		}
+	}
+
+	for service, dependsOn := range deps {
+		svc := services[service]
+		svc.DependsOn = dependsOn
+		services[service] = svc
+	}
+}
+
+func dependsOnReaches(deps map[string]map[string]ComposeDependsOn, from, to string) bool {
+	seen := map[string]bool{}
+	stack := []string{from}
+	for len(stack) > 0 {
+		current := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if current == to {
+			return true
+		}
+		if seen[current] {
+			continue
+		}
+		seen[current] = true
+		for next := range deps[current] {
+			stack = append(stack, next)
+		}
+	}
+	return false
+}
+
+// serviceHostnames 收集可在网络内解析到各服务的名称：服务名、网络别名和容器名。
+func (b *composeProjectBuilder) serviceHostnames(services map[string]ComposeService) map[string]string {
+	hosts := map[string]string{}
+	for _, service := range sortedComposeServiceNames(services) {
+		names := []string{service}
+		for _, replica := range b.replicas[service] {
+			names = append(names, replica.name)
+		}
+		for _, key := range sortedNetworkKeys(services[service].Networks) {
+			names = append(names, services[service].Networks[key].Aliases...)
+		}
+		for _, name := range names {
+			if _, ok := hosts[name]; !ok {
+				hosts[name] = service
+			}
+		}
+	}
+	return hosts
+}
+
+func sortedNetworkKeys(networks map[string]ComposeServiceNetwork) []string {
+	keys := make([]string, 0, len(networks))
+	for key := range networks {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func hostnameTokens(value string) []string {
+	return strings.FieldsFunc(value, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '_' && r != '.'
+	})
+}
+
+func sharesComposeNetwork(a, b ComposeService) bool {
+	left := composeServiceNetworkNames(a)
+	for name := range composeServiceNetworkNames(b) {
+		if left[name] {
+			return true
+		}
+	}
+	return false
+}
+
+func composeServiceNetworkNames(svc ComposeService) map[string]bool {
+	names := map[string]bool{}
+	for key := range svc.Networks {
+		names[key] = true
+	}
+	switch {
+	case len(names) > 0:
+	case svc.NetworkMode == "":
+		names["default"] = true
+	case svc.NetworkMode != "none" && !strings.HasPrefix(svc.NetworkMode, "service:"):
+		names[svc.NetworkMode] = true
+	}
+	return names
+}
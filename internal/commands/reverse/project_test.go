@@ -0,0 +1,178 @@
+package reverse
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/moby/moby/api/types/container"
+	"github.com/moby/moby/api/types/mount"
+	"github.com/moby/moby/api/types/network"
+	"github.com/moby/moby/api/types/volume"
+)
+
+func composeProjectInspect(name, service, number string, labels map[string]string, env []string, networks ...string) container.InspectResponse {
+	allLabels := map[string]string{
+		composeProjectLabel:          "shop",
+		composeServiceLabel:          service,
+		composeNumberLabel:           number,
+		"com.docker.compose.version": "2.29.0",
+	}
+	for key, value := range labels {
+		allLabels[key] = value
+	}
+	endpoints := map[string]*network.EndpointSettings{}
+	for _, net := range networks {
+		endpoints[net] = &network.EndpointSettings{Aliases: []string{name, service}}
+	}
+	return container.InspectResponse{
+		ID:   name + "-0123456789abcdef",
+		Name: "/" + name,
+		HostConfig: &container.HostConfig{
+			NetworkMode:   container.NetworkMode(networks[0]),
+			RestartPolicy: container.RestartPolicy{Name: "always"},
+		},
+		Config:          &container.Config{Image: service + ":1", Labels: allLabels, Env: env},
+		NetworkSettings: &container.NetworkSettings{Networks: endpoints},
+	}
+}
+
+func projectResult(infos ...container.InspectResponse) *ReverseResult {
+	opts := ReverseOptions{ReverseType: ReverseCompose, Project: true, PreserveVolumes: true, FilterDefaultEnvs: true}
+	results := make([]ParsedResult, 0, len(infos))
+	for _, info := range infos {
+		results = append(results, NewParser(info, opts).ToResult())
+	}
+	result := NewReverseResult(results, opts)
+	for _, info := range infos {
+		result.sources[strings.TrimPrefix(info.Name, "/")] = info
+	}
+	return result
+}
+
+func TestComposeProjectsRestoresServicesDependenciesAndReplicas(t *testing.T) {
+	db := composeProjectInspect("shop-db-1", "db", "1", nil, nil, "shop_default")
+	db.Mounts = []container.MountPoint{{Type: mount.TypeVolume, Name: "shop_pgdata", Destination: "/var/lib/postgresql/data"}}
+	dependsOn := map[string]string{composeDependsOnLabel: "db:service_healthy:false"}
+	web1 := composeProjectInspect("shop-web-1", "web", "1", dependsOn, []string{"DB_URL=postgres://db:5432/app"}, "shop_default")
+	web2 := composeProjectInspect("shop-web-2", "web", "2", dependsOn, []string{"DB_URL=postgres://db:5432/app"}, "shop_default")
+	worker := composeProjectInspect("shop-worker-1", "worker", "1", nil, []string{"API=http://web:80"}, "shop_default", "shared")
+	solo := container.InspectResponse{ID: "abcdef0123456789", Name: "/solo", HostConfig: &container.HostConfig{}, Config: &container.Config{Image: "busybox"}}
+
+	result := projectResult(db, web1, web2, worker, solo)
+	result.VolumeMeta["shop_pgdata"] = volume.Volume{
+		Name:   "shop_pgdata",
+		Driver: "local",
+		Labels: map[string]string{composeProjectLabel: "shop", composeVolumeLabel: "pgdata"},
+	}
+
+	projects, standalone := result.ComposeProjects()
+	if len(projects) != 1 || projects[0].Name != "shop" {
+		t.Fatalf("unexpected projects: %+v", projects)
+	}
+	if len(standalone) != 1 || standalone[0] != "solo" {
+		t.Fatalf("unexpected standalone containers: %v", standalone)
+	}
+
+	services := projects[0].File.Services
+	if len(services) != 3 {
+		t.Fatalf("expected db/web/worker services, got %v", sortedComposeServiceNames(services))
+	}
+	web := services["web"]
+	if web.ContainerName != "" || web.Labels != nil {
+		t.Fatalf("compose managed name and labels should be dropped: %+v", web)
+	}
+	if web.Deploy == nil || web.Deploy.Replicas == nil || *web.Deploy.Replicas != 2 {
+		t.Fatalf("web replicas should collapse into deploy.replicas=2: %+v", web.Deploy)
+	}
+	if dep, ok := web.DependsOn["db"]; !ok || dep.Condition != "service_healthy" {
+		t.Fatalf("web should depend on healthy db: %+v", web.DependsOn)
+	}
+	if web.NetworkMode != "" || web.Networks != nil {
+		t.Fatalf("default project network should be implicit: %q %+v", web.NetworkMode, web.Networks)
+	}
+	if _, ok := services["worker"].DependsOn["web"]; !ok {
+		t.Fatalf("worker should depend on web via env reference: %+v", services["worker"].DependsOn)
+	}
+	if got := strings.Join(services["db"].Volumes, ","); got != "pgdata:/var/lib/postgresql/data" {
+		t.Fatalf("project volume should use compose key, got %s", got)
+	}
+
+	got := projects[0].String()
+	for _, want := range []string{"name: shop", "replicas: 2", "pgdata:\n        driver: local", "shared:\n        external: true\n        name: shared"} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("compose output missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "com.docker.compose") {
+		t.Fatalf("compose output should not contain compose labels:\n%s", got)
+	}
+}
+
+func TestComposeProjectsConvertsLinksAndSkipsDependencyCycles(t *testing.T) {
+	api := composeProjectInspect("shop-api-1", "api", "1", nil, []string{"CACHE=cache:6379"}, "shop_default")
+	api.HostConfig.Links = []string{"/shop-cache-1:/shop-api-1/cache", "/shop-cache-1:/shop-api-1/shop-cache-1"}
+	cache := composeProjectInspect("shop-cache-1", "cache", "1", nil, []string{"NOTIFY=http://api:8080"}, "shop_default")
+
+	projects, _ := projectResult(api, cache).ComposeProjects()
+	services := projects[0].File.Services
+	if got := strings.Join(services["api"].ServiceLinks, ","); got != "cache" {
+		t.Fatalf("container links should become service links, got %q", got)
+	}
+	if services["api"].Links != nil {
+		t.Fatalf("project links should not stay in external_links: %v", services["api"].Links)
+	}
+	if _, ok := services["api"].DependsOn["cache"]; !ok {
+		t.Fatalf("api should depend on linked cache: %+v", services["api"].DependsOn)
+	}
+	if len(services["cache"].DependsOn) != 0 {
+		t.Fatalf("env reference back to api would form a cycle: %+v", services["cache"].DependsOn)
+	}
+}
+
+func TestComposeProjectsWarnsWhenReplicasDiverge(t *testing.T) {
+	web1 := composeProjectInspect("shop-web-1", "web", "1", nil, []string{"MODE=primary"}, "shop_default")
+	web2 := composeProjectInspect("shop-web-2", "web", "2", nil, []string{"MODE=replica"}, "shop_default")
+	web2.Config.Image = "web:2"
+	api1 := composeProjectInspect("shop-api-1", "api", "1", nil, []string{"MODE=api"}, "shop_default")
+	api2 := composeProjectInspect("shop-api-2", "api", "2", nil, []string{"MODE=api"}, "shop_default")
+	for i, info := range []*container.InspectResponse{&api1, &api2} {
+		info.Mounts = []container.MountPoint{{Type: mount.TypeVolume, Name: strings.Repeat(string(rune('a'+i)), 64), Destination: "/tmp/cache"}}
+	}
+
+	result := projectResult(web1, web2, api1, api2)
+	for name, port := range map[string]string{"shop-web-1": "8080:80/tcp", "shop-api-1": "127.0.0.1::80/tcp", "shop-api-2": "127.0.0.1::80/tcp"} {
+		svc := result.ComposeMap[name]
+		svc.Ports = []string{port}
+		result.ComposeMap[name] = svc
+	}
+	projects, _ := result.ComposeProjects()
+	warnings := projects[0].Warnings
+	got := strings.Join(warnings["web"], "\n")
+	for _, want := range []string{"副本数 2 与固定宿主机端口 8080:80/tcp 冲突", "副本 shop-web-2 的 image、environment 与 shop-web-1 不一致"} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("web warnings missing %q, got:\n%s", want, got)
+		}
+	}
+	if len(warnings["api"]) != 0 {
+		t.Fatalf("identical replicas with anonymous volumes and random host ports should not warn: %v", warnings["api"])
+	}
+}
+
+func TestComposeFixedHostPort(t *testing.T) {
+	tests := []struct {
+		port string
+		want string
+	}{
+		{port: "80/tcp", want: ""},
+		{port: "8080:80/tcp", want: "8080"},
+		{port: "127.0.0.1:8080:80/tcp", want: "8080"},
+		{port: "127.0.0.1::80/tcp", want: ""},
+		{port: "127.0.0.1:0:80/tcp", want: ""},
+		{port: "::1:8443:443/tcp", want: "8443"},
+	}
+	for _, tt := range tests {
+		if got := composeFixedHostPort(tt.port); got != tt.want {
+			t.Fatalf("composeFixedHostPort(%q) = %q, want %q", tt.port, got, tt.want)
+		}
+	}
+}
@@ -11,9 +11,9 @@ import (
 	"docker-manager/internal/docker"
 	"docker-manager/internal/parallel"
 
+	"github.com/moby/moby/api/types/container"
 	"github.com/moby/moby/api/types/network"
 	"github.com/moby/moby/api/types/volume"
-	"gopkg.in/yaml.v3"
 )
 
 const inspectBackupRoot = "docker-inspect-backups"
@@ -39,13 +39,22 @@ func ensureContainerManager() error {
 }
 
 type ReverseResult struct {
-	ParsedResults  []ParsedResult
-	RunCommands    map[string][]string
-	ComposeMap     map[string]ComposeService
-	VolumeMeta     map[string]volume.Volume
-	NetworkMeta    map[string]network.Inspect
-	DockerEndpoint string
-	options        ReverseOptions
+	ParsedResults   []ParsedResult
+	RunCommands     map[string][]string
+	ComposeMap      map[string]ComposeService
+	RunWarnings     map[string][]string
+	ComposeWarnings map[string][]string
+	K8sManifests    map[string][]K8sObject
+	K8sWarnings     map[string][]string
+	QuadletUnits    map[string]QuadletUnit
+	QuadletWarnings map[string][]string
+	SystemdUnits    map[string]string
+	SystemdWarnings map[string][]string
+	VolumeMeta      map[string]volume.Volume
+	NetworkMeta     map[string]network.Inspect
+	DockerEndpoint  string
+	options         ReverseOptions
+	sources         map[string]container.InspectResponse // 源容器 inspect，供 --verify 对比
 }
 
 func NewReverseResult(results []ParsedResult, options ReverseOptions) *ReverseResult {
@@ -56,17 +65,46 @@ func NewReverseResult(results []ParsedResult, options ReverseOptions) *ReverseRe
 	}
 	rr.RunCommands = make(map[string][]string)
 	rr.ComposeMap = make(map[string]ComposeService)
+	rr.RunWarnings = make(map[string][]string)
+	rr.ComposeWarnings = make(map[string][]string)
+	rr.K8sManifests = make(map[string][]K8sObject)
+	rr.K8sWarnings = make(map[string][]string)
+	rr.QuadletUnits = make(map[string]QuadletUnit)
+	rr.QuadletWarnings = make(map[string][]string)
+	rr.SystemdUnits = make(map[string]string)
+	rr.SystemdWarnings = make(map[string][]string)
 	rr.VolumeMeta = make(map[string]volume.Volume)
 	rr.NetworkMeta = make(map[string]network.Inspect)
+	rr.sources = make(map[string]container.InspectResponse)
 
 	for _, r := range results {
 		rr.RunCommands[r.Name] = r.Command
 		rr.ComposeMap[r.Name] = r.Compose
+		if len(r.CommandWarnings) > 0 {
+			rr.RunWarnings[r.Name] = r.CommandWarnings
+		}
+		if len(r.ComposeWarnings) > 0 {
+			rr.ComposeWarnings[r.Name] = r.ComposeWarnings
+		}
+		rr.K8sManifests[r.Name] = r.K8s
+		if len(r.K8sWarnings) > 0 {
+			rr.K8sWarnings[r.Name] = r.K8sWarnings
+		}
+		rr.QuadletUnits[r.Name] = r.Quadlet
+		if len(r.QuadletWarnings) > 0 {
+			rr.QuadletWarnings[r.Name] = r.QuadletWarnings
+		}
+		rr.SystemdUnits[r.Name] = r.Systemd
+		if len(r.SystemdWarnings) > 0 {
+			rr.SystemdWarnings[r.Name] = r.SystemdWarnings
+		}
 	}
+	rr.checkK8sNames()
+	rr.shareK8sClaims()
 	return rr
 }
 
-func (rr *ReverseResult) Print(w io.Writer) {
+func (rr *ReverseResult) Print(w io.Writer) error {
 	if w == nil {
 		w = io.Discard
 	}
@@ -82,8 +120,49 @@ func (rr *ReverseResult) Print(w io.Writer) {
 	}
 
 	if rr.options.ReverseType == ReverseCompose || rr.options.ReverseType == ReverseAll {
-		fmt.Fprintln(w, rr.DockerComposeFileString())
+		if rr.options.Project {
+			rr.printComposeProjects(w)
+		} else {
+			fmt.Fprintln(w, rr.DockerComposeFileString())
+		}
+	}
+
+	if rr.options.ReverseType == ReverseK8s {
+		manifest, err := rr.KubernetesManifestString()
+		if err != nil {
+			return err
+		}
+		fmt.Fprint(w, manifest)
+	}
+
+	if rr.options.ReverseType == ReverseQuadlet || rr.options.ReverseType == ReverseSystemd {
+		rr.printUnitFiles(w)
+	}
+	return nil
+}
+
+func (rr *ReverseResult) printComposeProjects(w io.Writer) {
+	projects, standalone := rr.ComposeProjects()
+	for _, project := range projects {
+		fmt.Fprintf(w, "# project: %s\n", project.Name)
+		fmt.Fprintln(w, project.String())
+	}
+	if len(standalone) > 0 {
+		fmt.Fprintln(w, "# 未属于 compose 项目的容器")
+		fmt.Fprintln(w, rr.standaloneComposeFileString(standalone))
+	}
+}
+
+func (rr *ReverseResult) standaloneComposeFileString(names []string) string {
+	services := make(map[string]ComposeService, len(names))
+	warnings := map[string][]string{}
+	for _, name := range names {
+		services[name] = rr.ComposeMap[name]
+		if len(rr.ComposeWarnings[name]) > 0 {
+			warnings[name] = rr.ComposeWarnings[name]
+		}
 	}
+	return rr.composeFileString(services, warnings)
 }
 
 func (rr *ReverseResult) DockerRunCommandStringRaw() string {
@@ -97,7 +176,9 @@ func (rr *ReverseResult) DockerRunCommandStringRaw() string {
 			}
 			filtered = append(filtered, c)
 		}
-		sb.WriteString(fmt.Sprintf("# %s\n%s\n\n", name, shellJoin(filtered)))
+		sb.WriteString(fmt.Sprintf("# %s\n", name))
+		writeWarningComments(&sb, "", rr.RunWarnings[name])
+		sb.WriteString(shellJoin(filtered) + "\n\n")
 	}
 	return sb.String()
 }
@@ -106,6 +187,7 @@ func (rr *ReverseResult) DockerRunCommandStringPretty() string {
 	var sb strings.Builder
 	for name, cmd := range rr.RunCommands {
 		sb.WriteString(fmt.Sprintf("# %s\n", name))
+		writeWarningComments(&sb, "", rr.RunWarnings[name])
 		sb.WriteString("docker run \\\n")
 
 		foundSplit := false
@@ -156,6 +238,13 @@ func (rr *ReverseResult) DockerRunCommandStringPretty() string {
 	return sb.String()
 }
 
+// writeWarningComments 以注释形式输出无法复现的配置，避免结果静默偏离原容器。
+func writeWarningComments(sb *strings.Builder, prefix string, warnings []string) {
+	for _, warning := range warnings {
+		sb.WriteString(fmt.Sprintf("# %s不可复现: %s\n", prefix, warning))
+	}
+}
+
 func shellJoin(args []string) string {
 	quoted := make([]string, 0, len(args))
 	for _, arg := range args {
@@ -226,11 +315,38 @@ func (rr *ReverseResult) saveOutput() error {
 	}
 
 	if rr.options.ReverseType == ReverseCompose || rr.options.ReverseType == ReverseAll {
-		vols, nets := rr.buildTopLevelComposeMeta()
-		yml, _ := yaml.Marshal(ComposeFile{Services: rr.ComposeMap, Volumes: vols, Networks: nets})
-		return os.WriteFile("docker-compose.reverse.yml", yml, 0644)
+		if rr.options.Project {
+			return rr.saveComposeProjects()
+		}
+		return os.WriteFile("docker-compose.reverse.yml", []byte(rr.DockerComposeFileString()), 0644)
 	}
 
+	if rr.options.ReverseType == ReverseK8s {
+		manifest, err := rr.KubernetesManifestString()
+		if err != nil {
+			return err
+		}
+		return os.WriteFile("kubernetes.reverse.yaml", []byte(manifest), 0644)
+	}
+
+	if rr.options.ReverseType == ReverseQuadlet || rr.options.ReverseType == ReverseSystemd {
+		return rr.saveUnitFiles()
+	}
+
+	return nil
+}
+
+// saveComposeProjects 每个项目写入 docker-compose.<project>.yml，未属于项目的容器写入 docker-compose.reverse.yml。
+func (rr *ReverseResult) saveComposeProjects() error {
+	projects, standalone := rr.ComposeProjects()
+	for _, project := range projects {
+		if err := os.WriteFile(fmt.Sprintf("docker-compose.%s.yml", project.Name), []byte(project.String()), 0644); err != nil {
+			return err
+		}
+	}
+	if len(standalone) > 0 {
+		return os.WriteFile("docker-compose.reverse.yml", []byte(rr.standaloneComposeFileString(standalone)), 0644)
+	}
 	return nil
 }
 
@@ -262,6 +378,7 @@ func reverseWithOptions(ctx context.Context, names []string, options ReverseOpti
 	results := make([]ParsedResult, 0, len(names))
 	volumeNames := map[string]bool{}
 	networkNames := map[string]bool{}
+	sources := make(map[string]container.InspectResponse, len(names))
 	for i, item := range inspectResults {
 		if item.err != nil {
 			log.Printf("容器 %s 解析失败: %v", names[i], item.err)
@@ -271,10 +388,12 @@ func reverseWithOptions(ctx context.Context, names []string, options ReverseOpti
 			continue
 		}
 		results = append(results, item.parsed)
+		sources[item.parsed.Name] = item.info
 		collectReverseResourceNames(item.info, volumeNames, networkNames)
 	}
 
 	result := NewReverseResult(results, options)
+	result.sources = sources
 	volumeMeta, err := inspectReverseVolumeMetadata(ctx, sortedBoolMapKeys(volumeNames))
 	if err != nil {
 		return nil, err
@@ -10,16 +10,38 @@ import (
 )
 
 func (rr *ReverseResult) DockerComposeFileString() string {
-	vols, nets := rr.buildTopLevelComposeMeta()
-	yml, _ := yaml.Marshal(ComposeFile{Services: rr.ComposeMap, Volumes: vols, Networks: nets})
-	return string(yml)
+	return rr.composeFileString(rr.ComposeMap, rr.ComposeWarnings)
 }
 
-func (rr *ReverseResult) buildTopLevelComposeMeta() (map[string]interface{}, map[string]interface{}) {
+func (rr *ReverseResult) composeFileString(services map[string]ComposeService, warnings map[string][]string) string {
+	vols, nets := rr.buildTopLevelComposeMeta(services)
+	return composeFileWithWarnings(ComposeFile{Services: services, Volumes: vols, Networks: nets}, warnings)
+}
+
+func composeFileWithWarnings(file ComposeFile, warnings map[string][]string) string {
+	yml, _ := yaml.Marshal(file)
+	var sb strings.Builder
+	for _, name := range sortedWarningNames(warnings) {
+		writeWarningComments(&sb, name+" ", warnings[name])
+	}
+	sb.Write(yml)
+	return sb.String()
+}
+
+func sortedWarningNames(warnings map[string][]string) []string {
+	names := make([]string, 0, len(warnings))
+	for name := range warnings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (rr *ReverseResult) buildTopLevelComposeMeta(services map[string]ComposeService) (map[string]interface{}, map[string]interface{}) {
 	volumes := make(map[string]interface{})
 	networks := make(map[string]interface{})
 
-	for _, svc := range rr.ComposeMap {
+	for _, svc := range services {
 		// volumes: look for named volumes like "name:dest" where name has no path separators
 		for _, v := range svc.Volumes {
 			parts := strings.SplitN(v, ":", 2)
@@ -38,6 +60,9 @@ func (rr *ReverseResult) buildTopLevelComposeMeta() (map[string]interface{}, map
 		if nm != "" && nm != "default" && nm != "bridge" && nm != "host" && nm != "none" {
 			networks[nm] = rr.composeNetworkDefinition(nm)
 		}
+		for name := range svc.Networks {
+			networks[name] = rr.composeNetworkDefinition(name)
+		}
 	}
 
 	if len(volumes) == 0 {
@@ -0,0 +1,86 @@
+package reverse
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"docker-manager/internal/runconfig"
+
+	"gopkg.in/yaml.v3"
+)
+
+// KubernetesManifestString 按容器名输出多文档 YAML，无对应字段的配置以注释列在每个容器之前。
+func (rr *ReverseResult) KubernetesManifestString() (string, error) {
+	var sb strings.Builder
+	for _, name := range sortedK8sManifestNames(rr.K8sManifests) {
+		sb.WriteString("# " + name + "\n")
+		writeWarningComments(&sb, "", rr.K8sWarnings[name])
+		for _, object := range rr.K8sManifests[name] {
+			yml, err := yaml.Marshal(object)
+			if err != nil {
+				return "", fmt.Errorf("生成 %s 的 %s 清单失败: %w", name, object.Kind, err)
+			}
+			sb.WriteString("---\n")
+			sb.Write(yml)
+		}
+	}
+	return sb.String(), nil
+}
+
+// checkK8sNames 提示映射为同一 Kubernetes 对象名的容器，这些清单在 kubectl apply 时会互相覆盖。
+func (rr *ReverseResult) checkK8sNames() {
+	owners := map[string][]string{}
+	for _, name := range sortedK8sManifestNames(rr.K8sManifests) {
+		workload := runconfig.K8sWorkloadName(name)
+		owners[workload] = append(owners[workload], name)
+	}
+	for workload, names := range owners {
+		if len(names) < 2 {
+			continue
+		}
+		for _, name := range names {
+			rr.K8sWarnings[name] = append(copyWarnings(rr.K8sWarnings[name]), fmt.Sprintf("对象名 %s 由 %s 共用: 清单会互相覆盖，需手动改名", workload, strings.Join(names, "、")))
+		}
+	}
+}
+
+// shareK8sClaims 让多个容器共用的命名卷只保留一个 PVC，否则多文档清单里同名 PVC 的标签会被 kubectl apply 来回覆盖。
+func (rr *ReverseResult) shareK8sClaims() {
+	users := map[string][]string{}
+	for _, name := range sortedK8sManifestNames(rr.K8sManifests) {
+		for _, object := range rr.K8sManifests[name] {
+			if object.Kind == "PersistentVolumeClaim" {
+				users[object.Metadata.Name] = append(users[object.Metadata.Name], name)
+			}
+		}
+	}
+	for claim, names := range users {
+		if len(names) < 2 {
+			continue
+		}
+		for i, name := range names {
+			var objects []K8sObject
+			for _, object := range rr.K8sManifests[name] {
+				if object.Kind == "PersistentVolumeClaim" && object.Metadata.Name == claim {
+					if i > 0 {
+						continue
+					}
+					object.Metadata.Labels = nil
+				}
+				objects = append(objects, object)
+			}
+			rr.K8sManifests[name] = objects
+			rr.K8sWarnings[name] = append(copyWarnings(rr.K8sWarnings[name]), fmt.Sprintf("PVC %s 由 %s 共用: ReadWriteOnce 只能挂载到单个节点，需改为 ReadWriteMany 或把工作负载调度到同一节点", claim, strings.Join(names, "、")))
+		}
+	}
+}
+
+func sortedK8sManifestNames(manifests map[string][]K8sObject) []string {
+	names := make([]string, 0, len(manifests))
+	for name := range manifests {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
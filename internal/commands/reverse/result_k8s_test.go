@@ -0,0 +1,191 @@
+package reverse
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/moby/moby/api/types/container"
+	"github.com/moby/moby/api/types/mount"
+	"github.com/moby/moby/api/types/network"
+)
+
+func k8sObjectsByKind(objects []K8sObject) map[string]K8sObject {
+	result := map[string]K8sObject{}
+	for _, object := range objects {
+		result[object.Kind] = object
+	}
+	return result
+}
+
+func TestK8sFormatterMapsWorkloadServiceStorageAndEnv(t *testing.T) {
+	info := fullFidelityInspect()
+	info.Config.Env = []string{"DB_PASSWORD=s3cret", "LOG_LEVEL=info", "HOST_TOKEN"}
+	info.Config.User = "1000:1000"
+	info.HostConfig.CapAdd = []string{"CAP_NET_ADMIN"}
+	info.HostConfig.PortBindings = network.PortMap{network.MustParsePort("80/tcp"): {{HostPort: "8080"}}}
+	info.Mounts = []container.MountPoint{
+		{Type: mount.TypeVolume, Name: "api_data", Destination: "/data"},
+		{Type: mount.TypeBind, Source: "/etc/app", Destination: "/etc/app", Mode: "ro"},
+	}
+	result := NewParser(info, ReverseOptions{ReverseType: ReverseK8s, PreserveVolumes: true, FilterDefaultEnvs: true, MergePorts: true}).ToResult()
+	objects := k8sObjectsByKind(result.K8s)
+
+	if got := objects["ConfigMap"].Data; got["LOG_LEVEL"] != "info" || got["DB_PASSWORD"] != "" {
+		t.Fatalf("ConfigMap should only hold non-sensitive env: %+v", got)
+	}
+	if got := objects["Secret"].StringData; got["DB_PASSWORD"] != "s3cret" {
+		t.Fatalf("Secret should hold sensitive env: %+v", got)
+	}
+	pvc, ok := objects["PersistentVolumeClaim"]
+	if !ok || pvc.Metadata.Name != "api-data" {
+		t.Fatalf("named volume should become PVC api-data: %+v", result.K8s)
+	}
+	if _, ok := objects["Deployment"]; ok {
+		t.Fatalf("container with named volume should be a StatefulSet")
+	}
+	workload := objects["StatefulSet"].Spec.(K8sWorkloadSpec)
+	pod := workload.Template.Spec
+	c := pod.Containers[0]
+	if c.Resources.Limits["memory"] != "512Mi" || c.Resources.Limits["cpu"] != "1500m" || c.Resources.Requests["memory"] != "256Mi" {
+		t.Fatalf("unexpected resources: %+v", c.Resources)
+	}
+	if c.LivenessProbe == nil || strings.Join(c.LivenessProbe.Exec.Command, " ") != "/bin/sh -c curl -f http://localhost/health" || c.LivenessProbe.PeriodSeconds != 30 {
+		t.Fatalf("healthcheck should become exec probe: %+v", c.LivenessProbe)
+	}
+	sc := c.SecurityContext
+	if sc == nil || *sc.RunAsUser != 1000 || *sc.RunAsGroup != 1000 || !*sc.ReadOnlyRootFilesystem || strings.Join(sc.Capabilities.Add, ",") != "NET_ADMIN" {
+		t.Fatalf("unexpected securityContext: %+v", sc)
+	}
+	if !pod.HostPID || pod.Hostname != "api-host" || *pod.TerminationGracePeriodSeconds != 30 {
+		t.Fatalf("unexpected pod spec: %+v", pod)
+	}
+	service := objects["Service"].Spec.(K8sServiceSpec)
+	if len(service.Ports) != 1 || service.Ports[0].Port != 8080 || service.Ports[0].TargetPort != 80 {
+		t.Fatalf("published port should become Service port: %+v", service.Ports)
+	}
+
+	warnings := strings.Join(result.K8sWarnings, "\n")
+	for _, want := range []string{"env HOST_TOKEN", "AutoRemove", "hostPath", "group-add audio", "pids-limit", "link db:database"} {
+		if !strings.Contains(warnings, want) {
+			t.Fatalf("warnings missing %q:\n%s", want, warnings)
+		}
+	}
+}
+
+func TestK8sFormatterSharesOnePVCForRepeatedVolumeMounts(t *testing.T) {
+	info := container.InspectResponse{
+		Name:       "/web",
+		HostConfig: &container.HostConfig{},
+		Config:     &container.Config{Image: "nginx"},
+		Mounts: []container.MountPoint{
+			{Type: mount.TypeVolume, Name: "web_data", Destination: "/data"},
+			{Type: mount.TypeVolume, Name: "web_data", Destination: "/var/cache/nginx"},
+		},
+	}
+	result := NewParser(info, ReverseOptions{ReverseType: ReverseK8s, PreserveVolumes: true}).ToResult()
+
+	pvcs := 0
+	var workload K8sWorkloadSpec
+	for _, object := range result.K8s {
+		switch object.Kind {
+		case "PersistentVolumeClaim":
+			pvcs++
+		case "StatefulSet":
+			workload = object.Spec.(K8sWorkloadSpec)
+		}
+	}
+	if pvcs != 1 {
+		t.Fatalf("PVC count = %d, want 1: %+v", pvcs, result.K8s)
+	}
+	pod := workload.Template.Spec
+	if len(pod.Volumes) != 1 || pod.Volumes[0].Name != "web-data" {
+		t.Fatalf("pod volumes = %+v, want single web-data volume", pod.Volumes)
+	}
+	mounts := pod.Containers[0].VolumeMounts
+	if len(mounts) != 2 || mounts[0].Name != "web-data" || mounts[1].Name != "web-data" || mounts[1].MountPath != "/var/cache/nginx" {
+		t.Fatalf("volumeMounts = %+v, want both paths on web-data", mounts)
+	}
+	if got := strings.Count(strings.Join(result.K8sWarnings, "\n"), "卷 web_data"); got != 1 {
+		t.Fatalf("volume warning repeated %d times: %v", got, result.K8sWarnings)
+	}
+}
+
+func TestKubernetesManifestStringPrintsWarningsAndDocuments(t *testing.T) {
+	info := container.InspectResponse{
+		Name:       "/web",
+		HostConfig: &container.HostConfig{RestartPolicy: container.RestartPolicy{Name: "no"}},
+		Config:     &container.Config{Image: "nginx"},
+	}
+	opts := ReverseOptions{ReverseType: ReverseK8s}
+	got, err := NewReverseResult([]ParsedResult{NewParser(info, opts).ToResult()}, opts).KubernetesManifestString()
+	if err != nil {
+		t.Fatalf("KubernetesManifestString() error = %v", err)
+	}
+
+	for _, want := range []string{"# web\n", "# 不可复现: restart=no", "---\napiVersion: apps/v1\nkind: Deployment"} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("manifest missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "kind: Service") {
+		t.Fatalf("container without published ports should not get a Service:\n%s", got)
+	}
+}
+
+func TestKubernetesManifestStringSharesClaimAcrossContainers(t *testing.T) {
+	opts := ReverseOptions{ReverseType: ReverseK8s, PreserveVolumes: true}
+	var results []ParsedResult
+	for _, name := range []string{"/api", "/worker"} {
+		info := container.InspectResponse{
+			Name:       name,
+			HostConfig: &container.HostConfig{},
+			Config:     &container.Config{Image: "demo"},
+			Mounts:     []container.MountPoint{{Type: mount.TypeVolume, Name: "shared_data", Destination: "/data"}},
+		}
+		results = append(results, NewParser(info, opts).ToResult())
+	}
+	result := NewReverseResult(results, opts)
+	got, err := result.KubernetesManifestString()
+	if err != nil {
+		t.Fatalf("KubernetesManifestString() error = %v", err)
+	}
+
+	if n := strings.Count(got, "kind: PersistentVolumeClaim"); n != 1 {
+		t.Fatalf("shared volume should produce one PVC, got %d:\n%s", n, got)
+	}
+	if n := strings.Count(got, "claimName: shared-data"); n != 2 {
+		t.Fatalf("both workloads should mount the shared claim, got %d:\n%s", n, got)
+	}
+	for _, name := range []string{"api", "worker"} {
+		if !strings.Contains(strings.Join(result.K8sWarnings[name], "\n"), "PVC shared-data 由 api、worker 共用") {
+			t.Fatalf("%s should warn about the shared ReadWriteOnce claim: %v", name, result.K8sWarnings[name])
+		}
+	}
+}
+
+func TestK8sNamesStartWithLetterAndReportCollisions(t *testing.T) {
+	opts := ReverseOptions{ReverseType: ReverseK8s}
+	var results []ParsedResult
+	for _, name := range []string{"/1api", "/web_1", "/web.1"} {
+		info := container.InspectResponse{
+			Name:       name,
+			HostConfig: &container.HostConfig{PortBindings: network.PortMap{network.MustParsePort("80/tcp"): {{HostPort: "8080"}}}},
+			Config:     &container.Config{Image: "demo"},
+		}
+		results = append(results, NewParser(info, opts).ToResult())
+	}
+	result := NewReverseResult(results, opts)
+
+	service := k8sObjectsByKind(result.K8sManifests["1api"])["Service"]
+	if service.Metadata.Name != "c-1api" {
+		t.Fatalf("Service name = %q, want DNS-1035 name c-1api", service.Metadata.Name)
+	}
+	if len(result.K8sWarnings["1api"]) != 0 {
+		t.Fatalf("unique name should not warn: %v", result.K8sWarnings["1api"])
+	}
+	for _, name := range []string{"web_1", "web.1"} {
+		if !strings.Contains(strings.Join(result.K8sWarnings[name], "\n"), "对象名 web-1 由 web.1、web_1 共用") {
+			t.Fatalf("%s should warn about the name collision: %v", name, result.K8sWarnings[name])
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package reverse
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"docker-manager/internal/runconfig"
+)
+
+// reverseUnitDir 是 --save 输出 unit 文件的根目录，目录结构与安装路径一致，可直接 cp -r reverse-units/. / 安装。
+const reverseUnitDir = "reverse-units"
+
+const (
+	quadletUnitPath = "etc/containers/systemd"
+	systemdUnitPath = "etc/systemd/system"
+)
+
+type ReverseUnitFile struct {
+	Path    string
+	Content string
+}
+
+// UnitFiles 按安装路径返回 quadlet 或 systemd 输出，多个容器共用的卷和网络只生成一次。
+func (rr *ReverseResult) UnitFiles() []ReverseUnitFile {
+	var files []ReverseUnitFile
+	if rr.options.ReverseType == ReverseSystemd {
+		for _, name := range sortedUnitNames(rr.SystemdUnits) {
+			files = append(files, ReverseUnitFile{
+				Path:    filepath.Join(systemdUnitPath, name+".service"),
+				Content: unitWithWarnings(rr.SystemdUnits[name], rr.SystemdWarnings[name]),
+			})
+		}
+		return files
+	}
+
+	volumes := map[string]bool{}
+	networks := map[string]bool{}
+	for _, name := range sortedUnitNames(rr.QuadletUnits) {
+		unit := rr.QuadletUnits[name]
+		files = append(files, ReverseUnitFile{
+			Path:    filepath.Join(quadletUnitPath, name+".container"),
+			Content: unitWithWarnings(unit.Container, rr.QuadletWarnings[name]),
+		})
+		for _, volume := range unit.Volumes {
+			volumes[volume] = true
+		}
+		for _, network := range unit.Networks {
+			networks[network] = true
+		}
+	}
+	for _, name := range sortedBoolMapKeys(volumes) {
+		files = append(files, ReverseUnitFile{
+			Path:    filepath.Join(quadletUnitPath, name+".volume"),
+			Content: runconfig.QuadletVolumeUnit(name, rr.VolumeMeta[name]),
+		})
+	}
+	for _, name := range sortedBoolMapKeys(networks) {
+		files = append(files, ReverseUnitFile{
+			Path:    filepath.Join(quadletUnitPath, name+".network"),
+			Content: runconfig.QuadletNetworkUnit(name, rr.NetworkMeta[name]),
+		})
+	}
+	return files
+}
+
+func (rr *ReverseResult) printUnitFiles(w io.Writer) {
+	for i, file := range rr.UnitFiles() {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "# %s\n", file.Path)
+		fmt.Fprint(w, file.Content)
+	}
+}
+
+func (rr *ReverseResult) saveUnitFiles() error {
+	for _, file := range rr.UnitFiles() {
+		path := filepath.Join(reverseUnitDir, file.Path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, []byte(file.Content), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func unitWithWarnings(content string, warnings []string) string {
+	var sb strings.Builder
+	writeWarningComments(&sb, "", warnings)
+	sb.WriteString(content)
+	return sb.String()
+}
+
+func sortedUnitNames[T any](units map[string]T) []string {
+	names := make([]string, 0, len(units))
+	for name := range units {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
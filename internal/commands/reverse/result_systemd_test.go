@@ -0,0 +1,122 @@
+package reverse
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/moby/moby/api/types/container"
+	"github.com/moby/moby/api/types/mount"
+	"github.com/moby/moby/api/types/network"
+	"github.com/moby/moby/api/types/volume"
+)
+
+func unitFilesByPath(files []ReverseUnitFile) map[string]string {
+	result := map[string]string{}
+	for _, file := range files {
+		result[file.Path] = file.Content
+	}
+	return result
+}
+
+func TestQuadletUnitFilesReferenceVolumesAndNetworks(t *testing.T) {
+	info := fullFidelityInspect()
+	info.Config.Env = []string{"GREETING=hello world"}
+	info.Config.Cmd = []string{"serve", "--port", "$PORT"}
+	info.HostConfig.RestartPolicy = container.RestartPolicy{Name: "unless-stopped"}
+	info.HostConfig.PortBindings = network.PortMap{network.MustParsePort("80/tcp"): {{HostPort: "8080"}}}
+	info.Mounts = []container.MountPoint{
+		{Type: mount.TypeVolume, Name: "api_data", Destination: "/data"},
+		{Type: mount.TypeBind, Source: "/etc/app", Destination: "/etc/app", Mode: "ro"},
+	}
+	opts := ReverseOptions{ReverseType: ReverseQuadlet, PreserveVolumes: true, FilterDefaultEnvs: true, MergePorts: true}
+	result := NewReverseResult([]ParsedResult{NewParser(info, opts).ToResult()}, opts)
+	result.VolumeMeta["api_data"] = volume.Volume{Name: "api_data", Driver: "local", Options: map[string]string{"type": "nfs", "o": "addr=10.0.0.1", "device": ":/export"}}
+	result.NetworkMeta["app_net"] = network.Inspect{Network: network.Network{Name: "app_net", Driver: "bridge", Internal: true}}
+	files := unitFilesByPath(result.UnitFiles())
+
+	unit := files["etc/containers/systemd/api.container"]
+	for _, want := range []string{
+		"ContainerName=api\n",
+		"Image=demo/api:latest\n",
+		"Exec=serve --port $$PORT\n",
+		`Environment="GREETING=hello world"` + "\n",
+		"PublishPort=8080:80/tcp\n",
+		"Volume=api_data.volume:/data\n",
+		"Volume=/etc/app:/etc/app:ro\n",
+		"Network=app_net.network\n",
+		"NetworkAlias=backend\n",
+		"IP=172.20.0.10\n",
+		"ReadOnly=true\n",
+		"HealthCmd=curl -f http://localhost/health\n",
+		"HealthInterval=30s\n",
+		"Restart=always\n",
+		"WantedBy=default.target\n",
+		"# 不可复现: link db:database: Podman 不支持 --link",
+	} {
+		if !strings.Contains(unit, want) {
+			t.Fatalf("container unit missing %q:\n%s", want, unit)
+		}
+	}
+	if !strings.Contains(unit, "PodmanArgs=") || !strings.Contains(unit, "--pid host") {
+		t.Fatalf("flags without quadlet keys should go to PodmanArgs:\n%s", unit)
+	}
+	if got := files["etc/containers/systemd/api_data.volume"]; !strings.Contains(got, "VolumeName=api_data\n") || !strings.Contains(got, "Type=nfs\n") || !strings.Contains(got, "Device=:/export\n") {
+		t.Fatalf("unexpected volume unit:\n%s", got)
+	}
+	if got := files["etc/containers/systemd/app_net.network"]; !strings.Contains(got, "NetworkName=app_net\n") || !strings.Contains(got, "Internal=true\n") || strings.Contains(got, "Driver=") {
+		t.Fatalf("unexpected network unit:\n%s", got)
+	}
+}
+
+func TestSystemdUnitRunsDockerInForeground(t *testing.T) {
+	info := container.InspectResponse{
+		Name:       "/web",
+		Config:     &container.Config{Image: "nginx:1.27"},
+		HostConfig: &container.HostConfig{NetworkMode: "front", RestartPolicy: container.RestartPolicy{Name: "on-failure", MaximumRetryCount: 5}},
+	}
+	opts := ReverseOptions{ReverseType: ReverseSystemd}
+	result := NewReverseResult([]ParsedResult{NewParser(info, opts).ToResult()}, opts)
+	files := unitFilesByPath(result.UnitFiles())
+
+	unit, ok := files["etc/systemd/system/web.service"]
+	if !ok || len(files) != 1 {
+		t.Fatalf("expected a single web.service unit: %+v", files)
+	}
+	for _, want := range []string{
+		"Requires=docker.service\n",
+		"StartLimitBurst=5\n",
+		"Restart=on-failure\n",
+		"ExecStartPre=-/usr/bin/docker network create front\n",
+		"ExecStartPre=-/usr/bin/docker rm -f web\n",
+		"ExecStart=/usr/bin/docker run --name web --network front nginx:1.27\n",
+		"ExecStop=/usr/bin/docker stop web\n",
+		"WantedBy=multi-user.target\n",
+		"# 不可复现: restart=on-failure:5",
+	} {
+		if !strings.Contains(unit, want) {
+			t.Fatalf("service unit missing %q:\n%s", want, unit)
+		}
+	}
+	if strings.Contains(unit, " -d ") || strings.Contains(unit, "--restart") {
+		t.Fatalf("docker run should stay in foreground without --restart:\n%s", unit)
+	}
+}
+
+func TestSystemdUnitKeepsRunFlagsInsideImageCommand(t *testing.T) {
+	info := container.InspectResponse{
+		Name:       "/worker",
+		Config:     &container.Config{Image: "demo/worker:1", Cmd: []string{"worker", "-d", "--restart", "always", "--restart=never"}},
+		HostConfig: &container.HostConfig{RestartPolicy: container.RestartPolicy{Name: "always"}},
+	}
+	opts := ReverseOptions{ReverseType: ReverseSystemd}
+	result := NewReverseResult([]ParsedResult{NewParser(info, opts).ToResult()}, opts)
+	unit := unitFilesByPath(result.UnitFiles())["etc/systemd/system/worker.service"]
+
+	want := "ExecStart=/usr/bin/docker run --name worker demo/worker:1 worker -d --restart always --restart=never\n"
+	if !strings.Contains(unit, want) {
+		t.Fatalf("service unit missing %q:\n%s", want, unit)
+	}
+	if strings.Contains(unit, CommandSplitMarker) {
+		t.Fatalf("split marker leaked into unit:\n%s", unit)
+	}
+}
@@ -371,8 +371,10 @@ func TestComposeFormatterFormat(t *testing.T) {
 	if service.Image != "busybox:latest" {
 		t.Fatalf("Image = %q, want busybox:latest", service.Image)
 	}
-	if service.Restart != "on-failure" {
-		t.Fatalf("Restart = %q, want on-failure", service.Restart)
+	// The retry count used to be dropped here; reverse output is meant to
+	// reproduce the container, and compose accepts on-failure:N.
+	if service.Restart != "on-failure:3" {
+		t.Fatalf("Restart = %q, want on-failure:3", service.Restart)
 	}
 	if len(service.Ports) != 1 || service.Ports[0] != "8080:80/tcp" {
 		t.Fatalf("Ports = %#v, want 8080:80/tcp", service.Ports)
@@ -421,6 +423,31 @@ func TestComposeFormatterFormat(t *testing.T) {
 	}
 }
 
+func TestComposeFormatterKeepsOnFailureRetryCount(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy container.RestartPolicy
+		want   string
+	}{
+		{name: "unlimited", policy: container.RestartPolicy{Name: "on-failure"}, want: "on-failure"},
+		{name: "retry count", policy: container.RestartPolicy{Name: "on-failure", MaximumRetryCount: 3}, want: "on-failure:3"},
+		{name: "always", policy: container.RestartPolicy{Name: "always"}, want: "always"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := container.InspectResponse{
+				Name:       "/demo",
+				Config:     &container.Config{Image: "busybox:latest"},
+				HostConfig: &container.HostConfig{RestartPolicy: tt.policy},
+			}
+			service := ComposeFormatter{}.Format(NewParser(info, ReverseOptions{}).ToSpec())
+			if service.Restart != tt.want {
+				t.Fatalf("Restart = %q, want %q", service.Restart, tt.want)
+			}
+		})
+	}
+}
+
 func TestDockerComposeFileStringIncludesInspectedVolumeAndNetworkMetadata(t *testing.T) {
 	result := NewReverseResult([]ParsedResult{{
 		Name: "api",
@@ -486,3 +513,165 @@ func TestDockerComposeFileStringIncludesInspectedVolumeAndNetworkMetadata(t *tes
 		}
 	}
 }
+
+func fullFidelityInspect() container.InspectResponse {
+	stopTimeout := 30
+	swappiness := int64(10)
+	pidsLimit := int64(256)
+	enabled := true
+	return container.InspectResponse{
+		ID:   "0123456789abcdef",
+		Name: "/api",
+		HostConfig: &container.HostConfig{
+			NetworkMode:    "app_net",
+			ReadonlyRootfs: true,
+			Init:           &enabled,
+			Runtime:        "runc",
+			PidMode:        "host",
+			IpcMode:        "private",
+			UTSMode:        "host",
+			CgroupnsMode:   "host",
+			Cgroup:         "container:other",
+			GroupAdd:       []string{"audio"},
+			DNSOptions:     []string{"ndots:2"},
+			Tmpfs:          map[string]string{"/run": "size=64m"},
+			Sysctls:        map[string]string{"net.core.somaxconn": "1024"},
+			ShmSize:        256 << 20,
+			OomScoreAdj:    -500,
+			AutoRemove:     true,
+			Links:          []string{"/db:/api/database"},
+			Resources: container.Resources{
+				Memory:            512 << 20,
+				MemoryReservation: 256 << 20,
+				MemorySwap:        -1,
+				MemorySwappiness:  &swappiness,
+				NanoCPUs:          1500000000,
+				CpusetCpus:        "0-1",
+				PidsLimit:         &pidsLimit,
+				DeviceRequests: []container.DeviceRequest{{
+					Driver: "nvidia", Count: -1, Capabilities: [][]string{{"gpu"}},
+				}},
+			},
+		},
+		Config: &container.Config{
+			Image:       "demo/api:latest",
+			Hostname:    "api-host",
+			Tty:         true,
+			OpenStdin:   true,
+			StopSignal:  "SIGINT",
+			StopTimeout: &stopTimeout,
+			ExposedPorts: network.PortSet{
+				network.MustParsePort("9000/tcp"): {},
+			},
+			Healthcheck: &container.HealthConfig{
+				Test:     []string{"CMD-SHELL", "curl -f http://localhost/health"},
+				Interval: 30 * time.Second,
+				Retries:  3,
+			},
+		},
+		NetworkSettings: &container.NetworkSettings{
+			Networks: map[string]*network.EndpointSettings{
+				"app_net": {
+					Aliases:    []string{"api", "0123456789ab", "backend"},
+					IPAMConfig: &network.EndpointIPAMConfig{IPv4Address: netip.MustParseAddr("172.20.0.10")},
+				},
+			},
+		},
+	}
+}
+
+func TestParserToResultCoversHostConfigFields(t *testing.T) {
+	result := NewParser(fullFidelityInspect(), ReverseOptions{}).ToResult()
+
+	run := strings.Join(result.Command, " ")
+	for _, want := range []string{
+		"--network app_net --network-alias backend --ip 172.20.0.10",
+		"--hostname api-host", "--dns-option ndots:2", "-t -i", "--read-only", "--init",
+		"--pid host", "--uts host", "--cgroupns host", "--group-add audio",
+		"--stop-signal SIGINT", "--stop-timeout 30",
+		"--health-cmd curl -f http://localhost/health", "--health-interval 30s", "--health-retries 3",
+		"--memory 512m", "--memory-reservation 256m", "--memory-swap=-1", "--memory-swappiness 10",
+		"--oom-score-adj=-500", "--pids-limit 256", "--shm-size 256m", "--cpus 1.5", "--cpuset-cpus 0-1",
+		"--gpus all", "--sysctl net.core.somaxconn=1024", "--tmpfs /run:size=64m",
+		"--expose 9000/tcp", "--link db:database",
+	} {
+		if !strings.Contains(run, want) {
+			t.Fatalf("Command = %s\nwant %q", run, want)
+		}
+	}
+	for _, unwanted := range []string{"--runtime", "--ipc", "--network-alias api", "0123456789ab"} {
+		if strings.Contains(run, unwanted) {
+			t.Fatalf("Command = %s\nshould not contain %q", run, unwanted)
+		}
+	}
+
+	svc := result.Compose
+	if svc.NetworkMode != "" || strings.Join(svc.Networks["app_net"].Aliases, ",") != "backend" || svc.Networks["app_net"].IPv4Address != "172.20.0.10" {
+		t.Fatalf("Compose networks = %#v network_mode=%q", svc.Networks, svc.NetworkMode)
+	}
+	if svc.Hostname != "api-host" || !svc.ReadOnly || svc.Init == nil || !*svc.Init || svc.Pid != "host" || svc.Cgroup != "host" ||
+		svc.StopGracePeriod != "30s" || svc.MemLimit != "512m" || svc.MemSwapLimit != "-1" || svc.CPUs != 1.5 || svc.ShmSize != "256m" ||
+		strings.Join(svc.Tmpfs, ",") != "/run:size=64m" || strings.Join(svc.Links, ",") != "db:database" || !svc.Tty || !svc.StdinOpen {
+		t.Fatalf("Compose service = %#v", svc)
+	}
+	if svc.Healthcheck == nil || svc.Healthcheck.Interval != "30s" || svc.Healthcheck.Test[0] != "CMD-SHELL" {
+		t.Fatalf("Compose healthcheck = %#v", svc.Healthcheck)
+	}
+	if svc.Deploy == nil || len(svc.Deploy.Resources.Reservations.Devices) != 1 || svc.Deploy.Resources.Reservations.Devices[0].Count != "all" {
+		t.Fatalf("Compose deploy = %#v", svc.Deploy)
+	}
+
+	if len(result.CommandWarnings) != 1 || !strings.Contains(result.CommandWarnings[0], "HostConfig.Cgroup") {
+		t.Fatalf("CommandWarnings = %#v", result.CommandWarnings)
+	}
+	composeWarnings := strings.Join(result.ComposeWarnings, "\n")
+	if !strings.Contains(composeWarnings, "HostConfig.Cgroup") || !strings.Contains(composeWarnings, "AutoRemove") {
+		t.Fatalf("ComposeWarnings = %#v", result.ComposeWarnings)
+	}
+}
+
+func TestCommandFormatterUsesAdvancedNetworkSyntaxForMultipleNetworks(t *testing.T) {
+	spec := &ContainerSpec{
+		Image:         "busybox:latest",
+		ContainerName: "demo",
+		NetworkMode:   "front",
+		Networks: []NetworkEndpointSpec{
+			{Name: "front", Aliases: []string{"web"}},
+			{Name: "back", IPv4Address: "10.0.0.5", DriverOpts: map[string]string{"com.example.opt": "a,b"}},
+		},
+	}
+	got := strings.Join(CommandFormatter{}.Format(spec, ReverseOptions{}), " ")
+	want := `docker run -d --name demo --network name=front,alias=web --network name=back,ip=10.0.0.5,"driver-opt=com.example.opt=a,b" --__SPLIT__ busybox:latest`
+	if got != want {
+		t.Fatalf("CommandFormatter networks = %q, want %q", got, want)
+	}
+	if warnings := (CommandFormatter{}).Warnings(spec); len(warnings) != 0 {
+		t.Fatalf("Warnings = %#v", warnings)
+	}
+
+	spec.NetworkMode = "bridge"
+	spec.Networks = []NetworkEndpointSpec{{Name: "bridge"}, {Name: "back"}}
+	got = strings.Join(CommandFormatter{}.Format(spec, ReverseOptions{}), " ")
+	if !strings.Contains(got, "--network bridge --__SPLIT__") {
+		t.Fatalf("CommandFormatter bridge = %q", got)
+	}
+	if warnings := (CommandFormatter{}).Warnings(spec); len(warnings) != 1 || !strings.Contains(warnings[0], "back") {
+		t.Fatalf("Warnings = %#v", warnings)
+	}
+}
+
+func TestReverseResultPrintsNotReproducibleWarnings(t *testing.T) {
+	result := NewReverseResult([]ParsedResult{NewParser(fullFidelityInspect(), ReverseOptions{}).ToResult()}, ReverseOptions{ReverseType: ReverseAll})
+	result.DockerEndpoint = ""
+	var out bytes.Buffer
+	if err := result.Print(&out); err != nil {
+		t.Fatalf("Print() error = %v", err)
+	}
+	got := out.String()
+	if !strings.Contains(got, "# api\n# 不可复现: HostConfig.Cgroup=container:other") {
+		t.Fatalf("run output =\n%s", got)
+	}
+	if !strings.Contains(got, "# api 不可复现: AutoRemove (--rm): Compose 不支持") || !strings.Contains(got, "app_net:\n") {
+		t.Fatalf("compose output =\n%s", got)
+	}
+}
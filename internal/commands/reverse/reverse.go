@@ -3,11 +3,13 @@ package reverse
 import (
 	"context"
 	"fmt"
+	"io"
 	"sort"
 	"strings"
 
 	"docker-manager/internal/commandflags"
 	"docker-manager/internal/completion"
+	rpt "docker-manager/internal/report"
 	"docker-manager/internal/sensitive"
 	"docker-manager/internal/targets"
 
@@ -27,23 +29,39 @@ func NewReverseCommand() *cobra.Command {
 		redactSecrets   bool
 		redactProfile   string
 		filters         []string
+		verify          bool
+		project         bool
+		format          string
 	)
 
 	cmd := &cobra.Command{
 		Use:   "reverse [container-filter...]",
 		Short: "逆向 Docker 容器到启动命令",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if running && !cmd.Flags().Changed("reverse-type") {
+			if (running || project) && !cmd.Flags().Changed("reverse-type") {
 				reverseType = string(ReverseCompose)
 			}
 
 			// 校验输出类型
 			rt := ReverseType(reverseType)
 			switch rt {
-			case ReverseCmd, ReverseCompose, ReverseAll:
+			case ReverseCmd, ReverseCompose, ReverseAll, ReverseK8s, ReverseQuadlet, ReverseSystemd:
 				// ok
 			default:
-				return fmt.Errorf("无效的输出类型: %s (必须是 cmd | compose | all)", reverseType)
+				return fmt.Errorf("无效的输出类型: %s (必须是 cmd | compose | all | k8s | quadlet | systemd)", reverseType)
+			}
+
+			if !verify && cmd.Flags().Changed("format") {
+				return fmt.Errorf("--format 仅用于 --verify 校验报告")
+			}
+			if verify && save {
+				return fmt.Errorf("--verify 仅做解析校验，不能与 --save 同时使用")
+			}
+			if project && (verify || (rt != ReverseCompose && rt != ReverseAll)) {
+				return fmt.Errorf("--project 仅用于 compose 输出，不能与 --verify 或 --reverse-type cmd|k8s|quadlet|systemd 同时使用")
+			}
+			if verify && (rt == ReverseK8s || rt == ReverseQuadlet || rt == ReverseSystemd) {
+				return fmt.Errorf("--verify 仅支持校验 cmd | compose | all 输出")
 			}
 
 			// 传递选项
@@ -59,7 +77,7 @@ func NewReverseCommand() *cobra.Command {
 				effectiveMergePorts = false
 			}
 			opts := ReverseOptions{
-				PreserveVolumes:   preserveVolumes,
+				PreserveVolumes:   preserveVolumes || project || rt == ReverseK8s || rt == ReverseQuadlet,
 				FilterDefaultEnvs: effectiveFilterDefaultEnvs,
 				PrettyFormat:      prettyFormat,
 				MergePorts:        effectiveMergePorts,
@@ -67,6 +85,7 @@ func NewReverseCommand() *cobra.Command {
 				ReverseType:       rt,
 				RedactSecrets:     redactSecrets,
 				RedactProfile:     redactProfile,
+				Project:           project,
 			}
 
 			targetFilters := append(append([]string(nil), filters...), args...)
@@ -76,7 +95,7 @@ func NewReverseCommand() *cobra.Command {
 				return err
 			}
 
-			if comment := reverseTargetSelectionComment(len(targets), running, targetFilters); comment != "" {
+			if comment := reverseTargetSelectionComment(len(targets), running, targetFilters); comment != "" && (!verify || format == rpt.FormatText) {
 				fmt.Fprintln(cmd.OutOrStdout(), comment)
 			}
 
@@ -85,8 +104,18 @@ func NewReverseCommand() *cobra.Command {
 				return err
 			}
 
+			// 回读生成结果并与源容器对比，不创建任何资源
+			if verify {
+				report := reverseResult.Verify()
+				return rpt.Print(cmd.OutOrStdout(), format, report, func(w io.Writer) {
+					printReverseVerifyReport(w, report)
+				})
+			}
+
 			// 打印输出
-			reverseResult.Print(cmd.OutOrStdout())
+			if err := reverseResult.Print(cmd.OutOrStdout()); err != nil {
+				return err
+			}
 
 			// 保存输出
 			if save {
@@ -101,11 +130,14 @@ func NewReverseCommand() *cobra.Command {
 	}
 
 	cmd.Flags().BoolVarP(&save, "save", "s", false, "保存输出到文件")
-	cmd.Flags().StringVarP(&reverseType, "reverse-type", "t", "cmd", "输出类型: cmd | compose | all")
+	cmd.Flags().StringVarP(&reverseType, "reverse-type", "t", "cmd", "输出类型: cmd | compose | all | k8s | quadlet | systemd")
 	cmd.Flags().BoolVar(&preserveVolumes, "preserve-volumes", false, "是否保留匿名卷名称（默认关闭）")
 	cmd.Flags().BoolVar(&noDefaultEnvs, "no-default-envs", false, "不过滤 Docker 默认环境变量")
 	cmd.Flags().BoolVar(&noMergePorts, "no-merge-ports", false, "不合并连续端口")
 	cmd.Flags().BoolVar(&prettyFormat, "pretty", false, "是否格式化输出 docker run 命令（默认关闭）")
+	cmd.Flags().BoolVar(&verify, "verify", false, "将生成的命令/compose 解析回容器配置并与源容器对比，输出逐字段保真度报告（不创建容器）")
+	commandflags.AddReportFormatFlag(cmd, &format)
+	cmd.Flags().BoolVar(&project, "project", false, "按 com.docker.compose.project/service 标签还原 compose 项目，推断 depends_on 并合并副本，每个项目输出一个 compose 文件")
 	commandflags.AddContainerFilterFlags(cmd, &running, &filters, "仅筛选正在运行的容器；未指定 --reverse-type 时默认输出 compose")
 	commandflags.AddRedactFlags(cmd, &redactSecrets, &redactProfile, "脱敏 env/label 中疑似敏感字段，便于分享输出")
 	_ = cmd.RegisterFlagCompletionFunc("reverse-type", completeReverseTypes)
@@ -124,7 +156,7 @@ func reverseTargetSelectionComment(count int, running bool, filters []string) st
 }
 
 func completeReverseTypes(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-	values := []string{string(ReverseCmd), string(ReverseCompose), string(ReverseAll)}
+	values := []string{string(ReverseCmd), string(ReverseCompose), string(ReverseAll), string(ReverseK8s), string(ReverseQuadlet), string(ReverseSystemd)}
 	var suggestions []string
 	for _, value := range values {
 		if strings.HasPrefix(value, toComplete) {
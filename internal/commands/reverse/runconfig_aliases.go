@@ -12,16 +12,27 @@ const (
 	ReverseCmd     = runconfig.ReverseCmd
 	ReverseCompose = runconfig.ReverseCompose
 	ReverseAll     = runconfig.ReverseAll
+	ReverseK8s     = runconfig.ReverseK8s
+	ReverseQuadlet = runconfig.ReverseQuadlet
+	ReverseSystemd = runconfig.ReverseSystemd
 )
 
 type ReverseOptions = runconfig.ReverseOptions
 type ContainerSpec = runconfig.ContainerSpec
 type PortBindingSpec = runconfig.PortBindingSpec
 type UlimitSpec = runconfig.UlimitSpec
+type NetworkEndpointSpec = runconfig.NetworkEndpointSpec
 type ParsedResult = runconfig.ParsedResult
 type ComposeFile = runconfig.ComposeFile
 type ComposeService = runconfig.ComposeService
 type ComposeLogging = runconfig.ComposeLogging
+type ComposeDependsOn = runconfig.ComposeDependsOn
+type K8sObject = runconfig.K8sObject
+type K8sWorkloadSpec = runconfig.K8sWorkloadSpec
+type K8sServiceSpec = runconfig.K8sServiceSpec
+type QuadletUnit = runconfig.QuadletUnit
+type ComposeDeploy = runconfig.ComposeDeploy
+type ComposeServiceNetwork = runconfig.ComposeServiceNetwork
 type CommandFormatter = runconfig.CommandFormatter
 type ComposeFormatter = runconfig.ComposeFormatter
 type Parser = runconfig.Parser
@@ -0,0 +1,260 @@
+package reverse
+
+import (
+	"fmt"
+	"io"
+	"net/netip"
+	"sort"
+	"strings"
+
+	"docker-manager/internal/inspectfields"
+	"docker-manager/internal/runconfig"
+	"docker-manager/internal/sensitive"
+
+	"github.com/moby/moby/api/types/container"
+	"github.com/moby/moby/api/types/network"
+)
+
+// ReverseVerifyReport 是 reverse --verify 的逐容器保真度报告。
+type ReverseVerifyReport struct {
+	DockerEndpoint string                   `json:"docker_endpoint"`
+	Total          int                      `json:"total"`
+	Reproducible   int                      `json:"reproducible"`
+	Containers     []ReverseVerifyContainer `json:"containers"`
+}
+
+type ReverseVerifyContainer struct {
+	Name         string               `json:"name"`
+	Output       ReverseType          `json:"output"`
+	Reproducible bool                 `json:"reproducible"`
+	Compared     int                  `json:"compared"`
+	Changed      []ReverseVerifyField `json:"changed,omitempty"`
+	Lost         []ReverseVerifyField `json:"lost,omitempty"`
+	Added        []ReverseVerifyField `json:"added,omitempty"`
+	Warnings     []string             `json:"warnings,omitempty"`
+	Error        string               `json:"error,omitempty"`
+}
+
+type ReverseVerifyField struct {
+	Path      string `json:"path"`
+	Source    string `json:"source,omitempty"`
+	Generated string `json:"generated,omitempty"`
+}
+
+// Verify 将生成的 docker run / compose 解析回容器配置，与源容器 inspect 逐字段对比，不创建任何资源。
+func (rr *ReverseResult) Verify() ReverseVerifyReport {
+	report := ReverseVerifyReport{DockerEndpoint: rr.DockerEndpoint}
+	results := append([]ParsedResult(nil), rr.ParsedResults...)
+	sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+	for _, result := range results {
+		source, ok := rr.sources[result.Name]
+		if !ok {
+			continue
+		}
+		if rr.options.ReverseType == ReverseCmd || rr.options.ReverseType == ReverseAll {
+			generated, err := runconfig.CommandToInspect(result.Command)
+			report.add(rr.verifyContainer(result.Name, ReverseCmd, source, generated, err, result.CommandWarnings))
+		}
+		if rr.options.ReverseType == ReverseCompose || rr.options.ReverseType == ReverseAll {
+			generated, err := runconfig.ComposeToInspect(result.Name, result.Compose)
+			report.add(rr.verifyContainer(result.Name, ReverseCompose, source, generated, err, result.ComposeWarnings))
+		}
+	}
+	return report
+}
+
+func (r *ReverseVerifyReport) add(item ReverseVerifyContainer) {
+	r.Total++
+	if item.Reproducible {
+		r.Reproducible++
+	}
+	r.Containers = append(r.Containers, item)
+}
+
+func (rr *ReverseResult) verifyContainer(name string, output ReverseType, source, generated container.InspectResponse, err error, warnings []string) ReverseVerifyContainer {
+	item := ReverseVerifyContainer{Name: name, Output: output, Warnings: warnings}
+	if err != nil {
+		item.Error = err.Error()
+		return item
+	}
+	profile, _ := sensitive.NormalizeProfile(rr.options.RedactProfile, rr.options.RedactSecrets)
+	left := inspectfields.ComparableRunFields(normalizeVerifyInspect(source, rr.options), profile)
+	right := inspectfields.ComparableRunFields(normalizeVerifyInspect(generated, rr.options), profile)
+	// Binds 只是挂载的一种写法，实际挂载结果由 mounts 对比
+	delete(left, "host.binds")
+	delete(right, "host.binds")
+
+	paths := map[string]bool{}
+	for path := range left {
+		paths[path] = true
+	}
+	for path := range right {
+		paths[path] = true
+	}
+	for path := range paths {
+		item.Compared++
+		sourceValue, generatedValue := left[path], right[path]
+		sourceEmpty, generatedEmpty := isEmptyVerifyValue(sourceValue), isEmptyVerifyValue(generatedValue)
+		switch {
+		case sourceEmpty && generatedEmpty, sourceValue == generatedValue:
+		case generatedEmpty:
+			item.Lost = append(item.Lost, ReverseVerifyField{Path: path, Source: sourceValue})
+		case sourceEmpty:
+			item.Added = append(item.Added, ReverseVerifyField{Path: path, Generated: generatedValue})
+		default:
+			item.Changed = append(item.Changed, ReverseVerifyField{Path: path, Source: sourceValue, Generated: generatedValue})
+		}
+	}
+	for _, fields := range [][]ReverseVerifyField{item.Changed, item.Lost, item.Added} {
+		sort.Slice(fields, func(i, j int) bool { return fields[i].Path < fields[j].Path })
+	}
+	item.Reproducible = len(item.Changed) == 0 && len(item.Lost) == 0 && len(item.Added) == 0
+	return item
+}
+
+func isEmptyVerifyValue(value string) bool {
+	switch value {
+	case "", "null", "[]", "{}", `""`, "0", "false":
+		return true
+	}
+	return false
+}
+
+// normalizeVerifyInspect 补齐 daemon 默认值并清理运行态字段，使源容器与回读结果可直接对比。
+func normalizeVerifyInspect(info container.InspectResponse, opts ReverseOptions) container.InspectResponse {
+	name := strings.TrimPrefix(info.Name, "/")
+	shortID := ""
+	if len(info.ID) >= 12 {
+		shortID = info.ID[:12]
+	}
+	if info.Config != nil {
+		cfg := *info.Config
+		if cfg.Hostname == shortID {
+			cfg.Hostname = ""
+		}
+		if opts.FilterDefaultEnvs {
+			var envs []string
+			for _, env := range cfg.Env {
+				key, _, _ := strings.Cut(env, "=")
+				if !runconfig.IsDefaultEnvKey(key) {
+					envs = append(envs, env)
+				}
+			}
+			cfg.Env = envs
+		}
+		info.Config = &cfg
+	}
+	if info.HostConfig != nil {
+		host := *info.HostConfig
+		switch host.NetworkMode {
+		case "", "default":
+			host.NetworkMode = "bridge"
+		}
+		host.Runtime = runconfig.DefaultString(host.Runtime, "runc")
+		host.IpcMode = container.IpcMode(runconfig.DefaultString(string(host.IpcMode), "private"))
+		host.CgroupnsMode = container.CgroupnsMode(runconfig.DefaultString(string(host.CgroupnsMode), "private"))
+		host.RestartPolicy.Name = container.RestartPolicyMode(runconfig.DefaultString(string(host.RestartPolicy.Name), "no"))
+		if host.ShmSize == 0 {
+			host.ShmSize = 64 << 20
+		}
+		if host.PidsLimit != nil && *host.PidsLimit <= 0 {
+			host.PidsLimit = nil
+		}
+		host.Ulimits = append([]*container.Ulimit(nil), host.Ulimits...)
+		sort.Slice(host.Ulimits, func(i, j int) bool { return host.Ulimits[i].Name < host.Ulimits[j].Name })
+		if len(host.PortBindings) > 0 {
+			bindings := make(network.PortMap, len(host.PortBindings))
+			for port, list := range host.PortBindings {
+				for _, binding := range list {
+					if binding.HostIP.IsUnspecified() {
+						binding.HostIP = netip.Addr{}
+					}
+					bindings[port] = append(bindings[port], binding)
+				}
+			}
+			host.PortBindings = bindings
+		}
+		info.HostConfig = &host
+	}
+
+	mounts := make([]container.MountPoint, 0, len(info.Mounts))
+	for _, m := range info.Mounts {
+		if m.Type == "tmpfs" {
+			continue
+		}
+		if m.Type == "volume" {
+			m.Source = ""
+			if runconfig.IsAnonymousVolumeName(m.Name) {
+				m.Name = ""
+			}
+		}
+		if m.Driver == "local" {
+			m.Driver = ""
+		}
+		if m.Propagation == "rprivate" {
+			m.Propagation = ""
+		}
+		m.Mode = ""
+		mounts = append(mounts, m)
+	}
+	info.Mounts = mounts
+
+	if info.NetworkSettings != nil && len(info.NetworkSettings.Networks) > 0 {
+		implicit := map[string]bool{name: true, shortID: true}
+		networks := make(map[string]*network.EndpointSettings, len(info.NetworkSettings.Networks))
+		for netName, endpoint := range info.NetworkSettings.Networks {
+			if endpoint == nil {
+				networks[netName] = nil
+				continue
+			}
+			normalized := network.EndpointSettings{DriverOpts: endpoint.DriverOpts, Links: endpoint.Links}
+			for _, alias := range endpoint.Aliases {
+				if !implicit[alias] {
+					normalized.Aliases = append(normalized.Aliases, alias)
+				}
+			}
+			if ipam := endpoint.IPAMConfig; ipam != nil && (ipam.IPv4Address.IsValid() || ipam.IPv6Address.IsValid() || len(ipam.LinkLocalIPs) > 0) {
+				normalized.IPAMConfig = ipam
+			}
+			if len(normalized.DriverOpts) == 0 {
+				normalized.DriverOpts = nil
+			}
+			networks[netName] = &normalized
+		}
+		settings := *info.NetworkSettings
+		settings.Networks = networks
+		info.NetworkSettings = &settings
+	}
+	return info
+}
+
+func printReverseVerifyReport(w io.Writer, report ReverseVerifyReport) {
+	fmt.Fprintln(w, "逆向输出校验 (仅解析，不创建容器)")
+	if report.DockerEndpoint != "" {
+		fmt.Fprintf(w, "Docker: %s\n", report.DockerEndpoint)
+	}
+	fmt.Fprintf(w, "摘要: 总计=%d 可还原=%d 存在差异=%d\n\n", report.Total, report.Reproducible, report.Total-report.Reproducible)
+	for _, item := range report.Containers {
+		switch {
+		case item.Error != "":
+			fmt.Fprintf(w, "%s [%s]: 回读失败: %s\n", item.Name, item.Output, item.Error)
+		case item.Reproducible:
+			fmt.Fprintf(w, "%s [%s]: 可还原 (对比字段=%d)\n", item.Name, item.Output, item.Compared)
+		default:
+			fmt.Fprintf(w, "%s [%s]: 存在差异 (变化=%d 丢失=%d 新增=%d)\n", item.Name, item.Output, len(item.Changed), len(item.Lost), len(item.Added))
+		}
+		for _, field := range item.Changed {
+			fmt.Fprintf(w, "  变化 %s\n      源容器: %s\n      生成结果: %s\n", field.Path, field.Source, field.Generated)
+		}
+		for _, field := range item.Lost {
+			fmt.Fprintf(w, "  丢失 %s: %s\n", field.Path, field.Source)
+		}
+		for _, field := range item.Added {
+			fmt.Fprintf(w, "  新增 %s: %s\n", field.Path, field.Generated)
+		}
+		for _, warning := range item.Warnings {
+			fmt.Fprintf(w, "  不可复现: %s\n", warning)
+		}
+		fmt.Fprintln(w)
+	}
+}
@@ -0,0 +1,79 @@
+package reverse
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/moby/moby/api/types/container"
+	"github.com/moby/moby/api/types/network"
+)
+
+func verifyResult(info container.InspectResponse, opts ReverseOptions) *ReverseResult {
+	result := NewReverseResult([]ParsedResult{NewParser(info, opts).ToResult()}, opts)
+	result.sources[strings.TrimPrefix(info.Name, "/")] = info
+	return result
+}
+
+func TestReverseVerifyRoundTripsFullHostConfig(t *testing.T) {
+	report := verifyResult(fullFidelityInspect(), ReverseOptions{ReverseType: ReverseAll, FilterDefaultEnvs: true, MergePorts: true}).Verify()
+
+	if report.Total != 2 || report.Reproducible != 1 {
+		t.Fatalf("unexpected summary: %+v", report)
+	}
+	cmd, compose := report.Containers[0], report.Containers[1]
+	if cmd.Output != ReverseCmd || !cmd.Reproducible || cmd.Error != "" {
+		t.Fatalf("docker run output should reproduce source, got %+v", cmd)
+	}
+	if compose.Output != ReverseCompose || compose.Reproducible {
+		t.Fatalf("compose output should report differences, got %+v", compose)
+	}
+	if len(compose.Lost) != 1 || compose.Lost[0].Path != "host.auto_remove" || len(compose.Changed) != 0 {
+		t.Fatalf("compose should only lose auto_remove, got %+v", compose)
+	}
+}
+
+func TestReverseVerifyReportsChangedAndLostFields(t *testing.T) {
+	info := container.InspectResponse{
+		ID:   "fedcba9876543210",
+		Name: "/web",
+		HostConfig: &container.HostConfig{
+			NetworkMode:  "default",
+			PortBindings: network.PortMap{network.MustParsePort("80/tcp"): {{HostPort: ""}}},
+		},
+		Config: &container.Config{
+			Image:      "nginx",
+			Hostname:   "fedcba987654",
+			Entrypoint: []string{"tini", "--"},
+			Cmd:        []string{"nginx"},
+			Env:        []string{"PATH=/usr/bin", "A=1"},
+		},
+		NetworkSettings: &container.NetworkSettings{
+			Networks: map[string]*network.EndpointSettings{"bridge": {}},
+		},
+	}
+	report := verifyResult(info, ReverseOptions{ReverseType: ReverseCmd, FilterDefaultEnvs: true, MergePorts: true}).Verify()
+
+	if report.Total != 1 || report.Reproducible != 0 {
+		t.Fatalf("unexpected summary: %+v", report)
+	}
+	item := report.Containers[0]
+	var changed []string
+	for _, field := range item.Changed {
+		changed = append(changed, field.Path)
+	}
+	if strings.Join(changed, ",") != "config.cmd,config.entrypoint" {
+		t.Fatalf("unexpected changed fields: %+v", item.Changed)
+	}
+	if len(item.Lost) != 1 || item.Lost[0].Path != "host.port_bindings" {
+		t.Fatalf("unexpected lost fields: %+v", item.Lost)
+	}
+
+	var buf bytes.Buffer
+	printReverseVerifyReport(&buf, report)
+	for _, want := range []string{"摘要: 总计=1 可还原=0 存在差异=1", "变化 config.entrypoint", "丢失 host.port_bindings"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Fatalf("report missing %q:\n%s", want, buf.String())
+		}
+	}
+}
@@ -0,0 +1,275 @@
+package inspectfields
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+
+	"docker-manager/internal/sensitive"
+
+	"github.com/moby/moby/api/types/container"
+	"github.com/moby/moby/api/types/network"
+)
+
+// Comparable 把 inspect 展开为 "路径 -> JSON 值" 的可对比字段集合，
+// inspect diff 与 reverse --verify 共用同一字段模型。
+func Comparable(info container.InspectResponse, redactProfile sensitive.Profile) map[string]string {
+	fields := map[string]string{}
+	add := func(path string, value interface{}) {
+		addField(fields, path, value, redactProfile)
+	}
+
+	if info.Config != nil {
+		cfg := info.Config
+		add("config.image", cfg.Image)
+		add("config.user", cfg.User)
+		add("config.working_dir", cfg.WorkingDir)
+		add("config.hostname", cfg.Hostname)
+		add("config.domainname", cfg.Domainname)
+		add("config.cmd", []string(cfg.Cmd))
+		add("config.entrypoint", []string(cfg.Entrypoint))
+		add("config.healthcheck", comparableHealthcheck(cfg.Healthcheck))
+		add("config.env", envMap(cfg.Env, redactProfile))
+		add("config.labels", cfg.Labels)
+		add("config.exposed_ports", cfg.ExposedPorts)
+		add("config.tty", cfg.Tty)
+		add("config.open_stdin", cfg.OpenStdin)
+		add("config.stop_signal", cfg.StopSignal)
+	}
+
+	if info.HostConfig != nil {
+		host := info.HostConfig
+		add("host.restart_policy", host.RestartPolicy)
+		add("host.network_mode", host.NetworkMode)
+		add("host.privileged", host.Privileged)
+		add("host.auto_remove", host.AutoRemove)
+		add("host.publish_all_ports", host.PublishAllPorts)
+		add("host.port_bindings", host.PortBindings)
+		add("host.binds", sortedStrings(host.Binds))
+		add("host.dns", sortedNetIPAddrs(host.DNS))
+		add("host.dns_search", sortedStrings(host.DNSSearch))
+		add("host.extra_hosts", sortedStrings(host.ExtraHosts))
+		add("host.cap_add", sortedStrings([]string(host.CapAdd)))
+		add("host.cap_drop", sortedStrings([]string(host.CapDrop)))
+		add("host.security_opt", sortedStrings(host.SecurityOpt))
+		add("host.devices", host.Devices)
+		add("host.ulimits", host.Ulimits)
+		add("host.log_config", comparableLogConfig(host.LogConfig))
+		add("host.memory", host.Memory)
+		add("host.memory_reservation", host.MemoryReservation)
+		add("host.memory_swap", host.MemorySwap)
+		add("host.nano_cpus", host.NanoCPUs)
+		add("host.cpu_shares", host.CPUShares)
+		add("host.cpu_quota", host.CPUQuota)
+		add("host.cpu_period", host.CPUPeriod)
+		add("host.cpuset_cpus", host.CpusetCpus)
+		add("host.shm_size", host.ShmSize)
+		add("host.readonly_rootfs", host.ReadonlyRootfs)
+		add("host.tmpfs", host.Tmpfs)
+		add("host.sysctls", host.Sysctls)
+		add("host.runtime", host.Runtime)
+		add("host.ipc_mode", host.IpcMode)
+		add("host.pid_mode", host.PidMode)
+		add("host.userns_mode", host.UsernsMode)
+	}
+
+	add("mounts", comparableMounts(info.Mounts))
+	if info.NetworkSettings != nil {
+		add("networks", comparableNetworks(info.NetworkSettings.Networks))
+	}
+	return fields
+}
+
+// ComparableRunFields 在 Comparable 的基础上补充 docker run 可设置但 dm diff 不对比的字段，
+// 仅供 reverse --verify 校验逆向输出。
+func ComparableRunFields(info container.InspectResponse, redactProfile sensitive.Profile) map[string]string {
+	fields := Comparable(info, redactProfile)
+	add := func(path string, value interface{}) {
+		addField(fields, path, value, redactProfile)
+	}
+	if info.Config != nil {
+		add("config.stop_timeout", info.Config.StopTimeout)
+	}
+	if info.HostConfig != nil {
+		host := info.HostConfig
+		add("host.uts_mode", host.UTSMode)
+		add("host.cgroupns_mode", host.CgroupnsMode)
+		add("host.init", host.Init)
+		add("host.group_add", sortedStrings(host.GroupAdd))
+		add("host.dns_options", sortedStrings(host.DNSOptions))
+		add("host.oom_score_adj", host.OomScoreAdj)
+		add("host.pids_limit", host.PidsLimit)
+		add("host.links", sortedStrings(host.Links))
+		add("host.volumes_from", sortedStrings(host.VolumesFrom))
+	}
+	return fields
+}
+
+func addField(fields map[string]string, path string, value interface{}, redactProfile sensitive.Profile) {
+	if redactProfile != sensitive.ProfileNone {
+		value = redactValue(value, redactProfile)
+	}
+	fields[path] = Value(value)
+}
+
+func redactValue(value interface{}, profile sensitive.Profile) interface{} {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case string:
+		return sensitive.RedactText(v, profile)
+	case []string:
+		return sensitive.RedactStringSlice(v, profile)
+	case map[string]string:
+		return sensitive.RedactStringMap(v, profile)
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			if sensitive.IsSensitiveKey(key, profile) {
+				result[key] = sensitive.RedactedValue
+			} else {
+				result[key] = redactValue(item, profile)
+			}
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = redactValue(item, profile)
+		}
+		return result
+	case []map[string]interface{}:
+		result := make([]map[string]interface{}, len(v))
+		for i, item := range v {
+			result[i] = redactValue(item, profile).(map[string]interface{})
+		}
+		return result
+	default:
+		return value
+	}
+}
+
+func comparableHealthcheck(health *container.HealthConfig) map[string]interface{} {
+	if health == nil {
+		return nil
+	}
+	return map[string]interface{}{
+		"test":         append([]string(nil), health.Test...),
+		"interval":     health.Interval,
+		"timeout":      health.Timeout,
+		"start_period": health.StartPeriod,
+		"retries":      health.Retries,
+	}
+}
+
+func comparableLogConfig(config container.LogConfig) map[string]interface{} {
+	return map[string]interface{}{
+		"type":   config.Type,
+		"config": cloneStringMap(config.Config),
+	}
+}
+
+func comparableMounts(mounts []container.MountPoint) []map[string]interface{} {
+	result := make([]map[string]interface{}, 0, len(mounts))
+	for _, mount := range mounts {
+		result = append(result, map[string]interface{}{
+			"type":        mount.Type,
+			"name":        mount.Name,
+			"source":      mount.Source,
+			"destination": mount.Destination,
+			"driver":      mount.Driver,
+			"mode":        mount.Mode,
+			"rw":          mount.RW,
+			"propagation": mount.Propagation,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return fmt.Sprint(result[i]["destination"]) < fmt.Sprint(result[j]["destination"])
+	})
+	return result
+}
+
+func comparableNetworks(networks map[string]*network.EndpointSettings) map[string]interface{} {
+	result := map[string]interface{}{}
+	for name, endpoint := range networks {
+		if endpoint == nil {
+			result[name] = nil
+			continue
+		}
+		result[name] = map[string]interface{}{
+			"ip_address":  endpoint.IPAddress,
+			"ipam_config": endpoint.IPAMConfig,
+			"links":       sortedStrings(endpoint.Links),
+			"aliases":     sortedStrings(endpoint.Aliases),
+			"mac_address": endpoint.MacAddress,
+			"driver_opts": endpoint.DriverOpts,
+		}
+	}
+	return result
+}
+
+func envMap(envs []string, redactProfile sensitive.Profile) map[string]string {
+	result := map[string]string{}
+	for _, env := range envs {
+		key, value, found := strings.Cut(env, "=")
+		if !found {
+			result[env] = ""
+			continue
+		}
+		if redactProfile != sensitive.ProfileNone && sensitive.IsSensitiveKey(key, redactProfile) {
+			value = sensitive.RedactedValue
+		} else if redactProfile != sensitive.ProfileNone {
+			value = sensitive.RedactText(value, redactProfile)
+		}
+		result[key] = value
+	}
+	return result
+}
+
+// Value 以稳定的 JSON 形式编码字段值。
+func Value(value interface{}) string {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return fmt.Sprint(value)
+	}
+	return strings.TrimSpace(buf.String())
+}
+
+func sortedStrings(items []string) []string {
+	if len(items) == 0 {
+		return nil
+	}
+	result := append([]string(nil), items...)
+	sort.Strings(result)
+	return result
+}
+
+func sortedNetIPAddrs(items []netip.Addr) []string {
+	if len(items) == 0 {
+		return nil
+	}
+	result := make([]string, 0, len(items))
+	for _, item := range items {
+		if item.IsValid() {
+			result = append(result, item.String())
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+func cloneStringMap(src map[string]string) map[string]string {
+	if len(src) == 0 {
+		return nil
+	}
+	dst := make(map[string]string, len(src))
+	for key, value := range src {
+		dst[key] = value
+	}
+	return dst
+}
@@ -0,0 +1,144 @@
+package inspectfields
+
+import (
+	"testing"
+	"time"
+
+	"docker-manager/internal/sensitive"
+
+	"github.com/moby/moby/api/types/container"
+	"github.com/moby/moby/api/types/network"
+)
+
+func TestComparable(t *testing.T) {
+	tests := []struct {
+		name    string
+		info    container.InspectResponse
+		profile sensitive.Profile
+		want    map[string]string
+		absent  []string
+	}{
+		{
+			name:   "empty inspect only has mounts",
+			info:   container.InspectResponse{},
+			want:   map[string]string{"mounts": "[]"},
+			absent: []string{"config.image", "host.network_mode", "networks"},
+		},
+		{
+			name: "config fields",
+			info: container.InspectResponse{Config: &container.Config{
+				Image:      "nginx:1.27",
+				Cmd:        []string{"nginx", "-g", "daemon off;"},
+				Env:        []string{"A=1", "FLAG"},
+				Labels:     map[string]string{"app": "web"},
+				StopSignal: "SIGQUIT",
+				Healthcheck: &container.HealthConfig{
+					Test:     []string{"CMD", "true"},
+					Interval: time.Second,
+					Retries:  2,
+				},
+			}},
+			profile: sensitive.ProfileNone,
+			want: map[string]string{
+				"config.image":       `"nginx:1.27"`,
+				"config.cmd":         `["nginx","-g","daemon off;"]`,
+				"config.env":         `{"A":"1","FLAG":""}`,
+				"config.labels":      `{"app":"web"}`,
+				"config.stop_signal": `"SIGQUIT"`,
+				"config.healthcheck": `{"interval":1000000000,"retries":2,"start_period":0,"test":["CMD","true"],"timeout":0}`,
+			},
+		},
+		{
+			name: "host slices are sorted",
+			info: container.InspectResponse{HostConfig: &container.HostConfig{
+				NetworkMode: "app_net",
+				CapAdd:      []string{"SYS_TIME", "NET_ADMIN"},
+				ExtraHosts:  []string{"b:10.0.0.2", "a:10.0.0.1"},
+				Binds:       []string{"/b:/b", "/a:/a"},
+			}},
+			want: map[string]string{
+				"host.network_mode": `"app_net"`,
+				"host.cap_add":      `["NET_ADMIN","SYS_TIME"]`,
+				"host.extra_hosts":  `["a:10.0.0.1","b:10.0.0.2"]`,
+				"host.binds":        `["/a:/a","/b:/b"]`,
+			},
+		},
+		{
+			name: "diff field set excludes run-only fields",
+			info: container.InspectResponse{
+				Config:     &container.Config{StopTimeout: new(int)},
+				HostConfig: &container.HostConfig{UTSMode: "host", GroupAdd: []string{"audio"}, Links: []string{"/db:/web/db"}},
+			},
+			absent: []string{"config.stop_timeout", "host.uts_mode", "host.group_add", "host.links", "host.volumes_from", "host.pids_limit"},
+		},
+		{
+			name:    "sensitive env redacted",
+			info:    container.InspectResponse{Config: &container.Config{Env: []string{"DB_PASSWORD=s3cret", "LOG_LEVEL=info"}}},
+			profile: sensitive.ProfileBasic,
+			want:    map[string]string{"config.env": `{"DB_PASSWORD":"` + sensitive.RedactedValue + `","LOG_LEVEL":"info"}`},
+		},
+		{
+			name: "networks keep endpoint settings",
+			info: container.InspectResponse{NetworkSettings: &container.NetworkSettings{Networks: map[string]*network.EndpointSettings{
+				"app_net": {Aliases: []string{"web", "api"}},
+				"other":   nil,
+			}}},
+			want: map[string]string{
+				"networks": `{"app_net":{"aliases":["api","web"],"driver_opts":null,"ip_address":"","ipam_config":null,"links":null,"mac_address":""},"other":null}`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			profile := tt.profile
+			if profile == "" {
+				profile = sensitive.ProfileNone
+			}
+			got := Comparable(tt.info, profile)
+			for path, want := range tt.want {
+				if got[path] != want {
+					t.Fatalf("Comparable()[%q] = %s, want %s", path, got[path], want)
+				}
+			}
+			for _, path := range tt.absent {
+				if value, ok := got[path]; ok {
+					t.Fatalf("Comparable() should not contain %q, got %s", path, value)
+				}
+			}
+		})
+	}
+}
+
+func TestComparableRunFields(t *testing.T) {
+	info := container.InspectResponse{
+		Config:     &container.Config{Image: "nginx"},
+		HostConfig: &container.HostConfig{UTSMode: "host", GroupAdd: []string{"video", "audio"}, Links: []string{"/db:/web/db"}},
+	}
+	got := ComparableRunFields(info, sensitive.ProfileNone)
+	for path, want := range map[string]string{
+		"config.image":    `"nginx"`,
+		"host.uts_mode":   `"host"`,
+		"host.group_add":  `["audio","video"]`,
+		"host.links":      `["/db:/web/db"]`,
+		"host.pids_limit": "null",
+	} {
+		if got[path] != want {
+			t.Fatalf("ComparableRunFields()[%q] = %s, want %s", path, got[path], want)
+		}
+	}
+	if len(got) <= len(Comparable(info, sensitive.ProfileNone)) {
+		t.Fatalf("ComparableRunFields should extend the diff field set")
+	}
+}
+
+func TestComparableRunFieldsRedactsRunOnlyFields(t *testing.T) {
+	info := container.InspectResponse{
+		HostConfig: &container.HostConfig{DNSOptions: []string{"ndots:2", "api_token=s3cret"}},
+	}
+	got := ComparableRunFields(info, sensitive.ProfileBasic)
+	want := `["api_token=` + sensitive.RedactedValue + `","ndots:2"]`
+	if got["host.dns_options"] != want {
+		t.Fatalf("ComparableRunFields()[host.dns_options] = %s, want %s", got["host.dns_options"], want)
+	}
+}
@@ -0,0 +1,454 @@
+package objectstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"docker-manager/internal/appconfig"
+)
+
+const (
+	Scheme = "s3://"
+
+	DefaultRegion   = "us-east-1"
+	DefaultPartSize = 16 * 1024 * 1024
+	// MinPartSize is the smallest part S3 accepts for all but the last part
+	// of a multipart upload.
+	MinPartSize = 5 * 1024 * 1024
+
+	emptyPayloadSHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	signAlgorithm      = "AWS4-HMAC-SHA256"
+)
+
+// Config is the resolved S3 endpoint and credentials.
+type Config struct {
+	Endpoint        string
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	PathStyle       *bool
+	PartSize        int64
+}
+
+// ConfigFromApp takes .dm.yaml s3 settings and fills empty fields from the
+// standard AWS environment variables, matching how docker_host falls back to
+// DOCKER_HOST.
+func ConfigFromApp(cfg appconfig.S3Config) Config {
+	resolved := Config{
+		Endpoint:        firstNonEmpty(cfg.Endpoint, os.Getenv("AWS_ENDPOINT_URL_S3"), os.Getenv("AWS_ENDPOINT_URL")),
+		Region:          firstNonEmpty(cfg.Region, os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION"), DefaultRegion),
+		AccessKeyID:     firstNonEmpty(cfg.AccessKeyID, os.Getenv("AWS_ACCESS_KEY_ID")),
+		SecretAccessKey: firstNonEmpty(cfg.SecretAccessKey, os.Getenv("AWS_SECRET_ACCESS_KEY")),
+		SessionToken:    firstNonEmpty(cfg.SessionToken, os.Getenv("AWS_SESSION_TOKEN")),
+		PathStyle:       cfg.PathStyle,
+		PartSize:        DefaultPartSize,
+	}
+	if cfg.PartSizeMB > 0 {
+		resolved.PartSize = max(int64(cfg.PartSizeMB)*1024*1024, MinPartSize)
+	}
+	return resolved
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if strings.TrimSpace(value) != "" {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
+
+func IsURL(value string) bool {
+	return strings.HasPrefix(strings.ToLower(value), Scheme)
+}
+
+// ParseURL splits s3://bucket/key into bucket and key.
+func ParseURL(value string) (string, string, error) {
+	if !IsURL(value) {
+		return "", "", fmt.Errorf("not an s3 URL: %s", value)
+	}
+	bucket, key, _ := strings.Cut(value[len(Scheme):], "/")
+	if bucket == "" || key == "" || strings.HasSuffix(key, "/") {
+		return "", "", fmt.Errorf("s3 URL must be s3://<bucket>/<key>: %s", value)
+	}
+	return bucket, key, nil
+}
+
+// Client implements the few S3 calls backups need with SigV4 signing, so
+// MinIO and other S3-compatible stores work without an SDK.
+type Client struct {
+	cfg      Config
+	endpoint *url.URL
+	http     *http.Client
+	now      func() time.Time
+}
+
+func NewClient(cfg Config) (*Client, error) {
+	if cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+		return nil, errors.New("S3 凭据缺失：设置 AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY 或 .dm.yaml 中的 s3.access_key_id/s3.secret_access_key")
+	}
+	if cfg.Region == "" {
+		cfg.Region = DefaultRegion
+	}
+	if cfg.PartSize <= 0 {
+		cfg.PartSize = DefaultPartSize
+	}
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = "https://s3." + cfg.Region + ".amazonaws.com"
+	} else if !strings.Contains(endpoint, "://") {
+		endpoint = "https://" + endpoint
+	}
+	parsed, err := url.Parse(strings.TrimRight(endpoint, "/"))
+	if err != nil || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", cfg.Endpoint)
+	}
+	return &Client{cfg: cfg, endpoint: parsed, http: &http.Client{Transport: http.DefaultTransport}, now: time.Now}, nil
+}
+
+// pathStyle defaults to true for custom endpoints: MinIO and most
+// self-hosted stores do not serve bucket subdomains.
+func (c *Client) pathStyle() bool {
+	if c.cfg.PathStyle != nil {
+		return *c.cfg.PathStyle
+	}
+	return c.cfg.Endpoint != ""
+}
+
+func (c *Client) objectURL(bucket, key string, query url.Values) *url.URL {
+	u := *c.endpoint
+	objectPath := "/" + key
+	if c.pathStyle() {
+		objectPath = "/" + bucket + objectPath
+	} else {
+		u.Host = bucket + "." + u.Host
+	}
+	u.Path = c.endpoint.Path + objectPath
+	u.RawPath = escapePath(u.Path)
+	u.RawQuery = canonicalQuery(query)
+	return &u
+}
+
+// Get streams an object. A missing object returns an error that matches
+// os.ErrNotExist.
+func (c *Client) Get(ctx context.Context, bucket, key string) (io.ReadCloser, int64, error) {
+	resp, err := c.do(ctx, http.MethodGet, bucket, key, nil, nil, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	return resp.Body, resp.ContentLength, nil
+}
+
+func (c *Client) Put(ctx context.Context, bucket, key string, data []byte) error {
+	resp, err := c.do(ctx, http.MethodPut, bucket, key, nil, nil, data)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (c *Client) do(ctx context.Context, method, bucket, key string, query url.Values, header http.Header, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.objectURL(bucket, key, query).String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	sum := sha256.Sum256(body)
+	c.sign(req, hex.EncodeToString(sum[:]), c.now())
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		return nil, responseError(method, bucket, key, resp)
+	}
+	return resp, nil
+}
+
+type s3Error struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+func responseError(method, bucket, key string, resp *http.Response) error {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	var parsed s3Error
+	_ = xml.Unmarshal(data, &parsed)
+	err := fmt.Errorf("s3 %s s3://%s/%s: HTTP %d %s %s", method, bucket, key, resp.StatusCode, parsed.Code, parsed.Message)
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%w: %w", os.ErrNotExist, err)
+	}
+	return err
+}
+
+// sign adds AWS Signature Version 4 headers. Every header already set on the
+// request is signed together with host.
+func (c *Client) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	scope := amzDate[:8] + "/" + c.cfg.Region + "/s3/aws4_request"
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if c.cfg.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", c.cfg.SessionToken)
+	}
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := signAlgorithm + "\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+	key := hmacSHA256([]byte("AWS4"+c.cfg.SecretAccessKey), amzDate[:8])
+	for _, part := range []string{c.cfg.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s", signAlgorithm, c.cfg.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalQuery encodes query parameters sorted by key, as SigV4 requires;
+// the same string is sent on the wire so both sides agree.
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, key := range keys {
+		values := append([]string(nil), query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			parts = append(parts, escape(key, false)+"="+escape(value, false))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func escapePath(value string) string {
+	return escape(value, true)
+}
+
+// escape applies the SigV4 URI encoding: everything except unreserved
+// characters is percent-encoded, and '/' only outside of paths.
+func escape(value string, path bool) string {
+	var sb strings.Builder
+	for _, b := range []byte(value) {
+		switch {
+		case 'A' <= b && b <= 'Z', 'a' <= b && b <= 'z', '0' <= b && b <= '9', b == '-', b == '_', b == '.', b == '~':
+			sb.WriteByte(b)
+		case b == '/' && path:
+			sb.WriteByte(b)
+		default:
+			fmt.Fprintf(&sb, "%%%02X", b)
+		}
+	}
+	return sb.String()
+}
+
+// Writer uploads everything written to it as one object. Data is buffered
+// one part at a time: objects smaller than a part are sent with a single PUT,
+// larger ones with a multipart upload that Close completes and Abort cancels.
+type Writer struct {
+	ctx      context.Context
+	client   *Client
+	bucket   string
+	key      string
+	buf      []byte
+	uploadID string
+	parts    []completedPart
+	err      error
+	closed   bool
+}
+
+type completedPart struct {
+	XMLName    xml.Name `xml:"Part"`
+	PartNumber int      `xml:"PartNumber"`
+	ETag       string   `xml:"ETag"`
+}
+
+func (c *Client) NewWriter(ctx context.Context, bucket, key string) *Writer {
+	return &Writer{ctx: ctx, client: c, bucket: bucket, key: key, buf: make([]byte, 0, c.cfg.PartSize)}
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	if w.closed {
+		return 0, errors.New("s3 writer is closed")
+	}
+	written := len(p)
+	partSize := int(w.client.cfg.PartSize)
+	for len(p) > 0 {
+		space := min(partSize-len(w.buf), len(p))
+		w.buf = append(w.buf, p[:space]...)
+		p = p[space:]
+		if len(w.buf) == partSize {
+			if err := w.flushPart(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return written, nil
+}
+
+func (w *Writer) flushPart() error {
+	if w.uploadID == "" {
+		uploadID, err := w.client.createMultipartUpload(w.ctx, w.bucket, w.key)
+		if err != nil {
+			w.err = err
+			return err
+		}
+		w.uploadID = uploadID
+	}
+	number := len(w.parts) + 1
+	query := url.Values{"partNumber": {strconv.Itoa(number)}, "uploadId": {w.uploadID}}
+	resp, err := w.client.do(w.ctx, http.MethodPut, w.bucket, w.key, query, nil, w.buf)
+	if err != nil {
+		w.err = fmt.Errorf("upload part %d: %w", number, err)
+		return w.err
+	}
+	_ = resp.Body.Close()
+	w.parts = append(w.parts, completedPart{PartNumber: number, ETag: resp.Header.Get("ETag")})
+	w.buf = w.buf[:0]
+	return nil
+}
+
+func (w *Writer) Close() error {
+	if w.closed {
+		return w.err
+	}
+	w.closed = true
+	if w.err != nil {
+		_ = w.abort()
+		return w.err
+	}
+	if w.uploadID == "" {
+		w.err = w.client.Put(w.ctx, w.bucket, w.key, w.buf)
+		return w.err
+	}
+	if len(w.buf) > 0 {
+		if err := w.flushPart(); err != nil {
+			_ = w.abort()
+			return err
+		}
+	}
+	if err := w.client.completeMultipartUpload(w.ctx, w.bucket, w.key, w.uploadID, w.parts); err != nil {
+		w.err = err
+		_ = w.abort()
+		return err
+	}
+	return nil
+}
+
+// Abort discards the upload; parts already sent are deleted by the server.
+func (w *Writer) Abort() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	w.err = errors.New("s3 upload aborted")
+	return w.abort()
+}
+
+func (w *Writer) abort() error {
+	if w.uploadID == "" {
+		return nil
+	}
+	// The upload context may already be canceled; abort must still reach the
+	// server or the parts are billed until a lifecycle rule removes them.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(w.ctx), 30*time.Second)
+	defer cancel()
+	resp, err := w.client.do(ctx, http.MethodDelete, w.bucket, w.key, url.Values{"uploadId": {w.uploadID}}, nil, nil)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (c *Client) createMultipartUpload(ctx context.Context, bucket, key string) (string, error) {
+	resp, err := c.do(ctx, http.MethodPost, bucket, key, url.Values{"uploads": {""}}, nil, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var result struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("create multipart upload: %w", err)
+	}
+	if result.UploadID == "" {
+		return "", errors.New("create multipart upload: empty upload id")
+	}
+	return result.UploadID, nil
+}
+
+func (c *Client) completeMultipartUpload(ctx context.Context, bucket, key, uploadID string, parts []completedPart) error {
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+		Parts   []completedPart `xml:"Part"`
+	}{Parts: parts})
+	if err != nil {
+		return err
+	}
+	header := http.Header{"Content-Type": {"application/xml"}}
+	resp, err := c.do(ctx, http.MethodPost, bucket, key, url.Values{"uploadId": {uploadID}}, header, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// S3 may report a failed completion with HTTP 200 and an Error body.
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return err
+	}
+	var failure s3Error
+	if xml.Unmarshal(data, &failure) == nil && failure.Code != "" {
+		return fmt.Errorf("complete multipart upload s3://%s/%s: %s %s", bucket, key, failure.Code, failure.Message)
+	}
+	return nil
+}
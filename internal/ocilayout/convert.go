@@ -0,0 +1,178 @@
+package ocilayout
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/distribution/reference"
+	digest "github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// ConvertDockerArchive turns a docker save tar into an OCI layout at dst: a
+// directory when asArchive is false, otherwise a tar of that directory. Tars
+// that already are OCI layouts (Docker 25+ writes both) are passed through.
+func ConvertDockerArchive(ctx context.Context, src, dst string, asArchive bool) error {
+	work, err := os.MkdirTemp(filepath.Dir(dst), ".oci-convert-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(work)
+
+	if err := ExtractTar(ctx, src, work); err != nil {
+		return fmt.Errorf("解包 docker 归档失败: %w", err)
+	}
+	if !IsLayoutDir(work) {
+		if err := convertDockerLayout(ctx, work); err != nil {
+			return err
+		}
+	}
+	if asArchive {
+		return TarDir(ctx, work, dst)
+	}
+	if err := os.Chmod(work, 0755); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(dst); err != nil {
+		return err
+	}
+	return os.Rename(work, dst)
+}
+
+// convertDockerLayout rewrites an extracted docker-archive in place: config
+// and layer files move under blobs/sha256, an OCI manifest is written per
+// image, and everything the layout does not reference is removed.
+func convertDockerLayout(ctx context.Context, root string) error {
+	data, err := os.ReadFile(filepath.Join(root, DockerManifestFile))
+	if err != nil {
+		return fmt.Errorf("读取 manifest.json 失败: %w", err)
+	}
+	var entries []DockerManifest
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("解析 manifest.json 失败: %w", err)
+	}
+	var descriptors []ocispec.Descriptor
+	moved := map[string]ocispec.Descriptor{}
+	for i, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		config, err := moveToBlob(root, entry.Config, ocispec.MediaTypeImageConfig, moved)
+		if err != nil {
+			return fmt.Errorf("转换镜像配置失败: %w", err)
+		}
+		manifest := ocispec.Manifest{MediaType: ocispec.MediaTypeImageManifest, Config: config}
+		manifest.SchemaVersion = 2
+		entries[i].Config = BlobRelPath(config.Digest)
+		for j, layerPath := range entry.Layers {
+			layer, err := moveToBlob(root, layerPath, "", moved)
+			if err != nil {
+				return fmt.Errorf("转换镜像层失败: %w", err)
+			}
+			manifest.Layers = append(manifest.Layers, layer)
+			entries[i].Layers[j] = BlobRelPath(layer.Digest)
+		}
+		manifestData, err := json.Marshal(manifest)
+		if err != nil {
+			return err
+		}
+		desc, err := WriteBlob(root, ocispec.MediaTypeImageManifest, manifestData)
+		if err != nil {
+			return err
+		}
+		if len(entry.RepoTags) == 0 {
+			descriptors = append(descriptors, desc)
+		}
+		for _, tag := range entry.RepoTags {
+			named := desc
+			named.Annotations = repoTagAnnotations(tag)
+			descriptors = append(descriptors, named)
+		}
+	}
+	if err := removeNonLayoutFiles(root); err != nil {
+		return err
+	}
+	return WriteLayout(root, descriptors, entries)
+}
+
+// moveToBlob renames rel to its content address. Images saved together list
+// shared layers more than once, so moved remembers paths already handled.
+func moveToBlob(root, rel, mediaType string, moved map[string]ocispec.Descriptor) (ocispec.Descriptor, error) {
+	if desc, ok := moved[rel]; ok {
+		return desc, nil
+	}
+	path := filepath.Join(root, filepath.FromSlash(rel))
+	file, err := os.Open(path)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	header := make([]byte, 4)
+	n, _ := io.ReadFull(file, header)
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		file.Close()
+		return ocispec.Descriptor{}, err
+	}
+	digester := digest.Canonical.Digester()
+	size, err := io.Copy(digester.Hash(), file)
+	file.Close()
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	if mediaType == "" {
+		mediaType = layerMediaType(header[:n])
+	}
+	desc := ocispec.Descriptor{MediaType: mediaType, Digest: digester.Digest(), Size: size}
+	moved[rel] = desc
+	target := BlobPath(root, desc.Digest)
+	if path == target {
+		return desc, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return desc, err
+	}
+	return desc, os.Rename(path, target)
+}
+
+func layerMediaType(header []byte) string {
+	switch {
+	case bytes.HasPrefix(header, []byte{0x1f, 0x8b}):
+		return ocispec.MediaTypeImageLayerGzip
+	case bytes.HasPrefix(header, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		return ocispec.MediaTypeImageLayerZstd
+	default:
+		return ocispec.MediaTypeImageLayer
+	}
+}
+
+func repoTagAnnotations(repoTag string) map[string]string {
+	named, err := reference.ParseNormalizedNamed(repoTag)
+	if err != nil {
+		return RefAnnotations("", repoTag)
+	}
+	tag := ""
+	if tagged, ok := named.(reference.Tagged); ok {
+		tag = tagged.Tag()
+	}
+	return RefAnnotations(named.String(), tag)
+}
+
+func removeNonLayoutFiles(root string) error {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.Name() == "blobs" {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(root, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
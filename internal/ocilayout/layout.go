@@ -0,0 +1,252 @@
+package ocilayout
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	digest "github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// Archive formats accepted by --archive-format.
+const (
+	FormatDockerArchive = "docker-archive"
+	FormatOCI           = "oci"
+	FormatOCIArchive    = "oci-archive"
+)
+
+const (
+	IndexFile          = "index.json"
+	DockerManifestFile = "manifest.json"
+	// AnnotationImageName is the full image reference containerd and Docker
+	// 25+ read when importing an OCI archive.
+	AnnotationImageName = "io.containerd.image.name"
+)
+
+// ParseFormat normalizes an --archive-format value; empty means docker-archive.
+func ParseFormat(value string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", FormatDockerArchive:
+		return FormatDockerArchive, nil
+	case FormatOCI:
+		return FormatOCI, nil
+	case FormatOCIArchive:
+		return FormatOCIArchive, nil
+	default:
+		return "", fmt.Errorf("不支持的 --archive-format %q，可选 docker-archive、oci、oci-archive", value)
+	}
+}
+
+// IsOCI reports whether format produces an OCI layout (directory or tar).
+func IsOCI(format string) bool {
+	return format == FormatOCI || format == FormatOCIArchive
+}
+
+// DockerManifest is one entry of a docker-archive manifest.json. Layouts
+// written here carry one too, pointing at blobs/sha256/..., so daemons that
+// predate OCI import can still docker load them.
+type DockerManifest struct {
+	Config   string   `json:"Config"`
+	Layers   []string `json:"Layers"`
+	RepoTags []string `json:"RepoTags"`
+}
+
+// BlobPath returns the content-addressed location of d under root.
+func BlobPath(root string, d digest.Digest) string {
+	return filepath.Join(root, BlobRelPath(d))
+}
+
+// BlobRelPath is BlobPath relative to the layout root, slash separated as
+// manifest.json expects.
+func BlobRelPath(d digest.Digest) string {
+	return "blobs/" + d.Algorithm().String() + "/" + d.Encoded()
+}
+
+// WriteBlob stores data under its sha256 digest and returns the descriptor.
+func WriteBlob(root, mediaType string, data []byte) (ocispec.Descriptor, error) {
+	desc := ocispec.Descriptor{MediaType: mediaType, Digest: digest.FromBytes(data), Size: int64(len(data))}
+	path := BlobPath(root, desc.Digest)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return desc, err
+	}
+	return desc, os.WriteFile(path, data, 0644)
+}
+
+// WriteLayout finishes a layout whose blobs are already in place by writing
+// oci-layout, index.json and the docker-compatible manifest.json.
+func WriteLayout(root string, manifests []ocispec.Descriptor, docker []DockerManifest) error {
+	layout, err := json.Marshal(ocispec.ImageLayout{Version: ocispec.ImageLayoutVersion})
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(root, ocispec.ImageLayoutFile), layout, 0644); err != nil {
+		return err
+	}
+	index := ocispec.Index{MediaType: ocispec.MediaTypeImageIndex, Manifests: manifests}
+	index.SchemaVersion = 2
+	data, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(root, IndexFile), data, 0644); err != nil {
+		return err
+	}
+	if len(docker) == 0 {
+		return nil
+	}
+	data, err = json.Marshal(docker)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(root, DockerManifestFile), data, 0644)
+}
+
+// RefAnnotations names an index.json entry both the OCI way (tag only) and
+// the containerd way (full reference).
+func RefAnnotations(fullName, tag string) map[string]string {
+	annotations := map[string]string{}
+	if tag != "" {
+		annotations[ocispec.AnnotationRefName] = tag
+	}
+	if fullName != "" {
+		annotations[AnnotationImageName] = fullName
+	}
+	return annotations
+}
+
+// IsLayoutDir reports whether path is an OCI image layout directory.
+func IsLayoutDir(path string) bool {
+	info, err := os.Stat(filepath.Join(path, ocispec.ImageLayoutFile))
+	return err == nil && info.Mode().IsRegular()
+}
+
+// TarDir writes the contents of dir as an uncompressed tar to output, going
+// through output+".part" so an interrupted run never leaves a truncated file.
+func TarDir(ctx context.Context, dir, output string) error {
+	part := output + ".part"
+	file, err := os.Create(part)
+	if err != nil {
+		return err
+	}
+	tw := tar.NewWriter(file)
+	walkErr := filepath.WalkDir(dir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if path == dir {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		if entry.IsDir() {
+			header.Name += "/"
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		src, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		_, err = io.Copy(tw, src)
+		return err
+	})
+	err = errors.Join(walkErr, tw.Close(), file.Close())
+	if err != nil {
+		_ = os.Remove(part)
+		return err
+	}
+	return os.Rename(part, output)
+}
+
+// ExtractTar unpacks a tar into dir, refusing entries that escape it.
+func ExtractTar(ctx context.Context, archive, dir string) error {
+	file, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	tr := tar.NewReader(file)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name := filepath.Clean(filepath.FromSlash(header.Name))
+		if name == "." {
+			continue
+		}
+		if filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("镜像归档包含非法路径: %s", header.Name)
+		}
+		target := filepath.Join(dir, name)
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+			if err != nil {
+				return err
+			}
+			_, copyErr := io.Copy(out, tr)
+			if err := errors.Join(copyErr, out.Close()); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// TarDirTemp tars dir into a temporary file for APIs that only accept a tar
+// stream, such as docker load. The caller removes the returned file.
+func TarDirTemp(ctx context.Context, dir string) (string, error) {
+	file, err := os.CreateTemp("", "dm-oci-*.tar")
+	if err != nil {
+		return "", err
+	}
+	path := file.Name()
+	if err := file.Close(); err != nil {
+		_ = os.Remove(path)
+		return "", err
+	}
+	if err := TarDir(ctx, dir, path); err != nil {
+		_ = os.Remove(path)
+		return "", err
+	}
+	return path, nil
+}
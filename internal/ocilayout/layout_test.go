@@ -0,0 +1,117 @@
+package ocilayout
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	digest "github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestConvertDockerArchiveWritesLayoutWithSharedLayers(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "docker.tar")
+	writeTestTar(t, src, map[string]string{
+		"manifest.json": `[{"Config":"a.json","Layers":["l1/layer.tar","l2/layer.tar"],"RepoTags":["nginx:1.27"]},` +
+			`{"Config":"b.json","Layers":["l1/layer.tar"],"RepoTags":["registry.local/team/app:v1"]}]`,
+		"a.json":       `{"architecture":"amd64"}`,
+		"b.json":       `{"architecture":"arm64"}`,
+		"l1/layer.tar": "shared",
+		"l2/layer.tar": "only-a",
+		"repositories": "{}",
+	})
+
+	dst := filepath.Join(dir, "layout")
+	if err := ConvertDockerArchive(context.Background(), src, dst, false); err != nil {
+		t.Fatalf("ConvertDockerArchive() error = %v", err)
+	}
+	if !IsLayoutDir(dst) {
+		t.Fatal("oci-layout missing")
+	}
+	if _, err := os.Stat(filepath.Join(dst, "repositories")); !os.IsNotExist(err) {
+		t.Fatalf("legacy file kept: %v", err)
+	}
+	var index ocispec.Index
+	readJSON(t, filepath.Join(dst, IndexFile), &index)
+	if len(index.Manifests) != 2 {
+		t.Fatalf("index manifests = %d, want 2", len(index.Manifests))
+	}
+	if got := index.Manifests[0].Annotations[AnnotationImageName]; got != "docker.io/library/nginx:1.27" {
+		t.Fatalf("image name = %q", got)
+	}
+	if got := index.Manifests[1].Annotations[ocispec.AnnotationRefName]; got != "v1" {
+		t.Fatalf("ref name = %q", got)
+	}
+	var manifest ocispec.Manifest
+	readJSON(t, BlobPath(dst, index.Manifests[0].Digest), &manifest)
+	if len(manifest.Layers) != 2 || manifest.Layers[0].Digest != digest.FromString("shared") || manifest.Layers[0].MediaType != ocispec.MediaTypeImageLayer {
+		t.Fatalf("manifest layers = %#v", manifest.Layers)
+	}
+	if data, err := os.ReadFile(BlobPath(dst, digest.FromString("shared"))); err != nil || string(data) != "shared" {
+		t.Fatalf("shared blob = %q, %v", data, err)
+	}
+	var docker []DockerManifest
+	readJSON(t, filepath.Join(dst, DockerManifestFile), &docker)
+	if docker[1].Layers[0] != BlobRelPath(digest.FromString("shared")) {
+		t.Fatalf("docker manifest layers = %v", docker[1].Layers)
+	}
+
+	archive := filepath.Join(dir, "layout.tar")
+	if err := ConvertDockerArchive(context.Background(), src, archive, true); err != nil {
+		t.Fatalf("ConvertDockerArchive(archive) error = %v", err)
+	}
+	extracted := filepath.Join(dir, "extracted")
+	if err := ExtractTar(context.Background(), archive, extracted); err != nil {
+		t.Fatal(err)
+	}
+	if !IsLayoutDir(extracted) {
+		t.Fatal("archive is not an OCI layout")
+	}
+}
+
+func TestExtractTarRejectsEscapingPaths(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "evil.tar")
+	writeTestTar(t, src, map[string]string{"../evil": "x"})
+	if err := ExtractTar(context.Background(), src, filepath.Join(dir, "out")); err == nil {
+		t.Fatal("ExtractTar() error = nil, want path rejection")
+	}
+}
+
+func writeTestTar(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tw := tar.NewWriter(file)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readJSON(t *testing.T, path string, value any) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, value); err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+}
@@ -1,6 +1,7 @@
 package runconfig
 
 type ComposeFile struct {
+	Name     string                    `yaml:"name,omitempty"`
 	Services map[string]ComposeService `yaml:"services"`
 	Volumes  map[string]interface{}    `yaml:"volumes,omitempty"`
 	Networks map[string]interface{}    `yaml:"networks,omitempty"`
@@ -29,9 +30,110 @@ type ComposeService struct {
 	Entrypoint    []string              `yaml:"entrypoint,omitempty"`
 	WorkingDir    string                `yaml:"working_dir,omitempty"`
 	NetworkMode   string                `yaml:"network_mode,omitempty"`
+
+	Networks          map[string]ComposeServiceNetwork `yaml:"networks,omitempty"`
+	Hostname          string                           `yaml:"hostname,omitempty"`
+	Domainname        string                           `yaml:"domainname,omitempty"`
+	DNSOpt            []string                         `yaml:"dns_opt,omitempty"`
+	Expose            []string                         `yaml:"expose,omitempty"`
+	Links             []string                         `yaml:"external_links,omitempty"`
+	ServiceLinks      []string                         `yaml:"links,omitempty"`
+	DependsOn         map[string]ComposeDependsOn      `yaml:"depends_on,omitempty"`
+	VolumesFrom       []string                         `yaml:"volumes_from,omitempty"`
+	Tmpfs             []string                         `yaml:"tmpfs,omitempty"`
+	Tty               bool                             `yaml:"tty,omitempty"`
+	StdinOpen         bool                             `yaml:"stdin_open,omitempty"`
+	ReadOnly          bool                             `yaml:"read_only,omitempty"`
+	Init              *bool                            `yaml:"init,omitempty"`
+	Healthcheck       *ComposeHealthcheck              `yaml:"healthcheck,omitempty"`
+	StopSignal        string                           `yaml:"stop_signal,omitempty"`
+	StopGracePeriod   string                           `yaml:"stop_grace_period,omitempty"`
+	Runtime           string                           `yaml:"runtime,omitempty"`
+	Isolation         string                           `yaml:"isolation,omitempty"`
+	Pid               string                           `yaml:"pid,omitempty"`
+	Ipc               string                           `yaml:"ipc,omitempty"`
+	Uts               string                           `yaml:"uts,omitempty"`
+	UsernsMode        string                           `yaml:"userns_mode,omitempty"`
+	Cgroup            string                           `yaml:"cgroup,omitempty"`
+	CgroupParent      string                           `yaml:"cgroup_parent,omitempty"`
+	GroupAdd          []string                         `yaml:"group_add,omitempty"`
+	Sysctls           map[string]string                `yaml:"sysctls,omitempty"`
+	StorageOpt        map[string]string                `yaml:"storage_opt,omitempty"`
+	Annotations       map[string]string                `yaml:"annotations,omitempty"`
+	DeviceCgroupRules []string                         `yaml:"device_cgroup_rules,omitempty"`
+	ShmSize           string                           `yaml:"shm_size,omitempty"`
+	MemLimit          string                           `yaml:"mem_limit,omitempty"`
+	MemReservation    string                           `yaml:"mem_reservation,omitempty"`
+	MemSwapLimit      string                           `yaml:"memswap_limit,omitempty"`
+	MemSwappiness     *int64                           `yaml:"mem_swappiness,omitempty"`
+	OomKillDisable    bool                             `yaml:"oom_kill_disable,omitempty"`
+	OomScoreAdj       int                              `yaml:"oom_score_adj,omitempty"`
+	PidsLimit         int64                            `yaml:"pids_limit,omitempty"`
+	CPUs              float64                          `yaml:"cpus,omitempty"`
+	CPUShares         int64                            `yaml:"cpu_shares,omitempty"`
+	CPUPeriod         int64                            `yaml:"cpu_period,omitempty"`
+	CPUQuota          int64                            `yaml:"cpu_quota,omitempty"`
+	CPURTPeriod       int64                            `yaml:"cpu_rt_period,omitempty"`
+	CPURTRuntime      int64                            `yaml:"cpu_rt_runtime,omitempty"`
+	Cpuset            string                           `yaml:"cpuset,omitempty"`
+	CPUCount          int64                            `yaml:"cpu_count,omitempty"`
+	CPUPercent        int64                            `yaml:"cpu_percent,omitempty"`
+	BlkioConfig       *ComposeBlkioConfig              `yaml:"blkio_config,omitempty"`
+	Deploy            *ComposeDeploy                   `yaml:"deploy,omitempty"`
 }
 
 type ComposeLogging struct {
 	Driver  string            `yaml:"driver,omitempty"`
 	Options map[string]string `yaml:"options,omitempty"`
 }
+
+type ComposeServiceNetwork struct {
+	Aliases      []string          `yaml:"aliases,omitempty"`
+	IPv4Address  string            `yaml:"ipv4_address,omitempty"`
+	IPv6Address  string            `yaml:"ipv6_address,omitempty"`
+	LinkLocalIPs []string          `yaml:"link_local_ips,omitempty"`
+	DriverOpts   map[string]string `yaml:"driver_opts,omitempty"`
+	GwPriority   int               `yaml:"gw_priority,omitempty"`
+}
+
+type ComposeHealthcheck struct {
+	Test          []string `yaml:"test,omitempty"`
+	Interval      string   `yaml:"interval,omitempty"`
+	Timeout       string   `yaml:"timeout,omitempty"`
+	Retries       int      `yaml:"retries,omitempty"`
+	StartPeriod   string   `yaml:"start_period,omitempty"`
+	StartInterval string   `yaml:"start_interval,omitempty"`
+	Disable       bool     `yaml:"disable,omitempty"`
+}
+
+type ComposeBlkioConfig struct {
+	Weight          uint16            `yaml:"weight,omitempty"`
+	WeightDevice    []BlkioWeightSpec `yaml:"weight_device,omitempty"`
+	DeviceReadBps   []BlkioRateSpec   `yaml:"device_read_bps,omitempty"`
+	DeviceWriteBps  []BlkioRateSpec   `yaml:"device_write_bps,omitempty"`
+	DeviceReadIOps  []BlkioRateSpec   `yaml:"device_read_iops,omitempty"`
+	DeviceWriteIOps []BlkioRateSpec   `yaml:"device_write_iops,omitempty"`
+}
+
+// ComposeDependsOn 对应 depends_on 的长格式。
+type ComposeDependsOn struct {
+	Condition string `yaml:"condition"`
+	Restart   bool   `yaml:"restart,omitempty"`
+}
+
+type ComposeDeploy struct {
+	Replicas  *int `yaml:"replicas,omitempty"`
+	Resources struct {
+		Reservations struct {
+			Devices []ComposeDeviceRequest `yaml:"devices,omitempty"`
+		} `yaml:"reservations,omitempty"`
+	} `yaml:"resources,omitempty"`
+}
+
+type ComposeDeviceRequest struct {
+	Driver       string            `yaml:"driver,omitempty"`
+	Count        interface{}       `yaml:"count,omitempty"`
+	DeviceIDs    []string          `yaml:"device_ids,omitempty"`
+	Capabilities []string          `yaml:"capabilities,omitempty"`
+	Options      map[string]string `yaml:"options,omitempty"`
+}
@@ -0,0 +1,743 @@
+package runconfig
+
+import (
+	"encoding/csv"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/moby/moby/api/types/blkiodev"
+	"github.com/moby/moby/api/types/mount"
+)
+
+// ResourceSpec 汇总 cgroup 资源限制，零值表示未设置。
+type ResourceSpec struct {
+	Memory             int64
+	MemoryReservation  int64
+	MemorySwap         int64
+	MemorySwappiness   *int64
+	OomKillDisable     bool
+	OomScoreAdj        int
+	PidsLimit          int64
+	ShmSize            int64
+	NanoCPUs           int64
+	CPUShares          int64
+	CPUPeriod          int64
+	CPUQuota           int64
+	CPURealtimePeriod  int64
+	CPURealtimeRuntime int64
+	CpusetCpus         string
+	CpusetMems         string
+	CPUCount           int64
+	CPUPercent         int64
+	IOMaximumIOps      uint64
+	IOMaximumBandwidth uint64
+	BlkioWeight        uint16
+	BlkioWeightDevice  []BlkioWeightSpec
+	BlkioReadBps       []BlkioRateSpec
+	BlkioWriteBps      []BlkioRateSpec
+	BlkioReadIOps      []BlkioRateSpec
+	BlkioWriteIOps     []BlkioRateSpec
+}
+
+type BlkioWeightSpec struct {
+	Path   string `yaml:"path"`
+	Weight uint16 `yaml:"weight"`
+}
+
+type BlkioRateSpec struct {
+	Path string `yaml:"path"`
+	Rate uint64 `yaml:"rate"`
+}
+
+type HealthcheckSpec struct {
+	Test          []string
+	Interval      time.Duration
+	Timeout       time.Duration
+	StartPeriod   time.Duration
+	StartInterval time.Duration
+	Retries       int
+}
+
+// Disabled 对应 HEALTHCHECK NONE / --no-healthcheck。
+func (h *HealthcheckSpec) Disabled() bool {
+	return len(h.Test) > 0 && h.Test[0] == "NONE"
+}
+
+type DeviceRequestSpec struct {
+	Driver       string
+	Count        int
+	DeviceIDs    []string
+	Capabilities [][]string
+	Options      map[string]string
+}
+
+// NetworkEndpointSpec 描述容器在某个网络上的端点配置，只保留用户可指定的部分。
+type NetworkEndpointSpec struct {
+	Name         string
+	Aliases      []string
+	IPv4Address  string
+	IPv6Address  string
+	LinkLocalIPs []string
+	DriverOpts   map[string]string
+	GwPriority   int
+}
+
+func (e NetworkEndpointSpec) configured() bool {
+	return len(e.Aliases) > 0 || e.IPv4Address != "" || e.IPv6Address != "" || len(e.LinkLocalIPs) > 0 ||
+		len(e.DriverOpts) > 0 || e.GwPriority != 0
+}
+
+// Docker 默认 shm 大小，与默认值相同时不输出。
+const defaultShmSize = 64 << 20
+
+// -------------------- HostConfig 解析 --------------------
+
+func (p *Parser) defaultHostname() string {
+	if len(p.ci.ID) >= 12 {
+		return p.ci.ID[:12]
+	}
+	return ""
+}
+
+func (p *Parser) parseHostname() string {
+	mode := string(p.ci.HostConfig.NetworkMode)
+	if mode == "host" || strings.HasPrefix(mode, "container:") {
+		return ""
+	}
+	if p.ci.Config.Hostname == p.defaultHostname() {
+		return ""
+	}
+	return p.ci.Config.Hostname
+}
+
+func (p *Parser) parseExposedPorts() []string {
+	var ports []string
+	for port := range p.ci.Config.ExposedPorts {
+		if _, published := p.ci.HostConfig.PortBindings[port]; published {
+			continue
+		}
+		ports = append(ports, port.String())
+	}
+	sort.Strings(ports)
+	return ports
+}
+
+func (p *Parser) parseHealthcheck() *HealthcheckSpec {
+	hc := p.ci.Config.Healthcheck
+	if hc == nil || len(hc.Test) == 0 {
+		return nil
+	}
+	return &HealthcheckSpec{
+		Test:          copyStringSlice(hc.Test),
+		Interval:      hc.Interval,
+		Timeout:       hc.Timeout,
+		StartPeriod:   hc.StartPeriod,
+		StartInterval: hc.StartInterval,
+		Retries:       hc.Retries,
+	}
+}
+
+func (p *Parser) parseResources() ResourceSpec {
+	hc := p.ci.HostConfig
+	spec := ResourceSpec{
+		Memory:             hc.Memory,
+		MemoryReservation:  hc.MemoryReservation,
+		MemorySwap:         hc.MemorySwap,
+		OomScoreAdj:        hc.OomScoreAdj,
+		NanoCPUs:           hc.NanoCPUs,
+		CPUShares:          hc.CPUShares,
+		CPUPeriod:          hc.CPUPeriod,
+		CPUQuota:           hc.CPUQuota,
+		CPURealtimePeriod:  hc.CPURealtimePeriod,
+		CPURealtimeRuntime: hc.CPURealtimeRuntime,
+		CpusetCpus:         hc.CpusetCpus,
+		CpusetMems:         hc.CpusetMems,
+		CPUCount:           hc.CPUCount,
+		CPUPercent:         hc.CPUPercent,
+		IOMaximumIOps:      hc.IOMaximumIOps,
+		IOMaximumBandwidth: hc.IOMaximumBandwidth,
+		BlkioWeight:        hc.BlkioWeight,
+	}
+	if hc.MemorySwappiness != nil && *hc.MemorySwappiness >= 0 {
+		value := *hc.MemorySwappiness
+		spec.MemorySwappiness = &value
+	}
+	if hc.OomKillDisable != nil {
+		spec.OomKillDisable = *hc.OomKillDisable
+	}
+	if hc.PidsLimit != nil && *hc.PidsLimit > 0 {
+		spec.PidsLimit = *hc.PidsLimit
+	}
+	if hc.ShmSize != defaultShmSize {
+		spec.ShmSize = hc.ShmSize
+	}
+	for _, device := range hc.BlkioWeightDevice {
+		if device != nil {
+			spec.BlkioWeightDevice = append(spec.BlkioWeightDevice, BlkioWeightSpec{Path: device.Path, Weight: device.Weight})
+		}
+	}
+	spec.BlkioReadBps = blkioRates(hc.BlkioDeviceReadBps)
+	spec.BlkioWriteBps = blkioRates(hc.BlkioDeviceWriteBps)
+	spec.BlkioReadIOps = blkioRates(hc.BlkioDeviceReadIOps)
+	spec.BlkioWriteIOps = blkioRates(hc.BlkioDeviceWriteIOps)
+	return spec
+}
+
+func (p *Parser) parseDeviceRequests() []DeviceRequestSpec {
+	var requests []DeviceRequestSpec
+	for _, req := range p.ci.HostConfig.DeviceRequests {
+		capabilities := make([][]string, 0, len(req.Capabilities))
+		for _, set := range req.Capabilities {
+			capabilities = append(capabilities, copyStringSlice(set))
+		}
+		requests = append(requests, DeviceRequestSpec{
+			Driver:       req.Driver,
+			Count:        req.Count,
+			DeviceIDs:    copyStringSlice(req.DeviceIDs),
+			Capabilities: capabilities,
+			Options:      copyStringMap(req.Options),
+		})
+	}
+	return requests
+}
+
+// parseTmpfs 合并 --tmpfs 与 --mount type=tmpfs 两种来源。
+func (p *Parser) parseTmpfs() map[string]string {
+	tmpfs := copyStringMap(p.ci.HostConfig.Tmpfs)
+	for _, m := range p.ci.HostConfig.Mounts {
+		if m.Type != mount.TypeTmpfs || m.Target == "" {
+			continue
+		}
+		if tmpfs == nil {
+			tmpfs = map[string]string{}
+		}
+		if _, ok := tmpfs[m.Target]; ok {
+			continue
+		}
+		var opts []string
+		if m.ReadOnly {
+			opts = append(opts, "ro")
+		}
+		if m.TmpfsOptions != nil {
+			if m.TmpfsOptions.SizeBytes > 0 {
+				opts = append(opts, "size="+formatByteSize(m.TmpfsOptions.SizeBytes))
+			}
+			if m.TmpfsOptions.Mode != 0 {
+				opts = append(opts, fmt.Sprintf("mode=%o", m.TmpfsOptions.Mode.Perm()))
+			}
+		}
+		tmpfs[m.Target] = strings.Join(opts, ",")
+	}
+	return tmpfs
+}
+
+// parseLinks 将 "/db:/web/alias" 还原为 "db:alias"。
+func (p *Parser) parseLinks() []string {
+	var links []string
+	for _, link := range p.ci.HostConfig.Links {
+		source, target, ok := strings.Cut(link, ":")
+		if !ok {
+			continue
+		}
+		name := strings.TrimPrefix(source, "/")
+		alias := target[strings.LastIndex(target, "/")+1:]
+		if alias == "" || alias == name {
+			links = append(links, name)
+		} else {
+			links = append(links, name+":"+alias)
+		}
+	}
+	return links
+}
+
+func (p *Parser) parseNetworks() []NetworkEndpointSpec {
+	mode := string(p.ci.HostConfig.NetworkMode)
+	if p.ci.NetworkSettings == nil || mode == "host" || mode == "none" || strings.HasPrefix(mode, "container:") {
+		return nil
+	}
+	if mode == "" || mode == "default" {
+		mode = "bridge"
+	}
+	names := make([]string, 0, len(p.ci.NetworkSettings.Networks))
+	for name := range p.ci.NetworkSettings.Networks {
+		names = append(names, name)
+	}
+	// 主网络排在首位，其余按名称排序
+	sort.Slice(names, func(i, j int) bool {
+		if (names[i] == mode) != (names[j] == mode) {
+			return names[i] == mode
+		}
+		return names[i] < names[j]
+	})
+
+	implicit := map[string]bool{strings.TrimPrefix(p.ci.Name, "/"): true, p.defaultHostname(): true}
+	var endpoints []NetworkEndpointSpec
+	for _, name := range names {
+		settings := p.ci.NetworkSettings.Networks[name]
+		if settings == nil {
+			continue
+		}
+		endpoint := NetworkEndpointSpec{
+			Name:       name,
+			DriverOpts: copyStringMap(settings.DriverOpts),
+			GwPriority: settings.GwPriority,
+		}
+		for _, alias := range settings.Aliases {
+			if !implicit[alias] {
+				endpoint.Aliases = append(endpoint.Aliases, alias)
+			}
+		}
+		if ipam := settings.IPAMConfig; ipam != nil {
+			endpoint.IPv4Address = addrString(ipam.IPv4Address)
+			endpoint.IPv6Address = addrString(ipam.IPv6Address)
+			endpoint.LinkLocalIPs = addrSliceToStrings(ipam.LinkLocalIPs)
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	if len(endpoints) == 1 && !endpoints[0].configured() {
+		return nil
+	}
+	return endpoints
+}
+
+// parseUnsupported 列出 docker run 与 Compose 均无法表达的配置。
+func (p *Parser) parseUnsupported() []string {
+	var warnings []string
+	if cgroup := string(p.ci.HostConfig.Cgroup); cgroup != "" {
+		warnings = append(warnings, fmt.Sprintf("HostConfig.Cgroup=%s: 无对应的 docker run 参数或 Compose 字段", cgroup))
+	}
+	if p.ci.Config.NetworkDisabled && p.ci.HostConfig.NetworkMode != "none" {
+		warnings = append(warnings, "Config.NetworkDisabled: 仅能通过 API 设置")
+	}
+	for _, m := range p.ci.Mounts {
+		switch m.Type {
+		case mount.TypeVolume, mount.TypeBind, mount.TypeTmpfs:
+		default:
+			warnings = append(warnings, fmt.Sprintf("挂载 %s (type=%s): 无法还原", m.Destination, m.Type))
+		}
+	}
+	return warnings
+}
+
+func blkioRates(devices []*blkiodev.ThrottleDevice) []BlkioRateSpec {
+	var rates []BlkioRateSpec
+	for _, device := range devices {
+		if device != nil {
+			rates = append(rates, BlkioRateSpec{Path: device.Path, Rate: device.Rate})
+		}
+	}
+	return rates
+}
+
+// -------------------- HostConfig 格式化 --------------------
+
+// formatByteSize 优先使用 docker 可识别的 g/m/k 后缀。
+func formatByteSize(n int64) string {
+	switch {
+	case n == 0 || n < 0:
+		return strconv.FormatInt(n, 10)
+	case n%(1<<30) == 0:
+		return fmt.Sprintf("%dg", n>>30)
+	case n%(1<<20) == 0:
+		return fmt.Sprintf("%dm", n>>20)
+	case n%(1<<10) == 0:
+		return fmt.Sprintf("%dk", n>>10)
+	default:
+		return strconv.FormatInt(n, 10)
+	}
+}
+
+func nanoCPUs(n int64) float64 {
+	return float64(n) / 1e9
+}
+
+func formatTmpfs(tmpfs map[string]string) []string {
+	var result []string
+	for _, path := range sortedKeys(tmpfs) {
+		if opts := tmpfs[path]; opts != "" {
+			result = append(result, path+":"+opts)
+		} else {
+			result = append(result, path)
+		}
+	}
+	return result
+}
+
+// commandResourceArgs 生成资源限制相关的 docker run 参数。
+func commandResourceArgs(r ResourceSpec) [][2]string {
+	var args [][2]string
+	add := func(flag, value string) { args = append(args, [2]string{flag, value}) }
+	addInt := func(flag string, value int64) {
+		if value != 0 {
+			add(flag, strconv.FormatInt(value, 10))
+		}
+	}
+	if r.Memory > 0 {
+		add("--memory", formatByteSize(r.Memory))
+	}
+	if r.MemoryReservation > 0 {
+		add("--memory-reservation", formatByteSize(r.MemoryReservation))
+	}
+	if r.MemorySwap != 0 {
+		add("--memory-swap", formatByteSize(r.MemorySwap))
+	}
+	if r.MemorySwappiness != nil {
+		add("--memory-swappiness", strconv.FormatInt(*r.MemorySwappiness, 10))
+	}
+	if r.OomKillDisable {
+		add("--oom-kill-disable", "")
+	}
+	addInt("--oom-score-adj", int64(r.OomScoreAdj))
+	addInt("--pids-limit", r.PidsLimit)
+	if r.ShmSize > 0 {
+		add("--shm-size", formatByteSize(r.ShmSize))
+	}
+	if r.NanoCPUs > 0 {
+		add("--cpus", strconv.FormatFloat(nanoCPUs(r.NanoCPUs), 'f', -1, 64))
+	}
+	addInt("--cpu-shares", r.CPUShares)
+	addInt("--cpu-period", r.CPUPeriod)
+	addInt("--cpu-quota", r.CPUQuota)
+	addInt("--cpu-rt-period", r.CPURealtimePeriod)
+	addInt("--cpu-rt-runtime", r.CPURealtimeRuntime)
+	if r.CpusetCpus != "" {
+		add("--cpuset-cpus", r.CpusetCpus)
+	}
+	if r.CpusetMems != "" {
+		add("--cpuset-mems", r.CpusetMems)
+	}
+	addInt("--cpu-count", r.CPUCount)
+	addInt("--cpu-percent", r.CPUPercent)
+	if r.IOMaximumIOps > 0 {
+		add("--io-maxiops", strconv.FormatUint(r.IOMaximumIOps, 10))
+	}
+	if r.IOMaximumBandwidth > 0 {
+		add("--io-maxbandwidth", strconv.FormatUint(r.IOMaximumBandwidth, 10))
+	}
+	addInt("--blkio-weight", int64(r.BlkioWeight))
+	for _, device := range r.BlkioWeightDevice {
+		add("--blkio-weight-device", fmt.Sprintf("%s:%d", device.Path, device.Weight))
+	}
+	for _, item := range []struct {
+		flag  string
+		rates []BlkioRateSpec
+	}{
+		{"--device-read-bps", r.BlkioReadBps},
+		{"--device-write-bps", r.BlkioWriteBps},
+		{"--device-read-iops", r.BlkioReadIOps},
+		{"--device-write-iops", r.BlkioWriteIOps},
+	} {
+		for _, rate := range item.rates {
+			add(item.flag, fmt.Sprintf("%s:%d", rate.Path, rate.Rate))
+		}
+	}
+	return args
+}
+
+// healthcheckArgs 生成 --health-* 参数；exec 形式只能退化为 shell 形式。
+func healthcheckArgs(h *HealthcheckSpec) [][2]string {
+	if h == nil {
+		return nil
+	}
+	if h.Disabled() {
+		return [][2]string{{"--no-healthcheck", ""}}
+	}
+	var args [][2]string
+	if h.Test[0] == "CMD" || h.Test[0] == "CMD-SHELL" {
+		args = append(args, [2]string{"--health-cmd", strings.Join(h.Test[1:], " ")})
+	}
+	for _, item := range []struct {
+		flag  string
+		value time.Duration
+	}{
+		{"--health-interval", h.Interval},
+		{"--health-timeout", h.Timeout},
+		{"--health-start-period", h.StartPeriod},
+		{"--health-start-interval", h.StartInterval},
+	} {
+		if item.value > 0 {
+			args = append(args, [2]string{item.flag, item.value.String()})
+		}
+	}
+	if h.Retries > 0 {
+		args = append(args, [2]string{"--health-retries", strconv.Itoa(h.Retries)})
+	}
+	return args
+}
+
+func isGPURequest(req DeviceRequestSpec) bool {
+	for _, set := range req.Capabilities {
+		for _, capability := range set {
+			if capability == "gpu" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// gpusValue 还原 --gpus 参数值，例如 all 或 "device=0,1",capabilities=compute。
+func gpusValue(req DeviceRequestSpec) string {
+	var capabilities []string
+	if len(req.Capabilities) > 0 {
+		for _, capability := range req.Capabilities[0] {
+			if capability != "gpu" {
+				capabilities = append(capabilities, capability)
+			}
+		}
+	}
+	if req.Count == -1 && len(req.DeviceIDs) == 0 && len(capabilities) == 0 && (req.Driver == "" || req.Driver == "nvidia") {
+		return "all"
+	}
+	var fields []string
+	if req.Driver != "" && req.Driver != "nvidia" {
+		fields = append(fields, "driver="+req.Driver)
+	}
+	switch {
+	case len(req.DeviceIDs) > 0:
+		fields = append(fields, "device="+strings.Join(req.DeviceIDs, ","))
+	case req.Count == -1:
+		fields = append(fields, "count=all")
+	case req.Count > 0:
+		fields = append(fields, "count="+strconv.Itoa(req.Count))
+	}
+	if len(capabilities) > 0 {
+		fields = append(fields, "capabilities="+strings.Join(capabilities, ","))
+	}
+	for _, key := range sortedKeys(req.Options) {
+		fields = append(fields, key+"="+req.Options[key])
+	}
+	return csvJoin(fields)
+}
+
+func isUserDefinedNetwork(name string) bool {
+	switch name {
+	case "", "default", "bridge", "host", "none":
+		return false
+	}
+	return !strings.HasPrefix(name, "container:")
+}
+
+// commandNetworkArgs 单个网络沿用 --network-alias/--ip 等传统参数，
+// 多网络或带驱动选项时改用 --network name=...,alias=... 高级语法。
+func commandNetworkArgs(spec *ContainerSpec) ([]string, []string) {
+	var args, warnings []string
+	if len(spec.Networks) == 0 || !isUserDefinedNetwork(spec.Networks[0].Name) {
+		if spec.NetworkMode != "" && spec.NetworkMode != "default" {
+			args = append(args, "--network", spec.NetworkMode)
+		}
+		for i := 1; i < len(spec.Networks); i++ {
+			warnings = append(warnings, fmt.Sprintf("网络 %s: docker run 无法同时连接默认 bridge 与自定义网络，需在创建后执行 docker network connect", spec.Networks[i].Name))
+		}
+		return args, warnings
+	}
+	primary := spec.Networks[0]
+	if len(spec.Networks) == 1 && len(primary.DriverOpts) == 0 && primary.GwPriority == 0 {
+		args = append(args, "--network", primary.Name)
+		for _, alias := range primary.Aliases {
+			args = append(args, "--network-alias", alias)
+		}
+		if primary.IPv4Address != "" {
+			args = append(args, "--ip", primary.IPv4Address)
+		}
+		if primary.IPv6Address != "" {
+			args = append(args, "--ip6", primary.IPv6Address)
+		}
+		for _, ip := range primary.LinkLocalIPs {
+			args = append(args, "--link-local-ip", ip)
+		}
+		return args, nil
+	}
+	for _, endpoint := range spec.Networks {
+		fields := []string{"name=" + endpoint.Name}
+		for _, alias := range endpoint.Aliases {
+			fields = append(fields, "alias="+alias)
+		}
+		if endpoint.IPv4Address != "" {
+			fields = append(fields, "ip="+endpoint.IPv4Address)
+		}
+		if endpoint.IPv6Address != "" {
+			fields = append(fields, "ip6="+endpoint.IPv6Address)
+		}
+		for _, ip := range endpoint.LinkLocalIPs {
+			fields = append(fields, "link-local-ip="+ip)
+		}
+		for _, key := range sortedKeys(endpoint.DriverOpts) {
+			fields = append(fields, "driver-opt="+key+"="+endpoint.DriverOpts[key])
+		}
+		if endpoint.GwPriority != 0 {
+			fields = append(fields, "gw-priority="+strconv.Itoa(endpoint.GwPriority))
+		}
+		args = append(args, "--network", csvJoin(fields))
+	}
+	return args, nil
+}
+
+// csvJoin 按 docker CLI 的 CSV 规则拼接字段，含逗号的字段加引号。
+func csvJoin(fields []string) string {
+	var sb strings.Builder
+	w := csv.NewWriter(&sb)
+	_ = w.Write(fields)
+	w.Flush()
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// -------------------- Compose 映射 --------------------
+
+func composeHealthcheck(h *HealthcheckSpec) *ComposeHealthcheck {
+	if h == nil {
+		return nil
+	}
+	if h.Disabled() {
+		return &ComposeHealthcheck{Disable: true}
+	}
+	hc := &ComposeHealthcheck{Test: h.Test, Retries: h.Retries}
+	for _, item := range []struct {
+		dst   *string
+		value time.Duration
+	}{
+		{&hc.Interval, h.Interval},
+		{&hc.Timeout, h.Timeout},
+		{&hc.StartPeriod, h.StartPeriod},
+		{&hc.StartInterval, h.StartInterval},
+	} {
+		if item.value > 0 {
+			*item.dst = item.value.String()
+		}
+	}
+	return hc
+}
+
+func composeBlkio(r ResourceSpec) *ComposeBlkioConfig {
+	blkio := &ComposeBlkioConfig{
+		Weight:          r.BlkioWeight,
+		WeightDevice:    r.BlkioWeightDevice,
+		DeviceReadBps:   r.BlkioReadBps,
+		DeviceWriteBps:  r.BlkioWriteBps,
+		DeviceReadIOps:  r.BlkioReadIOps,
+		DeviceWriteIOps: r.BlkioWriteIOps,
+	}
+	if blkio.Weight == 0 && len(blkio.WeightDevice) == 0 && len(blkio.DeviceReadBps) == 0 && len(blkio.DeviceWriteBps) == 0 &&
+		len(blkio.DeviceReadIOps) == 0 && len(blkio.DeviceWriteIOps) == 0 {
+		return nil
+	}
+	return blkio
+}
+
+func composeDeploy(requests []DeviceRequestSpec) *ComposeDeploy {
+	if len(requests) == 0 {
+		return nil
+	}
+	devices := make([]ComposeDeviceRequest, 0, len(requests))
+	for _, req := range requests {
+		device := ComposeDeviceRequest{
+			Driver:    req.Driver,
+			DeviceIDs: req.DeviceIDs,
+			Options:   req.Options,
+		}
+		if req.Count == -1 {
+			device.Count = "all"
+		} else if req.Count > 0 {
+			device.Count = req.Count
+		}
+		if len(req.Capabilities) > 0 {
+			device.Capabilities = req.Capabilities[0]
+		}
+		devices = append(devices, device)
+	}
+	deploy := &ComposeDeploy{}
+	deploy.Resources.Reservations.Devices = devices
+	return deploy
+}
+
+// composeNetworks 返回 service 级 networks；主网络为默认 bridge 时仍使用 network_mode。
+func composeNetworks(spec *ContainerSpec) (map[string]ComposeServiceNetwork, string, []string) {
+	if len(spec.Networks) == 0 || !isUserDefinedNetwork(spec.Networks[0].Name) {
+		var warnings []string
+		for i := 1; i < len(spec.Networks); i++ {
+			warnings = append(warnings, fmt.Sprintf("网络 %s: Compose 中 network_mode 与 networks 互斥，仅保留 network_mode=%s", spec.Networks[i].Name, spec.NetworkMode))
+		}
+		return nil, spec.NetworkMode, warnings
+	}
+	networks := make(map[string]ComposeServiceNetwork, len(spec.Networks))
+	for _, endpoint := range spec.Networks {
+		networks[endpoint.Name] = ComposeServiceNetwork{
+			Aliases:      endpoint.Aliases,
+			IPv4Address:  endpoint.IPv4Address,
+			IPv6Address:  endpoint.IPv6Address,
+			LinkLocalIPs: endpoint.LinkLocalIPs,
+			DriverOpts:   endpoint.DriverOpts,
+			GwPriority:   endpoint.GwPriority,
+		}
+	}
+	return networks, "", nil
+}
+
+func composeVolumesFrom(volumesFrom []string) []string {
+	var result []string
+	for _, source := range volumesFrom {
+		result = append(result, "container:"+source)
+	}
+	return result
+}
+
+func composeStopGracePeriod(timeout *int) string {
+	if timeout == nil {
+		return ""
+	}
+	return fmt.Sprintf("%ds", *timeout)
+}
+
+// -------------------- 不可复现项 --------------------
+
+// Warnings 返回 docker run 无法表达的配置。
+func (f CommandFormatter) Warnings(spec *ContainerSpec) []string {
+	warnings := copyStringSlice(spec.Unsupported)
+	_, networkWarnings := commandNetworkArgs(spec)
+	warnings = append(warnings, networkWarnings...)
+	if h := spec.Healthcheck; h != nil && !h.Disabled() && h.Test[0] == "CMD" {
+		warnings = append(warnings, "HEALTHCHECK CMD: --health-cmd 只能以 shell 形式 (CMD-SHELL) 执行")
+	}
+	for _, req := range spec.DeviceRequests {
+		if !isGPURequest(req) {
+			warnings = append(warnings, fmt.Sprintf("DeviceRequest driver=%s: --gpus 仅支持 GPU 设备请求", req.Driver))
+		}
+	}
+	return warnings
+}
+
+// Warnings 返回 Compose 无法表达的配置。
+func (f ComposeFormatter) Warnings(spec *ContainerSpec) []string {
+	warnings := copyStringSlice(spec.Unsupported)
+	_, _, networkWarnings := composeNetworks(spec)
+	warnings = append(warnings, networkWarnings...)
+	for _, item := range []struct {
+		set  bool
+		name string
+	}{
+		{spec.AutoRemove, "AutoRemove (--rm)"},
+		{spec.PublishAllPorts, "PublishAllPorts (-P)"},
+		{spec.ContainerIDFile != "", "ContainerIDFile (--cidfile)"},
+		{spec.VolumeDriver != "", "VolumeDriver (--volume-driver)"},
+		{spec.Resources.CpusetMems != "", "CpusetMems (--cpuset-mems)"},
+		{spec.Resources.IOMaximumIOps > 0, "IOMaximumIOps (--io-maxiops)"},
+		{spec.Resources.IOMaximumBandwidth > 0, "IOMaximumBandwidth (--io-maxbandwidth)"},
+	} {
+		if item.set {
+			warnings = append(warnings, item.name+": Compose 不支持")
+		}
+	}
+	return warnings
+}
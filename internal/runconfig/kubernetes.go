@@ -0,0 +1,771 @@
+package runconfig
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"docker-manager/internal/sensitive"
+)
+
+// -------------------- Kubernetes 清单结构 --------------------
+
+// K8sObject 是单个 Kubernetes 资源，按 kind 只填充对应字段。
+type K8sObject struct {
+	APIVersion string            `yaml:"apiVersion"`
+	Kind       string            `yaml:"kind"`
+	Metadata   K8sMeta           `yaml:"metadata"`
+	Type       string            `yaml:"type,omitempty"`
+	Data       map[string]string `yaml:"data,omitempty"`
+	StringData map[string]string `yaml:"stringData,omitempty"`
+	Spec       interface{}       `yaml:"spec,omitempty"`
+}
+
+type K8sMeta struct {
+	Name        string            `yaml:"name,omitempty"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+type K8sWorkloadSpec struct {
+	Replicas    int              `yaml:"replicas"`
+	ServiceName string           `yaml:"serviceName,omitempty"`
+	Selector    K8sLabelSelector `yaml:"selector"`
+	Template    K8sPodTemplate   `yaml:"template"`
+}
+
+type K8sLabelSelector struct {
+	MatchLabels map[string]string `yaml:"matchLabels"`
+}
+
+type K8sPodTemplate struct {
+	Metadata K8sMeta    `yaml:"metadata"`
+	Spec     K8sPodSpec `yaml:"spec"`
+}
+
+type K8sPodSpec struct {
+	Hostname                      string                 `yaml:"hostname,omitempty"`
+	HostNetwork                   bool                   `yaml:"hostNetwork,omitempty"`
+	HostPID                       bool                   `yaml:"hostPID,omitempty"`
+	HostIPC                       bool                   `yaml:"hostIPC,omitempty"`
+	DNSPolicy                     string                 `yaml:"dnsPolicy,omitempty"`
+	DNSConfig                     *K8sDNSConfig          `yaml:"dnsConfig,omitempty"`
+	HostAliases                   []K8sHostAlias         `yaml:"hostAliases,omitempty"`
+	RuntimeClassName              string                 `yaml:"runtimeClassName,omitempty"`
+	TerminationGracePeriodSeconds *int                   `yaml:"terminationGracePeriodSeconds,omitempty"`
+	SecurityContext               *K8sPodSecurityContext `yaml:"securityContext,omitempty"`
+	Containers                    []K8sContainer         `yaml:"containers"`
+	Volumes                       []K8sVolume            `yaml:"volumes,omitempty"`
+}
+
+type K8sDNSConfig struct {
+	Nameservers []string          `yaml:"nameservers,omitempty"`
+	Searches    []string          `yaml:"searches,omitempty"`
+	Options     []K8sDNSConfigOpt `yaml:"options,omitempty"`
+}
+
+type K8sDNSConfigOpt struct {
+	Name  string `yaml:"name"`
+	Value string `yaml:"value,omitempty"`
+}
+
+type K8sHostAlias struct {
+	IP        string   `yaml:"ip"`
+	Hostnames []string `yaml:"hostnames"`
+}
+
+type K8sPodSecurityContext struct {
+	SupplementalGroups []int64        `yaml:"supplementalGroups,omitempty"`
+	Sysctls            []K8sNameValue `yaml:"sysctls,omitempty"`
+}
+
+type K8sNameValue struct {
+	Name  string `yaml:"name"`
+	Value string `yaml:"value"`
+}
+
+type K8sContainer struct {
+	Name            string              `yaml:"name"`
+	Image           string              `yaml:"image"`
+	Command         []string            `yaml:"command,omitempty"`
+	Args            []string            `yaml:"args,omitempty"`
+	WorkingDir      string              `yaml:"workingDir,omitempty"`
+	Ports           []K8sContainerPort  `yaml:"ports,omitempty"`
+	EnvFrom         []K8sEnvFromSource  `yaml:"envFrom,omitempty"`
+	Resources       *K8sResources       `yaml:"resources,omitempty"`
+	VolumeMounts    []K8sVolumeMount    `yaml:"volumeMounts,omitempty"`
+	LivenessProbe   *K8sProbe           `yaml:"livenessProbe,omitempty"`
+	ReadinessProbe  *K8sProbe           `yaml:"readinessProbe,omitempty"`
+	SecurityContext *K8sSecurityContext `yaml:"securityContext,omitempty"`
+	TTY             bool                `yaml:"tty,omitempty"`
+	Stdin           bool                `yaml:"stdin,omitempty"`
+}
+
+type K8sContainerPort struct {
+	ContainerPort int    `yaml:"containerPort"`
+	Protocol      string `yaml:"protocol,omitempty"`
+}
+
+type K8sEnvFromSource struct {
+	ConfigMapRef *K8sLocalRef `yaml:"configMapRef,omitempty"`
+	SecretRef    *K8sLocalRef `yaml:"secretRef,omitempty"`
+}
+
+type K8sLocalRef struct {
+	Name string `yaml:"name"`
+}
+
+type K8sResources struct {
+	Limits   map[string]string `yaml:"limits,omitempty"`
+	Requests map[string]string `yaml:"requests,omitempty"`
+}
+
+type K8sVolumeMount struct {
+	Name      string `yaml:"name"`
+	MountPath string `yaml:"mountPath"`
+	ReadOnly  bool   `yaml:"readOnly,omitempty"`
+}
+
+type K8sProbe struct {
+	Exec                K8sExecAction `yaml:"exec"`
+	InitialDelaySeconds int           `yaml:"initialDelaySeconds,omitempty"`
+	PeriodSeconds       int           `yaml:"periodSeconds,omitempty"`
+	TimeoutSeconds      int           `yaml:"timeoutSeconds,omitempty"`
+	FailureThreshold    int           `yaml:"failureThreshold,omitempty"`
+}
+
+type K8sExecAction struct {
+	Command []string `yaml:"command"`
+}
+
+type K8sSecurityContext struct {
+	Privileged             *bool            `yaml:"privileged,omitempty"`
+	RunAsUser              *int64           `yaml:"runAsUser,omitempty"`
+	RunAsGroup             *int64           `yaml:"runAsGroup,omitempty"`
+	ReadOnlyRootFilesystem *bool            `yaml:"readOnlyRootFilesystem,omitempty"`
+	Capabilities           *K8sCapabilities `yaml:"capabilities,omitempty"`
+}
+
+type K8sCapabilities struct {
+	Add  []string `yaml:"add,omitempty"`
+	Drop []string `yaml:"drop,omitempty"`
+}
+
+type K8sVolume struct {
+	Name                  string              `yaml:"name"`
+	PersistentVolumeClaim *K8sPVCVolumeSource `yaml:"persistentVolumeClaim,omitempty"`
+	HostPath              *K8sHostPathSource  `yaml:"hostPath,omitempty"`
+	EmptyDir              *K8sEmptyDirSource  `yaml:"emptyDir,omitempty"`
+}
+
+type K8sPVCVolumeSource struct {
+	ClaimName string `yaml:"claimName"`
+}
+
+type K8sHostPathSource struct {
+	Path string `yaml:"path"`
+}
+
+type K8sEmptyDirSource struct {
+	Medium    string `yaml:"medium,omitempty"`
+	SizeLimit string `yaml:"sizeLimit,omitempty"`
+}
+
+type K8sServiceSpec struct {
+	Type      string            `yaml:"type,omitempty"`
+	ClusterIP string            `yaml:"clusterIP,omitempty"`
+	Selector  map[string]string `yaml:"selector"`
+	Ports     []K8sServicePort  `yaml:"ports,omitempty"`
+}
+
+type K8sServicePort struct {
+	Name       string `yaml:"name"`
+	Protocol   string `yaml:"protocol"`
+	Port       int    `yaml:"port"`
+	TargetPort int    `yaml:"targetPort"`
+}
+
+type K8sPVCSpec struct {
+	AccessModes []string     `yaml:"accessModes"`
+	Resources   K8sResources `yaml:"resources"`
+}
+
+// -------------------- K8sFormatter --------------------
+
+// K8sDefaultVolumeSize 是 PVC 的默认申请容量，Docker 卷本身没有容量概念。
+const K8sDefaultVolumeSize = "1Gi"
+
+// K8sFormatter 将容器配置映射为 Kubernetes 清单：ConfigMap、Secret、PVC、Deployment/StatefulSet 和 Service。
+type K8sFormatter struct{}
+
+type k8sMount struct {
+	kind   string // volume | bind | anonymous
+	source string
+	target string
+	ro     bool
+}
+
+func (f K8sFormatter) Format(spec *ContainerSpec, opts ReverseOptions) []K8sObject {
+	name := K8sWorkloadName(spec.ContainerName)
+	selector := map[string]string{"app": name}
+	container := K8sContainer{
+		Name:       name,
+		Image:      spec.Image,
+		Command:    spec.Entrypoint,
+		Args:       spec.Cmd,
+		WorkingDir: spec.WorkingDir,
+		Ports:      k8sContainerPorts(spec),
+		Resources:  k8sResources(spec),
+		TTY:        spec.Tty,
+		Stdin:      spec.OpenStdin,
+	}
+	container.LivenessProbe = k8sProbe(spec.Healthcheck)
+	container.ReadinessProbe = k8sProbe(spec.Healthcheck)
+	container.SecurityContext = k8sSecurityContext(spec)
+
+	var objects []K8sObject
+	plain, secret := splitSensitiveEnvs(spec.Envs, opts)
+	if len(plain) > 0 {
+		objects = append(objects, K8sObject{APIVersion: "v1", Kind: "ConfigMap", Metadata: K8sMeta{Name: name + "-env", Labels: selector}, Data: plain})
+		container.EnvFrom = append(container.EnvFrom, K8sEnvFromSource{ConfigMapRef: &K8sLocalRef{Name: name + "-env"}})
+	}
+	if len(secret) > 0 {
+		objects = append(objects, K8sObject{APIVersion: "v1", Kind: "Secret", Metadata: K8sMeta{Name: name + "-secret", Labels: selector}, Type: "Opaque", StringData: secret})
+		container.EnvFrom = append(container.EnvFrom, K8sEnvFromSource{SecretRef: &K8sLocalRef{Name: name + "-secret"}})
+	}
+
+	pod := K8sPodSpec{
+		Hostname:                      k8sHostname(spec.Hostname),
+		HostNetwork:                   spec.NetworkMode == "host",
+		HostPID:                       spec.PidMode == "host",
+		HostIPC:                       spec.IpcMode == "host",
+		HostAliases:                   k8sHostAliases(spec.ExtraHosts),
+		TerminationGracePeriodSeconds: spec.StopTimeout,
+		SecurityContext:               k8sPodSecurityContext(spec),
+	}
+	if spec.Runtime != "" {
+		pod.RuntimeClassName = spec.Runtime
+	}
+	if len(spec.DNS) > 0 || len(spec.DNSSearch) > 0 || len(spec.DNSOptions) > 0 {
+		pod.DNSConfig = &K8sDNSConfig{Nameservers: spec.DNS, Searches: spec.DNSSearch, Options: k8sDNSOptions(spec.DNSOptions)}
+		if len(spec.DNS) > 0 {
+			pod.DNSPolicy = "None"
+		}
+	}
+
+	stateful := false
+	// 同一命名卷挂载到多个路径时只生成一个 PVC 和一个 Pod volume，由多个 volumeMounts 引用
+	seen := map[string]bool{}
+	for i, m := range k8sMounts(spec.Mounts) {
+		volume := K8sVolume{}
+		switch m.kind {
+		case "volume":
+			stateful = true
+			volume.Name = k8sName(m.source)
+			if seen[volume.Name] {
+				container.VolumeMounts = append(container.VolumeMounts, K8sVolumeMount{Name: volume.Name, MountPath: m.target, ReadOnly: m.ro})
+				continue
+			}
+			seen[volume.Name] = true
+			volume.PersistentVolumeClaim = &K8sPVCVolumeSource{ClaimName: volume.Name}
+			objects = append(objects, K8sObject{
+				APIVersion: "v1",
+				Kind:       "PersistentVolumeClaim",
+				Metadata:   K8sMeta{Name: volume.Name, Labels: selector},
+				Spec: K8sPVCSpec{
+					AccessModes: []string{"ReadWriteOnce"},
+					Resources:   K8sResources{Requests: map[string]string{"storage": K8sDefaultVolumeSize}},
+				},
+			})
+		case "bind":
+			volume.Name = fmt.Sprintf("host-%d", i+1)
+			volume.HostPath = &K8sHostPathSource{Path: m.source}
+		default:
+			volume.Name = fmt.Sprintf("data-%d", i+1)
+			volume.EmptyDir = &K8sEmptyDirSource{}
+		}
+		pod.Volumes = append(pod.Volumes, volume)
+		container.VolumeMounts = append(container.VolumeMounts, K8sVolumeMount{Name: volume.Name, MountPath: m.target, ReadOnly: m.ro})
+	}
+	for i, target := range sortedKeys(spec.Tmpfs) {
+		volume := K8sVolume{Name: fmt.Sprintf("tmpfs-%d", i+1), EmptyDir: &K8sEmptyDirSource{Medium: "Memory", SizeLimit: tmpfsSizeLimit(spec.Tmpfs[target])}}
+		pod.Volumes = append(pod.Volumes, volume)
+		container.VolumeMounts = append(container.VolumeMounts, K8sVolumeMount{Name: volume.Name, MountPath: target})
+	}
+	if shm := spec.Resources.ShmSize; shm > 0 && shm != 64<<20 {
+		pod.Volumes = append(pod.Volumes, K8sVolume{Name: "dshm", EmptyDir: &K8sEmptyDirSource{Medium: "Memory", SizeLimit: k8sQuantity(shm)}})
+		container.VolumeMounts = append(container.VolumeMounts, K8sVolumeMount{Name: "dshm", MountPath: "/dev/shm"})
+	}
+	pod.Containers = []K8sContainer{container}
+
+	annotations := copyStringMap(spec.Labels)
+	for key, value := range spec.Annotations {
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[key] = value
+	}
+	workload := K8sObject{
+		APIVersion: "apps/v1",
+		Kind:       "Deployment",
+		Metadata:   K8sMeta{Name: name, Labels: selector},
+	}
+	workloadSpec := K8sWorkloadSpec{
+		Replicas: 1,
+		Selector: K8sLabelSelector{MatchLabels: selector},
+		Template: K8sPodTemplate{Metadata: K8sMeta{Labels: selector, Annotations: annotations}, Spec: pod},
+	}
+	// 有命名卷时使用 StatefulSet，保证重建后仍挂载同一个 PVC
+	if stateful {
+		workload.Kind = "StatefulSet"
+		workloadSpec.ServiceName = name
+	}
+	workload.Spec = workloadSpec
+	objects = append(objects, workload)
+
+	if ports := k8sServicePorts(spec.PortBindings); len(ports) > 0 {
+		objects = append(objects, K8sObject{APIVersion: "v1", Kind: "Service", Metadata: K8sMeta{Name: name, Labels: selector}, Spec: K8sServiceSpec{Selector: selector, Ports: ports}})
+	} else if stateful {
+		// StatefulSet 需要 serviceName 指向的 headless Service
+		objects = append(objects, K8sObject{APIVersion: "v1", Kind: "Service", Metadata: K8sMeta{Name: name, Labels: selector}, Spec: K8sServiceSpec{ClusterIP: "None", Selector: selector}})
+	}
+	return objects
+}
+
+// Warnings 返回 Kubernetes 中没有对应字段、或只能近似映射的配置。
+func (f K8sFormatter) Warnings(spec *ContainerSpec) []string {
+	warnings := copyStringSlice(spec.Unsupported)
+	add := func(format string, args ...interface{}) {
+		warnings = append(warnings, fmt.Sprintf(format, args...))
+	}
+
+	switch policy := spec.RestartPolicy; {
+	case policy == "no", strings.HasPrefix(policy, "on-failure"):
+		add("restart=%s: Deployment/StatefulSet 只支持 restartPolicy=Always", policy)
+	}
+	if spec.AutoRemove {
+		add("AutoRemove (--rm): Kubernetes 无对应配置")
+	}
+	if spec.PublishAllPorts {
+		add("PublishAllPorts (-P): 需在 Service 中显式声明端口")
+	}
+	for _, b := range spec.PortBindings {
+		if b.HostIP != "" {
+			add("端口 %s:%d:%d/%s: Service 无法绑定宿主机 IP", b.HostIP, b.HostPort, b.ContPort, b.Proto)
+		}
+	}
+	switch mode := spec.NetworkMode; {
+	case mode == "none":
+		add("network=none: Pod 总会接入集群网络")
+	case strings.HasPrefix(mode, "container:"):
+		add("network=%s: 需改为同一 Pod 内的 sidecar 容器", mode)
+	}
+	for _, endpoint := range spec.Networks {
+		if endpoint.configured() {
+			add("网络 %s 的别名/静态 IP: Pod 网络由 CNI 分配，请改用 Service 名称访问", endpoint.Name)
+		}
+	}
+	for _, link := range spec.Links {
+		add("link %s: 请改用 Service 名称访问", link)
+	}
+	for _, source := range spec.VolumesFrom {
+		add("volumes-from %s: 需改为共享 PVC 或同 Pod 内共享卷", source)
+	}
+	for _, env := range spec.Envs {
+		if !strings.Contains(env, "=") {
+			add("env %s: 没有值（docker run -e 从宿主机继承），ConfigMap/Secret 中未包含，需手动设置", env)
+		}
+	}
+	seen := map[string]bool{}
+	for _, m := range k8sMounts(spec.Mounts) {
+		switch m.kind {
+		case "volume":
+			if seen[m.source] {
+				continue
+			}
+			seen[m.source] = true
+			add("卷 %s: Docker 卷没有容量，PVC 按 %s 申请，数据需另行迁移", m.source, K8sDefaultVolumeSize)
+		case "bind":
+			add("bind %s:%s: 映射为 hostPath，需保证调度节点上存在该路径", m.source, m.target)
+		}
+	}
+	if _, _, ok := k8sRunAs(spec.User); spec.User != "" && !ok {
+		add("user=%s: securityContext 只支持数值 UID/GID", spec.User)
+	}
+	for _, group := range spec.GroupAdd {
+		if _, err := strconv.ParseInt(group, 10, 64); err != nil {
+			add("group-add %s: supplementalGroups 只支持数值 GID", group)
+		}
+	}
+	if h := spec.Healthcheck; h != nil && !h.Disabled() && h.StartInterval > 0 {
+		add("healthcheck start_interval: 探针无对应配置")
+	}
+	for _, item := range []struct {
+		name string
+		set  bool
+	}{
+		{"devices", len(spec.Devices) > 0},
+		{"device-cgroup-rule", len(spec.DeviceCgroupRules) > 0},
+		{"ulimits", len(spec.Ulimits) > 0},
+		{"security-opt", len(spec.SecurityOpt) > 0},
+		{"log-driver/log-opt", spec.LogDriver != "" || len(spec.LogOptions) > 0},
+		{"stop-signal", spec.StopSignal != ""},
+		{"init", spec.Init != nil && *spec.Init},
+		{"domainname", spec.Domainname != ""},
+		{"isolation", spec.Isolation != ""},
+		{"uts", spec.UTSMode != ""},
+		{"userns", spec.UsernsMode != ""},
+		{"cgroupns", spec.CgroupnsMode != ""},
+		{"cgroup-parent", spec.CgroupParent != ""},
+		{"storage-opt", len(spec.StorageOpt) > 0},
+		{"memory-swap/memory-swappiness", spec.Resources.MemorySwap != 0 || spec.Resources.MemorySwappiness != nil},
+		{"oom-kill-disable/oom-score-adj", spec.Resources.OomKillDisable || spec.Resources.OomScoreAdj != 0},
+		{"pids-limit", spec.Resources.PidsLimit > 0},
+		{"cpu-shares/cpu-period/cpu-quota", spec.Resources.CPUShares > 0 || spec.Resources.CPUPeriod > 0 || spec.Resources.CPUQuota > 0},
+		{"cpu-rt-period/cpu-rt-runtime", spec.Resources.CPURealtimePeriod > 0 || spec.Resources.CPURealtimeRuntime > 0},
+		{"cpuset-cpus/cpuset-mems", spec.Resources.CpusetCpus != "" || spec.Resources.CpusetMems != ""},
+		{"cpu-count/cpu-percent", spec.Resources.CPUCount > 0 || spec.Resources.CPUPercent > 0},
+		{"blkio/io 限制", spec.Resources.BlkioWeight > 0 || len(spec.Resources.BlkioWeightDevice) > 0 ||
+			len(spec.Resources.BlkioReadBps) > 0 || len(spec.Resources.BlkioWriteBps) > 0 ||
+			len(spec.Resources.BlkioReadIOps) > 0 || len(spec.Resources.BlkioWriteIOps) > 0 ||
+			spec.Resources.IOMaximumIOps > 0 || spec.Resources.IOMaximumBandwidth > 0},
+	} {
+		if item.set {
+			add("%s: Kubernetes 无对应字段", item.name)
+		}
+	}
+	for _, req := range spec.DeviceRequests {
+		switch {
+		case !isGPURequest(req):
+			add("DeviceRequest driver=%s: 仅支持映射 GPU 请求", req.Driver)
+		case req.Count == -1:
+			add("--gpus all: 按 1 个 nvidia.com/gpu 申请，需按节点实际数量调整")
+		case len(req.DeviceIDs) > 0:
+			add("--gpus device=%s: Kubernetes 只能按数量申请 GPU", strings.Join(req.DeviceIDs, ","))
+		}
+	}
+	return warnings
+}
+
+// splitSensitiveEnvs 按 sensitive.IsSensitiveKey 拆分环境变量，敏感项写入 Secret。
+func splitSensitiveEnvs(envs []string, opts ReverseOptions) (map[string]string, map[string]string) {
+	profile := sensitive.ProfileBasic
+	if normalized, err := normalizeRedactProfile(opts.RedactProfile, opts.RedactSecrets); err == nil && normalized == sensitive.ProfileStrict {
+		profile = normalized
+	}
+	plain, secret := map[string]string{}, map[string]string{}
+	for _, env := range envs {
+		key, value, ok := strings.Cut(env, "=")
+		if !ok {
+			continue
+		}
+		if sensitive.IsSensitiveKey(key, profile) {
+			secret[key] = value
+		} else {
+			plain[key] = value
+		}
+	}
+	if len(plain) == 0 {
+		plain = nil
+	}
+	if len(secret) == 0 {
+		secret = nil
+	}
+	return plain, secret
+}
+
+func k8sMounts(mounts []string) []k8sMount {
+	var result []k8sMount
+	for _, mount := range mounts {
+		parts := strings.Split(mount, ":")
+		if len(parts) == 1 {
+			result = append(result, k8sMount{kind: "anonymous", target: parts[0]})
+			continue
+		}
+		m := k8sMount{source: parts[0], target: parts[1]}
+		if len(parts) > 2 {
+			for _, opt := range strings.Split(parts[2], ",") {
+				m.ro = m.ro || opt == "ro"
+			}
+		}
+		switch {
+		case strings.ContainsAny(m.source, "/\\") || strings.HasPrefix(m.source, ".") || strings.HasPrefix(m.source, "~"):
+			m.kind = "bind"
+		case IsAnonymousVolumeName(m.source):
+			m.kind = "anonymous"
+		default:
+			m.kind = "volume"
+		}
+		result = append(result, m)
+	}
+	return result
+}
+
+func k8sContainerPorts(spec *ContainerSpec) []K8sContainerPort {
+	seen := map[string]bool{}
+	var ports []K8sContainerPort
+	add := func(port int, proto string) {
+		key := fmt.Sprintf("%d/%s", port, proto)
+		if port <= 0 || seen[key] {
+			return
+		}
+		seen[key] = true
+		ports = append(ports, K8sContainerPort{ContainerPort: port, Protocol: strings.ToUpper(proto)})
+	}
+	for _, b := range spec.PortBindings {
+		add(b.ContPort, b.Proto)
+	}
+	for _, exposed := range spec.ExposedPorts {
+		portText, proto, _ := strings.Cut(exposed, "/")
+		port, _ := strconv.Atoi(portText)
+		add(port, DefaultString(proto, "tcp"))
+	}
+	return ports
+}
+
+func k8sServicePorts(bindings []PortBindingSpec) []K8sServicePort {
+	seen := map[string]bool{}
+	var ports []K8sServicePort
+	for _, b := range bindings {
+		port := b.HostPort
+		if port == 0 {
+			port = b.ContPort
+		}
+		name := fmt.Sprintf("%s-%d", strings.ToLower(b.Proto), port)
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		ports = append(ports, K8sServicePort{Name: name, Protocol: strings.ToUpper(b.Proto), Port: port, TargetPort: b.ContPort})
+	}
+	return ports
+}
+
+func k8sResources(spec *ContainerSpec) *K8sResources {
+	r := spec.Resources
+	resources := &K8sResources{Limits: map[string]string{}, Requests: map[string]string{}}
+	if r.Memory > 0 {
+		resources.Limits["memory"] = k8sQuantity(r.Memory)
+	}
+	if r.MemoryReservation > 0 {
+		resources.Requests["memory"] = k8sQuantity(r.MemoryReservation)
+	}
+	if r.NanoCPUs > 0 {
+		resources.Limits["cpu"] = fmt.Sprintf("%dm", r.NanoCPUs/1e6)
+	}
+	for _, req := range spec.DeviceRequests {
+		if !isGPURequest(req) {
+			continue
+		}
+		count := req.Count
+		if len(req.DeviceIDs) > 0 {
+			count = len(req.DeviceIDs)
+		}
+		if count <= 0 {
+			count = 1
+		}
+		resources.Limits["nvidia.com/gpu"] = strconv.Itoa(count)
+	}
+	if len(resources.Limits) == 0 {
+		resources.Limits = nil
+	}
+	if len(resources.Requests) == 0 {
+		resources.Requests = nil
+	}
+	if resources.Limits == nil && resources.Requests == nil {
+		return nil
+	}
+	return resources
+}
+
+// k8sQuantity 将字节数转换为 Kubernetes 数量，例如 512Mi、2Gi。
+func k8sQuantity(n int64) string {
+	switch {
+	case n%(1<<30) == 0:
+		return fmt.Sprintf("%dGi", n>>30)
+	case n%(1<<20) == 0:
+		return fmt.Sprintf("%dMi", n>>20)
+	case n%(1<<10) == 0:
+		return fmt.Sprintf("%dKi", n>>10)
+	default:
+		return strconv.FormatInt(n, 10)
+	}
+}
+
+func tmpfsSizeLimit(opts string) string {
+	for _, opt := range strings.Split(opts, ",") {
+		if value, ok := strings.CutPrefix(opt, "size="); ok {
+			if size, err := parseByteSize(value); err == nil {
+				return k8sQuantity(size)
+			}
+		}
+	}
+	return ""
+}
+
+// k8sProbe 将 healthcheck 映射为 exec 探针，CMD-SHELL 通过 /bin/sh -c 执行。
+func k8sProbe(h *HealthcheckSpec) *K8sProbe {
+	if h == nil || h.Disabled() || len(h.Test) < 2 {
+		return nil
+	}
+	command := h.Test[1:]
+	if h.Test[0] == "CMD-SHELL" {
+		command = []string{"/bin/sh", "-c", strings.Join(h.Test[1:], " ")}
+	}
+	return &K8sProbe{
+		Exec:                K8sExecAction{Command: command},
+		InitialDelaySeconds: durationSeconds(h.StartPeriod),
+		PeriodSeconds:       durationSeconds(h.Interval),
+		TimeoutSeconds:      durationSeconds(h.Timeout),
+		FailureThreshold:    h.Retries,
+	}
+}
+
+func durationSeconds(d time.Duration) int {
+	if d <= 0 {
+		return 0
+	}
+	seconds := int(d / time.Second)
+	if d%time.Second != 0 {
+		seconds++
+	}
+	return seconds
+}
+
+func k8sSecurityContext(spec *ContainerSpec) *K8sSecurityContext {
+	sc := &K8sSecurityContext{}
+	set := false
+	if spec.Privileged {
+		privileged := true
+		sc.Privileged = &privileged
+		set = true
+	}
+	if uid, gid, ok := k8sRunAs(spec.User); ok {
+		sc.RunAsUser = uid
+		sc.RunAsGroup = gid
+		set = true
+	}
+	if spec.ReadonlyRootfs {
+		readOnly := true
+		sc.ReadOnlyRootFilesystem = &readOnly
+		set = true
+	}
+	if len(spec.CapAdd) > 0 || len(spec.CapDrop) > 0 {
+		sc.Capabilities = &K8sCapabilities{Add: k8sCapabilities(spec.CapAdd), Drop: k8sCapabilities(spec.CapDrop)}
+		set = true
+	}
+	if !set {
+		return nil
+	}
+	return sc
+}
+
+// k8sRunAs 解析 "uid" 或 "uid:gid"，用户名无法映射为 runAsUser。
+func k8sRunAs(user string) (*int64, *int64, bool) {
+	if user == "" {
+		return nil, nil, false
+	}
+	userPart, groupPart, hasGroup := strings.Cut(user, ":")
+	uid, err := strconv.ParseInt(userPart, 10, 64)
+	if err != nil {
+		return nil, nil, false
+	}
+	if !hasGroup {
+		return &uid, nil, true
+	}
+	gid, err := strconv.ParseInt(groupPart, 10, 64)
+	if err != nil {
+		return nil, nil, false
+	}
+	return &uid, &gid, true
+}
+
+func k8sCapabilities(caps []string) []string {
+	var result []string
+	for _, capability := range caps {
+		result = append(result, strings.TrimPrefix(strings.ToUpper(capability), "CAP_"))
+	}
+	return result
+}
+
+func k8sPodSecurityContext(spec *ContainerSpec) *K8sPodSecurityContext {
+	sc := &K8sPodSecurityContext{}
+	for _, group := range spec.GroupAdd {
+		if gid, err := strconv.ParseInt(group, 10, 64); err == nil {
+			sc.SupplementalGroups = append(sc.SupplementalGroups, gid)
+		}
+	}
+	for _, key := range sortedKeys(spec.Sysctls) {
+		sc.Sysctls = append(sc.Sysctls, K8sNameValue{Name: key, Value: spec.Sysctls[key]})
+	}
+	if len(sc.SupplementalGroups) == 0 && len(sc.Sysctls) == 0 {
+		return nil
+	}
+	return sc
+}
+
+func k8sDNSOptions(options []string) []K8sDNSConfigOpt {
+	var result []K8sDNSConfigOpt
+	for _, option := range options {
+		name, value, _ := strings.Cut(option, ":")
+		result = append(result, K8sDNSConfigOpt{Name: name, Value: value})
+	}
+	return result
+}
+
+// k8sHostAliases 将 "host:ip" 形式的 extra_hosts 按 IP 聚合为 hostAliases。
+func k8sHostAliases(extraHosts []string) []K8sHostAlias {
+	var aliases []K8sHostAlias
+	index := map[string]int{}
+	for _, entry := range extraHosts {
+		host, ip, ok := strings.Cut(entry, ":")
+		if !ok {
+			continue
+		}
+		if i, exists := index[ip]; exists {
+			aliases[i].Hostnames = append(aliases[i].Hostnames, host)
+			continue
+		}
+		index[ip] = len(aliases)
+		aliases = append(aliases, K8sHostAlias{IP: ip, Hostnames: []string{host}})
+	}
+	return aliases
+}
+
+func k8sHostname(hostname string) string {
+	if hostname == "" {
+		return ""
+	}
+	return k8sName(hostname)
+}
+
+var k8sNameInvalid = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// k8sName 将容器名、卷名转换为合法的 DNS-1123 名称。
+func k8sName(name string) string {
+	name = k8sNameInvalid.ReplaceAllString(strings.ToLower(name), "-")
+	if len(name) > 63 {
+		name = name[:63]
+	}
+	name = strings.Trim(name, "-")
+	if name == "" {
+		return "container"
+	}
+	return name
+}
+
+// K8sWorkloadName 返回容器的工作负载名。它同时用作 Service 名，需满足 DNS-1035，
+// 因此在 k8sName 的基础上保证以字母开头；不同的容器名可能映射为同一个名称。
+func K8sWorkloadName(name string) string {
+	name = k8sName(name)
+	if name[0] >= 'a' && name[0] <= 'z' {
+		return name
+	}
+	name = "c-" + name
+	if len(name) > 63 {
+		name = strings.TrimRight(name[:63], "-")
+	}
+	return name
+}
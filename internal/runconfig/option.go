@@ -6,6 +6,9 @@ const (
 	ReverseCmd     ReverseType = "cmd"
 	ReverseCompose ReverseType = "compose"
 	ReverseAll     ReverseType = "all"
+	ReverseK8s     ReverseType = "k8s"
+	ReverseQuadlet ReverseType = "quadlet"
+	ReverseSystemd ReverseType = "systemd"
 )
 
 type ReverseOptions struct {
@@ -14,7 +17,8 @@ type ReverseOptions struct {
 	PrettyFormat      bool        // 格式化输出 docker run 命令
 	MergePorts        bool        // 合并连续端口范围
 	Save              bool        // 是否保存输出到文件
-	ReverseType       ReverseType // 输出类型: cmd | compose | all
+	ReverseType       ReverseType // 输出类型: cmd | compose | all | k8s | quadlet | systemd
 	RedactSecrets     bool        // 启用 basic 脱敏，兼容旧开关
 	RedactProfile     string      // 脱敏策略: none | basic | strict
+	Project           bool        // 按 compose 项目标签还原，每个项目输出一个 compose 文件
 }
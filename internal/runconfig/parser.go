@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"net/netip"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
@@ -38,6 +39,40 @@ type ContainerSpec struct {
 	Entrypoint      []string
 	WorkingDir      string
 	NetworkMode     string
+
+	Hostname          string
+	Domainname        string
+	DNSOptions        []string
+	ExposedPorts      []string
+	Tty               bool
+	OpenStdin         bool
+	Healthcheck       *HealthcheckSpec
+	StopSignal        string
+	StopTimeout       *int
+	Init              *bool
+	ReadonlyRootfs    bool
+	Runtime           string
+	Isolation         string
+	PidMode           string
+	IpcMode           string
+	UTSMode           string
+	UsernsMode        string
+	CgroupnsMode      string
+	CgroupParent      string
+	GroupAdd          []string
+	Tmpfs             map[string]string
+	Sysctls           map[string]string
+	StorageOpt        map[string]string
+	Annotations       map[string]string
+	Links             []string
+	VolumesFrom       []string
+	VolumeDriver      string
+	ContainerIDFile   string
+	DeviceCgroupRules []string
+	DeviceRequests    []DeviceRequestSpec
+	Resources         ResourceSpec
+	Networks          []NetworkEndpointSpec // 仅在存在别名/静态 IP 或多个网络时填充，首项为主网络
+	Unsupported       []string              // docker run 与 Compose 均无法表达的配置
 }
 
 type PortBindingSpec struct {
@@ -53,9 +88,17 @@ type UlimitSpec struct {
 }
 
 type ParsedResult struct {
-	Name    string
-	Command []string
-	Compose ComposeService
+	Name            string
+	Command         []string
+	Compose         ComposeService
+	CommandWarnings []string // docker run 无法复现的配置
+	ComposeWarnings []string // Compose 无法复现的配置
+	K8s             []K8sObject
+	K8sWarnings     []string // Kubernetes 无对应字段的配置
+	Quadlet         QuadletUnit
+	QuadletWarnings []string // Podman Quadlet 无法复现的配置
+	Systemd         string
+	SystemdWarnings []string // systemd service 无法复现的配置
 }
 
 // -------------------- Parser --------------------
@@ -70,6 +113,7 @@ func NewParser(ci container.InspectResponse, opts ReverseOptions) *Parser {
 }
 
 func (p *Parser) ToSpec() *ContainerSpec {
+	hc := p.ci.HostConfig
 	return &ContainerSpec{
 		Image:           p.ci.Config.Image,
 		ContainerName:   strings.TrimPrefix(p.ci.Name, "/"),
@@ -96,7 +140,48 @@ func (p *Parser) ToSpec() *ContainerSpec {
 		Entrypoint:      p.ci.Config.Entrypoint,
 		WorkingDir:      p.ci.Config.WorkingDir,
 		NetworkMode:     string(p.ci.HostConfig.NetworkMode),
+
+		Hostname:          p.parseHostname(),
+		Domainname:        p.ci.Config.Domainname,
+		DNSOptions:        copyStringSlice(hc.DNSOptions),
+		ExposedPorts:      p.parseExposedPorts(),
+		Tty:               p.ci.Config.Tty,
+		OpenStdin:         p.ci.Config.OpenStdin,
+		Healthcheck:       p.parseHealthcheck(),
+		StopSignal:        p.ci.Config.StopSignal,
+		StopTimeout:       p.ci.Config.StopTimeout,
+		Init:              hc.Init,
+		ReadonlyRootfs:    hc.ReadonlyRootfs,
+		Runtime:           omitDefault(hc.Runtime, "runc"),
+		Isolation:         omitDefault(string(hc.Isolation), "default"),
+		PidMode:           string(hc.PidMode),
+		IpcMode:           omitDefault(string(hc.IpcMode), "private"),
+		UTSMode:           string(hc.UTSMode),
+		UsernsMode:        string(hc.UsernsMode),
+		CgroupnsMode:      omitDefault(string(hc.CgroupnsMode), "private"),
+		CgroupParent:      hc.CgroupParent,
+		GroupAdd:          copyStringSlice(hc.GroupAdd),
+		Tmpfs:             p.parseTmpfs(),
+		Sysctls:           copyStringMap(hc.Sysctls),
+		StorageOpt:        copyStringMap(hc.StorageOpt),
+		Annotations:       copyStringMap(hc.Annotations),
+		Links:             p.parseLinks(),
+		VolumesFrom:       copyStringSlice(hc.VolumesFrom),
+		VolumeDriver:      hc.VolumeDriver,
+		ContainerIDFile:   hc.ContainerIDFile,
+		DeviceCgroupRules: copyStringSlice(hc.DeviceCgroupRules),
+		DeviceRequests:    p.parseDeviceRequests(),
+		Resources:         p.parseResources(),
+		Networks:          p.parseNetworks(),
+		Unsupported:       p.parseUnsupported(),
+	}
+}
+
+func omitDefault(value, defaultValue string) string {
+	if value == defaultValue {
+		return ""
 	}
+	return value
 }
 
 func (p *Parser) parseRestartPolicy() string {
@@ -115,6 +200,26 @@ var defaultEnvKeys = map[string]bool{
 	"container": true,
 }
 
+// IsDefaultEnvKey 判断 key 是否属于 FilterDefaultEnvs 过滤的 Docker 默认环境变量。
+func IsDefaultEnvKey(key string) bool {
+	return defaultEnvKeys[key]
+}
+
+var anonymousVolumeName = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// IsAnonymousVolumeName 判断卷名是否为 Docker 为匿名卷生成的 64 位十六进制 ID。
+func IsAnonymousVolumeName(name string) bool {
+	return anonymousVolumeName.MatchString(name)
+}
+
+// DefaultString 在 value 为空时返回 defaultValue。
+func DefaultString(value, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
+
 func (p *Parser) parseEnvs() []string {
 	envs := p.ci.Config.Env
 	profile, _ := normalizeRedactProfile(p.options.RedactProfile, p.options.RedactSecrets)
@@ -164,6 +269,8 @@ func (p *Parser) parseMounts() []string {
 				mode = ":" + m.Mode
 			}
 			mounts = append(mounts, fmt.Sprintf("%s:%s%s", m.Source, m.Destination, mode))
+		case "tmpfs":
+			// 由 parseTmpfs 还原为 --tmpfs
 		default:
 			mounts = append(mounts, fmt.Sprintf("%s:%s", m.Source, m.Destination))
 		}
@@ -333,9 +440,8 @@ func (f CommandFormatter) Format(spec *ContainerSpec, opts ReverseOptions) []str
 	if spec.WorkingDir != "" {
 		add("-w", spec.WorkingDir)
 	}
-	if spec.NetworkMode != "" && spec.NetworkMode != "default" {
-		add("--network", spec.NetworkMode)
-	}
+	networkArgs, _ := commandNetworkArgs(spec)
+	add(networkArgs...)
 	for _, label := range formatLabels(spec.Labels) {
 		add("--label", label)
 	}
@@ -369,6 +475,95 @@ func (f CommandFormatter) Format(spec *ContainerSpec, opts ReverseOptions) []str
 	for _, opt := range formatMapOptions(spec.LogOptions) {
 		add("--log-opt", opt)
 	}
+	// 以 "-" 开头的取值（如 --memory-swap -1）写成 --flag=value，避免被当作独立参数
+	addValue := func(flag, value string) {
+		switch {
+		case value == "":
+			add(flag)
+		case strings.HasPrefix(value, "-"):
+			add(flag + "=" + value)
+		default:
+			add(flag, value)
+		}
+	}
+	addString := func(flag, value string) {
+		if value != "" {
+			add(flag, value)
+		}
+	}
+	addString("--hostname", spec.Hostname)
+	addString("--domainname", spec.Domainname)
+	for _, opt := range spec.DNSOptions {
+		add("--dns-option", opt)
+	}
+	if spec.Tty {
+		add("-t")
+	}
+	if spec.OpenStdin {
+		add("-i")
+	}
+	if spec.ReadonlyRootfs {
+		add("--read-only")
+	}
+	if spec.Init != nil {
+		if *spec.Init {
+			add("--init")
+		} else {
+			add("--init=false")
+		}
+	}
+	addString("--runtime", spec.Runtime)
+	addString("--isolation", spec.Isolation)
+	addString("--pid", spec.PidMode)
+	addString("--ipc", spec.IpcMode)
+	addString("--uts", spec.UTSMode)
+	addString("--userns", spec.UsernsMode)
+	addString("--cgroupns", spec.CgroupnsMode)
+	addString("--cgroup-parent", spec.CgroupParent)
+	for _, group := range spec.GroupAdd {
+		add("--group-add", group)
+	}
+	addString("--stop-signal", spec.StopSignal)
+	if spec.StopTimeout != nil {
+		addValue("--stop-timeout", strconv.Itoa(*spec.StopTimeout))
+	}
+	for _, arg := range healthcheckArgs(spec.Healthcheck) {
+		addValue(arg[0], arg[1])
+	}
+	for _, arg := range commandResourceArgs(spec.Resources) {
+		addValue(arg[0], arg[1])
+	}
+	for _, req := range spec.DeviceRequests {
+		if isGPURequest(req) {
+			add("--gpus", gpusValue(req))
+		}
+	}
+	for _, rule := range spec.DeviceCgroupRules {
+		add("--device-cgroup-rule", rule)
+	}
+	for _, opt := range formatMapOptions(spec.Sysctls) {
+		add("--sysctl", opt)
+	}
+	for _, opt := range formatMapOptions(spec.StorageOpt) {
+		add("--storage-opt", opt)
+	}
+	for _, opt := range formatMapOptions(spec.Annotations) {
+		add("--annotation", opt)
+	}
+	for _, tmpfs := range formatTmpfs(spec.Tmpfs) {
+		add("--tmpfs", tmpfs)
+	}
+	for _, port := range spec.ExposedPorts {
+		add("--expose", port)
+	}
+	for _, link := range spec.Links {
+		add("--link", link)
+	}
+	for _, source := range spec.VolumesFrom {
+		add("--volumes-from", source)
+	}
+	addString("--volume-driver", spec.VolumeDriver)
+	addString("--cidfile", spec.ContainerIDFile)
 	for _, e := range spec.Envs {
 		add("-e", e)
 	}
@@ -468,9 +663,11 @@ func formatRange(start, end PortBindingSpec) string {
 type ComposeFormatter struct{}
 
 func (f ComposeFormatter) Format(spec *ContainerSpec) ComposeService {
-	restart := spec.RestartPolicy
-	if strings.HasPrefix(restart, "on-failure:") {
-		restart = "on-failure"
+	networks, networkMode, _ := composeNetworks(spec)
+	r := spec.Resources
+	var cpus float64
+	if r.NanoCPUs > 0 {
+		cpus = nanoCPUs(r.NanoCPUs)
 	}
 
 	// Compose 不支持连续范围，逐个展开
@@ -497,7 +694,7 @@ func (f ComposeFormatter) Format(spec *ContainerSpec) ComposeService {
 		Ulimits:       spec.Ulimits,
 		Logging:       formatComposeLogging(spec),
 		Privileged:    spec.Privileged,
-		Restart:       restart,
+		Restart:       spec.RestartPolicy, // compose 的 restart 接受 on-failure:N，保留重试次数
 		User:          spec.User,
 		Environment:   spec.Envs,
 		Volumes:       spec.Mounts,
@@ -505,8 +702,63 @@ func (f ComposeFormatter) Format(spec *ContainerSpec) ComposeService {
 		Entrypoint:    spec.Entrypoint,
 		WorkingDir:    spec.WorkingDir,
 		Command:       spec.Cmd,
-		NetworkMode:   spec.NetworkMode,
+		NetworkMode:   networkMode,
+
+		Networks:          networks,
+		Hostname:          spec.Hostname,
+		Domainname:        spec.Domainname,
+		DNSOpt:            spec.DNSOptions,
+		Expose:            spec.ExposedPorts,
+		Links:             spec.Links,
+		VolumesFrom:       composeVolumesFrom(spec.VolumesFrom),
+		Tmpfs:             formatTmpfs(spec.Tmpfs),
+		Tty:               spec.Tty,
+		StdinOpen:         spec.OpenStdin,
+		ReadOnly:          spec.ReadonlyRootfs,
+		Init:              spec.Init,
+		Healthcheck:       composeHealthcheck(spec.Healthcheck),
+		StopSignal:        spec.StopSignal,
+		StopGracePeriod:   composeStopGracePeriod(spec.StopTimeout),
+		Runtime:           spec.Runtime,
+		Isolation:         spec.Isolation,
+		Pid:               spec.PidMode,
+		Ipc:               spec.IpcMode,
+		Uts:               spec.UTSMode,
+		UsernsMode:        spec.UsernsMode,
+		Cgroup:            spec.CgroupnsMode,
+		CgroupParent:      spec.CgroupParent,
+		GroupAdd:          spec.GroupAdd,
+		Sysctls:           spec.Sysctls,
+		StorageOpt:        spec.StorageOpt,
+		Annotations:       spec.Annotations,
+		DeviceCgroupRules: spec.DeviceCgroupRules,
+		ShmSize:           composeByteSize(r.ShmSize),
+		MemLimit:          composeByteSize(r.Memory),
+		MemReservation:    composeByteSize(r.MemoryReservation),
+		MemSwapLimit:      composeByteSize(r.MemorySwap),
+		MemSwappiness:     r.MemorySwappiness,
+		OomKillDisable:    r.OomKillDisable,
+		OomScoreAdj:       r.OomScoreAdj,
+		PidsLimit:         r.PidsLimit,
+		CPUs:              cpus,
+		CPUShares:         r.CPUShares,
+		CPUPeriod:         r.CPUPeriod,
+		CPUQuota:          r.CPUQuota,
+		CPURTPeriod:       r.CPURealtimePeriod,
+		CPURTRuntime:      r.CPURealtimeRuntime,
+		Cpuset:            r.CpusetCpus,
+		CPUCount:          r.CPUCount,
+		CPUPercent:        r.CPUPercent,
+		BlkioConfig:       composeBlkio(r),
+		Deploy:            composeDeploy(spec.DeviceRequests),
+	}
+}
+
+func composeByteSize(n int64) string {
+	if n == 0 {
+		return ""
 	}
+	return formatByteSize(n)
 }
 
 // -------------------- Parser 统一输出 --------------------
@@ -515,11 +767,22 @@ func (p *Parser) ToResult() ParsedResult {
 	spec := p.ToSpec()
 	cmdFormatter := CommandFormatter{}
 	composeFormatter := ComposeFormatter{}
+	k8sFormatter := K8sFormatter{}
+	quadletFormatter := QuadletFormatter{}
+	systemdFormatter := SystemdFormatter{}
 
 	return ParsedResult{
-		Name:    trimContainerName(p.ci.Name),
-		Command: cmdFormatter.Format(spec, p.options),
-		Compose: composeFormatter.Format(spec),
+		Name:            trimContainerName(p.ci.Name),
+		Command:         cmdFormatter.Format(spec, p.options),
+		Compose:         composeFormatter.Format(spec),
+		CommandWarnings: cmdFormatter.Warnings(spec),
+		ComposeWarnings: composeFormatter.Warnings(spec),
+		K8s:             k8sFormatter.Format(spec, p.options),
+		K8sWarnings:     k8sFormatter.Warnings(spec),
+		Quadlet:         quadletFormatter.Format(spec, p.options),
+		QuadletWarnings: quadletFormatter.Warnings(spec),
+		Systemd:         systemdFormatter.Format(spec, p.options),
+		SystemdWarnings: systemdFormatter.Warnings(spec),
 	}
 }
 
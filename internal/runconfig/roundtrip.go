@@ -0,0 +1,881 @@
+package runconfig
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/moby/moby/api/types/blkiodev"
+	"github.com/moby/moby/api/types/container"
+	"github.com/moby/moby/api/types/mount"
+	"github.com/moby/moby/api/types/network"
+	"github.com/spf13/pflag"
+)
+
+// -------------------- 回读: docker run --------------------
+
+// runFlags 覆盖 CommandFormatter 会生成的全部 docker run 参数。
+type runFlags struct {
+	fs *pflag.FlagSet
+
+	name, restart, user, entrypoint, workdir, logDriver       string
+	hostname, domainname, runtime, isolation                  string
+	pid, ipc, uts, userns, cgroupns, cgroupParent             string
+	stopSignal, volumeDriver, cidfile, ip, ip6                string
+	healthCmd, healthInterval, healthTimeout                  string
+	healthStartPeriod, healthStartInterval                    string
+	memory, memoryReservation, memorySwap, shmSize, cpus      string
+	cpusetCpus, cpusetMems                                    string
+	privileged, publishAll, rm, tty, stdin, readOnly, init    bool
+	oomKillDisable, noHealthcheck                             bool
+	stopTimeout, healthRetries, oomScoreAdj                   int
+	memorySwappiness, pidsLimit, cpuShares, cpuPeriod         int64
+	cpuQuota, cpuRtPeriod, cpuRtRuntime, cpuCount, cpuPercent int64
+	blkioWeight                                               uint16
+	ioMaxIOps, ioMaxBandwidth                                 uint64
+
+	networks, networkAliases, linkLocalIPs, labels, dns, dnsSearch, dnsOptions []string
+	hosts, capAdd, capDrop, securityOpt, devices, ulimits, logOpts             []string
+	envs, volumes, ports, groupAdd, gpus, cgroupRules, sysctls                 []string
+	storageOpts, annotations, tmpfs, expose, links, volumesFrom                []string
+	blkioWeightDevices, readBps, writeBps, readIOps, writeIOps                 []string
+}
+
+func newRunFlags() *runFlags {
+	f := &runFlags{fs: pflag.NewFlagSet("docker run", pflag.ContinueOnError)}
+	fs := f.fs
+	fs.BoolP("detach", "d", false, "")
+	for _, item := range []struct {
+		dst  *string
+		name string
+	}{
+		{&f.name, "name"}, {&f.restart, "restart"}, {&f.entrypoint, "entrypoint"}, {&f.logDriver, "log-driver"},
+		{&f.hostname, "hostname"}, {&f.domainname, "domainname"}, {&f.runtime, "runtime"}, {&f.isolation, "isolation"},
+		{&f.pid, "pid"}, {&f.ipc, "ipc"}, {&f.uts, "uts"}, {&f.userns, "userns"}, {&f.cgroupns, "cgroupns"},
+		{&f.cgroupParent, "cgroup-parent"}, {&f.stopSignal, "stop-signal"}, {&f.volumeDriver, "volume-driver"},
+		{&f.cidfile, "cidfile"}, {&f.ip, "ip"}, {&f.ip6, "ip6"}, {&f.healthCmd, "health-cmd"},
+		{&f.healthInterval, "health-interval"}, {&f.healthTimeout, "health-timeout"},
+		{&f.healthStartPeriod, "health-start-period"}, {&f.healthStartInterval, "health-start-interval"},
+		{&f.memory, "memory"}, {&f.memoryReservation, "memory-reservation"}, {&f.memorySwap, "memory-swap"},
+		{&f.shmSize, "shm-size"}, {&f.cpus, "cpus"}, {&f.cpusetCpus, "cpuset-cpus"}, {&f.cpusetMems, "cpuset-mems"},
+	} {
+		fs.StringVar(item.dst, item.name, "", "")
+	}
+	fs.StringVarP(&f.user, "user", "u", "", "")
+	fs.StringVarP(&f.workdir, "workdir", "w", "", "")
+	for _, item := range []struct {
+		dst       *bool
+		name      string
+		shorthand string
+	}{
+		{&f.privileged, "privileged", ""}, {&f.publishAll, "publish-all", "P"}, {&f.rm, "rm", ""},
+		{&f.tty, "tty", "t"}, {&f.stdin, "interactive", "i"}, {&f.readOnly, "read-only", ""}, {&f.init, "init", ""},
+		{&f.oomKillDisable, "oom-kill-disable", ""}, {&f.noHealthcheck, "no-healthcheck", ""},
+	} {
+		fs.BoolVarP(item.dst, item.name, item.shorthand, false, "")
+	}
+	fs.IntVar(&f.stopTimeout, "stop-timeout", 0, "")
+	fs.IntVar(&f.healthRetries, "health-retries", 0, "")
+	fs.IntVar(&f.oomScoreAdj, "oom-score-adj", 0, "")
+	for _, item := range []struct {
+		dst  *int64
+		name string
+	}{
+		{&f.memorySwappiness, "memory-swappiness"}, {&f.pidsLimit, "pids-limit"}, {&f.cpuShares, "cpu-shares"},
+		{&f.cpuPeriod, "cpu-period"}, {&f.cpuQuota, "cpu-quota"}, {&f.cpuRtPeriod, "cpu-rt-period"},
+		{&f.cpuRtRuntime, "cpu-rt-runtime"}, {&f.cpuCount, "cpu-count"}, {&f.cpuPercent, "cpu-percent"},
+	} {
+		fs.Int64Var(item.dst, item.name, 0, "")
+	}
+	fs.Uint16Var(&f.blkioWeight, "blkio-weight", 0, "")
+	fs.Uint64Var(&f.ioMaxIOps, "io-maxiops", 0, "")
+	fs.Uint64Var(&f.ioMaxBandwidth, "io-maxbandwidth", 0, "")
+	for _, item := range []struct {
+		dst       *[]string
+		name      string
+		shorthand string
+	}{
+		{&f.networks, "network", ""}, {&f.networkAliases, "network-alias", ""}, {&f.linkLocalIPs, "link-local-ip", ""},
+		{&f.labels, "label", "l"}, {&f.dns, "dns", ""}, {&f.dnsSearch, "dns-search", ""}, {&f.dnsOptions, "dns-option", ""},
+		{&f.hosts, "add-host", ""}, {&f.capAdd, "cap-add", ""}, {&f.capDrop, "cap-drop", ""},
+		{&f.securityOpt, "security-opt", ""}, {&f.devices, "device", ""}, {&f.ulimits, "ulimit", ""},
+		{&f.logOpts, "log-opt", ""}, {&f.envs, "env", "e"}, {&f.volumes, "volume", "v"}, {&f.ports, "publish", "p"},
+		{&f.groupAdd, "group-add", ""}, {&f.gpus, "gpus", ""}, {&f.cgroupRules, "device-cgroup-rule", ""},
+		{&f.sysctls, "sysctl", ""}, {&f.storageOpts, "storage-opt", ""}, {&f.annotations, "annotation", ""},
+		{&f.tmpfs, "tmpfs", ""}, {&f.expose, "expose", ""}, {&f.links, "link", ""}, {&f.volumesFrom, "volumes-from", ""},
+		{&f.blkioWeightDevices, "blkio-weight-device", ""}, {&f.readBps, "device-read-bps", ""},
+		{&f.writeBps, "device-write-bps", ""}, {&f.readIOps, "device-read-iops", ""}, {&f.writeIOps, "device-write-iops", ""},
+	} {
+		fs.StringArrayVarP(item.dst, item.name, item.shorthand, nil, "")
+	}
+	return f
+}
+
+// CommandToInspect 将 CommandFormatter 生成的参数解析回容器配置，只做解析不创建容器。
+func CommandToInspect(args []string) (container.InspectResponse, error) {
+	if len(args) < 2 || args[0] != "docker" || args[1] != "run" {
+		return container.InspectResponse{}, fmt.Errorf("不是 docker run 命令")
+	}
+	var flagArgs, rest []string
+	flagArgs = args[2:]
+	for i, arg := range args[2:] {
+		if arg == CommandSplitMarker {
+			flagArgs, rest = args[2:2+i], args[3+i:]
+			break
+		}
+	}
+	f := newRunFlags()
+	if err := f.fs.Parse(flagArgs); err != nil {
+		return container.InspectResponse{}, fmt.Errorf("解析 docker run 参数失败: %w", err)
+	}
+	rest = append(f.fs.Args(), rest...)
+	if len(rest) == 0 {
+		return container.InspectResponse{}, fmt.Errorf("docker run 命令缺少镜像")
+	}
+
+	b := newRoundtripBuilder(f.name)
+	cfg, host := b.info.Config, b.info.HostConfig
+	cfg.Image = rest[0]
+	if len(rest) > 1 {
+		cfg.Cmd = rest[1:]
+	}
+	if f.entrypoint != "" {
+		cfg.Entrypoint = []string{f.entrypoint}
+	}
+	cfg.User = f.user
+	cfg.WorkingDir = f.workdir
+	cfg.Hostname = f.hostname
+	cfg.Domainname = f.domainname
+	cfg.Tty = f.tty
+	cfg.OpenStdin = f.stdin
+	cfg.StopSignal = f.stopSignal
+	cfg.Env = f.envs
+	if f.fs.Changed("stop-timeout") {
+		cfg.StopTimeout = &f.stopTimeout
+	}
+	cfg.Labels = parseKeyValues(f.labels)
+
+	host.Privileged = f.privileged
+	host.PublishAllPorts = f.publishAll
+	host.AutoRemove = f.rm
+	host.ReadonlyRootfs = f.readOnly
+	if f.fs.Changed("init") {
+		host.Init = &f.init
+	}
+	host.Runtime = f.runtime
+	host.Isolation = container.Isolation(f.isolation)
+	host.PidMode = container.PidMode(f.pid)
+	host.IpcMode = container.IpcMode(f.ipc)
+	host.UTSMode = container.UTSMode(f.uts)
+	host.UsernsMode = container.UsernsMode(f.userns)
+	host.CgroupnsMode = container.CgroupnsMode(f.cgroupns)
+	host.CgroupParent = f.cgroupParent
+	host.VolumeDriver = f.volumeDriver
+	host.ContainerIDFile = f.cidfile
+	host.GroupAdd = f.groupAdd
+	host.DNSSearch = f.dnsSearch
+	host.DNSOptions = f.dnsOptions
+	host.ExtraHosts = f.hosts
+	host.CapAdd = f.capAdd
+	host.CapDrop = f.capDrop
+	host.SecurityOpt = f.securityOpt
+	host.DeviceCgroupRules = f.cgroupRules
+	host.VolumesFrom = f.volumesFrom
+	host.Sysctls = parseKeyValues(f.sysctls)
+	host.StorageOpt = parseKeyValues(f.storageOpts)
+	host.Annotations = parseKeyValues(f.annotations)
+	host.LogConfig = container.LogConfig{Type: f.logDriver, Config: parseKeyValues(f.logOpts)}
+
+	errs := &roundtripErrors{}
+	if f.restart != "" {
+		host.RestartPolicy = errs.restartPolicy(f.restart)
+	}
+	host.DNS = errs.addrs(f.dns)
+	for _, device := range f.devices {
+		host.Devices = append(host.Devices, parseDeviceSpec(device))
+	}
+	for _, ulimit := range f.ulimits {
+		host.Ulimits = append(host.Ulimits, errs.ulimit(ulimit))
+	}
+	for _, v := range f.volumes {
+		b.addVolume(v)
+	}
+	for _, p := range f.ports {
+		errs.add(b.addPort(p))
+	}
+	for _, port := range f.expose {
+		errs.add(b.expose(port))
+	}
+	for _, t := range f.tmpfs {
+		b.addTmpfs(t)
+	}
+	for _, link := range f.links {
+		b.addLink(link)
+	}
+
+	if f.noHealthcheck {
+		cfg.Healthcheck = &container.HealthConfig{Test: []string{"NONE"}}
+	} else if f.healthCmd != "" {
+		cfg.Healthcheck = &container.HealthConfig{
+			Test:          []string{"CMD-SHELL", f.healthCmd},
+			Interval:      errs.duration(f.healthInterval),
+			Timeout:       errs.duration(f.healthTimeout),
+			StartPeriod:   errs.duration(f.healthStartPeriod),
+			StartInterval: errs.duration(f.healthStartInterval),
+			Retries:       f.healthRetries,
+		}
+	}
+
+	r := &host.Resources
+	r.Memory = errs.byteSize(f.memory)
+	r.MemoryReservation = errs.byteSize(f.memoryReservation)
+	r.MemorySwap = errs.byteSize(f.memorySwap)
+	host.ShmSize = errs.byteSize(f.shmSize)
+	if f.fs.Changed("memory-swappiness") {
+		r.MemorySwappiness = &f.memorySwappiness
+	}
+	if f.oomKillDisable {
+		r.OomKillDisable = &f.oomKillDisable
+	}
+	host.OomScoreAdj = f.oomScoreAdj
+	if f.fs.Changed("pids-limit") {
+		r.PidsLimit = &f.pidsLimit
+	}
+	r.NanoCPUs = errs.cpus(f.cpus)
+	r.CPUShares, r.CPUPeriod, r.CPUQuota = f.cpuShares, f.cpuPeriod, f.cpuQuota
+	r.CPURealtimePeriod, r.CPURealtimeRuntime = f.cpuRtPeriod, f.cpuRtRuntime
+	r.CpusetCpus, r.CpusetMems = f.cpusetCpus, f.cpusetMems
+	r.CPUCount, r.CPUPercent = f.cpuCount, f.cpuPercent
+	r.IOMaximumIOps, r.IOMaximumBandwidth = f.ioMaxIOps, f.ioMaxBandwidth
+	r.BlkioWeight = f.blkioWeight
+	for _, device := range f.blkioWeightDevices {
+		path, weight, _ := strings.Cut(device, ":")
+		value, err := strconv.ParseUint(weight, 10, 16)
+		errs.add(err)
+		r.BlkioWeightDevice = append(r.BlkioWeightDevice, &blkiodev.WeightDevice{Path: path, Weight: uint16(value)})
+	}
+	r.BlkioDeviceReadBps = errs.throttles(f.readBps)
+	r.BlkioDeviceWriteBps = errs.throttles(f.writeBps)
+	r.BlkioDeviceReadIOps = errs.throttles(f.readIOps)
+	r.BlkioDeviceWriteIOps = errs.throttles(f.writeIOps)
+	for _, value := range f.gpus {
+		r.DeviceRequests = append(r.DeviceRequests, errs.gpus(value))
+	}
+
+	errs.add(b.setNetworks(f.networks, f.networkAliases, f.ip, f.ip6, f.linkLocalIPs))
+	if errs.err != nil {
+		return container.InspectResponse{}, errs.err
+	}
+	return b.info, nil
+}
+
+// -------------------- 回读: Compose --------------------
+
+// ComposeToInspect 将 ComposeFormatter 生成的 service 解析回容器配置。
+func ComposeToInspect(name string, svc ComposeService) (container.InspectResponse, error) {
+	if svc.ContainerName != "" {
+		name = svc.ContainerName
+	}
+	b := newRoundtripBuilder(name)
+	cfg, host := b.info.Config, b.info.HostConfig
+	errs := &roundtripErrors{}
+
+	cfg.Image = svc.Image
+	cfg.Cmd = svc.Command
+	cfg.Entrypoint = svc.Entrypoint
+	cfg.User = svc.User
+	cfg.WorkingDir = svc.WorkingDir
+	cfg.Hostname = svc.Hostname
+	cfg.Domainname = svc.Domainname
+	cfg.Tty = svc.Tty
+	cfg.OpenStdin = svc.StdinOpen
+	cfg.StopSignal = svc.StopSignal
+	cfg.Env = svc.Environment
+	cfg.Labels = copyStringMap(svc.Labels)
+	if svc.StopGracePeriod != "" {
+		seconds := int(errs.duration(svc.StopGracePeriod) / time.Second)
+		cfg.StopTimeout = &seconds
+	}
+	if hc := svc.Healthcheck; hc != nil {
+		if hc.Disable {
+			cfg.Healthcheck = &container.HealthConfig{Test: []string{"NONE"}}
+		} else {
+			cfg.Healthcheck = &container.HealthConfig{
+				Test:          hc.Test,
+				Interval:      errs.duration(hc.Interval),
+				Timeout:       errs.duration(hc.Timeout),
+				StartPeriod:   errs.duration(hc.StartPeriod),
+				StartInterval: errs.duration(hc.StartInterval),
+				Retries:       hc.Retries,
+			}
+		}
+	}
+
+	host.Privileged = svc.Privileged
+	host.ReadonlyRootfs = svc.ReadOnly
+	host.Init = svc.Init
+	host.Runtime = svc.Runtime
+	host.Isolation = container.Isolation(svc.Isolation)
+	host.PidMode = container.PidMode(svc.Pid)
+	host.IpcMode = container.IpcMode(svc.Ipc)
+	host.UTSMode = container.UTSMode(svc.Uts)
+	host.UsernsMode = container.UsernsMode(svc.UsernsMode)
+	host.CgroupnsMode = container.CgroupnsMode(svc.Cgroup)
+	host.CgroupParent = svc.CgroupParent
+	host.GroupAdd = svc.GroupAdd
+	host.DNS = errs.addrs(svc.DNS)
+	host.DNSSearch = svc.DNSSearch
+	host.DNSOptions = svc.DNSOpt
+	host.ExtraHosts = svc.ExtraHosts
+	host.CapAdd = svc.CapAdd
+	host.CapDrop = svc.CapDrop
+	host.SecurityOpt = svc.SecurityOpt
+	host.DeviceCgroupRules = svc.DeviceCgroupRules
+	host.Sysctls = copyStringMap(svc.Sysctls)
+	host.StorageOpt = copyStringMap(svc.StorageOpt)
+	host.Annotations = copyStringMap(svc.Annotations)
+	if svc.Restart != "" {
+		host.RestartPolicy = errs.restartPolicy(svc.Restart)
+	}
+	if svc.Logging != nil {
+		host.LogConfig = container.LogConfig{Type: svc.Logging.Driver, Config: copyStringMap(svc.Logging.Options)}
+	}
+	for _, device := range svc.Devices {
+		host.Devices = append(host.Devices, parseDeviceSpec(device))
+	}
+	for _, name := range sortedUlimitNames(svc.Ulimits) {
+		ulimit := svc.Ulimits[name]
+		host.Ulimits = append(host.Ulimits, &container.Ulimit{Name: name, Soft: ulimit.Soft, Hard: ulimit.Hard})
+	}
+	for _, v := range svc.Volumes {
+		b.addVolume(v)
+	}
+	for _, p := range svc.Ports {
+		errs.add(b.addPort(p))
+	}
+	for _, port := range svc.Expose {
+		errs.add(b.expose(port))
+	}
+	for _, t := range svc.Tmpfs {
+		b.addTmpfs(t)
+	}
+	for _, link := range svc.Links {
+		b.addLink(link)
+	}
+	for _, source := range svc.VolumesFrom {
+		host.VolumesFrom = append(host.VolumesFrom, strings.TrimPrefix(source, "container:"))
+	}
+
+	r := &host.Resources
+	r.Memory = errs.byteSize(svc.MemLimit)
+	r.MemoryReservation = errs.byteSize(svc.MemReservation)
+	r.MemorySwap = errs.byteSize(svc.MemSwapLimit)
+	host.ShmSize = errs.byteSize(svc.ShmSize)
+	r.MemorySwappiness = svc.MemSwappiness
+	if svc.OomKillDisable {
+		r.OomKillDisable = &svc.OomKillDisable
+	}
+	host.OomScoreAdj = svc.OomScoreAdj
+	if svc.PidsLimit > 0 {
+		r.PidsLimit = &svc.PidsLimit
+	}
+	r.NanoCPUs = int64(math.Round(svc.CPUs * 1e9))
+	r.CPUShares, r.CPUPeriod, r.CPUQuota = svc.CPUShares, svc.CPUPeriod, svc.CPUQuota
+	r.CPURealtimePeriod, r.CPURealtimeRuntime = svc.CPURTPeriod, svc.CPURTRuntime
+	r.CpusetCpus = svc.Cpuset
+	r.CPUCount, r.CPUPercent = svc.CPUCount, svc.CPUPercent
+	if blkio := svc.BlkioConfig; blkio != nil {
+		r.BlkioWeight = blkio.Weight
+		for _, device := range blkio.WeightDevice {
+			r.BlkioWeightDevice = append(r.BlkioWeightDevice, &blkiodev.WeightDevice{Path: device.Path, Weight: device.Weight})
+		}
+		r.BlkioDeviceReadBps = throttleDevices(blkio.DeviceReadBps)
+		r.BlkioDeviceWriteBps = throttleDevices(blkio.DeviceWriteBps)
+		r.BlkioDeviceReadIOps = throttleDevices(blkio.DeviceReadIOps)
+		r.BlkioDeviceWriteIOps = throttleDevices(blkio.DeviceWriteIOps)
+	}
+	if svc.Deploy != nil {
+		for _, device := range svc.Deploy.Resources.Reservations.Devices {
+			req := container.DeviceRequest{Driver: device.Driver, DeviceIDs: device.DeviceIDs, Options: device.Options}
+			switch count := device.Count.(type) {
+			case string:
+				if count == "all" {
+					req.Count = -1
+				}
+			case int:
+				req.Count = count
+			}
+			if len(device.Capabilities) > 0 {
+				req.Capabilities = [][]string{device.Capabilities}
+			}
+			r.DeviceRequests = append(r.DeviceRequests, req)
+		}
+	}
+
+	if len(svc.Networks) > 0 {
+		b.setComposeNetworks(svc.Networks)
+	} else {
+		var modes []string
+		if svc.NetworkMode != "" {
+			modes = []string{svc.NetworkMode}
+		}
+		errs.add(b.setNetworks(modes, nil, "", "", nil))
+	}
+	if errs.err != nil {
+		return container.InspectResponse{}, errs.err
+	}
+	return b.info, nil
+}
+
+// -------------------- 回读辅助 --------------------
+
+type roundtripBuilder struct {
+	info container.InspectResponse
+}
+
+func newRoundtripBuilder(name string) *roundtripBuilder {
+	return &roundtripBuilder{info: container.InspectResponse{
+		Name:            "/" + strings.TrimPrefix(name, "/"),
+		Config:          &container.Config{},
+		HostConfig:      &container.HostConfig{},
+		NetworkSettings: &container.NetworkSettings{},
+	}}
+}
+
+func (b *roundtripBuilder) addVolume(spec string) {
+	parts := strings.Split(spec, ":")
+	if len(parts) == 1 {
+		b.info.Mounts = append(b.info.Mounts, container.MountPoint{Type: mount.TypeVolume, Destination: spec, RW: true})
+		return
+	}
+	b.info.HostConfig.Binds = append(b.info.HostConfig.Binds, spec)
+	mp := container.MountPoint{Destination: parts[1], RW: true}
+	if len(parts) > 2 {
+		mp.Mode = parts[2]
+		for _, opt := range strings.Split(parts[2], ",") {
+			if opt == "ro" {
+				mp.RW = false
+			}
+		}
+	}
+	if strings.HasPrefix(parts[0], "/") || strings.HasPrefix(parts[0], ".") || strings.HasPrefix(parts[0], "~") {
+		mp.Type, mp.Source = mount.TypeBind, parts[0]
+	} else {
+		mp.Type, mp.Name = mount.TypeVolume, parts[0]
+	}
+	b.info.Mounts = append(b.info.Mounts, mp)
+}
+
+// addPort 解析 [ip:]host[-end]:container[-end]/proto。
+func (b *roundtripBuilder) addPort(spec string) error {
+	proto := "tcp"
+	if base, p, ok := strings.Cut(spec, "/"); ok {
+		spec, proto = base, p
+	}
+	idx := strings.LastIndex(spec, ":")
+	if idx < 0 {
+		return fmt.Errorf("无法解析端口映射 %q", spec)
+	}
+	containerPart, hostPart := spec[idx+1:], spec[:idx]
+	hostIP := ""
+	if i := strings.LastIndex(hostPart, ":"); i >= 0 {
+		hostIP, hostPart = strings.Trim(hostPart[:i], "[]"), hostPart[i+1:]
+	}
+	hostStart, hostEnd, err := parsePortRange(hostPart)
+	if err != nil {
+		return err
+	}
+	contStart, contEnd, err := parsePortRange(containerPart)
+	if err != nil {
+		return err
+	}
+	if hostEnd-hostStart != contEnd-contStart {
+		return fmt.Errorf("端口范围长度不一致 %q", spec)
+	}
+	var addr netip.Addr
+	if hostIP != "" {
+		if addr, err = netip.ParseAddr(hostIP); err != nil {
+			return err
+		}
+	}
+	if b.info.HostConfig.PortBindings == nil {
+		b.info.HostConfig.PortBindings = network.PortMap{}
+	}
+	for i := 0; i <= contEnd-contStart; i++ {
+		port, err := network.ParsePort(fmt.Sprintf("%d/%s", contStart+i, proto))
+		if err != nil {
+			return err
+		}
+		binding := network.PortBinding{HostIP: addr, HostPort: strconv.Itoa(hostStart + i)}
+		b.info.HostConfig.PortBindings[port] = append(b.info.HostConfig.PortBindings[port], binding)
+		b.exposePort(port)
+	}
+	return nil
+}
+
+func parsePortRange(value string) (int, int, error) {
+	startValue, endValue, isRange := strings.Cut(value, "-")
+	start, err := strconv.Atoi(startValue)
+	if err != nil {
+		return 0, 0, fmt.Errorf("无法解析端口 %q", value)
+	}
+	if !isRange {
+		return start, start, nil
+	}
+	end, err := strconv.Atoi(endValue)
+	if err != nil || end < start {
+		return 0, 0, fmt.Errorf("无法解析端口范围 %q", value)
+	}
+	return start, end, nil
+}
+
+func (b *roundtripBuilder) expose(value string) error {
+	port, err := network.ParsePort(value)
+	if err != nil {
+		return err
+	}
+	b.exposePort(port)
+	return nil
+}
+
+// exposePort 与 docker create 一致：发布的端口同时写入 ExposedPorts。
+func (b *roundtripBuilder) exposePort(port network.Port) {
+	if b.info.Config.ExposedPorts == nil {
+		b.info.Config.ExposedPorts = network.PortSet{}
+	}
+	b.info.Config.ExposedPorts[port] = struct{}{}
+}
+
+func (b *roundtripBuilder) addTmpfs(spec string) {
+	if b.info.HostConfig.Tmpfs == nil {
+		b.info.HostConfig.Tmpfs = map[string]string{}
+	}
+	path, opts, _ := strings.Cut(spec, ":")
+	b.info.HostConfig.Tmpfs[path] = opts
+}
+
+// addLink 还原为 daemon 存储的 "/db:/web/alias" 形式。
+func (b *roundtripBuilder) addLink(spec string) {
+	name, alias, ok := strings.Cut(spec, ":")
+	if !ok {
+		alias = name
+	}
+	b.info.HostConfig.Links = append(b.info.HostConfig.Links, fmt.Sprintf("/%s:%s/%s", name, b.info.Name, alias))
+}
+
+func (b *roundtripBuilder) setNetworks(values, aliases []string, ip, ip6 string, linkLocal []string) error {
+	host := b.info.HostConfig
+	endpoints := map[string]*network.EndpointSettings{}
+	for i, value := range values {
+		if !strings.HasPrefix(value, "name=") {
+			if value == "default" {
+				// daemon 将 default 解析为 bridge
+				value = "bridge"
+			}
+			host.NetworkMode = container.NetworkMode(value)
+			endpoints[value] = &network.EndpointSettings{}
+			continue
+		}
+		fields, err := csv.NewReader(strings.NewReader(value)).Read()
+		if err != nil {
+			return fmt.Errorf("无法解析 --network %q: %w", value, err)
+		}
+		var name string
+		endpoint := &network.EndpointSettings{}
+		for _, field := range fields {
+			key, val, _ := strings.Cut(field, "=")
+			switch key {
+			case "name":
+				name = val
+			case "alias":
+				endpoint.Aliases = append(endpoint.Aliases, val)
+			case "ip", "ip6", "link-local-ip":
+				if err := setEndpointIP(endpoint, key, val); err != nil {
+					return err
+				}
+			case "driver-opt":
+				k, v, _ := strings.Cut(val, "=")
+				if endpoint.DriverOpts == nil {
+					endpoint.DriverOpts = map[string]string{}
+				}
+				endpoint.DriverOpts[k] = v
+			case "gw-priority":
+				priority, err := strconv.Atoi(val)
+				if err != nil {
+					return err
+				}
+				endpoint.GwPriority = priority
+			default:
+				return fmt.Errorf("未知的 --network 选项 %q", key)
+			}
+		}
+		if i == 0 {
+			host.NetworkMode = container.NetworkMode(name)
+		}
+		endpoints[name] = endpoint
+	}
+	if host.NetworkMode == "" {
+		host.NetworkMode = "bridge"
+		endpoints["bridge"] = &network.EndpointSettings{}
+	}
+	if primary := endpoints[string(host.NetworkMode)]; primary != nil {
+		primary.Aliases = append(primary.Aliases, aliases...)
+		for _, item := range []struct {
+			key    string
+			values []string
+		}{{"ip", []string{ip}}, {"ip6", []string{ip6}}, {"link-local-ip", linkLocal}} {
+			for _, value := range item.values {
+				if value == "" {
+					continue
+				}
+				if err := setEndpointIP(primary, item.key, value); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if strings.HasPrefix(string(host.NetworkMode), "container:") {
+		endpoints = nil
+	}
+	b.info.NetworkSettings.Networks = endpoints
+	return nil
+}
+
+func (b *roundtripBuilder) setComposeNetworks(networks map[string]ComposeServiceNetwork) {
+	endpoints := map[string]*network.EndpointSettings{}
+	primary := ""
+	for name, cfg := range networks {
+		if primary == "" || cfg.GwPriority > networks[primary].GwPriority ||
+			cfg.GwPriority == networks[primary].GwPriority && name < primary {
+			primary = name
+		}
+		endpoint := &network.EndpointSettings{
+			Aliases:    cfg.Aliases,
+			DriverOpts: copyStringMap(cfg.DriverOpts),
+			GwPriority: cfg.GwPriority,
+		}
+		_ = setEndpointIP(endpoint, "ip", cfg.IPv4Address)
+		_ = setEndpointIP(endpoint, "ip6", cfg.IPv6Address)
+		for _, ip := range cfg.LinkLocalIPs {
+			_ = setEndpointIP(endpoint, "link-local-ip", ip)
+		}
+		endpoints[name] = endpoint
+	}
+	// 与 Compose 一致：gw_priority 最高者为主网络，相同时按名称
+	b.info.HostConfig.NetworkMode = container.NetworkMode(primary)
+	b.info.NetworkSettings.Networks = endpoints
+}
+
+func setEndpointIP(endpoint *network.EndpointSettings, key, value string) error {
+	if value == "" {
+		return nil
+	}
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return fmt.Errorf("无法解析 IP %q: %w", value, err)
+	}
+	if endpoint.IPAMConfig == nil {
+		endpoint.IPAMConfig = &network.EndpointIPAMConfig{}
+	}
+	switch key {
+	case "ip":
+		endpoint.IPAMConfig.IPv4Address = addr
+	case "ip6":
+		endpoint.IPAMConfig.IPv6Address = addr
+	default:
+		endpoint.IPAMConfig.LinkLocalIPs = append(endpoint.IPAMConfig.LinkLocalIPs, addr)
+	}
+	return nil
+}
+
+func parseKeyValues(items []string) map[string]string {
+	if len(items) == 0 {
+		return nil
+	}
+	result := make(map[string]string, len(items))
+	for _, item := range items {
+		key, value, _ := strings.Cut(item, "=")
+		result[key] = value
+	}
+	return result
+}
+
+// parseDeviceSpec 是 formatDevice 的逆过程，未写权限时与 docker 一样默认 rwm。
+func parseDeviceSpec(spec string) container.DeviceMapping {
+	parts := strings.Split(spec, ":")
+	device := container.DeviceMapping{PathOnHost: parts[0], PathInContainer: parts[0], CgroupPermissions: "rwm"}
+	if len(parts) > 1 {
+		device.PathInContainer = parts[1]
+	}
+	if len(parts) > 2 {
+		device.CgroupPermissions = parts[2]
+	}
+	return device
+}
+
+func throttleDevices(rates []BlkioRateSpec) []*blkiodev.ThrottleDevice {
+	var devices []*blkiodev.ThrottleDevice
+	for _, rate := range rates {
+		devices = append(devices, &blkiodev.ThrottleDevice{Path: rate.Path, Rate: rate.Rate})
+	}
+	return devices
+}
+
+func sortedUlimitNames(ulimits map[string]UlimitSpec) []string {
+	names := make([]string, 0, len(ulimits))
+	for name := range ulimits {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// roundtripErrors 收集第一个解析错误，让调用方按顺序平铺解析逻辑。
+type roundtripErrors struct {
+	err error
+}
+
+func (e *roundtripErrors) add(err error) {
+	if e.err == nil && err != nil {
+		e.err = err
+	}
+}
+
+func (e *roundtripErrors) duration(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	d, err := time.ParseDuration(value)
+	e.add(err)
+	return d
+}
+
+func (e *roundtripErrors) byteSize(value string) int64 {
+	if value == "" {
+		return 0
+	}
+	n, err := parseByteSize(value)
+	e.add(err)
+	return n
+}
+
+func (e *roundtripErrors) cpus(value string) int64 {
+	if value == "" {
+		return 0
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	e.add(err)
+	return int64(math.Round(f * 1e9))
+}
+
+func (e *roundtripErrors) addrs(values []string) []netip.Addr {
+	var addrs []netip.Addr
+	for _, value := range values {
+		addr, err := netip.ParseAddr(value)
+		e.add(err)
+		addrs = append(addrs, addr)
+	}
+	return addrs
+}
+
+func (e *roundtripErrors) restartPolicy(value string) container.RestartPolicy {
+	name, retries, ok := strings.Cut(value, ":")
+	policy := container.RestartPolicy{Name: container.RestartPolicyMode(name)}
+	if ok {
+		n, err := strconv.Atoi(retries)
+		e.add(err)
+		policy.MaximumRetryCount = n
+	}
+	return policy
+}
+
+func (e *roundtripErrors) ulimit(value string) *container.Ulimit {
+	name, limits, _ := strings.Cut(value, "=")
+	softValue, hardValue, ok := strings.Cut(limits, ":")
+	if !ok {
+		hardValue = softValue
+	}
+	soft, err := strconv.ParseInt(softValue, 10, 64)
+	e.add(err)
+	hard, err := strconv.ParseInt(hardValue, 10, 64)
+	e.add(err)
+	return &container.Ulimit{Name: name, Soft: soft, Hard: hard}
+}
+
+func (e *roundtripErrors) throttles(values []string) []*blkiodev.ThrottleDevice {
+	var devices []*blkiodev.ThrottleDevice
+	for _, value := range values {
+		path, rate, _ := strings.Cut(value, ":")
+		n, err := strconv.ParseUint(rate, 10, 64)
+		e.add(err)
+		devices = append(devices, &blkiodev.ThrottleDevice{Path: path, Rate: n})
+	}
+	return devices
+}
+
+// gpus 是 gpusValue 的逆过程。
+func (e *roundtripErrors) gpus(value string) container.DeviceRequest {
+	req := container.DeviceRequest{Capabilities: [][]string{{"gpu"}}}
+	if value == "all" {
+		req.Count = -1
+		return req
+	}
+	fields, err := csv.NewReader(strings.NewReader(value)).Read()
+	e.add(err)
+	for _, field := range fields {
+		key, val, _ := strings.Cut(field, "=")
+		switch key {
+		case "driver":
+			req.Driver = val
+		case "device":
+			req.DeviceIDs = strings.Split(val, ",")
+		case "count":
+			if val == "all" {
+				req.Count = -1
+			} else {
+				req.Count, err = strconv.Atoi(val)
+				e.add(err)
+			}
+		case "capabilities":
+			req.Capabilities = [][]string{append(strings.Split(val, ","), "gpu")}
+		default:
+			if req.Options == nil {
+				req.Options = map[string]string{}
+			}
+			req.Options[key] = val
+		}
+	}
+	return req
+}
+
+// parseByteSize 解析 formatByteSize 及 docker 常用的 b/k/m/g/t 后缀。
+func parseByteSize(value string) (int64, error) {
+	v := strings.ToLower(strings.TrimSpace(value))
+	v = strings.TrimSuffix(v, "b")
+	multiplier := int64(1)
+	if v != "" {
+		switch v[len(v)-1] {
+		case 'k':
+			multiplier = 1 << 10
+		case 'm':
+			multiplier = 1 << 20
+		case 'g':
+			multiplier = 1 << 30
+		case 't':
+			multiplier = 1 << 40
+		}
+		if multiplier > 1 {
+			v = v[:len(v)-1]
+		}
+	}
+	n, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, fmt.Errorf("无法解析大小 %q", value)
+	}
+	return int64(n * float64(multiplier)), nil
+}
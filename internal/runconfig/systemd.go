@@ -0,0 +1,591 @@
+package runconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/moby/moby/api/types/network"
+	"github.com/moby/moby/api/types/volume"
+)
+
+// QuadletUnit 是单个容器的 Quadlet 输出：.container 内容及其引用的 .volume/.network 名称。
+type QuadletUnit struct {
+	Container string
+	Volumes   []string
+	Networks  []string
+}
+
+// -------------------- QuadletFormatter --------------------
+
+// QuadletFormatter 将容器配置映射为 Podman Quadlet 的 .container 单元，没有专用键的参数写入 PodmanArgs。
+type QuadletFormatter struct{}
+
+func (f QuadletFormatter) Format(spec *ContainerSpec, opts ReverseOptions) QuadletUnit {
+	var unit QuadletUnit
+	w := &unitWriter{}
+	restart, burst := systemdRestart(spec.RestartPolicy)
+
+	w.section("Unit")
+	w.set("Description", spec.ContainerName+" container")
+	if burst > 0 {
+		w.set("StartLimitBurst", strconv.Itoa(burst))
+	}
+
+	w.section("Container")
+	w.set("ContainerName", spec.ContainerName)
+	w.set("Image", spec.Image)
+	podmanArgs := []string{}
+	podman := func(args ...string) { podmanArgs = append(podmanArgs, args...) }
+	if len(spec.Entrypoint) > 0 {
+		// podman 的 --entrypoint 接受 JSON 数组，可完整保留多段 entrypoint
+		entrypoint, _ := json.Marshal(spec.Entrypoint)
+		podman("--entrypoint=" + string(entrypoint))
+	}
+	if len(spec.Cmd) > 0 {
+		w.set("Exec", unitExecWords(spec.Cmd))
+	}
+	if spec.User != "" {
+		user, group, _ := strings.Cut(spec.User, ":")
+		w.set("User", user)
+		w.set("Group", group)
+	}
+	w.set("WorkingDir", spec.WorkingDir)
+	w.set("HostName", spec.Hostname)
+	for _, env := range spec.Envs {
+		w.set("Environment", unitQuote(env))
+	}
+	for _, label := range formatLabels(spec.Labels) {
+		w.set("Label", unitQuote(label))
+	}
+	for _, annotation := range formatMapOptions(spec.Annotations) {
+		w.set("Annotation", unitQuote(annotation))
+	}
+
+	for _, port := range quadletPorts(spec, opts) {
+		w.set("PublishPort", port)
+	}
+	for _, port := range spec.ExposedPorts {
+		w.set("ExposeHostPort", port)
+	}
+	for _, mount := range spec.Mounts {
+		// 命名卷引用同名 .volume 单元，由 Quadlet 负责创建并建立依赖
+		if m := k8sMounts([]string{mount})[0]; m.kind == "volume" {
+			unit.Volumes = append(unit.Volumes, m.source)
+			mount = m.source + ".volume" + strings.TrimPrefix(mount, m.source)
+		}
+		w.set("Volume", mount)
+	}
+	for _, tmpfs := range formatTmpfs(spec.Tmpfs) {
+		w.set("Tmpfs", tmpfs)
+	}
+	for _, source := range spec.VolumesFrom {
+		podman("--volumes-from", source)
+	}
+
+	networks, networkArgs := quadletNetworks(spec)
+	unit.Networks = networks
+	for _, line := range networkArgs {
+		w.set(line[0], line[1])
+	}
+	for _, dns := range spec.DNS {
+		w.set("DNS", dns)
+	}
+	for _, search := range spec.DNSSearch {
+		w.set("DNSSearch", search)
+	}
+	for _, opt := range spec.DNSOptions {
+		w.set("DNSOption", opt)
+	}
+	for _, host := range spec.ExtraHosts {
+		w.set("AddHost", host)
+	}
+
+	for _, capability := range spec.CapAdd {
+		w.set("AddCapability", capability)
+	}
+	for _, capability := range spec.CapDrop {
+		w.set("DropCapability", capability)
+	}
+	for _, opt := range spec.SecurityOpt {
+		switch opt {
+		case "label=disable", "label:disable":
+			w.set("SecurityLabelDisable", "true")
+		case "no-new-privileges", "no-new-privileges:true", "no-new-privileges=true":
+			w.set("NoNewPrivileges", "true")
+		case "seccomp=unconfined", "seccomp:unconfined":
+			w.set("SeccompProfile", "unconfined")
+		default:
+			podman("--security-opt", opt)
+		}
+	}
+	if spec.Privileged {
+		podman("--privileged")
+	}
+	for _, device := range spec.Devices {
+		w.set("AddDevice", device)
+	}
+	for _, req := range spec.DeviceRequests {
+		for _, device := range quadletGPUDevices(req) {
+			w.set("AddDevice", device)
+		}
+	}
+	if spec.ReadonlyRootfs {
+		w.set("ReadOnly", "true")
+	}
+	if spec.Init != nil && *spec.Init {
+		w.set("RunInit", "true")
+	}
+	for _, group := range spec.GroupAdd {
+		w.set("GroupAdd", group)
+	}
+	for _, opt := range formatMapOptions(spec.Sysctls) {
+		w.set("Sysctl", opt)
+	}
+	for _, ulimit := range formatUlimits(spec.Ulimits) {
+		w.set("Ulimit", ulimit)
+	}
+	w.set("LogDriver", spec.LogDriver)
+	for _, opt := range formatMapOptions(spec.LogOptions) {
+		w.set("LogOpt", opt)
+	}
+	w.set("StopSignal", spec.StopSignal)
+	if spec.StopTimeout != nil {
+		w.set("StopTimeout", strconv.Itoa(*spec.StopTimeout))
+	}
+	if h := spec.Healthcheck; h != nil && h.Disabled() {
+		podman("--no-healthcheck")
+	} else if h != nil {
+		w.set("HealthCmd", quadletHealthCmd(h))
+		for _, item := range []struct {
+			key   string
+			value string
+		}{
+			{"HealthInterval", durationValue(h.Interval)},
+			{"HealthTimeout", durationValue(h.Timeout)},
+			{"HealthStartPeriod", durationValue(h.StartPeriod)},
+		} {
+			w.set(item.key, item.value)
+		}
+		if h.Retries > 0 {
+			w.set("HealthRetries", strconv.Itoa(h.Retries))
+		}
+	}
+
+	if spec.Tty {
+		podman("--tty")
+	}
+	if spec.OpenStdin {
+		podman("--interactive")
+	}
+	if spec.PublishAllPorts {
+		podman("--publish-all")
+	}
+	for _, item := range [][2]string{
+		{"--domainname", spec.Domainname}, {"--runtime", spec.Runtime}, {"--pid", spec.PidMode},
+		{"--ipc", spec.IpcMode}, {"--uts", spec.UTSMode}, {"--userns", spec.UsernsMode},
+		{"--cgroupns", spec.CgroupnsMode}, {"--cgroup-parent", spec.CgroupParent},
+	} {
+		if item[1] != "" {
+			podman(item[0], item[1])
+		}
+	}
+	for _, arg := range commandResourceArgs(spec.Resources) {
+		podman(flagValueArgs(arg[0], arg[1])...)
+	}
+	for _, rule := range spec.DeviceCgroupRules {
+		podman("--device-cgroup-rule", rule)
+	}
+	for _, opt := range formatMapOptions(spec.StorageOpt) {
+		podman("--storage-opt", opt)
+	}
+	if len(podmanArgs) > 0 {
+		w.set("PodmanArgs", unitExecWords(podmanArgs))
+	}
+
+	w.section("Service")
+	w.set("Restart", restart)
+	w.section("Install")
+	w.set("WantedBy", "default.target")
+
+	unit.Container = w.String()
+	return unit
+}
+
+// Warnings 返回 Podman/Quadlet 无法表达的配置。
+func (f QuadletFormatter) Warnings(spec *ContainerSpec) []string {
+	warnings := copyStringSlice(spec.Unsupported)
+	warnings = append(warnings, quadletNetworkWarnings(spec)...)
+	for _, link := range spec.Links {
+		warnings = append(warnings, fmt.Sprintf("link %s: Podman 不支持 --link，请改用同一网络内的容器名访问", link))
+	}
+	if spec.Isolation != "" {
+		warnings = append(warnings, fmt.Sprintf("isolation=%s: Podman 不支持 Windows 隔离模式", spec.Isolation))
+	}
+	if spec.VolumeDriver != "" {
+		warnings = append(warnings, fmt.Sprintf("volume-driver=%s: 请在 .volume 单元中设置 Driver", spec.VolumeDriver))
+	}
+	if spec.ContainerIDFile != "" {
+		warnings = append(warnings, "cidfile: 容器由 Quadlet 管理，不再写入 cidfile")
+	}
+	if h := spec.Healthcheck; h != nil && !h.Disabled() && h.StartInterval > 0 {
+		warnings = append(warnings, fmt.Sprintf("health-start-interval=%s: Podman 无对应参数", h.StartInterval))
+	}
+	for _, req := range spec.DeviceRequests {
+		if isGPURequest(req) {
+			warnings = append(warnings, "GPU: 以 CDI 设备 nvidia.com/gpu 映射，需先在主机上生成 NVIDIA CDI 配置")
+		} else {
+			warnings = append(warnings, fmt.Sprintf("DeviceRequest driver=%s: Podman 仅支持通过 CDI 设备映射", req.Driver))
+		}
+	}
+	if _, burst := systemdRestart(spec.RestartPolicy); burst > 0 {
+		warnings = append(warnings, fmt.Sprintf("restart=%s: 最大重试次数近似为 StartLimitBurst=%d，按 systemd 的时间窗口计数", spec.RestartPolicy, burst))
+	}
+	return warnings
+}
+
+// quadletNetworks 返回引用的 .network 名称、对应的 Network/NetworkAlias/IP 键值以及无法表达的网络配置。
+func quadletNetworks(spec *ContainerSpec) ([]string, [][2]string) {
+	var networks []string
+	var lines [][2]string
+	if len(spec.Networks) == 0 || !isUserDefinedNetwork(spec.Networks[0].Name) {
+		switch mode := spec.NetworkMode; {
+		case mode == "" || mode == "default" || mode == "bridge":
+		case isUserDefinedNetwork(mode):
+			networks = append(networks, mode)
+			lines = append(lines, [2]string{"Network", mode + ".network"})
+		default:
+			lines = append(lines, [2]string{"Network", mode})
+		}
+		return networks, lines
+	}
+	for i, endpoint := range spec.Networks {
+		networks = append(networks, endpoint.Name)
+		lines = append(lines, [2]string{"Network", endpoint.Name + ".network"})
+		if i > 0 {
+			continue
+		}
+		for _, alias := range endpoint.Aliases {
+			lines = append(lines, [2]string{"NetworkAlias", alias})
+		}
+		if endpoint.IPv4Address != "" {
+			lines = append(lines, [2]string{"IP", endpoint.IPv4Address})
+		}
+		if endpoint.IPv6Address != "" {
+			lines = append(lines, [2]string{"IP6", endpoint.IPv6Address})
+		}
+	}
+	return networks, lines
+}
+
+func quadletNetworkWarnings(spec *ContainerSpec) []string {
+	var warnings []string
+	if len(spec.Networks) > 0 && !isUserDefinedNetwork(spec.Networks[0].Name) {
+		for i := 1; i < len(spec.Networks); i++ {
+			warnings = append(warnings, fmt.Sprintf("网络 %s: 主网络为 %s 时无法同时连接自定义网络", spec.Networks[i].Name, spec.NetworkMode))
+		}
+		return warnings
+	}
+	for i, endpoint := range spec.Networks {
+		if i > 0 && (len(endpoint.Aliases) > 0 || endpoint.IPv4Address != "" || endpoint.IPv6Address != "") {
+			warnings = append(warnings, fmt.Sprintf("网络 %s 的别名/静态 IP: Quadlet 只能为主网络设置 NetworkAlias/IP", endpoint.Name))
+		}
+		if len(endpoint.LinkLocalIPs) > 0 || len(endpoint.DriverOpts) > 0 || endpoint.GwPriority != 0 {
+			warnings = append(warnings, fmt.Sprintf("网络 %s 的 link-local-ip/driver-opt/gw-priority: Quadlet 无对应键", endpoint.Name))
+		}
+	}
+	return warnings
+}
+
+func quadletPorts(spec *ContainerSpec, opts ReverseOptions) []string {
+	if opts.MergePorts {
+		return mergePortRanges(spec.PortBindings)
+	}
+	var ports []string
+	for _, b := range spec.PortBindings {
+		if b.HostIP == "" {
+			ports = append(ports, fmt.Sprintf("%d:%d/%s", b.HostPort, b.ContPort, b.Proto))
+		} else {
+			ports = append(ports, fmt.Sprintf("%s:%d:%d/%s", b.HostIP, b.HostPort, b.ContPort, b.Proto))
+		}
+	}
+	return ports
+}
+
+// quadletHealthCmd 对 CMD 形式使用 JSON 数组，保持逐参数执行语义。
+func quadletHealthCmd(h *HealthcheckSpec) string {
+	if len(h.Test) < 2 {
+		return ""
+	}
+	if h.Test[0] == "CMD" {
+		command, _ := json.Marshal(h.Test[1:])
+		return unitEscape(string(command))
+	}
+	return unitEscape(strings.Join(h.Test[1:], " "))
+}
+
+func quadletGPUDevices(req DeviceRequestSpec) []string {
+	if !isGPURequest(req) {
+		return nil
+	}
+	if len(req.DeviceIDs) > 0 {
+		var devices []string
+		for _, id := range req.DeviceIDs {
+			devices = append(devices, "nvidia.com/gpu="+id)
+		}
+		return devices
+	}
+	if req.Count > 0 {
+		var devices []string
+		for i := 0; i < req.Count; i++ {
+			devices = append(devices, "nvidia.com/gpu="+strconv.Itoa(i))
+		}
+		return devices
+	}
+	return []string{"nvidia.com/gpu=all"}
+}
+
+func durationValue(d time.Duration) string {
+	if d <= 0 {
+		return ""
+	}
+	return d.String()
+}
+
+// flagValueArgs 与 CommandFormatter 一致，以 "-" 开头的取值写成 --flag=value。
+func flagValueArgs(flag, value string) []string {
+	switch {
+	case value == "":
+		return []string{flag}
+	case strings.HasPrefix(value, "-"):
+		return []string{flag + "=" + value}
+	default:
+		return []string{flag, value}
+	}
+}
+
+// QuadletVolumeUnit 根据卷元数据生成 .volume 单元，VolumeName 保持原卷名而非 Quadlet 默认的 systemd- 前缀。
+func QuadletVolumeUnit(name string, meta volume.Volume) string {
+	w := &unitWriter{}
+	w.section("Unit")
+	w.set("Description", name+" volume")
+	w.section("Volume")
+	w.set("VolumeName", name)
+	if meta.Driver != "local" {
+		w.set("Driver", meta.Driver)
+	}
+	for _, label := range formatLabels(meta.Labels) {
+		w.set("Label", unitQuote(label))
+	}
+	var podmanArgs []string
+	for _, key := range sortedKeys(meta.Options) {
+		value := meta.Options[key]
+		switch {
+		case key == "type":
+			w.set("Type", value)
+		case key == "device":
+			w.set("Device", unitQuote(value))
+		case key == "o":
+			w.set("Options", unitQuote(value))
+		default:
+			podmanArgs = append(podmanArgs, "--opt", key+"="+value)
+		}
+	}
+	if len(podmanArgs) > 0 {
+		w.set("PodmanArgs", unitExecWords(podmanArgs))
+	}
+	return w.String()
+}
+
+// QuadletNetworkUnit 根据网络元数据生成 .network 单元，NetworkName 保持原网络名。
+func QuadletNetworkUnit(name string, meta network.Inspect) string {
+	w := &unitWriter{}
+	w.section("Unit")
+	w.set("Description", name+" network")
+	w.section("Network")
+	w.set("NetworkName", name)
+	if meta.Driver != "bridge" {
+		w.set("Driver", meta.Driver)
+	}
+	if meta.IPAM.Driver != "default" {
+		w.set("IPAMDriver", meta.IPAM.Driver)
+	}
+	for _, cfg := range meta.IPAM.Config {
+		if cfg.Subnet.IsValid() {
+			w.set("Subnet", cfg.Subnet.String())
+		}
+		if cfg.Gateway.IsValid() {
+			w.set("Gateway", cfg.Gateway.String())
+		}
+		if cfg.IPRange.IsValid() {
+			w.set("IPRange", cfg.IPRange.String())
+		}
+	}
+	if meta.EnableIPv6 {
+		w.set("IPv6", "true")
+	}
+	if meta.Internal {
+		w.set("Internal", "true")
+	}
+	for _, label := range formatLabels(meta.Labels) {
+		w.set("Label", unitQuote(label))
+	}
+	for _, opt := range formatMapOptions(meta.Options) {
+		w.set("Options", unitQuote(opt))
+	}
+	return w.String()
+}
+
+// -------------------- SystemdFormatter --------------------
+
+// SystemdFormatter 生成以前台 docker run 运行容器的 systemd service，重启由 systemd 接管。
+type SystemdFormatter struct{}
+
+func (f SystemdFormatter) Format(spec *ContainerSpec, opts ReverseOptions) string {
+	restart, burst := systemdRestart(spec.RestartPolicy)
+	w := &unitWriter{}
+
+	w.section("Unit")
+	w.set("Description", spec.ContainerName+" container")
+	w.set("Requires", "docker.service")
+	w.set("After", "docker.service network-online.target")
+	w.set("Wants", "network-online.target")
+	if burst > 0 {
+		w.set("StartLimitBurst", strconv.Itoa(burst))
+	}
+
+	w.section("Service")
+	w.set("Restart", restart)
+	for _, network := range systemdNetworks(spec) {
+		w.set("ExecStartPre", "-/usr/bin/docker network create "+unitQuote(network))
+	}
+	w.set("ExecStartPre", "-/usr/bin/docker rm -f "+unitQuote(spec.ContainerName))
+	w.set("ExecStart", "/usr/bin/"+unitExecWords(withoutSplitMarker(systemdRunArgs(CommandFormatter{}.Format(spec, opts)))))
+	stop := "/usr/bin/docker stop"
+	if spec.StopTimeout != nil {
+		stop += " -t " + strconv.Itoa(*spec.StopTimeout)
+	}
+	w.set("ExecStop", stop+" "+unitQuote(spec.ContainerName))
+
+	w.section("Install")
+	w.set("WantedBy", "multi-user.target")
+	return w.String()
+}
+
+// Warnings 返回 docker run 本身无法表达的配置，以及重启策略近似映射的说明。
+func (f SystemdFormatter) Warnings(spec *ContainerSpec) []string {
+	warnings := CommandFormatter{}.Warnings(spec)
+	if _, burst := systemdRestart(spec.RestartPolicy); burst > 0 {
+		warnings = append(warnings, fmt.Sprintf("restart=%s: 最大重试次数近似为 StartLimitBurst=%d，按 systemd 的时间窗口计数", spec.RestartPolicy, burst))
+	}
+	return warnings
+}
+
+// systemdRunArgs 去掉 docker run 选项中的 -d 和 --restart，使容器在前台运行并由 systemd 负责重启；
+// 分隔符及其后的镜像和命令原样保留，命令里的同名参数不受影响。
+func systemdRunArgs(args []string) []string {
+	var result []string
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == CommandSplitMarker:
+			return append(result, args[i:]...)
+		case arg == "-d":
+		case arg == "--restart":
+			i++
+		case strings.HasPrefix(arg, "--restart="):
+		default:
+			result = append(result, arg)
+		}
+	}
+	return result
+}
+
+// withoutSplitMarker 去掉 CommandFormatter 在镜像前插入的分隔符。
+func withoutSplitMarker(args []string) []string {
+	for i, arg := range args {
+		if arg == CommandSplitMarker {
+			return append(append([]string(nil), args[:i]...), args[i+1:]...)
+		}
+	}
+	return args
+}
+
+func systemdNetworks(spec *ContainerSpec) []string {
+	var networks []string
+	if len(spec.Networks) > 0 {
+		for _, endpoint := range spec.Networks {
+			if isUserDefinedNetwork(endpoint.Name) {
+				networks = append(networks, endpoint.Name)
+			}
+		}
+		return networks
+	}
+	if isUserDefinedNetwork(spec.NetworkMode) {
+		networks = append(networks, spec.NetworkMode)
+	}
+	return networks
+}
+
+// systemdRestart 将 Docker 重启策略翻译为 systemd Restart=，on-failure:N 的次数通过 StartLimitBurst 近似。
+func systemdRestart(policy string) (string, int) {
+	name, count, _ := strings.Cut(policy, ":")
+	switch name {
+	case "always", "unless-stopped":
+		return "always", 0
+	case "on-failure":
+		burst, _ := strconv.Atoi(count)
+		return "on-failure", burst
+	default:
+		return "no", 0
+	}
+}
+
+// -------------------- unit 文件写入 --------------------
+
+type unitWriter struct {
+	sb strings.Builder
+}
+
+func (w *unitWriter) section(name string) {
+	if w.sb.Len() > 0 {
+		w.sb.WriteString("\n")
+	}
+	w.sb.WriteString("[" + name + "]\n")
+}
+
+// set 写入一行 key=value，空值跳过。
+func (w *unitWriter) set(key, value string) {
+	if value == "" {
+		return
+	}
+	w.sb.WriteString(key + "=" + value + "\n")
+}
+
+func (w *unitWriter) String() string {
+	return w.sb.String()
+}
+
+// unitEscape 转义 unit 文件中的 % 说明符。
+func unitEscape(value string) string {
+	return strings.ReplaceAll(value, "%", "%%")
+}
+
+// unitQuote 按 systemd 的引号规则包裹含空白或特殊字符的取值。
+func unitQuote(value string) string {
+	if value != "" && !strings.ContainsAny(value, " \t\n\"'\\;") {
+		return unitEscape(value)
+	}
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`)
+	return `"` + unitEscape(replacer.Replace(value)) + `"`
+}
+
+// unitExecWords 拼接命令行参数，额外转义 $ 以免被 systemd 当作环境变量展开。
+func unitExecWords(args []string) string {
+	words := make([]string, 0, len(args))
+	for _, arg := range args {
+		words = append(words, strings.ReplaceAll(unitQuote(arg), "$", "$$"))
+	}
+	return strings.Join(words, " ")
+}